@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os"
@@ -10,10 +11,14 @@ import (
 	"time"
 
 	"github.com/bemindtech/bmt-manta-dashboard-service/config"
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/alert"
 	"github.com/bemindtech/bmt-manta-dashboard-service/internal/api"
 	"github.com/bemindtech/bmt-manta-dashboard-service/internal/db"
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/events"
 	"github.com/bemindtech/bmt-manta-dashboard-service/internal/firebase"
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/models"
 	"github.com/bemindtech/bmt-manta-dashboard-service/internal/services"
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/storage"
 	"github.com/gofiber/fiber/v2"
 	"github.com/swaggo/fiber-swagger"
 	_ "github.com/bemindtech/bmt-manta-dashboard-service/docs" // ส่งออก docs
@@ -71,12 +76,62 @@ func main() {
 		log.Println("เชื่อมต่อกับ Firebase สำเร็จ")
 	}
 
+	// สร้างช่องทางการแจ้งเตือน
+	notifier := alert.NewNotifier(cfg)
+
+	// สร้างตัวกระจายเหตุการณ์ของ live stream (ใช้ Redis pub/sub ถ้ามี เพื่อกระจายไปทุก replica)
+	broker := events.NewBroker(redisClient, cfg.StreamBufferSize)
+
+	// สร้างบริการจัดเก็บรูปภาพ และตัวสร้าง URL ของรูปภาพที่มีอายุจำกัดสำหรับ response, live stream และการแจ้งเตือน
+	storageService, err := storage.NewStorageService(cfg)
+	if errors.Is(err, storage.ErrWeakSigningKey) {
+		// URL ที่มีลายเซ็นเป็นสิทธิ์เดียวในการอ่านและเขียนไฟล์ของ storage ในเครื่อง จึงไม่เริ่ม service ด้วย key ที่คาดเดาได้
+		log.Fatalf("ไม่สามารถเริ่มต้นบริการจัดเก็บข้อมูล: %v", err)
+	}
+	if err != nil {
+		log.Printf("ไม่สามารถเริ่มต้นบริการจัดเก็บข้อมูล: %v", err)
+		storageService = nil
+	}
+	imageURLs := services.NewImageURLSigner(storageService, cfg.SignedURLTTL)
+
 	// สร้าง service
 	statsService := services.NewStatsService(postgres, redisClient)
+	anomalyService := services.NewAnomalyService(postgres, notifier, cfg.AnomalyBaselineWeeks, cfg.AnomalyZThreshold)
+	occupancyService := services.NewOccupancyService(postgres, notifier, cfg.OccupancyTimeout)
+	forecastService := services.NewForecastService(postgres, cfg.ForecastHistoryWeeks, cfg.ForecastHorizonDays, cfg.ForecastHolidayAware)
+	watchlistService := services.NewWatchlistService(postgres, notifier, broker, imageURLs, cfg.WatchlistAlertCooldown)
+	faceIndex := services.NewFaceIndex(postgres, cfg.FaceSearchHNSWThreshold)
+	jobService := services.NewJobService(postgres, cfg.JobMaxConcurrent)
+
+	// เริ่มการตรวจหาความผิดปกติของปริมาณคนเป็นระยะ
+	jobCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+	anomalyService.StartDetectionJob(jobCtx, cfg.AnomalyCheckInterval)
+
+	// เริ่มการสร้างผลพยากรณ์ปริมาณคนทุกคืน
+	forecastService.StartNightlyJob(jobCtx, cfg.ForecastRefreshHour)
+
+	// เริ่มการปิดช่วงการอยู่ในสาขาของคนที่ไม่ถูกพบเกินเวลาที่กำหนด
+	occupancyService.StartTimeoutJob(jobCtx, cfg.OccupancyCheckInterval)
+
+	// โหลดดัชนีใบหน้าสำหรับค้นหาด้วย embedding และรับ embedding ที่ replica อื่นบันทึกเป็นระยะ
+	faceIndex.StartSyncJob(jobCtx, cfg.FaceIndexSyncInterval)
+
+	// ทำเครื่องหมายงานเบื้องหลังที่ค้างจากการเริ่ม service ใหม่ว่าล้มเหลว
+	jobService.StartStaleJobCleanupJob(jobCtx)
+
+	// เริ่มการเก็บกวาดคำขออัปโหลดรูปภาพที่ไม่ถูกยืนยันจนหมดอายุ
+	if storageService != nil {
+		services.NewFaceService(postgres, storageService, nil, imageURLs, cfg.ThumbnailSizes, cfg.UploadIntentTTL, cfg.FaceBatchConcurrency, faceIndex).StartUploadIntentCleanupJob(jobCtx, cfg.UploadIntentCleanupInterval)
+	}
+
+	// เริ่มรับเหตุการณ์จาก replica อื่นและส่งตัวนับรายนาทีไปยัง live stream
+	broker.Start(jobCtx)
+	services.NewLiveCounterService(postgres, broker).StartCounterJob(jobCtx)
 
 	// เริ่มต้นการซิงค์ข้อมูลจาก Firebase (ถ้ามี)
 	if firebaseClient != nil {
-		syncService := services.NewSyncService(postgres, firebaseClient)
+		syncService := services.NewSyncService(postgres, firebaseClient, occupancyService, watchlistService, broker)
 		ctx := context.Background()
 
 		// ซิงค์ข้อมูลเก่า
@@ -92,6 +147,8 @@ func main() {
 
 	// สร้างแอปพลิเคชัน Fiber
 	app := fiber.New(fiber.Config{
+		// รับ multipart ของรูปภาพใบหน้าได้ถึงขนาดสูงสุดที่องค์กรตั้งค่าได้ และ archive ของการอัปโหลดแบบกลุ่ม (ค่าเริ่มต้นของ Fiber คือ 4MB)
+		BodyLimit: max(models.MaxFaceImageFileSize, int(cfg.FaceBatchMaxSize)) + 1024*1024,
 		ErrorHandler: func(c *fiber.Ctx, err error) error {
 			// ส่งข้อผิดพลาดในรูปแบบ JSON
 			code := fiber.StatusInternalServerError
@@ -111,7 +168,7 @@ func main() {
 	app.Get("/docs/*", fiberSwagger.WrapHandler)
 
 	// ตั้งค่าเส้นทาง API
-	api.SetupRoutes(app, cfg, postgres, statsService)
+	api.SetupRoutes(app, cfg, postgres, storageService, jobService, imageURLs, statsService, anomalyService, forecastService, occupancyService, watchlistService, faceIndex, broker)
 
 	// สร้าง channel สำหรับรับสัญญาณ interrupt
 	shutdownChan := make(chan os.Signal, 1)
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/bemindtech/bmt-manta-dashboard-service/config"
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/db"
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/models"
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/services"
+)
+
+// parseTime แปลงเวลาในรูปแบบ RFC3339 หรือ YYYY-MM-DD
+func parseTime(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		parsed, err = time.Parse("2006-01-02", value)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &parsed, nil
+}
+
+// สร้างข้อมูลที่คำนวณจาก person_logs ใหม่ (is_new_person และ first_seen, last_seen, visit_count ของบุคคล)
+// งานถูกบันทึกในตาราง jobs เช่นเดียวกับงานที่เริ่มจาก API จึงดูความคืบหน้าผ่าน /api/admin/rebuilds ได้
+// และถ้าถูกหยุดกลางทาง (Ctrl+C) ให้ทำต่อด้วย -resume <job id>
+func main() {
+	organizationID := flag.String("org", "", "organization ID (required)")
+	from := flag.String("from", "", "rebuild persons seen from this time (RFC3339 or YYYY-MM-DD)")
+	to := flag.String("to", "", "rebuild persons seen before this time (RFC3339 or YYYY-MM-DD)")
+	dryRun := flag.Bool("dry-run", false, "only report what would change")
+	chunkSize := flag.Int("chunk-size", 0, "persons per transaction (default 500)")
+	resume := flag.String("resume", "", "resume a failed rebuild job by ID")
+	flag.Parse()
+
+	if *organizationID == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	// โหลดการตั้งค่า
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("ไม่สามารถโหลดการตั้งค่า: %v", err)
+	}
+
+	// เชื่อมต่อกับ PostgreSQL
+	postgres, err := db.NewPostgresDB(cfg)
+	if err != nil {
+		log.Fatalf("ไม่สามารถเชื่อมต่อกับ PostgreSQL: %v", err)
+	}
+	defer postgres.Close()
+
+	// สร้างตารางที่จำเป็น
+	if err := postgres.InitTables(); err != nil {
+		log.Fatalf("ไม่สามารถสร้างตาราง: %v", err)
+	}
+
+	// เชื่อมต่อกับ Redis (ถ้ามี) เพื่อล้าง cache สถิติเมื่อข้อมูลเปลี่ยน
+	var redisClient *db.RedisClient
+	if cfg.RedisHost != "" {
+		redisClient, err = db.NewRedisClient(cfg)
+		if err != nil {
+			log.Printf("ไม่สามารถเชื่อมต่อกับ Redis: %v", err)
+		} else {
+			defer redisClient.Close()
+		}
+	}
+
+	jobService := services.NewJobService(postgres, 1)
+	rebuildService := services.NewRebuildService(postgres, jobService, services.NewStatsService(postgres, redisClient))
+
+	// ยกเลิกงานเมื่อได้รับสัญญาณ interrupt งานจะหยุดหลัง chunk ปัจจุบันและทำต่อได้ภายหลัง
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var job *models.Job
+	if *resume != "" {
+		job, err = rebuildService.ClaimRebuildJob(ctx, *resume, *organizationID)
+	} else {
+		params := models.RebuildParams{
+			OrganizationID: *organizationID,
+			DryRun:         *dryRun,
+			ChunkSize:      *chunkSize,
+		}
+		if params.From, err = parseTime(*from); err != nil {
+			log.Fatalf("รูปแบบของ from ไม่ถูกต้อง: %v", err)
+		}
+		if params.To, err = parseTime(*to); err != nil {
+			log.Fatalf("รูปแบบของ to ไม่ถูกต้อง: %v", err)
+		}
+		job, err = rebuildService.CreateRebuildJob(ctx, params)
+	}
+	if err != nil {
+		log.Fatalf("ไม่สามารถเริ่มงานสร้างข้อมูลใหม่: %v", err)
+	}
+
+	log.Printf("เริ่มงานสร้างข้อมูลใหม่ %s", job.ID)
+	result, err := jobService.Execute(ctx, job, rebuildService.RunRebuildJob)
+	if err != nil {
+		log.Fatalf("งานสร้างข้อมูลใหม่ %s ล้มเหลว ทำต่อได้ด้วย -resume %s: %v", job.ID, job.ID, err)
+	}
+
+	// แสดงผลลัพธ์ (รวมรายการเปลี่ยนแปลงของ dry run) ในรูปแบบ JSON
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(result); err != nil {
+		log.Fatalf("ไม่สามารถแสดงผลลัพธ์: %v", err)
+	}
+}
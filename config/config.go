@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
@@ -37,6 +38,14 @@ type Config struct {
 	S3AccessKey    string
 	S3SecretKey    string
 	S3UsePathStyle bool
+
+	// การตั้งค่าการเก็บรูปภาพในเครื่อง (เมื่อไม่ได้ใช้ S3)
+	LocalStoragePath string
+	PublicBaseURL    string // URL ภายนอกของ API ใช้สร้าง URL ของรูปภาพ เช่น https://dashboard.example.com
+
+	// การตั้งค่า URL ของรูปภาพที่มีอายุจำกัด
+	URLSigningKey string
+	SignedURLTTL  time.Duration
 	
 	// การตั้งค่า Redis
 	RedisHost     string
@@ -52,6 +61,53 @@ type Config struct {
 	// การตั้งค่า Rate Limiting
 	RateLimitMax      int
 	RateLimitDuration time.Duration
+
+	// การตั้งค่าการแจ้งเตือน
+	AlertWebhookURL     string
+	AlertWebhookTimeout time.Duration
+
+	// การตั้งค่าการตรวจจับความผิดปกติของปริมาณคน
+	AnomalyBaselineWeeks int
+	AnomalyZThreshold    float64
+	AnomalyCheckInterval time.Duration
+
+	// การตั้งค่าการพยากรณ์ปริมาณคน
+	ForecastHistoryWeeks int
+	ForecastHorizonDays  int
+	ForecastRefreshHour  int
+	ForecastHolidayAware bool
+
+	// การตั้งค่าการนับจำนวนคนในสาขา
+	OccupancyTimeout       time.Duration
+	OccupancyCheckInterval time.Duration
+
+	// การตั้งค่า live stream
+	StreamBufferSize int
+	StreamHeartbeat  time.Duration
+
+	// การตั้งค่างานเบื้องหลัง (จำนวนงานที่ทำพร้อมกันของแต่ละประเภทงาน)
+	JobMaxConcurrent int
+
+	// การตั้งค่ารูปย่อของรูปภาพใบหน้า (ขนาดด้านของรูปสี่เหลี่ยมจัตุรัสเป็น pixel)
+	ThumbnailSizes []int
+
+	// การตั้งค่าการอัปโหลดรูปภาพใบหน้าตรงไปยัง storage
+	UploadIntentTTL             time.Duration
+	UploadIntentCleanupInterval time.Duration
+
+	// การตั้งค่าการอัปโหลดรูปภาพใบหน้าแบบกลุ่มจาก archive
+	FaceBatchMaxSize     int64 // bytes
+	FaceBatchConcurrency int
+
+	// การตั้งค่าการค้นหาใบหน้าด้วย embedding
+	FaceSearchHNSWThreshold int // องค์กรที่มี embedding อย่างน้อยเท่านี้ใช้ดัชนี HNSW แทนการเปรียบเทียบทุกรายการ
+	FaceIndexSyncInterval   time.Duration
+
+	// การตั้งค่า timeline การเข้าชมของบุคคล
+	JourneyVisitGap time.Duration
+
+	// การตั้งค่าการแจ้งเตือนรายการเฝ้าระวัง
+	WatchlistAlertCooldown time.Duration
 }
 
 // Load โหลดการตั้งค่าจากไฟล์ .env และตัวแปรสภาพแวดล้อม
@@ -68,6 +124,42 @@ func Load() (*Config, error) {
 	s3Enabled, _ := strconv.ParseBool(getEnv("S3_ENABLED", "false"))
 	s3UsePathStyle, _ := strconv.ParseBool(getEnv("S3_USE_PATH_STYLE", "false"))
 
+	alertWebhookTimeout, _ := time.ParseDuration(getEnv("ALERT_WEBHOOK_TIMEOUT", "10s"))
+	anomalyBaselineWeeks, _ := strconv.Atoi(getEnv("ANOMALY_BASELINE_WEEKS", "4"))
+	anomalyZThreshold, _ := strconv.ParseFloat(getEnv("ANOMALY_Z_THRESHOLD", "3"), 64)
+	anomalyCheckInterval, _ := time.ParseDuration(getEnv("ANOMALY_CHECK_INTERVAL", "1h"))
+
+	forecastHistoryWeeks, _ := strconv.Atoi(getEnv("FORECAST_HISTORY_WEEKS", "8"))
+	forecastHorizonDays, _ := strconv.Atoi(getEnv("FORECAST_HORIZON_DAYS", "7"))
+	forecastRefreshHour, _ := strconv.Atoi(getEnv("FORECAST_REFRESH_HOUR", "2"))
+	forecastHolidayAware, _ := strconv.ParseBool(getEnv("FORECAST_HOLIDAY_AWARE", "true"))
+
+	occupancyTimeout, _ := time.ParseDuration(getEnv("OCCUPANCY_TIMEOUT", "2h"))
+	occupancyCheckInterval, _ := time.ParseDuration(getEnv("OCCUPANCY_CHECK_INTERVAL", "1m"))
+
+	streamBufferSize, _ := strconv.Atoi(getEnv("STREAM_BUFFER_SIZE", "1000"))
+	streamHeartbeat, _ := time.ParseDuration(getEnv("STREAM_HEARTBEAT", "15s"))
+
+	// EXPORT_MAX_CONCURRENT เป็นชื่อเดิมของ JOB_MAX_CONCURRENT ซึ่งยังรับไว้สำหรับการตั้งค่าเดิม
+	jobMaxConcurrent, _ := strconv.Atoi(getEnv("JOB_MAX_CONCURRENT", getEnv("EXPORT_MAX_CONCURRENT", "2")))
+
+	signedURLTTL, _ := time.ParseDuration(getEnv("SIGNED_URL_TTL", "15m"))
+
+	thumbnailSizes := getEnvInts("THUMBNAIL_SIZES", "48,160")
+
+	uploadIntentTTL, _ := time.ParseDuration(getEnv("UPLOAD_INTENT_TTL", "15m"))
+	uploadIntentCleanupInterval, _ := time.ParseDuration(getEnv("UPLOAD_INTENT_CLEANUP_INTERVAL", "10m"))
+
+	faceBatchMaxSizeMB, _ := strconv.ParseInt(getEnv("FACE_BATCH_MAX_SIZE_MB", "256"), 10, 64)
+	faceBatchConcurrency, _ := strconv.Atoi(getEnv("FACE_BATCH_CONCURRENCY", "4"))
+
+	faceSearchHNSWThreshold, _ := strconv.Atoi(getEnv("FACE_SEARCH_HNSW_THRESHOLD", "5000"))
+	faceIndexSyncInterval, _ := time.ParseDuration(getEnv("FACE_INDEX_SYNC_INTERVAL", "30s"))
+
+	journeyVisitGap, _ := time.ParseDuration(getEnv("JOURNEY_VISIT_GAP", "30m"))
+
+	watchlistAlertCooldown, _ := time.ParseDuration(getEnv("WATCHLIST_ALERT_COOLDOWN", "15m"))
+
 	return &Config{
 		// การตั้งค่าทั่วไป
 		Port:       getEnv("PORT", "8080"),
@@ -97,6 +189,14 @@ func Load() (*Config, error) {
 		S3SecretKey:    getEnv("S3_SECRET_KEY", ""),
 		S3UsePathStyle: s3UsePathStyle,
 
+		// การตั้งค่าการเก็บรูปภาพในเครื่อง (เมื่อไม่ได้ใช้ S3)
+		LocalStoragePath: getEnv("LOCAL_STORAGE_PATH", "./storage/faces"),
+		PublicBaseURL:    getEnv("PUBLIC_BASE_URL", "http://localhost:"+getEnv("PORT", "8080")),
+
+		// การตั้งค่า URL ของรูปภาพที่มีอายุจำกัด (storage ในเครื่องต้องกำหนด URL_SIGNING_KEY เอง ไม่ใช้ JWT_SECRET)
+		URLSigningKey: getEnv("URL_SIGNING_KEY", ""),
+		SignedURLTTL:  signedURLTTL,
+
 		// การตั้งค่า Redis
 		RedisHost:     getEnv("REDIS_HOST", "localhost"),
 		RedisPort:     getEnv("REDIS_PORT", "6379"),
@@ -111,6 +211,53 @@ func Load() (*Config, error) {
 		// การตั้งค่า Rate Limiting
 		RateLimitMax:      rateLimitMax,
 		RateLimitDuration: rateLimitDuration,
+
+		// การตั้งค่าการแจ้งเตือน
+		AlertWebhookURL:     getEnv("ALERT_WEBHOOK_URL", ""),
+		AlertWebhookTimeout: alertWebhookTimeout,
+
+		// การตั้งค่าการตรวจจับความผิดปกติของปริมาณคน
+		AnomalyBaselineWeeks: anomalyBaselineWeeks,
+		AnomalyZThreshold:    anomalyZThreshold,
+		AnomalyCheckInterval: anomalyCheckInterval,
+
+		// การตั้งค่าการพยากรณ์ปริมาณคน
+		ForecastHistoryWeeks: forecastHistoryWeeks,
+		ForecastHorizonDays:  forecastHorizonDays,
+		ForecastRefreshHour:  forecastRefreshHour,
+		ForecastHolidayAware: forecastHolidayAware,
+
+		// การตั้งค่าการนับจำนวนคนในสาขา
+		OccupancyTimeout:       occupancyTimeout,
+		OccupancyCheckInterval: occupancyCheckInterval,
+
+		// การตั้งค่า live stream
+		StreamBufferSize: streamBufferSize,
+		StreamHeartbeat:  streamHeartbeat,
+
+		// การตั้งค่างานเบื้องหลัง
+		JobMaxConcurrent: jobMaxConcurrent,
+
+		// การตั้งค่ารูปย่อของรูปภาพใบหน้า
+		ThumbnailSizes: thumbnailSizes,
+
+		// การตั้งค่าการอัปโหลดรูปภาพใบหน้าตรงไปยัง storage
+		UploadIntentTTL:             uploadIntentTTL,
+		UploadIntentCleanupInterval: uploadIntentCleanupInterval,
+
+		// การตั้งค่าการอัปโหลดรูปภาพใบหน้าแบบกลุ่มจาก archive
+		FaceBatchMaxSize:     faceBatchMaxSizeMB * 1024 * 1024,
+		FaceBatchConcurrency: faceBatchConcurrency,
+
+		// การตั้งค่าการค้นหาใบหน้าด้วย embedding
+		FaceSearchHNSWThreshold: faceSearchHNSWThreshold,
+		FaceIndexSyncInterval:   faceIndexSyncInterval,
+
+		// การตั้งค่า timeline การเข้าชมของบุคคล
+		JourneyVisitGap: journeyVisitGap,
+
+		// การตั้งค่าการแจ้งเตือนรายการเฝ้าระวัง
+		WatchlistAlertCooldown: watchlistAlertCooldown,
 	}, nil
 }
 
@@ -121,4 +268,16 @@ func getEnv(key, defaultValue string) string {
 		return defaultValue
 	}
 	return value
-} 
\ No newline at end of file
+} 
+
+// getEnvInts รับรายการตัวเลขที่คั่นด้วยจุลภาคจากตัวแปรสภาพแวดล้อม โดยข้ามค่าที่ไม่ใช่ตัวเลขบวก
+func getEnvInts(key, defaultValue string) []int {
+	var values []int
+	for _, part := range strings.Split(getEnv(key, defaultValue), ",") {
+		value, err := strconv.Atoi(strings.TrimSpace(part))
+		if err == nil && value > 0 {
+			values = append(values, value)
+		}
+	}
+	return values
+}
@@ -0,0 +1,103 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	appconfig "github.com/bemindtech/bmt-manta-dashboard-service/config"
+)
+
+// Alert is a notification raised by the service for operators
+type Alert struct {
+	Type           string      `json:"type"`
+	Severity       string      `json:"severity"`
+	OrganizationID string      `json:"organization_id"`
+	Title          string      `json:"title"`
+	Message        string      `json:"message"`
+	Data           interface{} `json:"data,omitempty"`
+	CreatedAt      time.Time   `json:"created_at"`
+}
+
+// Notifier is an interface for different alert delivery implementations
+type Notifier interface {
+	// Notify delivers an alert
+	Notify(ctx context.Context, alert Alert) error
+}
+
+// LogNotifier implements Notifier by writing alerts to the application log
+type LogNotifier struct{}
+
+// Notify implements Notifier interface for the application log
+func (n *LogNotifier) Notify(ctx context.Context, alert Alert) error {
+	log.Printf("[ALERT][%s][%s] org=%s %s: %s", alert.Severity, alert.Type, alert.OrganizationID, alert.Title, alert.Message)
+	return nil
+}
+
+// WebhookNotifier implements Notifier by posting alerts as JSON to an HTTP endpoint
+type WebhookNotifier struct {
+	URL    string
+	Client *http.Client
+}
+
+// NewWebhookNotifier creates a new webhook notifier
+func NewWebhookNotifier(url string, timeout time.Duration) *WebhookNotifier {
+	return &WebhookNotifier{
+		URL:    url,
+		Client: &http.Client{Timeout: timeout},
+	}
+}
+
+// Notify implements Notifier interface for webhooks
+func (n *WebhookNotifier) Notify(ctx context.Context, alert Alert) error {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return fmt.Errorf("unable to encode alert: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("unable to create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("unable to deliver webhook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// MultiNotifier delivers an alert to every notifier it contains
+type MultiNotifier []Notifier
+
+// Notify implements Notifier interface by fanning out to all notifiers
+func (m MultiNotifier) Notify(ctx context.Context, alert Alert) error {
+	var firstErr error
+	for _, n := range m {
+		if err := n.Notify(ctx, alert); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// NewNotifier creates a notifier based on configuration
+func NewNotifier(cfg *appconfig.Config) Notifier {
+	// Alerts are always written to the log so they are never silently dropped
+	notifiers := MultiNotifier{&LogNotifier{}}
+	if cfg.AlertWebhookURL != "" {
+		notifiers = append(notifiers, NewWebhookNotifier(cfg.AlertWebhookURL, cfg.AlertWebhookTimeout))
+	}
+	return notifiers
+}
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/models"
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+// AnomalyHandler เป็นโครงสร้างสำหรับจัดการ API endpoints เกี่ยวกับความผิดปกติของปริมาณคน
+type AnomalyHandler struct {
+	AnomalyService *services.AnomalyService
+}
+
+// NewAnomalyHandler สร้าง AnomalyHandler ใหม่
+func NewAnomalyHandler(anomalyService *services.AnomalyService) *AnomalyHandler {
+	return &AnomalyHandler{
+		AnomalyService: anomalyService,
+	}
+}
+
+// GetAnomalies เป็น handler สำหรับดึงรายการความผิดปกติของปริมาณคน
+// @Summary List traffic anomalies
+// @Description Retrieve hours whose traffic deviated from the seasonal baseline (same weekday and hour over the trailing weeks)
+// @Tags anomalies
+// @Accept json
+// @Produce json
+// @Param from query string false "Start time (format YYYY-MM-DDTHH:MM:SSZ)"
+// @Param to query string false "End time (format YYYY-MM-DDTHH:MM:SSZ)"
+// @Param camera_id query string false "Camera ID to filter anomalies by"
+// @Param severity query string false "Severity to filter anomalies by (medium, high, critical)"
+// @Param page query int false "Page number (starting from 1)" default(1)
+// @Param page_size query int false "Items per page (max 100)" default(10)
+// @Security ApiKeyAuth
+// @Success 200 {object} AnomaliesResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse "Unauthorized (invalid or missing API key)"
+// @Failure 500 {object} ErrorResponse
+// @Router /api/anomalies [get]
+func (h *AnomalyHandler) GetAnomalies(c *fiber.Ctx) error {
+	// ดึง organization ID จาก context
+	organizationID := c.Locals("organization_id").(string)
+	if organizationID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "ไม่พบข้อมูลองค์กร",
+		})
+	}
+
+	filter := models.AnomalyFilter{
+		CameraID:       c.Query("camera_id"),
+		Severity:       c.Query("severity"),
+		OrganizationID: organizationID,
+	}
+
+	// แปลงค่า from และ to ถ้ามี
+	if fromStr := c.Query("from"); fromStr != "" {
+		from, err := time.Parse(time.RFC3339, fromStr)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "รูปแบบของพารามิเตอร์ from ไม่ถูกต้อง โปรดใช้รูปแบบ YYYY-MM-DDTHH:MM:SSZ",
+			})
+		}
+		filter.From = from
+	}
+	if toStr := c.Query("to"); toStr != "" {
+		to, err := time.Parse(time.RFC3339, toStr)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "รูปแบบของพารามิเตอร์ to ไม่ถูกต้อง โปรดใช้รูปแบบ YYYY-MM-DDTHH:MM:SSZ",
+			})
+		}
+		filter.To = to
+	}
+
+	// ดึงค่า pagination จาก query parameters
+	page, err := strconv.Atoi(c.Query("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	pageSize, err := strconv.Atoi(c.Query("page_size", "10"))
+	if err != nil || pageSize < 1 {
+		pageSize = 10
+	}
+	if pageSize > 100 {
+		pageSize = 100
+	}
+	filter.Page = page
+	filter.PageSize = pageSize
+
+	// ดึงรายการความผิดปกติ
+	anomalies, pagination, err := h.AnomalyService.ListAnomalies(c.Context(), filter)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	// สร้าง response
+	response := AnomaliesResponse{
+		Data:       anomalies,
+		Pagination: pagination,
+	}
+
+	return c.JSON(response)
+}
+
+// AnomaliesResponse เป็นโครงสร้างสำหรับส่งรายการความผิดปกติพร้อมกับข้อมูล pagination
+type AnomaliesResponse struct {
+	Data       []models.TrafficAnomaly `json:"data"`
+	Pagination *models.Pagination      `json:"pagination"`
+}
@@ -1,7 +1,6 @@
 package handlers
 
 import (
-	"strconv"
 
 	"github.com/bemindtech/bmt-manta-dashboard-service/internal/models"
 	"github.com/bemindtech/bmt-manta-dashboard-service/internal/services"
@@ -22,12 +21,20 @@ func NewCameraHandler(cameraService *services.CameraService) *CameraHandler {
 
 // GetCameras ดึงรายการกล้องทั้งหมดขององค์กร
 // @Summary Get all cameras
-// @Description Retrieve a list of all cameras in the organization with pagination
+// @Description Retrieve a list of all cameras in the organization with cursor pagination. The page parameter of the former offset pagination is rejected with 400, and pagination is {limit, next_cursor, has_more, total} instead of {total, page, page_size, total_page}.
 // @Tags cameras
 // @Accept json
 // @Produce json
-// @Param page query int false "Page number (starting from 1)" default(1)
-// @Param page_size query int false "Items per page (max 100)" default(10)
+// @Param status query []string false "Camera statuses to filter by" collectionFormat(multi)
+// @Param zone_id query []string false "Camera zones to filter by" collectionFormat(multi)
+// @Param site_id query []string false "Site IDs to filter by" collectionFormat(multi)
+// @Param role query []string false "Camera roles to filter by" collectionFormat(multi)
+// @Param cursor query string false "Cursor from pagination.next_cursor of the previous page"
+// @Param limit query int false "Number of items per page (max 100)" default(10)
+// @Param sort query string false "Sort field (name, created_at)" default(name)
+// @Param order query string false "Sort order (asc, desc)" default(asc)
+// @Param fields query string false "Comma-separated fields to return"
+// @Param include_total query bool false "Also count the total number of items (slower)"
 // @Security ApiKeyAuth
 // @Success 200 {object} ListCamerasResponse
 // @Failure 400 {object} ErrorResponse
@@ -43,28 +50,34 @@ func (h *CameraHandler) GetCameras(c *fiber.Ctx) error {
 		})
 	}
 
-	// ดึงค่า pagination จาก query parameters
-	page, err := strconv.Atoi(c.Query("page", "1"))
-	if err != nil || page < 1 {
-		page = 1
+	// ดึงค่าการแบ่งหน้า การเรียงลำดับ และฟิลด์ที่เลือก
+	opts, err := parseListOptions(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
 	}
 
-	pageSize, err := strconv.Atoi(c.Query("page_size", "10"))
-	if err != nil || pageSize < 1 {
-		pageSize = 10
-	}
-	if pageSize > 100 {
-		pageSize = 100
+	// ดึงเงื่อนไขการกรอง ซึ่งแต่ละเงื่อนไขรับได้หลายค่า
+	filter := models.CameraFilter{
+		Statuses: queryValues(c, "status"),
+		Zones:    queryValues(c, "zone_id"),
+		SiteIDs:  queryValues(c, "site_id"),
+		Roles:    queryValues(c, "role"),
 	}
 
 	// ดึงรายการกล้อง
-	cameras, pagination, err := h.CameraService.ListCameras(c.Context(), organizationID, page, pageSize)
+	cameras, pagination, err := h.CameraService.ListCameras(c.Context(), organizationID, filter, opts)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		return c.Status(listErrorStatus(err)).JSON(fiber.Map{
 			"error": err.Error(),
 		})
 	}
 
+	if len(opts.Fields) > 0 {
+		return projectedResponse(c, cameras, pagination, opts.Fields)
+	}
+
 	// สร้าง response
 	response := ListCamerasResponse{
 		Data:       cameras,
@@ -159,6 +172,11 @@ func (h *CameraHandler) CreateCamera(c *fiber.Ctx) error {
 
 	// สร้างกล้องใหม่
 	if err := h.CameraService.CreateCamera(c.Context(), &camera); err != nil {
+		if err.Error() == "ไม่พบสาขาที่ระบุ" || err.Error() == "บทบาทของกล้องไม่ถูกต้อง" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": err.Error(),
 		})
@@ -224,12 +242,22 @@ func (h *CameraHandler) UpdateCamera(c *fiber.Ctx) error {
 	// อัปเดตข้อมูลที่เปลี่ยนแปลง
 	camera.Name = updatedCamera.Name
 	camera.Location = updatedCamera.Location
+	camera.SiteID = updatedCamera.SiteID
+	camera.Zone = updatedCamera.Zone
+	if updatedCamera.Role != "" {
+		camera.Role = updatedCamera.Role
+	}
 	if updatedCamera.Status != "" {
 		camera.Status = updatedCamera.Status
 	}
 
 	// บันทึกการเปลี่ยนแปลง
 	if err := h.CameraService.UpdateCamera(c.Context(), camera); err != nil {
+		if err.Error() == "ไม่พบสาขาที่ระบุ" || err.Error() == "บทบาทของกล้องไม่ถูกต้อง" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": err.Error(),
 		})
@@ -295,6 +323,6 @@ func (h *CameraHandler) DeleteCamera(c *fiber.Ctx) error {
 
 // ListCamerasResponse โครงสร้างสำหรับส่งข้อมูลรายการกล้องพร้อมข้อมูลการแบ่งหน้า
 type ListCamerasResponse struct {
-	Data       []models.Camera     `json:"data"`
-	Pagination *models.Pagination `json:"pagination"`
+	Data       []models.Camera          `json:"data"`
+	Pagination *models.CursorPagination `json:"pagination"`
 }
\ No newline at end of file
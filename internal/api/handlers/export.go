@@ -0,0 +1,328 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/models"
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+// ExportHandler เป็นโครงสร้างสำหรับจัดการ API endpoints เกี่ยวกับการส่งออกข้อมูล logs
+type ExportHandler struct {
+	ExportService *services.ExportService
+}
+
+// NewExportHandler สร้าง ExportHandler ใหม่
+func NewExportHandler(exportService *services.ExportService) *ExportHandler {
+	return &ExportHandler{
+		ExportService: exportService,
+	}
+}
+
+// ExportJobRequest เป็นโครงสร้างสำหรับสร้างงานส่งออกข้อมูล logs
+type ExportJobRequest struct {
+	Format        string   `json:"format" example:"parquet"`
+	From          string   `json:"from,omitempty" example:"2025-01-01T00:00:00Z"`
+	To            string   `json:"to,omitempty" example:"2025-02-01T00:00:00Z"`
+	CameraIDs     []string `json:"camera_ids,omitempty"`
+	ZoneIDs       []string `json:"zone_ids,omitempty"`
+	IsNewPerson   *bool    `json:"is_new_person,omitempty"`
+	PersonID      string   `json:"person_id,omitempty"`
+	IncludeCamera bool     `json:"include_camera"`
+}
+
+// ListExportJobsResponse เป็นโครงสร้างสำหรับส่งรายการงานส่งออกพร้อมกับข้อมูล pagination
+type ListExportJobsResponse struct {
+	Data       []models.Job       `json:"data"`
+	Pagination *models.Pagination `json:"pagination"`
+}
+
+// parseExportParams แปลง query parameters เป็นพารามิเตอร์การส่งออก
+func parseExportParams(c *fiber.Ctx, organizationID string) (models.LogExportParams, error) {
+	filter, err := parseLogsFilter(c)
+	if err != nil {
+		return models.LogExportParams{}, err
+	}
+	filter.OrganizationID = organizationID
+
+	params := models.LogExportParams{
+		Filter:        filter,
+		Format:        c.Query("format", services.ExportFormatCSV),
+		IncludeCamera: c.Query("include") == "camera",
+	}
+	if _, _, err := services.ExportContentType(params.Format); err != nil {
+		return params, err
+	}
+
+	return params, nil
+}
+
+// exportErrorStatus แปลงข้อผิดพลาดของการส่งออกเป็น HTTP status
+func exportErrorStatus(err error) int {
+	switch err.Error() {
+	case "ไม่พบงาน":
+		return fiber.StatusNotFound
+	case "งานส่งออกยังไม่เสร็จ":
+		return fiber.StatusConflict
+	}
+	return fiber.StatusInternalServerError
+}
+
+// ExportLogs เป็น handler สำหรับส่งออกข้อมูล logs แบบ streaming
+// @Summary Stream log export
+// @Description Stream every person log matching the filters as CSV, NDJSON or Parquet using chunked transfer. Use include=camera to add the camera name and zone. Use the export jobs endpoints for very large ranges.
+// @Tags logs
+// @Produce text/csv
+// @Produce application/x-ndjson
+// @Produce application/vnd.apache.parquet
+// @Param format query string false "Export format (csv, ndjson, parquet)" default(csv)
+// @Param include query string false "Set to camera to add camera name and zone columns"
+// @Param from query string false "Start time (format YYYY-MM-DDTHH:MM:SS)"
+// @Param to query string false "End time (format YYYY-MM-DDTHH:MM:SS)"
+// @Param camera_id query []string false "Camera IDs to filter logs by" collectionFormat(multi)
+// @Param zone_id query []string false "Camera zones to filter logs by" collectionFormat(multi)
+// @Param is_new_person query bool false "Only new (true) or returning (false) detections"
+// @Param person_id query string false "Person ID to filter logs by"
+// @Security ApiKeyAuth
+// @Success 200 {file} file
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse "Unauthorized (invalid or missing API key)"
+// @Router /api/logs/export [get]
+func (h *ExportHandler) ExportLogs(c *fiber.Ctx) error {
+	// ดึง organization ID จาก context
+	organizationID := c.Locals("organization_id").(string)
+	if organizationID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "ไม่พบข้อมูลองค์กร",
+		})
+	}
+
+	params, err := parseExportParams(c, organizationID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	contentType, extension, _ := services.ExportContentType(params.Format)
+	fileName := fmt.Sprintf("logs-%s.%s", time.Now().Format("20060102-150405"), extension)
+
+	c.Set("Content-Type", contentType)
+	c.Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fileName))
+	c.Set("X-Accel-Buffering", "no")
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		// header ถูกส่งไปแล้ว จึงทำได้เพียงบันทึกข้อผิดพลาดและตัดการเชื่อมต่อ
+		if _, err := h.ExportService.ExportLogs(context.Background(), params, w, nil); err != nil {
+			log.Printf("ไม่สามารถส่งออกข้อมูล logs ขององค์กร %s: %v", organizationID, err)
+			return
+		}
+		if err := w.Flush(); err != nil {
+			log.Printf("ไม่สามารถส่งข้อมูล logs ที่ส่งออก: %v", err)
+		}
+	})
+
+	return nil
+}
+
+// CreateExportJob เป็น handler สำหรับสร้างงานส่งออกข้อมูล logs แบบเบื้องหลัง
+// @Summary Create log export job
+// @Description Start an asynchronous export of the matching person logs. The file is written to the configured storage backend and can be downloaded when the job completes.
+// @Tags logs
+// @Accept json
+// @Produce json
+// @Param job body ExportJobRequest true "Export parameters"
+// @Security ApiKeyAuth
+// @Success 202 {object} models.Job
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse "Unauthorized (invalid or missing API key)"
+// @Failure 500 {object} ErrorResponse
+// @Router /api/logs/export/jobs [post]
+func (h *ExportHandler) CreateExportJob(c *fiber.Ctx) error {
+	// ดึง organization ID จาก context
+	organizationID := c.Locals("organization_id").(string)
+	if organizationID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "ไม่พบข้อมูลองค์กร",
+		})
+	}
+
+	var req ExportJobRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "รูปแบบข้อมูลไม่ถูกต้อง",
+		})
+	}
+	if req.Format == "" {
+		req.Format = services.ExportFormatCSV
+	}
+	if _, _, err := services.ExportContentType(req.Format); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	params := models.LogExportParams{
+		Filter: models.LogFilter{
+			OrganizationID: organizationID,
+			CameraIDs:      req.CameraIDs,
+			Zones:          req.ZoneIDs,
+			IsNewPerson:    req.IsNewPerson,
+			PersonID:       req.PersonID,
+		},
+		Format:        req.Format,
+		IncludeCamera: req.IncludeCamera,
+	}
+	if req.From != "" {
+		from, err := time.Parse(time.RFC3339, req.From)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "รูปแบบของ from ไม่ถูกต้อง โปรดใช้รูปแบบ YYYY-MM-DDTHH:MM:SS",
+			})
+		}
+		params.Filter.From = from
+	}
+	if req.To != "" {
+		to, err := time.Parse(time.RFC3339, req.To)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "รูปแบบของ to ไม่ถูกต้อง โปรดใช้รูปแบบ YYYY-MM-DDTHH:MM:SS",
+			})
+		}
+		params.Filter.To = to
+	}
+
+	job, err := h.ExportService.StartExportJob(c.Context(), params)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(job)
+}
+
+// ListExportJobs เป็น handler สำหรับดึงรายการงานส่งออกข้อมูล logs
+// @Summary List log export jobs
+// @Description List the log export jobs of the caller's organization, newest first
+// @Tags logs
+// @Produce json
+// @Param page query int false "Page number to retrieve (starting from 1)" default(1)
+// @Param page_size query int false "Number of items per page (max 100)" default(10)
+// @Security ApiKeyAuth
+// @Success 200 {object} ListExportJobsResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse "Unauthorized (invalid or missing API key)"
+// @Failure 500 {object} ErrorResponse
+// @Router /api/logs/export/jobs [get]
+func (h *ExportHandler) ListExportJobs(c *fiber.Ctx) error {
+	// ดึง organization ID จาก context
+	organizationID := c.Locals("organization_id").(string)
+	if organizationID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "ไม่พบข้อมูลองค์กร",
+		})
+	}
+
+	page, err := strconv.Atoi(c.Query("page", "1"))
+	if err != nil || page <= 0 {
+		page = 1
+	}
+	pageSize, err := strconv.Atoi(c.Query("page_size", "10"))
+	if err != nil || pageSize <= 0 {
+		pageSize = 10
+	}
+	if pageSize > 100 {
+		pageSize = 100
+	}
+
+	jobs, pagination, err := h.ExportService.Jobs.ListJobs(c.Context(), organizationID, models.JobTypeLogExport, page, pageSize)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(ListExportJobsResponse{
+		Data:       jobs,
+		Pagination: pagination,
+	})
+}
+
+// GetExportJob เป็น handler สำหรับดึงสถานะของงานส่งออกข้อมูล logs
+// @Summary Get log export job
+// @Description Get the status, progress (rows written) and result of a log export job
+// @Tags logs
+// @Produce json
+// @Param id path string true "Job ID"
+// @Security ApiKeyAuth
+// @Success 200 {object} models.Job
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse "Unauthorized (invalid or missing API key)"
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/logs/export/jobs/{id} [get]
+func (h *ExportHandler) GetExportJob(c *fiber.Ctx) error {
+	// ดึง organization ID จาก context
+	organizationID := c.Locals("organization_id").(string)
+	if organizationID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "ไม่พบข้อมูลองค์กร",
+		})
+	}
+
+	job, err := h.ExportService.Jobs.GetJob(c.Context(), c.Params("id"), organizationID)
+	if err == nil && job.Type != models.JobTypeLogExport {
+		err = fmt.Errorf("ไม่พบงาน")
+	}
+	if err != nil {
+		return c.Status(exportErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(job)
+}
+
+// DownloadExport เป็น handler สำหรับดาวน์โหลดไฟล์ของงานส่งออกที่เสร็จแล้ว
+// @Summary Download log export file
+// @Description Download the file produced by a completed log export job
+// @Tags logs
+// @Produce octet-stream
+// @Param id path string true "Job ID"
+// @Security ApiKeyAuth
+// @Success 200 {file} file
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse "Unauthorized (invalid or missing API key)"
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse "Job has not completed"
+// @Failure 500 {object} ErrorResponse
+// @Router /api/logs/export/jobs/{id}/download [get]
+func (h *ExportHandler) DownloadExport(c *fiber.Ctx) error {
+	// ดึง organization ID จาก context
+	organizationID := c.Locals("organization_id").(string)
+	if organizationID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "ไม่พบข้อมูลองค์กร",
+		})
+	}
+
+	reader, result, err := h.ExportService.OpenExportFile(c.Context(), c.Params("id"), organizationID)
+	if err != nil {
+		return c.Status(exportErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	c.Set("Content-Type", result.ContentType)
+	c.Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", result.FileName))
+
+	// fasthttp ปิด reader ให้เมื่อส่งข้อมูลครบ
+	return c.SendStream(reader, int(result.Size))
+}
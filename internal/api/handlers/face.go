@@ -1,10 +1,18 @@
 package handlers
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/bemindtech/bmt-manta-dashboard-service/internal/models"
 	"github.com/bemindtech/bmt-manta-dashboard-service/internal/services"
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/storage"
 	"github.com/gofiber/fiber/v2"
 )
 
@@ -20,9 +28,96 @@ func NewFaceHandler(faceService *services.FaceService) *FaceHandler {
 	}
 }
 
+// faceErrorStatus แปลงข้อผิดพลาดของรูปภาพใบหน้าและงานสร้างรูปย่อเป็น HTTP status
+func faceErrorStatus(err error) int {
+	var imageErr *services.FaceImageError
+	if errors.As(err, &imageErr) {
+		if imageErr.Code == services.FaceImageFileTooLarge {
+			return fiber.StatusRequestEntityTooLarge
+		}
+		return fiber.StatusBadRequest
+	}
+	var batchErr *services.FaceBatchError
+	if errors.As(err, &batchErr) {
+		return fiber.StatusBadRequest
+	}
+	switch {
+	case errors.Is(err, services.ErrUploadIntentNotFound):
+		return fiber.StatusNotFound
+	case errors.Is(err, services.ErrUploadIntentClosed), errors.Is(err, services.ErrUploadNotReceived):
+		return fiber.StatusConflict
+	case errors.Is(err, storage.ErrInvalidSignature):
+		return fiber.StatusForbidden
+	case errors.Is(err, services.ErrFaceIndexLoading):
+		return fiber.StatusServiceUnavailable
+	}
+	switch err.Error() {
+	case "ไม่พบงาน", "ไม่พบรูปภาพ":
+		return fiber.StatusNotFound
+	}
+	return fiber.StatusInternalServerError
+}
+
+// faceErrorResponse สร้าง response ของข้อผิดพลาด โดยใส่รหัสข้อผิดพลาดใน code ถ้ารูปภาพ archive หรือ manifest ไม่ผ่านการตรวจสอบ
+func faceErrorResponse(err error) fiber.Map {
+	response := fiber.Map{
+		"error": err.Error(),
+	}
+	var imageErr *services.FaceImageError
+	var batchErr *services.FaceBatchError
+	switch {
+	case errors.As(err, &imageErr):
+		response["code"] = imageErr.Code
+	case errors.As(err, &batchErr):
+		response["code"] = batchErr.Code
+	}
+	return response
+}
+
+// faceImageStatus คืน 200 เมื่อรูปที่อัปโหลดซ้ำกับรูปเดิมและไม่ถูกเก็บ ไม่เช่นนั้นคืน 201
+func faceImageStatus(faceImage *models.FaceImage) int {
+	if faceImage.Duplicate {
+		return fiber.StatusOK
+	}
+	return fiber.StatusCreated
+}
+
+// parseFaceQuality ดึงข้อมูลคุณภาพของรูป (face_confidence และ yaw) จาก form ซึ่งไม่บังคับ
+func parseFaceQuality(c *fiber.Ctx) (models.FaceQuality, error) {
+	var quality models.FaceQuality
+	for _, number := range []struct {
+		field string
+		value **float64
+	}{{"face_confidence", &quality.FaceConfidence}, {"yaw", &quality.Yaw}} {
+		text := c.FormValue(number.field)
+		if text == "" {
+			continue
+		}
+		value, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return quality, fmt.Errorf("%s ต้องเป็นตัวเลข", number.field)
+		}
+		*number.value = &value
+	}
+	return quality, nil
+}
+
+// parseFaceEmbedding อ่าน embedding ของใบหน้า (ไม่บังคับ) จาก form ซึ่งเป็น JSON array ของตัวเลข
+func parseFaceEmbedding(c *fiber.Ctx) ([]float32, error) {
+	text := c.FormValue("embedding")
+	if text == "" {
+		return nil, nil
+	}
+	var embedding []float32
+	if err := json.Unmarshal([]byte(text), &embedding); err != nil {
+		return nil, fmt.Errorf("embedding ต้องเป็น JSON array ของตัวเลข")
+	}
+	return embedding, nil
+}
+
 // UploadFaceImage เป็น handler สำหรับอัปโหลดรูปภาพใบหน้า
 // @Summary Upload a face image
-// @Description Upload an image of a person's face for training AI models
+// @Description Upload an image of a person's face for training AI models. The image type is detected from its content and must be JPEG, PNG or WebP, within the organization's file size and dimension limits (GET /api/faces/settings). Rejected images return a code: unsupported_format, decode_failed, file_too_large, dimensions_too_small, dimensions_too_large or invalid_quality. The image is re-encoded without metadata (EXIF, GPS), rotated by its EXIF orientation; PNG stays PNG and JPEG and WebP are stored as JPEG. Square thumbnails of the configured sizes (THUMBNAIL_SIZES) are generated and returned in thumbnails, keyed by size in pixels, with the smallest in thumbnail_url. A near-duplicate of a recent image of the same person is handled by the organization's dedup_mode (GET /api/faces/settings): skip returns the existing image with duplicate true and status 200, link returns a new image with duplicate_of_id that shares the existing image's files. The image gets a quality_score (0-1) from its sharpness, resolution and the optional face_confidence and yaw; the best image of a person becomes its primary_face_image unless one was pinned. The optional embedding is stored for POST /api/faces/search; all embeddings of an organization must have the same number of dimensions (code invalid_embedding).
 // @Tags faces
 // @Accept multipart/form-data
 // @Produce json
@@ -30,11 +125,15 @@ func NewFaceHandler(faceService *services.FaceService) *FaceHandler {
 // @Param person_hash formData string true "Person's unique hash"
 // @Param camera_id formData string true "ID of the camera that captured the image"
 // @Param organization_id formData string true "Organization ID"
+// @Param face_confidence formData number false "Face detector confidence, 0-1"
+// @Param yaw formData number false "Head rotation in degrees, 0 is frontal, -90 to 90"
+// @Param embedding formData string false "Face embedding as a JSON array of numbers, e.g. [0.12,-0.03,...]"
 // @Security ApiKeyAuth
+// @Success 200 {object} models.FaceImage "Near-duplicate of an existing image, which is returned"
 // @Success 201 {object} models.FaceImage
-// @Failure 400 {object} ErrorResponse
+// @Failure 400 {object} FaceImageErrorResponse
 // @Failure 401 {object} ErrorResponse "Unauthorized (invalid or missing API key)"
-// @Failure 413 {object} ErrorResponse "File too large"
+// @Failure 413 {object} FaceImageErrorResponse "File too large"
 // @Failure 500 {object} ErrorResponse
 // @Router /api/faces [post]
 func (h *FaceHandler) UploadFaceImage(c *fiber.Ctx) error {
@@ -46,13 +145,6 @@ func (h *FaceHandler) UploadFaceImage(c *fiber.Ctx) error {
 		})
 	}
 
-	// ขนาดไฟล์ไม่เกิน 5MB
-	if file.Size > 5*1024*1024 {
-		return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{
-			"error": "ขนาดไฟล์ต้องไม่เกิน 5MB",
-		})
-	}
-
 	// ดึงข้อมูลจาก form
 	personHash := c.FormValue("person_hash")
 	if personHash == "" {
@@ -79,26 +171,317 @@ func (h *FaceHandler) UploadFaceImage(c *fiber.Ctx) error {
 		}
 	}
 
+	quality, err := parseFaceQuality(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+			"code":  services.FaceImageInvalidQuality,
+		})
+	}
+
+	embedding, err := parseFaceEmbedding(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+			"code":  services.FaceImageInvalidEmbedding,
+		})
+	}
+
 	// อัปโหลดรูปภาพ
-	faceImage, err := h.FaceService.UploadFaceImage(c.Context(), file, personHash, cameraID, organizationID)
+	faceImage, err := h.FaceService.UploadFaceImage(c.Context(), file, personHash, cameraID, organizationID, quality, embedding)
+	if err != nil {
+		return c.Status(faceErrorStatus(err)).JSON(faceErrorResponse(err))
+	}
+
+	return c.Status(faceImageStatus(faceImage)).JSON(faceImage)
+}
+
+// FaceSearchRequest เป็นโครงสร้างคำขอค้นหาบุคคลจาก embedding ของใบหน้า
+type FaceSearchRequest struct {
+	Embedding     []float32 `json:"embedding"`
+	K             int       `json:"k,omitempty" example:"10"`               // number of persons, 1-100, default 10
+	MinSimilarity float64   `json:"min_similarity,omitempty" example:"0.5"` // minimum cosine similarity, -1 to 1
+}
+
+// FaceSearchResponse โครงสร้างสำหรับส่งผลการค้นหาบุคคลจาก embedding ของใบหน้า
+type FaceSearchResponse struct {
+	Data []models.FaceSearchMatch `json:"data"`
+}
+
+// SearchFaces เป็น handler สำหรับค้นหาบุคคลที่ใบหน้าคล้าย embedding ที่ระบุ
+// @Summary Search persons by face embedding
+// @Description Returns up to k person_hashes of the organization whose face images are most similar to the embedding by cosine similarity, most similar first, each with its most similar face image. The embedding must have the same number of dimensions as the embeddings uploaded with the organization's face images (code invalid_embedding). Small organizations are searched exactly; organizations with at least FACE_SEARCH_HNSW_THRESHOLD embeddings use an approximate HNSW index, which may miss a few matches. Returns 503 while the index is being loaded after startup.
+// @Tags faces
+// @Accept json
+// @Produce json
+// @Param search body FaceSearchRequest true "Face search"
+// @Security ApiKeyAuth
+// @Success 200 {object} FaceSearchResponse
+// @Failure 400 {object} FaceImageErrorResponse
+// @Failure 401 {object} ErrorResponse "Unauthorized (invalid or missing API key)"
+// @Failure 500 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse "Face index is loading"
+// @Router /api/faces/search [post]
+func (h *FaceHandler) SearchFaces(c *fiber.Ctx) error {
+	// ดึง organization ID จาก context
+	organizationID := c.Locals("organization_id").(string)
+	if organizationID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "ไม่พบข้อมูลองค์กร",
+		})
+	}
+
+	// แปลงข้อมูลจาก request
+	var request FaceSearchRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "รูปแบบข้อมูลไม่ถูกต้อง",
+		})
+	}
+	if len(request.Embedding) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "ต้องระบุ embedding",
+			"code":  services.FaceImageInvalidEmbedding,
+		})
+	}
+
+	matches, err := h.FaceService.SearchFaces(c.Context(), organizationID, request.Embedding, request.K, request.MinSimilarity)
+	if err != nil {
+		status := faceErrorStatus(err)
+		if status == fiber.StatusInternalServerError && strings.HasPrefix(err.Error(), "k ") {
+			status = fiber.StatusBadRequest
+		}
+		return c.Status(status).JSON(faceErrorResponse(err))
+	}
+
+	return c.JSON(FaceSearchResponse{Data: matches})
+}
+
+// CreateUploadIntentRequest เป็นโครงสร้างคำขออัปโหลดรูปภาพใบหน้าตรงไปยัง storage
+type CreateUploadIntentRequest struct {
+	PersonHash  string `json:"person_hash"`
+	CameraID    string `json:"camera_id"`
+	ContentType string `json:"content_type" example:"image/jpeg"`
+	Size        int64  `json:"size" example:"183402"` // bytes
+
+	// Optional quality metadata, stored with the face image
+	FaceConfidence *float64 `json:"face_confidence,omitempty" example:"0.98"` // face detector confidence, 0-1
+	Yaw            *float64 `json:"yaw,omitempty" example:"-12.5"`            // head rotation in degrees, 0 is frontal
+
+	// Optional face embedding, stored for face search
+	Embedding []float32 `json:"embedding,omitempty"`
+}
+
+// CreateUploadIntent เป็น handler สำหรับขอ URL อัปโหลดรูปภาพใบหน้าตรงไปยัง storage
+// @Summary Create a direct face image upload
+// @Description Step 1 of a direct upload: returns upload_url, a presigned S3 PUT URL or a signed local upload URL valid until expires_at. PUT exactly size bytes to it with the returned upload_headers (Content-Type), then call the complete endpoint. content_type must be image/jpeg, image/png or image/webp and size must be within the organization's max_file_size. Intents that are not completed are removed after they expire.
+// @Tags faces
+// @Accept json
+// @Produce json
+// @Param intent body CreateUploadIntentRequest true "Upload intent"
+// @Security ApiKeyAuth
+// @Success 201 {object} models.FaceUploadIntent
+// @Failure 400 {object} FaceImageErrorResponse
+// @Failure 401 {object} ErrorResponse "Unauthorized (invalid or missing API key)"
+// @Failure 413 {object} FaceImageErrorResponse "File too large"
+// @Failure 500 {object} ErrorResponse
+// @Router /api/faces/upload-intents [post]
+func (h *FaceHandler) CreateUploadIntent(c *fiber.Ctx) error {
+	// ดึง organization ID จาก context
+	organizationID := c.Locals("organization_id").(string)
+	if organizationID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "ไม่พบข้อมูลองค์กร",
+		})
+	}
+
+	// แปลงข้อมูลจาก request
+	var request CreateUploadIntentRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "รูปแบบข้อมูลไม่ถูกต้อง",
+		})
+	}
+	if request.PersonHash == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "ต้องระบุรหัสบุคคล (person_hash)",
+		})
+	}
+	if request.CameraID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "ต้องระบุรหัสกล้อง (camera_id)",
+		})
+	}
+	if request.Size < 1 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "ต้องระบุขนาดไฟล์ (size)",
+		})
+	}
+
+	intent, err := h.FaceService.CreateUploadIntent(c.Context(), organizationID, request.PersonHash, request.CameraID, request.ContentType, request.Size, models.FaceQuality{
+		FaceConfidence: request.FaceConfidence,
+		Yaw:            request.Yaw,
+	}, request.Embedding)
+	if err != nil {
+		return c.Status(faceErrorStatus(err)).JSON(faceErrorResponse(err))
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(intent)
+}
+
+// CompleteUploadIntent เป็น handler สำหรับยืนยันการอัปโหลดรูปภาพใบหน้าตรงไปยัง storage
+// @Summary Complete a direct face image upload
+// @Description Step 2 of a direct upload: verifies that the file was uploaded, validates and re-encodes it like POST /api/faces and creates the face image. Completing an already completed intent returns the same image. A rejected file is deleted and its intent cannot be completed again.
+// @Tags faces
+// @Produce json
+// @Param id path string true "Upload intent ID"
+// @Security ApiKeyAuth
+// @Success 200 {object} models.FaceImage "Near-duplicate of an existing image, which is returned"
+// @Success 201 {object} models.FaceImage
+// @Failure 400 {object} FaceImageErrorResponse
+// @Failure 401 {object} ErrorResponse "Unauthorized (invalid or missing API key)"
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse "File not uploaded yet, or intent expired or rejected"
+// @Failure 413 {object} FaceImageErrorResponse "File too large"
+// @Failure 500 {object} ErrorResponse
+// @Router /api/faces/upload-intents/{id}/complete [post]
+func (h *FaceHandler) CompleteUploadIntent(c *fiber.Ctx) error {
+	// ดึง organization ID จาก context
+	organizationID := c.Locals("organization_id").(string)
+	if organizationID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "ไม่พบข้อมูลองค์กร",
+		})
+	}
+
+	faceImage, err := h.FaceService.CompleteUploadIntent(c.Context(), c.Params("id"), organizationID)
+	if err != nil {
+		return c.Status(faceErrorStatus(err)).JSON(faceErrorResponse(err))
+	}
+
+	return c.Status(faceImageStatus(faceImage)).JSON(faceImage)
+}
+
+// ReceiveUpload เป็น handler สำหรับรับไฟล์ที่อัปโหลดไปยัง URL สำหรับอัปโหลดของ storage ในเครื่อง
+// @Summary Upload a file to a signed local upload URL
+// @Description Receives the PUT to the upload_url of an upload intent when the API stores images locally. The URL carries its own expires and signature query parameters instead of an API key; the signature covers the path, Content-Type and body size requested in the intent.
+// @Tags faces
+// @Accept octet-stream
+// @Param organization_id path string true "Organization ID"
+// @Param file path string true "Upload path from upload_url"
+// @Param expires query int true "Expiry time of the URL (Unix seconds)"
+// @Param signature query string true "Signature of the URL"
+// @Success 200
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse "Invalid or expired signature, or another Content-Type or size"
+// @Failure 500 {object} ErrorResponse
+// @Router /api/files/{organization_id}/{file} [put]
+func (h *FaceHandler) ReceiveUpload(c *fiber.Ctx) error {
+	err := h.FaceService.ReceiveUpload(c.Context(), c.Params("organization_id"), c.Params("+"), c.Get("Content-Type"), c.Body(), c.Query("expires"), c.Query("signature"))
+	if err != nil {
+		status := faceErrorStatus(err)
+		if status == fiber.StatusNotFound {
+			status = fiber.StatusBadRequest
+		}
+		return c.Status(status).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.SendStatus(fiber.StatusOK)
+}
+
+// GetUploadSettings เป็น handler สำหรับดึงข้อจำกัดของรูปภาพที่อัปโหลดขององค์กร
+// @Summary Get face image upload limits
+// @Description Retrieve the file size (bytes) and dimension (pixels) limits of face images uploaded by the organization
+// @Tags faces
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} models.FaceUploadSettings
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse "Unauthorized (invalid or missing API key)"
+// @Failure 500 {object} ErrorResponse
+// @Router /api/faces/settings [get]
+func (h *FaceHandler) GetUploadSettings(c *fiber.Ctx) error {
+	// ดึง organization ID จาก context
+	organizationID := c.Locals("organization_id").(string)
+	if organizationID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "ไม่พบข้อมูลองค์กร",
+		})
+	}
+
+	settings, err := h.FaceService.GetUploadSettings(c.Context(), organizationID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(settings)
+}
+
+// UpdateUploadSettings เป็น handler สำหรับบันทึกข้อจำกัดของรูปภาพที่อัปโหลดขององค์กร
+// @Summary Update face image upload limits
+// @Description Configure the file size (bytes, at most 20 MB) and dimension (pixels) limits of face images uploaded by the organization, and how near-duplicate uploads are handled: dedup_mode off stores every upload, skip returns the existing image (200, duplicate true) and link records the upload as an image sharing the existing image's files (duplicate_of_id). An upload is a near-duplicate when its perceptual hash is within dedup_max_distance bits (0-32) of an image of the same person uploaded in the last dedup_window seconds. Fields that are omitted keep their current values.
+// @Tags faces
+// @Accept json
+// @Produce json
+// @Param settings body models.FaceUploadSettings true "Upload limits"
+// @Security ApiKeyAuth
+// @Success 200 {object} models.FaceUploadSettings
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse "Unauthorized (invalid or missing API key)"
+// @Failure 500 {object} ErrorResponse
+// @Router /api/faces/settings [put]
+func (h *FaceHandler) UpdateUploadSettings(c *fiber.Ctx) error {
+	// ดึง organization ID จาก context
+	organizationID := c.Locals("organization_id").(string)
+	if organizationID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "ไม่พบข้อมูลองค์กร",
+		})
+	}
+
+	// แปลงข้อมูลจาก request ทับการตั้งค่าปัจจุบัน ฟิลด์ที่ไม่ได้ส่งมาจึงคงค่าเดิม
+	settings, err := h.FaceService.GetUploadSettings(c.Context(), organizationID)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": err.Error(),
 		})
 	}
+	if err := c.BodyParser(settings); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "รูปแบบข้อมูลไม่ถูกต้อง",
+		})
+	}
+	settings.OrganizationID = organizationID
+
+	// บันทึกข้อจำกัด
+	if err := h.FaceService.UpdateUploadSettings(c.Context(), settings); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
 
-	return c.Status(fiber.StatusCreated).JSON(faceImage)
+	return c.JSON(settings)
 }
 
 // GetFaceImages เป็น handler สำหรับดึงรูปภาพใบหน้าตามรหัสบุคคล
 // @Summary Get face images by person hash
-// @Description Retrieve face images for a specific person
+// @Description Retrieve face images for a specific person with cursor pagination. The page parameter of the former offset pagination is rejected with 400, and pagination is {limit, next_cursor, has_more, total} instead of {total, page, page_size, total_page}.
 // @Tags faces
 // @Accept json
 // @Produce json
 // @Param person_hash path string true "Person's unique hash"
-// @Param page query int false "Page number (starting from 1)" default(1)
-// @Param page_size query int false "Items per page (max 100)" default(10)
+// @Param camera_id query []string false "Camera IDs to filter images by" collectionFormat(multi)
+// @Param cursor query string false "Cursor from pagination.next_cursor of the previous page"
+// @Param limit query int false "Number of items per page (max 100)" default(10)
+// @Param sort query string false "Sort field (created_at)" default(created_at)
+// @Param order query string false "Sort order (asc, desc)" default(desc)
+// @Param fields query string false "Comma-separated fields to return"
+// @Param include_total query bool false "Also count the total number of items (slower)"
 // @Security ApiKeyAuth
 // @Success 200 {object} FaceImagesResponse
 // @Failure 400 {object} ErrorResponse
@@ -121,28 +504,26 @@ func (h *FaceHandler) GetFaceImages(c *fiber.Ctx) error {
 		})
 	}
 
-	// ดึงค่า pagination จาก query parameters
-	page, err := strconv.Atoi(c.Query("page", "1"))
-	if err != nil || page < 1 {
-		page = 1
-	}
-
-	pageSize, err := strconv.Atoi(c.Query("page_size", "10"))
-	if err != nil || pageSize < 1 {
-		pageSize = 10
-	}
-	if pageSize > 100 {
-		pageSize = 100
+	// ดึงค่าการแบ่งหน้า การเรียงลำดับ และฟิลด์ที่เลือก
+	opts, err := parseListOptions(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
 	}
 
 	// ดึงรูปภาพ
-	images, pagination, err := h.FaceService.GetFaceImages(c.Context(), personHash, organizationID, page, pageSize)
+	images, pagination, err := h.FaceService.GetFaceImages(c.Context(), personHash, organizationID, queryValues(c, "camera_id"), opts)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		return c.Status(listErrorStatus(err)).JSON(fiber.Map{
 			"error": err.Error(),
 		})
 	}
 
+	if len(opts.Fields) > 0 {
+		return projectedResponse(c, images, pagination, opts.Fields)
+	}
+
 	// สร้าง response
 	response := FaceImagesResponse{
 		Data:       images,
@@ -201,8 +582,646 @@ func (h *FaceHandler) DeleteFaceImage(c *fiber.Ctx) error {
 	})
 }
 
+// ServeFaceImage เป็น handler สำหรับส่งไฟล์รูปภาพใบหน้าหรือรูปย่อที่เก็บใน storage ในเครื่อง
+// @Summary Get a face image file
+// @Description Serve a stored face image or thumbnail at the signed URL returned in image_url, thumbnail_url and thumbnails. The URL carries its own expires and signature query parameters instead of an API key and stops working after urls_expire_at. Supports conditional requests (ETag, If-None-Match, If-Modified-Since) and single byte ranges.
+// @Tags faces
+// @Produce octet-stream
+// @Param organization_id path string true "Organization ID"
+// @Param file path string true "File path, e.g. the image file name or thumbnails/{id}_{size}.jpg"
+// @Param expires query int true "Expiry time of the URL (Unix seconds)"
+// @Param signature query string true "Signature of the URL"
+// @Param Range header string false "Single byte range, e.g. bytes=0-1023"
+// @Success 200 {file} file
+// @Success 206 {file} file "Partial content"
+// @Success 304 "Not modified"
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse "Invalid or expired signature"
+// @Failure 404 {object} ErrorResponse
+// @Failure 416 {object} ErrorResponse "Range not satisfiable"
+// @Failure 500 {object} ErrorResponse
+// @Router /api/files/{organization_id}/{file} [get]
+func (h *FaceHandler) ServeFaceImage(c *fiber.Ctx) error {
+	// ตรวจลายเซ็นของ URL ซึ่งผูกกับ organization และ path ของไฟล์ จึงใช้เข้าถึงไฟล์อื่นไม่ได้
+	organizationID := c.Params("organization_id")
+	filePath := c.Params("+")
+	expires := c.Query("expires")
+	if err := h.FaceService.VerifyFaceImageURL(organizationID, filePath, expires, c.Query("signature")); err != nil {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	info, err := h.FaceService.StatFaceImageFile(c.Context(), organizationID, filePath)
+	if err != nil {
+		return c.Status(faceErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	// ไฟล์ถูกเขียนทับได้เฉพาะตอนสร้างรูปย่อใหม่ ซึ่งเปลี่ยน ETag จึง cache ได้จนกว่า URL หมดอายุและตรวจซ้ำด้วย ETag
+	c.Set("Content-Type", info.ContentType)
+	c.Set("Cache-Control", fmt.Sprintf("private, max-age=%d", signedURLMaxAge(expires)))
+	c.Set("X-Content-Type-Options", "nosniff")
+	c.Set("Accept-Ranges", "bytes")
+	c.Set("ETag", info.ETag)
+	c.Set("Last-Modified", info.ModTime.UTC().Format(http.TimeFormat))
+
+	if notModified(c.Get("If-None-Match"), c.Get("If-Modified-Since"), info.ETag, info.ModTime) {
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+
+	start, length := int64(0), info.Size
+	if header := c.Get("Range"); header != "" && ifRangeMatches(c.Get("If-Range"), info.ETag, info.ModTime) {
+		rangeStart, rangeLength, ok, satisfiable := byteRange(header, info.Size)
+		if ok && !satisfiable {
+			c.Set("Content-Range", fmt.Sprintf("bytes */%d", info.Size))
+			return c.Status(fiber.StatusRequestedRangeNotSatisfiable).JSON(fiber.Map{
+				"error": "ช่วงข้อมูลที่ขออยู่นอกไฟล์",
+			})
+		}
+		if ok {
+			start, length = rangeStart, rangeLength
+			c.Status(fiber.StatusPartialContent)
+			c.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, start+length-1, info.Size))
+		}
+	}
+
+	reader, err := h.FaceService.OpenFaceImageFile(c.Context(), organizationID, filePath)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if start > 0 {
+		if _, err := io.CopyN(io.Discard, reader, start); err != nil {
+			reader.Close()
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "ไม่สามารถอ่านไฟล์รูปภาพ",
+			})
+		}
+	}
+
+	// fasthttp ปิด reader ให้เมื่อส่งข้อมูลครบ
+	return c.SendStream(struct {
+		io.Reader
+		io.Closer
+	}{io.LimitReader(reader, length), reader}, int(length))
+}
+
+// signedURLMaxAge คืนจำนวนวินาทีที่เหลือก่อน URL ที่มีลายเซ็นหมดอายุ สำหรับ Cache-Control
+func signedURLMaxAge(expires string) int64 {
+	expiresAt, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return 0
+	}
+	return max(expiresAt-time.Now().Unix(), 0)
+}
+
+// notModified ตรวจ If-None-Match (หรือ If-Modified-Since เมื่อไม่มี If-None-Match) ว่าไฟล์ของ client ยังเป็นปัจจุบัน
+func notModified(ifNoneMatch, ifModifiedSince, etag string, modTime time.Time) bool {
+	if ifNoneMatch != "" {
+		for _, candidate := range strings.Split(ifNoneMatch, ",") {
+			candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+			if candidate == "*" || candidate == etag {
+				return true
+			}
+		}
+		return false
+	}
+	if ifModifiedSince == "" {
+		return false
+	}
+	since, err := http.ParseTime(ifModifiedSince)
+	return err == nil && !modTime.Truncate(time.Second).After(since)
+}
+
+// ifRangeMatches ตรวจว่า If-Range (ถ้ามี) ยังตรงกับไฟล์ปัจจุบัน ถ้าไม่ตรงต้องส่งทั้งไฟล์
+func ifRangeMatches(ifRange, etag string, modTime time.Time) bool {
+	if ifRange == "" {
+		return true
+	}
+	if strings.HasPrefix(ifRange, `"`) {
+		return ifRange == etag
+	}
+	since, err := http.ParseTime(ifRange)
+	return err == nil && modTime.Truncate(time.Second).Equal(since)
+}
+
+// byteRange แปลง Range header แบบช่วงเดียว (bytes=start-end, bytes=start- หรือ bytes=-suffix) เป็นตำแหน่งเริ่มและความยาว
+// ok เป็น false เมื่ออ่าน header ไม่ได้หรือขอหลายช่วง ซึ่งจะส่งทั้งไฟล์แทน
+// satisfiable เป็น false เมื่อช่วงที่ขออยู่นอกไฟล์
+func byteRange(header string, size int64) (start, length int64, ok, satisfiable bool) {
+	spec, found := strings.CutPrefix(header, "bytes=")
+	if !found || strings.Contains(spec, ",") {
+		return 0, 0, false, false
+	}
+	first, last, found := strings.Cut(strings.TrimSpace(spec), "-")
+	if !found {
+		return 0, 0, false, false
+	}
+
+	if first == "" {
+		// ขอ suffix bytes สุดท้ายของไฟล์
+		suffix, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || suffix < 0 {
+			return 0, 0, false, false
+		}
+		if suffix == 0 || size == 0 {
+			return 0, 0, true, false
+		}
+		suffix = min(suffix, size)
+		return size - suffix, suffix, true, true
+	}
+
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil || start < 0 {
+		return 0, 0, false, false
+	}
+	end := size - 1
+	if last != "" {
+		end, err = strconv.ParseInt(last, 10, 64)
+		if err != nil || end < start {
+			return 0, 0, false, false
+		}
+		end = min(end, size-1)
+	}
+	if start >= size {
+		return 0, 0, true, false
+	}
+	return start, end - start + 1, true, true
+}
+
+// CreateThumbnailBackfillJob เป็น handler สำหรับสร้างงานสร้างรูปย่อของรูปภาพใบหน้าเดิมแบบเบื้องหลัง
+// @Summary Create thumbnail backfill job
+// @Description Generate thumbnails of the configured sizes for the organization's face images uploaded before thumbnails were generated, or before a size was added. Images that already have every size are skipped, so the job can be started again to retry failures.
+// @Tags admin
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 202 {object} models.Job
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse "Unauthorized (invalid or missing API key)"
+// @Failure 500 {object} ErrorResponse
+// @Router /api/admin/thumbnail-backfills [post]
+func (h *FaceHandler) CreateThumbnailBackfillJob(c *fiber.Ctx) error {
+	// ดึง organization ID จาก context
+	organizationID := c.Locals("organization_id").(string)
+	if organizationID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "ไม่พบข้อมูลองค์กร",
+		})
+	}
+
+	job, err := h.FaceService.StartThumbnailBackfill(c.Context(), organizationID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(job)
+}
+
+// ListThumbnailBackfillJobs เป็น handler สำหรับดึงรายการงานสร้างรูปย่อ
+// @Summary List thumbnail backfill jobs
+// @Description List the thumbnail backfill jobs of the caller's organization, newest first
+// @Tags admin
+// @Produce json
+// @Param page query int false "Page number to retrieve (starting from 1)" default(1)
+// @Param page_size query int false "Number of items per page (max 100)" default(10)
+// @Security ApiKeyAuth
+// @Success 200 {object} ListExportJobsResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse "Unauthorized (invalid or missing API key)"
+// @Failure 500 {object} ErrorResponse
+// @Router /api/admin/thumbnail-backfills [get]
+func (h *FaceHandler) ListThumbnailBackfillJobs(c *fiber.Ctx) error {
+	// ดึง organization ID จาก context
+	organizationID := c.Locals("organization_id").(string)
+	if organizationID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "ไม่พบข้อมูลองค์กร",
+		})
+	}
+
+	page, err := strconv.Atoi(c.Query("page", "1"))
+	if err != nil || page <= 0 {
+		page = 1
+	}
+	pageSize, err := strconv.Atoi(c.Query("page_size", "10"))
+	if err != nil || pageSize <= 0 {
+		pageSize = 10
+	}
+	if pageSize > 100 {
+		pageSize = 100
+	}
+
+	jobs, pagination, err := h.FaceService.Jobs.ListJobs(c.Context(), organizationID, models.JobTypeThumbnailBackfill, page, pageSize)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(ListExportJobsResponse{
+		Data:       jobs,
+		Pagination: pagination,
+	})
+}
+
+// GetThumbnailBackfillJob เป็น handler สำหรับดึงสถานะของงานสร้างรูปย่อ
+// @Summary Get thumbnail backfill job
+// @Description Get the status, progress (images checked out of total) and result of a thumbnail backfill job. The result counts generated, skipped and failed images and lists the failures.
+// @Tags admin
+// @Produce json
+// @Param id path string true "Job ID"
+// @Security ApiKeyAuth
+// @Success 200 {object} models.Job
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse "Unauthorized (invalid or missing API key)"
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/admin/thumbnail-backfills/{id} [get]
+func (h *FaceHandler) GetThumbnailBackfillJob(c *fiber.Ctx) error {
+	// ดึง organization ID จาก context
+	organizationID := c.Locals("organization_id").(string)
+	if organizationID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "ไม่พบข้อมูลองค์กร",
+		})
+	}
+
+	job, err := h.FaceService.GetThumbnailBackfillJob(c.Context(), c.Params("id"), organizationID)
+	if err != nil {
+		return c.Status(faceErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(job)
+}
+
+// CreateFaceBatchUploadJob เป็น handler สำหรับสร้างงานอัปโหลดรูปภาพใบหน้าแบบกลุ่มจาก archive
+// @Summary Create face batch upload job
+// @Description Upload a zip, tar or tar.gz archive of face images with a manifest.csv or manifest.json at its top level. Each manifest entry maps file (path relative to the manifest) to person_hash, camera_id and the optional captured_at (RFC 3339), face_confidence, yaw and embedding (a JSON array, also in CSV); CSV manifests have a header row with those column names. The archive and manifest are checked before the job is created (code invalid_archive or invalid_manifest); the images are then created in the background like POST /api/faces, FACE_BATCH_CONCURRENCY at a time. Archives larger than FACE_BATCH_MAX_SIZE_MB are rejected with 413.
+// @Tags faces
+// @Accept multipart/form-data
+// @Produce json
+// @Param archive formData file true "zip, tar or tar.gz archive"
+// @Security ApiKeyAuth
+// @Success 202 {object} models.Job
+// @Failure 400 {object} FaceImageErrorResponse
+// @Failure 401 {object} ErrorResponse "Unauthorized (invalid or missing API key)"
+// @Failure 413 {object} ErrorResponse "Archive too large"
+// @Failure 500 {object} ErrorResponse
+// @Router /api/faces/batches [post]
+func (h *FaceHandler) CreateFaceBatchUploadJob(c *fiber.Ctx) error {
+	// ดึง organization ID จาก context
+	organizationID := c.Locals("organization_id").(string)
+	if organizationID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "ไม่พบข้อมูลองค์กร",
+		})
+	}
+
+	file, err := c.FormFile("archive")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "ไม่พบไฟล์ในการอัปโหลด",
+		})
+	}
+
+	job, err := h.FaceService.StartFaceBatchUpload(c.Context(), organizationID, file)
+	if err != nil {
+		return c.Status(faceErrorStatus(err)).JSON(faceErrorResponse(err))
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(job)
+}
+
+// ListFaceBatchUploadJobs เป็น handler สำหรับดึงรายการงานอัปโหลดรูปภาพใบหน้าแบบกลุ่ม
+// @Summary List face batch upload jobs
+// @Description List the face batch upload jobs of the caller's organization, newest first
+// @Tags faces
+// @Produce json
+// @Param page query int false "Page number to retrieve (starting from 1)" default(1)
+// @Param page_size query int false "Number of items per page (max 100)" default(10)
+// @Security ApiKeyAuth
+// @Success 200 {object} ListExportJobsResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse "Unauthorized (invalid or missing API key)"
+// @Failure 500 {object} ErrorResponse
+// @Router /api/faces/batches [get]
+func (h *FaceHandler) ListFaceBatchUploadJobs(c *fiber.Ctx) error {
+	// ดึง organization ID จาก context
+	organizationID := c.Locals("organization_id").(string)
+	if organizationID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "ไม่พบข้อมูลองค์กร",
+		})
+	}
+
+	page, err := strconv.Atoi(c.Query("page", "1"))
+	if err != nil || page <= 0 {
+		page = 1
+	}
+	pageSize, err := strconv.Atoi(c.Query("page_size", "10"))
+	if err != nil || pageSize <= 0 {
+		pageSize = 10
+	}
+	if pageSize > 100 {
+		pageSize = 100
+	}
+
+	jobs, pagination, err := h.FaceService.Jobs.ListJobs(c.Context(), organizationID, models.JobTypeFaceBatchUpload, page, pageSize)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(ListExportJobsResponse{
+		Data:       jobs,
+		Pagination: pagination,
+	})
+}
+
+// GetFaceBatchUploadJob เป็น handler สำหรับดึงสถานะและผลลัพธ์ของงานอัปโหลดรูปภาพใบหน้าแบบกลุ่ม
+// @Summary Get face batch upload job
+// @Description Get the status, progress (entries processed out of total) and result of a face batch upload job. The result counts created and failed entries and lists every manifest entry in order with its face_image_id, or a code and error: invalid_entry, file_not_found, invalid_archive, upload_failed or one of the image codes of POST /api/faces.
+// @Tags faces
+// @Produce json
+// @Param id path string true "Job ID"
+// @Security ApiKeyAuth
+// @Success 200 {object} models.Job
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse "Unauthorized (invalid or missing API key)"
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/faces/batches/{id} [get]
+func (h *FaceHandler) GetFaceBatchUploadJob(c *fiber.Ctx) error {
+	// ดึง organization ID จาก context
+	organizationID := c.Locals("organization_id").(string)
+	if organizationID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "ไม่พบข้อมูลองค์กร",
+		})
+	}
+
+	job, err := h.FaceService.GetFaceBatchUploadJob(c.Context(), c.Params("id"), organizationID)
+	if err != nil {
+		return c.Status(faceErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(job)
+}
+
+// CreateFaceDedupJob เป็น handler สำหรับสร้างงานรวมรูปภาพใบหน้าเดิมที่ซ้ำกันแบบเบื้องหลัง
+// @Summary Create face near-duplicate cleanup job
+// @Description Collapse the organization's existing near-duplicate face images using its dedup_max_distance and dedup_window settings. Images uploaded before perceptual hashes were stored are hashed first. For each person, the image with the most pixels (then the newest) is kept; its near-duplicates are deleted with their files, or turned into images that share the kept image's files when dedup_mode is link. Watchlist alerts are moved to the kept image.
+// @Tags admin
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 202 {object} models.Job
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse "Unauthorized (invalid or missing API key)"
+// @Failure 500 {object} ErrorResponse
+// @Router /api/admin/face-dedups [post]
+func (h *FaceHandler) CreateFaceDedupJob(c *fiber.Ctx) error {
+	// ดึง organization ID จาก context
+	organizationID := c.Locals("organization_id").(string)
+	if organizationID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "ไม่พบข้อมูลองค์กร",
+		})
+	}
+
+	job, err := h.FaceService.StartFaceDedup(c.Context(), organizationID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(job)
+}
+
+// ListFaceDedupJobs เป็น handler สำหรับดึงรายการงานรวมรูปภาพใบหน้าที่ซ้ำ
+// @Summary List face near-duplicate cleanup jobs
+// @Description List the face near-duplicate cleanup jobs of the caller's organization, newest first
+// @Tags admin
+// @Produce json
+// @Param page query int false "Page number to retrieve (starting from 1)" default(1)
+// @Param page_size query int false "Number of items per page (max 100)" default(10)
+// @Security ApiKeyAuth
+// @Success 200 {object} ListExportJobsResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse "Unauthorized (invalid or missing API key)"
+// @Failure 500 {object} ErrorResponse
+// @Router /api/admin/face-dedups [get]
+func (h *FaceHandler) ListFaceDedupJobs(c *fiber.Ctx) error {
+	// ดึง organization ID จาก context
+	organizationID := c.Locals("organization_id").(string)
+	if organizationID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "ไม่พบข้อมูลองค์กร",
+		})
+	}
+
+	page, err := strconv.Atoi(c.Query("page", "1"))
+	if err != nil || page <= 0 {
+		page = 1
+	}
+	pageSize, err := strconv.Atoi(c.Query("page_size", "10"))
+	if err != nil || pageSize <= 0 {
+		pageSize = 10
+	}
+	if pageSize > 100 {
+		pageSize = 100
+	}
+
+	jobs, pagination, err := h.FaceService.Jobs.ListJobs(c.Context(), organizationID, models.JobTypeFaceDedup, page, pageSize)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(ListExportJobsResponse{
+		Data:       jobs,
+		Pagination: pagination,
+	})
+}
+
+// GetFaceDedupJob เป็น handler สำหรับดึงสถานะของงานรวมรูปภาพใบหน้าที่ซ้ำ
+// @Summary Get face near-duplicate cleanup job
+// @Description Get the status, progress (people compared out of total) and result of a face near-duplicate cleanup job. The result counts compared, newly hashed and collapsed images and lists images that could not be hashed.
+// @Tags admin
+// @Produce json
+// @Param id path string true "Job ID"
+// @Security ApiKeyAuth
+// @Success 200 {object} models.Job
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse "Unauthorized (invalid or missing API key)"
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/admin/face-dedups/{id} [get]
+func (h *FaceHandler) GetFaceDedupJob(c *fiber.Ctx) error {
+	// ดึง organization ID จาก context
+	organizationID := c.Locals("organization_id").(string)
+	if organizationID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "ไม่พบข้อมูลองค์กร",
+		})
+	}
+
+	job, err := h.FaceService.GetFaceDedupJob(c.Context(), c.Params("id"), organizationID)
+	if err != nil {
+		return c.Status(faceErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(job)
+}
+
+// StorageReconcileRequest เป็นโครงสร้างสำหรับสร้างงานเปรียบเทียบไฟล์ใน storage กับข้อมูลรูปภาพใบหน้า
+type StorageReconcileRequest struct {
+	ObjectAction string `json:"object_action,omitempty" example:"quarantine"` // report (default), delete or quarantine
+	ImageAction  string `json:"image_action,omitempty" example:"report"`      // report (default) or delete
+	DryRun       bool   `json:"dry_run" example:"true"`
+}
+
+// CreateStorageReconcileJob เป็น handler สำหรับสร้างงานเปรียบเทียบไฟล์ใน storage กับข้อมูลรูปภาพใบหน้าแบบเบื้องหลัง
+// @Summary Create storage reconcile job
+// @Description Compare the objects stored under the organization's prefix with its face images, in both directions. Objects that no face image uses and that are older than an hour (files of deleted persons or images, or of uploads that failed midway) are reported, deleted or moved under {organization_id}/quarantine/ by object_action. Face images whose image file is missing are reported or deleted by image_action; missing thumbnails are only reported. Pending direct uploads and quarantined objects are not checked. Set dry_run to only report what would be deleted or quarantined.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param job body StorageReconcileRequest true "Reconcile parameters"
+// @Security ApiKeyAuth
+// @Success 202 {object} models.Job
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse "Unauthorized (invalid or missing API key)"
+// @Failure 500 {object} ErrorResponse
+// @Router /api/admin/storage-reconciliations [post]
+func (h *FaceHandler) CreateStorageReconcileJob(c *fiber.Ctx) error {
+	// ดึง organization ID จาก context
+	organizationID := c.Locals("organization_id").(string)
+	if organizationID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "ไม่พบข้อมูลองค์กร",
+		})
+	}
+
+	var req StorageReconcileRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "รูปแบบข้อมูลไม่ถูกต้อง",
+		})
+	}
+
+	job, err := h.FaceService.StartStorageReconcile(c.Context(), organizationID, models.StorageReconcileParams{
+		ObjectAction: req.ObjectAction,
+		ImageAction:  req.ImageAction,
+		DryRun:       req.DryRun,
+	})
+	if err != nil {
+		status := fiber.StatusInternalServerError
+		if strings.HasPrefix(err.Error(), "object_action ") || strings.HasPrefix(err.Error(), "image_action ") {
+			status = fiber.StatusBadRequest
+		}
+		return c.Status(status).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(job)
+}
+
+// ListStorageReconcileJobs เป็น handler สำหรับดึงรายการงานเปรียบเทียบไฟล์ใน storage
+// @Summary List storage reconcile jobs
+// @Description List the storage reconcile jobs of the caller's organization, newest first
+// @Tags admin
+// @Produce json
+// @Param page query int false "Page number to retrieve (starting from 1)" default(1)
+// @Param page_size query int false "Number of items per page (max 100)" default(10)
+// @Security ApiKeyAuth
+// @Success 200 {object} ListExportJobsResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse "Unauthorized (invalid or missing API key)"
+// @Failure 500 {object} ErrorResponse
+// @Router /api/admin/storage-reconciliations [get]
+func (h *FaceHandler) ListStorageReconcileJobs(c *fiber.Ctx) error {
+	// ดึง organization ID จาก context
+	organizationID := c.Locals("organization_id").(string)
+	if organizationID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "ไม่พบข้อมูลองค์กร",
+		})
+	}
+
+	page, err := strconv.Atoi(c.Query("page", "1"))
+	if err != nil || page <= 0 {
+		page = 1
+	}
+	pageSize, err := strconv.Atoi(c.Query("page_size", "10"))
+	if err != nil || pageSize <= 0 {
+		pageSize = 10
+	}
+	if pageSize > 100 {
+		pageSize = 100
+	}
+
+	jobs, pagination, err := h.FaceService.Jobs.ListJobs(c.Context(), organizationID, models.JobTypeStorageReconcile, page, pageSize)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(ListExportJobsResponse{
+		Data:       jobs,
+		Pagination: pagination,
+	})
+}
+
+// GetStorageReconcileJob เป็น handler สำหรับดึงสถานะของงานเปรียบเทียบไฟล์ใน storage
+// @Summary Get storage reconcile job
+// @Description Get the status, progress (objects listed plus face images checked) and result of a storage reconcile job. The result counts orphan objects and missing files and what was deleted or quarantined (or would be, in a dry run), and lists up to 1000 of each.
+// @Tags admin
+// @Produce json
+// @Param id path string true "Job ID"
+// @Security ApiKeyAuth
+// @Success 200 {object} models.Job
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse "Unauthorized (invalid or missing API key)"
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/admin/storage-reconciliations/{id} [get]
+func (h *FaceHandler) GetStorageReconcileJob(c *fiber.Ctx) error {
+	// ดึง organization ID จาก context
+	organizationID := c.Locals("organization_id").(string)
+	if organizationID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "ไม่พบข้อมูลองค์กร",
+		})
+	}
+
+	job, err := h.FaceService.GetStorageReconcileJob(c.Context(), c.Params("id"), organizationID)
+	if err != nil {
+		return c.Status(faceErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(job)
+}
+
 // FaceImagesResponse โครงสร้างสำหรับส่งข้อมูลรายการรูปภาพใบหน้าพร้อมข้อมูลการแบ่งหน้า
 type FaceImagesResponse struct {
-	Data       []models.FaceImage  `json:"data"`
-	Pagination *models.Pagination `json:"pagination"`
+	Data       []models.FaceImage       `json:"data"`
+	Pagination *models.CursorPagination `json:"pagination"`
 }
\ No newline at end of file
@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/services"
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/storage"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newFaceImageApp สร้าง app ที่ส่งรูปภาพจาก storage ชั่วคราว และคืน storage ไว้สร้าง URL ที่มีลายเซ็น
+func newFaceImageApp(t *testing.T) (*fiber.App, *storage.LocalStorageService) {
+	store, err := storage.NewLocalStorageService(t.TempDir(), "http://images.example.com/api/files", []byte("test-signing-key"))
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	content := []byte("0123456789")
+	require.NoError(t, store.PutObject(ctx, "org-1/face.jpg", bytes.NewReader(content), int64(len(content)), "image/jpeg"))
+	require.NoError(t, store.PutObject(ctx, "org-1/thumbnails/face_48.jpg", bytes.NewReader(content), int64(len(content)), "image/jpeg"))
+	require.NoError(t, store.PutObject(ctx, "org-2/face.jpg", bytes.NewReader(content), int64(len(content)), "image/jpeg"))
+
+	handler := NewFaceHandler(&services.FaceService{Storage: store})
+	app := fiber.New()
+	app.Get("/api/files/:organization_id/+", handler.ServeFaceImage)
+	return app, store
+}
+
+// signedTarget สร้าง path พร้อม query ของ URL ที่มีลายเซ็นสำหรับส่งไปยัง app
+func signedTarget(t *testing.T, store *storage.LocalStorageService, key string, ttl time.Duration) string {
+	signedURL, err := store.SignedURL(key, ttl)
+	require.NoError(t, err)
+	parsed, err := url.Parse(signedURL)
+	require.NoError(t, err)
+	return parsed.RequestURI()
+}
+
+// TestServeFaceImage ทดสอบการส่งไฟล์รูปภาพพร้อม header และการตรวจลายเซ็นของ URL
+func TestServeFaceImage(t *testing.T) {
+	app, store := newFaceImageApp(t)
+	target := signedTarget(t, store, "org-1/face.jpg", time.Hour)
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, target, nil))
+	require.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "0123456789", string(body))
+	assert.Equal(t, "image/jpeg", resp.Header.Get("Content-Type"))
+	assert.Regexp(t, `^private, max-age=(3600|3599)$`, resp.Header.Get("Cache-Control"))
+	assert.Equal(t, "bytes", resp.Header.Get("Accept-Ranges"))
+	etag := resp.Header.Get("ETag")
+	assert.NotEmpty(t, etag)
+
+	// ไฟล์รูปย่ออยู่ใน path ย่อย
+	resp, err = app.Test(httptest.NewRequest(http.MethodGet, signedTarget(t, store, "org-1/thumbnails/face_48.jpg", time.Hour), nil))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// ETag ตรงกับของ client
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	req.Header.Set("If-None-Match", etag)
+	resp, err = app.Test(req)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotModified, resp.StatusCode)
+
+	// ไฟล์ที่ไม่มีแต่ลายเซ็นถูกต้อง
+	resp, err = app.Test(httptest.NewRequest(http.MethodGet, signedTarget(t, store, "org-1/missing.jpg", time.Hour), nil))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	// ไม่มีลายเซ็น URL หมดอายุ ลายเซ็นของไฟล์อื่น และ path ที่ออกนอกองค์กร
+	query := target[strings.Index(target, "?"):]
+	for _, forbidden := range []string{
+		"/api/files/org-1/face.jpg",
+		signedTarget(t, store, "org-1/face.jpg", -time.Minute),
+		"/api/files/org-2/face.jpg" + query,
+		"/api/files/org-1/thumbnails/face_48.jpg" + query,
+		"/api/files/org-1/thumbnails/../../org-2/face.jpg" + query,
+	} {
+		resp, err = app.Test(httptest.NewRequest(http.MethodGet, forbidden, nil))
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode, forbidden)
+	}
+}
+
+// TestServeFaceImage_Range ทดสอบการส่งไฟล์บางช่วงตาม Range header
+func TestServeFaceImage_Range(t *testing.T) {
+	app, store := newFaceImageApp(t)
+	target := signedTarget(t, store, "org-1/face.jpg", time.Hour)
+
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	req.Header.Set("Range", "bytes=2-5")
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, http.StatusPartialContent, resp.StatusCode)
+	assert.Equal(t, "2345", string(body))
+	assert.Equal(t, "bytes 2-5/10", resp.Header.Get("Content-Range"))
+
+	req = httptest.NewRequest(http.MethodGet, target, nil)
+	req.Header.Set("Range", "bytes=20-")
+	resp, err = app.Test(req)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusRequestedRangeNotSatisfiable, resp.StatusCode)
+	assert.Equal(t, "bytes */10", resp.Header.Get("Content-Range"))
+
+	// If-Range ที่ไม่ตรงกับไฟล์ปัจจุบันได้ทั้งไฟล์
+	req = httptest.NewRequest(http.MethodGet, target, nil)
+	req.Header.Set("Range", "bytes=2-5")
+	req.Header.Set("If-Range", `"stale"`)
+	resp, err = app.Test(req)
+	require.NoError(t, err)
+	body, _ = io.ReadAll(resp.Body)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "0123456789", string(body))
+}
+
+// TestByteRange ทดสอบการแปลง Range header
+func TestByteRange(t *testing.T) {
+	tests := []struct {
+		header      string
+		start       int64
+		length      int64
+		ok          bool
+		satisfiable bool
+	}{
+		{"bytes=0-3", 0, 4, true, true},
+		{"bytes=5-", 5, 5, true, true},
+		{"bytes=-3", 7, 3, true, true},
+		{"bytes=8-100", 8, 2, true, true},
+		{"bytes=-100", 0, 10, true, true},
+		{"bytes=10-", 0, 0, true, false},
+		{"bytes=-0", 0, 0, true, false},
+		{"bytes=0-1,4-5", 0, 0, false, false},
+		{"bytes=5-2", 0, 0, false, false},
+		{"items=0-3", 0, 0, false, false},
+		{"bytes=abc", 0, 0, false, false},
+	}
+	for _, tt := range tests {
+		start, length, ok, satisfiable := byteRange(tt.header, 10)
+		assert.Equal(t, tt.ok, ok, tt.header)
+		assert.Equal(t, tt.satisfiable, satisfiable, tt.header)
+		if tt.satisfiable {
+			assert.Equal(t, tt.start, start, tt.header)
+			assert.Equal(t, tt.length, length, tt.header)
+		}
+	}
+}
+
+// TestReceiveUpload ทดสอบการอัปโหลดไฟล์ไปยัง URL สำหรับอัปโหลดของ storage ในเครื่อง
+func TestReceiveUpload(t *testing.T) {
+	store, err := storage.NewLocalStorageService(t.TempDir(), "http://images.example.com/api/files", []byte("test-signing-key"))
+	require.NoError(t, err)
+	handler := NewFaceHandler(&services.FaceService{Storage: store})
+	app := fiber.New()
+	app.Put("/api/files/:organization_id/+", handler.ReceiveUpload)
+
+	content := []byte("uploaded image")
+	uploadURL, err := store.SignedUploadURL("org-1/uploads/intent-1", "image/jpeg", int64(len(content)), time.Hour)
+	require.NoError(t, err)
+	parsed, err := url.Parse(uploadURL)
+	require.NoError(t, err)
+	target := parsed.RequestURI()
+
+	upload := func(target, contentType string, body []byte) int {
+		req := httptest.NewRequest(http.MethodPut, target, bytes.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		return resp.StatusCode
+	}
+
+	// Content-Type หรือขนาดไฟล์ต่างจากที่ขอไว้ และ URL ขององค์กรอื่น
+	assert.Equal(t, http.StatusForbidden, upload(target, "image/png", content))
+	assert.Equal(t, http.StatusForbidden, upload(target, "image/jpeg", append(content, '!')))
+	assert.Equal(t, http.StatusForbidden, upload(strings.Replace(target, "/org-1/", "/org-2/", 1), "image/jpeg", content))
+	_, err = store.StatObject(context.Background(), "org-1/uploads/intent-1")
+	assert.Error(t, err)
+
+	// URL สำหรับดาวน์โหลดใช้อัปโหลดไม่ได้ จึงเขียนทับรูปภาพที่มีอยู่ด้วย URL ของรูปภาพใน response ไม่ได้
+	existing := []byte("existing image")
+	require.NoError(t, store.PutObject(context.Background(), "org-1/face.jpg", bytes.NewReader(existing), int64(len(existing)), "image/jpeg"))
+	assert.Equal(t, http.StatusForbidden, upload(signedTarget(t, store, "org-1/face.jpg", time.Hour), "image/jpeg", content))
+	assert.Equal(t, http.StatusForbidden, upload(signedTarget(t, store, "org-1/uploads/intent-1", time.Hour), "image/jpeg", content))
+	reader, _, err := store.GetObject(context.Background(), "org-1/face.jpg")
+	require.NoError(t, err)
+	stored, _ := io.ReadAll(reader)
+	reader.Close()
+	assert.Equal(t, existing, stored)
+
+	assert.Equal(t, http.StatusOK, upload(target, "image/jpeg", content))
+	reader, _, err = store.GetObject(context.Background(), "org-1/uploads/intent-1")
+	require.NoError(t, err)
+	defer reader.Close()
+	stored, _ = io.ReadAll(reader)
+	assert.Equal(t, content, stored)
+}
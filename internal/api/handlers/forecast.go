@@ -0,0 +1,232 @@
+package handlers
+
+import (
+	"strings"
+	"time"
+
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/models"
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+// ForecastHandler เป็นโครงสร้างสำหรับจัดการ API endpoints เกี่ยวกับการพยากรณ์ปริมาณคน
+type ForecastHandler struct {
+	ForecastService *services.ForecastService
+}
+
+// NewForecastHandler สร้าง ForecastHandler ใหม่
+func NewForecastHandler(forecastService *services.ForecastService) *ForecastHandler {
+	return &ForecastHandler{
+		ForecastService: forecastService,
+	}
+}
+
+// GetForecast เป็น handler สำหรับดึงผลพยากรณ์ปริมาณคนรายชั่วโมง
+// @Summary Get hourly traffic forecast
+// @Description Retrieve hourly visitor forecasts with 95% confidence intervals and backtest accuracy (MAE, RMSE, MAPE, interval coverage) for an organization, site or camera
+// @Tags stats
+// @Accept json
+// @Produce json
+// @Param scope query string false "Forecast scope (organization, site, camera)" default(organization)
+// @Param scope_id query string false "Site ID or camera ID (required when scope is site or camera)"
+// @Param from query string false "Start time (format YYYY-MM-DDTHH:MM:SSZ), defaults to the current hour"
+// @Param to query string false "End time (format YYYY-MM-DDTHH:MM:SSZ), defaults to the end of the forecast horizon"
+// @Security ApiKeyAuth
+// @Success 200 {object} models.ForecastResult
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse "Unauthorized (invalid or missing API key)"
+// @Failure 500 {object} ErrorResponse
+// @Router /api/stats/forecast [get]
+func (h *ForecastHandler) GetForecast(c *fiber.Ctx) error {
+	// ดึง organization ID จาก context
+	organizationID := c.Locals("organization_id").(string)
+	if organizationID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "ไม่พบข้อมูลองค์กร",
+		})
+	}
+
+	scope := c.Query("scope", models.ForecastScopeOrganization)
+	scopeID := c.Query("scope_id")
+	if scope != models.ForecastScopeOrganization && scopeID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "ต้องระบุ scope_id เมื่อ scope เป็น site หรือ camera",
+		})
+	}
+
+	// ค่าเริ่มต้นคือตั้งแต่ชั่วโมงปัจจุบันจนสิ้นสุดช่วงพยากรณ์
+	from := time.Now().UTC().Truncate(time.Hour)
+	to := from.AddDate(0, 0, h.ForecastService.HorizonDays)
+	if fromStr := c.Query("from"); fromStr != "" {
+		parsed, err := time.Parse(time.RFC3339, fromStr)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "รูปแบบของพารามิเตอร์ from ไม่ถูกต้อง โปรดใช้รูปแบบ YYYY-MM-DDTHH:MM:SSZ",
+			})
+		}
+		from = parsed
+	}
+	if toStr := c.Query("to"); toStr != "" {
+		parsed, err := time.Parse(time.RFC3339, toStr)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "รูปแบบของพารามิเตอร์ to ไม่ถูกต้อง โปรดใช้รูปแบบ YYYY-MM-DDTHH:MM:SSZ",
+			})
+		}
+		to = parsed
+	}
+	if !to.After(from) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "to ต้องมากกว่า from",
+		})
+	}
+
+	result, err := h.ForecastService.GetForecast(c.Context(), organizationID, scope, scopeID, from, to)
+	if err != nil {
+		if strings.HasPrefix(err.Error(), "scope ต้องเป็น") ||
+			err.Error() == "ไม่พบขอบเขตของการพยากรณ์" ||
+			strings.HasPrefix(err.Error(), "ข้อมูลย้อนหลังไม่พอ") ||
+			strings.HasPrefix(err.Error(), "ข้อมูลวันปกติไม่พอ") {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(result)
+}
+
+// HolidayRequest เป็นโครงสร้างข้อมูลสำหรับเพิ่มวันหยุด
+type HolidayRequest struct {
+	Date string `json:"date" example:"2025-12-31"`
+	Name string `json:"name" example:"New Year's Eve"`
+}
+
+// GetHolidays เป็น handler สำหรับดึงรายการวันหยุดขององค์กร
+// @Summary List holidays
+// @Description Retrieve the holidays used by the holiday-aware traffic forecast
+// @Tags stats
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {array} models.Holiday
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse "Unauthorized (invalid or missing API key)"
+// @Failure 500 {object} ErrorResponse
+// @Router /api/holidays [get]
+func (h *ForecastHandler) GetHolidays(c *fiber.Ctx) error {
+	// ดึง organization ID จาก context
+	organizationID := c.Locals("organization_id").(string)
+	if organizationID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "ไม่พบข้อมูลองค์กร",
+		})
+	}
+
+	holidays, err := h.ForecastService.ListHolidays(c.Context(), organizationID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(holidays)
+}
+
+// CreateHoliday เป็น handler สำหรับเพิ่มวันหยุดขององค์กร
+// @Summary Add a holiday
+// @Description Add a date on which traffic follows the holiday pattern instead of the weekday pattern
+// @Tags stats
+// @Accept json
+// @Produce json
+// @Param holiday body HolidayRequest true "Holiday details"
+// @Security ApiKeyAuth
+// @Success 201 {object} models.Holiday
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse "Unauthorized (invalid or missing API key)"
+// @Failure 500 {object} ErrorResponse
+// @Router /api/holidays [post]
+func (h *ForecastHandler) CreateHoliday(c *fiber.Ctx) error {
+	// ดึง organization ID จาก context
+	organizationID := c.Locals("organization_id").(string)
+	if organizationID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "ไม่พบข้อมูลองค์กร",
+		})
+	}
+
+	var req HolidayRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "รูปแบบข้อมูลไม่ถูกต้อง",
+		})
+	}
+
+	date, err := time.Parse("2006-01-02", req.Date)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "รูปแบบวันที่ไม่ถูกต้อง โปรดใช้รูปแบบ YYYY-MM-DD",
+		})
+	}
+	if req.Name == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "ต้องระบุชื่อวันหยุด",
+		})
+	}
+
+	holiday := models.Holiday{
+		OrganizationID: organizationID,
+		Date:           date,
+		Name:           req.Name,
+	}
+	if err := h.ForecastService.CreateHoliday(c.Context(), &holiday); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(holiday)
+}
+
+// DeleteHoliday เป็น handler สำหรับลบวันหยุดขององค์กร
+// @Summary Delete a holiday
+// @Description Delete a holiday by ID
+// @Tags stats
+// @Accept json
+// @Produce json
+// @Param id path string true "Holiday ID"
+// @Security ApiKeyAuth
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse "Unauthorized (invalid or missing API key)"
+// @Failure 404 {object} ErrorResponse "Holiday not found"
+// @Failure 500 {object} ErrorResponse
+// @Router /api/holidays/{id} [delete]
+func (h *ForecastHandler) DeleteHoliday(c *fiber.Ctx) error {
+	// ดึง organization ID จาก context
+	organizationID := c.Locals("organization_id").(string)
+	if organizationID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "ไม่พบข้อมูลองค์กร",
+		})
+	}
+
+	if err := h.ForecastService.DeleteHoliday(c.Context(), c.Params("id"), organizationID); err != nil {
+		if err.Error() == "ไม่พบวันหยุดที่ต้องการลบ" {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "ลบวันหยุดสำเร็จ",
+	})
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+// JourneyHandler เป็นโครงสร้างสำหรับจัดการ API endpoints เกี่ยวกับ timeline การเข้าชมของบุคคล
+type JourneyHandler struct {
+	JourneyService *services.JourneyService
+}
+
+// NewJourneyHandler สร้าง JourneyHandler ใหม่
+func NewJourneyHandler(journeyService *services.JourneyService) *JourneyHandler {
+	return &JourneyHandler{
+		JourneyService: journeyService,
+	}
+}
+
+// GetJourney เป็น handler สำหรับดึง timeline การเข้าชมของบุคคล
+// @Summary Get person journey timeline
+// @Description Visits of a person grouped by day, newest first. Each visit lists the ordered camera sequence with dwell time per camera and a representative face thumbnail. Detections further apart than the configured visit gap start a new visit. Paginated by visit with an opaque cursor.
+// @Tags persons
+// @Produce json
+// @Param person_hash path string true "Person Hash"
+// @Param from query string false "Only detections from this time (format YYYY-MM-DDTHH:MM:SS)"
+// @Param to query string false "Only detections before this time (format YYYY-MM-DDTHH:MM:SS)"
+// @Param cursor query string false "Cursor from pagination.next_cursor of the previous page"
+// @Param limit query int false "Number of visits per page (max 100)" default(10)
+// @Security ApiKeyAuth
+// @Success 200 {object} models.PersonJourney
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse "Unauthorized (invalid or missing API key)"
+// @Failure 404 {object} ErrorResponse "Person not found"
+// @Failure 500 {object} ErrorResponse
+// @Router /api/persons/{person_hash}/journey [get]
+func (h *JourneyHandler) GetJourney(c *fiber.Ctx) error {
+	// ดึง person_hash จาก path parameters
+	personHash := c.Params("person_hash")
+	if personHash == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "ต้องระบุรหัสบุคคล",
+		})
+	}
+
+	// ดึง organization ID จาก context
+	organizationID := c.Locals("organization_id").(string)
+	if organizationID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "ไม่พบข้อมูลองค์กร",
+		})
+	}
+
+	var from, to time.Time
+	if fromStr := c.Query("from"); fromStr != "" {
+		var err error
+		from, err = time.Parse(time.RFC3339, fromStr)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "รูปแบบของพารามิเตอร์ from ไม่ถูกต้อง โปรดใช้รูปแบบ YYYY-MM-DDTHH:MM:SS",
+			})
+		}
+	}
+	if toStr := c.Query("to"); toStr != "" {
+		var err error
+		to, err = time.Parse(time.RFC3339, toStr)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "รูปแบบของพารามิเตอร์ to ไม่ถูกต้อง โปรดใช้รูปแบบ YYYY-MM-DDTHH:MM:SS",
+			})
+		}
+	}
+
+	limit, err := strconv.Atoi(c.Query("limit", "10"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "รูปแบบของพารามิเตอร์ limit ไม่ถูกต้อง",
+		})
+	}
+
+	journey, err := h.JourneyService.GetJourney(c.Context(), personHash, organizationID, from, to, c.Query("cursor"), limit)
+	if err != nil {
+		status := fiber.StatusInternalServerError
+		if err.Error() == "ไม่พบข้อมูลบุคคล" {
+			status = fiber.StatusNotFound
+		} else if errors.Is(err, services.ErrInvalidListOptions) {
+			status = fiber.StatusBadRequest
+		}
+		return c.Status(status).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(journey)
+}
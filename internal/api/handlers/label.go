@@ -0,0 +1,316 @@
+package handlers
+
+import (
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+// LabelHandler เป็นโครงสร้างสำหรับจัดการ API endpoints เกี่ยวกับป้ายกำกับและบันทึกของบุคคล
+type LabelHandler struct {
+	LabelService *services.LabelService
+}
+
+// NewLabelHandler สร้าง LabelHandler ใหม่
+func NewLabelHandler(labelService *services.LabelService) *LabelHandler {
+	return &LabelHandler{
+		LabelService: labelService,
+	}
+}
+
+// LabelRequest เป็นโครงสร้างข้อมูลสำหรับติดป้ายกำกับให้บุคคล
+type LabelRequest struct {
+	Label string `json:"label" example:"staff"`
+}
+
+// NoteRequest เป็นโครงสร้างข้อมูลสำหรับเพิ่มบันทึกของบุคคล
+type NoteRequest struct {
+	Body   string `json:"body" example:"Security guard, night shift"`
+	Author string `json:"author,omitempty" example:"somchai@example.com"`
+}
+
+// labelErrorStatus แปลงข้อผิดพลาดของป้ายกำกับและบันทึกเป็น HTTP status
+func labelErrorStatus(err error) int {
+	switch err.Error() {
+	case "ไม่พบข้อมูลบุคคล", "ไม่พบป้ายกำกับ", "ไม่พบบันทึก":
+		return fiber.StatusNotFound
+	case "ป้ายกำกับต้องประกอบด้วย a-z, 0-9, _ หรือ - และยาวไม่เกิน 50 ตัวอักษร",
+		"ต้องระบุข้อความของบันทึก",
+		"ข้อความของบันทึกยาวเกินไป":
+		return fiber.StatusBadRequest
+	}
+	return fiber.StatusInternalServerError
+}
+
+// personContext ดึง person_hash จาก path และ organization ID จาก context
+func personContext(c *fiber.Ctx) (string, string, error) {
+	personHash := c.Params("person_hash")
+	if personHash == "" {
+		return "", "", fiber.NewError(fiber.StatusBadRequest, "ต้องระบุรหัสบุคคล")
+	}
+
+	organizationID := c.Locals("organization_id").(string)
+	if organizationID == "" {
+		return "", "", fiber.NewError(fiber.StatusBadRequest, "ไม่พบข้อมูลองค์กร")
+	}
+
+	return personHash, organizationID, nil
+}
+
+// requestAuthor คืนผู้เรียก API สำหรับบันทึกเป็นผู้สร้างข้อมูล
+func requestAuthor(c *fiber.Ctx) string {
+	if apiKeyID, ok := c.Locals("api_key_id").(string); ok && apiKeyID != "" {
+		return "api_key:" + apiKeyID
+	}
+	if userID, ok := c.Locals("user_id").(string); ok && userID != "" {
+		return "user:" + userID
+	}
+	return ""
+}
+
+// GetLabelCounts เป็น handler สำหรับดึงจำนวนบุคคลในแต่ละป้ายกำกับ
+// @Summary Count persons per label
+// @Description Retrieve every label used in the organization with the number of persons carrying it
+// @Tags persons
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {array} models.LabelCount
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse "Unauthorized (invalid or missing API key)"
+// @Failure 500 {object} ErrorResponse
+// @Router /api/labels [get]
+func (h *LabelHandler) GetLabelCounts(c *fiber.Ctx) error {
+	// ดึง organization ID จาก context
+	organizationID := c.Locals("organization_id").(string)
+	if organizationID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "ไม่พบข้อมูลองค์กร",
+		})
+	}
+
+	counts, err := h.LabelService.CountLabels(c.Context(), organizationID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(counts)
+}
+
+// GetLabels เป็น handler สำหรับดึงป้ายกำกับของบุคคล
+// @Summary Get person labels
+// @Description Retrieve the labels of a person
+// @Tags persons
+// @Accept json
+// @Produce json
+// @Param person_hash path string true "Person Hash"
+// @Security ApiKeyAuth
+// @Success 200 {array} models.PersonLabel
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse "Unauthorized (invalid or missing API key)"
+// @Failure 404 {object} ErrorResponse "Person not found"
+// @Failure 500 {object} ErrorResponse
+// @Router /api/persons/{person_hash}/labels [get]
+func (h *LabelHandler) GetLabels(c *fiber.Ctx) error {
+	personHash, organizationID, err := personContext(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	labels, err := h.LabelService.ListLabels(c.Context(), personHash, organizationID)
+	if err != nil {
+		return c.Status(labelErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(labels)
+}
+
+// AddLabel เป็น handler สำหรับติดป้ายกำกับให้บุคคล
+// @Summary Add a label to a person
+// @Description Tag a person with a built-in label (staff, vip, blocked) or a custom label of a-z, 0-9, _ and -. Persons labelled as staff are excluded from visitor statistics, including past days. Adding a label the person already has returns the existing label.
+// @Tags persons
+// @Accept json
+// @Produce json
+// @Param person_hash path string true "Person Hash"
+// @Param label body LabelRequest true "Label"
+// @Security ApiKeyAuth
+// @Success 201 {object} models.PersonLabel
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse "Unauthorized (invalid or missing API key)"
+// @Failure 404 {object} ErrorResponse "Person not found"
+// @Failure 500 {object} ErrorResponse
+// @Router /api/persons/{person_hash}/labels [post]
+func (h *LabelHandler) AddLabel(c *fiber.Ctx) error {
+	personHash, organizationID, err := personContext(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	var req LabelRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "รูปแบบข้อมูลไม่ถูกต้อง",
+		})
+	}
+
+	label, err := h.LabelService.AddLabel(c.Context(), personHash, organizationID, req.Label, requestAuthor(c))
+	if err != nil {
+		return c.Status(labelErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(label)
+}
+
+// RemoveLabel เป็น handler สำหรับถอดป้ายกำกับออกจากบุคคล
+// @Summary Remove a label from a person
+// @Description Remove a label from a person. Removing the staff label counts the person in visitor statistics again, including past days.
+// @Tags persons
+// @Accept json
+// @Produce json
+// @Param person_hash path string true "Person Hash"
+// @Param label path string true "Label"
+// @Security ApiKeyAuth
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse "Unauthorized (invalid or missing API key)"
+// @Failure 404 {object} ErrorResponse "Label not found"
+// @Failure 500 {object} ErrorResponse
+// @Router /api/persons/{person_hash}/labels/{label} [delete]
+func (h *LabelHandler) RemoveLabel(c *fiber.Ctx) error {
+	personHash, organizationID, err := personContext(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	if err := h.LabelService.RemoveLabel(c.Context(), personHash, organizationID, c.Params("label")); err != nil {
+		return c.Status(labelErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "ลบป้ายกำกับสำเร็จ",
+	})
+}
+
+// GetNotes เป็น handler สำหรับดึงบันทึกของบุคคล
+// @Summary Get person notes
+// @Description Retrieve the notes of a person, newest first
+// @Tags persons
+// @Accept json
+// @Produce json
+// @Param person_hash path string true "Person Hash"
+// @Security ApiKeyAuth
+// @Success 200 {array} models.PersonNote
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse "Unauthorized (invalid or missing API key)"
+// @Failure 404 {object} ErrorResponse "Person not found"
+// @Failure 500 {object} ErrorResponse
+// @Router /api/persons/{person_hash}/notes [get]
+func (h *LabelHandler) GetNotes(c *fiber.Ctx) error {
+	personHash, organizationID, err := personContext(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	notes, err := h.LabelService.ListNotes(c.Context(), personHash, organizationID)
+	if err != nil {
+		return c.Status(labelErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(notes)
+}
+
+// AddNote เป็น handler สำหรับเพิ่มบันทึกของบุคคล
+// @Summary Add a note to a person
+// @Description Add a free-text note to a person. The author defaults to the API key that made the request.
+// @Tags persons
+// @Accept json
+// @Produce json
+// @Param person_hash path string true "Person Hash"
+// @Param note body NoteRequest true "Note"
+// @Security ApiKeyAuth
+// @Success 201 {object} models.PersonNote
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse "Unauthorized (invalid or missing API key)"
+// @Failure 404 {object} ErrorResponse "Person not found"
+// @Failure 500 {object} ErrorResponse
+// @Router /api/persons/{person_hash}/notes [post]
+func (h *LabelHandler) AddNote(c *fiber.Ctx) error {
+	personHash, organizationID, err := personContext(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	var req NoteRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "รูปแบบข้อมูลไม่ถูกต้อง",
+		})
+	}
+	if req.Author == "" {
+		req.Author = requestAuthor(c)
+	}
+
+	note, err := h.LabelService.AddNote(c.Context(), personHash, organizationID, req.Body, req.Author)
+	if err != nil {
+		return c.Status(labelErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(note)
+}
+
+// DeleteNote เป็น handler สำหรับลบบันทึกของบุคคล
+// @Summary Delete a person note
+// @Description Delete a note of a person by ID
+// @Tags persons
+// @Accept json
+// @Produce json
+// @Param person_hash path string true "Person Hash"
+// @Param note_id path string true "Note ID"
+// @Security ApiKeyAuth
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse "Unauthorized (invalid or missing API key)"
+// @Failure 404 {object} ErrorResponse "Note not found"
+// @Failure 500 {object} ErrorResponse
+// @Router /api/persons/{person_hash}/notes/{note_id} [delete]
+func (h *LabelHandler) DeleteNote(c *fiber.Ctx) error {
+	personHash, organizationID, err := personContext(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	if err := h.LabelService.DeleteNote(c.Context(), personHash, organizationID, c.Params("note_id")); err != nil {
+		return c.Status(labelErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "ลบบันทึกสำเร็จ",
+	})
+}
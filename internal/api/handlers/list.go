@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/models"
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+// queryValues ดึงค่าหลายค่าของ query parameter ทั้งแบบ name=a,b และแบบ name[]=a&name[]=b
+func queryValues(c *fiber.Ctx, name string) []string {
+	var values []string
+	args := c.Context().QueryArgs()
+	for _, key := range []string{name, name + "[]"} {
+		for _, value := range args.PeekMulti(key) {
+			values = append(values, splitQuery(string(value))...)
+		}
+	}
+	return values
+}
+
+// parseListOptions แปลง query parameters ของการแบ่งหน้าแบบ cursor การเรียงลำดับ และการเลือกฟิลด์
+// page ของการแบ่งหน้าแบบเดิมถูกปฏิเสธ เพราะถ้าไม่สนใจ client ที่ยังเพิ่ม page จะได้หน้าแรกซ้ำไม่รู้จบ
+func parseListOptions(c *fiber.Ctx) (models.ListOptions, error) {
+	if c.Query("page") != "" {
+		return models.ListOptions{}, fmt.Errorf("ไม่รองรับพารามิเตอร์ page แล้ว โปรดใช้ cursor จาก pagination.next_cursor ของหน้าก่อนหน้า")
+	}
+
+	opts := models.ListOptions{
+		Cursor: c.Query("cursor"),
+		Sort:   c.Query("sort"),
+		Order:  c.Query("order"),
+		Fields: queryValues(c, "fields"),
+	}
+
+	// รองรับ page_size เดิมเป็นชื่อเรียกอื่นของ limit
+	limitStr := c.Query("limit", c.Query("page_size", "10"))
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil {
+		return opts, fmt.Errorf("รูปแบบของพารามิเตอร์ limit ไม่ถูกต้อง")
+	}
+	if limit <= 0 {
+		limit = 10
+	}
+	if limit > 100 {
+		limit = 100
+	}
+	opts.Limit = limit
+
+	if includeTotal := c.Query("include_total"); includeTotal != "" {
+		opts.IncludeTotal, err = strconv.ParseBool(includeTotal)
+		if err != nil {
+			return opts, fmt.Errorf("รูปแบบของพารามิเตอร์ include_total ไม่ถูกต้อง")
+		}
+	}
+
+	return opts, nil
+}
+
+// projectFields ตัดข้อมูลแต่ละรายการให้เหลือเฉพาะฟิลด์ที่ผู้เรียกเลือก
+func projectFields[T any](items []T, fields []string) ([]map[string]interface{}, error) {
+	projected := make([]map[string]interface{}, 0, len(items))
+	for _, item := range items {
+		encoded, err := json.Marshal(item)
+		if err != nil {
+			return nil, err
+		}
+		var full map[string]json.RawMessage
+		if err := json.Unmarshal(encoded, &full); err != nil {
+			return nil, err
+		}
+
+		row := make(map[string]interface{}, len(fields))
+		for _, field := range fields {
+			if value, ok := full[field]; ok {
+				row[field] = value
+			} else {
+				// ฟิลด์ที่มี omitempty และไม่มีค่า
+				row[field] = nil
+			}
+		}
+		projected = append(projected, row)
+	}
+	return projected, nil
+}
+
+// projectedResponse ส่งรายการที่ตัดให้เหลือเฉพาะฟิลด์ที่ผู้เรียกเลือก พร้อมกับข้อมูล pagination
+func projectedResponse[T any](c *fiber.Ctx, items []T, pagination *models.CursorPagination, fields []string) error {
+	projected, err := projectFields(items, fields)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	return c.JSON(fiber.Map{
+		"data":       projected,
+		"pagination": pagination,
+	})
+}
+
+// listErrorStatus แปลงข้อผิดพลาดของการดึงรายการเป็น HTTP status
+func listErrorStatus(err error) int {
+	if errors.Is(err, services.ErrInvalidListOptions) {
+		return fiber.StatusBadRequest
+	}
+	return fiber.StatusInternalServerError
+}
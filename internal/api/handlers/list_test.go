@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/models"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestParseListOptions ทดสอบการแปลง query parameters ของการแบ่งหน้า และการปฏิเสธ page ของการแบ่งหน้าแบบเดิม
+func TestParseListOptions(t *testing.T) {
+	var opts models.ListOptions
+	app := fiber.New()
+	app.Get("/list", func(c *fiber.Ctx) error {
+		var err error
+		opts, err = parseListOptions(c)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.SendStatus(fiber.StatusOK)
+	})
+
+	status := func(target string) int {
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, target, nil))
+		require.NoError(t, err)
+		return resp.StatusCode
+	}
+
+	require.Equal(t, http.StatusOK, status("/list?cursor=abc&page_size=500&include_total=true"))
+	assert.Equal(t, "abc", opts.Cursor)
+	assert.Equal(t, 100, opts.Limit)
+	assert.True(t, opts.IncludeTotal)
+
+	// page ถูกปฏิเสธแทนการคืนหน้าแรกซ้ำ
+	assert.Equal(t, http.StatusBadRequest, status("/list?page=2"))
+	assert.Equal(t, http.StatusBadRequest, status("/list?page=1&limit=10"))
+	assert.Equal(t, http.StatusBadRequest, status("/list?limit=abc"))
+}
@@ -29,16 +29,21 @@ func NewLogsHandler(statsService *services.StatsService) *LogsHandler {
 
 // GetLogs เป็น handler สำหรับดึงข้อมูล logs
 // @Summary Retrieve logs data with filtering
-// @Description Retrieve person detection logs based on specified filters
+// @Description Retrieve person detection logs based on specified filters. Results are paginated with an opaque cursor on (timestamp, id): pass pagination.next_cursor as cursor to get the next page. The page parameter of the former offset pagination is rejected with 400, and pagination is {limit, next_cursor, has_more, total} instead of {total, page, page_size, total_page}.
 // @Tags logs
 // @Accept json
 // @Produce json
 // @Param from query string false "Start time for log retrieval (format YYYY-MM-DDTHH:MM:SS)"
 // @Param to query string false "End time for log retrieval (format YYYY-MM-DDTHH:MM:SS)"
-// @Param camera_id query string false "Camera ID to filter logs by"
+// @Param camera_id query []string false "Camera IDs to filter logs by (camera_id[]=a&camera_id[]=b or comma-separated)" collectionFormat(multi)
+// @Param zone_id query []string false "Camera zones to filter logs by" collectionFormat(multi)
+// @Param is_new_person query bool false "Only new (true) or returning (false) detections"
 // @Param person_id query string false "Person ID to filter logs by"
-// @Param page query int false "Page number to retrieve (starting from 1)" default(1)
-// @Param page_size query int false "Number of items per page (max 100)" default(10)
+// @Param cursor query string false "Cursor from pagination.next_cursor of the previous page"
+// @Param limit query int false "Number of items per page (max 100)" default(10)
+// @Param order query string false "Sort by timestamp ascending or descending (asc, desc)" default(desc)
+// @Param fields query string false "Comma-separated fields to return (e.g. id,timestamp,camera_id)"
+// @Param include_total query bool false "Also count the total number of matching logs (slower)"
 // @Security ApiKeyAuth
 // @Success 200 {object} LogsResponse
 // @Failure 400 {object} ErrorResponse
@@ -65,14 +70,26 @@ func (h *LogsHandler) GetLogs(c *fiber.Ctx) error {
 	// กำหนดองค์กรให้กับ filter
 	filter.OrganizationID = organizationID
 
+	// ดึงค่าการแบ่งหน้า การเรียงลำดับ และฟิลด์ที่เลือก
+	opts, err := parseListOptions(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
 	// ดึงข้อมูล logs
-	logs, pagination, err := h.StatsService.GetLogs(c.Context(), filter)
+	logs, pagination, err := h.StatsService.GetLogs(c.Context(), filter, opts)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		return c.Status(listErrorStatus(err)).JSON(fiber.Map{
 			"error": err.Error(),
 		})
 	}
 
+	if len(opts.Fields) > 0 {
+		return projectedResponse(c, logs, pagination, opts.Fields)
+	}
+
 	// สร้าง response
 	response := LogsResponse{
 		Data:       logs,
@@ -84,8 +101,8 @@ func (h *LogsHandler) GetLogs(c *fiber.Ctx) error {
 
 // LogsResponse เป็นโครงสร้างสำหรับส่งข้อมูล logs พร้อมกับข้อมูล pagination
 type LogsResponse struct {
-	Data       []models.PersonLog   `json:"data"`
-	Pagination *models.Pagination `json:"pagination"`
+	Data       []models.PersonLog       `json:"data"`
+	Pagination *models.CursorPagination `json:"pagination"`
 }
 
 // parseLogsFilter แปลง query parameters เป็น LogFilter
@@ -112,39 +129,27 @@ func parseLogsFilter(c *fiber.Ctx) (models.LogFilter, error) {
 		filter.To = to
 	}
 
-	// ดึงค่า camera_id
-	filter.CameraID = c.Query("camera_id")
-
 	// ดึงค่า person_id
 	filter.PersonID = c.Query("person_id")
 
-	// แปลงค่า page ถ้ามี
-	pageStr := c.Query("page", "1")
-	if pageStr != "" {
-		var err error
-		filter.Page, err = strconv.Atoi(pageStr)
-		if err != nil {
-			return filter, fmt.Errorf("รูปแบบของพารามิเตอร์ page ไม่ถูกต้อง")
-		}
-		if filter.Page <= 0 {
-			filter.Page = 1
-		}
+	// ดึงค่า camera_id หลายค่า (camera_id[]=a&camera_id[]=b หรือ camera_id=a,b)
+	cameraIDs := queryValues(c, "camera_id")
+	if len(cameraIDs) == 1 {
+		filter.CameraID = cameraIDs[0]
+	} else {
+		filter.CameraIDs = cameraIDs
 	}
 
-	// แปลงค่า page_size ถ้ามี
-	pageSizeStr := c.Query("page_size", "10")
-	if pageSizeStr != "" {
-		var err error
-		filter.PageSize, err = strconv.Atoi(pageSizeStr)
+	// ดึงค่า zone_id หลายค่า
+	filter.Zones = queryValues(c, "zone_id")
+
+	// แปลงค่า is_new_person ถ้ามี
+	if isNewPersonStr := c.Query("is_new_person"); isNewPersonStr != "" {
+		isNewPerson, err := strconv.ParseBool(isNewPersonStr)
 		if err != nil {
-			return filter, fmt.Errorf("รูปแบบของพารามิเตอร์ page_size ไม่ถูกต้อง")
-		}
-		if filter.PageSize <= 0 {
-			filter.PageSize = 10
-		}
-		if filter.PageSize > 100 {
-			filter.PageSize = 100
+			return filter, fmt.Errorf("รูปแบบของพารามิเตอร์ is_new_person ไม่ถูกต้อง")
 		}
+		filter.IsNewPerson = &isNewPerson
 	}
 
 	return filter, nil
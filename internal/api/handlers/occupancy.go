@@ -0,0 +1,374 @@
+package handlers
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/models"
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+// OccupancyHandler เป็นโครงสร้างสำหรับจัดการ API endpoints เกี่ยวกับจำนวนคนที่อยู่ในสาขา
+type OccupancyHandler struct {
+	OccupancyService *services.OccupancyService
+}
+
+// NewOccupancyHandler สร้าง OccupancyHandler ใหม่
+func NewOccupancyHandler(occupancyService *services.OccupancyService) *OccupancyHandler {
+	return &OccupancyHandler{
+		OccupancyService: occupancyService,
+	}
+}
+
+// occupancyErrorStatus แปลงข้อผิดพลาดจาก service เป็น HTTP status
+func occupancyErrorStatus(err error) int {
+	switch err.Error() {
+	case "ไม่พบสาขา", "ไม่พบเกณฑ์ความจุที่ต้องการลบ":
+		return fiber.StatusNotFound
+	case "จำนวนคนของเกณฑ์ต้องมากกว่า 0":
+		return fiber.StatusBadRequest
+	}
+	return fiber.StatusInternalServerError
+}
+
+// parseOccupancyRange แปลงช่วงเวลาจาก query parameters ค่าเริ่มต้นคือ 24 ชั่วโมงล่าสุด
+func parseOccupancyRange(c *fiber.Ctx) (time.Time, time.Time, error) {
+	to := time.Now()
+	from := to.Add(-24 * time.Hour)
+
+	if fromStr := c.Query("from"); fromStr != "" {
+		parsed, err := time.Parse(time.RFC3339, fromStr)
+		if err != nil {
+			return from, to, fiber.NewError(fiber.StatusBadRequest, "รูปแบบของพารามิเตอร์ from ไม่ถูกต้อง โปรดใช้รูปแบบ YYYY-MM-DDTHH:MM:SSZ")
+		}
+		from = parsed
+	}
+	if toStr := c.Query("to"); toStr != "" {
+		parsed, err := time.Parse(time.RFC3339, toStr)
+		if err != nil {
+			return from, to, fiber.NewError(fiber.StatusBadRequest, "รูปแบบของพารามิเตอร์ to ไม่ถูกต้อง โปรดใช้รูปแบบ YYYY-MM-DDTHH:MM:SSZ")
+		}
+		to = parsed
+	}
+	if !to.After(from) {
+		return from, to, fiber.NewError(fiber.StatusBadRequest, "to ต้องมากกว่า from")
+	}
+	if to.Sub(from) > 92*24*time.Hour {
+		return from, to, fiber.NewError(fiber.StatusBadRequest, "ช่วงเวลาต้องไม่เกิน 92 วัน")
+	}
+
+	return from, to, nil
+}
+
+// GetCurrent เป็น handler สำหรับดึงจำนวนคนที่อยู่ในสาขา ณ ขณะนี้
+// @Summary Get live site occupancy
+// @Description Estimate how many people are inside a site right now from entrance/exit detections, corrected by the occupancy timeout
+// @Tags occupancy
+// @Accept json
+// @Produce json
+// @Param id path string true "Site ID"
+// @Security ApiKeyAuth
+// @Success 200 {object} models.OccupancyStatus
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse "Unauthorized (invalid or missing API key)"
+// @Failure 404 {object} ErrorResponse "Site not found"
+// @Failure 500 {object} ErrorResponse
+// @Router /api/sites/{id}/occupancy [get]
+func (h *OccupancyHandler) GetCurrent(c *fiber.Ctx) error {
+	// ดึง organization ID จาก context
+	organizationID := c.Locals("organization_id").(string)
+	if organizationID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "ไม่พบข้อมูลองค์กร",
+		})
+	}
+
+	status, err := h.OccupancyService.GetCurrent(c.Context(), organizationID, c.Params("id"))
+	if err != nil {
+		return c.Status(occupancyErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(status)
+}
+
+// GetTimeSeries เป็น handler สำหรับดึงจำนวนคนในสาขาตามช่วงเวลา
+// @Summary Get site occupancy over time
+// @Description Retrieve the estimated number of people inside a site sampled at a fixed interval
+// @Tags occupancy
+// @Accept json
+// @Produce json
+// @Param id path string true "Site ID"
+// @Param from query string false "Start time (format YYYY-MM-DDTHH:MM:SSZ), defaults to 24 hours ago"
+// @Param to query string false "End time (format YYYY-MM-DDTHH:MM:SSZ), defaults to now"
+// @Param interval query string false "Sampling interval (e.g. 5m, 15m, 1h)" default(15m)
+// @Security ApiKeyAuth
+// @Success 200 {array} models.OccupancyPoint
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse "Unauthorized (invalid or missing API key)"
+// @Failure 404 {object} ErrorResponse "Site not found"
+// @Failure 500 {object} ErrorResponse
+// @Router /api/sites/{id}/occupancy/series [get]
+func (h *OccupancyHandler) GetTimeSeries(c *fiber.Ctx) error {
+	// ดึง organization ID จาก context
+	organizationID := c.Locals("organization_id").(string)
+	if organizationID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "ไม่พบข้อมูลองค์กร",
+		})
+	}
+
+	from, to, err := parseOccupancyRange(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	interval, err := time.ParseDuration(c.Query("interval", "15m"))
+	if err != nil || interval < time.Minute {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "interval ต้องเป็นระยะเวลาอย่างน้อย 1m เช่น 5m, 15m หรือ 1h",
+		})
+	}
+	if to.Sub(from)/interval > 5000 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "จำนวนจุดข้อมูลมากเกินไป โปรดเพิ่ม interval หรือลดช่วงเวลา",
+		})
+	}
+
+	points, err := h.OccupancyService.GetTimeSeries(c.Context(), organizationID, c.Params("id"), from, to, interval)
+	if err != nil {
+		return c.Status(occupancyErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(points)
+}
+
+// GetPeaks เป็น handler สำหรับดึงจำนวนคนสูงสุดในสาขาของแต่ละวัน
+// @Summary Get peak site occupancy
+// @Description Retrieve the highest number of people inside a site for each day, when it happened and the number of entries
+// @Tags occupancy
+// @Accept json
+// @Produce json
+// @Param id path string true "Site ID"
+// @Param from query string false "Start time (format YYYY-MM-DDTHH:MM:SSZ), defaults to 24 hours ago"
+// @Param to query string false "End time (format YYYY-MM-DDTHH:MM:SSZ), defaults to now"
+// @Security ApiKeyAuth
+// @Success 200 {array} models.OccupancyPeak
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse "Unauthorized (invalid or missing API key)"
+// @Failure 404 {object} ErrorResponse "Site not found"
+// @Failure 500 {object} ErrorResponse
+// @Router /api/sites/{id}/occupancy/peaks [get]
+func (h *OccupancyHandler) GetPeaks(c *fiber.Ctx) error {
+	// ดึง organization ID จาก context
+	organizationID := c.Locals("organization_id").(string)
+	if organizationID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "ไม่พบข้อมูลองค์กร",
+		})
+	}
+
+	from, to, err := parseOccupancyRange(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	peaks, err := h.OccupancyService.GetPeaks(c.Context(), organizationID, c.Params("id"), from, to)
+	if err != nil {
+		return c.Status(occupancyErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(peaks)
+}
+
+// ListEvents เป็น handler สำหรับดึงเหตุการณ์ที่จำนวนคนข้ามเกณฑ์ความจุ
+// @Summary List occupancy threshold events
+// @Description Retrieve the events raised when the site occupancy crossed one of its capacity thresholds
+// @Tags occupancy
+// @Accept json
+// @Produce json
+// @Param id path string true "Site ID"
+// @Param page query int false "Page number (starting from 1)" default(1)
+// @Param page_size query int false "Items per page (max 100)" default(10)
+// @Security ApiKeyAuth
+// @Success 200 {object} OccupancyEventsResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse "Unauthorized (invalid or missing API key)"
+// @Failure 404 {object} ErrorResponse "Site not found"
+// @Failure 500 {object} ErrorResponse
+// @Router /api/sites/{id}/occupancy/events [get]
+func (h *OccupancyHandler) ListEvents(c *fiber.Ctx) error {
+	// ดึง organization ID จาก context
+	organizationID := c.Locals("organization_id").(string)
+	if organizationID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "ไม่พบข้อมูลองค์กร",
+		})
+	}
+
+	// ดึงค่า pagination จาก query parameters
+	page, err := strconv.Atoi(c.Query("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	pageSize, err := strconv.Atoi(c.Query("page_size", "10"))
+	if err != nil || pageSize < 1 {
+		pageSize = 10
+	}
+	if pageSize > 100 {
+		pageSize = 100
+	}
+
+	events, pagination, err := h.OccupancyService.ListEvents(c.Context(), organizationID, c.Params("id"), page, pageSize)
+	if err != nil {
+		return c.Status(occupancyErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(OccupancyEventsResponse{
+		Data:       events,
+		Pagination: pagination,
+	})
+}
+
+// ListThresholds เป็น handler สำหรับดึงเกณฑ์ความจุของสาขา
+// @Summary List site capacity thresholds
+// @Description Retrieve the capacity thresholds of a site and whether each one is currently exceeded
+// @Tags occupancy
+// @Accept json
+// @Produce json
+// @Param id path string true "Site ID"
+// @Security ApiKeyAuth
+// @Success 200 {array} models.OccupancyThreshold
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse "Unauthorized (invalid or missing API key)"
+// @Failure 500 {object} ErrorResponse
+// @Router /api/sites/{id}/thresholds [get]
+func (h *OccupancyHandler) ListThresholds(c *fiber.Ctx) error {
+	// ดึง organization ID จาก context
+	organizationID := c.Locals("organization_id").(string)
+	if organizationID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "ไม่พบข้อมูลองค์กร",
+		})
+	}
+
+	thresholds, err := h.OccupancyService.ListThresholds(c.Context(), organizationID, c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(thresholds)
+}
+
+// CreateThreshold เป็น handler สำหรับเพิ่มเกณฑ์ความจุของสาขา
+// @Summary Add a site capacity threshold
+// @Description Add a capacity threshold; an event is raised when the occupancy reaches it and when it drops back below
+// @Tags occupancy
+// @Accept json
+// @Produce json
+// @Param id path string true "Site ID"
+// @Param threshold body models.OccupancyThreshold true "Threshold details (name, occupancy, severity)"
+// @Security ApiKeyAuth
+// @Success 201 {object} models.OccupancyThreshold
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse "Unauthorized (invalid or missing API key)"
+// @Failure 404 {object} ErrorResponse "Site not found"
+// @Failure 500 {object} ErrorResponse
+// @Router /api/sites/{id}/thresholds [post]
+func (h *OccupancyHandler) CreateThreshold(c *fiber.Ctx) error {
+	// ดึง organization ID จาก context
+	organizationID := c.Locals("organization_id").(string)
+	if organizationID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "ไม่พบข้อมูลองค์กร",
+		})
+	}
+
+	var threshold models.OccupancyThreshold
+	if err := c.BodyParser(&threshold); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "รูปแบบข้อมูลไม่ถูกต้อง",
+		})
+	}
+	if threshold.Name == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "ต้องระบุชื่อเกณฑ์",
+		})
+	}
+	switch threshold.Severity {
+	case "", models.SeverityLow, models.SeverityMedium, models.SeverityHigh, models.SeverityCritical:
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "severity ต้องเป็น low, medium, high หรือ critical",
+		})
+	}
+
+	threshold.ID = ""
+	threshold.OrganizationID = organizationID
+	threshold.SiteID = c.Params("id")
+
+	if err := h.OccupancyService.CreateThreshold(c.Context(), &threshold); err != nil {
+		return c.Status(occupancyErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(threshold)
+}
+
+// DeleteThreshold เป็น handler สำหรับลบเกณฑ์ความจุของสาขา
+// @Summary Delete a site capacity threshold
+// @Description Delete a capacity threshold by ID
+// @Tags occupancy
+// @Accept json
+// @Produce json
+// @Param id path string true "Site ID"
+// @Param threshold_id path string true "Threshold ID"
+// @Security ApiKeyAuth
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse "Unauthorized (invalid or missing API key)"
+// @Failure 404 {object} ErrorResponse "Threshold not found"
+// @Failure 500 {object} ErrorResponse
+// @Router /api/sites/{id}/thresholds/{threshold_id} [delete]
+func (h *OccupancyHandler) DeleteThreshold(c *fiber.Ctx) error {
+	// ดึง organization ID จาก context
+	organizationID := c.Locals("organization_id").(string)
+	if organizationID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "ไม่พบข้อมูลองค์กร",
+		})
+	}
+
+	if err := h.OccupancyService.DeleteThreshold(c.Context(), organizationID, c.Params("id"), c.Params("threshold_id")); err != nil {
+		return c.Status(occupancyErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "ลบเกณฑ์ความจุสำเร็จ",
+	})
+}
+
+// OccupancyEventsResponse เป็นโครงสร้างสำหรับส่งรายการเหตุการณ์ความจุพร้อมกับข้อมูล pagination
+type OccupancyEventsResponse struct {
+	Data       []models.OccupancyEvent `json:"data"`
+	Pagination *models.Pagination      `json:"pagination"`
+}
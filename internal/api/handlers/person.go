@@ -4,7 +4,6 @@ import (
 	"github.com/bemindtech/bmt-manta-dashboard-service/internal/models"
 	"github.com/bemindtech/bmt-manta-dashboard-service/internal/services"
 	"github.com/gofiber/fiber/v2"
-	"strconv"
 )
 
 // PersonHandler เป็นโครงสร้างสำหรับจัดการ API endpoints เกี่ยวกับข้อมูลบุคคล
@@ -21,7 +20,7 @@ func NewPersonHandler(personService *services.PersonService) *PersonHandler {
 
 // GetPerson เป็น handler สำหรับดึงข้อมูลบุคคลตาม PersonHash
 // @Summary Get person by person hash
-// @Description Retrieve a person by their person_hash
+// @Description Retrieve a person by their person_hash, with all face images and the primary_face_image shown for the person
 // @Tags persons
 // @Accept json
 // @Produce json
@@ -63,16 +62,33 @@ func (h *PersonHandler) GetPerson(c *fiber.Ctx) error {
 
 // ListPersons เป็น handler สำหรับดึงรายการบุคคลทั้งหมด
 // @Summary List all persons
-// @Description Retrieve a list of all persons with pagination
+// @Description Retrieve a list of persons with cursor pagination, optionally only persons matching every given filter. Each person includes its primary_face_image, which is also the only item of face_images. The page parameter of the former offset pagination is rejected with 400, and pagination is {limit, next_cursor, has_more, total} instead of {total, page, page_size, total_page}.
 // @Tags persons
 // @Accept json
 // @Produce json
-// @Param page query int false "Page number to retrieve (starting from 1)" default(1)
-// @Param page_size query int false "Number of items per page (max 100)" default(10)
+// @Param first_seen_from query string false "Only persons first seen at or after this time (YYYY-MM-DD or RFC3339)"
+// @Param first_seen_to query string false "Only persons first seen before this time (YYYY-MM-DD or RFC3339)"
+// @Param last_seen_from query string false "Only persons last seen at or after this time (YYYY-MM-DD or RFC3339)"
+// @Param last_seen_to query string false "Only persons last seen before this time (YYYY-MM-DD or RFC3339)"
+// @Param min_visits query int false "Only persons with at least this many visits"
+// @Param max_visits query int false "Only persons with at most this many visits"
+// @Param camera_id query []string false "Only persons ever seen at any of these cameras" collectionFormat(multi)
+// @Param zone query []string false "Only persons ever seen in any of these camera zones" collectionFormat(multi)
+// @Param label query []string false "Only persons with any of these labels (e.g. staff, vip, blocked or a custom label)" collectionFormat(multi)
+// @Param has_face_images query bool false "Only persons with (true) or without (false) face images"
+// @Param new_within_days query int false "Only persons first seen within the last N days"
+// @Param saved_search query string false "Also apply the filter of this saved search"
+// @Param cursor query string false "Cursor from pagination.next_cursor of the previous page"
+// @Param limit query int false "Number of items per page (max 100)" default(10)
+// @Param sort query string false "Sort field (last_seen, first_seen, visit_count, created_at)" default(last_seen)
+// @Param order query string false "Sort order (asc, desc)" default(desc)
+// @Param fields query string false "Comma-separated fields to return"
+// @Param include_total query bool false "Also count the total number of items (slower)"
 // @Security ApiKeyAuth
 // @Success 200 {object} PersonsResponse
 // @Failure 400 {object} ErrorResponse
 // @Failure 401 {object} ErrorResponse "Unauthorized (invalid or missing API key)"
+// @Failure 404 {object} ErrorResponse "Saved search not found"
 // @Failure 500 {object} ErrorResponse
 // @Router /api/persons [get]
 func (h *PersonHandler) ListPersons(c *fiber.Ctx) error {
@@ -84,49 +100,34 @@ func (h *PersonHandler) ListPersons(c *fiber.Ctx) error {
 		})
 	}
 
-	// แปลงค่า page ถ้ามี
-	page := 1
-	pageStr := c.Query("page", "1")
-	if pageStr != "" {
-		var err error
-		page, err = strconv.Atoi(pageStr)
-		if err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-				"error": "รูปแบบของพารามิเตอร์ page ไม่ถูกต้อง",
-			})
-		}
-		if page <= 0 {
-			page = 1
-		}
-	}
-
-	// แปลงค่า page_size ถ้ามี
-	pageSize := 10
-	pageSizeStr := c.Query("page_size", "10")
-	if pageSizeStr != "" {
-		var err error
-		pageSize, err = strconv.Atoi(pageSizeStr)
-		if err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-				"error": "รูปแบบของพารามิเตอร์ page_size ไม่ถูกต้อง",
-			})
-		}
-		if pageSize <= 0 {
-			pageSize = 10
-		}
-		if pageSize > 100 {
-			pageSize = 100
-		}
+	// ดึงค่าการแบ่งหน้า การเรียงลำดับ และฟิลด์ที่เลือก
+	opts, err := parseListOptions(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	// ดึงตัวกรองของการค้นหา
+	search, err := parsePersonSearch(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
 	}
 
 	// ดึงรายการบุคคล
-	persons, pagination, err := h.PersonService.ListPersons(c.Context(), organizationID, page, pageSize)
+	persons, pagination, err := h.PersonService.ListPersons(c.Context(), organizationID, search, opts)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		return c.Status(searchErrorStatus(err)).JSON(fiber.Map{
 			"error": err.Error(),
 		})
 	}
 
+	if len(opts.Fields) > 0 {
+		return projectedResponse(c, persons, pagination, opts.Fields)
+	}
+
 	// สร้าง response
 	response := PersonsResponse{
 		Data:       persons,
@@ -136,6 +137,57 @@ func (h *PersonHandler) ListPersons(c *fiber.Ctx) error {
 	return c.JSON(response)
 }
 
+// GetPersonFacets เป็น handler สำหรับนับจำนวนบุคคลที่ตรงกับการค้นหาแยกตามป้ายกำกับ กล้อง โซน และการเข้าชม
+// @Summary Count matching persons per facet
+// @Description Retrieve the number of persons matching the filters, with counts per label, camera and zone, with and without face images, and histograms of visit counts and days since first seen
+// @Tags persons
+// @Accept json
+// @Produce json
+// @Param first_seen_from query string false "Only persons first seen at or after this time (YYYY-MM-DD or RFC3339)"
+// @Param first_seen_to query string false "Only persons first seen before this time (YYYY-MM-DD or RFC3339)"
+// @Param last_seen_from query string false "Only persons last seen at or after this time (YYYY-MM-DD or RFC3339)"
+// @Param last_seen_to query string false "Only persons last seen before this time (YYYY-MM-DD or RFC3339)"
+// @Param min_visits query int false "Only persons with at least this many visits"
+// @Param max_visits query int false "Only persons with at most this many visits"
+// @Param camera_id query []string false "Only persons ever seen at any of these cameras" collectionFormat(multi)
+// @Param zone query []string false "Only persons ever seen in any of these camera zones" collectionFormat(multi)
+// @Param label query []string false "Only persons with any of these labels (e.g. staff, vip, blocked or a custom label)" collectionFormat(multi)
+// @Param has_face_images query bool false "Only persons with (true) or without (false) face images"
+// @Param new_within_days query int false "Only persons first seen within the last N days"
+// @Param saved_search query string false "Also apply the filter of this saved search"
+// @Security ApiKeyAuth
+// @Success 200 {object} models.PersonFacets
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse "Unauthorized (invalid or missing API key)"
+// @Failure 404 {object} ErrorResponse "Saved search not found"
+// @Failure 500 {object} ErrorResponse
+// @Router /api/persons/facets [get]
+func (h *PersonHandler) GetPersonFacets(c *fiber.Ctx) error {
+	// ดึง organization ID จาก context
+	organizationID := c.Locals("organization_id").(string)
+	if organizationID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "ไม่พบข้อมูลองค์กร",
+		})
+	}
+
+	search, err := parsePersonSearch(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	facets, err := h.PersonService.GetFacets(c.Context(), organizationID, search)
+	if err != nil {
+		return c.Status(searchErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(facets)
+}
+
 // GetPersonStats เป็น handler สำหรับดึงข้อมูลสถิติของบุคคล
 // @Summary Get person statistics
 // @Description Retrieve statistics for a person
@@ -221,8 +273,89 @@ func (h *PersonHandler) DeletePerson(c *fiber.Ctx) error {
 	})
 }
 
+// SetPrimaryFaceImageRequest เป็นโครงสร้างข้อมูลสำหรับกำหนดรูปภาพหลักของบุคคล
+type SetPrimaryFaceImageRequest struct {
+	FaceImageID string `json:"face_image_id" example:"0b6f3c1e-..."`
+}
+
+// SetPrimaryFaceImage เป็น handler สำหรับกำหนดรูปภาพหลักของบุคคลเอง
+// @Summary Pin the primary face image of a person
+// @Description Show the given face image of the person as its primary_face_image in person list and detail responses instead of the image with the best quality_score. The pin is kept until it is removed, or until the image is deleted or moved to another person by a split or merge.
+// @Tags persons
+// @Accept json
+// @Produce json
+// @Param person_hash path string true "Person Hash"
+// @Param primary body SetPrimaryFaceImageRequest true "Face image to pin"
+// @Security ApiKeyAuth
+// @Success 200 {object} models.Person
+// @Failure 400 {object} ErrorResponse "Face image is not an image of the person"
+// @Failure 401 {object} ErrorResponse "Unauthorized (invalid or missing API key)"
+// @Failure 404 {object} ErrorResponse "Person not found"
+// @Failure 500 {object} ErrorResponse
+// @Router /api/persons/{person_hash}/primary-face-image [put]
+func (h *PersonHandler) SetPrimaryFaceImage(c *fiber.Ctx) error {
+	personHash, organizationID, err := personContext(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	var req SetPrimaryFaceImageRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "รูปแบบข้อมูลไม่ถูกต้อง",
+		})
+	}
+	if req.FaceImageID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "ต้องระบุรหัสรูปภาพ (face_image_id)",
+		})
+	}
+
+	person, err := h.PersonService.SetPrimaryFaceImage(c.Context(), organizationID, personHash, req.FaceImageID)
+	if err != nil {
+		return c.Status(personOperationErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(person)
+}
+
+// UnpinPrimaryFaceImage เป็น handler สำหรับยกเลิกรูปภาพหลักที่กำหนดเอง
+// @Summary Unpin the primary face image of a person
+// @Description Remove the pinned primary face image, so the image with the best quality_score is shown again
+// @Tags persons
+// @Produce json
+// @Param person_hash path string true "Person Hash"
+// @Security ApiKeyAuth
+// @Success 200 {object} models.Person
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse "Unauthorized (invalid or missing API key)"
+// @Failure 404 {object} ErrorResponse "Person not found"
+// @Failure 500 {object} ErrorResponse
+// @Router /api/persons/{person_hash}/primary-face-image [delete]
+func (h *PersonHandler) UnpinPrimaryFaceImage(c *fiber.Ctx) error {
+	personHash, organizationID, err := personContext(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	person, err := h.PersonService.SetPrimaryFaceImage(c.Context(), organizationID, personHash, "")
+	if err != nil {
+		return c.Status(personOperationErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(person)
+}
+
 // PersonsResponse เป็นโครงสร้างสำหรับส่งรายการบุคคลพร้อมกับข้อมูล pagination
 type PersonsResponse struct {
-	Data       []models.Person      `json:"data"`
-	Pagination *models.Pagination `json:"pagination"`
+	Data       []models.Person          `json:"data"`
+	Pagination *models.CursorPagination `json:"pagination"`
 }
\ No newline at end of file
@@ -0,0 +1,278 @@
+package handlers
+
+import (
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/models"
+	"github.com/gofiber/fiber/v2"
+)
+
+// MergeRequest เป็นโครงสร้างข้อมูลสำหรับรวมบุคคลเข้ากับบุคคลใน path
+type MergeRequest struct {
+	SourceHash string `json:"source_hash" example:"b7c1e2..."`
+	Reason     string `json:"reason,omitempty" example:"Same visitor detected with two hashes"`
+}
+
+// SplitRequest เป็นโครงสร้างข้อมูลสำหรับแยก logs และรูปภาพออกเป็นบุคคลใหม่
+type SplitRequest struct {
+	LogIDs        []string `json:"log_ids"`
+	FaceImageIDs  []string `json:"face_image_ids,omitempty"`
+	NewPersonHash string   `json:"new_person_hash,omitempty" example:"split-3f2a..."`
+	Reason        string   `json:"reason,omitempty" example:"Two people detected as one"`
+}
+
+// PersonOperationsResponse เป็นโครงสร้างข้อมูลสำหรับการตอบกลับรายการประวัติการรวมและแยกบุคคล
+type PersonOperationsResponse struct {
+	Data       []models.PersonOperation `json:"data"`
+	Pagination *models.CursorPagination `json:"pagination"`
+}
+
+// personOperationErrorStatus แปลงข้อผิดพลาดของการรวมและแยกบุคคลเป็น HTTP status
+func personOperationErrorStatus(err error) int {
+	switch err.Error() {
+	case "ไม่พบข้อมูลบุคคล", "ไม่พบการดำเนินการ":
+		return fiber.StatusNotFound
+	case "ต้องระบุรหัสบุคคลที่ต้องการรวม",
+		"ไม่สามารถรวมบุคคลเข้ากับตัวเอง",
+		"พบ log ที่ไม่ใช่ของบุคคลนี้",
+		"ต้องระบุ logs ที่ต้องการแยก",
+		"ต้องเหลือ log อย่างน้อยหนึ่งรายการกับบุคคลเดิม",
+		"พบรูปภาพใบหน้าที่ไม่ใช่ของบุคคลนี้":
+		return fiber.StatusBadRequest
+	case "รหัสบุคคลใหม่ถูกใช้แล้ว",
+		"การดำเนินการนี้ถูกยกเลิกแล้ว",
+		"ต้องยกเลิกการดำเนินการที่ใหม่กว่าของบุคคลเดียวกันก่อน":
+		return fiber.StatusConflict
+	}
+	return fiber.StatusInternalServerError
+}
+
+// MergePerson เป็น handler สำหรับรวมบุคคลอื่นเข้ากับบุคคลใน path
+// @Summary Merge a person into another
+// @Description Merge the person source_hash into the person in the path. All logs, face images, labels and notes move to the surviving person, first_seen, last_seen, visit_count and is_new_person are recomputed, and future detections of source_hash are counted as the surviving person. The merge is recorded and can be undone.
+// @Tags persons
+// @Accept json
+// @Produce json
+// @Param person_hash path string true "Surviving Person Hash"
+// @Param merge body MergeRequest true "Person to merge"
+// @Security ApiKeyAuth
+// @Success 201 {object} models.PersonOperation
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse "Unauthorized (invalid or missing API key)"
+// @Failure 404 {object} ErrorResponse "Person not found"
+// @Failure 500 {object} ErrorResponse
+// @Router /api/persons/{person_hash}/merge [post]
+func (h *PersonHandler) MergePerson(c *fiber.Ctx) error {
+	personHash, organizationID, err := personContext(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	var req MergeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "รูปแบบข้อมูลไม่ถูกต้อง",
+		})
+	}
+
+	operation, err := h.PersonService.MergePersons(c.Context(), organizationID, personHash, req.SourceHash, req.Reason, requestAuthor(c))
+	if err != nil {
+		return c.Status(personOperationErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(operation)
+}
+
+// SplitPerson เป็น handler สำหรับแยก logs และรูปภาพที่เลือกออกเป็นบุคคลใหม่
+// @Summary Split a person
+// @Description Move the selected logs and face images of a person to a new person. When new_person_hash is empty a hash is generated. Both persons are recomputed. The split is recorded and can be undone.
+// @Tags persons
+// @Accept json
+// @Produce json
+// @Param person_hash path string true "Person Hash to split"
+// @Param split body SplitRequest true "Logs and face images to move"
+// @Security ApiKeyAuth
+// @Success 201 {object} models.PersonOperation
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse "Unauthorized (invalid or missing API key)"
+// @Failure 404 {object} ErrorResponse "Person not found"
+// @Failure 409 {object} ErrorResponse "New person hash already in use"
+// @Failure 500 {object} ErrorResponse
+// @Router /api/persons/{person_hash}/split [post]
+func (h *PersonHandler) SplitPerson(c *fiber.Ctx) error {
+	personHash, organizationID, err := personContext(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	var req SplitRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "รูปแบบข้อมูลไม่ถูกต้อง",
+		})
+	}
+
+	operation, err := h.PersonService.SplitPerson(c.Context(), organizationID, personHash, req.LogIDs, req.FaceImageIDs, req.NewPersonHash, req.Reason, requestAuthor(c))
+	if err != nil {
+		return c.Status(personOperationErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(operation)
+}
+
+// ListPersonOperations เป็น handler สำหรับดึงประวัติการรวมและแยกของบุคคล
+// @Summary List merges and splits of a person
+// @Description Retrieve the merges and splits where the person is the source or the target, newest first
+// @Tags persons
+// @Accept json
+// @Produce json
+// @Param person_hash path string true "Person Hash"
+// @Param cursor query string false "Cursor from pagination.next_cursor of the previous page"
+// @Param limit query int false "Number of items per page (max 100)" default(10)
+// @Param order query string false "Sort order (asc, desc)" default(desc)
+// @Param fields query string false "Comma-separated fields to return"
+// @Param include_total query bool false "Also count the total number of items (slower)"
+// @Security ApiKeyAuth
+// @Success 200 {object} PersonOperationsResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse "Unauthorized (invalid or missing API key)"
+// @Failure 500 {object} ErrorResponse
+// @Router /api/persons/{person_hash}/operations [get]
+func (h *PersonHandler) ListPersonOperations(c *fiber.Ctx) error {
+	personHash, organizationID, err := personContext(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return h.listOperations(c, organizationID, personHash)
+}
+
+// ListOperations เป็น handler สำหรับดึงประวัติการรวมและแยกบุคคลทั้งหมดขององค์กร
+// @Summary List person merges and splits
+// @Description Retrieve the audit trail of person merges and splits of the organization, newest first
+// @Tags persons
+// @Accept json
+// @Produce json
+// @Param cursor query string false "Cursor from pagination.next_cursor of the previous page"
+// @Param limit query int false "Number of items per page (max 100)" default(10)
+// @Param order query string false "Sort order (asc, desc)" default(desc)
+// @Param fields query string false "Comma-separated fields to return"
+// @Param include_total query bool false "Also count the total number of items (slower)"
+// @Security ApiKeyAuth
+// @Success 200 {object} PersonOperationsResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse "Unauthorized (invalid or missing API key)"
+// @Failure 500 {object} ErrorResponse
+// @Router /api/person-operations [get]
+func (h *PersonHandler) ListOperations(c *fiber.Ctx) error {
+	// ดึง organization ID จาก context
+	organizationID := c.Locals("organization_id").(string)
+	if organizationID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "ไม่พบข้อมูลองค์กร",
+		})
+	}
+
+	return h.listOperations(c, organizationID, "")
+}
+
+// listOperations ดึงประวัติการรวมและแยกบุคคลตามค่าการแบ่งหน้าใน query
+func (h *PersonHandler) listOperations(c *fiber.Ctx, organizationID, personHash string) error {
+	opts, err := parseListOptions(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	operations, pagination, err := h.PersonService.ListOperations(c.Context(), organizationID, personHash, opts)
+	if err != nil {
+		return c.Status(listErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	if len(opts.Fields) > 0 {
+		return projectedResponse(c, operations, pagination, opts.Fields)
+	}
+
+	return c.JSON(PersonOperationsResponse{
+		Data:       operations,
+		Pagination: pagination,
+	})
+}
+
+// GetOperation เป็น handler สำหรับดึงการรวมหรือแยกบุคคลตาม ID
+// @Summary Get a person merge or split
+// @Description Retrieve a person merge or split by ID, including the snapshot used to undo it
+// @Tags persons
+// @Accept json
+// @Produce json
+// @Param id path string true "Operation ID"
+// @Security ApiKeyAuth
+// @Success 200 {object} models.PersonOperation
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse "Unauthorized (invalid or missing API key)"
+// @Failure 404 {object} ErrorResponse "Operation not found"
+// @Failure 500 {object} ErrorResponse
+// @Router /api/person-operations/{id} [get]
+func (h *PersonHandler) GetOperation(c *fiber.Ctx) error {
+	// ดึง organization ID จาก context
+	organizationID := c.Locals("organization_id").(string)
+	if organizationID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "ไม่พบข้อมูลองค์กร",
+		})
+	}
+
+	operation, err := h.PersonService.GetOperation(c.Context(), c.Params("id"), organizationID)
+	if err != nil {
+		return c.Status(personOperationErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(operation)
+}
+
+// UndoOperation เป็น handler สำหรับยกเลิกการรวมหรือแยกบุคคล
+// @Summary Undo a person merge or split
+// @Description Restore the persons, logs, face images, labels, notes and aliases to their state before the operation. Newer operations on the same persons must be undone first.
+// @Tags persons
+// @Accept json
+// @Produce json
+// @Param id path string true "Operation ID"
+// @Security ApiKeyAuth
+// @Success 200 {object} models.PersonOperation
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse "Unauthorized (invalid or missing API key)"
+// @Failure 404 {object} ErrorResponse "Operation not found"
+// @Failure 409 {object} ErrorResponse "Already undone or a newer operation exists"
+// @Failure 500 {object} ErrorResponse
+// @Router /api/person-operations/{id}/undo [post]
+func (h *PersonHandler) UndoOperation(c *fiber.Ctx) error {
+	// ดึง organization ID จาก context
+	organizationID := c.Locals("organization_id").(string)
+	if organizationID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "ไม่พบข้อมูลองค์กร",
+		})
+	}
+
+	operation, err := h.PersonService.UndoOperation(c.Context(), c.Params("id"), organizationID, requestAuthor(c))
+	if err != nil {
+		return c.Status(personOperationErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(operation)
+}
@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/models"
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+// RebuildHandler เป็นโครงสร้างสำหรับจัดการ API endpoints เกี่ยวกับการสร้างข้อมูลที่คำนวณจาก logs ใหม่
+type RebuildHandler struct {
+	RebuildService *services.RebuildService
+}
+
+// NewRebuildHandler สร้าง RebuildHandler ใหม่
+func NewRebuildHandler(rebuildService *services.RebuildService) *RebuildHandler {
+	return &RebuildHandler{
+		RebuildService: rebuildService,
+	}
+}
+
+// RebuildRequest เป็นโครงสร้างสำหรับสร้างงานสร้างข้อมูลใหม่
+type RebuildRequest struct {
+	From      string `json:"from,omitempty" example:"2025-01-01T00:00:00Z"`
+	To        string `json:"to,omitempty" example:"2025-02-01T00:00:00Z"`
+	DryRun    bool   `json:"dry_run" example:"true"`
+	ChunkSize int    `json:"chunk_size,omitempty" example:"500"`
+}
+
+// rebuildErrorStatus แปลงข้อผิดพลาดของงานสร้างข้อมูลใหม่เป็น HTTP status
+func rebuildErrorStatus(err error) int {
+	switch err.Error() {
+	case "ไม่พบงาน":
+		return fiber.StatusNotFound
+	case "to ต้องอยู่หลัง from":
+		return fiber.StatusBadRequest
+	case "งานสร้างข้อมูลใหม่เสร็จแล้ว", "งานสร้างข้อมูลใหม่กำลังทำงานอยู่":
+		return fiber.StatusConflict
+	}
+	if strings.HasPrefix(err.Error(), "chunk_size ") {
+		return fiber.StatusBadRequest
+	}
+	return fiber.StatusInternalServerError
+}
+
+// CreateRebuildJob เป็น handler สำหรับสร้างงานสร้างข้อมูลที่คำนวณจาก logs ใหม่แบบเบื้องหลัง
+// @Summary Create derived data rebuild job
+// @Description Recompute is_new_person flags and the first_seen, last_seen and visit_count of every person seen in the time range from the person's whole log history. Persons are processed in chunks, each committed with a checkpoint, so a failed job can be resumed. Set dry_run to only report what would change.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param job body RebuildRequest true "Rebuild parameters"
+// @Security ApiKeyAuth
+// @Success 202 {object} models.Job
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse "Unauthorized (invalid or missing API key)"
+// @Failure 500 {object} ErrorResponse
+// @Router /api/admin/rebuilds [post]
+func (h *RebuildHandler) CreateRebuildJob(c *fiber.Ctx) error {
+	// ดึง organization ID จาก context
+	organizationID := c.Locals("organization_id").(string)
+	if organizationID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "ไม่พบข้อมูลองค์กร",
+		})
+	}
+
+	var req RebuildRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "รูปแบบข้อมูลไม่ถูกต้อง",
+		})
+	}
+
+	params := models.RebuildParams{
+		OrganizationID: organizationID,
+		DryRun:         req.DryRun,
+		ChunkSize:      req.ChunkSize,
+	}
+	if req.From != "" {
+		from, err := time.Parse(time.RFC3339, req.From)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "รูปแบบของ from ไม่ถูกต้อง โปรดใช้รูปแบบ YYYY-MM-DDTHH:MM:SS",
+			})
+		}
+		params.From = &from
+	}
+	if req.To != "" {
+		to, err := time.Parse(time.RFC3339, req.To)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "รูปแบบของ to ไม่ถูกต้อง โปรดใช้รูปแบบ YYYY-MM-DDTHH:MM:SS",
+			})
+		}
+		params.To = &to
+	}
+
+	job, err := h.RebuildService.StartRebuild(c.Context(), params)
+	if err != nil {
+		return c.Status(rebuildErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(job)
+}
+
+// ListRebuildJobs เป็น handler สำหรับดึงรายการงานสร้างข้อมูลใหม่
+// @Summary List derived data rebuild jobs
+// @Description List the derived data rebuild jobs of the caller's organization, newest first
+// @Tags admin
+// @Produce json
+// @Param page query int false "Page number to retrieve (starting from 1)" default(1)
+// @Param page_size query int false "Number of items per page (max 100)" default(10)
+// @Security ApiKeyAuth
+// @Success 200 {object} ListExportJobsResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse "Unauthorized (invalid or missing API key)"
+// @Failure 500 {object} ErrorResponse
+// @Router /api/admin/rebuilds [get]
+func (h *RebuildHandler) ListRebuildJobs(c *fiber.Ctx) error {
+	// ดึง organization ID จาก context
+	organizationID := c.Locals("organization_id").(string)
+	if organizationID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "ไม่พบข้อมูลองค์กร",
+		})
+	}
+
+	page, err := strconv.Atoi(c.Query("page", "1"))
+	if err != nil || page <= 0 {
+		page = 1
+	}
+	pageSize, err := strconv.Atoi(c.Query("page_size", "10"))
+	if err != nil || pageSize <= 0 {
+		pageSize = 10
+	}
+	if pageSize > 100 {
+		pageSize = 100
+	}
+
+	jobs, pagination, err := h.RebuildService.Jobs.ListJobs(c.Context(), organizationID, models.JobTypeDerivedRebuild, page, pageSize)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(ListExportJobsResponse{
+		Data:       jobs,
+		Pagination: pagination,
+	})
+}
+
+// GetRebuildJob เป็น handler สำหรับดึงสถานะของงานสร้างข้อมูลใหม่
+// @Summary Get derived data rebuild job
+// @Description Get the status, progress (persons rebuilt out of total) and result of a rebuild job. The result of a dry run lists the changes that would be made.
+// @Tags admin
+// @Produce json
+// @Param id path string true "Job ID"
+// @Security ApiKeyAuth
+// @Success 200 {object} models.Job
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse "Unauthorized (invalid or missing API key)"
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/admin/rebuilds/{id} [get]
+func (h *RebuildHandler) GetRebuildJob(c *fiber.Ctx) error {
+	// ดึง organization ID จาก context
+	organizationID := c.Locals("organization_id").(string)
+	if organizationID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "ไม่พบข้อมูลองค์กร",
+		})
+	}
+
+	job, err := h.RebuildService.GetRebuildJob(c.Context(), c.Params("id"), organizationID)
+	if err != nil {
+		return c.Status(rebuildErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(job)
+}
+
+// ResumeRebuildJob เป็น handler สำหรับทำงานสร้างข้อมูลใหม่ที่ล้มเหลวต่อจาก checkpoint
+// @Summary Resume derived data rebuild job
+// @Description Continue a failed rebuild job, or one left running by a stopped server, after the last committed chunk
+// @Tags admin
+// @Produce json
+// @Param id path string true "Job ID"
+// @Security ApiKeyAuth
+// @Success 202 {object} models.Job
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse "Unauthorized (invalid or missing API key)"
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse "Job has completed or is still running"
+// @Failure 500 {object} ErrorResponse
+// @Router /api/admin/rebuilds/{id}/resume [post]
+func (h *RebuildHandler) ResumeRebuildJob(c *fiber.Ctx) error {
+	// ดึง organization ID จาก context
+	organizationID := c.Locals("organization_id").(string)
+	if organizationID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "ไม่พบข้อมูลองค์กร",
+		})
+	}
+
+	job, err := h.RebuildService.ResumeRebuild(c.Context(), c.Params("id"), organizationID)
+	if err != nil {
+		return c.Status(rebuildErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(job)
+}
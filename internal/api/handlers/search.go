@@ -0,0 +1,316 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/models"
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+// SearchHandler เป็นโครงสร้างสำหรับจัดการ API endpoints เกี่ยวกับการค้นหาบุคคลที่บันทึกไว้
+type SearchHandler struct {
+	SearchService *services.SearchService
+}
+
+// NewSearchHandler สร้าง SearchHandler ใหม่
+func NewSearchHandler(searchService *services.SearchService) *SearchHandler {
+	return &SearchHandler{
+		SearchService: searchService,
+	}
+}
+
+// SavedSearchRequest เป็นโครงสร้างข้อมูลสำหรับสร้างหรือแก้ไขการค้นหาที่บันทึกไว้
+type SavedSearchRequest struct {
+	Name        string              `json:"name" example:"Loyal VIPs"`
+	Description string              `json:"description,omitempty" example:"VIP customers with at least 5 visits"`
+	Filter      models.PersonSearch `json:"filter"`
+}
+
+// searchErrorStatus แปลงข้อผิดพลาดของการค้นหาบุคคลเป็น HTTP status
+func searchErrorStatus(err error) int {
+	switch err.Error() {
+	case "ไม่พบการค้นหาที่บันทึกไว้":
+		return fiber.StatusNotFound
+	case "ต้องระบุชื่อการค้นหา",
+		"จำนวนการเข้าชมต้องไม่ติดลบ",
+		"min_visits ต้องไม่มากกว่า max_visits",
+		"first_seen_to ต้องไม่ก่อน first_seen_from",
+		"last_seen_to ต้องไม่ก่อน last_seen_from",
+		"new_within_days ต้องไม่ติดลบ",
+		"ป้ายกำกับต้องประกอบด้วย a-z, 0-9, _ หรือ - และยาวไม่เกิน 50 ตัวอักษร":
+		return fiber.StatusBadRequest
+	}
+	return listErrorStatus(err)
+}
+
+// scopeErrorStatus แปลงข้อผิดพลาดของสถิติเป็น HTTP status โดยคืน 404 เมื่อไม่พบการค้นหาที่บันทึกไว้ใน saved_search
+func scopeErrorStatus(err error, fallback int) int {
+	if err.Error() == "ไม่พบการค้นหาที่บันทึกไว้" {
+		return fiber.StatusNotFound
+	}
+	return fallback
+}
+
+// parseSearchTime แปลงเวลาในรูปแบบ RFC3339 หรือ YYYY-MM-DD จาก query parameter ถ้ามี
+func parseSearchTime(c *fiber.Ctx, name string) (*time.Time, error) {
+	value := c.Query(name)
+	if value == "" {
+		return nil, nil
+	}
+	if parsed, err := time.Parse(time.RFC3339, value); err == nil {
+		return &parsed, nil
+	}
+	parsed, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return nil, fmt.Errorf("รูปแบบของพารามิเตอร์ %s ไม่ถูกต้อง โปรดใช้รูปแบบ YYYY-MM-DD หรือ YYYY-MM-DDTHH:MM:SSZ", name)
+	}
+	return &parsed, nil
+}
+
+// parseSearchInt แปลงจำนวนเต็มจาก query parameter ถ้ามี
+func parseSearchInt(c *fiber.Ctx, name string) (*int, error) {
+	value := c.Query(name)
+	if value == "" {
+		return nil, nil
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return nil, fmt.Errorf("รูปแบบของพารามิเตอร์ %s ไม่ถูกต้อง", name)
+	}
+	return &parsed, nil
+}
+
+// parsePersonSearch แปลง query parameters ของการค้นหาบุคคล
+func parsePersonSearch(c *fiber.Ctx) (models.PersonSearch, error) {
+	search := models.PersonSearch{
+		CameraIDs:     queryValues(c, "camera_id"),
+		Zones:         queryValues(c, "zone"),
+		Labels:        queryValues(c, "label"),
+		SavedSearchID: c.Query("saved_search"),
+	}
+
+	var err error
+	if search.FirstSeenFrom, err = parseSearchTime(c, "first_seen_from"); err != nil {
+		return search, err
+	}
+	if search.FirstSeenTo, err = parseSearchTime(c, "first_seen_to"); err != nil {
+		return search, err
+	}
+	if search.LastSeenFrom, err = parseSearchTime(c, "last_seen_from"); err != nil {
+		return search, err
+	}
+	if search.LastSeenTo, err = parseSearchTime(c, "last_seen_to"); err != nil {
+		return search, err
+	}
+	if search.MinVisits, err = parseSearchInt(c, "min_visits"); err != nil {
+		return search, err
+	}
+	if search.MaxVisits, err = parseSearchInt(c, "max_visits"); err != nil {
+		return search, err
+	}
+
+	newWithinDays, err := parseSearchInt(c, "new_within_days")
+	if err != nil {
+		return search, err
+	}
+	if newWithinDays != nil {
+		search.NewWithinDays = *newWithinDays
+	}
+
+	if hasFaceImages := c.Query("has_face_images"); hasFaceImages != "" {
+		value, err := strconv.ParseBool(hasFaceImages)
+		if err != nil {
+			return search, fmt.Errorf("รูปแบบของพารามิเตอร์ has_face_images ไม่ถูกต้อง")
+		}
+		search.HasFaceImages = &value
+	}
+
+	return search, nil
+}
+
+// GetSavedSearches เป็น handler สำหรับดึงการค้นหาที่บันทึกไว้ทั้งหมดขององค์กร
+// @Summary List saved searches
+// @Description Retrieve all saved person searches of the organization
+// @Tags saved-searches
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {array} models.SavedSearch
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse "Unauthorized (invalid or missing API key)"
+// @Failure 500 {object} ErrorResponse
+// @Router /api/saved-searches [get]
+func (h *SearchHandler) GetSavedSearches(c *fiber.Ctx) error {
+	// ดึง organization ID จาก context
+	organizationID := c.Locals("organization_id").(string)
+	if organizationID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "ไม่พบข้อมูลองค์กร",
+		})
+	}
+
+	searches, err := h.SearchService.ListSavedSearches(c.Context(), organizationID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(searches)
+}
+
+// CreateSavedSearch เป็น handler สำหรับบันทึกการค้นหาบุคคล
+// @Summary Create a saved search
+// @Description Save a person search. Pass its ID as saved_search to /api/persons or to the stats and segment endpoints to use it as a dynamic segment.
+// @Tags saved-searches
+// @Accept json
+// @Produce json
+// @Param search body SavedSearchRequest true "Saved search details"
+// @Security ApiKeyAuth
+// @Success 201 {object} models.SavedSearch
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse "Unauthorized (invalid or missing API key)"
+// @Failure 500 {object} ErrorResponse
+// @Router /api/saved-searches [post]
+func (h *SearchHandler) CreateSavedSearch(c *fiber.Ctx) error {
+	// ดึง organization ID จาก context
+	organizationID := c.Locals("organization_id").(string)
+	if organizationID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "ไม่พบข้อมูลองค์กร",
+		})
+	}
+
+	var req SavedSearchRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "รูปแบบข้อมูลไม่ถูกต้อง",
+		})
+	}
+
+	saved := models.SavedSearch{
+		OrganizationID: organizationID,
+		Name:           req.Name,
+		Description:    req.Description,
+		CreatedBy:      requestAuthor(c),
+	}
+	if err := h.SearchService.CreateSavedSearch(c.Context(), &saved, req.Filter); err != nil {
+		return c.Status(searchErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(saved)
+}
+
+// GetSavedSearch เป็น handler สำหรับดึงการค้นหาที่บันทึกไว้ตาม ID
+// @Summary Get a saved search
+// @Description Retrieve a saved person search by ID
+// @Tags saved-searches
+// @Accept json
+// @Produce json
+// @Param id path string true "Saved search ID"
+// @Security ApiKeyAuth
+// @Success 200 {object} models.SavedSearch
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse "Unauthorized (invalid or missing API key)"
+// @Failure 404 {object} ErrorResponse "Saved search not found"
+// @Failure 500 {object} ErrorResponse
+// @Router /api/saved-searches/{id} [get]
+func (h *SearchHandler) GetSavedSearch(c *fiber.Ctx) error {
+	// ดึง organization ID จาก context
+	organizationID := c.Locals("organization_id").(string)
+	if organizationID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "ไม่พบข้อมูลองค์กร",
+		})
+	}
+
+	saved, err := h.SearchService.GetSavedSearch(c.Context(), c.Params("id"), organizationID)
+	if err != nil {
+		return c.Status(searchErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(saved)
+}
+
+// UpdateSavedSearch เป็น handler สำหรับแก้ไขการค้นหาที่บันทึกไว้
+// @Summary Update a saved search
+// @Description Replace the name, description and filter of a saved person search
+// @Tags saved-searches
+// @Accept json
+// @Produce json
+// @Param id path string true "Saved search ID"
+// @Param search body SavedSearchRequest true "Saved search details"
+// @Security ApiKeyAuth
+// @Success 200 {object} models.SavedSearch
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse "Unauthorized (invalid or missing API key)"
+// @Failure 404 {object} ErrorResponse "Saved search not found"
+// @Failure 500 {object} ErrorResponse
+// @Router /api/saved-searches/{id} [put]
+func (h *SearchHandler) UpdateSavedSearch(c *fiber.Ctx) error {
+	// ดึง organization ID จาก context
+	organizationID := c.Locals("organization_id").(string)
+	if organizationID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "ไม่พบข้อมูลองค์กร",
+		})
+	}
+
+	var req SavedSearchRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "รูปแบบข้อมูลไม่ถูกต้อง",
+		})
+	}
+
+	saved, err := h.SearchService.UpdateSavedSearch(c.Context(), c.Params("id"), organizationID, req.Name, req.Description, req.Filter)
+	if err != nil {
+		return c.Status(searchErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(saved)
+}
+
+// DeleteSavedSearch เป็น handler สำหรับลบการค้นหาที่บันทึกไว้
+// @Summary Delete a saved search
+// @Description Delete a saved person search
+// @Tags saved-searches
+// @Accept json
+// @Produce json
+// @Param id path string true "Saved search ID"
+// @Security ApiKeyAuth
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse "Unauthorized (invalid or missing API key)"
+// @Failure 404 {object} ErrorResponse "Saved search not found"
+// @Failure 500 {object} ErrorResponse
+// @Router /api/saved-searches/{id} [delete]
+func (h *SearchHandler) DeleteSavedSearch(c *fiber.Ctx) error {
+	// ดึง organization ID จาก context
+	organizationID := c.Locals("organization_id").(string)
+	if organizationID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "ไม่พบข้อมูลองค์กร",
+		})
+	}
+
+	if err := h.SearchService.DeleteSavedSearch(c.Context(), c.Params("id"), organizationID); err != nil {
+		return c.Status(searchErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "ลบการค้นหาที่บันทึกไว้สำเร็จ",
+	})
+}
@@ -0,0 +1,282 @@
+package handlers
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/models"
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+// SegmentHandler เป็นโครงสร้างสำหรับจัดการ API endpoints เกี่ยวกับการแบ่งกลุ่มผู้เข้าชม
+type SegmentHandler struct {
+	SegmentService *services.SegmentService
+}
+
+// NewSegmentHandler สร้าง SegmentHandler ใหม่
+func NewSegmentHandler(segmentService *services.SegmentService) *SegmentHandler {
+	return &SegmentHandler{
+		SegmentService: segmentService,
+	}
+}
+
+// GetDistribution เป็น handler สำหรับดึงการกระจายของกลุ่มผู้เข้าชม
+// @Summary Get visitor segment distribution
+// @Description Retrieve segment counts, visit frequency and recency distributions, and average days between visits. Persons labelled as staff are excluded unless include_staff=true.
+// @Tags segments
+// @Accept json
+// @Produce json
+// @Param include_staff query bool false "Also count persons labelled as staff" default(false)
+// @Param saved_search query string false "Only count persons matching this saved search"
+// @Security ApiKeyAuth
+// @Success 200 {object} models.SegmentDistribution
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse "Unauthorized (invalid or missing API key)"
+// @Failure 404 {object} ErrorResponse "Saved search not found"
+// @Failure 500 {object} ErrorResponse
+// @Router /api/segments [get]
+func (h *SegmentHandler) GetDistribution(c *fiber.Ctx) error {
+	// ดึง organization ID จาก context
+	organizationID := c.Locals("organization_id").(string)
+	if organizationID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "ไม่พบข้อมูลองค์กร",
+		})
+	}
+
+	scope, err := parseStatsScope(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	// ดึงการกระจายของกลุ่มผู้เข้าชม
+	distribution, err := h.SegmentService.GetDistribution(c.Context(), organizationID, scope)
+	if err != nil {
+		return c.Status(scopeErrorStatus(err, fiber.StatusInternalServerError)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(distribution)
+}
+
+// GetTrend เป็น handler สำหรับดึงจำนวนบุคคลในแต่ละกลุ่มตามช่วงเวลา
+// @Summary Get visitor segment counts over time
+// @Description Retrieve the number of persons in each segment at every point of the requested range
+// @Tags segments
+// @Accept json
+// @Produce json
+// @Param from query string false "Start date (format YYYY-MM-DD). Defaults to 30 days ago."
+// @Param to query string false "End date (format YYYY-MM-DD). Defaults to today."
+// @Param interval query string false "Interval between points (day, week, month)" default(day)
+// @Param include_staff query bool false "Also count persons labelled as staff" default(false)
+// @Param saved_search query string false "Only count persons matching this saved search"
+// @Security ApiKeyAuth
+// @Success 200 {array} models.SegmentTrendPoint
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse "Unauthorized (invalid or missing API key)"
+// @Failure 404 {object} ErrorResponse "Saved search not found"
+// @Failure 500 {object} ErrorResponse
+// @Router /api/segments/trend [get]
+func (h *SegmentHandler) GetTrend(c *fiber.Ctx) error {
+	// ดึง organization ID จาก context
+	organizationID := c.Locals("organization_id").(string)
+	if organizationID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "ไม่พบข้อมูลองค์กร",
+		})
+	}
+
+	// แปลงช่วงวันที่ ถ้าไม่ระบุจะใช้ 30 วันล่าสุด
+	to := time.Now()
+	if toStr := c.Query("to"); toStr != "" {
+		parsed, err := time.Parse("2006-01-02", toStr)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "รูปแบบของพารามิเตอร์ to ไม่ถูกต้อง โปรดใช้รูปแบบ YYYY-MM-DD",
+			})
+		}
+		to = parsed
+	}
+
+	from := to.AddDate(0, 0, -30)
+	if fromStr := c.Query("from"); fromStr != "" {
+		parsed, err := time.Parse("2006-01-02", fromStr)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "รูปแบบของพารามิเตอร์ from ไม่ถูกต้อง โปรดใช้รูปแบบ YYYY-MM-DD",
+			})
+		}
+		from = parsed
+	}
+
+	// จำกัดช่วงเวลาไม่ให้เกิน 1 ปี เพื่อไม่ให้ query หนักเกินไป
+	if to.Sub(from) > 366*24*time.Hour {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "ช่วงเวลาต้องไม่เกิน 366 วัน",
+		})
+	}
+
+	scope, err := parseStatsScope(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	// ดึงแนวโน้มของกลุ่มผู้เข้าชม
+	trend, err := h.SegmentService.GetTrend(c.Context(), organizationID, from, to, c.Query("interval", "day"), scope)
+	if err != nil {
+		return c.Status(scopeErrorStatus(err, fiber.StatusBadRequest)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(trend)
+}
+
+// ListSegmentMembers เป็น handler สำหรับดึงรายการบุคคลในกลุ่ม
+// @Summary List persons in a segment
+// @Description Retrieve the person hashes that belong to a segment with pagination
+// @Tags segments
+// @Accept json
+// @Produce json
+// @Param segment path string true "Segment name (one_timer, occasional, loyal, lapsed)"
+// @Param page query int false "Page number (starting from 1)" default(1)
+// @Param page_size query int false "Items per page (max 100)" default(10)
+// @Param include_staff query bool false "Also count persons labelled as staff" default(false)
+// @Param saved_search query string false "Only count persons matching this saved search"
+// @Security ApiKeyAuth
+// @Success 200 {object} SegmentMembersResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse "Unauthorized (invalid or missing API key)"
+// @Failure 404 {object} ErrorResponse "Saved search not found"
+// @Failure 500 {object} ErrorResponse
+// @Router /api/segments/{segment}/persons [get]
+func (h *SegmentHandler) ListSegmentMembers(c *fiber.Ctx) error {
+	// ดึง organization ID จาก context
+	organizationID := c.Locals("organization_id").(string)
+	if organizationID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "ไม่พบข้อมูลองค์กร",
+		})
+	}
+
+	// ดึงค่า pagination จาก query parameters
+	page, err := strconv.Atoi(c.Query("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	pageSize, err := strconv.Atoi(c.Query("page_size", "10"))
+	if err != nil || pageSize < 1 {
+		pageSize = 10
+	}
+	if pageSize > 100 {
+		pageSize = 100
+	}
+
+	scope, err := parseStatsScope(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	// ดึงรายการบุคคลในกลุ่ม
+	members, pagination, err := h.SegmentService.ListSegmentMembers(c.Context(), organizationID, c.Params("segment"), page, pageSize, scope)
+	if err != nil {
+		return c.Status(scopeErrorStatus(err, fiber.StatusBadRequest)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	// สร้าง response
+	response := SegmentMembersResponse{
+		Data:       members,
+		Pagination: pagination,
+	}
+
+	return c.JSON(response)
+}
+
+// GetSettings เป็น handler สำหรับดึงเกณฑ์การแบ่งกลุ่มขององค์กร
+// @Summary Get segment thresholds
+// @Description Retrieve the visitor segmentation thresholds of the organization
+// @Tags segments
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} models.SegmentSettings
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse "Unauthorized (invalid or missing API key)"
+// @Failure 500 {object} ErrorResponse
+// @Router /api/segments/settings [get]
+func (h *SegmentHandler) GetSettings(c *fiber.Ctx) error {
+	// ดึง organization ID จาก context
+	organizationID := c.Locals("organization_id").(string)
+	if organizationID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "ไม่พบข้อมูลองค์กร",
+		})
+	}
+
+	settings, err := h.SegmentService.GetSettings(c.Context(), organizationID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(settings)
+}
+
+// UpdateSettings เป็น handler สำหรับบันทึกเกณฑ์การแบ่งกลุ่มขององค์กร
+// @Summary Update segment thresholds
+// @Description Configure the visitor segmentation thresholds of the organization
+// @Tags segments
+// @Accept json
+// @Produce json
+// @Param settings body models.SegmentSettings true "Segment thresholds"
+// @Security ApiKeyAuth
+// @Success 200 {object} models.SegmentSettings
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse "Unauthorized (invalid or missing API key)"
+// @Failure 500 {object} ErrorResponse
+// @Router /api/segments/settings [put]
+func (h *SegmentHandler) UpdateSettings(c *fiber.Ctx) error {
+	// ดึง organization ID จาก context
+	organizationID := c.Locals("organization_id").(string)
+	if organizationID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "ไม่พบข้อมูลองค์กร",
+		})
+	}
+
+	// แปลงข้อมูลจาก request
+	var settings models.SegmentSettings
+	if err := c.BodyParser(&settings); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "รูปแบบข้อมูลไม่ถูกต้อง",
+		})
+	}
+	settings.OrganizationID = organizationID
+
+	// บันทึกเกณฑ์
+	if err := h.SegmentService.UpdateSettings(c.Context(), &settings); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(settings)
+}
+
+// SegmentMembersResponse เป็นโครงสร้างสำหรับส่งรายการบุคคลในกลุ่มพร้อมกับข้อมูล pagination
+type SegmentMembersResponse struct {
+	Data       []models.SegmentMember `json:"data"`
+	Pagination *models.Pagination     `json:"pagination"`
+}
@@ -0,0 +1,284 @@
+package handlers
+
+import (
+	"strconv"
+
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/models"
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+// SiteHandler เป็นโครงสร้างสำหรับจัดการ API endpoints เกี่ยวกับสาขา
+type SiteHandler struct {
+	SiteService *services.SiteService
+}
+
+// NewSiteHandler สร้าง SiteHandler ใหม่
+func NewSiteHandler(siteService *services.SiteService) *SiteHandler {
+	return &SiteHandler{
+		SiteService: siteService,
+	}
+}
+
+// GetSites ดึงรายการสาขาทั้งหมดขององค์กร
+// @Summary Get all sites
+// @Description Retrieve a list of all sites (stores, branches) in the organization with pagination
+// @Tags sites
+// @Accept json
+// @Produce json
+// @Param page query int false "Page number (starting from 1)" default(1)
+// @Param page_size query int false "Items per page (max 100)" default(10)
+// @Security ApiKeyAuth
+// @Success 200 {object} ListSitesResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse "Unauthorized (invalid or missing API key)"
+// @Failure 500 {object} ErrorResponse
+// @Router /api/sites [get]
+func (h *SiteHandler) GetSites(c *fiber.Ctx) error {
+	// ดึง organization ID จาก context
+	organizationID := c.Locals("organization_id").(string)
+	if organizationID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "ไม่พบข้อมูลองค์กร",
+		})
+	}
+
+	// ดึงค่า pagination จาก query parameters
+	page, err := strconv.Atoi(c.Query("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	pageSize, err := strconv.Atoi(c.Query("page_size", "10"))
+	if err != nil || pageSize < 1 {
+		pageSize = 10
+	}
+	if pageSize > 100 {
+		pageSize = 100
+	}
+
+	// ดึงรายการสาขา
+	sites, pagination, err := h.SiteService.ListSites(c.Context(), organizationID, page, pageSize)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	response := ListSitesResponse{
+		Data:       sites,
+		Pagination: pagination,
+	}
+
+	return c.JSON(response)
+}
+
+// GetSite ดึงข้อมูลสาขาตาม ID
+// @Summary Get site by ID
+// @Description Retrieve a specific site and its cameras
+// @Tags sites
+// @Accept json
+// @Produce json
+// @Param id path string true "Site ID"
+// @Security ApiKeyAuth
+// @Success 200 {object} models.Site
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse "Unauthorized (invalid or missing API key)"
+// @Failure 404 {object} ErrorResponse "Site not found"
+// @Failure 500 {object} ErrorResponse
+// @Router /api/sites/{id} [get]
+func (h *SiteHandler) GetSite(c *fiber.Ctx) error {
+	id := c.Params("id")
+	if id == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "ต้องระบุรหัสสาขา",
+		})
+	}
+
+	// ดึง organization ID จาก context
+	organizationID := c.Locals("organization_id").(string)
+	if organizationID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "ไม่พบข้อมูลองค์กร",
+		})
+	}
+
+	site, err := h.SiteService.GetSite(c.Context(), id, organizationID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(site)
+}
+
+// CreateSite สร้างสาขาใหม่
+// @Summary Create a new site
+// @Description Create a new site in the organization
+// @Tags sites
+// @Accept json
+// @Produce json
+// @Param site body models.Site true "Site details"
+// @Security ApiKeyAuth
+// @Success 201 {object} models.Site
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse "Unauthorized (invalid or missing API key)"
+// @Failure 500 {object} ErrorResponse
+// @Router /api/sites [post]
+func (h *SiteHandler) CreateSite(c *fiber.Ctx) error {
+	// ดึง organization ID จาก context
+	organizationID := c.Locals("organization_id").(string)
+	if organizationID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "ไม่พบข้อมูลองค์กร",
+		})
+	}
+
+	// แปลงข้อมูลจาก request
+	var site models.Site
+	if err := c.BodyParser(&site); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "รูปแบบข้อมูลไม่ถูกต้อง",
+		})
+	}
+
+	// ตรวจสอบข้อมูลจำเป็น
+	if site.Name == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "ต้องระบุชื่อสาขา",
+		})
+	}
+	site.OrganizationID = organizationID
+
+	if err := h.SiteService.CreateSite(c.Context(), &site); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(site)
+}
+
+// UpdateSite อัปเดตข้อมูลสาขา
+// @Summary Update a site
+// @Description Update an existing site's details
+// @Tags sites
+// @Accept json
+// @Produce json
+// @Param id path string true "Site ID"
+// @Param site body models.Site true "Updated site details"
+// @Security ApiKeyAuth
+// @Success 200 {object} models.Site
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse "Unauthorized (invalid or missing API key)"
+// @Failure 404 {object} ErrorResponse "Site not found"
+// @Failure 500 {object} ErrorResponse
+// @Router /api/sites/{id} [put]
+func (h *SiteHandler) UpdateSite(c *fiber.Ctx) error {
+	id := c.Params("id")
+	if id == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "ต้องระบุรหัสสาขา",
+		})
+	}
+
+	// ดึง organization ID จาก context
+	organizationID := c.Locals("organization_id").(string)
+	if organizationID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "ไม่พบข้อมูลองค์กร",
+		})
+	}
+
+	// แปลงข้อมูลจาก request
+	var site models.Site
+	if err := c.BodyParser(&site); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "รูปแบบข้อมูลไม่ถูกต้อง",
+		})
+	}
+
+	// ตรวจสอบข้อมูลจำเป็น
+	if site.Name == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "ต้องระบุชื่อสาขา",
+		})
+	}
+	site.ID = id
+	site.OrganizationID = organizationID
+
+	if err := h.SiteService.UpdateSite(c.Context(), &site); err != nil {
+		if err.Error() == "ไม่พบสาขาที่ต้องการอัปเดต" {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	// ดึงข้อมูลล่าสุดเพื่อส่งกลับ
+	updated, err := h.SiteService.GetSite(c.Context(), id, organizationID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(updated)
+}
+
+// DeleteSite ลบสาขา
+// @Summary Delete a site
+// @Description Delete a site by ID. Cameras in the site are kept but no longer belong to any site
+// @Tags sites
+// @Accept json
+// @Produce json
+// @Param id path string true "Site ID"
+// @Security ApiKeyAuth
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse "Unauthorized (invalid or missing API key)"
+// @Failure 404 {object} ErrorResponse "Site not found"
+// @Failure 500 {object} ErrorResponse
+// @Router /api/sites/{id} [delete]
+func (h *SiteHandler) DeleteSite(c *fiber.Ctx) error {
+	id := c.Params("id")
+	if id == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "ต้องระบุรหัสสาขา",
+		})
+	}
+
+	// ดึง organization ID จาก context
+	organizationID := c.Locals("organization_id").(string)
+	if organizationID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "ไม่พบข้อมูลองค์กร",
+		})
+	}
+
+	if err := h.SiteService.DeleteSite(c.Context(), id, organizationID); err != nil {
+		if err.Error() == "ไม่พบสาขาที่ต้องการลบ" {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "ลบสาขาสำเร็จ",
+	})
+}
+
+// ListSitesResponse โครงสร้างสำหรับส่งข้อมูลรายการสาขาพร้อมข้อมูลการแบ่งหน้า
+type ListSitesResponse struct {
+	Data       []models.Site      `json:"data"`
+	Pagination *models.Pagination `json:"pagination"`
+}
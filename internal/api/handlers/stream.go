@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/events"
+	"github.com/gofiber/fiber/v2"
+)
+
+// StreamHandler เป็นโครงสร้างสำหรับจัดการ live stream ของการตรวจจับและตัวนับ
+type StreamHandler struct {
+	Broker    *events.Broker
+	Heartbeat time.Duration
+}
+
+// NewStreamHandler สร้าง StreamHandler ใหม่
+func NewStreamHandler(broker *events.Broker, heartbeat time.Duration) *StreamHandler {
+	if heartbeat <= 0 {
+		heartbeat = 15 * time.Second
+	}
+	return &StreamHandler{
+		Broker:    broker,
+		Heartbeat: heartbeat,
+	}
+}
+
+// splitQuery แยกค่าที่คั่นด้วยจุลภาคใน query parameter
+func splitQuery(value string) []string {
+	var values []string
+	for _, part := range strings.Split(value, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			values = append(values, part)
+		}
+	}
+	return values
+}
+
+// writeEvent เขียนเหตุการณ์ในรูปแบบ Server-Sent Events
+func writeEvent(w *bufio.Writer, event events.Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", event.ID, event.Type, payload); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// Stream เป็น handler สำหรับรับการตรวจจับ ตัวนับรายนาที การเปลี่ยนสถานะของกล้อง และการแจ้งเตือนรายการเฝ้าระวังแบบ real-time
+// @Summary Stream live events
+// @Description Server-Sent Events stream of new detections, per-minute counters, camera status changes and watchlist alerts of the caller's organization. Send the Last-Event-ID header (or last_event_id query parameter) to resume after a disconnect. Browsers' EventSource can authenticate with the api_key query parameter.
+// @Tags stream
+// @Produce text/event-stream
+// @Param types query string false "Comma-separated event types (detection, counter, camera_status, watchlist_alert)"
+// @Param camera_id query string false "Comma-separated camera IDs to filter camera events by"
+// @Param zone query string false "Comma-separated zones to filter camera events by"
+// @Param last_event_id query string false "Resume after this event ID (same as the Last-Event-ID header)"
+// @Security ApiKeyAuth
+// @Success 200 {string} string "text/event-stream"
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse "Unauthorized (invalid or missing API key)"
+// @Router /api/stream [get]
+func (h *StreamHandler) Stream(c *fiber.Ctx) error {
+	// ดึง organization ID จาก context
+	organizationID := c.Locals("organization_id").(string)
+	if organizationID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "ไม่พบข้อมูลองค์กร",
+		})
+	}
+
+	filter := events.Filter{
+		Types:     splitQuery(c.Query("types")),
+		CameraIDs: splitQuery(c.Query("camera_id")),
+		Zones:     splitQuery(c.Query("zone")),
+	}
+	for _, eventType := range filter.Types {
+		if eventType != events.TypeDetection && eventType != events.TypeCounter && eventType != events.TypeCameraStatus && eventType != events.TypeWatchlistAlert {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "types ต้องเป็น detection, counter, camera_status หรือ watchlist_alert",
+			})
+		}
+	}
+
+	lastEventID := c.Get("Last-Event-ID", c.Query("last_event_id"))
+	if lastEventID != "" {
+		if _, _, err := events.ParseID(lastEventID); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "รูปแบบของ Last-Event-ID ไม่ถูกต้อง",
+			})
+		}
+	}
+
+	// สมัครรับเหตุการณ์ก่อนดึงเหตุการณ์ย้อนหลัง เพื่อไม่ให้พลาดเหตุการณ์ที่เกิดระหว่างนั้น
+	subscription := h.Broker.Subscribe(organizationID, filter)
+
+	c.Set("Content-Type", "text/event-stream")
+	c.Set("Cache-Control", "no-cache")
+	c.Set("Connection", "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer subscription.Close()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		// ให้ EventSource เชื่อมต่อใหม่หลัง 3 วินาทีถ้าการเชื่อมต่อหลุด
+		if _, err := fmt.Fprint(w, "retry: 3000\n\n"); err != nil {
+			return
+		}
+		if err := w.Flush(); err != nil {
+			return
+		}
+
+		// ส่งเหตุการณ์ที่พลาดไประหว่างการเชื่อมต่อหลุด
+		lastSent := lastEventID
+		if lastEventID != "" {
+			missed, err := h.Broker.Replay(ctx, organizationID, lastEventID, filter)
+			if err != nil {
+				log.Printf("ไม่สามารถดึงเหตุการณ์ย้อนหลังของ live stream: %v", err)
+			}
+			for _, event := range missed {
+				if err := writeEvent(w, event); err != nil {
+					return
+				}
+				lastSent = event.ID
+			}
+		}
+
+		heartbeat := time.NewTicker(h.Heartbeat)
+		defer heartbeat.Stop()
+
+		for {
+			select {
+			case event, ok := <-subscription.C:
+				if !ok {
+					// ผู้รับที่รับไม่ทันจะถูกปิด และเชื่อมต่อใหม่ด้วย Last-Event-ID
+					return
+				}
+				// ข้ามเหตุการณ์ที่ส่งไปแล้วจากการดึงย้อนหลัง
+				if lastSent != "" && events.CompareIDs(event.ID, lastSent) <= 0 {
+					continue
+				}
+				if err := writeEvent(w, event); err != nil {
+					return
+				}
+				lastSent = event.ID
+			case <-heartbeat.C:
+				// comment ของ SSE ใช้ตรวจว่าผู้รับยังเชื่อมต่ออยู่
+				if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+					return
+				}
+				if err := w.Flush(); err != nil {
+					return
+				}
+			}
+		}
+	})
+
+	return nil
+}
@@ -1,8 +1,11 @@
 package handlers
 
 import (
+	"fmt"
+	"strconv"
 	"time"
 
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/models"
 	"github.com/bemindtech/bmt-manta-dashboard-service/internal/services"
 	"github.com/gofiber/fiber/v2"
 )
@@ -19,17 +22,34 @@ func NewSummaryHandler(statsService *services.StatsService) *SummaryHandler {
 	}
 }
 
+// parseStatsScope แปลง query parameter include_staff ที่กำหนดให้นับรวมบุคคลที่มีป้ายกำกับ staff
+// และ saved_search ที่กำหนดให้นับเฉพาะบุคคลที่ตรงกับการค้นหาที่บันทึกไว้
+func parseStatsScope(c *fiber.Ctx) (models.StatsScope, error) {
+	scope := models.StatsScope{SavedSearchID: c.Query("saved_search")}
+	if includeStaff := c.Query("include_staff"); includeStaff != "" {
+		var err error
+		scope.IncludeStaff, err = strconv.ParseBool(includeStaff)
+		if err != nil {
+			return scope, fmt.Errorf("รูปแบบของพารามิเตอร์ include_staff ไม่ถูกต้อง")
+		}
+	}
+	return scope, nil
+}
+
 // GetDailySummary เป็น handler สำหรับดึงข้อมูลสรุปรายวัน
 // @Summary Get daily summary statistics
-// @Description Retrieve total, new, and returning people counts for the specified date
+// @Description Retrieve total, new, and returning people counts for the specified date. Persons labelled as staff are excluded unless include_staff=true.
 // @Tags summary
 // @Accept json
 // @Produce json
 // @Param date query string false "Date to retrieve data for (format YYYY-MM-DD). If not specified, current date will be used."
+// @Param include_staff query bool false "Also count persons labelled as staff" default(false)
+// @Param saved_search query string false "Only count persons matching this saved search"
 // @Security ApiKeyAuth
 // @Success 200 {object} models.DailySummary
 // @Failure 400 {object} ErrorResponse
 // @Failure 401 {object} ErrorResponse "Unauthorized (invalid or missing API key)"
+// @Failure 404 {object} ErrorResponse "Saved search not found"
 // @Failure 500 {object} ErrorResponse
 // @Router /api/summary [get]
 func (h *SummaryHandler) GetDailySummary(c *fiber.Ctx) error {
@@ -56,10 +76,17 @@ func (h *SummaryHandler) GetDailySummary(c *fiber.Ctx) error {
 		})
 	}
 
+	scope, err := parseStatsScope(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
 	// ดึงข้อมูลสรุปรายวัน
-	summary, err := h.StatsService.GetDailySummary(c.Context(), date, organizationID)
+	summary, err := h.StatsService.GetDailySummary(c.Context(), date, organizationID, scope)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		return c.Status(scopeErrorStatus(err, fiber.StatusInternalServerError)).JSON(fiber.Map{
 			"error": err.Error(),
 		})
 	}
@@ -69,15 +96,18 @@ func (h *SummaryHandler) GetDailySummary(c *fiber.Ctx) error {
 
 // GetHeatmap เป็น handler สำหรับดึงข้อมูลความหนาแน่นตามช่วงเวลา
 // @Summary Get heatmap data by time period
-// @Description Retrieve people count data by hour for the specified date
+// @Description Retrieve people count data by hour for the specified date. Persons labelled as staff are excluded unless include_staff=true.
 // @Tags summary
 // @Accept json
 // @Produce json
 // @Param date query string false "Date to retrieve data for (format YYYY-MM-DD). If not specified, current date will be used."
+// @Param include_staff query bool false "Also count persons labelled as staff" default(false)
+// @Param saved_search query string false "Only count persons matching this saved search"
 // @Security ApiKeyAuth
 // @Success 200 {array} models.HeatmapData
 // @Failure 400 {object} ErrorResponse
 // @Failure 401 {object} ErrorResponse "Unauthorized (invalid or missing API key)"
+// @Failure 404 {object} ErrorResponse "Saved search not found"
 // @Failure 500 {object} ErrorResponse
 // @Router /api/heatmap [get]
 func (h *SummaryHandler) GetHeatmap(c *fiber.Ctx) error {
@@ -104,10 +134,17 @@ func (h *SummaryHandler) GetHeatmap(c *fiber.Ctx) error {
 		})
 	}
 
+	scope, err := parseStatsScope(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
 	// ดึงข้อมูลความหนาแน่น
-	heatmap, err := h.StatsService.GetHeatmapData(c.Context(), date, organizationID)
+	heatmap, err := h.StatsService.GetHeatmapData(c.Context(), date, organizationID, scope)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		return c.Status(scopeErrorStatus(err, fiber.StatusInternalServerError)).JSON(fiber.Map{
 			"error": err.Error(),
 		})
 	}
@@ -117,15 +154,18 @@ func (h *SummaryHandler) GetHeatmap(c *fiber.Ctx) error {
 
 // GetPersonStats เป็น handler สำหรับดึงข้อมูลสถิติคนใหม่และคนซ้ำ
 // @Summary Get new vs. returning person statistics
-// @Description Retrieve statistics about new vs. returning people for the specified date
+// @Description Retrieve statistics about new vs. returning people for the specified date. Persons labelled as staff are excluded unless include_staff=true.
 // @Tags summary
 // @Accept json
 // @Produce json
 // @Param date query string false "Date to retrieve data for (format YYYY-MM-DD). If not specified, current date will be used."
+// @Param include_staff query bool false "Also count persons labelled as staff" default(false)
+// @Param saved_search query string false "Only count persons matching this saved search"
 // @Security ApiKeyAuth
 // @Success 200 {object} models.PersonStats
 // @Failure 400 {object} ErrorResponse
 // @Failure 401 {object} ErrorResponse "Unauthorized (invalid or missing API key)"
+// @Failure 404 {object} ErrorResponse "Saved search not found"
 // @Failure 500 {object} ErrorResponse
 // @Router /api/person-stats [get]
 func (h *SummaryHandler) GetPersonStats(c *fiber.Ctx) error {
@@ -152,10 +192,17 @@ func (h *SummaryHandler) GetPersonStats(c *fiber.Ctx) error {
 		})
 	}
 
+	scope, err := parseStatsScope(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
 	// ดึงข้อมูลสถิติ
-	stats, err := h.StatsService.GetPersonStats(c.Context(), date, organizationID)
+	stats, err := h.StatsService.GetPersonStats(c.Context(), date, organizationID, scope)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		return c.Status(scopeErrorStatus(err, fiber.StatusInternalServerError)).JSON(fiber.Map{
 			"error": err.Error(),
 		})
 	}
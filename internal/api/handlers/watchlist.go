@@ -0,0 +1,586 @@
+package handlers
+
+import (
+	"time"
+
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/models"
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+// WatchlistHandler เป็นโครงสร้างสำหรับจัดการ API endpoints เกี่ยวกับรายการเฝ้าระวังและการแจ้งเตือน
+type WatchlistHandler struct {
+	WatchlistService *services.WatchlistService
+}
+
+// NewWatchlistHandler สร้าง WatchlistHandler ใหม่
+func NewWatchlistHandler(watchlistService *services.WatchlistService) *WatchlistHandler {
+	return &WatchlistHandler{
+		WatchlistService: watchlistService,
+	}
+}
+
+// WatchlistRequest เป็นโครงสร้างข้อมูลสำหรับสร้างหรือแก้ไขรายการเฝ้าระวัง
+type WatchlistRequest struct {
+	Name        string `json:"name" example:"Known shoplifters"`
+	Description string `json:"description,omitempty" example:"Persons caught shoplifting at any branch"`
+}
+
+// WatchlistEntryRequest เป็นโครงสร้างข้อมูลสำหรับเพิ่มหรือแก้ไขบุคคลในรายการเฝ้าระวัง
+type WatchlistEntryRequest struct {
+	PersonHash string     `json:"person_hash,omitempty" example:"a1b2c3..."`
+	Reason     string     `json:"reason,omitempty" example:"Shoplifting at Siam branch on 2025-03-01"`
+	Priority   string     `json:"priority,omitempty" example:"high"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty" example:"2025-12-31T23:59:59Z"`
+}
+
+// ResolveAlertRequest เป็นโครงสร้างข้อมูลสำหรับปิดการแจ้งเตือน
+type ResolveAlertRequest struct {
+	Resolution string `json:"resolution,omitempty" example:"Security escorted the person out"`
+}
+
+// WatchlistAlertsResponse เป็นโครงสร้างข้อมูลสำหรับการตอบกลับรายการการแจ้งเตือนของรายการเฝ้าระวัง
+type WatchlistAlertsResponse struct {
+	Data       []models.WatchlistAlert  `json:"data"`
+	Pagination *models.CursorPagination `json:"pagination"`
+}
+
+// watchlistErrorStatus แปลงข้อผิดพลาดของรายการเฝ้าระวังเป็น HTTP status
+func watchlistErrorStatus(err error) int {
+	switch err.Error() {
+	case "ไม่พบรายการเฝ้าระวัง", "ไม่พบบุคคลในรายการเฝ้าระวัง", "ไม่พบข้อมูลบุคคล", "ไม่พบการแจ้งเตือน":
+		return fiber.StatusNotFound
+	case "ต้องระบุชื่อรายการเฝ้าระวัง",
+		"ต้องระบุรหัสบุคคล",
+		"priority ต้องเป็น low, medium, high หรือ critical",
+		"เวลาหมดอายุต้องอยู่ในอนาคต":
+		return fiber.StatusBadRequest
+	case "บุคคลนี้อยู่ในรายการเฝ้าระวังแล้ว", "การแจ้งเตือนนี้ถูกปิดแล้ว", "การแจ้งเตือนนี้ถูกรับทราบแล้ว":
+		return fiber.StatusConflict
+	}
+	return fiber.StatusInternalServerError
+}
+
+// GetWatchlists เป็น handler สำหรับดึงรายการเฝ้าระวังทั้งหมดขององค์กร
+// @Summary List watchlists
+// @Description Retrieve all watchlists of the organization
+// @Tags watchlists
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {array} models.Watchlist
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse "Unauthorized (invalid or missing API key)"
+// @Failure 500 {object} ErrorResponse
+// @Router /api/watchlists [get]
+func (h *WatchlistHandler) GetWatchlists(c *fiber.Ctx) error {
+	// ดึง organization ID จาก context
+	organizationID := c.Locals("organization_id").(string)
+	if organizationID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "ไม่พบข้อมูลองค์กร",
+		})
+	}
+
+	watchlists, err := h.WatchlistService.ListWatchlists(c.Context(), organizationID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(watchlists)
+}
+
+// CreateWatchlist เป็น handler สำหรับสร้างรายการเฝ้าระวังใหม่
+// @Summary Create a watchlist
+// @Description Create a watchlist such as known shoplifters or VIP customers
+// @Tags watchlists
+// @Accept json
+// @Produce json
+// @Param watchlist body WatchlistRequest true "Watchlist details"
+// @Security ApiKeyAuth
+// @Success 201 {object} models.Watchlist
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse "Unauthorized (invalid or missing API key)"
+// @Failure 500 {object} ErrorResponse
+// @Router /api/watchlists [post]
+func (h *WatchlistHandler) CreateWatchlist(c *fiber.Ctx) error {
+	// ดึง organization ID จาก context
+	organizationID := c.Locals("organization_id").(string)
+	if organizationID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "ไม่พบข้อมูลองค์กร",
+		})
+	}
+
+	var req WatchlistRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "รูปแบบข้อมูลไม่ถูกต้อง",
+		})
+	}
+
+	watchlist := models.Watchlist{
+		OrganizationID: organizationID,
+		Name:           req.Name,
+		Description:    req.Description,
+	}
+	if err := h.WatchlistService.CreateWatchlist(c.Context(), &watchlist); err != nil {
+		return c.Status(watchlistErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(watchlist)
+}
+
+// GetWatchlist เป็น handler สำหรับดึงรายการเฝ้าระวังตาม ID
+// @Summary Get a watchlist
+// @Description Retrieve a watchlist by ID
+// @Tags watchlists
+// @Accept json
+// @Produce json
+// @Param id path string true "Watchlist ID"
+// @Security ApiKeyAuth
+// @Success 200 {object} models.Watchlist
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse "Unauthorized (invalid or missing API key)"
+// @Failure 404 {object} ErrorResponse "Watchlist not found"
+// @Failure 500 {object} ErrorResponse
+// @Router /api/watchlists/{id} [get]
+func (h *WatchlistHandler) GetWatchlist(c *fiber.Ctx) error {
+	// ดึง organization ID จาก context
+	organizationID := c.Locals("organization_id").(string)
+	if organizationID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "ไม่พบข้อมูลองค์กร",
+		})
+	}
+
+	watchlist, err := h.WatchlistService.GetWatchlist(c.Context(), c.Params("id"), organizationID)
+	if err != nil {
+		return c.Status(watchlistErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(watchlist)
+}
+
+// UpdateWatchlist เป็น handler สำหรับแก้ไขรายการเฝ้าระวัง
+// @Summary Update a watchlist
+// @Description Update the name and description of a watchlist
+// @Tags watchlists
+// @Accept json
+// @Produce json
+// @Param id path string true "Watchlist ID"
+// @Param watchlist body WatchlistRequest true "Watchlist details"
+// @Security ApiKeyAuth
+// @Success 200 {object} models.Watchlist
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse "Unauthorized (invalid or missing API key)"
+// @Failure 404 {object} ErrorResponse "Watchlist not found"
+// @Failure 500 {object} ErrorResponse
+// @Router /api/watchlists/{id} [put]
+func (h *WatchlistHandler) UpdateWatchlist(c *fiber.Ctx) error {
+	// ดึง organization ID จาก context
+	organizationID := c.Locals("organization_id").(string)
+	if organizationID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "ไม่พบข้อมูลองค์กร",
+		})
+	}
+
+	var req WatchlistRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "รูปแบบข้อมูลไม่ถูกต้อง",
+		})
+	}
+
+	watchlist, err := h.WatchlistService.UpdateWatchlist(c.Context(), c.Params("id"), organizationID, req.Name, req.Description)
+	if err != nil {
+		return c.Status(watchlistErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(watchlist)
+}
+
+// DeleteWatchlist เป็น handler สำหรับลบรายการเฝ้าระวัง
+// @Summary Delete a watchlist
+// @Description Delete a watchlist and its entries. Alerts already raised are kept.
+// @Tags watchlists
+// @Accept json
+// @Produce json
+// @Param id path string true "Watchlist ID"
+// @Security ApiKeyAuth
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse "Unauthorized (invalid or missing API key)"
+// @Failure 404 {object} ErrorResponse "Watchlist not found"
+// @Failure 500 {object} ErrorResponse
+// @Router /api/watchlists/{id} [delete]
+func (h *WatchlistHandler) DeleteWatchlist(c *fiber.Ctx) error {
+	// ดึง organization ID จาก context
+	organizationID := c.Locals("organization_id").(string)
+	if organizationID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "ไม่พบข้อมูลองค์กร",
+		})
+	}
+
+	if err := h.WatchlistService.DeleteWatchlist(c.Context(), c.Params("id"), organizationID); err != nil {
+		return c.Status(watchlistErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "ลบรายการเฝ้าระวังสำเร็จ",
+	})
+}
+
+// GetEntries เป็น handler สำหรับดึงบุคคลในรายการเฝ้าระวัง
+// @Summary List watchlist entries
+// @Description Retrieve the persons on a watchlist, newest first. Expired entries are left out unless include_expired is true.
+// @Tags watchlists
+// @Accept json
+// @Produce json
+// @Param id path string true "Watchlist ID"
+// @Param include_expired query bool false "Also return expired entries"
+// @Security ApiKeyAuth
+// @Success 200 {array} models.WatchlistEntry
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse "Unauthorized (invalid or missing API key)"
+// @Failure 404 {object} ErrorResponse "Watchlist not found"
+// @Failure 500 {object} ErrorResponse
+// @Router /api/watchlists/{id}/entries [get]
+func (h *WatchlistHandler) GetEntries(c *fiber.Ctx) error {
+	// ดึง organization ID จาก context
+	organizationID := c.Locals("organization_id").(string)
+	if organizationID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "ไม่พบข้อมูลองค์กร",
+		})
+	}
+
+	entries, err := h.WatchlistService.ListEntries(c.Context(), c.Params("id"), organizationID, c.QueryBool("include_expired"))
+	if err != nil {
+		return c.Status(watchlistErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(entries)
+}
+
+// AddEntry เป็น handler สำหรับเพิ่มบุคคลในรายการเฝ้าระวัง
+// @Summary Add a person to a watchlist
+// @Description Add a person to a watchlist with a reason, a priority (low, medium, high, critical; default medium) and an optional expiry. Every detection of the person raises or updates an alert until the entry expires.
+// @Tags watchlists
+// @Accept json
+// @Produce json
+// @Param id path string true "Watchlist ID"
+// @Param entry body WatchlistEntryRequest true "Entry details"
+// @Security ApiKeyAuth
+// @Success 201 {object} models.WatchlistEntry
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse "Unauthorized (invalid or missing API key)"
+// @Failure 404 {object} ErrorResponse "Watchlist or person not found"
+// @Failure 409 {object} ErrorResponse "Person already on the watchlist"
+// @Failure 500 {object} ErrorResponse
+// @Router /api/watchlists/{id}/entries [post]
+func (h *WatchlistHandler) AddEntry(c *fiber.Ctx) error {
+	// ดึง organization ID จาก context
+	organizationID := c.Locals("organization_id").(string)
+	if organizationID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "ไม่พบข้อมูลองค์กร",
+		})
+	}
+
+	var req WatchlistEntryRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "รูปแบบข้อมูลไม่ถูกต้อง",
+		})
+	}
+
+	entry := models.WatchlistEntry{
+		OrganizationID: organizationID,
+		WatchlistID:    c.Params("id"),
+		PersonHash:     req.PersonHash,
+		Reason:         req.Reason,
+		Priority:       req.Priority,
+		ExpiresAt:      req.ExpiresAt,
+		CreatedBy:      requestAuthor(c),
+	}
+	if err := h.WatchlistService.AddEntry(c.Context(), &entry); err != nil {
+		return c.Status(watchlistErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(entry)
+}
+
+// UpdateEntry เป็น handler สำหรับแก้ไขบุคคลในรายการเฝ้าระวัง
+// @Summary Update a watchlist entry
+// @Description Replace the reason, priority and expiry of a watchlist entry. Omitting expires_at makes the entry never expire.
+// @Tags watchlists
+// @Accept json
+// @Produce json
+// @Param id path string true "Watchlist ID"
+// @Param entry_id path string true "Entry ID"
+// @Param entry body WatchlistEntryRequest true "Entry details"
+// @Security ApiKeyAuth
+// @Success 200 {object} models.WatchlistEntry
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse "Unauthorized (invalid or missing API key)"
+// @Failure 404 {object} ErrorResponse "Entry not found"
+// @Failure 500 {object} ErrorResponse
+// @Router /api/watchlists/{id}/entries/{entry_id} [put]
+func (h *WatchlistHandler) UpdateEntry(c *fiber.Ctx) error {
+	// ดึง organization ID จาก context
+	organizationID := c.Locals("organization_id").(string)
+	if organizationID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "ไม่พบข้อมูลองค์กร",
+		})
+	}
+
+	var req WatchlistEntryRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "รูปแบบข้อมูลไม่ถูกต้อง",
+		})
+	}
+
+	entry, err := h.WatchlistService.UpdateEntry(c.Context(), c.Params("id"), c.Params("entry_id"), organizationID, models.WatchlistEntry{
+		Reason:    req.Reason,
+		Priority:  req.Priority,
+		ExpiresAt: req.ExpiresAt,
+	})
+	if err != nil {
+		return c.Status(watchlistErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(entry)
+}
+
+// RemoveEntry เป็น handler สำหรับนำบุคคลออกจากรายการเฝ้าระวัง
+// @Summary Remove a person from a watchlist
+// @Description Remove an entry from a watchlist. Alerts already raised are kept.
+// @Tags watchlists
+// @Accept json
+// @Produce json
+// @Param id path string true "Watchlist ID"
+// @Param entry_id path string true "Entry ID"
+// @Security ApiKeyAuth
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse "Unauthorized (invalid or missing API key)"
+// @Failure 404 {object} ErrorResponse "Entry not found"
+// @Failure 500 {object} ErrorResponse
+// @Router /api/watchlists/{id}/entries/{entry_id} [delete]
+func (h *WatchlistHandler) RemoveEntry(c *fiber.Ctx) error {
+	// ดึง organization ID จาก context
+	organizationID := c.Locals("organization_id").(string)
+	if organizationID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "ไม่พบข้อมูลองค์กร",
+		})
+	}
+
+	if err := h.WatchlistService.RemoveEntry(c.Context(), c.Params("id"), c.Params("entry_id"), organizationID); err != nil {
+		return c.Status(watchlistErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "นำบุคคลออกจากรายการเฝ้าระวังสำเร็จ",
+	})
+}
+
+// ListAlerts เป็น handler สำหรับดึงการแจ้งเตือนของรายการเฝ้าระวัง
+// @Summary List watchlist alerts
+// @Description Retrieve alerts raised when watchlisted persons were detected, with cursor pagination
+// @Tags watchlists
+// @Accept json
+// @Produce json
+// @Param status query []string false "Only alerts with any of these statuses (open, acknowledged, resolved)" collectionFormat(multi)
+// @Param watchlist_id query []string false "Only alerts of these watchlists" collectionFormat(multi)
+// @Param person_hash query []string false "Only alerts of these persons" collectionFormat(multi)
+// @Param camera_id query []string false "Only alerts first or last seen at these cameras" collectionFormat(multi)
+// @Param priority query []string false "Only alerts with any of these priorities (low, medium, high, critical)" collectionFormat(multi)
+// @Param cursor query string false "Cursor from pagination.next_cursor of the previous page"
+// @Param limit query int false "Number of items per page (max 100)" default(10)
+// @Param sort query string false "Sort field (detected_at, last_seen_at)" default(detected_at)
+// @Param order query string false "Sort order (asc, desc)" default(desc)
+// @Param fields query string false "Comma-separated fields to return"
+// @Param include_total query bool false "Also count the total number of items (slower)"
+// @Security ApiKeyAuth
+// @Success 200 {object} WatchlistAlertsResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse "Unauthorized (invalid or missing API key)"
+// @Failure 500 {object} ErrorResponse
+// @Router /api/watchlist-alerts [get]
+func (h *WatchlistHandler) ListAlerts(c *fiber.Ctx) error {
+	// ดึง organization ID จาก context
+	organizationID := c.Locals("organization_id").(string)
+	if organizationID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "ไม่พบข้อมูลองค์กร",
+		})
+	}
+
+	opts, err := parseListOptions(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	filter := models.WatchlistAlertFilter{
+		Statuses:     queryValues(c, "status"),
+		WatchlistIDs: queryValues(c, "watchlist_id"),
+		PersonHashes: queryValues(c, "person_hash"),
+		CameraIDs:    queryValues(c, "camera_id"),
+		Priorities:   queryValues(c, "priority"),
+	}
+
+	alerts, pagination, err := h.WatchlistService.ListAlerts(c.Context(), organizationID, filter, opts)
+	if err != nil {
+		return c.Status(listErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	if len(opts.Fields) > 0 {
+		return projectedResponse(c, alerts, pagination, opts.Fields)
+	}
+
+	return c.JSON(WatchlistAlertsResponse{
+		Data:       alerts,
+		Pagination: pagination,
+	})
+}
+
+// GetAlert เป็น handler สำหรับดึงการแจ้งเตือนของรายการเฝ้าระวังตาม ID
+// @Summary Get a watchlist alert
+// @Description Retrieve a watchlist alert by ID
+// @Tags watchlists
+// @Accept json
+// @Produce json
+// @Param id path string true "Alert ID"
+// @Security ApiKeyAuth
+// @Success 200 {object} models.WatchlistAlert
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse "Unauthorized (invalid or missing API key)"
+// @Failure 404 {object} ErrorResponse "Alert not found"
+// @Failure 500 {object} ErrorResponse
+// @Router /api/watchlist-alerts/{id} [get]
+func (h *WatchlistHandler) GetAlert(c *fiber.Ctx) error {
+	// ดึง organization ID จาก context
+	organizationID := c.Locals("organization_id").(string)
+	if organizationID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "ไม่พบข้อมูลองค์กร",
+		})
+	}
+
+	watchlistAlert, err := h.WatchlistService.GetAlert(c.Context(), c.Params("id"), organizationID)
+	if err != nil {
+		return c.Status(watchlistErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(watchlistAlert)
+}
+
+// AcknowledgeAlert เป็น handler สำหรับรับทราบการแจ้งเตือน
+// @Summary Acknowledge a watchlist alert
+// @Description Mark an open alert as acknowledged by the caller. Further detections within the cooldown still update the alert.
+// @Tags watchlists
+// @Accept json
+// @Produce json
+// @Param id path string true "Alert ID"
+// @Security ApiKeyAuth
+// @Success 200 {object} models.WatchlistAlert
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse "Unauthorized (invalid or missing API key)"
+// @Failure 404 {object} ErrorResponse "Alert not found"
+// @Failure 409 {object} ErrorResponse "Alert already acknowledged or resolved"
+// @Failure 500 {object} ErrorResponse
+// @Router /api/watchlist-alerts/{id}/acknowledge [post]
+func (h *WatchlistHandler) AcknowledgeAlert(c *fiber.Ctx) error {
+	// ดึง organization ID จาก context
+	organizationID := c.Locals("organization_id").(string)
+	if organizationID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "ไม่พบข้อมูลองค์กร",
+		})
+	}
+
+	watchlistAlert, err := h.WatchlistService.AcknowledgeAlert(c.Context(), c.Params("id"), organizationID, requestAuthor(c))
+	if err != nil {
+		return c.Status(watchlistErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(watchlistAlert)
+}
+
+// ResolveAlert เป็น handler สำหรับปิดการแจ้งเตือน
+// @Summary Resolve a watchlist alert
+// @Description Close an open or acknowledged alert with an optional resolution note. The next detection of the person raises a new alert.
+// @Tags watchlists
+// @Accept json
+// @Produce json
+// @Param id path string true "Alert ID"
+// @Param resolution body ResolveAlertRequest false "Resolution"
+// @Security ApiKeyAuth
+// @Success 200 {object} models.WatchlistAlert
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse "Unauthorized (invalid or missing API key)"
+// @Failure 404 {object} ErrorResponse "Alert not found"
+// @Failure 409 {object} ErrorResponse "Alert already resolved"
+// @Failure 500 {object} ErrorResponse
+// @Router /api/watchlist-alerts/{id}/resolve [post]
+func (h *WatchlistHandler) ResolveAlert(c *fiber.Ctx) error {
+	// ดึง organization ID จาก context
+	organizationID := c.Locals("organization_id").(string)
+	if organizationID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "ไม่พบข้อมูลองค์กร",
+		})
+	}
+
+	var req ResolveAlertRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "รูปแบบข้อมูลไม่ถูกต้อง",
+			})
+		}
+	}
+
+	watchlistAlert, err := h.WatchlistService.ResolveAlert(c.Context(), c.Params("id"), organizationID, requestAuthor(c), req.Resolution)
+	if err != nil {
+		return c.Status(watchlistErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(watchlistAlert)
+}
@@ -183,6 +183,7 @@ func NewAPIKeyWithOrgMiddleware(postgres *db.PostgresDB) fiber.Handler {
 
 		// เก็บข้อมูลองค์กรไว้ใน context
 		c.Locals("organization_id", apiKeyRecord.OrganizationID)
+		c.Locals("api_key_id", apiKeyRecord.ID)
 
 		return c.Next()
 	}
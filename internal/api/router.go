@@ -5,6 +5,7 @@ import (
 	"github.com/bemindtech/bmt-manta-dashboard-service/internal/api/handlers"
 	"github.com/bemindtech/bmt-manta-dashboard-service/internal/api/middleware"
 	"github.com/bemindtech/bmt-manta-dashboard-service/internal/db"
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/events"
 	"github.com/bemindtech/bmt-manta-dashboard-service/internal/services"
 	"github.com/bemindtech/bmt-manta-dashboard-service/internal/storage"
 	"github.com/gofiber/fiber/v2"
@@ -14,14 +15,14 @@ import (
 )
 
 // SetupRoutes ตั้งค่าเส้นทาง API ทั้งหมด
-func SetupRoutes(app *fiber.App, cfg *config.Config, postgres *db.PostgresDB, statsService *services.StatsService) {
+func SetupRoutes(app *fiber.App, cfg *config.Config, postgres *db.PostgresDB, storageService storage.StorageService, jobService *services.JobService, imageURLs *services.ImageURLSigner, statsService *services.StatsService, anomalyService *services.AnomalyService, forecastService *services.ForecastService, occupancyService *services.OccupancyService, watchlistService *services.WatchlistService, faceIndex *services.FaceIndex, broker *events.Broker) {
 	// ใช้ middleware พื้นฐาน
 	app.Use(recover.New())
 	app.Use(logger.New())
 	app.Use(cors.New(cors.Config{
 		AllowOrigins:     "http://localhost:3000,http://localhost:8080", // อนุญาตเฉพาะ origins ที่กำหนด
 		AllowMethods:     "GET,POST,HEAD,PUT,DELETE,PATCH",
-		AllowHeaders:     "Origin, Content-Type, Accept, Authorization, X-API-Key",
+		AllowHeaders:     "Origin, Content-Type, Accept, Authorization, X-API-Key, Last-Event-ID",
 		ExposeHeaders:    "Content-Length",
 		AllowCredentials: false, // ปิดการใช้งาน credentials เพื่อความปลอดภัย
 		MaxAge:           3600,  // cache preflight requests for 1 hour
@@ -29,26 +30,44 @@ func SetupRoutes(app *fiber.App, cfg *config.Config, postgres *db.PostgresDB, st
 
 	// สร้าง services
 	organizationService := services.NewOrganizationService(postgres)
-	cameraService := services.NewCameraService(postgres)
-	storageService, err := storage.NewStorageService(cfg)
-	if err != nil {
+	cameraService := services.NewCameraService(postgres, broker)
+	siteService := services.NewSiteService(postgres)
+	if storageService == nil {
 		app.Use(func(c *fiber.Ctx) error {
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": "ไม่สามารถเริ่มต้นบริการจัดเก็บข้อมูล: " + err.Error(),
+				"error": "ไม่สามารถเริ่มต้นบริการจัดเก็บข้อมูล",
 			})
 		})
 		return
 	}
-	faceService := services.NewFaceService(postgres, storageService)
-	personService := services.NewPersonService(postgres)
+	faceService := services.NewFaceService(postgres, storageService, jobService, imageURLs, cfg.ThumbnailSizes, cfg.UploadIntentTTL, cfg.FaceBatchConcurrency, faceIndex)
+	personService := services.NewPersonService(postgres, statsService, imageURLs)
+	journeyService := services.NewJourneyService(postgres, imageURLs, cfg.JourneyVisitGap)
+	labelService := services.NewLabelService(postgres, statsService)
+	segmentService := services.NewSegmentService(postgres)
+	searchService := services.NewSearchService(postgres)
+	exportService := services.NewExportService(postgres, storageService, jobService)
+	rebuildService := services.NewRebuildService(postgres, jobService, statsService)
 
 	// สร้าง handlers
 	summaryHandler := handlers.NewSummaryHandler(statsService)
 	logsHandler := handlers.NewLogsHandler(statsService)
+	exportHandler := handlers.NewExportHandler(exportService)
+	rebuildHandler := handlers.NewRebuildHandler(rebuildService)
 	organizationHandler := handlers.NewOrganizationHandler(organizationService)
 	cameraHandler := handlers.NewCameraHandler(cameraService)
+	siteHandler := handlers.NewSiteHandler(siteService)
 	faceHandler := handlers.NewFaceHandler(faceService)
 	personHandler := handlers.NewPersonHandler(personService)
+	journeyHandler := handlers.NewJourneyHandler(journeyService)
+	labelHandler := handlers.NewLabelHandler(labelService)
+	segmentHandler := handlers.NewSegmentHandler(segmentService)
+	anomalyHandler := handlers.NewAnomalyHandler(anomalyService)
+	forecastHandler := handlers.NewForecastHandler(forecastService)
+	occupancyHandler := handlers.NewOccupancyHandler(occupancyService)
+	watchlistHandler := handlers.NewWatchlistHandler(watchlistService)
+	searchHandler := handlers.NewSearchHandler(searchService)
+	streamHandler := handlers.NewStreamHandler(broker, cfg.StreamHeartbeat)
 
 	// กำหนดเส้นทาง API
 	api := app.Group("/api")
@@ -59,6 +78,11 @@ func SetupRoutes(app *fiber.App, cfg *config.Config, postgres *db.PostgresDB, st
 	// สร้าง middleware สำหรับตรวจสอบการเข้าถึงข้อมูลขององค์กร
 	orgAuthMiddleware := middleware.NewOrganizationAuthMiddleware()
 
+	// ไฟล์รูปภาพใบหน้าและการอัปโหลดไปยัง storage ในเครื่องใช้ URL ที่มีลายเซ็นและอายุจำกัดแทน API key
+	// จึงต้องลงทะเบียนก่อนเส้นทางที่ต้องการ API key และใช้ prefix ของตัวเองเพื่อไม่ทับเส้นทางใต้ /faces
+	api.Get("/files/:organization_id/+", faceHandler.ServeFaceImage)
+	api.Put("/files/:organization_id/+", faceHandler.ReceiveUpload)
+
 	// เส้นทางที่ต้องการ API Key
 	apiKeyProtected := api.Group("/", apiKeyWithOrgMiddleware)
 
@@ -67,6 +91,70 @@ func SetupRoutes(app *fiber.App, cfg *config.Config, postgres *db.PostgresDB, st
 	apiKeyProtected.Get("/heatmap", summaryHandler.GetHeatmap)
 	apiKeyProtected.Get("/person-stats", summaryHandler.GetPersonStats)
 	apiKeyProtected.Get("/logs", logsHandler.GetLogs)
+	apiKeyProtected.Get("/logs/export", exportHandler.ExportLogs)
+	apiKeyProtected.Post("/logs/export/jobs", exportHandler.CreateExportJob)
+	apiKeyProtected.Get("/logs/export/jobs", exportHandler.ListExportJobs)
+	apiKeyProtected.Get("/logs/export/jobs/:id", exportHandler.GetExportJob)
+	apiKeyProtected.Get("/logs/export/jobs/:id/download", exportHandler.DownloadExport)
+	apiKeyProtected.Get("/anomalies", anomalyHandler.GetAnomalies)
+	apiKeyProtected.Get("/stats/forecast", forecastHandler.GetForecast)
+	apiKeyProtected.Get("/stream", streamHandler.Stream)
+	apiKeyProtected.Get("/labels", labelHandler.GetLabelCounts)
+
+	// ตั้งค่าเส้นทาง API สำหรับประวัติการรวมและแยกบุคคล
+	personOperations := apiKeyProtected.Group("/person-operations")
+	personOperations.Get("/", personHandler.ListOperations)
+	personOperations.Get("/:id", personHandler.GetOperation)
+	personOperations.Post("/:id/undo", personHandler.UndoOperation)
+
+	// ตั้งค่าเส้นทาง API สำหรับรายการเฝ้าระวังและการแจ้งเตือน
+	watchlists := apiKeyProtected.Group("/watchlists")
+	watchlists.Get("/", watchlistHandler.GetWatchlists)
+	watchlists.Post("/", watchlistHandler.CreateWatchlist)
+	watchlists.Get("/:id", watchlistHandler.GetWatchlist)
+	watchlists.Put("/:id", watchlistHandler.UpdateWatchlist)
+	watchlists.Delete("/:id", watchlistHandler.DeleteWatchlist)
+	watchlists.Get("/:id/entries", watchlistHandler.GetEntries)
+	watchlists.Post("/:id/entries", watchlistHandler.AddEntry)
+	watchlists.Put("/:id/entries/:entry_id", watchlistHandler.UpdateEntry)
+	watchlists.Delete("/:id/entries/:entry_id", watchlistHandler.RemoveEntry)
+
+	watchlistAlerts := apiKeyProtected.Group("/watchlist-alerts")
+	watchlistAlerts.Get("/", watchlistHandler.ListAlerts)
+	watchlistAlerts.Get("/:id", watchlistHandler.GetAlert)
+	watchlistAlerts.Post("/:id/acknowledge", watchlistHandler.AcknowledgeAlert)
+	watchlistAlerts.Post("/:id/resolve", watchlistHandler.ResolveAlert)
+
+	// ตั้งค่าเส้นทาง API สำหรับงานดูแลระบบ: สร้างข้อมูลที่คำนวณจาก logs ใหม่
+	rebuilds := apiKeyProtected.Group("/admin/rebuilds")
+	rebuilds.Get("/", rebuildHandler.ListRebuildJobs)
+	rebuilds.Post("/", rebuildHandler.CreateRebuildJob)
+	rebuilds.Get("/:id", rebuildHandler.GetRebuildJob)
+	rebuilds.Post("/:id/resume", rebuildHandler.ResumeRebuildJob)
+
+	// ตั้งค่าเส้นทาง API สำหรับงานดูแลระบบ: สร้างรูปย่อของรูปภาพใบหน้าเดิม
+	thumbnailBackfills := apiKeyProtected.Group("/admin/thumbnail-backfills")
+	thumbnailBackfills.Get("/", faceHandler.ListThumbnailBackfillJobs)
+	thumbnailBackfills.Post("/", faceHandler.CreateThumbnailBackfillJob)
+	thumbnailBackfills.Get("/:id", faceHandler.GetThumbnailBackfillJob)
+
+	// ตั้งค่าเส้นทาง API สำหรับงานดูแลระบบ: รวมรูปภาพใบหน้าเดิมที่ซ้ำกัน
+	faceDedups := apiKeyProtected.Group("/admin/face-dedups")
+	faceDedups.Get("/", faceHandler.ListFaceDedupJobs)
+	faceDedups.Post("/", faceHandler.CreateFaceDedupJob)
+	faceDedups.Get("/:id", faceHandler.GetFaceDedupJob)
+
+	// ตั้งค่าเส้นทาง API สำหรับงานดูแลระบบ: เปรียบเทียบไฟล์ใน storage กับข้อมูลรูปภาพใบหน้า
+	storageReconciliations := apiKeyProtected.Group("/admin/storage-reconciliations")
+	storageReconciliations.Get("/", faceHandler.ListStorageReconcileJobs)
+	storageReconciliations.Post("/", faceHandler.CreateStorageReconcileJob)
+	storageReconciliations.Get("/:id", faceHandler.GetStorageReconcileJob)
+
+	// ตั้งค่าเส้นทาง API สำหรับจัดการวันหยุดที่ใช้ในการพยากรณ์
+	holidays := apiKeyProtected.Group("/holidays")
+	holidays.Get("/", forecastHandler.GetHolidays)
+	holidays.Post("/", forecastHandler.CreateHoliday)
+	holidays.Delete("/:id", forecastHandler.DeleteHoliday)
 
 	// ตั้งค่าเส้นทาง API สำหรับจัดการองค์กร
 	organizations := apiKeyProtected.Group("/organizations")
@@ -84,19 +172,71 @@ func SetupRoutes(app *fiber.App, cfg *config.Config, postgres *db.PostgresDB, st
 	cameras.Put("/:id", cameraHandler.UpdateCamera)
 	cameras.Delete("/:id", cameraHandler.DeleteCamera)
 
+	// ตั้งค่าเส้นทาง API สำหรับจัดการสาขา
+	sites := apiKeyProtected.Group("/sites")
+	sites.Get("/", siteHandler.GetSites)
+	sites.Post("/", siteHandler.CreateSite)
+	sites.Get("/:id", siteHandler.GetSite)
+	sites.Put("/:id", siteHandler.UpdateSite)
+	sites.Delete("/:id", siteHandler.DeleteSite)
+	sites.Get("/:id/occupancy", occupancyHandler.GetCurrent)
+	sites.Get("/:id/occupancy/series", occupancyHandler.GetTimeSeries)
+	sites.Get("/:id/occupancy/peaks", occupancyHandler.GetPeaks)
+	sites.Get("/:id/occupancy/events", occupancyHandler.ListEvents)
+	sites.Get("/:id/thresholds", occupancyHandler.ListThresholds)
+	sites.Post("/:id/thresholds", occupancyHandler.CreateThreshold)
+	sites.Delete("/:id/thresholds/:threshold_id", occupancyHandler.DeleteThreshold)
+
 	// ตั้งค่าเส้นทาง API สำหรับจัดการรูปภาพใบหน้า
 	faces := apiKeyProtected.Group("/faces")
 	faces.Post("/", faceHandler.UploadFaceImage)
+	faces.Get("/settings", faceHandler.GetUploadSettings)
+	faces.Post("/search", faceHandler.SearchFaces)
+	faces.Post("/upload-intents", faceHandler.CreateUploadIntent)
+	faces.Post("/upload-intents/:id/complete", faceHandler.CompleteUploadIntent)
+	faces.Get("/batches", faceHandler.ListFaceBatchUploadJobs)
+	faces.Post("/batches", faceHandler.CreateFaceBatchUploadJob)
+	faces.Get("/batches/:id", faceHandler.GetFaceBatchUploadJob)
+	faces.Put("/settings", faceHandler.UpdateUploadSettings)
 	faces.Get("/:person_hash", faceHandler.GetFaceImages)
 	faces.Delete("/image/:id", faceHandler.DeleteFaceImage)
 	
 	// ตั้งค่าเส้นทาง API สำหรับจัดการข้อมูลบุคคล
 	persons := apiKeyProtected.Group("/persons")
 	persons.Get("/", personHandler.ListPersons)
+	persons.Get("/facets", personHandler.GetPersonFacets)
 	persons.Get("/:person_hash", personHandler.GetPerson)
 	persons.Get("/:person_hash/stats", personHandler.GetPersonStats)
+	persons.Get("/:person_hash/journey", journeyHandler.GetJourney)
+	persons.Get("/:person_hash/labels", labelHandler.GetLabels)
+	persons.Post("/:person_hash/labels", labelHandler.AddLabel)
+	persons.Delete("/:person_hash/labels/:label", labelHandler.RemoveLabel)
+	persons.Get("/:person_hash/notes", labelHandler.GetNotes)
+	persons.Post("/:person_hash/notes", labelHandler.AddNote)
+	persons.Delete("/:person_hash/notes/:note_id", labelHandler.DeleteNote)
+	persons.Post("/:person_hash/merge", personHandler.MergePerson)
+	persons.Post("/:person_hash/split", personHandler.SplitPerson)
+	persons.Get("/:person_hash/operations", personHandler.ListPersonOperations)
+	persons.Put("/:person_hash/primary-face-image", personHandler.SetPrimaryFaceImage)
+	persons.Delete("/:person_hash/primary-face-image", personHandler.UnpinPrimaryFaceImage)
 	persons.Delete("/:person_hash", personHandler.DeletePerson)
 
+	// ตั้งค่าเส้นทาง API สำหรับการค้นหาบุคคลที่บันทึกไว้
+	savedSearches := apiKeyProtected.Group("/saved-searches")
+	savedSearches.Get("/", searchHandler.GetSavedSearches)
+	savedSearches.Post("/", searchHandler.CreateSavedSearch)
+	savedSearches.Get("/:id", searchHandler.GetSavedSearch)
+	savedSearches.Put("/:id", searchHandler.UpdateSavedSearch)
+	savedSearches.Delete("/:id", searchHandler.DeleteSavedSearch)
+
+	// ตั้งค่าเส้นทาง API สำหรับการแบ่งกลุ่มผู้เข้าชม
+	segments := apiKeyProtected.Group("/segments")
+	segments.Get("/", segmentHandler.GetDistribution)
+	segments.Get("/trend", segmentHandler.GetTrend)
+	segments.Get("/settings", segmentHandler.GetSettings)
+	segments.Put("/settings", segmentHandler.UpdateSettings)
+	segments.Get("/:segment/persons", segmentHandler.ListSegmentMembers)
+
 	// เส้นทางสำหรับตรวจสอบสถานะ API
 	// @Summary Check API health
 	// @Description Returns the health status of the API
@@ -0,0 +1,112 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/bemindtech/bmt-manta-dashboard-service/config"
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/db"
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/events"
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/models"
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/services"
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/storage"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newTestApp สร้าง app ด้วย SetupRoutes ที่ใช้ sqlmock และ storage ในเครื่องชั่วคราว
+func newTestApp(t *testing.T) (*fiber.App, sqlmock.Sqlmock, *storage.LocalStorageService) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { sqlDB.Close() })
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{Logger: logger.Discard})
+	require.NoError(t, err)
+
+	store, err := storage.NewLocalStorageService(t.TempDir(), "http://localhost:8080/api/files", []byte("test-signing-key"))
+	require.NoError(t, err)
+
+	app := fiber.New()
+	postgresDB := &db.PostgresDB{DB: gormDB}
+	SetupRoutes(app, &config.Config{}, postgresDB, store, services.NewJobService(postgresDB, 1),
+		services.NewImageURLSigner(store, time.Hour), nil, nil, nil, nil, nil, services.NewFaceIndex(nil, 0), events.NewBroker(nil, 10))
+	return app, mock, store
+}
+
+// expectAPIKey ตั้งให้ middleware พบ API key ขององค์กร
+func expectAPIKey(mock sqlmock.Sqlmock, organizationID string) {
+	mock.ExpectQuery(`SELECT \* FROM "api_keys" WHERE key_value = \$1`).
+		WithArgs("test-api-key", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "organization_id"}).AddRow("key-1", organizationID))
+}
+
+// TestSetupRoutes_FaceBatchJob ทดสอบว่า GET /api/faces/batches/:id ถึง handler ของงานอัปโหลดแบบกลุ่ม ไม่ใช่เส้นทางของไฟล์ที่มีลายเซ็น
+func TestSetupRoutes_FaceBatchJob(t *testing.T) {
+	app, mock, _ := newTestApp(t)
+
+	expectAPIKey(mock, "org-1")
+	mock.ExpectQuery(`SELECT \* FROM "jobs" WHERE \(id = \$1 AND organization_id = \$2\)`).
+		WithArgs("job-1", "org-1", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "organization_id", "type", "status"}).
+			AddRow("job-1", "org-1", models.JobTypeFaceBatchUpload, models.JobCompleted))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/faces/batches/job-1", nil)
+	req.Header.Set("X-API-Key", "test-api-key")
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var job models.Job
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&job))
+	assert.Equal(t, "job-1", job.ID)
+	assert.Equal(t, models.JobTypeFaceBatchUpload, job.Type)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	// ไม่มี API key ได้ 401 จากเส้นทางที่ต้องการ API key ไม่ใช่ 403 จากการตรวจลายเซ็น
+	resp, err = app.Test(httptest.NewRequest(http.MethodGet, "/api/faces/batches/job-1", nil))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
+
+// TestSetupRoutes_SignedFiles ทดสอบว่าไฟล์ใน storage ในเครื่องอ่านและเขียนได้ด้วย URL ที่มีลายเซ็นโดยไม่ต้องมี API key
+func TestSetupRoutes_SignedFiles(t *testing.T) {
+	app, mock, store := newTestApp(t)
+	ctx := context.Background()
+	content := []byte("face image")
+	require.NoError(t, store.PutObject(ctx, "org-1/face.jpg", bytes.NewReader(content), int64(len(content)), "image/jpeg"))
+
+	requestURI := func(signedURL string, err error) string {
+		require.NoError(t, err)
+		parsed, err := url.Parse(signedURL)
+		require.NoError(t, err)
+		return parsed.RequestURI()
+	}
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, requestURI(store.SignedURL("org-1/face.jpg", time.Hour)), nil))
+	require.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, content, body)
+
+	req := httptest.NewRequest(http.MethodPut, requestURI(store.SignedUploadURL("org-1/uploads/intent-1", "image/jpeg", int64(len(content)), time.Hour)), bytes.NewReader(content))
+	req.Header.Set("Content-Type", "image/jpeg")
+	resp, err = app.Test(req)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	_, err = store.StatObject(ctx, "org-1/uploads/intent-1")
+	assert.NoError(t, err)
+
+	// ไม่มีการค้นหา API key ในฐานข้อมูล
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
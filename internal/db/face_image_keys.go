@@ -0,0 +1,72 @@
+package db
+
+import (
+	"fmt"
+	"log"
+
+	"gorm.io/gorm"
+)
+
+// urlObjectKey extracts the object key from a stored face image URL column.
+// Local and S3 URLs both end with the key, which starts with the organization ID.
+func urlObjectKey(column string) string {
+	return fmt.Sprintf(`substring(%[1]s from position('/' || organization_id || '/' in %[1]s) + 1)`, column)
+}
+
+// migrateFaceImageKeys replaces the permanent image URLs stored before signed URLs with object keys
+// and drops the URL columns, so no public-style URL of biometric data remains in the database.
+// It runs after AutoMigrate has added the key columns and only once: afterwards face_images.image_url no longer exists.
+func (p *PostgresDB) migrateFaceImageKeys() error {
+	migrator := p.DB.Migrator()
+	if !migrator.HasColumn("face_images", "image_url") {
+		return nil
+	}
+
+	log.Println("กำลังย้าย URL ของรูปภาพใบหน้าที่บันทึกไว้ไปใช้ object key")
+
+	return p.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(`
+			UPDATE face_images SET image_key = ` + urlObjectKey("image_url") + `
+			WHERE image_key IS NULL AND position('/' || organization_id || '/' in image_url) > 0
+		`).Error; err != nil {
+			return fmt.Errorf("ไม่สามารถแปลง URL ของรูปภาพเป็น object key: %w", err)
+		}
+
+		if migrator.HasColumn("face_images", "thumbnails") {
+			if err := tx.Exec(`
+				UPDATE face_images SET thumbnail_keys = (
+					SELECT jsonb_object_agg(key, ` + urlObjectKey("value") + `)
+					FROM jsonb_each_text(thumbnails)
+				)
+				WHERE thumbnail_keys IS NULL AND jsonb_typeof(thumbnails) = 'object' AND thumbnails <> '{}'::jsonb
+			`).Error; err != nil {
+				return fmt.Errorf("ไม่สามารถแปลง URL ของรูปย่อเป็น object key: %w", err)
+			}
+		}
+
+		if migrator.HasColumn("watchlist_alerts", "face_image_url") {
+			if err := tx.Exec(`
+				UPDATE watchlist_alerts SET face_image_key = ` + urlObjectKey("face_image_url") + `
+				WHERE face_image_key IS NULL AND position('/' || organization_id || '/' in face_image_url) > 0
+			`).Error; err != nil {
+				return fmt.Errorf("ไม่สามารถแปลง URL ของรูปภาพในการแจ้งเตือนเป็น object key: %w", err)
+			}
+		}
+
+		for _, column := range []struct {
+			Table  string
+			Column string
+		}{
+			{"face_images", "image_url"},
+			{"face_images", "thumbnail_url"},
+			{"face_images", "thumbnails"},
+			{"watchlist_alerts", "face_image_url"},
+		} {
+			if err := tx.Exec(fmt.Sprintf(`ALTER TABLE %s DROP COLUMN IF EXISTS %s`, column.Table, column.Column)).Error; err != nil {
+				return fmt.Errorf("ไม่สามารถลบคอลัมน์ %s.%s: %w", column.Table, column.Column, err)
+			}
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,123 @@
+package db
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/models"
+	"gorm.io/gorm"
+)
+
+// personHashConstraints are the foreign keys GORM created on person_hash alone while persons.person_hash was globally unique.
+// AutoMigrate recreates them on (organization_id, person_hash) after they are dropped.
+var personHashConstraints = []struct {
+	Table string
+	Name  string
+}{
+	{"person_logs", "fk_persons_person_logs"},
+	{"face_images", "fk_persons_face_images"},
+	{"person_labels", "fk_persons_labels"},
+	{"persons", "fk_person_logs_person"},
+	{"persons", "fk_face_images_person"},
+}
+
+// collidingHashes selects person hashes used by more than one organization
+const collidingHashes = `
+	SELECT person_hash FROM (
+		SELECT organization_id, person_hash FROM persons
+		UNION SELECT organization_id, person_hash FROM person_logs
+		UNION SELECT organization_id, person_hash FROM face_images
+	) ids
+	GROUP BY person_hash
+	HAVING COUNT(DISTINCT organization_id) > 1`
+
+// migratePersonIdentity moves persons from a globally unique person_hash to a per-organization (organization_id, person_hash) identity.
+// Organizations that recorded logs or face images under a hash owned by another organization get their own person,
+// and the derived fields of every colliding hash are recomputed from the organization's own logs.
+// It runs before AutoMigrate and only once: afterwards idx_person_identity exists.
+func (p *PostgresDB) migratePersonIdentity() error {
+	migrator := p.DB.Migrator()
+	if !migrator.HasTable(&models.Person{}) || migrator.HasIndex(&models.Person{}, "idx_person_identity") {
+		return nil
+	}
+
+	log.Println("กำลังย้ายข้อมูลบุคคลไปใช้รหัสบุคคลแยกตามองค์กร")
+
+	return p.DB.Transaction(func(tx *gorm.DB) error {
+		// The old foreign keys depend on the global unique index, so drop them before the index
+		for _, constraint := range personHashConstraints {
+			if err := tx.Exec(fmt.Sprintf(`ALTER TABLE IF EXISTS %s DROP CONSTRAINT IF EXISTS %s`, constraint.Table, constraint.Name)).Error; err != nil {
+				return fmt.Errorf("ไม่สามารถลบ constraint %s: %w", constraint.Name, err)
+			}
+		}
+		if err := tx.Exec(`DROP INDEX IF EXISTS idx_persons_person_hash`).Error; err != nil {
+			return fmt.Errorf("ไม่สามารถลบ unique index ของ person_hash: %w", err)
+		}
+
+		// Split collisions: create the missing person of every organization whose logs or face images use a hash owned by another organization
+		result := tx.Exec(`
+			INSERT INTO persons (id, created_at, updated_at, person_hash, first_seen, last_seen, visit_count, organization_id)
+			SELECT gen_random_uuid()::text, NOW(), NOW(), d.person_hash, d.first_seen, d.last_seen, d.visits, d.organization_id
+			FROM (
+				SELECT organization_id, person_hash, MIN(first_seen) AS first_seen, MAX(last_seen) AS last_seen, SUM(visits) AS visits
+				FROM (
+					SELECT organization_id, person_hash, MIN(timestamp) AS first_seen, MAX(timestamp) AS last_seen, COUNT(*) AS visits
+					FROM person_logs
+					WHERE deleted_at IS NULL
+					GROUP BY organization_id, person_hash
+					UNION ALL
+					SELECT organization_id, person_hash, MIN(created_at), MAX(created_at), 0
+					FROM face_images
+					WHERE deleted_at IS NULL
+					GROUP BY organization_id, person_hash
+				) src
+				GROUP BY organization_id, person_hash
+			) d
+			WHERE d.person_hash IN (` + collidingHashes + `)
+				AND NOT EXISTS (
+					SELECT 1 FROM persons p
+					WHERE p.organization_id = d.organization_id AND p.person_hash = d.person_hash
+				)
+		`)
+		if result.Error != nil {
+			return fmt.Errorf("ไม่สามารถแยกบุคคลที่ใช้รหัสซ้ำกันระหว่างองค์กร: %w", result.Error)
+		}
+		if result.RowsAffected > 0 {
+			log.Printf("แยกบุคคลที่ใช้รหัสซ้ำกันระหว่างองค์กรแล้ว %d รายการ", result.RowsAffected)
+		}
+
+		// Recompute first_seen, last_seen and visit_count of colliding hashes from each organization's own logs
+		if err := tx.Exec(`
+			UPDATE persons p
+			SET first_seen = agg.first_seen, last_seen = agg.last_seen, visit_count = agg.visits, updated_at = NOW()
+			FROM (
+				SELECT organization_id, person_hash, MIN(timestamp) AS first_seen, MAX(timestamp) AS last_seen, COUNT(*) AS visits
+				FROM person_logs
+				WHERE deleted_at IS NULL AND person_hash IN (` + collidingHashes + `)
+				GROUP BY organization_id, person_hash
+			) agg
+			WHERE p.organization_id = agg.organization_id AND p.person_hash = agg.person_hash
+		`).Error; err != nil {
+			return fmt.Errorf("ไม่สามารถคำนวณข้อมูลบุคคลที่ใช้รหัสซ้ำกันใหม่: %w", err)
+		}
+
+		// The first log of each (organization_id, person_hash) is the new person, the rest are returning.
+		// The old history count ignored organization_id, so flags of colliding hashes may be wrong.
+		if err := tx.Exec(`
+			UPDATE person_logs l
+			SET is_new_person = (l.id = first_log.id)
+			FROM (
+				SELECT DISTINCT ON (organization_id, person_hash) organization_id, person_hash, id
+				FROM person_logs
+				WHERE deleted_at IS NULL AND person_hash IN (` + collidingHashes + `)
+				ORDER BY organization_id, person_hash, timestamp, id
+			) first_log
+			WHERE l.organization_id = first_log.organization_id AND l.person_hash = first_log.person_hash
+				AND l.deleted_at IS NULL AND l.is_new_person <> (l.id = first_log.id)
+		`).Error; err != nil {
+			return fmt.Errorf("ไม่สามารถคำนวณสถานะคนใหม่ของ logs ใหม่: %w", err)
+		}
+
+		return nil
+	})
+}
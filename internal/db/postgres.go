@@ -68,20 +68,61 @@ func (p *PostgresDB) Close() error {
 
 // InitTables creates all required tables using GORM auto migration
 func (p *PostgresDB) InitTables() error {
+	// Move existing persons to the per-organization identity before AutoMigrate adds its unique index
+	if err := p.migratePersonIdentity(); err != nil {
+		return fmt.Errorf("ไม่สามารถ migrate ฐานข้อมูล: %w", err)
+	}
+
+	// Persons from before primary face images get one after AutoMigrate adds the column
+	migrator := p.DB.Migrator()
+	fillPrimaryFaceImages := migrator.HasTable(&models.Person{}) && !migrator.HasColumn(&models.Person{}, "primary_face_image_id")
+
 	// Auto migrate all models - GORM will create tables, indexes, etc.
 	err := p.DB.AutoMigrate(
 		&models.Organization{},
 		&models.User{},
 		&models.APIKey{},
+		&models.Site{},
 		&models.Camera{},
 		&models.PersonLog{},
 		&models.FaceImage{},
+		&models.FaceEmbedding{},
 		&models.Person{},
+		&models.SegmentSettings{},
+		&models.FaceUploadSettings{},
+		&models.FaceUploadIntent{},
+		&models.TrafficAnomaly{},
+		&models.TrafficForecast{},
+		&models.ForecastAccuracy{},
+		&models.Holiday{},
+		&models.SitePresence{},
+		&models.OccupancyThreshold{},
+		&models.OccupancyEvent{},
+		&models.Job{},
+		&models.PersonLabel{},
+		&models.PersonNote{},
+		&models.PersonOperation{},
+		&models.PersonAlias{},
+		&models.Watchlist{},
+		&models.WatchlistEntry{},
+		&models.WatchlistAlert{},
+		&models.SavedSearch{},
 	)
 	if err != nil {
 		return fmt.Errorf("ไม่สามารถ migrate ฐานข้อมูล: %w", err)
 	}
 
+	// Replace stored face image URLs with the object keys added by AutoMigrate
+	if err := p.migrateFaceImageKeys(); err != nil {
+		return fmt.Errorf("ไม่สามารถ migrate ฐานข้อมูล: %w", err)
+	}
+
+	if fillPrimaryFaceImages {
+		if err := p.migratePrimaryFaceImages(); err != nil {
+			return fmt.Errorf("ไม่สามารถ migrate ฐานข้อมูล: %w", err)
+		}
+	}
+
 	log.Println("สร้างตารางทั้งหมดสำเร็จ (ถ้ายังไม่มี)")
 
 	// Check if we need to create a default organization and API key
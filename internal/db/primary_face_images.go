@@ -0,0 +1,28 @@
+package db
+
+import (
+	"fmt"
+	"log"
+)
+
+// migratePrimaryFaceImages sets the primary face image of persons created before primary face images existed.
+// Existing images have no quality score yet, so the newest image is chosen, like the former person list avatar.
+// It runs after AutoMigrate has added persons.primary_face_image_id and only when that column was missing before.
+func (p *PostgresDB) migratePrimaryFaceImages() error {
+	log.Println("กำลังกำหนดรูปภาพหลักของบุคคลที่มีอยู่")
+
+	if err := p.DB.Exec(`
+		UPDATE persons p SET primary_face_image_id = newest.id
+		FROM (
+			SELECT DISTINCT ON (organization_id, person_hash) organization_id, person_hash, id
+			FROM face_images
+			WHERE duplicate_of_id IS NULL AND deleted_at IS NULL
+			ORDER BY organization_id, person_hash, created_at DESC, id
+		) newest
+		WHERE p.organization_id = newest.organization_id AND p.person_hash = newest.person_hash
+			AND p.primary_face_image_id IS NULL
+	`).Error; err != nil {
+		return fmt.Errorf("ไม่สามารถกำหนดรูปภาพหลักของบุคคล: %w", err)
+	}
+	return nil
+}
@@ -87,4 +87,20 @@ func (r *RedisClient) Delete(ctx context.Context, key string) error {
 // FlushAll ลบข้อมูลทั้งหมดใน Redis
 func (r *RedisClient) FlushAll(ctx context.Context) error {
 	return r.Client.FlushAll(ctx).Err()
-} 
\ No newline at end of file
+} 
+
+// DeletePattern ลบข้อมูลทุก key ที่ตรงกับรูปแบบที่กำหนด (เช่น "heatmap:org-1:*")
+func (r *RedisClient) DeletePattern(ctx context.Context, pattern string) error {
+	iter := r.Client.Scan(ctx, 0, pattern, 100).Iterator()
+	var keys []string
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+	if err := iter.Err(); err != nil {
+		return fmt.Errorf("ไม่สามารถค้นหา key ใน Redis: %w", err)
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	return r.Client.Del(ctx, keys...).Err()
+}
@@ -0,0 +1,361 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/db"
+	"github.com/go-redis/redis/v8"
+)
+
+// Event types pushed to live subscribers
+const (
+	TypeDetection      = "detection"
+	TypeCounter        = "counter"
+	TypeCameraStatus   = "camera_status"
+	TypeWatchlistAlert = "watchlist_alert"
+)
+
+// Redis keys used for fan-out and the replay buffer
+const (
+	channelName  = "manta:events"
+	streamPrefix = "manta:events:"
+	lockPrefix   = "manta:locks:"
+)
+
+// Event is a message pushed to the live stream of an organization.
+// IDs have the form "<unix ms>-<sequence>" and increase within an organization.
+type Event struct {
+	ID             string          `json:"id"`
+	Type           string          `json:"type"`
+	OrganizationID string          `json:"organization_id"`
+	CameraID       string          `json:"camera_id,omitempty"`
+	Zone           string          `json:"zone,omitempty"`
+	Time           time.Time       `json:"time"`
+	Data           json.RawMessage `json:"data"`
+}
+
+// NewEvent creates an event with its payload encoded as JSON
+func NewEvent(eventType, organizationID, cameraID, zone string, data interface{}) (Event, error) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return Event{}, fmt.Errorf("unable to encode event payload: %w", err)
+	}
+	return Event{
+		Type:           eventType,
+		OrganizationID: organizationID,
+		CameraID:       cameraID,
+		Zone:           zone,
+		Time:           time.Now(),
+		Data:           payload,
+	}, nil
+}
+
+// Filter restricts the events delivered to a subscriber. Empty fields match everything.
+// Camera and zone filters only apply to events tied to a camera; organization-wide
+// events such as the total counter are always delivered.
+type Filter struct {
+	Types     []string
+	CameraIDs []string
+	Zones     []string
+}
+
+// Matches reports whether the event passes the filter
+func (f Filter) Matches(e Event) bool {
+	if len(f.Types) > 0 && !contains(f.Types, e.Type) {
+		return false
+	}
+	if e.CameraID == "" {
+		return true
+	}
+	if len(f.CameraIDs) > 0 && !contains(f.CameraIDs, e.CameraID) {
+		return false
+	}
+	if len(f.Zones) > 0 && !contains(f.Zones, e.Zone) {
+		return false
+	}
+	return true
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// CompareIDs compares two event IDs and returns -1, 0 or 1
+func CompareIDs(a, b string) int {
+	aMs, aSeq, _ := ParseID(a)
+	bMs, bSeq, _ := ParseID(b)
+	switch {
+	case aMs < bMs:
+		return -1
+	case aMs > bMs:
+		return 1
+	case aSeq < bSeq:
+		return -1
+	case aSeq > bSeq:
+		return 1
+	}
+	return 0
+}
+
+// ParseID splits an event ID into its millisecond and sequence parts
+func ParseID(id string) (int64, int64, error) {
+	parts := strings.SplitN(id, "-", 2)
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("invalid event id %q", id)
+	}
+	ms, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid event id %q", id)
+	}
+	seq, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid event id %q", id)
+	}
+	return ms, seq, nil
+}
+
+// Subscription receives the live events of one organization
+type Subscription struct {
+	C <-chan Event
+
+	ch             chan Event
+	organizationID string
+	filter         Filter
+	broker         *Broker
+	closed         bool
+}
+
+// Close stops the subscription and closes its channel
+func (s *Subscription) Close() {
+	s.broker.unsubscribe(s)
+}
+
+// Broker fans out events to subscribers on every replica. With Redis, events are
+// appended to a per-organization stream (the replay buffer) and broadcast through
+// pub/sub; without Redis, the broker only serves subscribers of this process.
+type Broker struct {
+	redis      *redis.Client
+	bufferSize int
+
+	mu          sync.Mutex
+	subscribers map[*Subscription]struct{}
+	buffers     map[string][]Event
+	lastMs      int64
+	seq         int64
+}
+
+// NewBroker creates a broker; redisClient may be nil
+func NewBroker(redisClient *db.RedisClient, bufferSize int) *Broker {
+	if bufferSize <= 0 {
+		bufferSize = 1000
+	}
+	b := &Broker{
+		bufferSize:  bufferSize,
+		subscribers: make(map[*Subscription]struct{}),
+		buffers:     make(map[string][]Event),
+	}
+	if redisClient != nil {
+		b.redis = redisClient.Client
+	}
+	return b
+}
+
+// Start listens for events published by any replica until ctx is cancelled
+func (b *Broker) Start(ctx context.Context) {
+	if b.redis == nil {
+		return
+	}
+
+	pubsub := b.redis.Subscribe(ctx, channelName)
+	go func() {
+		defer pubsub.Close()
+		messages := pubsub.Channel()
+		for {
+			select {
+			case message, ok := <-messages:
+				if !ok {
+					return
+				}
+				var event Event
+				if err := json.Unmarshal([]byte(message.Payload), &event); err != nil {
+					log.Printf("unable to decode live event: %v", err)
+					continue
+				}
+				b.dispatch(event)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Publish stores the event in the replay buffer and delivers it to subscribers on all replicas
+func (b *Broker) Publish(ctx context.Context, event Event) error {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	if b.redis == nil {
+		b.mu.Lock()
+		event.ID = b.nextLocalID(event.Time)
+		buffer := append(b.buffers[event.OrganizationID], event)
+		if len(buffer) > b.bufferSize {
+			buffer = buffer[len(buffer)-b.bufferSize:]
+		}
+		b.buffers[event.OrganizationID] = buffer
+		b.mu.Unlock()
+
+		b.dispatch(event)
+		return nil
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("unable to encode event: %w", err)
+	}
+	id, err := b.redis.XAdd(ctx, &redis.XAddArgs{
+		Stream: streamPrefix + event.OrganizationID,
+		MaxLen: int64(b.bufferSize),
+		Approx: true,
+		Values: map[string]interface{}{"event": payload},
+	}).Result()
+	if err != nil {
+		return fmt.Errorf("unable to append event to stream: %w", err)
+	}
+
+	event.ID = id
+	payload, err = json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("unable to encode event: %w", err)
+	}
+	if err := b.redis.Publish(ctx, channelName, payload).Err(); err != nil {
+		return fmt.Errorf("unable to publish event: %w", err)
+	}
+	return nil
+}
+
+// nextLocalID returns an increasing ID in the same format as Redis stream IDs; b.mu must be held
+func (b *Broker) nextLocalID(t time.Time) string {
+	ms := t.UnixMilli()
+	if ms <= b.lastMs {
+		ms = b.lastMs
+		b.seq++
+	} else {
+		b.lastMs = ms
+		b.seq = 0
+	}
+	return fmt.Sprintf("%d-%d", ms, b.seq)
+}
+
+// Replay returns the buffered events of an organization published after lastID
+func (b *Broker) Replay(ctx context.Context, organizationID, lastID string, filter Filter) ([]Event, error) {
+	if _, _, err := ParseID(lastID); err != nil {
+		return nil, err
+	}
+
+	var events []Event
+	if b.redis == nil {
+		b.mu.Lock()
+		for _, event := range b.buffers[organizationID] {
+			if CompareIDs(event.ID, lastID) > 0 && filter.Matches(event) {
+				events = append(events, event)
+			}
+		}
+		b.mu.Unlock()
+		return events, nil
+	}
+
+	messages, err := b.redis.XRangeN(ctx, streamPrefix+organizationID, lastID, "+", int64(b.bufferSize)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read event stream: %w", err)
+	}
+	for _, message := range messages {
+		if message.ID == lastID {
+			continue
+		}
+		raw, _ := message.Values["event"].(string)
+		var event Event
+		if err := json.Unmarshal([]byte(raw), &event); err != nil {
+			continue
+		}
+		event.ID = message.ID
+		if filter.Matches(event) {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+// Subscribe registers a subscriber for the live events of an organization
+func (b *Broker) Subscribe(organizationID string, filter Filter) *Subscription {
+	ch := make(chan Event, 256)
+	sub := &Subscription{
+		C:              ch,
+		ch:             ch,
+		organizationID: organizationID,
+		filter:         filter,
+		broker:         b,
+	}
+
+	b.mu.Lock()
+	b.subscribers[sub] = struct{}{}
+	b.mu.Unlock()
+
+	return sub
+}
+
+func (b *Broker) unsubscribe(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if sub.closed {
+		return
+	}
+	sub.closed = true
+	delete(b.subscribers, sub)
+	close(sub.ch)
+}
+
+// dispatch delivers an event to the matching subscribers of this process.
+// A subscriber that cannot keep up is closed; it can reconnect and resume from its last event ID.
+func (b *Broker) dispatch(event Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for sub := range b.subscribers {
+		if sub.organizationID != event.OrganizationID || !sub.filter.Matches(event) {
+			continue
+		}
+		select {
+		case sub.ch <- event:
+		default:
+			sub.closed = true
+			delete(b.subscribers, sub)
+			close(sub.ch)
+		}
+	}
+}
+
+// TryLock acquires a lock shared by all replicas so a periodic job runs only once.
+// Without Redis the lock is always acquired.
+func (b *Broker) TryLock(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	if b.redis == nil {
+		return true, nil
+	}
+	ok, err := b.redis.SetNX(ctx, lockPrefix+key, time.Now().Unix(), ttl).Result()
+	if err != nil {
+		return false, fmt.Errorf("unable to acquire lock %s: %w", key, err)
+	}
+	return ok, nil
+}
@@ -0,0 +1,96 @@
+package events
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func mustEvent(t *testing.T, eventType, organizationID, cameraID, zone string) Event {
+	event, err := NewEvent(eventType, organizationID, cameraID, zone, map[string]int{"n": 1})
+	require.NoError(t, err)
+	return event
+}
+
+// TestBrokerLocalFanOut tests delivery to matching subscribers without Redis
+func TestBrokerLocalFanOut(t *testing.T) {
+	broker := NewBroker(nil, 10)
+	ctx := context.Background()
+
+	all := broker.Subscribe("org-1", Filter{})
+	defer all.Close()
+	camera := broker.Subscribe("org-1", Filter{CameraIDs: []string{"cam-1"}})
+	defer camera.Close()
+	other := broker.Subscribe("org-2", Filter{})
+	defer other.Close()
+
+	require.NoError(t, broker.Publish(ctx, mustEvent(t, TypeDetection, "org-1", "cam-2", "")))
+	require.NoError(t, broker.Publish(ctx, mustEvent(t, TypeCounter, "org-1", "", "")))
+
+	received := <-all.C
+	assert.Equal(t, "cam-2", received.CameraID)
+	assert.Equal(t, TypeCounter, (<-all.C).Type)
+
+	// organization-wide events pass the camera filter
+	assert.Equal(t, TypeCounter, (<-camera.C).Type)
+
+	select {
+	case event := <-other.C:
+		t.Fatalf("unexpected event for another organization: %+v", event)
+	case <-time.After(10 * time.Millisecond):
+	}
+}
+
+// TestBrokerReplay tests resuming after a given event ID
+func TestBrokerReplay(t *testing.T) {
+	broker := NewBroker(nil, 3)
+	ctx := context.Background()
+
+	var ids []string
+	sub := broker.Subscribe("org-1", Filter{})
+	defer sub.Close()
+	for i := 0; i < 5; i++ {
+		require.NoError(t, broker.Publish(ctx, mustEvent(t, TypeDetection, "org-1", "cam-1", "lobby")))
+		ids = append(ids, (<-sub.C).ID)
+	}
+	for i := 1; i < len(ids); i++ {
+		assert.Equal(t, 1, CompareIDs(ids[i], ids[i-1]))
+	}
+
+	// only the last 3 events are buffered
+	events, err := broker.Replay(ctx, "org-1", ids[0], Filter{})
+	require.NoError(t, err)
+	require.Len(t, events, 3)
+	assert.Equal(t, ids[2], events[0].ID)
+
+	events, err = broker.Replay(ctx, "org-1", ids[3], Filter{Zones: []string{"lobby"}})
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, ids[4], events[0].ID)
+
+	_, err = broker.Replay(ctx, "org-1", "not-an-id", Filter{})
+	assert.Error(t, err)
+}
+
+// TestBrokerClosesSlowSubscriber tests that a subscriber that does not keep up is disconnected
+func TestBrokerClosesSlowSubscriber(t *testing.T) {
+	broker := NewBroker(nil, 1000)
+	ctx := context.Background()
+
+	sub := broker.Subscribe("org-1", Filter{})
+	for i := 0; i < 300; i++ {
+		require.NoError(t, broker.Publish(ctx, mustEvent(t, TypeDetection, "org-1", "cam-1", "")))
+	}
+
+	count := 0
+	for range sub.C {
+		count++
+	}
+	assert.Equal(t, 256, count)
+
+	// closing an already closed subscription is safe
+	sub.Close()
+}
@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+// Severity levels shared by anomalies and alerts
+const (
+	SeverityLow      = "low"
+	SeverityMedium   = "medium"
+	SeverityHigh     = "high"
+	SeverityCritical = "critical"
+)
+
+// Directions of a traffic anomaly
+const (
+	AnomalySpike = "spike"
+	AnomalyDrop  = "drop"
+)
+
+// TrafficAnomaly represents an hour whose traffic deviates from the seasonal baseline.
+// An empty CameraID means the anomaly was detected on the whole organization's traffic.
+type TrafficAnomaly struct {
+	Base
+	OrganizationID string    `json:"organization_id" gorm:"type:varchar(36);uniqueIndex:idx_traffic_anomalies_scope_hour;not null"`
+	CameraID       string    `json:"camera_id,omitempty" gorm:"type:varchar(36);uniqueIndex:idx_traffic_anomalies_scope_hour;not null;default:''"`
+	HourStart      time.Time `json:"hour_start" gorm:"type:timestamp;uniqueIndex:idx_traffic_anomalies_scope_hour;index;not null"`
+	Observed       int       `json:"observed" gorm:"type:int;not null"`
+	Expected       float64   `json:"expected" gorm:"type:double precision;not null"`
+	StdDev         float64   `json:"std_dev" gorm:"type:double precision;not null"`
+	ZScore         float64   `json:"z_score" gorm:"type:double precision;not null"`
+	Direction      string    `json:"direction" gorm:"type:varchar(10);not null"`
+	Severity       string    `json:"severity" gorm:"type:varchar(20);index;not null"`
+	BaselineWeeks  int       `json:"baseline_weeks" gorm:"type:int;not null"`
+}
+
+// TableName specifies the table name for TrafficAnomaly
+func (TrafficAnomaly) TableName() string {
+	return "traffic_anomalies"
+}
+
+// AnomalyFilter is used for filtering traffic anomalies
+type AnomalyFilter struct {
+	From           time.Time `json:"from,omitempty"`
+	To             time.Time `json:"to,omitempty"`
+	CameraID       string    `json:"camera_id,omitempty"`
+	Severity       string    `json:"severity,omitempty"`
+	OrganizationID string    `json:"organization_id,omitempty"`
+	Page           int       `json:"page,omitempty"`
+	PageSize       int       `json:"page_size,omitempty"`
+}
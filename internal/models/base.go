@@ -20,4 +20,21 @@ type Pagination struct {
 	Page      int `json:"page"`
 	PageSize  int `json:"page_size"`
 	TotalPage int `json:"total_page"`
-}
\ No newline at end of file
+}
+// ListOptions holds keyset pagination, sorting and field projection options for list endpoints
+type ListOptions struct {
+	Cursor       string   `json:"cursor,omitempty"`
+	Limit        int      `json:"limit,omitempty"`
+	Sort         string   `json:"sort,omitempty"`
+	Order        string   `json:"order,omitempty"` // asc or desc
+	Fields       []string `json:"fields,omitempty"`
+	IncludeTotal bool     `json:"include_total,omitempty"`
+}
+
+// CursorPagination is used for keyset paginated responses
+type CursorPagination struct {
+	Limit      int    `json:"limit"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	HasMore    bool   `json:"has_more"`
+	Total      *int64 `json:"total,omitempty"` // only counted when include_total=true
+}
@@ -3,10 +3,13 @@ package models
 // Camera represents a physical camera device
 type Camera struct {
 	Base
-	Name           string `json:"name" gorm:"type:varchar(255);not null"`
-	Location       string `json:"location" gorm:"type:varchar(255)"`
-	Status         string `json:"status" gorm:"type:varchar(50);not null;default:'active'"`
-	OrganizationID string `json:"organization_id" gorm:"type:varchar(36);index;not null"`
+	Name           string  `json:"name" gorm:"type:varchar(255);not null"`
+	Location       string  `json:"location" gorm:"type:varchar(255)"`
+	Status         string  `json:"status" gorm:"type:varchar(50);not null;default:'active'"`
+	OrganizationID string  `json:"organization_id" gorm:"type:varchar(36);index;not null"`
+	SiteID         *string `json:"site_id,omitempty" gorm:"type:varchar(36);index"`
+	Role           string  `json:"role" gorm:"type:varchar(20);not null;default:'interior'"` // entrance, exit, both, interior
+	Zone           string  `json:"zone,omitempty" gorm:"type:varchar(100);index"`
 
 	// Relationships
 	Organization Organization `json:"organization,omitempty" gorm:"foreignKey:OrganizationID"`
@@ -17,4 +20,11 @@ type Camera struct {
 // TableName specifies the table name for Camera
 func (Camera) TableName() string {
 	return "cameras"
-}
\ No newline at end of file
+}
+// CameraFilter is used for filtering cameras; each field matches any of the listed values
+type CameraFilter struct {
+	Statuses []string `json:"statuses,omitempty"`
+	Zones    []string `json:"zones,omitempty"`
+	SiteIDs  []string `json:"site_ids,omitempty"`
+	Roles    []string `json:"roles,omitempty"`
+}
@@ -0,0 +1,67 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"time"
+)
+
+// MaxEmbeddingDimensions is the largest face embedding accepted
+const MaxEmbeddingDimensions = 2048
+
+// Embedding is a face embedding vector, stored as little-endian float32 values in a bytea column
+type Embedding []float32
+
+// Value implements driver.Valuer
+func (e Embedding) Value() (driver.Value, error) {
+	if e == nil {
+		return nil, nil
+	}
+	data := make([]byte, 4*len(e))
+	for i, v := range e {
+		binary.LittleEndian.PutUint32(data[4*i:], math.Float32bits(v))
+	}
+	return data, nil
+}
+
+// Scan implements sql.Scanner
+func (e *Embedding) Scan(value interface{}) error {
+	if value == nil {
+		*e = nil
+		return nil
+	}
+	data, ok := value.([]byte)
+	if !ok || len(data)%4 != 0 {
+		return fmt.Errorf("invalid embedding value of type %T", value)
+	}
+	vector := make(Embedding, len(data)/4)
+	for i := range vector {
+		vector[i] = math.Float32frombits(binary.LittleEndian.Uint32(data[4*i:]))
+	}
+	*e = vector
+	return nil
+}
+
+// FaceEmbedding is the embedding of a face image computed by the edge device, normalized to unit length.
+// It is kept out of face_images so that listing images does not load the vectors.
+type FaceEmbedding struct {
+	FaceImageID    string    `json:"face_image_id" gorm:"type:varchar(36);primaryKey"`
+	OrganizationID string    `json:"organization_id" gorm:"type:varchar(36);index;not null"`
+	Dimensions     int       `json:"dimensions" gorm:"type:int;not null"`
+	Vector         Embedding `json:"-" gorm:"type:bytea;not null"`
+	CreatedAt      time.Time `json:"created_at" gorm:"index"`
+}
+
+// TableName specifies the table name for FaceEmbedding
+func (FaceEmbedding) TableName() string {
+	return "face_embeddings"
+}
+
+// FaceSearchMatch is a person whose face images are similar to a searched embedding
+type FaceSearchMatch struct {
+	PersonHash  string  `json:"person_hash"`
+	Similarity  float64 `json:"similarity"`    // cosine similarity of the most similar image, -1 to 1
+	FaceImageID string  `json:"face_image_id"` // most similar image of the person
+}
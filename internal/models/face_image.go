@@ -1,21 +1,137 @@
 package models
 
+import "time"
+
 // FaceImage represents a stored image of a detected face
 type FaceImage struct {
 	Base
-	PersonHash     string `json:"person_hash" gorm:"type:varchar(255);index;not null"`
-	ImageURL       string `json:"image_url" gorm:"type:varchar(512);not null"`
-	ThumbnailURL   string `json:"thumbnail_url,omitempty" gorm:"type:varchar(512)"`
-	OrganizationID string `json:"organization_id" gorm:"type:varchar(36);index;not null"`
-	CameraID       string `json:"camera_id" gorm:"type:varchar(36);index;not null"`
+	PersonHash     string            `json:"person_hash" gorm:"type:varchar(255);index;not null"`
+	ImageKey       string            `json:"-" gorm:"type:varchar(512)"`          // storage object key of the image
+	ThumbnailKeys  map[string]string `json:"-" gorm:"type:jsonb;serializer:json"` // thumbnail object key by size in pixels, e.g. "48"
+	OrganizationID string            `json:"organization_id" gorm:"type:varchar(36);index;not null"`
+	CameraID       string            `json:"camera_id" gorm:"type:varchar(36);index;not null"`
+	ReportedHash   string            `json:"reported_hash,omitempty" gorm:"type:varchar(255);index"` // hash sent by the uploader when it was an alias of person_hash
+	CapturedAt     *time.Time        `json:"captured_at,omitempty" gorm:"type:timestamp;index"`      // when the camera took the image, if known
+	Width          int               `json:"width,omitempty" gorm:"type:int;not null;default:0"`
+	Height         int               `json:"height,omitempty" gorm:"type:int;not null;default:0"`
+	PerceptualHash *int64            `json:"-" gorm:"type:bigint;index"`                              // 64-bit dHash of the stored image, for near-duplicate detection
+	DuplicateOfID  *string           `json:"duplicate_of_id,omitempty" gorm:"type:varchar(36);index"` // image whose files this near-duplicate shares
+	UploadIntentID *string           `json:"-" gorm:"type:varchar(36);uniqueIndex"`                   // direct upload this image was created from, so completing it twice finds the same image
+
+	// Quality of the image, used to choose the primary face image of the person
+	FaceQuality
+	Sharpness    *float64 `json:"sharpness,omitempty" gorm:"type:double precision"`                    // variance of the Laplacian of the image scaled to 128x128
+	QualityScore float64  `json:"quality_score" gorm:"type:double precision;not null;default:0;index"` // 0-1, higher is better
+
+	// Signed URLs generated for API responses; they are never stored
+	ImageURL     string            `json:"image_url" gorm:"-"`
+	ThumbnailURL string            `json:"thumbnail_url,omitempty" gorm:"-"` // smallest thumbnail
+	Thumbnails   map[string]string `json:"thumbnails,omitempty" gorm:"-"`    // thumbnail URL by size in pixels
+	URLsExpireAt *time.Time        `json:"urls_expire_at,omitempty" gorm:"-"`
+
+	// Set when an upload was skipped as a near-duplicate and this existing image was returned instead
+	Duplicate bool `json:"duplicate,omitempty" gorm:"-"`
 
 	// Relationships
 	Camera       Camera       `json:"camera,omitempty" gorm:"foreignKey:CameraID"`
 	Organization Organization `json:"organization,omitempty" gorm:"foreignKey:OrganizationID"`
-	Person       Person       `json:"person,omitempty" gorm:"foreignKey:PersonHash;references:PersonHash"`
+	Person       Person       `json:"person,omitempty" gorm:"foreignKey:OrganizationID,PersonHash;references:OrganizationID,PersonHash"`
 }
 
 // TableName specifies the table name for FaceImage
 func (FaceImage) TableName() string {
 	return "face_images"
-}
\ No newline at end of file
+}
+
+// FaceQuality is the optional quality metadata reported by the uploader of a face image
+type FaceQuality struct {
+	FaceConfidence *float64 `json:"face_confidence,omitempty" gorm:"type:double precision"` // face detector confidence, 0-1
+	Yaw            *float64 `json:"yaw,omitempty" gorm:"type:double precision"`             // head rotation in degrees, 0 is frontal, -90 to 90
+}
+
+// MaxFaceImageFileSize is the largest upload size an organization can allow (bytes)
+const MaxFaceImageFileSize = 20 * 1024 * 1024
+
+// Near-duplicate handling modes of face image uploads
+const (
+	FaceDedupOff  = "off"  // store every upload
+	FaceDedupSkip = "skip" // return the existing image instead of storing the upload
+	FaceDedupLink = "link" // record the upload as a new image that shares the existing image's files
+)
+
+// FaceUploadSettings stores the per-organization limits of uploaded face images
+type FaceUploadSettings struct {
+	Base
+	OrganizationID string `json:"organization_id" gorm:"type:varchar(36);uniqueIndex;not null"`
+	MaxFileSize    int64  `json:"max_file_size" gorm:"type:bigint;not null;default:5242880"` // bytes
+	MinWidth       int    `json:"min_width" gorm:"type:int;not null;default:64"`
+	MinHeight      int    `json:"min_height" gorm:"type:int;not null;default:64"`
+	MaxWidth       int    `json:"max_width" gorm:"type:int;not null;default:4096"`
+	MaxHeight      int    `json:"max_height" gorm:"type:int;not null;default:4096"`
+
+	// An upload is a near-duplicate when its perceptual hash is within DedupMaxDistance bits of
+	// an image of the same person uploaded in the last DedupWindow seconds
+	DedupMode        string `json:"dedup_mode" gorm:"type:varchar(10);not null;default:'skip'"` // off, skip or link
+	DedupMaxDistance int    `json:"dedup_max_distance" gorm:"type:int;not null;default:6"`      // bits, 0-32
+	DedupWindow      int    `json:"dedup_window" gorm:"type:int;not null;default:600"`          // seconds
+
+	// Relationships
+	Organization Organization `json:"organization,omitempty" gorm:"foreignKey:OrganizationID"`
+}
+
+// TableName specifies the table name for FaceUploadSettings
+func (FaceUploadSettings) TableName() string {
+	return "face_upload_settings"
+}
+
+// DefaultFaceUploadSettings returns the limits used when an organization has not configured its own
+func DefaultFaceUploadSettings(organizationID string) FaceUploadSettings {
+	return FaceUploadSettings{
+		OrganizationID:   organizationID,
+		MaxFileSize:      5 * 1024 * 1024,
+		MinWidth:         64,
+		MinHeight:        64,
+		MaxWidth:         4096,
+		MaxHeight:        4096,
+		DedupMode:        FaceDedupSkip,
+		DedupMaxDistance: 6,
+		DedupWindow:      600,
+	}
+}
+
+// Face upload intent statuses
+const (
+	FaceUploadIntentPending   = "pending"
+	FaceUploadIntentCompleted = "completed"
+	FaceUploadIntentRejected  = "rejected"
+	FaceUploadIntentExpired   = "expired"
+)
+
+// FaceUploadIntent is a pending direct-to-storage upload of a face image.
+// The client PUTs the image to UploadURL, then completes the intent to create the FaceImage.
+type FaceUploadIntent struct {
+	Base
+	OrganizationID string     `json:"organization_id" gorm:"type:varchar(36);index;not null"`
+	PersonHash     string     `json:"person_hash" gorm:"type:varchar(255);not null"`
+	CameraID       string     `json:"camera_id" gorm:"type:varchar(36);not null"`
+	ObjectKey      string     `json:"-" gorm:"type:varchar(512);not null"` // staging object the client uploads to
+	ContentType    string     `json:"content_type" gorm:"type:varchar(50);not null"`
+	Size           int64      `json:"size" gorm:"type:bigint;not null"`
+	Status         string     `json:"status" gorm:"type:varchar(20);index;not null;default:'pending'"`
+	ExpiresAt      time.Time  `json:"expires_at" gorm:"type:timestamp;index;not null"`
+	CompletedAt    *time.Time `json:"completed_at,omitempty" gorm:"type:timestamp"`
+	FaceImageID    string     `json:"face_image_id,omitempty" gorm:"type:varchar(36)"`
+	Error          string     `json:"error,omitempty" gorm:"type:text"` // reason the upload was rejected
+	FaceQuality
+	Embedding Embedding `json:"-" gorm:"type:bytea"` // normalized embedding stored with the face image
+
+	// Upload instructions returned when the intent is created; they are never stored
+	UploadURL     string            `json:"upload_url,omitempty" gorm:"-"`
+	UploadMethod  string            `json:"upload_method,omitempty" gorm:"-"`
+	UploadHeaders map[string]string `json:"upload_headers,omitempty" gorm:"-"`
+}
+
+// TableName specifies the table name for FaceUploadIntent
+func (FaceUploadIntent) TableName() string {
+	return "face_upload_intents"
+}
@@ -0,0 +1,72 @@
+package models
+
+import "time"
+
+// Forecast scopes
+const (
+	ForecastScopeOrganization = "organization"
+	ForecastScopeSite         = "site"
+	ForecastScopeCamera       = "camera"
+)
+
+// TrafficForecast represents the predicted number of detections for one hour of a scope
+type TrafficForecast struct {
+	Base
+	OrganizationID string    `json:"organization_id" gorm:"type:varchar(36);uniqueIndex:idx_traffic_forecasts_scope_hour;not null"`
+	ScopeType      string    `json:"scope_type" gorm:"type:varchar(20);uniqueIndex:idx_traffic_forecasts_scope_hour;not null"`
+	ScopeID        string    `json:"scope_id" gorm:"type:varchar(36);uniqueIndex:idx_traffic_forecasts_scope_hour;not null"`
+	HourStart      time.Time `json:"hour_start" gorm:"type:timestamp;uniqueIndex:idx_traffic_forecasts_scope_hour;not null"`
+	Predicted      float64   `json:"predicted" gorm:"type:double precision;not null"`
+	Lower          float64   `json:"lower" gorm:"type:double precision;not null"`
+	Upper          float64   `json:"upper" gorm:"type:double precision;not null"`
+	IsHoliday      bool      `json:"is_holiday" gorm:"type:boolean;not null;default:false"`
+	GeneratedAt    time.Time `json:"generated_at" gorm:"type:timestamp;not null"`
+}
+
+// TableName specifies the table name for TrafficForecast
+func (TrafficForecast) TableName() string {
+	return "traffic_forecasts"
+}
+
+// ForecastAccuracy stores the backtest accuracy of the latest model fitted for a scope
+type ForecastAccuracy struct {
+	Base
+	OrganizationID string    `json:"organization_id" gorm:"type:varchar(36);uniqueIndex:idx_forecast_accuracies_scope;not null"`
+	ScopeType      string    `json:"scope_type" gorm:"type:varchar(20);uniqueIndex:idx_forecast_accuracies_scope;not null"`
+	ScopeID        string    `json:"scope_id" gorm:"type:varchar(36);uniqueIndex:idx_forecast_accuracies_scope;not null"`
+	MAE            float64   `json:"mae" gorm:"column:mae;type:double precision;not null"`
+	RMSE           float64   `json:"rmse" gorm:"column:rmse;type:double precision;not null"`
+	MAPE           float64   `json:"mape" gorm:"column:mape;type:double precision;not null"`
+	Coverage       float64   `json:"coverage" gorm:"type:double precision;not null"`
+	TrainingHours  int       `json:"training_hours" gorm:"type:int;not null"`
+	TestHours      int       `json:"test_hours" gorm:"type:int;not null"`
+	GeneratedAt    time.Time `json:"generated_at" gorm:"type:timestamp;not null"`
+}
+
+// TableName specifies the table name for ForecastAccuracy
+func (ForecastAccuracy) TableName() string {
+	return "forecast_accuracies"
+}
+
+// Holiday represents a date on which an organization expects non-regular traffic
+type Holiday struct {
+	Base
+	OrganizationID string    `json:"organization_id" gorm:"type:varchar(36);uniqueIndex:idx_holidays_org_date;not null"`
+	Date           time.Time `json:"date" gorm:"type:date;uniqueIndex:idx_holidays_org_date;not null"`
+	Name           string    `json:"name" gorm:"type:varchar(255);not null"`
+}
+
+// TableName specifies the table name for Holiday
+func (Holiday) TableName() string {
+	return "holidays"
+}
+
+// ForecastResult is the response of a forecast query
+type ForecastResult struct {
+	ScopeType      string            `json:"scope_type"`
+	ScopeID        string            `json:"scope_id"`
+	Confidence     float64           `json:"confidence"`
+	Forecasts      []TrafficForecast `json:"forecasts"`
+	Accuracy       *ForecastAccuracy `json:"accuracy,omitempty"`
+	OrganizationID string            `json:"organization_id,omitempty"`
+}
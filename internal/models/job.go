@@ -0,0 +1,189 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Job statuses
+const (
+	JobPending   = "pending"
+	JobRunning   = "running"
+	JobCompleted = "completed"
+	JobFailed    = "failed"
+)
+
+// Job types
+const (
+	JobTypeLogExport         = "log_export"
+	JobTypeDerivedRebuild    = "derived_rebuild"
+	JobTypeThumbnailBackfill = "thumbnail_backfill"
+	JobTypeFaceBatchUpload   = "face_batch_upload"
+	JobTypeFaceDedup         = "face_dedup"
+	JobTypeStorageReconcile  = "storage_reconcile"
+)
+
+// Job represents a long-running background task started through the API
+type Job struct {
+	Base
+	OrganizationID string          `json:"organization_id" gorm:"type:varchar(36);index;not null"`
+	Type           string          `json:"type" gorm:"type:varchar(50);index;not null"`
+	Status         string          `json:"status" gorm:"type:varchar(20);index;not null;default:'pending'"`
+	Params         json.RawMessage `json:"params,omitempty" gorm:"type:jsonb"`
+	Result         json.RawMessage `json:"result,omitempty" gorm:"type:jsonb"`
+	Progress       int64           `json:"progress" gorm:"type:bigint;not null;default:0"`
+	Total          int64           `json:"total,omitempty" gorm:"type:bigint;not null;default:0"` // units of work, when known up front
+	Checkpoint     json.RawMessage `json:"checkpoint,omitempty" gorm:"type:jsonb"`                // where a resumable job continues after a failure
+	Error          string          `json:"error,omitempty" gorm:"type:text"`
+	StartedAt      *time.Time      `json:"started_at,omitempty" gorm:"type:timestamp"`
+	FinishedAt     *time.Time      `json:"finished_at,omitempty" gorm:"type:timestamp"`
+}
+
+// TableName specifies the table name for Job
+func (Job) TableName() string {
+	return "jobs"
+}
+
+// LogExportParams are the parameters of a log export job
+type LogExportParams struct {
+	Filter        LogFilter `json:"filter"`
+	Format        string    `json:"format"`
+	IncludeCamera bool      `json:"include_camera"`
+}
+
+// LogExportResult is the result of a completed log export job
+type LogExportResult struct {
+	ObjectKey   string `json:"object_key"`
+	FileName    string `json:"file_name"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+	Rows        int64  `json:"rows"`
+}
+
+// ThumbnailBackfillParams are the parameters of a thumbnail backfill job
+type ThumbnailBackfillParams struct {
+	OrganizationID string `json:"organization_id"`
+	Sizes          []int  `json:"sizes"`
+}
+
+// ThumbnailBackfillFailure is a face image whose thumbnails could not be generated
+type ThumbnailBackfillFailure struct {
+	FaceImageID string `json:"face_image_id"`
+	Error       string `json:"error"`
+}
+
+// ThumbnailBackfillResult is the result of a completed thumbnail backfill job
+type ThumbnailBackfillResult struct {
+	Images    int64                      `json:"images"`    // face images checked
+	Generated int64                      `json:"generated"` // face images that got new thumbnails
+	Skipped   int64                      `json:"skipped"`   // face images that already had every configured size
+	Failed    int64                      `json:"failed"`
+	Failures  []ThumbnailBackfillFailure `json:"failures,omitempty"`
+}
+
+// Face batch upload item statuses
+const (
+	FaceBatchItemCreated   = "created"
+	FaceBatchItemDuplicate = "duplicate" // skipped as a near-duplicate of face_image_id
+	FaceBatchItemFailed    = "failed"
+)
+
+// FaceBatchUploadParams are the parameters of a face batch upload job
+type FaceBatchUploadParams struct {
+	FileName string `json:"file_name"`
+	Format   string `json:"format"` // zip, tar or tar.gz
+	Items    int    `json:"items"`  // manifest entries
+}
+
+// FaceBatchItemResult is the outcome of one manifest entry of a face batch upload
+type FaceBatchItemResult struct {
+	File        string `json:"file"`
+	PersonHash  string `json:"person_hash,omitempty"`
+	CameraID    string `json:"camera_id,omitempty"`
+	Status      string `json:"status"`
+	FaceImageID string `json:"face_image_id,omitempty"`
+	Code        string `json:"code,omitempty"` // why the entry failed, e.g. file_not_found or dimensions_too_small
+	Error       string `json:"error,omitempty"`
+}
+
+// FaceBatchUploadResult is the result of a completed face batch upload job
+type FaceBatchUploadResult struct {
+	Items      int64                 `json:"items"`
+	Created    int64                 `json:"created"`
+	Duplicates int64                 `json:"duplicates"`
+	Failed     int64                 `json:"failed"`
+	Results    []FaceBatchItemResult `json:"results"` // in manifest order
+}
+
+// FaceDedupFailure is a face image that could not be hashed for near-duplicate detection
+type FaceDedupFailure struct {
+	FaceImageID string `json:"face_image_id"`
+	Error       string `json:"error"`
+}
+
+// FaceDedupResult is the result of a completed face near-duplicate cleanup job
+type FaceDedupResult struct {
+	Persons   int64              `json:"persons"`   // people whose images were compared
+	Images    int64              `json:"images"`    // images compared
+	Hashed    int64              `json:"hashed"`    // images that got a perceptual hash during the job
+	Collapsed int64              `json:"collapsed"` // near-duplicates removed, or linked to the image that was kept
+	Failed    int64              `json:"failed"`
+	Failures  []FaceDedupFailure `json:"failures,omitempty"`
+}
+
+// Storage reconcile actions
+const (
+	StorageReconcileReport     = "report"     // only report what is out of sync
+	StorageReconcileDelete     = "delete"     // delete orphan objects, or face images whose image file is missing
+	StorageReconcileQuarantine = "quarantine" // move orphan objects under {organization_id}/quarantine/
+)
+
+// StorageReconcileParams are the parameters of a storage reconcile job
+type StorageReconcileParams struct {
+	ObjectAction string `json:"object_action"` // report, delete or quarantine objects that no face image uses
+	ImageAction  string `json:"image_action"`  // report or delete face images whose image file is missing
+	DryRun       bool   `json:"dry_run"`
+}
+
+// StorageOrphanObject is a stored object of an organization that no face image uses
+type StorageOrphanObject struct {
+	Key     string    `json:"key"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+	Action  string    `json:"action,omitempty"` // deleted or quarantined, also in a dry run
+}
+
+// StorageMissingFile is a file of a face image that is not in storage
+type StorageMissingFile struct {
+	FaceImageID string `json:"face_image_id"`
+	PersonHash  string `json:"person_hash"`
+	Key         string `json:"key"`
+	Thumbnail   string `json:"thumbnail,omitempty"` // size of a missing thumbnail, empty for the image itself
+	Action      string `json:"action,omitempty"`    // deleted, also in a dry run
+}
+
+// StorageReconcileFailure is an object or face image that could not be deleted or quarantined
+type StorageReconcileFailure struct {
+	Key         string `json:"key,omitempty"`
+	FaceImageID string `json:"face_image_id,omitempty"`
+	Error       string `json:"error"`
+}
+
+// StorageReconcileResult is the result of a completed storage reconcile job.
+// A dry run counts and lists what would be deleted or quarantined without changing anything.
+type StorageReconcileResult struct {
+	DryRun             bool                      `json:"dry_run"`
+	Objects            int64                     `json:"objects"`        // objects listed
+	Images             int64                     `json:"images"`         // face images checked
+	OrphanObjects      int64                     `json:"orphan_objects"` // objects no face image uses
+	OrphanBytes        int64                     `json:"orphan_bytes"`
+	MissingFiles       int64                     `json:"missing_files"` // image and thumbnail files of face images that are not in storage
+	ObjectsDeleted     int64                     `json:"objects_deleted"`
+	ObjectsQuarantined int64                     `json:"objects_quarantined"`
+	ImagesDeleted      int64                     `json:"images_deleted"`
+	Failed             int64                     `json:"failed"`
+	Orphans            []StorageOrphanObject     `json:"orphans,omitempty"`
+	Missing            []StorageMissingFile      `json:"missing,omitempty"`
+	Truncated          bool                      `json:"truncated,omitempty"` // orphans or missing were cut to the first 1000
+	Failures           []StorageReconcileFailure `json:"failures,omitempty"`
+}
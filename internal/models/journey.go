@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// JourneyStop is a consecutive run of detections of a person by one camera within a visit
+type JourneyStop struct {
+	CameraID     string    `json:"camera_id"`
+	CameraName   string    `json:"camera_name"`
+	Zone         string    `json:"zone,omitempty"`
+	ArrivedAt    time.Time `json:"arrived_at"`
+	LeftAt       time.Time `json:"left_at"`
+	DwellSeconds int64     `json:"dwell_seconds"` // until the person was first seen by the next camera
+	Detections   int       `json:"detections"`
+}
+
+// JourneyVisit is one visit of a person: detections separated by less than the visit gap
+type JourneyVisit struct {
+	StartedAt       time.Time     `json:"started_at"`
+	EndedAt         time.Time     `json:"ended_at"`
+	DurationSeconds int64         `json:"duration_seconds"`
+	Detections      int           `json:"detections"`
+	Stops           []JourneyStop `json:"stops"`
+	FaceImageID     string        `json:"face_image_id,omitempty"`
+	ThumbnailURL    string        `json:"thumbnail_url,omitempty"`
+}
+
+// JourneyDay groups the visits that started on the same day
+type JourneyDay struct {
+	Date   string         `json:"date"`
+	Visits []JourneyVisit `json:"visits"`
+}
+
+// PersonJourney is the visit timeline of a person, newest visit first
+type PersonJourney struct {
+	PersonHash string            `json:"person_hash"`
+	Days       []JourneyDay      `json:"days"`
+	Pagination *CursorPagination `json:"pagination"`
+}
@@ -0,0 +1,50 @@
+package models
+
+// Built-in person labels
+const (
+	LabelStaff   = "staff" // employees and guards, excluded from visitor statistics
+	LabelVIP     = "vip"
+	LabelBlocked = "blocked"
+)
+
+// PersonLabel tags a person with a built-in or custom label
+type PersonLabel struct {
+	Base
+	OrganizationID string `json:"organization_id" gorm:"type:varchar(36);not null;uniqueIndex:idx_person_label"`
+	PersonHash     string `json:"person_hash" gorm:"type:varchar(255);not null;uniqueIndex:idx_person_label;index"`
+	Label          string `json:"label" gorm:"type:varchar(50);not null;uniqueIndex:idx_person_label;index"`
+	CreatedBy      string `json:"created_by,omitempty" gorm:"type:varchar(255)"`
+}
+
+// TableName specifies the table name for PersonLabel
+func (PersonLabel) TableName() string {
+	return "person_labels"
+}
+
+// PersonNote is a free-text note about a person
+type PersonNote struct {
+	Base
+	OrganizationID string `json:"organization_id" gorm:"type:varchar(36);not null;index"`
+	PersonHash     string `json:"person_hash" gorm:"type:varchar(255);not null;index"`
+	Body           string `json:"body" gorm:"type:text;not null"`
+	Author         string `json:"author" gorm:"type:varchar(255);not null"`
+}
+
+// TableName specifies the table name for PersonNote
+func (PersonNote) TableName() string {
+	return "person_notes"
+}
+
+// LabelCount is the number of persons carrying a label
+type LabelCount struct {
+	Label string `json:"label"`
+	Count int    `json:"count"`
+}
+
+// StatsScope selects which persons are counted in visitor statistics
+type StatsScope struct {
+	IncludeStaff  bool          // also count persons labelled as staff
+	SavedSearchID string        // only count persons matching this saved search
+	Search        *PersonSearch // filters of the saved search, resolved by the services
+	SearchKey     string        // identifies the saved search version in cache keys
+}
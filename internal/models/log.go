@@ -3,12 +3,25 @@
 package models
 
 // All models have been moved to their own files:
-// - base.go: Base, Pagination
+// - base.go: Base, Pagination, ListOptions, CursorPagination
 // - organization.go: Organization
 // - user.go: User
 // - api_key.go: APIKey
-// - camera.go: Camera
+// - camera.go: Camera, CameraFilter
 // - person_log.go: PersonLog, LogFilter
 // - face_image.go: FaceImage
 // - person.go: Person
-// - stats.go: DailySummary, HeatmapData, PersonStats
\ No newline at end of file
+// - stats.go: DailySummary, HeatmapData, PersonStats
+// - segment.go: SegmentSettings, SegmentDistribution, SegmentTrendPoint, SegmentMember
+// - anomaly.go: TrafficAnomaly, AnomalyFilter
+// - site.go: Site
+// - forecast.go: TrafficForecast, ForecastAccuracy, Holiday, ForecastResult
+// - occupancy.go: SitePresence, OccupancyThreshold, OccupancyEvent, OccupancyStatus, OccupancyPoint, OccupancyPeak
+// - stream.go: DetectionPayload, CounterPayload, CameraStatusPayload
+// - job.go: Job, LogExportParams, LogExportResult, ThumbnailBackfillParams, ThumbnailBackfillResult
+// - journey.go: PersonJourney, JourneyDay, JourneyVisit, JourneyStop
+// - label.go: PersonLabel, PersonNote, LabelCount, StatsScope
+// - person_operation.go: PersonOperation, PersonOperationSnapshot, PersonAlias
+// - watchlist.go: Watchlist, WatchlistEntry, WatchlistAlert, WatchlistAlertFilter
+// - search.go: PersonSearch, SavedSearch, FacetCount, PersonFacets
+// - rebuild.go: RebuildParams, RebuildChange, RebuildResult, RebuildCheckpoint
\ No newline at end of file
@@ -15,10 +15,41 @@ package models
 // - Person: Tracked person with identity
 // - PersonLog: Event log for person detection
 // - FaceImage: Stored image of a detected face
+// - SegmentSettings: Per-organization visitor segmentation thresholds
+// - TrafficAnomaly: Hour whose traffic deviates from the seasonal baseline
+// - Site: Physical location (store, branch) that groups cameras
+// - TrafficForecast: Predicted hourly traffic with confidence interval
+// - ForecastAccuracy: Backtest accuracy of the latest forecast model
+// - Holiday: Date with non-regular traffic used by the forecast
+// - SitePresence: Stay of a person inside a site from entry to exit
+// - OccupancyThreshold: Site capacity limit that raises events when crossed
+// - OccupancyEvent: Site occupancy crossing a capacity threshold
+// - PersonLabel: Built-in or custom label on a person (staff, vip, blocked, ...)
+// - PersonNote: Free-text note about a person with author and time
+// - PersonOperation: Audit record of a person merge or split that can be undone
+// - PersonAlias: Person hash merged away, mapped to the hash it was merged into
+// - Watchlist, WatchlistEntry: Per-organization list of persons to alert on, with reason, priority and expiry
+// - WatchlistAlert: Detection of a watchlisted person with open, acknowledged and resolved states
+// - SavedSearch: Named person search reusable as a dynamic segment in the stats endpoints
 //
 // DTO models for API:
 // - DailySummary: Daily statistics about visitors
 // - HeatmapData: Time-based density data
 // - PersonStats: Statistics about new vs returning visitors
+// - SegmentDistribution: Visit frequency and recency distributions
+// - ForecastResult: Hourly forecasts with accuracy metrics
+// - OccupancyStatus, OccupancyPoint, OccupancyPeak: Live, historical and peak site occupancy
+// - DetectionPayload, CounterPayload, CameraStatusPayload: Live stream event data
+// - Job: Background jobs started through the API (e.g. log exports)
+// - LogExportParams, LogExportResult: Parameters and result of a log export job
+// - RebuildParams, RebuildResult, RebuildChange, RebuildCheckpoint: Derived data rebuild job parameters, dry-run diff and resume point
+// - PersonJourney, JourneyDay, JourneyVisit, JourneyStop: Visit timeline of a person
+// - LabelCount: Number of persons per label
+// - StatsScope: Which persons are counted in visitor statistics
 // - LogFilter: Query parameters for filtering logs
-// - Pagination: Response structure for paginated results
\ No newline at end of file
+// - CameraFilter: Query parameters for filtering cameras
+// - WatchlistAlertFilter: Query parameters for filtering watchlist alerts
+// - PersonSearch: Filters for searching persons
+// - PersonFacets, FacetCount: Aggregate counts over the persons matching a search
+// - Pagination: Response structure for paginated results
+// - ListOptions, CursorPagination: Keyset pagination, sorting and projection for list endpoints
\ No newline at end of file
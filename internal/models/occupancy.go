@@ -0,0 +1,104 @@
+package models
+
+import "time"
+
+// Camera roles used to derive site occupancy
+const (
+	CameraRoleEntrance = "entrance"
+	CameraRoleExit     = "exit"
+	CameraRoleBoth     = "both"
+	CameraRoleInterior = "interior"
+)
+
+// Detection directions reported by entrance/exit cameras
+const (
+	DirectionIn  = "in"
+	DirectionOut = "out"
+)
+
+// Reasons a presence was closed
+const (
+	PresenceExitSeen = "exit"
+	PresenceTimeout  = "timeout"
+)
+
+// Occupancy threshold events
+const (
+	OccupancyEventExceeded = "exceeded"
+	OccupancyEventCleared  = "cleared"
+)
+
+// SitePresence represents one stay of a person inside a site, from entry to exit.
+// An open presence (ExitedAt is nil) counts towards the live occupancy until it
+// is closed by an exit detection or by the occupancy timeout.
+type SitePresence struct {
+	Base
+	OrganizationID string     `json:"organization_id" gorm:"type:varchar(36);index;not null"`
+	SiteID         string     `json:"site_id" gorm:"type:varchar(36);index:idx_site_presences_open;not null"`
+	PersonHash     string     `json:"person_hash" gorm:"type:varchar(255);index:idx_site_presences_open;not null"`
+	EnteredAt      time.Time  `json:"entered_at" gorm:"type:timestamp;index;not null"`
+	LastSeenAt     time.Time  `json:"last_seen_at" gorm:"type:timestamp;not null"`
+	ExitedAt       *time.Time `json:"exited_at,omitempty" gorm:"type:timestamp;index:idx_site_presences_open"`
+	ExitReason     string     `json:"exit_reason,omitempty" gorm:"type:varchar(20)"`
+}
+
+// TableName specifies the table name for SitePresence
+func (SitePresence) TableName() string {
+	return "site_presences"
+}
+
+// OccupancyThreshold is a capacity limit of a site that raises events when crossed
+type OccupancyThreshold struct {
+	Base
+	OrganizationID string `json:"organization_id" gorm:"type:varchar(36);index;not null"`
+	SiteID         string `json:"site_id" gorm:"type:varchar(36);index;not null"`
+	Name           string `json:"name" gorm:"type:varchar(255);not null"`
+	Occupancy      int    `json:"occupancy" gorm:"type:int;not null"`
+	Severity       string `json:"severity" gorm:"type:varchar(20);not null;default:'medium'"`
+	Exceeded       bool   `json:"exceeded" gorm:"type:boolean;not null;default:false"`
+}
+
+// TableName specifies the table name for OccupancyThreshold
+func (OccupancyThreshold) TableName() string {
+	return "occupancy_thresholds"
+}
+
+// OccupancyEvent records a site occupancy crossing one of its thresholds
+type OccupancyEvent struct {
+	Base
+	OrganizationID string    `json:"organization_id" gorm:"type:varchar(36);index;not null"`
+	SiteID         string    `json:"site_id" gorm:"type:varchar(36);index;not null"`
+	ThresholdID    string    `json:"threshold_id" gorm:"type:varchar(36);index;not null"`
+	Type           string    `json:"type" gorm:"type:varchar(20);not null"`
+	Occupancy      int       `json:"occupancy" gorm:"type:int;not null"`
+	Threshold      int       `json:"threshold" gorm:"type:int;not null"`
+	Severity       string    `json:"severity" gorm:"type:varchar(20);not null"`
+	OccurredAt     time.Time `json:"occurred_at" gorm:"type:timestamp;index;not null"`
+}
+
+// TableName specifies the table name for OccupancyEvent
+func (OccupancyEvent) TableName() string {
+	return "occupancy_events"
+}
+
+// OccupancyStatus is the live occupancy of a site
+type OccupancyStatus struct {
+	SiteID     string               `json:"site_id"`
+	Occupancy  int                  `json:"occupancy"`
+	AsOf       time.Time            `json:"as_of"`
+	Thresholds []OccupancyThreshold `json:"thresholds"`
+}
+
+// OccupancyPoint is the estimated occupancy of a site at a point in time
+type OccupancyPoint struct {
+	Time      time.Time `json:"time"`
+	Occupancy int       `json:"occupancy"`
+}
+
+// OccupancyPeak is the highest occupancy of a site on one day
+type OccupancyPeak struct {
+	Date    string    `json:"date"`
+	Peak    int       `json:"peak"`
+	PeakAt  time.Time `json:"peak_at"`
+	Entries int       `json:"entries"`
+}
@@ -2,22 +2,29 @@ package models
 
 import "time"
 
-// Person represents a tracked person entity with multiple face images
+// Person represents a tracked person entity with multiple face images.
+// A person is identified by (organization_id, person_hash): edge devices of different organizations may report the same hash.
 type Person struct {
 	Base
-	PersonHash     string      `json:"person_hash" gorm:"type:varchar(255);uniqueIndex;not null"`
-	FirstSeen      time.Time   `json:"first_seen" gorm:"type:timestamp;not null"`
-	LastSeen       time.Time   `json:"last_seen" gorm:"type:timestamp;not null"`
-	VisitCount     int         `json:"visit_count" gorm:"type:int;not null;default:0"`
-	OrganizationID string      `json:"organization_id" gorm:"type:varchar(36);index;not null"`
-	
+	PersonHash     string    `json:"person_hash" gorm:"type:varchar(255);uniqueIndex:idx_person_identity,priority:2;not null"`
+	FirstSeen      time.Time `json:"first_seen" gorm:"type:timestamp;not null"`
+	LastSeen       time.Time `json:"last_seen" gorm:"type:timestamp;not null"`
+	VisitCount     int       `json:"visit_count" gorm:"type:int;not null;default:0"`
+	OrganizationID string    `json:"organization_id" gorm:"type:varchar(36);uniqueIndex:idx_person_identity,priority:1;index;not null"`
+
+	// Face image shown for the person: the best quality image, unless pinned manually
+	PrimaryFaceImageID     *string `json:"primary_face_image_id,omitempty" gorm:"type:varchar(36);index"`
+	PrimaryFaceImagePinned bool    `json:"primary_face_image_pinned" gorm:"not null;default:false"`
+
 	// Relationships
-	Organization Organization `json:"organization,omitempty" gorm:"foreignKey:OrganizationID"`
-	FaceImages   []FaceImage  `json:"face_images,omitempty" gorm:"foreignKey:PersonHash;references:PersonHash"`
-	PersonLogs   []PersonLog  `json:"person_logs,omitempty" gorm:"foreignKey:PersonHash;references:PersonHash"`
+	Organization     Organization  `json:"organization,omitempty" gorm:"foreignKey:OrganizationID"`
+	FaceImages       []FaceImage   `json:"face_images,omitempty" gorm:"foreignKey:OrganizationID,PersonHash;references:OrganizationID,PersonHash"`
+	PrimaryFaceImage *FaceImage    `json:"primary_face_image,omitempty" gorm:"foreignKey:PrimaryFaceImageID;constraint:OnDelete:SET NULL"`
+	PersonLogs       []PersonLog   `json:"person_logs,omitempty" gorm:"foreignKey:OrganizationID,PersonHash;references:OrganizationID,PersonHash"`
+	Labels           []PersonLabel `json:"labels,omitempty" gorm:"foreignKey:OrganizationID,PersonHash;references:OrganizationID,PersonHash"`
 }
 
 // TableName specifies the table name for Person
 func (Person) TableName() string {
 	return "persons"
-}
\ No newline at end of file
+}
@@ -9,12 +9,14 @@ type PersonLog struct {
 	PersonHash     string    `json:"person_hash" gorm:"type:varchar(255);index;not null"`
 	CameraID       string    `json:"camera_id" gorm:"type:varchar(36);index;not null"`
 	IsNewPerson    bool      `json:"is_new_person" gorm:"type:boolean;not null;default:false"`
+	Direction      string    `json:"direction,omitempty" gorm:"type:varchar(10)"` // in, out or empty when unknown
+	ReportedHash   string    `json:"reported_hash,omitempty" gorm:"type:varchar(255);index"` // hash sent by the edge device when it was an alias of person_hash
 	OrganizationID string    `json:"organization_id" gorm:"type:varchar(36);index;not null"`
 
 	// Relationships
 	Camera       Camera       `json:"camera,omitempty" gorm:"foreignKey:CameraID"`
 	Organization Organization `json:"organization,omitempty" gorm:"foreignKey:OrganizationID"`
-	Person       Person       `json:"person,omitempty" gorm:"foreignKey:PersonHash;references:PersonHash"`
+	Person       Person       `json:"person,omitempty" gorm:"foreignKey:OrganizationID,PersonHash;references:OrganizationID,PersonHash"`
 }
 
 // TableName specifies the table name for PersonLog
@@ -27,8 +29,9 @@ type LogFilter struct {
 	From           time.Time `json:"from,omitempty"`
 	To             time.Time `json:"to,omitempty"`
 	CameraID       string    `json:"camera_id,omitempty"`
+	CameraIDs      []string  `json:"camera_ids,omitempty"`
+	Zones          []string  `json:"zones,omitempty"`
+	IsNewPerson    *bool     `json:"is_new_person,omitempty"`
 	PersonID       string    `json:"person_id,omitempty"`
 	OrganizationID string    `json:"organization_id,omitempty"`
-	Page           int       `json:"page,omitempty"`
-	PageSize       int       `json:"page_size,omitempty"`
-}
\ No newline at end of file
+}
@@ -0,0 +1,68 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Person operation types
+const (
+	PersonOperationMerge = "merge"
+	PersonOperationSplit = "split"
+)
+
+// Person operation statuses
+const (
+	PersonOperationApplied = "applied"
+	PersonOperationUndone  = "undone"
+)
+
+// PersonOperation is the audit record of a merge or split of person identities
+type PersonOperation struct {
+	Base
+	OrganizationID string          `json:"organization_id" gorm:"type:varchar(36);index;not null"`
+	Type           string          `json:"type" gorm:"type:varchar(20);index;not null"`
+	Status         string          `json:"status" gorm:"type:varchar(20);index;not null;default:'applied'"`
+	SourceHash     string          `json:"source_hash" gorm:"type:varchar(255);index;not null"` // merge: hash merged away, split: hash the logs were taken from
+	TargetHash     string          `json:"target_hash" gorm:"type:varchar(255);index;not null"` // merge: surviving hash, split: new hash
+	LogCount       int             `json:"log_count" gorm:"type:int;not null;default:0"`
+	FaceImageCount int             `json:"face_image_count" gorm:"type:int;not null;default:0"`
+	Reason         string          `json:"reason,omitempty" gorm:"type:text"`
+	PerformedBy    string          `json:"performed_by,omitempty" gorm:"type:varchar(255)"`
+	UndoneBy       string          `json:"undone_by,omitempty" gorm:"type:varchar(255)"`
+	UndoneAt       *time.Time      `json:"undone_at,omitempty" gorm:"type:timestamp"`
+	Snapshot       json.RawMessage `json:"snapshot,omitempty" gorm:"type:jsonb"` // PersonOperationSnapshot
+}
+
+// TableName specifies the table name for PersonOperation
+func (PersonOperation) TableName() string {
+	return "person_operations"
+}
+
+// PersonOperationSnapshot records what an operation changed so that it can be undone
+type PersonOperationSnapshot struct {
+	LogIDs                  []string         `json:"log_ids"`
+	FaceImageIDs            []string         `json:"face_image_ids"`
+	NewPersonLogIDs         []string         `json:"new_person_log_ids"`                  // logs of both persons flagged is_new_person before the operation
+	SourcePersonID          string           `json:"source_person_id,omitempty"`          // merge: person row removed by the merge
+	LabelIDs                []string         `json:"label_ids,omitempty"`                 // merge: labels moved to the target
+	DroppedLabels           []PersonLabel    `json:"dropped_labels,omitempty"`            // merge: labels the target already had
+	NoteIDs                 []string         `json:"note_ids,omitempty"`                  // merge: notes moved to the target
+	AliasIDs                []string         `json:"alias_ids,omitempty"`                 // merge: older aliases re-pointed to the target
+	WatchlistEntryIDs       []string         `json:"watchlist_entry_ids,omitempty"`       // merge: watchlist entries moved to the target
+	DroppedWatchlistEntries []WatchlistEntry `json:"dropped_watchlist_entries,omitempty"` // merge: entries on watchlists the target was already on
+}
+
+// PersonAlias maps a person hash that was merged away to the hash it was merged into
+type PersonAlias struct {
+	Base
+	OrganizationID string `json:"organization_id" gorm:"type:varchar(36);not null;uniqueIndex:idx_person_alias"`
+	AliasHash      string `json:"alias_hash" gorm:"type:varchar(255);not null;uniqueIndex:idx_person_alias"`
+	PersonHash     string `json:"person_hash" gorm:"type:varchar(255);not null;index"`
+	OperationID    string `json:"operation_id" gorm:"type:varchar(36);index;not null"`
+}
+
+// TableName specifies the table name for PersonAlias
+func (PersonAlias) TableName() string {
+	return "person_aliases"
+}
@@ -0,0 +1,48 @@
+package models
+
+import "time"
+
+// RebuildParams are the parameters of a derived data rebuild job.
+// Every person seen in [From, To) is rebuilt from its whole log history.
+type RebuildParams struct {
+	OrganizationID string     `json:"organization_id"`
+	From           *time.Time `json:"from,omitempty"`
+	To             *time.Time `json:"to,omitempty"`
+	DryRun         bool       `json:"dry_run"`
+	ChunkSize      int        `json:"chunk_size"` // persons per transaction
+}
+
+// Rebuild change fields
+const (
+	RebuildFieldPerson      = "person" // the person row is missing
+	RebuildFieldFirstSeen   = "first_seen"
+	RebuildFieldLastSeen    = "last_seen"
+	RebuildFieldVisitCount  = "visit_count"
+	RebuildFieldIsNewPerson = "is_new_person"
+)
+
+// RebuildChange is a derived value that differs from what the person logs imply
+type RebuildChange struct {
+	PersonHash string      `json:"person_hash"`
+	LogID      string      `json:"log_id,omitempty"` // only for is_new_person
+	Field      string      `json:"field"`
+	Old        interface{} `json:"old"`
+	New        interface{} `json:"new"`
+}
+
+// RebuildResult summarizes a derived data rebuild. A dry run counts and lists the changes without applying them.
+type RebuildResult struct {
+	DryRun           bool            `json:"dry_run"`
+	Persons          int64           `json:"persons"` // persons checked
+	PersonsCreated   int64           `json:"persons_created"`
+	PersonsUpdated   int64           `json:"persons_updated"`
+	LogsUpdated      int64           `json:"logs_updated"`
+	Changes          []RebuildChange `json:"changes,omitempty"`
+	ChangesTruncated bool            `json:"changes_truncated,omitempty"`
+}
+
+// RebuildCheckpoint is saved with every chunk so a failed rebuild can resume after the last rebuilt person
+type RebuildCheckpoint struct {
+	Cursor string        `json:"cursor"` // last person_hash rebuilt
+	Result RebuildResult `json:"result"`
+}
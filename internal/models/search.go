@@ -0,0 +1,55 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// PersonSearch is a set of person filters that must all match. Empty fields match everything.
+type PersonSearch struct {
+	FirstSeenFrom *time.Time `json:"first_seen_from,omitempty"`
+	FirstSeenTo   *time.Time `json:"first_seen_to,omitempty"`
+	LastSeenFrom  *time.Time `json:"last_seen_from,omitempty"`
+	LastSeenTo    *time.Time `json:"last_seen_to,omitempty"`
+	MinVisits     *int       `json:"min_visits,omitempty"`
+	MaxVisits     *int       `json:"max_visits,omitempty"`
+	CameraIDs     []string   `json:"camera_ids,omitempty"` // seen at any of these cameras
+	Zones         []string   `json:"zones,omitempty"`      // seen at a camera in any of these zones
+	Labels        []string   `json:"labels,omitempty"`     // carries any of these labels
+	HasFaceImages *bool      `json:"has_face_images,omitempty"`
+	NewWithinDays int        `json:"new_within_days,omitempty"` // first seen within the last N days, relative to when the search runs
+	SavedSearchID string     `json:"-"`                         // also apply the filters of this saved search
+}
+
+// SavedSearch is a named person search that can be reused as a dynamic segment in the stats endpoints
+type SavedSearch struct {
+	Base
+	OrganizationID string          `json:"organization_id" gorm:"type:varchar(36);index;not null"`
+	Name           string          `json:"name" gorm:"type:varchar(255);not null"`
+	Description    string          `json:"description,omitempty" gorm:"type:text"`
+	Filter         json.RawMessage `json:"filter" gorm:"type:jsonb;not null"` // PersonSearch
+	CreatedBy      string          `json:"created_by,omitempty" gorm:"type:varchar(255)"`
+}
+
+// TableName specifies the table name for SavedSearch
+func (SavedSearch) TableName() string {
+	return "saved_searches"
+}
+
+// FacetCount is the number of matching persons for one facet value
+type FacetCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// PersonFacets are aggregate counts over the persons matching a search
+type PersonFacets struct {
+	Total         int               `json:"total"`
+	Labels        []LabelCount      `json:"labels"`
+	Cameras       []FacetCount      `json:"cameras"`
+	Zones         []FacetCount      `json:"zones"`
+	WithFaces     int               `json:"with_face_images"`
+	WithoutFaces  int               `json:"without_face_images"`
+	Visits        []HistogramBucket `json:"visits"`
+	FirstSeenDays []HistogramBucket `json:"first_seen_days"` // days since first seen
+}
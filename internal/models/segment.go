@@ -0,0 +1,84 @@
+package models
+
+import "time"
+
+// Visitor segment names used by the RFM-style segmentation
+const (
+	SegmentOneTimer   = "one_timer"
+	SegmentOccasional = "occasional"
+	SegmentLoyal      = "loyal"
+	SegmentLapsed     = "lapsed"
+)
+
+// Segments lists all visitor segments in display order
+var Segments = []string{SegmentOneTimer, SegmentOccasional, SegmentLoyal, SegmentLapsed}
+
+// SegmentSettings stores the per-organization thresholds for visitor segmentation
+type SegmentSettings struct {
+	Base
+	OrganizationID      string `json:"organization_id" gorm:"type:varchar(36);uniqueIndex;not null"`
+	OneTimerMaxVisits   int    `json:"one_timer_max_visits" gorm:"type:int;not null;default:1"`
+	OccasionalMaxVisits int    `json:"occasional_max_visits" gorm:"type:int;not null;default:4"`
+	LapsedAfterDays     int    `json:"lapsed_after_days" gorm:"type:int;not null;default:30"`
+
+	// Relationships
+	Organization Organization `json:"organization,omitempty" gorm:"foreignKey:OrganizationID"`
+}
+
+// TableName specifies the table name for SegmentSettings
+func (SegmentSettings) TableName() string {
+	return "segment_settings"
+}
+
+// DefaultSegmentSettings returns the thresholds used when an organization has not configured its own
+func DefaultSegmentSettings(organizationID string) SegmentSettings {
+	return SegmentSettings{
+		OrganizationID:      organizationID,
+		OneTimerMaxVisits:   1,
+		OccasionalMaxVisits: 4,
+		LapsedAfterDays:     30,
+	}
+}
+
+// SegmentCount represents the number of persons in a segment
+type SegmentCount struct {
+	Segment string `json:"segment"`
+	Count   int    `json:"count"`
+}
+
+// HistogramBucket represents one bucket of a distribution
+type HistogramBucket struct {
+	Label string `json:"label"`
+	Count int    `json:"count"`
+}
+
+// SegmentDistribution represents visit frequency and recency distributions for an organization
+type SegmentDistribution struct {
+	AsOf                 string            `json:"as_of"`
+	TotalPersons         int               `json:"total_persons"`
+	Segments             []SegmentCount    `json:"segments"`
+	Frequency            []HistogramBucket `json:"frequency"`
+	Recency              []HistogramBucket `json:"recency"`
+	AvgDaysBetweenVisits float64           `json:"avg_days_between_visits"`
+	Settings             SegmentSettings   `json:"settings"`
+	OrganizationID       string            `json:"organization_id,omitempty"`
+}
+
+// SegmentTrendPoint represents segment counts at a point in time
+type SegmentTrendPoint struct {
+	Date       string `json:"date"`
+	OneTimer   int    `json:"one_timer"`
+	Occasional int    `json:"occasional"`
+	Loyal      int    `json:"loyal"`
+	Lapsed     int    `json:"lapsed"`
+}
+
+// SegmentMember represents a person that belongs to a segment
+type SegmentMember struct {
+	PersonHash           string    `json:"person_hash"`
+	VisitCount           int       `json:"visit_count"`
+	FirstSeen            time.Time `json:"first_seen"`
+	LastSeen             time.Time `json:"last_seen"`
+	DaysSinceLastVisit   int       `json:"days_since_last_visit"`
+	AvgDaysBetweenVisits float64   `json:"avg_days_between_visits"`
+}
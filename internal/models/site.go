@@ -0,0 +1,18 @@
+package models
+
+// Site represents a physical location (store, branch) that groups cameras
+type Site struct {
+	Base
+	Name           string `json:"name" gorm:"type:varchar(255);not null"`
+	Address        string `json:"address" gorm:"type:text"`
+	OrganizationID string `json:"organization_id" gorm:"type:varchar(36);index;not null"`
+
+	// Relationships
+	Organization Organization `json:"organization,omitempty" gorm:"foreignKey:OrganizationID"`
+	Cameras      []Camera     `json:"cameras,omitempty" gorm:"foreignKey:SiteID"`
+}
+
+// TableName specifies the table name for Site
+func (Site) TableName() string {
+	return "sites"
+}
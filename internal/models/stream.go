@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// DetectionPayload is the data of a live detection event
+type DetectionPayload struct {
+	ID          string    `json:"id"`
+	Timestamp   time.Time `json:"timestamp"`
+	PersonHash  string    `json:"person_hash"`
+	CameraID    string    `json:"camera_id"`
+	IsNewPerson bool      `json:"is_new_person"`
+	Direction   string    `json:"direction,omitempty"`
+}
+
+// CounterPayload is the data of a live per-minute counter event.
+// Counter events with a camera ID count that camera only; otherwise they cover the whole organization.
+type CounterPayload struct {
+	Minute        time.Time `json:"minute"`
+	Detections    int       `json:"detections"`
+	UniquePersons int       `json:"unique_persons"`
+	NewPersons    int       `json:"new_persons"`
+}
+
+// CameraStatusPayload is the data of a live camera status change event
+type CameraStatusPayload struct {
+	CameraID       string `json:"camera_id"`
+	Name           string `json:"name"`
+	PreviousStatus string `json:"previous_status"`
+	Status         string `json:"status"`
+}
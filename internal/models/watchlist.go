@@ -0,0 +1,81 @@
+package models
+
+import "time"
+
+// Watchlist alert statuses
+const (
+	WatchlistAlertOpen         = "open"
+	WatchlistAlertAcknowledged = "acknowledged"
+	WatchlistAlertResolved     = "resolved"
+)
+
+// Watchlist is a named list of persons whose detections raise alerts, e.g. known shoplifters or VIP customers
+type Watchlist struct {
+	Base
+	OrganizationID string `json:"organization_id" gorm:"type:varchar(36);index;not null"`
+	Name           string `json:"name" gorm:"type:varchar(255);not null"`
+	Description    string `json:"description,omitempty" gorm:"type:text"`
+}
+
+// TableName specifies the table name for Watchlist
+func (Watchlist) TableName() string {
+	return "watchlists"
+}
+
+// WatchlistEntry puts a person on a watchlist until it expires
+type WatchlistEntry struct {
+	Base
+	OrganizationID string     `json:"organization_id" gorm:"type:varchar(36);index;not null"`
+	WatchlistID    string     `json:"watchlist_id" gorm:"type:varchar(36);not null;uniqueIndex:idx_watchlist_entry"`
+	PersonHash     string     `json:"person_hash" gorm:"type:varchar(255);not null;uniqueIndex:idx_watchlist_entry;index"`
+	Reason         string     `json:"reason,omitempty" gorm:"type:text"`
+	Priority       string     `json:"priority" gorm:"type:varchar(20);not null;default:'medium'"` // low, medium, high or critical
+	ExpiresAt      *time.Time `json:"expires_at,omitempty" gorm:"type:timestamp;index"`           // nil never expires
+	CreatedBy      string     `json:"created_by,omitempty" gorm:"type:varchar(255)"`
+}
+
+// TableName specifies the table name for WatchlistEntry
+func (WatchlistEntry) TableName() string {
+	return "watchlist_entries"
+}
+
+// WatchlistAlert is raised when a person on a watchlist is detected.
+// Detections of the same entry within the alert cooldown update the unresolved alert instead of raising a new one.
+type WatchlistAlert struct {
+	Base
+	OrganizationID string     `json:"organization_id" gorm:"type:varchar(36);index;not null"`
+	WatchlistID    string     `json:"watchlist_id" gorm:"type:varchar(36);index;not null"`
+	EntryID        string     `json:"entry_id" gorm:"type:varchar(36);index;not null"`
+	PersonHash     string     `json:"person_hash" gorm:"type:varchar(255);index;not null"`
+	Priority       string     `json:"priority" gorm:"type:varchar(20);index;not null"`
+	Reason         string     `json:"reason,omitempty" gorm:"type:text"`
+	CameraID       string     `json:"camera_id" gorm:"type:varchar(36);not null"` // camera of the first detection
+	LogID          string     `json:"log_id" gorm:"type:varchar(36);not null"`
+	DetectedAt     time.Time  `json:"detected_at" gorm:"type:timestamp;index;not null"`
+	LastCameraID   string     `json:"last_camera_id" gorm:"type:varchar(36);not null"`
+	LastSeenAt     time.Time  `json:"last_seen_at" gorm:"type:timestamp;not null"`
+	DetectionCount int        `json:"detection_count" gorm:"type:int;not null;default:1"`
+	FaceImageID    string     `json:"face_image_id,omitempty" gorm:"type:varchar(36)"`
+	FaceImageKey   string     `json:"-" gorm:"type:varchar(512)"`        // storage object key of the face image
+	FaceImageURL   string     `json:"face_image_url,omitempty" gorm:"-"` // signed when the alert is returned or sent
+	Status         string     `json:"status" gorm:"type:varchar(20);index;not null;default:'open'"`
+	AcknowledgedBy string     `json:"acknowledged_by,omitempty" gorm:"type:varchar(255)"`
+	AcknowledgedAt *time.Time `json:"acknowledged_at,omitempty" gorm:"type:timestamp"`
+	ResolvedBy     string     `json:"resolved_by,omitempty" gorm:"type:varchar(255)"`
+	ResolvedAt     *time.Time `json:"resolved_at,omitempty" gorm:"type:timestamp"`
+	Resolution     string     `json:"resolution,omitempty" gorm:"type:text"`
+}
+
+// TableName specifies the table name for WatchlistAlert
+func (WatchlistAlert) TableName() string {
+	return "watchlist_alerts"
+}
+
+// WatchlistAlertFilter is used for filtering watchlist alerts. Empty fields match everything.
+type WatchlistAlertFilter struct {
+	Statuses     []string `json:"statuses,omitempty"`
+	WatchlistIDs []string `json:"watchlist_ids,omitempty"`
+	PersonHashes []string `json:"person_hashes,omitempty"`
+	CameraIDs    []string `json:"camera_ids,omitempty"`
+	Priorities   []string `json:"priorities,omitempty"`
+}
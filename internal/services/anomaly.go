@@ -0,0 +1,366 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/alert"
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/db"
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm/clause"
+)
+
+// minBaselinePoints คือจำนวนสัปดาห์ย้อนหลังขั้นต่ำที่ต้องมีก่อนจะตัดสินว่าผิดปกติ
+const minBaselinePoints = 2
+
+// AnomalyService ให้บริการตรวจจับปริมาณคนที่ผิดปกติเทียบกับค่าฐานตามฤดูกาล
+type AnomalyService struct {
+	DB            *db.PostgresDB
+	Notifier      alert.Notifier
+	BaselineWeeks int
+	ZThreshold    float64
+}
+
+// NewAnomalyService สร้าง AnomalyService ใหม่
+func NewAnomalyService(postgres *db.PostgresDB, notifier alert.Notifier, baselineWeeks int, zThreshold float64) *AnomalyService {
+	if baselineWeeks < minBaselinePoints {
+		baselineWeeks = minBaselinePoints
+	}
+	if zThreshold <= 0 {
+		zThreshold = 3
+	}
+	return &AnomalyService{
+		DB:            postgres,
+		Notifier:      notifier,
+		BaselineWeeks: baselineWeeks,
+		ZThreshold:    zThreshold,
+	}
+}
+
+// EvaluateBaseline คำนวณค่าเฉลี่ย ส่วนเบี่ยงเบนมาตรฐาน และ z-score ของค่าที่สังเกตได้เทียบกับค่าฐาน
+func EvaluateBaseline(observed int, baseline []int) (mean, stdDev, zScore float64) {
+	if len(baseline) == 0 {
+		return 0, 0, 0
+	}
+
+	for _, v := range baseline {
+		mean += float64(v)
+	}
+	mean /= float64(len(baseline))
+
+	var variance float64
+	for _, v := range baseline {
+		variance += (float64(v) - mean) * (float64(v) - mean)
+	}
+	stdDev = math.Sqrt(variance / float64(len(baseline)))
+
+	// ข้อมูลนับจำนวนมีความแปรปรวนตามธรรมชาติอย่างน้อยเท่ากับ Poisson
+	// จึงไม่ให้ส่วนเบี่ยงเบนต่ำกว่า sqrt(mean) หรือ 1 เพื่อไม่ให้ค่าฐานที่นิ่งมากแจ้งเตือนเกินจริง
+	stdDev = math.Max(stdDev, math.Max(math.Sqrt(mean), 1))
+	zScore = (float64(observed) - mean) / stdDev
+
+	return mean, stdDev, zScore
+}
+
+// AnomalySeverity แปลง z-score เป็นระดับความรุนแรง หรือค่าว่างถ้ายังไม่ถึงเกณฑ์
+func AnomalySeverity(zScore, threshold float64) string {
+	abs := math.Abs(zScore)
+	switch {
+	case abs < threshold:
+		return ""
+	case abs < threshold*1.5:
+		return models.SeverityMedium
+	case abs < threshold*2:
+		return models.SeverityHigh
+	default:
+		return models.SeverityCritical
+	}
+}
+
+// baselineRow เป็นจำนวนคนของขอบเขตหนึ่งในช่วงเวลาย้อนหลัง k สัปดาห์
+type baselineRow struct {
+	CameraID   string
+	CameraName string
+	K          int
+	Count      int
+}
+
+// DetectForHour ตรวจหาความผิดปกติของชั่วโมงที่กำหนดสำหรับองค์กร ทั้งรายกล้องและภาพรวม
+func (s *AnomalyService) DetectForHour(ctx context.Context, organizationID string, hourStart time.Time) ([]models.TrafficAnomaly, error) {
+	hourStart = hourStart.Truncate(time.Hour)
+	args := map[string]interface{}{
+		"org_id":     organizationID,
+		"hour_start": hourStart,
+		"weeks":      s.BaselineWeeks,
+	}
+
+	// จำนวนคนต่อกล้องในชั่วโมงเดียวกันของวันเดียวกันในสัปดาห์ก่อนๆ (k = 0 คือชั่วโมงปัจจุบัน) ไม่นับพนักงาน
+	// ไม่นับสัปดาห์ที่กล้องยังไม่ถูกลงทะเบียน เพราะไม่มีข้อมูลให้เปรียบเทียบ
+	var cameraRows []baselineRow
+	if err := s.DB.DB.WithContext(ctx).Raw(`
+		WITH cams AS (
+			SELECT id, name, created_at FROM cameras
+			WHERE organization_id = @org_id AND deleted_at IS NULL AND status = 'active'
+		),
+		slots AS (
+			SELECT k, @hour_start::timestamp - k * INTERVAL '7 days' AS slot_start
+			FROM generate_series(0, @weeks) AS k
+		)
+		SELECT cams.id AS camera_id, cams.name AS camera_name, slots.k, COUNT(l.id) AS count
+		FROM cams
+		CROSS JOIN slots
+		LEFT JOIN person_logs l ON l.camera_id = cams.id
+			AND l.organization_id = @org_id
+			AND l.deleted_at IS NULL
+			AND l.timestamp >= slots.slot_start
+			AND l.timestamp < slots.slot_start + INTERVAL '1 hour'
+			AND `+staffCondition("l.person_hash", "l.organization_id")+`
+		WHERE slots.k = 0 OR cams.created_at <= slots.slot_start
+		GROUP BY cams.id, cams.name, slots.k
+		ORDER BY cams.id, slots.k
+	`, args).Scan(&cameraRows).Error; err != nil {
+		return nil, fmt.Errorf("ไม่สามารถดึงข้อมูลค่าฐานรายกล้อง: %w", err)
+	}
+
+	// จำนวนคนรวมทั้งองค์กรในช่วงเวลาเดียวกัน
+	var orgRows []baselineRow
+	if err := s.DB.DB.WithContext(ctx).Raw(`
+		WITH slots AS (
+			SELECT k, @hour_start::timestamp - k * INTERVAL '7 days' AS slot_start
+			FROM generate_series(0, @weeks) AS k
+		)
+		SELECT '' AS camera_id, '' AS camera_name, slots.k, COUNT(l.id) AS count
+		FROM slots
+		LEFT JOIN person_logs l ON l.organization_id = @org_id
+			AND l.deleted_at IS NULL
+			AND l.timestamp >= slots.slot_start
+			AND l.timestamp < slots.slot_start + INTERVAL '1 hour'
+			AND `+staffCondition("l.person_hash", "l.organization_id")+`
+		WHERE slots.k = 0 OR slots.slot_start >= (SELECT created_at FROM organizations WHERE id = @org_id)
+		GROUP BY slots.k
+		ORDER BY slots.k
+	`, args).Scan(&orgRows).Error; err != nil {
+		return nil, fmt.Errorf("ไม่สามารถดึงข้อมูลค่าฐานขององค์กร: %w", err)
+	}
+
+	// จัดกลุ่มข้อมูลตามขอบเขต (กล้องหรือทั้งองค์กร)
+	type scope struct {
+		name     string
+		observed int
+		baseline []int
+	}
+	scopes := make(map[string]*scope)
+	order := make([]string, 0)
+	for _, row := range append(cameraRows, orgRows...) {
+		sc, ok := scopes[row.CameraID]
+		if !ok {
+			sc = &scope{name: row.CameraName}
+			scopes[row.CameraID] = sc
+			order = append(order, row.CameraID)
+		}
+		if row.K == 0 {
+			sc.observed = row.Count
+		} else {
+			sc.baseline = append(sc.baseline, row.Count)
+		}
+	}
+
+	// ประเมินแต่ละขอบเขตเทียบกับค่าฐาน
+	anomalies := make([]models.TrafficAnomaly, 0)
+	names := make(map[string]string)
+	for _, cameraID := range order {
+		sc := scopes[cameraID]
+		if len(sc.baseline) < minBaselinePoints {
+			continue
+		}
+
+		mean, stdDev, zScore := EvaluateBaseline(sc.observed, sc.baseline)
+		severity := AnomalySeverity(zScore, s.ZThreshold)
+		if severity == "" {
+			continue
+		}
+
+		direction := models.AnomalySpike
+		if zScore < 0 {
+			direction = models.AnomalyDrop
+		}
+
+		anomaly := models.TrafficAnomaly{
+			Base: models.Base{
+				ID: uuid.New().String(),
+			},
+			OrganizationID: organizationID,
+			CameraID:       cameraID,
+			HourStart:      hourStart,
+			Observed:       sc.observed,
+			Expected:       math.Round(mean*100) / 100,
+			StdDev:         math.Round(stdDev*100) / 100,
+			ZScore:         math.Round(zScore*100) / 100,
+			Direction:      direction,
+			Severity:       severity,
+			BaselineWeeks:  len(sc.baseline),
+		}
+
+		// บันทึกเฉพาะรายการใหม่ เพื่อให้รันซ้ำกับชั่วโมงเดิมได้โดยไม่แจ้งเตือนซ้ำ
+		result := s.DB.DB.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&anomaly)
+		if result.Error != nil {
+			return nil, fmt.Errorf("ไม่สามารถบันทึกความผิดปกติ: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			continue
+		}
+
+		anomalies = append(anomalies, anomaly)
+		names[anomaly.ID] = sc.name
+	}
+
+	// ส่งการแจ้งเตือนสำหรับความผิดปกติที่พบใหม่
+	for _, anomaly := range anomalies {
+		s.notify(ctx, anomaly, names[anomaly.ID])
+	}
+
+	return anomalies, nil
+}
+
+// notify ส่งการแจ้งเตือนของความผิดปกติผ่านช่องทางที่ตั้งค่าไว้
+func (s *AnomalyService) notify(ctx context.Context, anomaly models.TrafficAnomaly, cameraName string) {
+	if s.Notifier == nil {
+		return
+	}
+
+	target := "ทั้งองค์กร"
+	if anomaly.CameraID != "" {
+		target = fmt.Sprintf("กล้อง %s (%s)", cameraName, anomaly.CameraID)
+	}
+
+	kind := "สูงผิดปกติ"
+	if anomaly.Direction == models.AnomalyDrop {
+		kind = "ต่ำผิดปกติ"
+	}
+
+	if err := s.Notifier.Notify(ctx, alert.Alert{
+		Type:           "traffic_anomaly",
+		Severity:       anomaly.Severity,
+		OrganizationID: anomaly.OrganizationID,
+		Title:          fmt.Sprintf("ปริมาณคน%s ที่%s", kind, target),
+		Message: fmt.Sprintf("ชั่วโมง %s พบ %d คน จากค่าปกติ %.1f (z-score %.2f)",
+			anomaly.HourStart.Format("2006-01-02 15:04"), anomaly.Observed, anomaly.Expected, anomaly.ZScore),
+		Data:      anomaly,
+		CreatedAt: time.Now(),
+	}); err != nil {
+		log.Printf("ไม่สามารถส่งการแจ้งเตือนความผิดปกติ %s: %v", anomaly.ID, err)
+	}
+}
+
+// RunDetection ตรวจหาความผิดปกติของชั่วโมงที่กำหนดสำหรับทุกองค์กร
+func (s *AnomalyService) RunDetection(ctx context.Context, hourStart time.Time) error {
+	var organizationIDs []string
+	if err := s.DB.DB.WithContext(ctx).Model(&models.Organization{}).Pluck("id", &organizationIDs).Error; err != nil {
+		return fmt.Errorf("ไม่สามารถดึงรายการองค์กร: %w", err)
+	}
+
+	for _, organizationID := range organizationIDs {
+		anomalies, err := s.DetectForHour(ctx, organizationID, hourStart)
+		if err != nil {
+			log.Printf("ไม่สามารถตรวจหาความผิดปกติขององค์กร %s: %v", organizationID, err)
+			continue
+		}
+		if len(anomalies) > 0 {
+			log.Printf("พบความผิดปกติ %d รายการขององค์กร %s", len(anomalies), organizationID)
+		}
+	}
+
+	return nil
+}
+
+// StartDetectionJob เริ่มการตรวจหาความผิดปกติของชั่วโมงที่ผ่านมาเป็นระยะ
+func (s *AnomalyService) StartDetectionJob(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = time.Hour
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			// ตรวจชั่วโมงล่าสุดที่จบไปแล้ว
+			lastHour := time.Now().Truncate(time.Hour).Add(-time.Hour)
+			if err := s.RunDetection(ctx, lastHour); err != nil {
+				log.Printf("ไม่สามารถตรวจหาความผิดปกติ: %v", err)
+			}
+
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				log.Println("การตรวจหาความผิดปกติถูกยกเลิก")
+				return
+			}
+		}
+	}()
+}
+
+// ListAnomalies ดึงรายการความผิดปกติตามเงื่อนไข
+func (s *AnomalyService) ListAnomalies(ctx context.Context, filter models.AnomalyFilter) ([]models.TrafficAnomaly, *models.Pagination, error) {
+	// จัดการ pagination
+	if filter.Page <= 0 {
+		filter.Page = 1
+	}
+	if filter.PageSize <= 0 {
+		filter.PageSize = 10
+	}
+	offset := (filter.Page - 1) * filter.PageSize
+
+	// สร้าง query ด้วย GORM
+	query := s.DB.DB.WithContext(ctx).Model(&models.TrafficAnomaly{}).
+		Where("organization_id = ?", filter.OrganizationID)
+
+	// เพิ่มเงื่อนไขการค้นหา
+	if !filter.From.IsZero() {
+		query = query.Where("hour_start >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		query = query.Where("hour_start < ?", filter.To)
+	}
+	if filter.CameraID != "" {
+		query = query.Where("camera_id = ?", filter.CameraID)
+	}
+	if filter.Severity != "" {
+		query = query.Where("severity = ?", filter.Severity)
+	}
+
+	// นับจำนวนรายการทั้งหมด
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, nil, fmt.Errorf("ไม่สามารถนับจำนวนความผิดปกติ: %w", err)
+	}
+
+	// ดึงข้อมูลพร้อม pagination
+	var anomalies []models.TrafficAnomaly
+	if err := query.Order("hour_start DESC").
+		Limit(filter.PageSize).
+		Offset(offset).
+		Find(&anomalies).Error; err != nil {
+		return nil, nil, fmt.Errorf("ไม่สามารถดึงรายการความผิดปกติ: %w", err)
+	}
+
+	// คำนวณจำนวนหน้าทั้งหมด
+	totalPage := int(total) / filter.PageSize
+	if int(total)%filter.PageSize > 0 {
+		totalPage++
+	}
+
+	// สร้างข้อมูล pagination
+	pagination := &models.Pagination{
+		Total:     int(total),
+		Page:      filter.Page,
+		PageSize:  filter.PageSize,
+		TotalPage: totalPage,
+	}
+
+	return anomalies, pagination, nil
+}
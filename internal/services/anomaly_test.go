@@ -0,0 +1,39 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestEvaluateBaseline ทดสอบการคำนวณค่าฐานและ z-score
+func TestEvaluateBaseline(t *testing.T) {
+	mean, stdDev, zScore := EvaluateBaseline(100, []int{90, 110, 100, 100})
+	assert.InDelta(t, 100, mean, 0.001)
+	assert.InDelta(t, 10, stdDev, 0.001) // ถูกยกขึ้นเป็น sqrt(mean)
+	assert.InDelta(t, 0, zScore, 0.001)
+
+	// กล้องดับ: ไม่มีคนเลยเทียบกับค่าปกติ 100 คน
+	_, _, zScore = EvaluateBaseline(0, []int{100, 100, 100, 100})
+	assert.Less(t, zScore, -9.0)
+
+	// ปริมาณคนเพิ่มขึ้น 10 เท่า
+	_, _, zScore = EvaluateBaseline(1000, []int{100, 100, 100, 100})
+	assert.Greater(t, zScore, 80.0)
+
+	// ค่าฐานเป็นศูนย์ทั้งหมดไม่ทำให้หารด้วยศูนย์
+	mean, stdDev, zScore = EvaluateBaseline(2, []int{0, 0, 0})
+	assert.Equal(t, 0.0, mean)
+	assert.Equal(t, 1.0, stdDev)
+	assert.Equal(t, 2.0, zScore)
+}
+
+// TestAnomalySeverity ทดสอบการแปลง z-score เป็นระดับความรุนแรง
+func TestAnomalySeverity(t *testing.T) {
+	assert.Equal(t, "", AnomalySeverity(2.9, 3))
+	assert.Equal(t, models.SeverityMedium, AnomalySeverity(3, 3))
+	assert.Equal(t, models.SeverityMedium, AnomalySeverity(-4, 3))
+	assert.Equal(t, models.SeverityHigh, AnomalySeverity(5, 3))
+	assert.Equal(t, models.SeverityCritical, AnomalySeverity(-10, 3))
+}
@@ -2,9 +2,12 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log"
 
 	"github.com/bemindtech/bmt-manta-dashboard-service/internal/db"
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/events"
 	"github.com/bemindtech/bmt-manta-dashboard-service/internal/models"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
@@ -12,13 +15,15 @@ import (
 
 // CameraService ให้บริการเกี่ยวกับการจัดการกล้อง
 type CameraService struct {
-	DB *db.PostgresDB
+	DB     *db.PostgresDB
+	Events *events.Broker
 }
 
 // NewCameraService สร้าง CameraService ใหม่
-func NewCameraService(postgres *db.PostgresDB) *CameraService {
+func NewCameraService(postgres *db.PostgresDB, broker *events.Broker) *CameraService {
 	return &CameraService{
-		DB: postgres,
+		DB:     postgres,
+		Events: broker,
 	}
 }
 
@@ -34,6 +39,19 @@ func (s *CameraService) CreateCamera(ctx context.Context, camera *models.Camera)
 		camera.Status = "active"
 	}
 
+	// กำหนดบทบาทเริ่มต้นเป็นกล้องภายใน ถ้าไม่ระบุ
+	if camera.Role == "" {
+		camera.Role = models.CameraRoleInterior
+	}
+	if !IsValidCameraRole(camera.Role) {
+		return fmt.Errorf("บทบาทของกล้องไม่ถูกต้อง")
+	}
+
+	// ตรวจสอบว่าสาขาที่ระบุเป็นขององค์กรเดียวกัน
+	if err := s.validateSite(ctx, camera); err != nil {
+		return err
+	}
+
 	// เพิ่มกล้องลงในฐานข้อมูลด้วย GORM
 	// GORM จะจัดการกับ created_at และ updated_at โดยอัตโนมัติ
 	if err := s.DB.DB.WithContext(ctx).Create(camera).Error; err != nil {
@@ -60,12 +78,30 @@ func (s *CameraService) GetCamera(ctx context.Context, id, organizationID string
 
 // UpdateCamera อัปเดตข้อมูลกล้อง
 func (s *CameraService) UpdateCamera(ctx context.Context, camera *models.Camera) error {
+	if !IsValidCameraRole(camera.Role) {
+		return fmt.Errorf("บทบาทของกล้องไม่ถูกต้อง")
+	}
+
+	// ตรวจสอบว่าสาขาที่ระบุเป็นขององค์กรเดียวกัน
+	if err := s.validateSite(ctx, camera); err != nil {
+		return err
+	}
+
+	// ดึงสถานะเดิมเพื่อตรวจว่าสถานะของกล้องเปลี่ยนหรือไม่
+	var statuses []string
+	if err := s.DB.DB.WithContext(ctx).Model(&models.Camera{}).Where("id = ? AND organization_id = ?", camera.ID, camera.OrganizationID).Pluck("status", &statuses).Error; err != nil {
+		return fmt.Errorf("ไม่สามารถดึงสถานะของกล้อง: %w", err)
+	}
+
 	// อัปเดตกล้องด้วย GORM
 	// เลือกเฉพาะฟิลด์ที่ต้องการอัปเดต และอัปเดตเฉพาะกล้องที่เป็นขององค์กรนั้น
 	result := s.DB.DB.WithContext(ctx).Model(&models.Camera{}).Where("id = ? AND organization_id = ?", camera.ID, camera.OrganizationID).Updates(map[string]interface{}{
 		"name":     camera.Name,
 		"location": camera.Location,
 		"status":   camera.Status,
+		"site_id":  camera.SiteID,
+		"role":     camera.Role,
+		"zone":     camera.Zone,
 	})
 
 	if result.Error != nil {
@@ -76,9 +112,34 @@ func (s *CameraService) UpdateCamera(ctx context.Context, camera *models.Camera)
 		return fmt.Errorf("ไม่พบกล้องที่ต้องการอัปเดต")
 	}
 
+	// แจ้งการเปลี่ยนสถานะของกล้องไปยัง live stream
+	if len(statuses) > 0 && statuses[0] != camera.Status {
+		s.publishStatusChange(ctx, camera, statuses[0])
+	}
+
 	return nil
 }
 
+// publishStatusChange ส่งเหตุการณ์การเปลี่ยนสถานะของกล้องไปยัง live stream
+func (s *CameraService) publishStatusChange(ctx context.Context, camera *models.Camera, previousStatus string) {
+	if s.Events == nil {
+		return
+	}
+
+	event, err := events.NewEvent(events.TypeCameraStatus, camera.OrganizationID, camera.ID, camera.Zone, models.CameraStatusPayload{
+		CameraID:       camera.ID,
+		Name:           camera.Name,
+		PreviousStatus: previousStatus,
+		Status:         camera.Status,
+	})
+	if err == nil {
+		err = s.Events.Publish(ctx, event)
+	}
+	if err != nil {
+		log.Printf("ไม่สามารถส่งเหตุการณ์สถานะของกล้อง %s: %v", camera.ID, err)
+	}
+}
+
 // DeleteCamera ลบกล้อง
 func (s *CameraService) DeleteCamera(ctx context.Context, id, organizationID string) error {
 	// เพิ่มการตรวจสอบว่ามีการใช้งานอยู่หรือไม่ เช่น ตรวจสอบจำนวนข้อมูล logs ก่อนลบ
@@ -114,42 +175,75 @@ func (s *CameraService) DeleteCamera(ctx context.Context, id, organizationID str
 	return nil
 }
 
-// ListCameras ดึงรายการกล้องทั้งหมดขององค์กร
-func (s *CameraService) ListCameras(ctx context.Context, organizationID string, page, pageSize int) ([]models.Camera, *models.Pagination, error) {
-	// คำนวณ offset
-	if page <= 0 {
-		page = 1
+// cameraListSpec กำหนดการเรียงลำดับและฟิลด์ที่เลือกได้ของรายการกล้อง
+var cameraListSpec = listSpec[models.Camera]{
+	Sorts: map[string]sortField{
+		"name":       {Column: "name", Kind: sortKindString},
+		"created_at": {Column: "created_at", Kind: sortKindTime},
+	},
+	DefaultSort:  "name",
+	DefaultOrder: "asc",
+	Fields: map[string]string{
+		"id":              "id",
+		"created_at":      "created_at",
+		"updated_at":      "updated_at",
+		"name":            "name",
+		"location":        "location",
+		"status":          "status",
+		"organization_id": "organization_id",
+		"site_id":         "site_id",
+		"role":            "role",
+		"zone":            "zone",
+	},
+	Key: func(camera models.Camera, sort string) (interface{}, string) {
+		if sort == "created_at" {
+			return camera.CreatedAt, camera.ID
+		}
+		return camera.Name, camera.ID
+	},
+}
+
+// ListCameras ดึงรายการกล้องขององค์กรตามเงื่อนไข โดยแบ่งหน้าด้วย cursor
+func (s *CameraService) ListCameras(ctx context.Context, organizationID string, filter models.CameraFilter, opts models.ListOptions) ([]models.Camera, *models.CursorPagination, error) {
+	query := s.DB.DB.WithContext(ctx).Model(&models.Camera{}).Where("organization_id = ?", organizationID)
+	if len(filter.Statuses) > 0 {
+		query = query.Where("status IN ?", filter.Statuses)
 	}
-	if pageSize <= 0 {
-		pageSize = 10
+	if len(filter.Zones) > 0 {
+		query = query.Where("zone IN ?", filter.Zones)
 	}
-	offset := (page - 1) * pageSize
-
-	// นับจำนวนกล้องทั้งหมดด้วย GORM
-	var total int64
-	if err := s.DB.DB.WithContext(ctx).Model(&models.Camera{}).Where("organization_id = ?", organizationID).Count(&total).Error; err != nil {
-		return nil, nil, fmt.Errorf("ไม่สามารถนับจำนวนกล้อง: %w", err)
+	if len(filter.SiteIDs) > 0 {
+		query = query.Where("site_id IN ?", filter.SiteIDs)
+	}
+	if len(filter.Roles) > 0 {
+		query = query.Where("role IN ?", filter.Roles)
 	}
 
-	// ดึงข้อมูลกล้องด้วย GORM
-	var cameras []models.Camera
-	if err := s.DB.DB.WithContext(ctx).Where("organization_id = ?", organizationID).Order("name").Limit(pageSize).Offset(offset).Find(&cameras).Error; err != nil {
+	cameras, pagination, err := paginate(query, cameraListSpec, opts)
+	if err != nil {
+		if errors.Is(err, ErrInvalidListOptions) {
+			return nil, nil, err
+		}
 		return nil, nil, fmt.Errorf("ไม่สามารถดึงรายการกล้อง: %w", err)
 	}
 
-	// คำนวณจำนวนหน้าทั้งหมด
-	totalPage := int(total) / pageSize
-	if int(total)%pageSize > 0 {
-		totalPage++
+	return cameras, pagination, nil
+}
+
+// validateSite ตรวจสอบว่าสาขาของกล้องมีอยู่จริงและเป็นขององค์กรเดียวกับกล้อง
+func (s *CameraService) validateSite(ctx context.Context, camera *models.Camera) error {
+	if camera.SiteID == nil || *camera.SiteID == "" {
+		camera.SiteID = nil
+		return nil
 	}
 
-	// สร้างข้อมูล pagination
-	pagination := &models.Pagination{
-		Total:     int(total),
-		Page:      page,
-		PageSize:  pageSize,
-		TotalPage: totalPage,
+	var count int64
+	if err := s.DB.DB.WithContext(ctx).Model(&models.Site{}).Where("id = ? AND organization_id = ?", *camera.SiteID, camera.OrganizationID).Count(&count).Error; err != nil {
+		return fmt.Errorf("ไม่สามารถตรวจสอบสาขา: %w", err)
+	}
+	if count == 0 {
+		return fmt.Errorf("ไม่พบสาขาที่ระบุ")
 	}
 
-	return cameras, pagination, nil
+	return nil
 }
\ No newline at end of file
@@ -0,0 +1,355 @@
+package services
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/db"
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/models"
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/storage"
+	"github.com/parquet-go/parquet-go"
+)
+
+// รูปแบบไฟล์ที่ส่งออกได้
+const (
+	ExportFormatCSV     = "csv"
+	ExportFormatNDJSON  = "ndjson"
+	ExportFormatParquet = "parquet"
+)
+
+// exportProgressEvery คือจำนวนแถวที่ส่งออกก่อนรายงานความคืบหน้าแต่ละครั้ง
+const exportProgressEvery = 10000
+
+// parquetRowGroupSize จำกัดจำนวนแถวที่ parquet writer เก็บในหน่วยความจำก่อนเขียนออก
+const parquetRowGroupSize = 50000
+
+// ExportContentType คืน content type และนามสกุลไฟล์ของรูปแบบที่ส่งออก
+func ExportContentType(format string) (string, string, error) {
+	switch format {
+	case ExportFormatCSV:
+		return "text/csv; charset=utf-8", "csv", nil
+	case ExportFormatNDJSON:
+		return "application/x-ndjson", "ndjson", nil
+	case ExportFormatParquet:
+		return "application/vnd.apache.parquet", "parquet", nil
+	}
+	return "", "", fmt.Errorf("format ต้องเป็น csv, ndjson หรือ parquet")
+}
+
+// exportRow เป็นข้อมูล log หนึ่งแถวที่ส่งออก
+type exportRow struct {
+	ID             string    `json:"id" parquet:"id"`
+	Timestamp      time.Time `json:"timestamp" parquet:"timestamp,timestamp(millisecond)"`
+	PersonHash     string    `json:"person_hash" parquet:"person_hash,dict"`
+	CameraID       string    `json:"camera_id" parquet:"camera_id,dict"`
+	IsNewPerson    bool      `json:"is_new_person" parquet:"is_new_person"`
+	Direction      string    `json:"direction,omitempty" parquet:"direction,dict"`
+	OrganizationID string    `json:"organization_id" parquet:"organization_id,dict"`
+}
+
+// exportRowWithCamera เป็นข้อมูล log หนึ่งแถวที่ส่งออกพร้อมชื่อและโซนของกล้อง
+type exportRowWithCamera struct {
+	ID             string    `json:"id" parquet:"id"`
+	Timestamp      time.Time `json:"timestamp" parquet:"timestamp,timestamp(millisecond)"`
+	PersonHash     string    `json:"person_hash" parquet:"person_hash,dict"`
+	CameraID       string    `json:"camera_id" parquet:"camera_id,dict"`
+	IsNewPerson    bool      `json:"is_new_person" parquet:"is_new_person"`
+	Direction      string    `json:"direction,omitempty" parquet:"direction,dict"`
+	OrganizationID string    `json:"organization_id" parquet:"organization_id,dict"`
+	CameraName     string    `json:"camera_name" parquet:"camera_name,dict"`
+	Zone           string    `json:"zone" parquet:"zone,dict"`
+}
+
+// rowWriter เขียนข้อมูลที่ส่งออกทีละแถวในรูปแบบไฟล์ที่กำหนด
+type rowWriter interface {
+	Write(row exportRowWithCamera) error
+	Close() error
+}
+
+// csvRowWriter เขียนข้อมูลในรูปแบบ CSV
+type csvRowWriter struct {
+	w             *csv.Writer
+	includeCamera bool
+	record        []string
+}
+
+func newCSVRowWriter(w io.Writer, includeCamera bool) (*csvRowWriter, error) {
+	header := []string{"id", "timestamp", "person_hash", "camera_id", "is_new_person", "direction", "organization_id"}
+	if includeCamera {
+		header = append(header, "camera_name", "zone")
+	}
+	writer := &csvRowWriter{w: csv.NewWriter(w), includeCamera: includeCamera, record: make([]string, len(header))}
+	if err := writer.w.Write(header); err != nil {
+		return nil, err
+	}
+	return writer, nil
+}
+
+func (c *csvRowWriter) Write(row exportRowWithCamera) error {
+	c.record[0] = row.ID
+	c.record[1] = row.Timestamp.Format(time.RFC3339)
+	c.record[2] = row.PersonHash
+	c.record[3] = row.CameraID
+	c.record[4] = strconv.FormatBool(row.IsNewPerson)
+	c.record[5] = row.Direction
+	c.record[6] = row.OrganizationID
+	if c.includeCamera {
+		c.record[7] = row.CameraName
+		c.record[8] = row.Zone
+	}
+	return c.w.Write(c.record)
+}
+
+func (c *csvRowWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// ndjsonRowWriter เขียนข้อมูลเป็น JSON หนึ่งบรรทัดต่อแถว
+type ndjsonRowWriter struct {
+	encoder       *json.Encoder
+	includeCamera bool
+}
+
+func (n *ndjsonRowWriter) Write(row exportRowWithCamera) error {
+	if n.includeCamera {
+		return n.encoder.Encode(row)
+	}
+	return n.encoder.Encode(exportRow{
+		ID:             row.ID,
+		Timestamp:      row.Timestamp,
+		PersonHash:     row.PersonHash,
+		CameraID:       row.CameraID,
+		IsNewPerson:    row.IsNewPerson,
+		Direction:      row.Direction,
+		OrganizationID: row.OrganizationID,
+	})
+}
+
+func (n *ndjsonRowWriter) Close() error {
+	return nil
+}
+
+// parquetRowWriter เขียนข้อมูลในรูปแบบ Parquet ทีละชุด (row group)
+type parquetRowWriter[T any] struct {
+	writer  *parquet.GenericWriter[T]
+	convert func(exportRowWithCamera) T
+	buffer  []T
+}
+
+func newParquetRowWriter[T any](w io.Writer, convert func(exportRowWithCamera) T) *parquetRowWriter[T] {
+	return &parquetRowWriter[T]{
+		writer: parquet.NewGenericWriter[T](w,
+			parquet.MaxRowsPerRowGroup(parquetRowGroupSize),
+			parquet.Compression(&parquet.Snappy),
+		),
+		convert: convert,
+		buffer:  make([]T, 0, 1000),
+	}
+}
+
+func (p *parquetRowWriter[T]) Write(row exportRowWithCamera) error {
+	p.buffer = append(p.buffer, p.convert(row))
+	if len(p.buffer) == cap(p.buffer) {
+		return p.flush()
+	}
+	return nil
+}
+
+func (p *parquetRowWriter[T]) flush() error {
+	if len(p.buffer) == 0 {
+		return nil
+	}
+	if _, err := p.writer.Write(p.buffer); err != nil {
+		return err
+	}
+	p.buffer = p.buffer[:0]
+	return nil
+}
+
+func (p *parquetRowWriter[T]) Close() error {
+	if err := p.flush(); err != nil {
+		return err
+	}
+	return p.writer.Close()
+}
+
+// newRowWriter สร้าง rowWriter ตามรูปแบบไฟล์
+func newRowWriter(w io.Writer, format string, includeCamera bool) (rowWriter, error) {
+	switch format {
+	case ExportFormatCSV:
+		return newCSVRowWriter(w, includeCamera)
+	case ExportFormatNDJSON:
+		return &ndjsonRowWriter{encoder: json.NewEncoder(w), includeCamera: includeCamera}, nil
+	case ExportFormatParquet:
+		if includeCamera {
+			return newParquetRowWriter(w, func(row exportRowWithCamera) exportRowWithCamera { return row }), nil
+		}
+		return newParquetRowWriter(w, func(row exportRowWithCamera) exportRow {
+			return exportRow{
+				ID:             row.ID,
+				Timestamp:      row.Timestamp,
+				PersonHash:     row.PersonHash,
+				CameraID:       row.CameraID,
+				IsNewPerson:    row.IsNewPerson,
+				Direction:      row.Direction,
+				OrganizationID: row.OrganizationID,
+			}
+		}), nil
+	}
+	return nil, fmt.Errorf("format ต้องเป็น csv, ndjson หรือ parquet")
+}
+
+// ExportService ให้บริการส่งออกข้อมูล person_logs จำนวนมาก
+type ExportService struct {
+	DB      *db.PostgresDB
+	Storage storage.StorageService
+	Jobs    *JobService
+}
+
+// NewExportService สร้าง ExportService ใหม่
+func NewExportService(postgres *db.PostgresDB, storageService storage.StorageService, jobService *JobService) *ExportService {
+	return &ExportService{
+		DB:      postgres,
+		Storage: storageService,
+		Jobs:    jobService,
+	}
+}
+
+// ExportLogs เขียน person_logs ทั้งหมดที่ตรงกับ filter ลงใน w ทีละแถว โดยไม่โหลดข้อมูลทั้งหมดเข้าหน่วยความจำ
+func (s *ExportService) ExportLogs(ctx context.Context, params models.LogExportParams, w io.Writer, progress func(int64)) (int64, error) {
+	writer, err := newRowWriter(w, params.Format, params.IncludeCamera)
+	if err != nil {
+		return 0, err
+	}
+
+	columns := "l.id, l.timestamp, l.person_hash, l.camera_id, l.is_new_person, COALESCE(l.direction, ''), l.organization_id"
+	query := s.DB.DB.WithContext(ctx).Table("person_logs AS l").Where("l.deleted_at IS NULL")
+	query = applyLogFilter(query, params.Filter, "l.")
+	if params.IncludeCamera {
+		columns += ", COALESCE(c.name, ''), COALESCE(c.zone, '')"
+		query = query.Joins("LEFT JOIN cameras c ON c.id = l.camera_id")
+	}
+
+	rows, err := query.Select(columns).Order("l.timestamp, l.id").Rows()
+	if err != nil {
+		return 0, fmt.Errorf("ไม่สามารถดึงข้อมูล logs สำหรับส่งออก: %w", err)
+	}
+	defer rows.Close()
+
+	var count int64
+	var row exportRowWithCamera
+	for rows.Next() {
+		dest := []interface{}{&row.ID, &row.Timestamp, &row.PersonHash, &row.CameraID, &row.IsNewPerson, &row.Direction, &row.OrganizationID}
+		if params.IncludeCamera {
+			dest = append(dest, &row.CameraName, &row.Zone)
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return count, fmt.Errorf("ไม่สามารถอ่านข้อมูล log: %w", err)
+		}
+		if err := writer.Write(row); err != nil {
+			return count, fmt.Errorf("ไม่สามารถเขียนข้อมูลที่ส่งออก: %w", err)
+		}
+		count++
+		if progress != nil && count%exportProgressEvery == 0 {
+			progress(count)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return count, fmt.Errorf("ไม่สามารถอ่านข้อมูล logs: %w", err)
+	}
+
+	if err := writer.Close(); err != nil {
+		return count, fmt.Errorf("ไม่สามารถเขียนข้อมูลที่ส่งออก: %w", err)
+	}
+	if progress != nil {
+		progress(count)
+	}
+
+	return count, nil
+}
+
+// StartExportJob สร้างงานส่งออกแบบเบื้องหลัง ซึ่งเขียนไฟล์ลงใน storage เพื่อให้ดาวน์โหลดภายหลัง
+func (s *ExportService) StartExportJob(ctx context.Context, params models.LogExportParams) (*models.Job, error) {
+	contentType, extension, err := ExportContentType(params.Format)
+	if err != nil {
+		return nil, err
+	}
+
+	job, err := s.Jobs.CreateJob(ctx, params.Filter.OrganizationID, models.JobTypeLogExport, params)
+	if err != nil {
+		return nil, err
+	}
+
+	s.Jobs.Run(job, func(ctx context.Context, job *models.Job, progress func(int64)) (interface{}, error) {
+		// เขียนลงไฟล์ชั่วคราวก่อน เพื่อให้รู้ขนาดไฟล์ก่อนอัปโหลด
+		tmp, err := os.CreateTemp("", "log-export-*."+extension)
+		if err != nil {
+			return nil, fmt.Errorf("ไม่สามารถสร้างไฟล์ชั่วคราว: %w", err)
+		}
+		defer os.Remove(tmp.Name())
+		defer tmp.Close()
+
+		rows, err := s.ExportLogs(ctx, params, tmp, progress)
+		if err != nil {
+			return nil, err
+		}
+
+		size, err := tmp.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return nil, fmt.Errorf("ไม่สามารถอ่านขนาดไฟล์ที่ส่งออก: %w", err)
+		}
+		if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+			return nil, fmt.Errorf("ไม่สามารถอ่านไฟล์ที่ส่งออก: %w", err)
+		}
+
+		fileName := fmt.Sprintf("logs-%s.%s", job.CreatedAt.Format("20060102-150405"), extension)
+		key := fmt.Sprintf("exports/%s/%s/%s", job.OrganizationID, job.ID, fileName)
+		if err := s.Storage.PutObject(ctx, key, tmp, size, contentType); err != nil {
+			return nil, fmt.Errorf("ไม่สามารถบันทึกไฟล์ที่ส่งออก: %w", err)
+		}
+
+		return models.LogExportResult{
+			ObjectKey:   key,
+			FileName:    fileName,
+			ContentType: contentType,
+			Size:        size,
+			Rows:        rows,
+		}, nil
+	})
+
+	return job, nil
+}
+
+// OpenExportFile เปิดไฟล์ของงานส่งออกที่เสร็จแล้วเพื่อดาวน์โหลด
+func (s *ExportService) OpenExportFile(ctx context.Context, jobID, organizationID string) (io.ReadCloser, *models.LogExportResult, error) {
+	job, err := s.Jobs.GetJob(ctx, jobID, organizationID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if job.Type != models.JobTypeLogExport {
+		return nil, nil, fmt.Errorf("ไม่พบงาน")
+	}
+	if job.Status != models.JobCompleted {
+		return nil, nil, fmt.Errorf("งานส่งออกยังไม่เสร็จ")
+	}
+
+	var result models.LogExportResult
+	if err := json.Unmarshal(job.Result, &result); err != nil {
+		return nil, nil, fmt.Errorf("ไม่สามารถอ่านผลของงานส่งออก: %w", err)
+	}
+
+	reader, size, err := s.Storage.GetObject(ctx, result.ObjectKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("ไม่สามารถเปิดไฟล์ที่ส่งออก: %w", err)
+	}
+	result.Size = size
+
+	return reader, &result, nil
+}
@@ -0,0 +1,85 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func sampleExportRows() []exportRowWithCamera {
+	timestamp := time.Date(2025, 3, 1, 9, 30, 0, 0, time.UTC)
+	return []exportRowWithCamera{
+		{ID: "log-1", Timestamp: timestamp, PersonHash: "p1", CameraID: "cam-1", IsNewPerson: true, Direction: "in", OrganizationID: "org-1", CameraName: "Front, Door", Zone: "lobby"},
+		{ID: "log-2", Timestamp: timestamp.Add(time.Minute), PersonHash: "p2", CameraID: "cam-1", OrganizationID: "org-1", CameraName: "Front, Door", Zone: "lobby"},
+	}
+}
+
+func writeExportRows(t *testing.T, format string, includeCamera bool) []byte {
+	var buf bytes.Buffer
+	writer, err := newRowWriter(&buf, format, includeCamera)
+	require.NoError(t, err)
+	for _, row := range sampleExportRows() {
+		require.NoError(t, writer.Write(row))
+	}
+	require.NoError(t, writer.Close())
+	return buf.Bytes()
+}
+
+// TestExportCSV ทดสอบรูปแบบ CSV ทั้งแบบมีและไม่มีข้อมูลกล้อง
+func TestExportCSV(t *testing.T) {
+	lines := strings.Split(strings.TrimSpace(string(writeExportRows(t, ExportFormatCSV, false))), "\n")
+	require.Len(t, lines, 3)
+	assert.Equal(t, "id,timestamp,person_hash,camera_id,is_new_person,direction,organization_id", lines[0])
+	assert.Equal(t, "log-1,2025-03-01T09:30:00Z,p1,cam-1,true,in,org-1", lines[1])
+
+	// ชื่อกล้องที่มีจุลภาคต้องถูกครอบด้วยเครื่องหมายคำพูด
+	lines = strings.Split(strings.TrimSpace(string(writeExportRows(t, ExportFormatCSV, true))), "\n")
+	assert.True(t, strings.HasSuffix(lines[0], ",camera_name,zone"))
+	assert.True(t, strings.HasSuffix(lines[2], `,"Front, Door",lobby`))
+}
+
+// TestExportNDJSON ทดสอบรูปแบบ JSON หนึ่งบรรทัดต่อแถว
+func TestExportNDJSON(t *testing.T) {
+	lines := strings.Split(strings.TrimSpace(string(writeExportRows(t, ExportFormatNDJSON, false))), "\n")
+	require.Len(t, lines, 2)
+
+	var row map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &row))
+	assert.Equal(t, "log-1", row["id"])
+	assert.NotContains(t, row, "camera_name")
+
+	lines = strings.Split(strings.TrimSpace(string(writeExportRows(t, ExportFormatNDJSON, true))), "\n")
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &row))
+	assert.Equal(t, "lobby", row["zone"])
+}
+
+// TestExportParquet ทดสอบว่าไฟล์ Parquet อ่านกลับได้ครบทุกแถว
+func TestExportParquet(t *testing.T) {
+	data := writeExportRows(t, ExportFormatParquet, true)
+	rows, err := parquet.Read[exportRowWithCamera](bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	assert.Equal(t, sampleExportRows()[0].Timestamp, rows[0].Timestamp.UTC())
+	assert.Equal(t, "Front, Door", rows[1].CameraName)
+
+	data = writeExportRows(t, ExportFormatParquet, false)
+	plain, err := parquet.Read[exportRow](bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+	assert.Equal(t, "p2", plain[1].PersonHash)
+}
+
+// TestExportContentType ทดสอบการตรวจสอบรูปแบบไฟล์
+func TestExportContentType(t *testing.T) {
+	_, extension, err := ExportContentType(ExportFormatParquet)
+	require.NoError(t, err)
+	assert.Equal(t, "parquet", extension)
+
+	_, _, err = ExportContentType("xlsx")
+	assert.Error(t, err)
+}
@@ -1,9 +1,15 @@
 package services
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"mime/multipart"
+	"path"
+	"strings"
+	"time"
 
 	"github.com/bemindtech/bmt-manta-dashboard-service/internal/db"
 	"github.com/bemindtech/bmt-manta-dashboard-service/internal/models"
@@ -14,20 +20,39 @@ import (
 
 // FaceService ให้บริการเกี่ยวกับการจัดการใบหน้า
 type FaceService struct {
-	DB      *db.PostgresDB
-	Storage storage.StorageService
+	DB               *db.PostgresDB
+	Storage          storage.StorageService
+	Jobs             *JobService
+	URLs             *ImageURLSigner
+	ThumbnailSizes   []int         // ขนาดรูปย่อ (pixel) ที่สร้างให้ทุกรูปภาพ
+	UploadIntentTTL  time.Duration // อายุของ URL สำหรับอัปโหลดรูปภาพตรงไปยัง storage
+	BatchConcurrency int           // จำนวนรูปภาพที่สร้างพร้อมกันในงานอัปโหลดแบบกลุ่มแต่ละงาน
+	Index            *FaceIndex    // ดัชนี embedding สำหรับค้นหาใบหน้า (nil ปิดการค้นหา)
 }
 
 // NewFaceService สร้าง FaceService ใหม่
-func NewFaceService(postgres *db.PostgresDB, storage storage.StorageService) *FaceService {
+func NewFaceService(postgres *db.PostgresDB, storage storage.StorageService, jobService *JobService, urls *ImageURLSigner, thumbnailSizes []int, uploadIntentTTL time.Duration, batchConcurrency int, index *FaceIndex) *FaceService {
+	if uploadIntentTTL <= 0 {
+		uploadIntentTTL = 15 * time.Minute
+	}
+	if batchConcurrency <= 0 {
+		batchConcurrency = 4
+	}
 	return &FaceService{
-		DB:      postgres,
-		Storage: storage,
+		DB:               postgres,
+		Storage:          storage,
+		Jobs:             jobService,
+		URLs:             urls,
+		ThumbnailSizes:   thumbnailSizes,
+		UploadIntentTTL:  uploadIntentTTL,
+		BatchConcurrency: batchConcurrency,
+		Index:            index,
 	}
 }
 
 // UploadFaceImage อัปโหลดรูปภาพใบหน้า
-func (s *FaceService) UploadFaceImage(ctx context.Context, file *multipart.FileHeader, personHash, cameraID, organizationID string) (*models.FaceImage, error) {
+// quality คือข้อมูลคุณภาพของรูปที่ผู้อัปโหลดส่งมา และ embedding คือ embedding ของใบหน้าที่ใช้ค้นหา (ไม่บังคับทั้งคู่)
+func (s *FaceService) UploadFaceImage(ctx context.Context, file *multipart.FileHeader, personHash, cameraID, organizationID string, quality models.FaceQuality, embedding []float32) (*models.FaceImage, error) {
 	// ตรวจสอบว่ามีรหัสกล้องหรือไม่
 	if cameraID == "" {
 		return nil, fmt.Errorf("ต้องระบุรหัสกล้อง")
@@ -48,73 +73,156 @@ func (s *FaceService) UploadFaceImage(ctx context.Context, file *multipart.FileH
 		return nil, fmt.Errorf("ไม่มีไฟล์ที่อัปโหลด")
 	}
 
-	// อัปโหลดไฟล์ไปยังระบบจัดเก็บ
-	imageURL, err := s.Storage.UploadFaceImage(ctx, file, personHash, organizationID)
+	// อ่านไฟล์ไม่เกินขนาดที่องค์กรอนุญาต
+	settings, err := s.GetUploadSettings(ctx, organizationID)
 	if err != nil {
-		return nil, fmt.Errorf("ไม่สามารถอัปโหลดรูปภาพ: %w", err)
+		return nil, err
+	}
+	if file.Size > settings.MaxFileSize {
+		return nil, &FaceImageError{Code: FaceImageFileTooLarge, Message: fmt.Sprintf("ขนาดไฟล์ต้องไม่เกิน %d bytes", settings.MaxFileSize)}
+	}
+	src, err := file.Open()
+	if err != nil {
+		return nil, fmt.Errorf("ไม่สามารถเปิดไฟล์: %w", err)
+	}
+	data, err := io.ReadAll(io.LimitReader(src, settings.MaxFileSize+1))
+	src.Close()
+	if err != nil {
+		return nil, fmt.Errorf("ไม่สามารถอ่านไฟล์: %w", err)
+	}
+
+	return s.createFaceImage(ctx, data, settings, personHash, cameraID, organizationID, nil, nil, quality, embedding)
+}
+
+// createFaceImage ตรวจและเข้ารหัสรูปภาพใหม่ตามข้อจำกัดขององค์กร บันทึกรูปภาพและรูปย่อลง storage แล้วสร้างข้อมูลรูปภาพ
+// capturedAt คือเวลาที่กล้องถ่ายรูป ถ้าทราบ quality คือข้อมูลคุณภาพที่ผู้อัปโหลดส่งมา และ embedding (ถ้ามี) ถูกบันทึกเพื่อค้นหาใบหน้า
+// รูปที่บันทึกแล้วอาจกลายเป็นรูปภาพหลักของบุคคลถ้าคะแนนคุณภาพสูงกว่ารูปเดิม
+// uploadIntentID ไม่ใช่ nil เมื่อสร้างจากคำขออัปโหลด ซึ่งบันทึกไว้ในรูปภาพเพื่อให้การยืนยันซ้ำพบรูปภาพเดิม
+func (s *FaceService) createFaceImage(ctx context.Context, data []byte, settings *models.FaceUploadSettings, personHash, cameraID, organizationID string, capturedAt *time.Time, uploadIntentID *string, quality models.FaceQuality, embedding []float32) (*models.FaceImage, error) {
+	if err := validateFaceQuality(quality); err != nil {
+		return nil, err
+	}
+	embedding, err := s.prepareFaceEmbedding(ctx, organizationID, embedding)
+	if err != nil {
+		return nil, err
+	}
+
+	// แปลง hash ที่ถูกรวมเข้ากับบุคคลอื่นแล้วเป็น hash ของบุคคลปัจจุบัน
+	reportedHash := ""
+	resolvedHash, err := resolvePersonHash(ctx, s.DB.DB, organizationID, personHash)
+	if err != nil {
+		return nil, err
+	}
+	if resolvedHash != personHash {
+		reportedHash = personHash
+		personHash = resolvedHash
+	}
+
+	// ถอดรหัสและเข้ารหัสรูปภาพใหม่ ซึ่งใช้สร้างรูปย่อด้วย
+	sanitized, err := sanitizeFaceImage(data, settings)
+	if err != nil {
+		return nil, err
 	}
 
-	// สร้างข้อมูลในฐานข้อมูล
 	faceImage := &models.FaceImage{
-		Base: models.Base{
-			ID: uuid.New().String(),
-		},
 		PersonHash:     personHash,
-		ImageURL:       imageURL,
+		ReportedHash:   reportedHash,
 		OrganizationID: organizationID,
 		CameraID:       cameraID,
+		CapturedAt:     capturedAt,
+		UploadIntentID: uploadIntentID,
+		FaceQuality:    quality,
+		Width:          sanitized.Image.Bounds().Dx(),
+		Height:         sanitized.Image.Bounds().Dy(),
 	}
+	scoreFaceImage(faceImage, sanitized.Image)
 
-	// บันทึกลงฐานข้อมูลด้วย GORM
-	if err := s.DB.DB.WithContext(ctx).Create(faceImage).Error; err != nil {
-		// ถ้าบันทึกไม่สำเร็จ ให้ลบไฟล์ที่อัปโหลดไปแล้ว
-		_ = s.Storage.DeleteFaceImage(ctx, imageURL)
-		return nil, fmt.Errorf("ไม่สามารถบันทึกข้อมูลรูปภาพ: %w", err)
+	// รูปที่เกือบเหมือนรูปล่าสุดของบุคคลเดียวกันไม่ถูกเก็บซ้ำ
+	hash := perceptualHash(sanitized.Image)
+	faceImage.PerceptualHash = &hash
+	if settings.DedupMode != models.FaceDedupOff {
+		original, err := s.findNearDuplicate(ctx, organizationID, personHash, hash, settings)
+		if err != nil {
+			return nil, err
+		}
+		if original != nil {
+			return s.recordNearDuplicate(ctx, original, settings, faceImage, embedding)
+		}
 	}
 
-	return faceImage, nil
-}
-
-// GetFaceImages ดึงรูปภาพใบหน้าตามรหัสบุคคล
-func (s *FaceService) GetFaceImages(ctx context.Context, personHash, organizationID string, page, pageSize int) ([]models.FaceImage, *models.Pagination, error) {
-	// คำนวณ offset
-	if page <= 0 {
-		page = 1
-	}
-	if pageSize <= 0 {
-		pageSize = 10
+	// อัปโหลดไฟล์ไปยังระบบจัดเก็บ
+	imageKey := faceImageKey(organizationID, personHash, sanitized.Extension)
+	if err := s.Storage.PutObject(ctx, imageKey, bytes.NewReader(sanitized.Data), int64(len(sanitized.Data)), sanitized.ContentType); err != nil {
+		return nil, fmt.Errorf("ไม่สามารถอัปโหลดรูปภาพ: %w", err)
 	}
-	offset := (page - 1) * pageSize
 
-	// สร้าง query ด้วย GORM
-	query := s.DB.DB.WithContext(ctx).Model(&models.FaceImage{}).Where("person_hash = ? AND organization_id = ?", personHash, organizationID)
+	// สร้างข้อมูลในฐานข้อมูล
+	faceImage.ID = uuid.New().String()
+	faceImage.ImageKey = imageKey
 
-	// นับจำนวนรายการทั้งหมด
-	var total int64
-	if err := query.Count(&total).Error; err != nil {
-		return nil, nil, fmt.Errorf("ไม่สามารถนับจำนวนรูปภาพ: %w", err)
+	// สร้างรูปย่อ ซึ่งใช้ ID ของรูปภาพเป็นชื่อไฟล์
+	if err := s.storeThumbnails(ctx, faceImage, sanitized.Image); err != nil {
+		_ = s.Storage.DeleteObject(ctx, imageKey)
+		return nil, err
 	}
 
-	// ดึงข้อมูลรูปภาพด้วย GORM
-	var images []models.FaceImage
-	if err := query.Order("created_at DESC").Limit(pageSize).Offset(offset).Find(&images).Error; err != nil {
-		return nil, nil, fmt.Errorf("ไม่สามารถดึงข้อมูลรูปภาพ: %w", err)
+	// บันทึกลงฐานข้อมูลพร้อม embedding
+	if err := s.saveFaceImage(ctx, faceImage, embedding); err != nil {
+		// ถ้าบันทึกไม่สำเร็จ ให้ลบไฟล์ที่อัปโหลดไปแล้ว
+		_ = s.Storage.DeleteObject(ctx, imageKey)
+		s.deleteThumbnails(ctx, faceImage.ThumbnailKeys)
+		return nil, err
 	}
+	s.refreshPrimaryFaceImage(ctx, organizationID, personHash)
 
-	// คำนวณจำนวนหน้าทั้งหมด
-	totalPage := int(total) / pageSize
-	if int(total)%pageSize > 0 {
-		totalPage++
+	s.URLs.SignFaceImage(faceImage)
+	return faceImage, nil
+}
+
+// faceImageListSpec กำหนดการเรียงลำดับและฟิลด์ที่เลือกได้ของรายการรูปภาพใบหน้า
+var faceImageListSpec = listSpec[models.FaceImage]{
+	Sorts: map[string]sortField{
+		"created_at": {Column: "created_at", Kind: sortKindTime},
+	},
+	DefaultSort:  "created_at",
+	DefaultOrder: "desc",
+	Fields: map[string]string{
+		"id":              "id",
+		"created_at":      "created_at",
+		"updated_at":      "updated_at",
+		"person_hash":     "person_hash",
+		"reported_hash":   "reported_hash",
+		"image_url":       "image_key",
+		"thumbnail_url":   "thumbnail_keys",
+		"thumbnails":      "thumbnail_keys",
+		"organization_id": "organization_id",
+		"camera_id":       "camera_id",
+		"captured_at":     "captured_at",
+		"width":           "width",
+		"height":          "height",
+		"duplicate_of_id": "duplicate_of_id",
+	},
+	Key: func(image models.FaceImage, sort string) (interface{}, string) {
+		return image.CreatedAt, image.ID
+	},
+}
+
+// GetFaceImages ดึงรูปภาพใบหน้าตามรหัสบุคคล โดยแบ่งหน้าด้วย cursor
+func (s *FaceService) GetFaceImages(ctx context.Context, personHash, organizationID string, cameraIDs []string, opts models.ListOptions) ([]models.FaceImage, *models.CursorPagination, error) {
+	query := s.DB.DB.WithContext(ctx).Model(&models.FaceImage{}).Where("person_hash = ? AND organization_id = ?", personHash, organizationID)
+	if len(cameraIDs) > 0 {
+		query = query.Where("camera_id IN ?", cameraIDs)
 	}
 
-	// สร้างข้อมูล pagination
-	pagination := &models.Pagination{
-		Total:     int(total),
-		Page:      page,
-		PageSize:  pageSize,
-		TotalPage: totalPage,
+	images, pagination, err := paginate(query, faceImageListSpec, opts)
+	if err != nil {
+		if errors.Is(err, ErrInvalidListOptions) {
+			return nil, nil, err
+		}
+		return nil, nil, fmt.Errorf("ไม่สามารถดึงข้อมูลรูปภาพ: %w", err)
 	}
 
+	s.URLs.SignFaceImages(images)
 	return images, pagination, nil
 }
 
@@ -130,24 +238,80 @@ func (s *FaceService) DeleteFaceImage(ctx context.Context, id, organizationID st
 		return fmt.Errorf("ไม่สามารถดึงข้อมูลรูปภาพ: %w", result.Error)
 	}
 
-	// เก็บ URL ของรูปภาพไว้เพื่อใช้ในการลบไฟล์
-	imageURL := faceImage.ImageURL
+	// ลบข้อมูลและ embedding ในฐานข้อมูลด้วย GORM
+	err := s.DB.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("id = ? AND organization_id = ?", id, organizationID).Delete(&models.FaceImage{})
+		if result.Error != nil {
+			return fmt.Errorf("ไม่สามารถลบข้อมูลรูปภาพในฐานข้อมูล: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("ไม่พบรูปภาพที่ต้องการลบ")
+		}
+		if err := tx.Where("face_image_id = ?", id).Delete(&models.FaceEmbedding{}).Error; err != nil {
+			return fmt.Errorf("ไม่สามารถลบ embedding ของใบหน้า: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	s.refreshPrimaryFaceImage(ctx, organizationID, faceImage.PersonHash)
 
-	// ลบข้อมูลในฐานข้อมูลด้วย GORM
-	result = s.DB.DB.WithContext(ctx).Where("id = ? AND organization_id = ?", id, organizationID).Delete(&models.FaceImage{})
-	if result.Error != nil {
-		return fmt.Errorf("ไม่สามารถลบข้อมูลรูปภาพในฐานข้อมูล: %w", result.Error)
+	// ลบไฟล์ ถ้าไม่มีรูปที่ซ้ำแบบ link ใช้ไฟล์เดียวกันอยู่ (บันทึก log แต่ไม่ return error เพราะข้อมูลในฐานข้อมูลถูกลบไปแล้ว)
+	s.deleteFaceImageFiles(ctx, &faceImage)
+
+	return nil
+}
+
+// faceImageFileKey แปลง path ของไฟล์ใน URL ของรูปภาพเป็น object key ขององค์กร
+// ปฏิเสธ path ที่ไม่อยู่ในรูปแบบปกติ เพื่อไม่ให้ออกนอกไฟล์ขององค์กร (เช่น ../)
+func faceImageFileKey(organizationID, filePath string) (string, error) {
+	if organizationID == "" || filePath == "" || path.Clean(filePath) != filePath ||
+		strings.HasPrefix(filePath, "/") || filePath == ".." || strings.HasPrefix(filePath, "../") {
+		return "", fmt.Errorf("ไม่พบรูปภาพ")
 	}
+	return organizationID + "/" + filePath, nil
+}
 
-	if result.RowsAffected == 0 {
-		return fmt.Errorf("ไม่พบรูปภาพที่ต้องการลบ")
+// VerifyFaceImageURL ตรวจลายเซ็นและเวลาหมดอายุของ URL รูปภาพที่ API ส่งให้
+func (s *FaceService) VerifyFaceImageURL(organizationID, filePath, expires, signature string) error {
+	key, err := faceImageFileKey(organizationID, filePath)
+	if err != nil {
+		return err
+	}
+	verifier, ok := s.Storage.(storage.SignatureVerifier)
+	if !ok || verifier.VerifySignature(key, expires, signature) != nil {
+		return fmt.Errorf("URL ของรูปภาพไม่ถูกต้องหรือหมดอายุ")
 	}
+	return nil
+}
 
-	// ลบไฟล์
-	if err := s.Storage.DeleteFaceImage(ctx, imageURL); err != nil {
-		// บันทึก log แต่ไม่ return error เพราะข้อมูลในฐานข้อมูลถูกลบไปแล้ว
-		fmt.Printf("ไม่สามารถลบไฟล์รูปภาพ %s: %v\n", imageURL, err)
+// StatFaceImageFile ดึงขนาด ประเภท และ ETag ของไฟล์รูปภาพใบหน้าหรือรูปย่อขององค์กร
+func (s *FaceService) StatFaceImageFile(ctx context.Context, organizationID, filePath string) (*storage.ObjectInfo, error) {
+	key, err := faceImageFileKey(organizationID, filePath)
+	if err != nil {
+		return nil, err
+	}
+	info, err := s.Storage.StatObject(ctx, key)
+	if err != nil {
+		if errors.Is(err, storage.ErrObjectNotFound) {
+			return nil, fmt.Errorf("ไม่พบรูปภาพ")
+		}
+		return nil, fmt.Errorf("ไม่สามารถอ่านข้อมูลรูปภาพ: %w", err)
 	}
+	return info, nil
+}
 
-	return nil
-}
\ No newline at end of file
+// OpenFaceImageFile เปิดไฟล์รูปภาพใบหน้าหรือรูปย่อขององค์กรเพื่ออ่าน
+func (s *FaceService) OpenFaceImageFile(ctx context.Context, organizationID, filePath string) (io.ReadCloser, error) {
+	key, err := faceImageFileKey(organizationID, filePath)
+	if err != nil {
+		return nil, err
+	}
+	reader, _, err := s.Storage.GetObject(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("ไม่สามารถเปิดไฟล์รูปภาพ: %w", err)
+	}
+	return reader, nil
+}
@@ -0,0 +1,583 @@
+package services
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/models"
+)
+
+const (
+	// maxFaceBatchItems จำนวนรายการสูงสุดใน manifest ของการอัปโหลดแบบกลุ่ม
+	maxFaceBatchItems = 10000
+	// maxFaceBatchManifestSize ขนาดสูงสุดของไฟล์ manifest (bytes)
+	maxFaceBatchManifestSize = 10 * 1024 * 1024
+	// faceBatchProgressInterval จำนวนรายการที่ทำเสร็จก่อนบันทึกความคืบหน้าแต่ละครั้ง
+	faceBatchProgressInterval = 50
+)
+
+// ชนิดของ archive ที่อัปโหลดแบบกลุ่มได้
+const (
+	faceBatchFormatZip   = "zip"
+	faceBatchFormatTar   = "tar"
+	faceBatchFormatTarGz = "tar.gz"
+)
+
+// รหัสข้อผิดพลาดของ archive, manifest และรายการใน manifest ของการอัปโหลดแบบกลุ่ม
+// รายการที่รูปภาพไม่ผ่านการตรวจสอบใช้รหัสเดียวกับ FaceImageError
+const (
+	FaceBatchInvalidArchive  = "invalid_archive"
+	FaceBatchInvalidManifest = "invalid_manifest"
+	FaceBatchInvalidEntry    = "invalid_entry"
+	FaceBatchFileNotFound    = "file_not_found"
+	FaceBatchUploadFailed    = "upload_failed"
+)
+
+// FaceBatchError เป็นข้อผิดพลาดของ archive หรือ manifest ของการอัปโหลดแบบกลุ่ม พร้อมรหัสข้อผิดพลาด
+type FaceBatchError struct {
+	Code    string
+	Message string
+}
+
+func (e *FaceBatchError) Error() string {
+	return e.Message
+}
+
+// faceBatchEntry เป็นรายการหนึ่งใน manifest ของการอัปโหลดแบบกลุ่ม
+type faceBatchEntry struct {
+	File       string    `json:"file"`
+	PersonHash string    `json:"person_hash"`
+	CameraID   string    `json:"camera_id"`
+	CapturedAt string    `json:"captured_at"`
+	Embedding  []float32 `json:"embedding"`
+	models.FaceQuality
+
+	key        string     // path ของไฟล์ใน archive
+	capturedAt *time.Time // captured_at ที่แปลงแล้ว
+	invalid    string     // เหตุผลที่รายการไม่ถูกต้อง
+}
+
+// faceBatchTask เป็นไฟล์ที่อ่านจาก archive แล้ว รอสร้างรูปภาพใบหน้า
+type faceBatchTask struct {
+	index int
+	data  []byte
+}
+
+// StartFaceBatchUpload ตรวจ archive (zip, tar หรือ tar.gz) และ manifest แล้วสร้างงานเบื้องหลังที่สร้างรูปภาพใบหน้าทุกรายการใน manifest
+// archive ถูกคัดลอกเป็นไฟล์ชั่วคราว ซึ่งถูกลบเมื่องานเสร็จ
+func (s *FaceService) StartFaceBatchUpload(ctx context.Context, organizationID string, file *multipart.FileHeader) (*models.Job, error) {
+	if organizationID == "" {
+		return nil, fmt.Errorf("ไม่พบข้อมูลองค์กร")
+	}
+	if file == nil {
+		return nil, fmt.Errorf("ไม่มีไฟล์ที่อัปโหลด")
+	}
+
+	archivePath, err := copyToTempFile(file)
+	if err != nil {
+		return nil, err
+	}
+	keep := false
+	defer func() {
+		if !keep {
+			os.Remove(archivePath)
+		}
+	}()
+
+	format, entries, err := readFaceBatchArchive(archivePath)
+	if err != nil {
+		return nil, err
+	}
+
+	params := models.FaceBatchUploadParams{
+		FileName: file.Filename,
+		Format:   format,
+		Items:    len(entries),
+	}
+	job, err := s.Jobs.CreateJob(ctx, organizationID, models.JobTypeFaceBatchUpload, params)
+	if err != nil {
+		return nil, err
+	}
+
+	keep = true
+	s.Jobs.Run(job, func(ctx context.Context, job *models.Job, progress func(int64)) (interface{}, error) {
+		defer os.Remove(archivePath)
+		return s.runFaceBatchUpload(ctx, job, archivePath, format, entries, progress)
+	})
+	return job, nil
+}
+
+// GetFaceBatchUploadJob ดึงงานอัปโหลดรูปภาพใบหน้าแบบกลุ่มขององค์กร
+func (s *FaceService) GetFaceBatchUploadJob(ctx context.Context, id, organizationID string) (*models.Job, error) {
+	job, err := s.Jobs.GetJob(ctx, id, organizationID)
+	if err != nil {
+		return nil, err
+	}
+	if job.Type != models.JobTypeFaceBatchUpload {
+		return nil, fmt.Errorf("ไม่พบงาน")
+	}
+	return job, nil
+}
+
+// runFaceBatchUpload อ่านไฟล์ใน archive ตามลำดับ และสร้างรูปภาพใบหน้าของรายการใน manifest พร้อมกันไม่เกิน BatchConcurrency รูป
+// รายการที่ไม่สำเร็จถูกบันทึกในผลลัพธ์พร้อมรหัสข้อผิดพลาดโดยไม่หยุดงาน
+func (s *FaceService) runFaceBatchUpload(ctx context.Context, job *models.Job, archivePath, format string, entries []faceBatchEntry, progress func(int64)) (interface{}, error) {
+	archive, err := os.Open(archivePath)
+	if err != nil {
+		return nil, fmt.Errorf("ไม่สามารถเปิด archive: %w", err)
+	}
+	defer archive.Close()
+	info, err := archive.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("ไม่สามารถเปิด archive: %w", err)
+	}
+
+	if err := s.Jobs.SetTotal(ctx, job.ID, int64(len(entries))); err != nil {
+		return nil, err
+	}
+	settings, err := s.GetUploadSettings(ctx, job.OrganizationID)
+	if err != nil {
+		return nil, err
+	}
+
+	// รายการที่ไม่ถูกต้องเสร็จแล้วตั้งแต่เริ่ม ส่วนรายการอื่นรอไฟล์จาก archive ตาม path
+	results := make([]models.FaceBatchItemResult, len(entries))
+	pending := make(map[string]int, len(entries))
+	var mu sync.Mutex
+	var done int64
+	finish := func(index int, faceImage *models.FaceImage, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		results[index] = faceBatchItemResult(&entries[index], faceImage, err)
+		done++
+		if done%faceBatchProgressInterval == 0 {
+			progress(done)
+		}
+	}
+	for i := range entries {
+		if entries[i].invalid != "" {
+			finish(i, nil, &FaceBatchError{Code: FaceBatchInvalidEntry, Message: entries[i].invalid})
+			continue
+		}
+		pending[entries[i].key] = i
+	}
+
+	// worker สร้างรูปภาพจากไฟล์ที่อ่านแล้ว การส่งงานรอจนมี worker ว่าง จึงมีไฟล์ในหน่วยความจำไม่เกินจำนวน worker
+	tasks := make(chan faceBatchTask)
+	var wg sync.WaitGroup
+	for w := 0; w < s.BatchConcurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for task := range tasks {
+				entry := &entries[task.index]
+				faceImage, err := s.createFaceImage(ctx, task.data, settings, entry.PersonHash, entry.CameraID, job.OrganizationID, entry.capturedAt, nil, entry.FaceQuality, entry.Embedding)
+				finish(task.index, faceImage, err)
+			}
+		}()
+	}
+
+	walkErr := walkArchive(archive, info.Size(), format, func(name string, size int64, open func() (io.Reader, error)) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		index, ok := pending[archiveEntryName(name)]
+		if !ok {
+			return nil
+		}
+		// ไฟล์ชื่อซ้ำใน archive ใช้ไฟล์แรก
+		delete(pending, archiveEntryName(name))
+
+		if size > settings.MaxFileSize {
+			finish(index, nil, &FaceImageError{Code: FaceImageFileTooLarge, Message: fmt.Sprintf("ขนาดไฟล์ต้องไม่เกิน %d bytes", settings.MaxFileSize)})
+			return nil
+		}
+		content, err := open()
+		if err != nil {
+			finish(index, nil, &FaceBatchError{Code: FaceBatchInvalidArchive, Message: fmt.Sprintf("ไม่สามารถอ่านไฟล์ใน archive: %v", err)})
+			return nil
+		}
+		data, err := io.ReadAll(io.LimitReader(content, settings.MaxFileSize+1))
+		if err != nil {
+			finish(index, nil, &FaceBatchError{Code: FaceBatchInvalidArchive, Message: fmt.Sprintf("ไม่สามารถอ่านไฟล์ใน archive: %v", err)})
+			return nil
+		}
+
+		select {
+		case tasks <- faceBatchTask{index: index, data: data}:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+	close(tasks)
+	wg.Wait()
+
+	if walkErr != nil && ctx.Err() != nil {
+		return nil, walkErr
+	}
+	// รายการที่ไม่พบไฟล์ หรือ archive เสียหายก่อนถึงไฟล์ของรายการ
+	for _, index := range pending {
+		if walkErr != nil {
+			finish(index, nil, &FaceBatchError{Code: FaceBatchInvalidArchive, Message: walkErr.Error()})
+			continue
+		}
+		finish(index, nil, &FaceBatchError{Code: FaceBatchFileNotFound, Message: "ไม่พบไฟล์ใน archive"})
+	}
+	progress(done)
+
+	result := models.FaceBatchUploadResult{
+		Items:   int64(len(entries)),
+		Results: results,
+	}
+	for i := range results {
+		switch results[i].Status {
+		case models.FaceBatchItemCreated:
+			result.Created++
+		case models.FaceBatchItemDuplicate:
+			result.Duplicates++
+		default:
+			result.Failed++
+		}
+	}
+	return result, nil
+}
+
+// faceBatchItemResult สร้างผลลัพธ์ของรายการใน manifest จากรูปภาพที่สร้างหรือข้อผิดพลาด
+func faceBatchItemResult(entry *faceBatchEntry, faceImage *models.FaceImage, err error) models.FaceBatchItemResult {
+	result := models.FaceBatchItemResult{
+		File:       entry.File,
+		PersonHash: entry.PersonHash,
+		CameraID:   entry.CameraID,
+	}
+	if err == nil {
+		result.Status = models.FaceBatchItemCreated
+		if faceImage.Duplicate {
+			result.Status = models.FaceBatchItemDuplicate
+		}
+		result.FaceImageID = faceImage.ID
+		return result
+	}
+
+	result.Status = models.FaceBatchItemFailed
+	result.Error = err.Error()
+	var imageErr *FaceImageError
+	var batchErr *FaceBatchError
+	switch {
+	case errors.As(err, &imageErr):
+		result.Code = imageErr.Code
+	case errors.As(err, &batchErr):
+		result.Code = batchErr.Code
+	default:
+		result.Code = FaceBatchUploadFailed
+	}
+	return result
+}
+
+// copyToTempFile คัดลอกไฟล์ที่อัปโหลดเป็นไฟล์ชั่วคราว ซึ่งยังอยู่หลังจบ request
+func copyToTempFile(file *multipart.FileHeader) (string, error) {
+	src, err := file.Open()
+	if err != nil {
+		return "", fmt.Errorf("ไม่สามารถเปิดไฟล์: %w", err)
+	}
+	defer src.Close()
+
+	dst, err := os.CreateTemp("", "face-batch-*")
+	if err != nil {
+		return "", fmt.Errorf("ไม่สามารถสร้างไฟล์ชั่วคราว: %w", err)
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		os.Remove(dst.Name())
+		return "", fmt.Errorf("ไม่สามารถบันทึกไฟล์ชั่วคราว: %w", err)
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(dst.Name())
+		return "", fmt.Errorf("ไม่สามารถบันทึกไฟล์ชั่วคราว: %w", err)
+	}
+	return dst.Name(), nil
+}
+
+// readFaceBatchArchive ตรวจชนิดของ archive จากเนื้อหา แล้วอ่านและตรวจ manifest
+func readFaceBatchArchive(archivePath string) (string, []faceBatchEntry, error) {
+	archive, err := os.Open(archivePath)
+	if err != nil {
+		return "", nil, fmt.Errorf("ไม่สามารถเปิด archive: %w", err)
+	}
+	defer archive.Close()
+	info, err := archive.Stat()
+	if err != nil {
+		return "", nil, fmt.Errorf("ไม่สามารถเปิด archive: %w", err)
+	}
+
+	header := make([]byte, 512)
+	n, _ := io.ReadFull(archive, header)
+	format := detectArchiveFormat(header[:n])
+	if format == "" {
+		return "", nil, &FaceBatchError{Code: FaceBatchInvalidArchive, Message: "ไฟล์ต้องเป็น zip, tar หรือ tar.gz"}
+	}
+
+	// ใช้ manifest ที่อยู่ระดับบนสุดของ archive path ของไฟล์ใน manifest อ้างอิงจากโฟลเดอร์ของ manifest
+	var manifestName string
+	var manifest []byte
+	depth := -1
+	ambiguous := false
+	err = walkArchive(archive, info.Size(), format, func(name string, size int64, open func() (io.Reader, error)) error {
+		name = archiveEntryName(name)
+		base := path.Base(name)
+		if base != "manifest.csv" && base != "manifest.json" {
+			return nil
+		}
+		nameDepth := strings.Count(name, "/")
+		if depth >= 0 && nameDepth > depth {
+			return nil
+		}
+		ambiguous = nameDepth == depth
+		if ambiguous {
+			return nil
+		}
+		if size > maxFaceBatchManifestSize {
+			return &FaceBatchError{Code: FaceBatchInvalidManifest, Message: fmt.Sprintf("ขนาดไฟล์ manifest ต้องไม่เกิน %d bytes", maxFaceBatchManifestSize)}
+		}
+		content, err := open()
+		if err != nil {
+			return &FaceBatchError{Code: FaceBatchInvalidArchive, Message: fmt.Sprintf("ไม่สามารถอ่านไฟล์ manifest: %v", err)}
+		}
+		data, err := io.ReadAll(io.LimitReader(content, maxFaceBatchManifestSize+1))
+		if err != nil {
+			return &FaceBatchError{Code: FaceBatchInvalidArchive, Message: fmt.Sprintf("ไม่สามารถอ่านไฟล์ manifest: %v", err)}
+		}
+		manifestName, manifest, depth = name, data, nameDepth
+		return nil
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	if manifestName == "" {
+		return "", nil, &FaceBatchError{Code: FaceBatchInvalidManifest, Message: "ไม่พบไฟล์ manifest.csv หรือ manifest.json ใน archive"}
+	}
+	if ambiguous {
+		return "", nil, &FaceBatchError{Code: FaceBatchInvalidManifest, Message: "พบไฟล์ manifest มากกว่าหนึ่งไฟล์ในโฟลเดอร์ระดับเดียวกัน"}
+	}
+
+	entries, err := parseFaceBatchManifest(manifestName, manifest)
+	if err != nil {
+		return "", nil, err
+	}
+	validateFaceBatchEntries(entries, path.Dir(manifestName))
+	return format, entries, nil
+}
+
+// parseFaceBatchManifest แปลง manifest แบบ CSV (มีแถวหัวตาราง) หรือ JSON (array ของรายการ)
+// ที่มี file, person_hash, camera_id และ captured_at (RFC 3339), face_confidence และ yaw ซึ่งไม่บังคับ
+func parseFaceBatchManifest(name string, data []byte) ([]faceBatchEntry, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	if len(data) > maxFaceBatchManifestSize {
+		return nil, &FaceBatchError{Code: FaceBatchInvalidManifest, Message: fmt.Sprintf("ขนาดไฟล์ manifest ต้องไม่เกิน %d bytes", maxFaceBatchManifestSize)}
+	}
+
+	var entries []faceBatchEntry
+	if strings.HasSuffix(name, ".json") {
+		if err := json.Unmarshal(data, &entries); err != nil {
+			return nil, &FaceBatchError{Code: FaceBatchInvalidManifest, Message: fmt.Sprintf("รูปแบบ manifest.json ไม่ถูกต้อง: %v", err)}
+		}
+	} else {
+		records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+		if err != nil {
+			return nil, &FaceBatchError{Code: FaceBatchInvalidManifest, Message: fmt.Sprintf("รูปแบบ manifest.csv ไม่ถูกต้อง: %v", err)}
+		}
+		if len(records) == 0 {
+			return nil, &FaceBatchError{Code: FaceBatchInvalidManifest, Message: "manifest.csv ต้องมีแถวหัวตาราง"}
+		}
+		columns := make(map[string]int)
+		for i, column := range records[0] {
+			columns[strings.ToLower(strings.TrimSpace(column))] = i
+		}
+		for _, column := range []string{"file", "person_hash", "camera_id"} {
+			if _, ok := columns[column]; !ok {
+				return nil, &FaceBatchError{Code: FaceBatchInvalidManifest, Message: fmt.Sprintf("manifest.csv ไม่มีคอลัมน์ %s", column)}
+			}
+		}
+		field := func(record []string, column string) string {
+			if i, ok := columns[column]; ok {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		for _, record := range records[1:] {
+			entry := faceBatchEntry{
+				File:       field(record, "file"),
+				PersonHash: field(record, "person_hash"),
+				CameraID:   field(record, "camera_id"),
+				CapturedAt: field(record, "captured_at"),
+			}
+			for _, number := range []struct {
+				column string
+				value  **float64
+			}{{"face_confidence", &entry.FaceConfidence}, {"yaw", &entry.Yaw}} {
+				text := field(record, number.column)
+				if text == "" || entry.invalid != "" {
+					continue
+				}
+				value, err := strconv.ParseFloat(text, 64)
+				if err != nil {
+					entry.invalid = fmt.Sprintf("%s ต้องเป็นตัวเลข", number.column)
+					continue
+				}
+				*number.value = &value
+			}
+			// embedding ใน CSV เป็น JSON array ในคอลัมน์เดียว
+			if text := field(record, "embedding"); text != "" && entry.invalid == "" {
+				if err := json.Unmarshal([]byte(text), &entry.Embedding); err != nil {
+					entry.invalid = "embedding ต้องเป็น JSON array ของตัวเลข"
+				}
+			}
+			entries = append(entries, entry)
+		}
+	}
+
+	if len(entries) == 0 {
+		return nil, &FaceBatchError{Code: FaceBatchInvalidManifest, Message: "ไม่มีรายการใน manifest"}
+	}
+	if len(entries) > maxFaceBatchItems {
+		return nil, &FaceBatchError{Code: FaceBatchInvalidManifest, Message: fmt.Sprintf("manifest ต้องมีไม่เกิน %d รายการ", maxFaceBatchItems)}
+	}
+	return entries, nil
+}
+
+// validateFaceBatchEntries กำหนด path ของไฟล์ใน archive ให้ทุกรายการ และทำเครื่องหมายรายการที่ไม่ถูกต้อง
+// ซึ่งจะถูกรายงานในผลลัพธ์โดยไม่ทำให้รายการอื่นล้มเหลว
+func validateFaceBatchEntries(entries []faceBatchEntry, dir string) {
+	seen := make(map[string]bool, len(entries))
+	for i := range entries {
+		entry := &entries[i]
+		file := archiveEntryName(entry.File)
+		switch {
+		case entry.File == "":
+			entry.invalid = "ต้องระบุไฟล์ (file)"
+		case path.IsAbs(entry.File) || file == ".." || strings.HasPrefix(file, "../"):
+			entry.invalid = "path ของไฟล์ต้องอยู่ภายใน archive"
+		case entry.PersonHash == "":
+			entry.invalid = "ต้องระบุรหัสบุคคล (person_hash)"
+		case entry.CameraID == "":
+			entry.invalid = "ต้องระบุรหัสกล้อง (camera_id)"
+		}
+		if entry.invalid != "" {
+			continue
+		}
+		if err := validateFaceQuality(entry.FaceQuality); err != nil {
+			entry.invalid = err.Error()
+			continue
+		}
+		if entry.Embedding != nil {
+			if _, err := normalizeEmbedding(entry.Embedding); err != nil {
+				entry.invalid = err.Error()
+				continue
+			}
+		}
+
+		if entry.CapturedAt != "" {
+			capturedAt, err := time.Parse(time.RFC3339, entry.CapturedAt)
+			if err != nil {
+				entry.invalid = "captured_at ต้องอยู่ในรูปแบบ RFC 3339"
+				continue
+			}
+			entry.capturedAt = &capturedAt
+		}
+
+		entry.key = path.Join(dir, file)
+		if seen[entry.key] {
+			entry.invalid = "ไฟล์ซ้ำกับรายการก่อนหน้าใน manifest"
+			continue
+		}
+		seen[entry.key] = true
+	}
+}
+
+// archiveEntryName ปรับ path ของไฟล์ใน archive หรือใน manifest ให้อยู่ในรูปแบบเดียวกัน
+func archiveEntryName(name string) string {
+	return path.Clean(strings.ReplaceAll(name, "\\", "/"))
+}
+
+// detectArchiveFormat ตรวจชนิดของ archive จากส่วนต้นของไฟล์ โดยคืนค่าว่างถ้าไม่รู้จัก
+func detectArchiveFormat(header []byte) string {
+	switch {
+	case bytes.HasPrefix(header, []byte("PK\x03\x04")), bytes.HasPrefix(header, []byte("PK\x05\x06")):
+		return faceBatchFormatZip
+	case bytes.HasPrefix(header, []byte{0x1f, 0x8b}):
+		return faceBatchFormatTarGz
+	case len(header) >= 262 && string(header[257:262]) == "ustar":
+		return faceBatchFormatTar
+	}
+	return ""
+}
+
+// walkArchive เรียก fn กับไฟล์ทุกไฟล์ใน archive ตามลำดับในไฟล์ โดย open ใช้อ่านเนื้อหาของไฟล์ได้เฉพาะระหว่างการเรียก fn
+func walkArchive(r io.ReaderAt, size int64, format string, fn func(name string, size int64, open func() (io.Reader, error)) error) error {
+	if format == faceBatchFormatZip {
+		reader, err := zip.NewReader(r, size)
+		if err != nil {
+			return &FaceBatchError{Code: FaceBatchInvalidArchive, Message: fmt.Sprintf("ไม่สามารถอ่านไฟล์ zip: %v", err)}
+		}
+		for _, file := range reader.File {
+			if file.FileInfo().IsDir() {
+				continue
+			}
+			var content io.ReadCloser
+			open := func() (io.Reader, error) {
+				var err error
+				content, err = file.Open()
+				return content, err
+			}
+			err := fn(file.Name, int64(file.UncompressedSize64), open)
+			if content != nil {
+				content.Close()
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var stream io.Reader = io.NewSectionReader(r, 0, size)
+	if format == faceBatchFormatTarGz {
+		gz, err := gzip.NewReader(stream)
+		if err != nil {
+			return &FaceBatchError{Code: FaceBatchInvalidArchive, Message: fmt.Sprintf("ไม่สามารถอ่านไฟล์ tar.gz: %v", err)}
+		}
+		defer gz.Close()
+		stream = gz
+	}
+	reader := tar.NewReader(stream)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return &FaceBatchError{Code: FaceBatchInvalidArchive, Message: fmt.Sprintf("ไม่สามารถอ่านไฟล์ tar: %v", err)}
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		if err := fn(header.Name, header.Size, func() (io.Reader, error) { return reader, nil }); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package services
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeZip สร้างไฟล์ zip ชั่วคราวจากชื่อไฟล์และเนื้อหา
+func writeZip(t *testing.T, files map[string]string) string {
+	var buf bytes.Buffer
+	writer := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := writer.Create(name)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, writer.Close())
+	return writeTempFile(t, buf.Bytes())
+}
+
+// writeTarGz สร้างไฟล์ tar.gz ชั่วคราวตามลำดับไฟล์ที่ระบุ
+func writeTarGz(t *testing.T, files [][2]string) string {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	writer := tar.NewWriter(gz)
+	for _, file := range files {
+		require.NoError(t, writer.WriteHeader(&tar.Header{Name: file[0], Mode: 0o644, Size: int64(len(file[1])), Typeflag: tar.TypeReg}))
+		_, err := writer.Write([]byte(file[1]))
+		require.NoError(t, err)
+	}
+	require.NoError(t, writer.Close())
+	require.NoError(t, gz.Close())
+	return writeTempFile(t, buf.Bytes())
+}
+
+func writeTempFile(t *testing.T, data []byte) string {
+	name := filepath.Join(t.TempDir(), "archive")
+	require.NoError(t, os.WriteFile(name, data, 0o644))
+	return name
+}
+
+// TestReadFaceBatchArchive ทดสอบการอ่าน manifest แบบ CSV ใน zip และการทำเครื่องหมายรายการที่ไม่ถูกต้อง
+func TestReadFaceBatchArchive(t *testing.T) {
+	archive := writeZip(t, map[string]string{
+		"site-a/manifest.csv": "\xef\xbb\xbfFile,person_hash,camera_id,captured_at\n" +
+			"faces/a.jpg,p1,cam-1,2025-03-01T08:00:00+07:00\n" +
+			"faces/b.jpg,p2,cam-1,\n" +
+			"faces/c.jpg,,cam-1,\n" +
+			"faces/d.jpg,p4,cam-1,yesterday\n" +
+			"../e.jpg,p5,cam-1,\n" +
+			"./faces/a.jpg,p6,cam-1,\n",
+		"site-a/faces/a.jpg":         "a",
+		"site-a/nested/manifest.csv": "file,person_hash,camera_id\n",
+	})
+
+	format, entries, err := readFaceBatchArchive(archive)
+	require.NoError(t, err)
+	assert.Equal(t, faceBatchFormatZip, format)
+	require.Len(t, entries, 6)
+
+	assert.Equal(t, "site-a/faces/a.jpg", entries[0].key)
+	assert.Empty(t, entries[0].invalid)
+	require.NotNil(t, entries[0].capturedAt)
+	assert.Equal(t, "2025-03-01T01:00:00Z", entries[0].capturedAt.UTC().Format("2006-01-02T15:04:05Z07:00"))
+	assert.Empty(t, entries[1].invalid)
+	assert.Nil(t, entries[1].capturedAt)
+	assert.Contains(t, entries[2].invalid, "person_hash")
+	assert.Contains(t, entries[3].invalid, "captured_at")
+	assert.NotEmpty(t, entries[4].invalid)
+	assert.Contains(t, entries[5].invalid, "ซ้ำ")
+}
+
+// TestReadFaceBatchArchive_Rejected ทดสอบรหัสข้อผิดพลาดของ archive และ manifest ที่ใช้ไม่ได้
+func TestReadFaceBatchArchive_Rejected(t *testing.T) {
+	tests := []struct {
+		name    string
+		archive string
+		code    string
+	}{
+		{"not an archive", writeTempFile(t, []byte("not an archive")), FaceBatchInvalidArchive},
+		{"no manifest", writeZip(t, map[string]string{"a.jpg": "a"}), FaceBatchInvalidManifest},
+		{"two manifests", writeZip(t, map[string]string{"manifest.csv": "file,person_hash,camera_id\na.jpg,p1,cam-1\n", "manifest.json": "[]"}), FaceBatchInvalidManifest},
+		{"missing column", writeZip(t, map[string]string{"manifest.csv": "file,person_hash\na.jpg,p1\n"}), FaceBatchInvalidManifest},
+		{"empty manifest", writeZip(t, map[string]string{"manifest.json": "[]"}), FaceBatchInvalidManifest},
+		{"bad json", writeZip(t, map[string]string{"manifest.json": "{"}), FaceBatchInvalidManifest},
+	}
+	for _, tt := range tests {
+		_, _, err := readFaceBatchArchive(tt.archive)
+		var batchErr *FaceBatchError
+		require.True(t, errors.As(err, &batchErr), tt.name)
+		assert.Equal(t, tt.code, batchErr.Code, tt.name)
+	}
+}
+
+// TestWalkArchive_TarGz ทดสอบการอ่าน manifest แบบ JSON ที่อยู่ท้าย tar.gz และการอ่านไฟล์ตามลำดับ
+func TestWalkArchive_TarGz(t *testing.T) {
+	archive := writeTarGz(t, [][2]string{
+		{"a.jpg", "image a"},
+		{"b.jpg", "image b"},
+		{"manifest.json", `[{"file":"a.jpg","person_hash":"p1","camera_id":"cam-1"},{"file":"b.jpg","person_hash":"p2","camera_id":"cam-2","captured_at":"2025-03-01T08:00:00Z"}]`},
+	})
+
+	format, entries, err := readFaceBatchArchive(archive)
+	require.NoError(t, err)
+	assert.Equal(t, faceBatchFormatTarGz, format)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "b.jpg", entries[1].key)
+	assert.Equal(t, "cam-2", entries[1].CameraID)
+	require.NotNil(t, entries[1].capturedAt)
+
+	file, err := os.Open(archive)
+	require.NoError(t, err)
+	defer file.Close()
+	info, err := file.Stat()
+	require.NoError(t, err)
+
+	contents := map[string]string{}
+	err = walkArchive(file, info.Size(), format, func(name string, size int64, open func() (io.Reader, error)) error {
+		if name == "manifest.json" {
+			return nil
+		}
+		content, err := open()
+		require.NoError(t, err)
+		data, err := io.ReadAll(content)
+		require.NoError(t, err)
+		assert.Equal(t, int64(len(data)), size)
+		contents[name] = string(data)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"a.jpg": "image a", "b.jpg": "image b"}, contents)
+}
+
+// TestParseFaceBatchManifest_Quality ทดสอบคอลัมน์ข้อมูลคุณภาพของ manifest แบบ CSV ที่ไม่บังคับ
+func TestParseFaceBatchManifest_Quality(t *testing.T) {
+	entries, err := parseFaceBatchManifest("manifest.csv", []byte("file,person_hash,camera_id,face_confidence,yaw\n"+
+		"a.jpg,p1,cam-1,0.9,-15\n"+
+		"b.jpg,p2,cam-1,,\n"+
+		"c.jpg,p3,cam-1,high,\n"+
+		"d.jpg,p4,cam-1,2,\n"))
+	require.NoError(t, err)
+	validateFaceBatchEntries(entries, ".")
+	require.Len(t, entries, 4)
+
+	require.NotNil(t, entries[0].FaceConfidence)
+	assert.Equal(t, 0.9, *entries[0].FaceConfidence)
+	require.NotNil(t, entries[0].Yaw)
+	assert.Equal(t, -15.0, *entries[0].Yaw)
+	assert.Empty(t, entries[0].invalid)
+	assert.Nil(t, entries[1].FaceConfidence)
+	assert.Empty(t, entries[1].invalid)
+	assert.Contains(t, entries[2].invalid, "face_confidence")
+	assert.Contains(t, entries[3].invalid, "face_confidence")
+}
+
+// TestParseFaceBatchManifest_Embedding ทดสอบคอลัมน์ embedding ของ manifest แบบ CSV ซึ่งเป็น JSON array
+func TestParseFaceBatchManifest_Embedding(t *testing.T) {
+	entries, err := parseFaceBatchManifest("manifest.csv", []byte("file,person_hash,camera_id,embedding\n"+
+		"a.jpg,p1,cam-1,\"[0.6,0.8]\"\n"+
+		"b.jpg,p2,cam-1,\n"+
+		"c.jpg,p3,cam-1,0.6 0.8\n"+
+		"d.jpg,p4,cam-1,\"[0,0]\"\n"))
+	require.NoError(t, err)
+	validateFaceBatchEntries(entries, ".")
+	require.Len(t, entries, 4)
+
+	assert.Equal(t, []float32{0.6, 0.8}, entries[0].Embedding)
+	assert.Empty(t, entries[0].invalid)
+	assert.Nil(t, entries[1].Embedding)
+	assert.Empty(t, entries[1].invalid)
+	assert.Contains(t, entries[2].invalid, "embedding")
+	assert.Contains(t, entries[3].invalid, "embedding")
+}
+
+// TestFaceBatchItemResult ทดสอบรหัสข้อผิดพลาดของผลลัพธ์แต่ละรายการ
+func TestFaceBatchItemResult(t *testing.T) {
+	entry := &faceBatchEntry{File: "a.jpg", PersonHash: "p1", CameraID: "cam-1"}
+
+	result := faceBatchItemResult(entry, &models.FaceImage{Base: models.Base{ID: "image-1"}}, nil)
+	assert.Equal(t, models.FaceBatchItemCreated, result.Status)
+	assert.Equal(t, "image-1", result.FaceImageID)
+	assert.Empty(t, result.Code)
+
+	result = faceBatchItemResult(entry, nil, &FaceImageError{Code: FaceImageDimensionsTooSmall, Message: "too small"})
+	assert.Equal(t, models.FaceBatchItemFailed, result.Status)
+	assert.Equal(t, FaceImageDimensionsTooSmall, result.Code)
+
+	result = faceBatchItemResult(entry, nil, &FaceBatchError{Code: FaceBatchFileNotFound, Message: "not found"})
+	assert.Equal(t, FaceBatchFileNotFound, result.Code)
+
+	result = faceBatchItemResult(entry, nil, errors.New("database unavailable"))
+	assert.Equal(t, FaceBatchUploadFailed, result.Code)
+	assert.Equal(t, "database unavailable", result.Error)
+}
@@ -0,0 +1,360 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/draw"
+	"io"
+	"log"
+	"math/bits"
+	"sort"
+	"time"
+
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/models"
+	"github.com/google/uuid"
+	xdraw "golang.org/x/image/draw"
+	"gorm.io/gorm"
+)
+
+const (
+	// maxDedupDistance ระยะ Hamming สูงสุดที่ตั้งค่าได้ (ครึ่งหนึ่งของ hash 64 bit ซึ่งเป็นค่าที่รูปไม่เกี่ยวข้องกันมักได้)
+	maxDedupDistance = 32
+	// maxDedupWindow ช่วงเวลาสูงสุดที่ตั้งค่าได้ (วินาที)
+	maxDedupWindow = 7 * 24 * 60 * 60
+	// dedupCandidateLimit จำนวนรูปล่าสุดของบุคคลที่เปรียบเทียบตอนอัปโหลด
+	dedupCandidateLimit = 200
+	// faceDedupChunkSize จำนวนรูปภาพหรือบุคคลที่ดึงต่อครั้งในงานรวมรูปที่ซ้ำ
+	faceDedupChunkSize = 100
+	// maxFaceDedupFailures จำนวนรายการรูปที่คำนวณ hash ไม่สำเร็จสูงสุดที่เก็บในผลลัพธ์
+	maxFaceDedupFailures = 100
+)
+
+// perceptualHash คำนวณ dHash 64 bit ของรูป: ย่อเป็นภาพขาวดำ 9x8 แล้วเทียบความสว่างของ pixel ที่อยู่ติดกันในแนวนอน
+// รูปที่ต่างกันเพียงการบีบอัด ขนาด หรือแสงเล็กน้อยจึงได้ hash ที่ต่างกันไม่กี่ bit
+func perceptualHash(img image.Image) int64 {
+	gray := image.NewGray(image.Rect(0, 0, 9, 8))
+	xdraw.BiLinear.Scale(gray, gray.Bounds(), img, img.Bounds(), draw.Src, nil)
+
+	var hash uint64
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			hash <<= 1
+			if gray.GrayAt(x, y).Y > gray.GrayAt(x+1, y).Y {
+				hash |= 1
+			}
+		}
+	}
+	return int64(hash)
+}
+
+// hammingDistance นับจำนวน bit ที่ต่างกันของ perceptual hash สองค่า
+func hammingDistance(a, b int64) int {
+	return bits.OnesCount64(uint64(a ^ b))
+}
+
+// betterFaceImage ตรวจว่ารูป a คุณภาพดีกว่ารูป b หรือไม่ โดยรูปที่คะแนนคุณภาพสูงกว่าดีกว่า
+// แล้วจึงเป็นรูปที่มีจำนวน pixel มากกว่า และรูปที่ใหม่กว่าเมื่อเท่ากัน
+func betterFaceImage(a, b *models.FaceImage) bool {
+	if a.QualityScore != b.QualityScore {
+		return a.QualityScore > b.QualityScore
+	}
+	if areaA, areaB := a.Width*a.Height, b.Width*b.Height; areaA != areaB {
+		return areaA > areaB
+	}
+	return a.CreatedAt.After(b.CreatedAt)
+}
+
+// findNearDuplicate หารูปภาพของบุคคลที่อัปโหลดภายในช่วงเวลาของการตั้งค่า และมี perceptual hash ใกล้ที่สุดไม่เกินระยะที่กำหนด
+// เปรียบเทียบเฉพาะรูปที่เก็บไฟล์ของตัวเอง เพราะรูปที่ซ้ำแบบ link ใช้ไฟล์ของรูปเหล่านั้น
+func (s *FaceService) findNearDuplicate(ctx context.Context, organizationID, personHash string, hash int64, settings *models.FaceUploadSettings) (*models.FaceImage, error) {
+	var recent []models.FaceImage
+	if err := s.DB.DB.WithContext(ctx).
+		Where("organization_id = ? AND person_hash = ? AND duplicate_of_id IS NULL AND perceptual_hash IS NOT NULL AND created_at >= ?",
+			organizationID, personHash, time.Now().Add(-time.Duration(settings.DedupWindow)*time.Second)).
+		Order("created_at DESC").
+		Limit(dedupCandidateLimit).
+		Find(&recent).Error; err != nil {
+		return nil, fmt.Errorf("ไม่สามารถตรวจสอบรูปภาพที่ซ้ำ: %w", err)
+	}
+
+	var nearest *models.FaceImage
+	nearestDistance := settings.DedupMaxDistance + 1
+	for i := range recent {
+		if distance := hammingDistance(*recent[i].PerceptualHash, hash); distance < nearestDistance {
+			nearest, nearestDistance = &recent[i], distance
+		}
+	}
+	return nearest, nil
+}
+
+// recordNearDuplicate จัดการรูปที่อัปโหลดซึ่งซ้ำกับรูปเดิมตามการตั้งค่าขององค์กร
+// skip คืนรูปเดิมโดยไม่บันทึกอะไร ส่วน link สร้างรูปภาพใหม่ที่ใช้ไฟล์ของรูปเดิม พร้อม embedding ของรูปที่อัปโหลด
+func (s *FaceService) recordNearDuplicate(ctx context.Context, original *models.FaceImage, settings *models.FaceUploadSettings, duplicate *models.FaceImage, embedding []float32) (*models.FaceImage, error) {
+	if settings.DedupMode != models.FaceDedupLink {
+		original.Duplicate = true
+		s.URLs.SignFaceImage(original)
+		return original, nil
+	}
+
+	duplicate.ID = uuid.New().String()
+	duplicate.ImageKey = original.ImageKey
+	duplicate.ThumbnailKeys = original.ThumbnailKeys
+	duplicate.Width = original.Width
+	duplicate.Height = original.Height
+	duplicate.DuplicateOfID = &original.ID
+	if err := s.saveFaceImage(ctx, duplicate, embedding); err != nil {
+		return nil, err
+	}
+
+	s.URLs.SignFaceImage(duplicate)
+	return duplicate, nil
+}
+
+// deleteFaceImageFiles ลบไฟล์รูปภาพและรูปย่อ เว้นแต่ยังมีรูปภาพอื่นที่ใช้ไฟล์เดียวกัน (รูปที่ซ้ำแบบ link)
+func (s *FaceService) deleteFaceImageFiles(ctx context.Context, faceImage *models.FaceImage) {
+	var references int64
+	if err := s.DB.DB.WithContext(ctx).Model(&models.FaceImage{}).
+		Where("organization_id = ? AND image_key = ?", faceImage.OrganizationID, faceImage.ImageKey).
+		Count(&references).Error; err != nil {
+		log.Printf("ไม่สามารถตรวจสอบการใช้ไฟล์รูปภาพ %s: %v", faceImage.ImageKey, err)
+		return
+	}
+	if references > 0 {
+		return
+	}
+
+	if err := s.Storage.DeleteObject(ctx, faceImage.ImageKey); err != nil {
+		log.Printf("ไม่สามารถลบไฟล์รูปภาพ %s: %v", faceImage.ImageKey, err)
+	}
+	s.deleteThumbnails(ctx, faceImage.ThumbnailKeys)
+}
+
+// StartFaceDedup สร้างงานเบื้องหลังที่รวมรูปภาพใบหน้าเดิมขององค์กรที่ซ้ำกัน โดยเก็บรูปที่คุณภาพดีที่สุดไว้
+func (s *FaceService) StartFaceDedup(ctx context.Context, organizationID string) (*models.Job, error) {
+	if organizationID == "" {
+		return nil, fmt.Errorf("ไม่พบข้อมูลองค์กร")
+	}
+
+	job, err := s.Jobs.CreateJob(ctx, organizationID, models.JobTypeFaceDedup, struct{}{})
+	if err != nil {
+		return nil, err
+	}
+
+	s.Jobs.Run(job, s.RunFaceDedup)
+	return job, nil
+}
+
+// GetFaceDedupJob ดึงงานรวมรูปภาพใบหน้าที่ซ้ำขององค์กร
+func (s *FaceService) GetFaceDedupJob(ctx context.Context, id, organizationID string) (*models.Job, error) {
+	job, err := s.Jobs.GetJob(ctx, id, organizationID)
+	if err != nil {
+		return nil, err
+	}
+	if job.Type != models.JobTypeFaceDedup {
+		return nil, fmt.Errorf("ไม่พบงาน")
+	}
+	return job, nil
+}
+
+// RunFaceDedup คำนวณ perceptual hash และคะแนนคุณภาพให้รูปภาพเดิมที่ยังไม่มี แล้วรวมรูปของแต่ละบุคคลที่ซ้ำกันภายในช่วงเวลาของการตั้งค่า
+// และเลือกรูปภาพหลักของบุคคลใหม่ รูปที่ซ้ำถูกลบ หรือเปลี่ยนเป็นรูปที่ใช้ไฟล์ของรูปที่เก็บไว้เมื่อองค์กรตั้งค่า dedup_mode เป็น link
+// งานเริ่มใหม่ได้เสมอ เพราะรูปที่รวมแล้วไม่ถูกเปรียบเทียบอีก
+func (s *FaceService) RunFaceDedup(ctx context.Context, job *models.Job, progress func(int64)) (interface{}, error) {
+	settings, err := s.GetUploadSettings(ctx, job.OrganizationID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := models.FaceDedupResult{}
+	if err := s.hashFaceImages(ctx, job.OrganizationID, &result); err != nil {
+		return nil, err
+	}
+
+	persons := s.DB.DB.WithContext(ctx).Model(&models.FaceImage{}).
+		Where("organization_id = ? AND duplicate_of_id IS NULL AND perceptual_hash IS NOT NULL", job.OrganizationID)
+	var total int64
+	if err := persons.Session(&gorm.Session{}).Distinct("person_hash").Count(&total).Error; err != nil {
+		return nil, fmt.Errorf("ไม่สามารถนับจำนวนบุคคล: %w", err)
+	}
+	if err := s.Jobs.SetTotal(ctx, job.ID, total); err != nil {
+		return nil, err
+	}
+
+	lastHash := ""
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		var personHashes []string
+		if err := persons.Session(&gorm.Session{}).Where("person_hash > ?", lastHash).
+			Distinct("person_hash").Order("person_hash").Limit(faceDedupChunkSize).
+			Pluck("person_hash", &personHashes).Error; err != nil {
+			return nil, fmt.Errorf("ไม่สามารถดึงรายการบุคคล: %w", err)
+		}
+		if len(personHashes) == 0 {
+			break
+		}
+
+		for _, personHash := range personHashes {
+			if err := s.dedupPersonImages(ctx, job.OrganizationID, personHash, settings, &result); err != nil {
+				return nil, err
+			}
+			result.Persons++
+		}
+
+		lastHash = personHashes[len(personHashes)-1]
+		progress(result.Persons)
+	}
+
+	return result, nil
+}
+
+// hashFaceImages คำนวณ perceptual hash ขนาด และคะแนนคุณภาพของรูปภาพเดิมที่ยังไม่มี จากไฟล์ใน storage
+func (s *FaceService) hashFaceImages(ctx context.Context, organizationID string, result *models.FaceDedupResult) error {
+	lastID := ""
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		var images []models.FaceImage
+		if err := s.DB.DB.WithContext(ctx).
+			Where("organization_id = ? AND duplicate_of_id IS NULL AND (perceptual_hash IS NULL OR sharpness IS NULL) AND id > ?", organizationID, lastID).
+			Order("id").Limit(faceDedupChunkSize).
+			Find(&images).Error; err != nil {
+			return fmt.Errorf("ไม่สามารถดึงข้อมูลรูปภาพ: %w", err)
+		}
+		if len(images) == 0 {
+			return nil
+		}
+
+		for i := range images {
+			if err := s.hashFaceImage(ctx, &images[i]); err != nil {
+				result.Failed++
+				if len(result.Failures) < maxFaceDedupFailures {
+					result.Failures = append(result.Failures, models.FaceDedupFailure{
+						FaceImageID: images[i].ID,
+						Error:       err.Error(),
+					})
+				}
+				continue
+			}
+			result.Hashed++
+		}
+		lastID = images[len(images)-1].ID
+	}
+}
+
+// hashFaceImage อ่านไฟล์ของรูปภาพ แล้วบันทึก perceptual hash ขนาด และคะแนนคุณภาพของรูป
+func (s *FaceService) hashFaceImage(ctx context.Context, faceImage *models.FaceImage) error {
+	reader, _, err := s.Storage.GetObject(ctx, faceImage.ImageKey)
+	if err != nil {
+		return fmt.Errorf("ไม่สามารถอ่านไฟล์รูปภาพ: %w", err)
+	}
+	data, err := io.ReadAll(reader)
+	reader.Close()
+	if err != nil {
+		return fmt.Errorf("ไม่สามารถอ่านไฟล์รูปภาพ: %w", err)
+	}
+
+	img, err := decodeFaceImage(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	hash := perceptualHash(img)
+	faceImage.Width = img.Bounds().Dx()
+	faceImage.Height = img.Bounds().Dy()
+	scoreFaceImage(faceImage, img)
+	if err := s.DB.DB.WithContext(ctx).Model(faceImage).Updates(map[string]interface{}{
+		"perceptual_hash": hash,
+		"width":           faceImage.Width,
+		"height":          faceImage.Height,
+		"sharpness":       faceImage.Sharpness,
+		"quality_score":   faceImage.QualityScore,
+	}).Error; err != nil {
+		return fmt.Errorf("ไม่สามารถบันทึก hash ของรูปภาพ: %w", err)
+	}
+	return nil
+}
+
+// dedupPersonImages จัดกลุ่มรูปของบุคคลที่ซ้ำกัน โดยไล่จากรูปที่คุณภาพดีที่สุด
+// รูปที่อยู่ในระยะ hash และช่วงเวลาของรูปที่เก็บไว้ถูกรวมเข้ากับรูปนั้น ส่วนรูปอื่นถูกเก็บไว้
+func (s *FaceService) dedupPersonImages(ctx context.Context, organizationID, personHash string, settings *models.FaceUploadSettings, result *models.FaceDedupResult) error {
+	var images []models.FaceImage
+	if err := s.DB.DB.WithContext(ctx).
+		Where("organization_id = ? AND person_hash = ? AND duplicate_of_id IS NULL AND perceptual_hash IS NOT NULL", organizationID, personHash).
+		Find(&images).Error; err != nil {
+		return fmt.Errorf("ไม่สามารถดึงข้อมูลรูปภาพ: %w", err)
+	}
+	sort.Slice(images, func(i, j int) bool { return betterFaceImage(&images[i], &images[j]) })
+	result.Images += int64(len(images))
+
+	window := time.Duration(settings.DedupWindow) * time.Second
+	var kept []*models.FaceImage
+	for i := range images {
+		image := &images[i]
+		var keeper *models.FaceImage
+		for _, candidate := range kept {
+			gap := image.CreatedAt.Sub(candidate.CreatedAt)
+			if gap < 0 {
+				gap = -gap
+			}
+			if gap <= window && hammingDistance(*image.PerceptualHash, *candidate.PerceptualHash) <= settings.DedupMaxDistance {
+				keeper = candidate
+				break
+			}
+		}
+		if keeper == nil {
+			kept = append(kept, image)
+			continue
+		}
+
+		if err := s.collapseFaceImage(ctx, keeper, image, settings.DedupMode == models.FaceDedupLink); err != nil {
+			return err
+		}
+		result.Collapsed++
+	}
+
+	// รูปที่ถูกรวมหรือเพิ่งได้คะแนนคุณภาพอาจเปลี่ยนรูปภาพหลักของบุคคล
+	return selectPrimaryFaceImage(s.DB.DB.WithContext(ctx), organizationID, personHash)
+}
+
+// collapseFaceImage รวมรูปที่ซ้ำเข้ากับรูปที่เก็บไว้: ย้ายรูปที่ link และการแจ้งเตือนที่ใช้รูปที่ซ้ำไปใช้รูปที่เก็บไว้
+// แล้วลบรูปที่ซ้ำหรือเปลี่ยนเป็น link (เมื่อ link เป็น true) ก่อนลบไฟล์ของรูปที่ซ้ำ
+func (s *FaceService) collapseFaceImage(ctx context.Context, keeper, duplicate *models.FaceImage, link bool) error {
+	shared := &models.FaceImage{DuplicateOfID: &keeper.ID, ImageKey: keeper.ImageKey, ThumbnailKeys: keeper.ThumbnailKeys}
+	err := s.DB.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.FaceImage{}).Where("duplicate_of_id = ?", duplicate.ID).
+			Select("duplicate_of_id", "image_key", "thumbnail_keys").Updates(shared).Error; err != nil {
+			return err
+		}
+
+		alerts := map[string]interface{}{"face_image_key": keeper.ImageKey}
+		if !link {
+			alerts["face_image_id"] = keeper.ID
+		}
+		if err := tx.Model(&models.WatchlistAlert{}).
+			Where("organization_id = ? AND (face_image_id = ? OR face_image_key = ?)", duplicate.OrganizationID, duplicate.ID, duplicate.ImageKey).
+			Updates(alerts).Error; err != nil {
+			return err
+		}
+
+		if link {
+			return tx.Model(&models.FaceImage{}).Where("id = ?", duplicate.ID).
+				Select("duplicate_of_id", "image_key", "thumbnail_keys").Updates(shared).Error
+		}
+		if err := tx.Where("face_image_id = ?", duplicate.ID).Delete(&models.FaceEmbedding{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(duplicate).Error
+	})
+	if err != nil {
+		return fmt.Errorf("ไม่สามารถรวมรูปภาพที่ซ้ำ: %w", err)
+	}
+
+	s.deleteFaceImageFiles(ctx, duplicate)
+	return nil
+}
@@ -0,0 +1,75 @@
+package services
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+	"time"
+
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// gradientImage สร้างรูปที่มีความสว่างไล่ระดับตามแนวทแยงและวงกลมตรงกลาง คล้ายรูปใบหน้าที่มีรายละเอียด
+func gradientImage(width, height int, invert bool) image.Image {
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			value := uint8((x*255/width + y*255/height) / 2)
+			dx, dy := x-width/2, y-height/2
+			if dx*dx+dy*dy < width*width/16 {
+				value = 255 - value
+			}
+			if invert {
+				value = 255 - value
+			}
+			img.SetGray(x, y, color.Gray{Y: value})
+		}
+	}
+	return img
+}
+
+// TestPerceptualHash ทดสอบว่ารูปเดียวกันที่ถูกบีบอัดหรือย่อได้ hash ใกล้กัน ส่วนรูปที่ต่างกันได้ hash ห่างกัน
+func TestPerceptualHash(t *testing.T) {
+	original := gradientImage(400, 400, false)
+	hash := perceptualHash(original)
+
+	// บีบอัด JPEG คุณภาพต่ำ
+	var compressed bytes.Buffer
+	require.NoError(t, jpeg.Encode(&compressed, original, &jpeg.Options{Quality: 40}))
+	decoded, err := jpeg.Decode(&compressed)
+	require.NoError(t, err)
+	assert.LessOrEqual(t, hammingDistance(hash, perceptualHash(decoded)), 4)
+
+	// รูปเดียวกันที่เล็กกว่า
+	assert.LessOrEqual(t, hammingDistance(hash, perceptualHash(gradientImage(120, 120, false))), 4)
+
+	// รูปที่ต่างกัน
+	assert.Greater(t, hammingDistance(hash, perceptualHash(gradientImage(400, 400, true))), 32)
+}
+
+// TestHammingDistance ทดสอบการนับ bit ที่ต่างกัน รวมถึง bit เครื่องหมายของ int64
+func TestHammingDistance(t *testing.T) {
+	assert.Equal(t, 0, hammingDistance(42, 42))
+	assert.Equal(t, 2, hammingDistance(0b1010, 0b0110))
+	assert.Equal(t, 64, hammingDistance(0, -1))
+}
+
+// TestBetterFaceImage ทดสอบการเลือกรูปที่คุณภาพดีกว่า
+func TestBetterFaceImage(t *testing.T) {
+	now := time.Now()
+	large := &models.FaceImage{Width: 400, Height: 400, Base: models.Base{CreatedAt: now.Add(-time.Hour)}}
+	small := &models.FaceImage{Width: 200, Height: 200, Base: models.Base{CreatedAt: now}}
+	newer := &models.FaceImage{Width: 400, Height: 400, Base: models.Base{CreatedAt: now}}
+
+	assert.True(t, betterFaceImage(large, small))
+	assert.False(t, betterFaceImage(small, large))
+	assert.True(t, betterFaceImage(newer, large))
+
+	// คะแนนคุณภาพมาก่อนขนาดของรูป
+	sharper := &models.FaceImage{Width: 200, Height: 200, QualityScore: 0.8}
+	assert.True(t, betterFaceImage(sharper, large))
+}
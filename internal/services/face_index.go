@@ -0,0 +1,273 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/db"
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/models"
+)
+
+const (
+	// faceIndexLoadBatchSize จำนวน embedding ที่อ่านจากฐานข้อมูลต่อครั้ง
+	faceIndexLoadBatchSize = 1000
+	// faceIndexSyncOverlap ช่วงเวลาที่อ่าน embedding ซ้ำในการ sync แต่ละครั้ง
+	// เผื่อ embedding ที่ replica อื่น commit ช้ากว่าเวลาที่บันทึก หรือนาฬิกาของ replica ไม่ตรงกัน
+	faceIndexSyncOverlap = time.Minute
+)
+
+// ErrFaceIndexLoading ถูกส่งคืนเมื่อค้นหาก่อนดัชนีใบหน้าโหลด embedding จากฐานข้อมูลเสร็จ
+var ErrFaceIndexLoading = errors.New("กำลังโหลดดัชนีใบหน้า กรุณาลองใหม่ภายหลัง")
+
+// faceIndexMatch เป็นรูปภาพที่ embedding ใกล้ embedding ที่ค้นหา
+type faceIndexMatch struct {
+	FaceImageID string
+	Similarity  float64
+}
+
+// orgFaceIndex เป็นดัชนี embedding ของใบหน้าในองค์กรหนึ่ง
+// ค้นหาด้วยการเปรียบเทียบทุกรายการจนกว่าจำนวน embedding ถึงเกณฑ์ จากนั้นจึงสร้างและใช้ดัชนี HNSW
+type orgFaceIndex struct {
+	mu         sync.RWMutex
+	dimensions int
+	ids        []string
+	vectors    [][]float32
+	known      map[string]bool
+	graph      *hnswGraph
+}
+
+// FaceIndex เก็บ embedding ของรูปภาพใบหน้าทุกองค์กรไว้ในหน่วยความจำเพื่อค้นหาใบหน้าที่คล้ายกัน
+// embedding ถูกโหลดจากฐานข้อมูลตอนเริ่มต้น และ sync เป็นระยะเพื่อรับ embedding ที่ replica อื่นบันทึก
+// รูปภาพที่ถูกลบยังอยู่ในดัชนีจนกว่าจะเริ่มใหม่ ผู้ค้นหาจึงต้องกรองรูปที่ถูกลบออกจากผลลัพธ์
+type FaceIndex struct {
+	DB            *db.PostgresDB
+	HNSWThreshold int
+
+	mu       sync.RWMutex
+	orgs     map[string]*orgFaceIndex
+	loaded   bool
+	syncMu   sync.Mutex
+	syncedAt time.Time
+}
+
+// NewFaceIndex สร้าง FaceIndex ใหม่ที่ยังไม่ได้โหลด embedding
+// องค์กรที่มี embedding อย่างน้อย hnswThreshold รายการใช้ดัชนี HNSW
+func NewFaceIndex(postgres *db.PostgresDB, hnswThreshold int) *FaceIndex {
+	return &FaceIndex{
+		DB:            postgres,
+		HNSWThreshold: hnswThreshold,
+		orgs:          make(map[string]*orgFaceIndex),
+	}
+}
+
+// normalizeEmbedding ตรวจ embedding และคืนสำเนาที่ยาวหนึ่งหน่วย เพื่อให้ cosine similarity เป็นผลคูณภายใน
+func normalizeEmbedding(embedding []float32) ([]float32, error) {
+	if len(embedding) == 0 || len(embedding) > models.MaxEmbeddingDimensions {
+		return nil, fmt.Errorf("embedding ต้องมี 1 ถึง %d มิติ", models.MaxEmbeddingDimensions)
+	}
+	var norm float64
+	for _, v := range embedding {
+		if math.IsNaN(float64(v)) || math.IsInf(float64(v), 0) {
+			return nil, fmt.Errorf("embedding ต้องเป็นตัวเลขที่มีค่าจำกัด")
+		}
+		norm += float64(v) * float64(v)
+	}
+	if norm == 0 {
+		return nil, fmt.Errorf("embedding ต้องไม่เป็นศูนย์ทุกมิติ")
+	}
+	norm = math.Sqrt(norm)
+	normalized := make([]float32, len(embedding))
+	for i, v := range embedding {
+		normalized[i] = float32(float64(v) / norm)
+	}
+	return normalized, nil
+}
+
+// org คืนดัชนีขององค์กร และสร้างใหม่ถ้ายังไม่มีเมื่อ create เป็น true
+func (x *FaceIndex) org(organizationID string, create bool) *orgFaceIndex {
+	x.mu.RLock()
+	index := x.orgs[organizationID]
+	x.mu.RUnlock()
+	if index != nil || !create {
+		return index
+	}
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if index = x.orgs[organizationID]; index == nil {
+		index = &orgFaceIndex{known: make(map[string]bool)}
+		x.orgs[organizationID] = index
+	}
+	return index
+}
+
+// Add เพิ่ม embedding ที่ยาวหนึ่งหน่วยของรูปภาพเข้าดัชนีขององค์กร embedding ที่มีอยู่แล้วถูกข้าม
+func (x *FaceIndex) Add(organizationID, faceImageID string, vector []float32) {
+	if x == nil {
+		return
+	}
+	index := x.org(organizationID, true)
+	index.mu.Lock()
+	defer index.mu.Unlock()
+
+	if index.known[faceImageID] {
+		return
+	}
+	if index.dimensions == 0 {
+		index.dimensions = len(vector)
+	}
+	if len(vector) != index.dimensions {
+		log.Printf("ข้าม embedding ของรูปภาพ %s: มี %d มิติ แต่ embedding ขององค์กรมี %d มิติ", faceImageID, len(vector), index.dimensions)
+		return
+	}
+
+	index.known[faceImageID] = true
+	index.ids = append(index.ids, faceImageID)
+	index.vectors = append(index.vectors, vector)
+	switch {
+	case index.graph != nil:
+		index.graph.Insert(vector)
+	case x.HNSWThreshold > 0 && len(index.vectors) >= x.HNSWThreshold:
+		// สร้างดัชนี HNSW จาก embedding ทั้งหมดเมื่อจำนวนถึงเกณฑ์ จากนั้นเพิ่มทีละรายการ
+		index.graph = newHNSWGraph(int64(len(organizationID)))
+		for _, v := range index.vectors {
+			index.graph.Insert(v)
+		}
+	}
+}
+
+// Dimensions คืนจำนวนมิติของ embedding ขององค์กร หรือ 0 ถ้ายังไม่มี embedding
+func (x *FaceIndex) Dimensions(organizationID string) int {
+	index := x.org(organizationID, false)
+	if index == nil {
+		return 0
+	}
+	index.mu.RLock()
+	defer index.mu.RUnlock()
+	return index.dimensions
+}
+
+// Search คืนรูปภาพขององค์กรที่ embedding ใกล้ query (ยาวหนึ่งหน่วย) ที่สุดไม่เกิน n รายการ เรียงจากคล้ายที่สุด
+func (x *FaceIndex) Search(organizationID string, query []float32, n int) ([]faceIndexMatch, error) {
+	x.mu.RLock()
+	loaded := x.loaded
+	x.mu.RUnlock()
+	if !loaded {
+		return nil, ErrFaceIndexLoading
+	}
+
+	index := x.org(organizationID, false)
+	if index == nil {
+		return nil, nil
+	}
+	index.mu.RLock()
+	defer index.mu.RUnlock()
+	if len(query) != index.dimensions {
+		return nil, &FaceImageError{Code: FaceImageInvalidEmbedding, Message: fmt.Sprintf("embedding ต้องมี %d มิติ เท่ากับ embedding ของรูปภาพในองค์กร", index.dimensions)}
+	}
+
+	var matches []faceIndexMatch
+	if index.graph != nil {
+		for _, candidate := range index.graph.Search(query, n) {
+			matches = append(matches, faceIndexMatch{FaceImageID: index.ids[candidate.node], Similarity: float64(1 - candidate.distance)})
+		}
+		return matches, nil
+	}
+
+	matches = make([]faceIndexMatch, len(index.vectors))
+	for i, vector := range index.vectors {
+		matches[i] = faceIndexMatch{FaceImageID: index.ids[i], Similarity: float64(dotProduct(query, vector))}
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i].Similarity > matches[j].Similarity })
+	return matches[:min(n, len(matches))], nil
+}
+
+// Size คืนจำนวน embedding ในดัชนีขององค์กร
+func (x *FaceIndex) Size(organizationID string) int {
+	index := x.org(organizationID, false)
+	if index == nil {
+		return 0
+	}
+	index.mu.RLock()
+	defer index.mu.RUnlock()
+	return len(index.ids)
+}
+
+// Sync โหลด embedding ที่บันทึกตั้งแต่การ sync ครั้งก่อน (ครั้งแรกโหลดทั้งหมด) เข้าดัชนี
+func (x *FaceIndex) Sync(ctx context.Context) error {
+	x.syncMu.Lock()
+	defer x.syncMu.Unlock()
+
+	started := time.Now()
+	since := time.Time{}
+	if !x.syncedAt.IsZero() {
+		since = x.syncedAt.Add(-faceIndexSyncOverlap)
+	}
+
+	lastCreatedAt, lastID := since, ""
+	loaded := 0
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		var embeddings []models.FaceEmbedding
+		if err := x.DB.DB.WithContext(ctx).
+			Where("created_at > ? OR (created_at = ? AND face_image_id > ?)", lastCreatedAt, lastCreatedAt, lastID).
+			Order("created_at, face_image_id").
+			Limit(faceIndexLoadBatchSize).
+			Find(&embeddings).Error; err != nil {
+			return fmt.Errorf("ไม่สามารถโหลด embedding ของใบหน้า: %w", err)
+		}
+		if len(embeddings) == 0 {
+			break
+		}
+
+		for _, embedding := range embeddings {
+			x.Add(embedding.OrganizationID, embedding.FaceImageID, embedding.Vector)
+		}
+		loaded += len(embeddings)
+		last := embeddings[len(embeddings)-1]
+		lastCreatedAt, lastID = last.CreatedAt, last.FaceImageID
+	}
+
+	x.syncedAt = started
+	x.mu.Lock()
+	if !x.loaded {
+		log.Printf("โหลดดัชนีใบหน้าสำเร็จ: %d embedding", loaded)
+	}
+	x.loaded = true
+	x.mu.Unlock()
+	return nil
+}
+
+// StartSyncJob โหลด embedding ทั้งหมดเข้าดัชนีใน background แล้ว sync embedding ใหม่ทุก interval จนกว่า ctx ถูกยกเลิก
+func (x *FaceIndex) StartSyncJob(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			if err := x.Sync(ctx); err != nil && ctx.Err() == nil {
+				log.Printf("ไม่สามารถ sync ดัชนีใบหน้า: %v", err)
+			}
+
+			select {
+			case <-ctx.Done():
+				log.Println("การ sync ดัชนีใบหน้าถูกยกเลิก")
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
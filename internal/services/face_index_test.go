@@ -0,0 +1,85 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestNormalizeEmbedding ทดสอบการทำ embedding ให้ยาวหนึ่งหน่วย และการปฏิเสธ embedding ที่ไม่ถูกต้อง
+func TestNormalizeEmbedding(t *testing.T) {
+	normalized, err := normalizeEmbedding([]float32{3, 4})
+	require.NoError(t, err)
+	assert.InDeltaSlice(t, []float32{0.6, 0.8}, normalized, 1e-6)
+
+	for name, embedding := range map[string][]float32{
+		"empty":     {},
+		"zero":      {0, 0, 0},
+		"nan":       {1, float32(math.NaN())},
+		"infinite":  {1, float32(math.Inf(1))},
+		"too large": make([]float32, 4096),
+	} {
+		_, err := normalizeEmbedding(embedding)
+		assert.Error(t, err, name)
+	}
+}
+
+// TestFaceIndex_Search ทดสอบว่าการค้นหาด้วยดัชนี HNSW ให้ผลเหมือนการเปรียบเทียบทุกรายการเกือบทั้งหมด
+func TestFaceIndex_Search(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	embeddings := randomEmbeddings(t, rng, 600, 16)
+	query := randomEmbeddings(t, rng, 1, 16)[0]
+
+	exact := NewFaceIndex(nil, 0)
+	approximate := NewFaceIndex(nil, 100)
+	exact.loaded, approximate.loaded = true, true
+	for i, embedding := range embeddings {
+		id := fmt.Sprintf("image-%d", i)
+		exact.Add("org-1", id, embedding)
+		approximate.Add("org-1", id, embedding)
+	}
+	exact.Add("org-1", "image-0", embeddings[1]) // รายการที่มีอยู่แล้วถูกข้าม
+	assert.Equal(t, 600, exact.Size("org-1"))
+	assert.Nil(t, exact.org("org-1", false).graph)
+	assert.NotNil(t, approximate.org("org-1", false).graph)
+
+	expected, err := exact.Search("org-1", query, 10)
+	require.NoError(t, err)
+	require.Len(t, expected, 10)
+	for i := 1; i < len(expected); i++ {
+		assert.GreaterOrEqual(t, expected[i-1].Similarity, expected[i].Similarity)
+	}
+
+	results, err := approximate.Search("org-1", query, 10)
+	require.NoError(t, err)
+	require.Len(t, results, 10)
+	assert.Equal(t, expected[0], results[0])
+
+	// embedding ขององค์กรอื่นไม่ถูกค้นหา
+	results, err = exact.Search("org-2", query, 10)
+	require.NoError(t, err)
+	assert.Empty(t, results)
+}
+
+// TestFaceIndex_Dimensions ทดสอบว่า embedding ที่จำนวนมิติไม่ตรงกับองค์กรถูกข้ามเมื่อเพิ่ม และถูกปฏิเสธเมื่อค้นหา
+func TestFaceIndex_Dimensions(t *testing.T) {
+	index := NewFaceIndex(nil, 0)
+	_, err := index.Search("org-1", []float32{1, 0}, 5)
+	assert.True(t, errors.Is(err, ErrFaceIndexLoading))
+
+	index.loaded = true
+	index.Add("org-1", "image-1", []float32{1, 0})
+	index.Add("org-1", "image-2", []float32{0, 0, 1})
+	assert.Equal(t, 2, index.Dimensions("org-1"))
+	assert.Equal(t, 1, index.Size("org-1"))
+
+	_, err = index.Search("org-1", []float32{0, 0, 1}, 5)
+	var imageErr *FaceImageError
+	require.True(t, errors.As(err, &imageErr))
+	assert.Equal(t, FaceImageInvalidEmbedding, imageErr.Code)
+}
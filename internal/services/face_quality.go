@@ -0,0 +1,170 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"log"
+	"math"
+
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/models"
+	xdraw "golang.org/x/image/draw"
+	"gorm.io/gorm"
+)
+
+const (
+	// faceSharpnessSize ขนาดของภาพขาวดำที่ใช้วัดความคมชัด รูปทุกขนาดจึงวัดด้วยมาตรฐานเดียวกัน
+	faceSharpnessSize = 128
+	// faceSharpnessMidpoint ความคมชัดที่ได้คะแนนครึ่งหนึ่ง ภาพที่ต่ำกว่านี้มักเบลอจนเห็นได้
+	faceSharpnessMidpoint = 100
+	// faceQualityMinSide ความยาวด้านที่สั้นกว่าของรูป (pixel) ที่ได้คะแนนความละเอียดเต็ม
+	faceQualityMinSide = 160
+	// maxFaceYaw มุมหันซ้ายขวาสูงสุดที่รับได้ (องศา)
+	maxFaceYaw = 90
+)
+
+// FaceImageInvalidQuality รหัสข้อผิดพลาดของข้อมูลคุณภาพที่ส่งมากับรูปภาพไม่ถูกต้อง
+const FaceImageInvalidQuality = "invalid_quality"
+
+// ErrFaceImageNotOwned ถูกส่งคืนเมื่อรูปภาพที่เลือกเป็นรูปหลักไม่ใช่ของบุคคล
+var ErrFaceImageNotOwned = errors.New("พบรูปภาพใบหน้าที่ไม่ใช่ของบุคคลนี้")
+
+// validateFaceQuality ตรวจช่วงของข้อมูลคุณภาพที่ผู้อัปโหลดส่งมา
+func validateFaceQuality(quality models.FaceQuality) error {
+	if quality.FaceConfidence != nil && (math.IsNaN(*quality.FaceConfidence) || *quality.FaceConfidence < 0 || *quality.FaceConfidence > 1) {
+		return &FaceImageError{Code: FaceImageInvalidQuality, Message: "face_confidence ต้องอยู่ระหว่าง 0 ถึง 1"}
+	}
+	if quality.Yaw != nil && (math.IsNaN(*quality.Yaw) || math.Abs(*quality.Yaw) > maxFaceYaw) {
+		return &FaceImageError{Code: FaceImageInvalidQuality, Message: fmt.Sprintf("yaw ต้องอยู่ระหว่าง -%d ถึง %d องศา", maxFaceYaw, maxFaceYaw)}
+	}
+	return nil
+}
+
+// faceSharpness วัดความคมชัดของรูปเป็นความแปรปรวนของ Laplacian ของภาพขาวดำขนาด 128x128
+// รูปที่เบลอมีขอบน้อยจึงได้ค่าต่ำ
+func faceSharpness(img image.Image) float64 {
+	gray := image.NewGray(image.Rect(0, 0, faceSharpnessSize, faceSharpnessSize))
+	xdraw.BiLinear.Scale(gray, gray.Bounds(), img, img.Bounds(), draw.Src, nil)
+
+	var sum, sumSquares float64
+	count := 0
+	for y := 1; y < faceSharpnessSize-1; y++ {
+		for x := 1; x < faceSharpnessSize-1; x++ {
+			laplacian := float64(gray.GrayAt(x-1, y).Y) + float64(gray.GrayAt(x+1, y).Y) +
+				float64(gray.GrayAt(x, y-1).Y) + float64(gray.GrayAt(x, y+1).Y) -
+				4*float64(gray.GrayAt(x, y).Y)
+			sum += laplacian
+			sumSquares += laplacian * laplacian
+			count++
+		}
+	}
+	mean := sum / float64(count)
+	return sumSquares/float64(count) - mean*mean
+}
+
+// faceQualityScore คำนวณคะแนนคุณภาพ 0-1 ของรูปจากความคมชัด ความละเอียด และข้อมูลของผู้อัปโหลด (ถ้ามี):
+// ความมั่นใจของตัวตรวจจับใบหน้า และมุมหันซ้ายขวา ซึ่งรูปหน้าตรงได้คะแนนเต็ม
+func faceQualityScore(faceImage *models.FaceImage) float64 {
+	score := 0.0
+	if faceImage.Sharpness != nil {
+		score = *faceImage.Sharpness / (*faceImage.Sharpness + faceSharpnessMidpoint)
+	}
+	score *= math.Min(1, float64(min(faceImage.Width, faceImage.Height))/faceQualityMinSide)
+	if faceImage.FaceConfidence != nil {
+		score *= *faceImage.FaceConfidence
+	}
+	if faceImage.Yaw != nil {
+		score *= math.Cos(*faceImage.Yaw * math.Pi / 180)
+	}
+	return math.Max(0, score)
+}
+
+// scoreFaceImage วัดความคมชัดของรูปและกำหนดคะแนนคุณภาพ โดยใช้ขนาดของรูปที่กำหนดไว้แล้ว
+func scoreFaceImage(faceImage *models.FaceImage, img image.Image) {
+	sharpness := faceSharpness(img)
+	faceImage.Sharpness = &sharpness
+	faceImage.QualityScore = faceQualityScore(faceImage)
+}
+
+// selectPrimaryFaceImage เลือกรูปภาพหลักของบุคคลใหม่ เป็นรูปที่คะแนนคุณภาพสูงสุด (รูปที่ใหม่กว่าเมื่อเท่ากัน)
+// ไม่เปลี่ยนรูปที่เลือกเอง เว้นแต่รูปนั้นถูกลบหรือย้ายไปเป็นของบุคคลอื่น ไม่เลือกรูปที่ซ้ำแบบ link เพราะใช้ไฟล์ของรูปอื่น
+func selectPrimaryFaceImage(tx *gorm.DB, organizationID, personHash string) error {
+	args := map[string]interface{}{
+		"org_id":      organizationID,
+		"person_hash": personHash,
+	}
+	if err := tx.Exec(`
+		UPDATE persons p SET primary_face_image_pinned = false
+		WHERE p.organization_id = @org_id AND p.person_hash = @person_hash AND p.primary_face_image_pinned
+			AND NOT EXISTS (
+				SELECT 1 FROM face_images f
+				WHERE f.id = p.primary_face_image_id AND f.organization_id = p.organization_id
+					AND f.person_hash = p.person_hash AND f.deleted_at IS NULL
+			)
+	`, args).Error; err != nil {
+		return fmt.Errorf("ไม่สามารถเลือกรูปภาพหลักของบุคคล: %w", err)
+	}
+	if err := tx.Exec(`
+		UPDATE persons p SET primary_face_image_id = (
+			SELECT f.id FROM face_images f
+			WHERE f.organization_id = p.organization_id AND f.person_hash = p.person_hash
+				AND f.duplicate_of_id IS NULL AND f.deleted_at IS NULL
+			ORDER BY f.quality_score DESC, f.created_at DESC, f.id
+			LIMIT 1
+		)
+		WHERE p.organization_id = @org_id AND p.person_hash = @person_hash AND NOT p.primary_face_image_pinned
+	`, args).Error; err != nil {
+		return fmt.Errorf("ไม่สามารถเลือกรูปภาพหลักของบุคคล: %w", err)
+	}
+	return nil
+}
+
+// SetPrimaryFaceImage กำหนดรูปภาพหลักของบุคคลเอง ซึ่งจะไม่ถูกเปลี่ยนตามคะแนนคุณภาพ
+// faceImageID ว่างยกเลิกการกำหนด แล้วกลับไปเลือกรูปที่คุณภาพดีที่สุด
+func (s *PersonService) SetPrimaryFaceImage(ctx context.Context, organizationID, personHash, faceImageID string) (*models.Person, error) {
+	err := s.DB.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		person, err := lockPerson(tx, organizationID, personHash)
+		if err != nil {
+			return err
+		}
+
+		if faceImageID == "" {
+			if err := tx.Model(person).Update("primary_face_image_pinned", false).Error; err != nil {
+				return fmt.Errorf("ไม่สามารถบันทึกรูปภาพหลักของบุคคล: %w", err)
+			}
+			return selectPrimaryFaceImage(tx, organizationID, personHash)
+		}
+
+		var owned int64
+		if err := tx.Model(&models.FaceImage{}).
+			Where("id = ? AND organization_id = ? AND person_hash = ?", faceImageID, organizationID, personHash).
+			Count(&owned).Error; err != nil {
+			return fmt.Errorf("ไม่สามารถดึงข้อมูลรูปภาพ: %w", err)
+		}
+		if owned == 0 {
+			return ErrFaceImageNotOwned
+		}
+		if err := tx.Model(person).Updates(map[string]interface{}{
+			"primary_face_image_id":     faceImageID,
+			"primary_face_image_pinned": true,
+		}).Error; err != nil {
+			return fmt.Errorf("ไม่สามารถบันทึกรูปภาพหลักของบุคคล: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetPerson(ctx, personHash, organizationID)
+}
+
+// refreshPrimaryFaceImage เลือกรูปภาพหลักของบุคคลใหม่หลังรูปภาพของบุคคลเปลี่ยน
+// บันทึก log แต่ไม่ return error เพราะการเปลี่ยนแปลงรูปภาพสำเร็จไปแล้ว
+func (s *FaceService) refreshPrimaryFaceImage(ctx context.Context, organizationID, personHash string) {
+	if err := selectPrimaryFaceImage(s.DB.DB.WithContext(ctx), organizationID, personHash); err != nil {
+		log.Printf("ไม่สามารถเลือกรูปภาพหลักของบุคคล %s: %v", personHash, err)
+	}
+}
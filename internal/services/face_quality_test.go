@@ -0,0 +1,64 @@
+package services
+
+import (
+	"errors"
+	"image"
+	"image/draw"
+	"testing"
+
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	xdraw "golang.org/x/image/draw"
+)
+
+// TestFaceSharpness ทดสอบว่ารูปที่เบลอได้ความคมชัดต่ำกว่ารูปเดิม และรูปสีเดียวไม่มีความคมชัด
+func TestFaceSharpness(t *testing.T) {
+	sharp := gradientImage(400, 400, false)
+
+	// ย่อแล้วขยายกลับทำให้ขอบเบลอ
+	small := image.NewGray(image.Rect(0, 0, 40, 40))
+	xdraw.BiLinear.Scale(small, small.Bounds(), sharp, sharp.Bounds(), draw.Src, nil)
+	blurred := image.NewGray(image.Rect(0, 0, 400, 400))
+	xdraw.BiLinear.Scale(blurred, blurred.Bounds(), small, small.Bounds(), draw.Src, nil)
+
+	assert.Greater(t, faceSharpness(sharp), 2*faceSharpness(blurred))
+	assert.Zero(t, faceSharpness(image.NewGray(image.Rect(0, 0, 200, 200))))
+}
+
+// TestFaceQualityScore ทดสอบว่าความละเอียด ความมั่นใจ และมุมหันซ้ายขวาลดคะแนนคุณภาพ
+func TestFaceQualityScore(t *testing.T) {
+	sharpness := 100.0
+	confidence := 0.5
+	yaw := 60.0
+
+	frontal := &models.FaceImage{Width: 320, Height: 240, Sharpness: &sharpness}
+	assert.InDelta(t, 0.5, faceQualityScore(frontal), 1e-9)
+
+	small := &models.FaceImage{Width: 80, Height: 80, Sharpness: &sharpness}
+	assert.InDelta(t, 0.25, faceQualityScore(small), 1e-9)
+
+	reported := &models.FaceImage{Width: 320, Height: 240, Sharpness: &sharpness, FaceQuality: models.FaceQuality{FaceConfidence: &confidence, Yaw: &yaw}}
+	assert.InDelta(t, 0.125, faceQualityScore(reported), 1e-9)
+
+	assert.Zero(t, faceQualityScore(&models.FaceImage{Width: 320, Height: 240}))
+}
+
+// TestValidateFaceQuality ทดสอบช่วงของข้อมูลคุณภาพที่ผู้อัปโหลดส่งมา
+func TestValidateFaceQuality(t *testing.T) {
+	value := func(v float64) *float64 { return &v }
+
+	assert.NoError(t, validateFaceQuality(models.FaceQuality{}))
+	assert.NoError(t, validateFaceQuality(models.FaceQuality{FaceConfidence: value(1), Yaw: value(-90)}))
+
+	for _, quality := range []models.FaceQuality{
+		{FaceConfidence: value(1.5)},
+		{FaceConfidence: value(-0.1)},
+		{Yaw: value(91)},
+	} {
+		err := validateFaceQuality(quality)
+		var imageErr *FaceImageError
+		require.True(t, errors.As(err, &imageErr))
+		assert.Equal(t, FaceImageInvalidQuality, imageErr.Code)
+	}
+}
@@ -0,0 +1,157 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/models"
+	"gorm.io/gorm"
+)
+
+const (
+	// FaceImageInvalidEmbedding รหัสข้อผิดพลาดของ embedding ที่ส่งมากับรูปภาพหรือใช้ค้นหาไม่ถูกต้อง
+	FaceImageInvalidEmbedding = "invalid_embedding"
+
+	// defaultFaceSearchResults จำนวนบุคคลที่คืนเมื่อไม่ได้ระบุ k
+	defaultFaceSearchResults = 10
+	// maxFaceSearchResults จำนวนบุคคลสูงสุดที่ค้นหาได้ต่อครั้ง
+	maxFaceSearchResults = 100
+	// faceSearchOversample จำนวนรูปที่ค้นหาต่อบุคคลที่ต้องการ เพราะบุคคลหนึ่งมีได้หลายรูปที่คล้ายกัน
+	faceSearchOversample = 10
+)
+
+// prepareFaceEmbedding ตรวจ embedding ที่ส่งมากับรูปภาพ และคืนสำเนาที่ยาวหนึ่งหน่วย (nil ถ้าไม่ได้ส่งมา)
+// embedding ทุกรายการขององค์กรต้องมีจำนวนมิติเท่ากัน เพื่อให้เปรียบเทียบกันได้
+func (s *FaceService) prepareFaceEmbedding(ctx context.Context, organizationID string, embedding []float32) ([]float32, error) {
+	if embedding == nil {
+		return nil, nil
+	}
+	normalized, err := normalizeEmbedding(embedding)
+	if err != nil {
+		return nil, &FaceImageError{Code: FaceImageInvalidEmbedding, Message: err.Error()}
+	}
+
+	var dimensions []int
+	if err := s.DB.DB.WithContext(ctx).Model(&models.FaceEmbedding{}).
+		Where("organization_id = ?", organizationID).
+		Limit(1).
+		Pluck("dimensions", &dimensions).Error; err != nil {
+		return nil, fmt.Errorf("ไม่สามารถตรวจสอบ embedding ขององค์กร: %w", err)
+	}
+	if len(dimensions) > 0 && dimensions[0] != len(normalized) {
+		return nil, &FaceImageError{Code: FaceImageInvalidEmbedding, Message: fmt.Sprintf("embedding ต้องมี %d มิติ เท่ากับ embedding ของรูปภาพในองค์กร", dimensions[0])}
+	}
+	return normalized, nil
+}
+
+// saveFaceImage บันทึกข้อมูลรูปภาพและ embedding (ถ้ามี) ใน transaction เดียวกัน แล้วเพิ่ม embedding เข้าดัชนีใบหน้า
+func (s *FaceService) saveFaceImage(ctx context.Context, faceImage *models.FaceImage, embedding []float32) error {
+	err := s.DB.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(faceImage).Error; err != nil {
+			return err
+		}
+		if embedding == nil {
+			return nil
+		}
+		return tx.Create(&models.FaceEmbedding{
+			FaceImageID:    faceImage.ID,
+			OrganizationID: faceImage.OrganizationID,
+			Dimensions:     len(embedding),
+			Vector:         embedding,
+		}).Error
+	})
+	if err != nil {
+		return fmt.Errorf("ไม่สามารถบันทึกข้อมูลรูปภาพ: %w", err)
+	}
+
+	if embedding != nil {
+		s.Index.Add(faceImage.OrganizationID, faceImage.ID, embedding)
+	}
+	return nil
+}
+
+// SearchFaces ค้นหาบุคคลในองค์กรที่มีรูปภาพใบหน้าซึ่ง embedding คล้าย embedding ที่ระบุที่สุดไม่เกิน k คน
+// ด้วย cosine similarity ของรูปที่คล้ายที่สุดของแต่ละบุคคล ไม่รวมบุคคลที่คล้ายน้อยกว่า minSimilarity
+func (s *FaceService) SearchFaces(ctx context.Context, organizationID string, embedding []float32, k int, minSimilarity float64) ([]models.FaceSearchMatch, error) {
+	if k == 0 {
+		k = defaultFaceSearchResults
+	}
+	if k < 1 || k > maxFaceSearchResults {
+		return nil, fmt.Errorf("k ต้องอยู่ระหว่าง 1 ถึง %d", maxFaceSearchResults)
+	}
+	if s.Index == nil {
+		return nil, ErrFaceIndexLoading
+	}
+	query, err := normalizeEmbedding(embedding)
+	if err != nil {
+		return nil, &FaceImageError{Code: FaceImageInvalidEmbedding, Message: err.Error()}
+	}
+
+	// ค้นหารูปมากกว่าจำนวนบุคคลที่ต้องการ และเพิ่มจำนวนเมื่อรูปที่พบเป็นของบุคคลไม่พอ
+	// เช่น เมื่อบุคคลเดียวมีหลายรูปที่คล้ายกัน หรือรูปที่พบถูกลบไปแล้ว
+	size := s.Index.Size(organizationID)
+	candidates := k * faceSearchOversample
+	for {
+		images, err := s.Index.Search(organizationID, query, candidates)
+		if err != nil {
+			return nil, err
+		}
+		results, err := s.faceSearchMatches(ctx, organizationID, images, k, minSimilarity)
+		if err != nil {
+			return nil, err
+		}
+
+		exhausted := len(images) < candidates || candidates >= size ||
+			(len(images) > 0 && images[len(images)-1].Similarity < minSimilarity)
+		if len(results) >= k || exhausted {
+			return results, nil
+		}
+		candidates *= 4
+	}
+}
+
+// faceSearchMatches แปลงรูปที่พบ (เรียงจากคล้ายที่สุด) เป็นบุคคลไม่เกิน k คน โดยใช้รูปที่คล้ายที่สุดของแต่ละบุคคล
+// รูปที่ถูกลบไปแล้วถูกข้าม
+func (s *FaceService) faceSearchMatches(ctx context.Context, organizationID string, images []faceIndexMatch, k int, minSimilarity float64) ([]models.FaceSearchMatch, error) {
+	results := []models.FaceSearchMatch{}
+	if len(images) == 0 {
+		return results, nil
+	}
+
+	ids := make([]string, len(images))
+	for i, image := range images {
+		ids[i] = image.FaceImageID
+	}
+	var rows []struct {
+		ID         string
+		PersonHash string
+	}
+	if err := s.DB.DB.WithContext(ctx).Model(&models.FaceImage{}).
+		Select("id, person_hash").
+		Where("organization_id = ? AND id IN ?", organizationID, ids).
+		Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("ไม่สามารถดึงข้อมูลรูปภาพ: %w", err)
+	}
+	personHashes := make(map[string]string, len(rows))
+	for _, row := range rows {
+		personHashes[row.ID] = row.PersonHash
+	}
+
+	seen := make(map[string]bool)
+	for _, image := range images {
+		if image.Similarity < minSimilarity || len(results) >= k {
+			break
+		}
+		personHash, ok := personHashes[image.FaceImageID]
+		if !ok || seen[personHash] {
+			continue
+		}
+		seen[personHash] = true
+		results = append(results, models.FaceSearchMatch{
+			PersonHash:  personHash,
+			Similarity:  image.Similarity,
+			FaceImageID: image.FaceImageID,
+		})
+	}
+	return results, nil
+}
@@ -0,0 +1,292 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// รหัสข้อผิดพลาดของรูปภาพใบหน้าที่ถูกปฏิเสธ ส่งให้ client ใน code
+const (
+	FaceImageUnsupportedFormat  = "unsupported_format"
+	FaceImageDecodeFailed       = "decode_failed"
+	FaceImageFileTooLarge       = "file_too_large"
+	FaceImageDimensionsTooSmall = "dimensions_too_small"
+	FaceImageDimensionsTooLarge = "dimensions_too_large"
+)
+
+// faceImageQuality คุณภาพ JPEG ของรูปภาพที่เข้ารหัสใหม่
+const faceImageQuality = 92
+
+// FaceImageError เป็นข้อผิดพลาดของรูปภาพใบหน้าที่ไม่ผ่านการตรวจสอบ พร้อมรหัสข้อผิดพลาด
+type FaceImageError struct {
+	Code    string
+	Message string
+}
+
+func (e *FaceImageError) Error() string {
+	return e.Message
+}
+
+// sanitizedFaceImage เป็นรูปภาพใบหน้าที่ผ่านการตรวจสอบและเข้ารหัสใหม่แล้ว
+type sanitizedFaceImage struct {
+	Data        []byte
+	ContentType string
+	Extension   string
+	Image       image.Image
+}
+
+// sanitizeFaceImage ตรวจชนิดไฟล์จากเนื้อหา ถอดรหัส และตรวจขนาดรูปภาพตามการตั้งค่าขององค์กร
+// แล้วเข้ารหัสใหม่เพื่อลบ metadata (เช่น EXIF และ GPS) โดย PNG ยังเป็น PNG ส่วน JPEG และ WebP เป็น JPEG
+func sanitizeFaceImage(data []byte, settings *models.FaceUploadSettings) (*sanitizedFaceImage, error) {
+	if int64(len(data)) > settings.MaxFileSize {
+		return nil, &FaceImageError{Code: FaceImageFileTooLarge, Message: fmt.Sprintf("ขนาดไฟล์ต้องไม่เกิน %d bytes", settings.MaxFileSize)}
+	}
+
+	// ใช้ชนิดไฟล์จากเนื้อหา ไม่ใช้ชื่อไฟล์หรือ Content-Type ที่ client ส่งมา
+	contentType := http.DetectContentType(data)
+	switch contentType {
+	case "image/jpeg", "image/png", "image/webp":
+	default:
+		return nil, &FaceImageError{Code: FaceImageUnsupportedFormat, Message: "ไฟล์ต้องเป็นรูปภาพ JPEG, PNG หรือ WebP"}
+	}
+
+	img, err := decodeFaceImage(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	// EXIF ถูกลบตอนเข้ารหัสใหม่ จึงต้องหมุนรูปตาม orientation ก่อน
+	if contentType == "image/jpeg" {
+		img = applyOrientation(img, jpegOrientation(data))
+	}
+
+	width, height := img.Bounds().Dx(), img.Bounds().Dy()
+	if width < settings.MinWidth || height < settings.MinHeight {
+		return nil, &FaceImageError{Code: FaceImageDimensionsTooSmall, Message: fmt.Sprintf("ขนาดรูปภาพต้องไม่เล็กกว่า %dx%d pixel", settings.MinWidth, settings.MinHeight)}
+	}
+	if width > settings.MaxWidth || height > settings.MaxHeight {
+		return nil, &FaceImageError{Code: FaceImageDimensionsTooLarge, Message: fmt.Sprintf("ขนาดรูปภาพต้องไม่เกิน %dx%d pixel", settings.MaxWidth, settings.MaxHeight)}
+	}
+
+	var buf bytes.Buffer
+	sanitized := &sanitizedFaceImage{Image: img}
+	if contentType == "image/png" {
+		err = png.Encode(&buf, img)
+		sanitized.ContentType, sanitized.Extension = "image/png", ".png"
+	} else {
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: faceImageQuality})
+		sanitized.ContentType, sanitized.Extension = "image/jpeg", ".jpg"
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ไม่สามารถเข้ารหัสรูปภาพ: %w", err)
+	}
+	sanitized.Data = buf.Bytes()
+	return sanitized, nil
+}
+
+// faceImageKey สร้าง object key ของรูปภาพใบหน้าใหม่ โดยใช้นามสกุลไฟล์ตามชนิดที่เข้ารหัสใหม่
+func faceImageKey(organizationID, personHash, extension string) string {
+	return fmt.Sprintf("%s/%s_%s%s", organizationID, personHash, uuid.New().String(), extension)
+}
+
+// jpegOrientation อ่านค่า orientation (1-8) จาก EXIF ของ JPEG โดยคืน 1 ถ้าไม่มีหรืออ่านไม่ได้
+func jpegOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xFF {
+			return 1
+		}
+		marker := data[i+1]
+		switch {
+		case marker == 0xFF:
+			// byte เติมก่อน marker
+			i++
+			continue
+		case marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7):
+			i += 2
+			continue
+		case marker == 0xDA || marker == 0xD9:
+			// EXIF อยู่ก่อนข้อมูลรูปภาพเสมอ
+			return 1
+		}
+		length := int(binary.BigEndian.Uint16(data[i+2:]))
+		if length < 2 || i+2+length > len(data) {
+			return 1
+		}
+		segment := data[i+4 : i+2+length]
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return exifOrientation(segment[6:])
+		}
+		i += 2 + length
+	}
+	return 1
+}
+
+// exifOrientation อ่าน tag orientation (0x0112) จาก IFD แรกของข้อมูล TIFF ใน EXIF
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+	offset := int(order.Uint32(tiff[4:]))
+	if offset < 8 || offset+2 > len(tiff) {
+		return 1
+	}
+	count := int(order.Uint16(tiff[offset:]))
+	for i := 0; i < count; i++ {
+		entry := offset + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			if value := int(order.Uint16(tiff[entry+8:])); value >= 1 && value <= 8 {
+				return value
+			}
+			return 1
+		}
+	}
+	return 1
+}
+
+// applyOrientation หมุนหรือกลับรูปตามค่า orientation ของ EXIF ให้เป็นรูปที่แสดงผลตรง
+func applyOrientation(img image.Image, orientation int) image.Image {
+	if orientation <= 1 || orientation > 8 {
+		return img
+	}
+
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	src := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(src, src.Bounds(), img, bounds.Min, draw.Src)
+
+	dstWidth, dstHeight := width, height
+	if orientation >= 5 {
+		dstWidth, dstHeight = height, width
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dstWidth, dstHeight))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2: // กลับซ้ายขวา
+				dx, dy = width-1-x, y
+			case 3: // หมุน 180 องศา
+				dx, dy = width-1-x, height-1-y
+			case 4: // กลับบนล่าง
+				dx, dy = x, height-1-y
+			case 5: // transpose
+				dx, dy = y, x
+			case 6: // หมุนตามเข็มนาฬิกา 90 องศา
+				dx, dy = height-1-y, x
+			case 7: // transverse
+				dx, dy = height-1-y, width-1-x
+			case 8: // หมุนทวนเข็มนาฬิกา 90 องศา
+				dx, dy = y, width-1-x
+			}
+			copy(dst.Pix[dst.PixOffset(dx, dy):dst.PixOffset(dx, dy)+4], src.Pix[src.PixOffset(x, y):src.PixOffset(x, y)+4])
+		}
+	}
+	return dst
+}
+
+// GetUploadSettings ดึงข้อจำกัดของรูปภาพที่อัปโหลดขององค์กร หรือค่าเริ่มต้นถ้ายังไม่ได้ตั้งค่า
+func (s *FaceService) GetUploadSettings(ctx context.Context, organizationID string) (*models.FaceUploadSettings, error) {
+	var settings models.FaceUploadSettings
+	result := s.DB.DB.WithContext(ctx).Where("organization_id = ?", organizationID).First(&settings)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			defaults := models.DefaultFaceUploadSettings(organizationID)
+			return &defaults, nil
+		}
+		return nil, fmt.Errorf("ไม่สามารถดึงการตั้งค่าการอัปโหลดรูปภาพ: %w", result.Error)
+	}
+
+	return &settings, nil
+}
+
+// UpdateUploadSettings บันทึกข้อจำกัดของรูปภาพที่อัปโหลดขององค์กร
+func (s *FaceService) UpdateUploadSettings(ctx context.Context, settings *models.FaceUploadSettings) error {
+	// ตรวจสอบความถูกต้องของข้อจำกัด
+	if settings.MaxFileSize < 1 || settings.MaxFileSize > models.MaxFaceImageFileSize {
+		return fmt.Errorf("max_file_size ต้องอยู่ระหว่าง 1 ถึง %d", models.MaxFaceImageFileSize)
+	}
+	if settings.MinWidth < 1 || settings.MinHeight < 1 {
+		return fmt.Errorf("min_width และ min_height ต้องมีค่าอย่างน้อย 1")
+	}
+	if settings.MaxWidth < settings.MinWidth || settings.MaxHeight < settings.MinHeight {
+		return fmt.Errorf("max_width และ max_height ต้องไม่น้อยกว่า min_width และ min_height")
+	}
+	if settings.MaxWidth*settings.MaxHeight > maxImagePixels {
+		return fmt.Errorf("max_width x max_height ต้องไม่เกิน %d ล้าน pixel", maxImagePixels/1_000_000)
+	}
+	switch settings.DedupMode {
+	case models.FaceDedupOff, models.FaceDedupSkip, models.FaceDedupLink:
+	default:
+		return fmt.Errorf("dedup_mode ต้องเป็น off, skip หรือ link")
+	}
+	if settings.DedupMaxDistance < 0 || settings.DedupMaxDistance > maxDedupDistance {
+		return fmt.Errorf("dedup_max_distance ต้องอยู่ระหว่าง 0 ถึง %d", maxDedupDistance)
+	}
+	if settings.DedupWindow < 1 || settings.DedupWindow > maxDedupWindow {
+		return fmt.Errorf("dedup_window ต้องอยู่ระหว่าง 1 ถึง %d วินาที", maxDedupWindow)
+	}
+
+	// อัปเดตถ้ามีอยู่แล้ว ไม่เช่นนั้นสร้างใหม่
+	var existing models.FaceUploadSettings
+	result := s.DB.DB.WithContext(ctx).Where("organization_id = ?", settings.OrganizationID).First(&existing)
+	if result.Error != nil && result.Error != gorm.ErrRecordNotFound {
+		return fmt.Errorf("ไม่สามารถตรวจสอบการตั้งค่าการอัปโหลดรูปภาพ: %w", result.Error)
+	}
+
+	if result.Error == gorm.ErrRecordNotFound {
+		settings.ID = uuid.New().String()
+		if err := s.DB.DB.WithContext(ctx).Create(settings).Error; err != nil {
+			return fmt.Errorf("ไม่สามารถบันทึกการตั้งค่าการอัปโหลดรูปภาพ: %w", err)
+		}
+		// GORM ใช้ค่าเริ่มต้นของคอลัมน์แทนค่าศูนย์ตอนสร้าง จึงต้องบันทึก dedup_max_distance = 0 แยก
+		if settings.DedupMaxDistance == 0 {
+			if err := s.DB.DB.WithContext(ctx).Model(settings).Update("dedup_max_distance", 0).Error; err != nil {
+				return fmt.Errorf("ไม่สามารถบันทึกการตั้งค่าการอัปโหลดรูปภาพ: %w", err)
+			}
+		}
+		return nil
+	}
+
+	settings.ID = existing.ID
+	settings.CreatedAt = existing.CreatedAt
+	if err := s.DB.DB.WithContext(ctx).Model(&existing).Updates(map[string]interface{}{
+		"max_file_size":      settings.MaxFileSize,
+		"min_width":          settings.MinWidth,
+		"min_height":         settings.MinHeight,
+		"max_width":          settings.MaxWidth,
+		"max_height":         settings.MaxHeight,
+		"dedup_mode":         settings.DedupMode,
+		"dedup_max_distance": settings.DedupMaxDistance,
+		"dedup_window":       settings.DedupWindow,
+	}).Error; err != nil {
+		return fmt.Errorf("ไม่สามารถบันทึกการตั้งค่าการอัปโหลดรูปภาพ: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,325 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/models"
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/storage"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// uploadIntentGrace เวลาหลัง URL หมดอายุที่ยังยืนยันการอัปโหลดได้ สำหรับไฟล์ที่อัปโหลดเสร็จก่อนหมดอายุเล็กน้อย
+	uploadIntentGrace = 10 * time.Minute
+	// uploadIntentCleanupBatchSize จำนวนคำขออัปโหลดที่หมดอายุที่เก็บกวาดต่อครั้ง
+	uploadIntentCleanupBatchSize = 100
+)
+
+var (
+	// ErrUploadIntentNotFound ถูกส่งคืนเมื่อไม่พบคำขออัปโหลดขององค์กร
+	ErrUploadIntentNotFound = errors.New("ไม่พบคำขออัปโหลด")
+	// ErrUploadIntentClosed ถูกส่งคืนเมื่อคำขออัปโหลดหมดอายุหรือถูกปฏิเสธไปแล้ว
+	ErrUploadIntentClosed = errors.New("คำขออัปโหลดหมดอายุหรือถูกปฏิเสธแล้ว")
+	// ErrUploadNotReceived ถูกส่งคืนเมื่อยืนยันการอัปโหลดก่อนไฟล์ถึง storage
+	ErrUploadNotReceived = errors.New("ยังไม่พบไฟล์ที่อัปโหลด")
+)
+
+// uploadContentTypes ชนิดไฟล์ที่ขออัปโหลดตรงไปยัง storage ได้
+var uploadContentTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/webp": true,
+}
+
+// uploadIntentKey สร้าง object key ชั่วคราวที่ client อัปโหลดไฟล์ไป ก่อนตรวจและย้ายเป็นรูปภาพใบหน้า
+func uploadIntentKey(organizationID, intentID string) string {
+	return fmt.Sprintf("%s/uploads/%s", organizationID, intentID)
+}
+
+// CreateUploadIntent สร้างคำขออัปโหลดรูปภาพตรงไปยัง storage พร้อม URL สำหรับ PUT ไฟล์ขนาด size bytes ชนิด contentType
+// quality คือข้อมูลคุณภาพของรูป และ embedding คือ embedding ของใบหน้า (ไม่บังคับทั้งคู่) ซึ่งบันทึกกับรูปภาพเมื่อยืนยันการอัปโหลด
+func (s *FaceService) CreateUploadIntent(ctx context.Context, organizationID, personHash, cameraID, contentType string, size int64, quality models.FaceQuality, embedding []float32) (*models.FaceUploadIntent, error) {
+	if err := validateFaceQuality(quality); err != nil {
+		return nil, err
+	}
+	embedding, err := s.prepareFaceEmbedding(ctx, organizationID, embedding)
+	if err != nil {
+		return nil, err
+	}
+	if !uploadContentTypes[contentType] {
+		return nil, &FaceImageError{Code: FaceImageUnsupportedFormat, Message: "ไฟล์ต้องเป็นรูปภาพ JPEG, PNG หรือ WebP"}
+	}
+	if size < 1 {
+		return nil, fmt.Errorf("size ต้องมีค่าอย่างน้อย 1")
+	}
+	settings, err := s.GetUploadSettings(ctx, organizationID)
+	if err != nil {
+		return nil, err
+	}
+	if size > settings.MaxFileSize {
+		return nil, &FaceImageError{Code: FaceImageFileTooLarge, Message: fmt.Sprintf("ขนาดไฟล์ต้องไม่เกิน %d bytes", settings.MaxFileSize)}
+	}
+
+	intent := &models.FaceUploadIntent{
+		Base: models.Base{
+			ID: uuid.New().String(),
+		},
+		OrganizationID: organizationID,
+		PersonHash:     personHash,
+		CameraID:       cameraID,
+		ContentType:    contentType,
+		Size:           size,
+		Status:         models.FaceUploadIntentPending,
+		ExpiresAt:      time.Now().Add(s.UploadIntentTTL).Truncate(time.Second),
+		FaceQuality:    quality,
+		Embedding:      embedding,
+	}
+	intent.ObjectKey = uploadIntentKey(organizationID, intent.ID)
+
+	uploadURL, err := s.Storage.SignedUploadURL(intent.ObjectKey, contentType, size, s.UploadIntentTTL)
+	if err != nil {
+		return nil, fmt.Errorf("ไม่สามารถสร้าง URL สำหรับอัปโหลด: %w", err)
+	}
+	if err := s.DB.DB.WithContext(ctx).Create(intent).Error; err != nil {
+		return nil, fmt.Errorf("ไม่สามารถบันทึกคำขออัปโหลด: %w", err)
+	}
+
+	intent.UploadURL = uploadURL
+	intent.UploadMethod = "PUT"
+	intent.UploadHeaders = map[string]string{"Content-Type": contentType}
+	return intent, nil
+}
+
+// CompleteUploadIntent ตรวจไฟล์ที่อัปโหลดตรงไปยัง storage แล้วสร้างรูปภาพใบหน้า
+// การยืนยันซ้ำหลังสำเร็จแล้วคืนรูปภาพเดิม ส่วนไฟล์ที่ไม่ผ่านการตรวจสอบถูกลบและคำขอถูกปฏิเสธ
+// รูปภาพบันทึก ID ของคำขอไว้ ถ้าสร้างรูปภาพแล้วแต่บันทึกคำขอไม่สำเร็จ การยืนยันซ้ำจึงใช้รูปภาพเดิมแทนการสร้างใหม่
+func (s *FaceService) CompleteUploadIntent(ctx context.Context, id, organizationID string) (*models.FaceImage, error) {
+	var faceImage *models.FaceImage
+	var rejectErr error
+	var uploadKey string // ไฟล์ที่อัปโหลดซึ่งลบหลัง commit เพื่อให้ยืนยันซ้ำได้ถ้า commit ล้มเหลว
+	err := s.DB.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// ล็อกคำขอ เพื่อไม่ให้การยืนยันพร้อมกันหรืองานเก็บกวาดสร้างหรือลบซ้ำ
+		var intent models.FaceUploadIntent
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND organization_id = ?", id, organizationID).
+			First(&intent).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return ErrUploadIntentNotFound
+			}
+			return fmt.Errorf("ไม่สามารถดึงคำขออัปโหลด: %w", err)
+		}
+
+		switch {
+		case intent.Status == models.FaceUploadIntentCompleted:
+			existing, err := s.uploadedFaceImage(tx, intent.FaceImageID, organizationID)
+			faceImage = existing
+			return err
+		case intent.Status != models.FaceUploadIntentPending, time.Now().After(intent.ExpiresAt.Add(uploadIntentGrace)):
+			return ErrUploadIntentClosed
+		}
+
+		// รูปภาพที่สร้างจากคำขอนี้ในการยืนยันครั้งก่อนซึ่งบันทึกคำขอไม่สำเร็จ
+		var createdIDs []string
+		if err := tx.Unscoped().Model(&models.FaceImage{}).Where("upload_intent_id = ?", intent.ID).Limit(1).Pluck("id", &createdIDs).Error; err != nil {
+			return fmt.Errorf("ไม่สามารถตรวจสอบรูปภาพของคำขออัปโหลด: %w", err)
+		}
+		if len(createdIDs) > 0 {
+			if err := completeUploadIntent(tx, &intent, createdIDs[0]); err != nil {
+				return err
+			}
+			uploadKey = intent.ObjectKey
+			existing, err := s.uploadedFaceImage(tx, createdIDs[0], organizationID)
+			faceImage = existing
+			return err
+		}
+
+		info, err := s.Storage.StatObject(ctx, intent.ObjectKey)
+		if err != nil {
+			if errors.Is(err, storage.ErrObjectNotFound) {
+				return ErrUploadNotReceived
+			}
+			return fmt.Errorf("ไม่สามารถตรวจสอบไฟล์ที่อัปโหลด: %w", err)
+		}
+
+		created, err := s.createUploadedFaceImage(ctx, &intent, info)
+		if err != nil {
+			var imageErr *FaceImageError
+			if !errors.As(err, &imageErr) {
+				return err
+			}
+			// ไฟล์ไม่ผ่านการตรวจสอบ: ปิดคำขอ แล้วลบไฟล์และคืนข้อผิดพลาดของรูปภาพหลัง commit
+			if updateErr := tx.Model(&intent).Updates(map[string]interface{}{
+				"status": models.FaceUploadIntentRejected,
+				"error":  imageErr.Message,
+			}).Error; updateErr != nil {
+				return fmt.Errorf("ไม่สามารถบันทึกคำขออัปโหลด: %w", updateErr)
+			}
+			rejectErr = imageErr
+			uploadKey = intent.ObjectKey
+			return nil
+		}
+
+		if err := completeUploadIntent(tx, &intent, created.ID); err != nil {
+			return err
+		}
+		uploadKey = intent.ObjectKey
+		faceImage = created
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	if uploadKey != "" {
+		s.deleteUploadObject(ctx, uploadKey)
+	}
+	if rejectErr != nil {
+		return nil, rejectErr
+	}
+	return faceImage, nil
+}
+
+// completeUploadIntent บันทึกว่าคำขออัปโหลดสร้างรูปภาพ faceImageID แล้ว
+func completeUploadIntent(tx *gorm.DB, intent *models.FaceUploadIntent, faceImageID string) error {
+	if err := tx.Model(intent).Updates(map[string]interface{}{
+		"status":        models.FaceUploadIntentCompleted,
+		"completed_at":  time.Now(),
+		"face_image_id": faceImageID,
+	}).Error; err != nil {
+		return fmt.Errorf("ไม่สามารถบันทึกคำขออัปโหลด: %w", err)
+	}
+	return nil
+}
+
+// uploadedFaceImage ดึงรูปภาพที่สร้างจากคำขออัปโหลดพร้อม URL ที่มีลายเซ็น
+func (s *FaceService) uploadedFaceImage(tx *gorm.DB, id, organizationID string) (*models.FaceImage, error) {
+	var faceImage models.FaceImage
+	if err := tx.First(&faceImage, "id = ? AND organization_id = ?", id, organizationID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("ไม่พบรูปภาพ")
+		}
+		return nil, fmt.Errorf("ไม่สามารถดึงข้อมูลรูปภาพ: %w", err)
+	}
+	s.URLs.SignFaceImage(&faceImage)
+	return &faceImage, nil
+}
+
+// createUploadedFaceImage อ่านไฟล์ที่อัปโหลดของคำขอและสร้างรูปภาพใบหน้าตามข้อจำกัดปัจจุบันขององค์กร
+func (s *FaceService) createUploadedFaceImage(ctx context.Context, intent *models.FaceUploadIntent, info *storage.ObjectInfo) (*models.FaceImage, error) {
+	settings, err := s.GetUploadSettings(ctx, intent.OrganizationID)
+	if err != nil {
+		return nil, err
+	}
+	if info.Size > settings.MaxFileSize || info.Size > intent.Size {
+		return nil, &FaceImageError{Code: FaceImageFileTooLarge, Message: fmt.Sprintf("ขนาดไฟล์ต้องไม่เกิน %d bytes", min(settings.MaxFileSize, intent.Size))}
+	}
+
+	reader, _, err := s.Storage.GetObject(ctx, intent.ObjectKey)
+	if err != nil {
+		return nil, fmt.Errorf("ไม่สามารถอ่านไฟล์ที่อัปโหลด: %w", err)
+	}
+	data, err := io.ReadAll(io.LimitReader(reader, settings.MaxFileSize+1))
+	reader.Close()
+	if err != nil {
+		return nil, fmt.Errorf("ไม่สามารถอ่านไฟล์ที่อัปโหลด: %w", err)
+	}
+
+	return s.createFaceImage(ctx, data, settings, intent.PersonHash, intent.CameraID, intent.OrganizationID, nil, &intent.ID, intent.FaceQuality, intent.Embedding)
+}
+
+// ReceiveUpload บันทึกไฟล์ที่ client PUT มายัง URL สำหรับอัปโหลดของ storage ในเครื่อง หลังตรวจลายเซ็นของ URL
+// ลายเซ็นผูกกับ path, Content-Type และขนาดไฟล์ จึงรับได้เฉพาะไฟล์ตามคำขออัปโหลด
+func (s *FaceService) ReceiveUpload(ctx context.Context, organizationID, filePath, contentType string, body []byte, expires, signature string) error {
+	key, err := faceImageFileKey(organizationID, filePath)
+	if err != nil {
+		return err
+	}
+	verifier, ok := s.Storage.(storage.SignatureVerifier)
+	if !ok || verifier.VerifyUploadSignature(key, contentType, int64(len(body)), expires, signature) != nil {
+		return storage.ErrInvalidSignature
+	}
+	if err := s.Storage.PutObject(ctx, key, bytes.NewReader(body), int64(len(body)), contentType); err != nil {
+		return fmt.Errorf("ไม่สามารถบันทึกไฟล์ที่อัปโหลด: %w", err)
+	}
+	return nil
+}
+
+// CleanupUploadIntents ปิดคำขออัปโหลดที่ไม่ถูกยืนยันจนหมดอายุ และลบไฟล์ที่อัปโหลดค้างไว้ คืนจำนวนคำขอที่ปิด
+func (s *FaceService) CleanupUploadIntents(ctx context.Context) (int, error) {
+	cutoff := time.Now().Add(-uploadIntentGrace)
+	total := 0
+	for {
+		// ปิดคำขอก่อนลบไฟล์ การยืนยันที่กำลังทำงานถือ lock ของคำขอไว้ จึงไม่ถูกปิดระหว่างสร้างรูปภาพ
+		var expired []models.FaceUploadIntent
+		err := s.DB.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+				Where("status = ? AND expires_at < ?", models.FaceUploadIntentPending, cutoff).
+				Limit(uploadIntentCleanupBatchSize).
+				Find(&expired).Error; err != nil {
+				return err
+			}
+			if len(expired) == 0 {
+				return nil
+			}
+			ids := make([]string, len(expired))
+			for i := range expired {
+				ids[i] = expired[i].ID
+			}
+			return tx.Model(&models.FaceUploadIntent{}).Where("id IN ?", ids).Update("status", models.FaceUploadIntentExpired).Error
+		})
+		if err != nil {
+			return total, fmt.Errorf("ไม่สามารถปิดคำขออัปโหลดที่หมดอายุ: %w", err)
+		}
+
+		for i := range expired {
+			s.deleteUploadObject(ctx, expired[i].ObjectKey)
+		}
+		total += len(expired)
+		if len(expired) < uploadIntentCleanupBatchSize {
+			return total, nil
+		}
+	}
+}
+
+// StartUploadIntentCleanupJob เริ่มการเก็บกวาดคำขออัปโหลดที่หมดอายุเป็นระยะจนกว่า ctx จะถูกยกเลิก
+func (s *FaceService) StartUploadIntentCleanupJob(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = 10 * time.Minute
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				count, err := s.CleanupUploadIntents(ctx)
+				if err != nil {
+					log.Printf("ไม่สามารถเก็บกวาดคำขออัปโหลด: %v", err)
+				}
+				if count > 0 {
+					log.Printf("เก็บกวาดคำขออัปโหลดที่หมดอายุแล้ว %d รายการ", count)
+				}
+			case <-ctx.Done():
+				log.Println("การเก็บกวาดคำขออัปโหลดถูกยกเลิก")
+				return
+			}
+		}
+	}()
+}
+
+// deleteUploadObject ลบไฟล์ที่อัปโหลดของคำขอ โดยบันทึก log เมื่อลบไม่สำเร็จ
+func (s *FaceService) deleteUploadObject(ctx context.Context, key string) {
+	if err := s.Storage.DeleteObject(ctx, key); err != nil {
+		log.Printf("ไม่สามารถลบไฟล์ที่อัปโหลด %s: %v", key, err)
+	}
+}
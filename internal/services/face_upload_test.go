@@ -0,0 +1,166 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/db"
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/models"
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// jpegWithOrientation สร้าง JPEG ที่มี EXIF ระบุ orientation และข้อความแทนข้อมูล GPS
+func jpegWithOrientation(t *testing.T, img image.Image, orientation uint16) []byte {
+	var encoded bytes.Buffer
+	require.NoError(t, jpeg.Encode(&encoded, img, nil))
+
+	// TIFF แบบ big-endian ที่มี IFD เดียว ซึ่งมี tag orientation
+	tiff := []byte("MM\x00\x2a\x00\x00\x00\x08")
+	tiff = binary.BigEndian.AppendUint16(tiff, 1)
+	tiff = binary.BigEndian.AppendUint16(tiff, 0x0112)
+	tiff = binary.BigEndian.AppendUint16(tiff, 3)
+	tiff = binary.BigEndian.AppendUint32(tiff, 1)
+	tiff = binary.BigEndian.AppendUint16(tiff, orientation)
+	tiff = append(tiff, 0, 0, 0, 0, 0, 0)
+	tiff = append(tiff, "GPS 13.7563 100.5018"...)
+
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+	app1 := []byte{0xFF, 0xE1}
+	app1 = binary.BigEndian.AppendUint16(app1, uint16(len(segment)+2))
+	app1 = append(app1, segment...)
+
+	data := append([]byte{}, encoded.Bytes()[:2]...)
+	data = append(data, app1...)
+	return append(data, encoded.Bytes()[2:]...)
+}
+
+// faceImageErrorCode คืนรหัสข้อผิดพลาดของรูปภาพ หรือค่าว่างถ้าไม่ใช่ FaceImageError
+func faceImageErrorCode(err error) string {
+	var imageErr *FaceImageError
+	if errors.As(err, &imageErr) {
+		return imageErr.Code
+	}
+	return ""
+}
+
+// TestSanitizeFaceImage ทดสอบการลบ EXIF การหมุนตาม orientation และนามสกุลไฟล์ตามชนิดที่เข้ารหัสใหม่
+func TestSanitizeFaceImage(t *testing.T) {
+	settings := models.DefaultFaceUploadSettings("org-1")
+
+	// รูปกว้าง 200 สูง 100 ที่กล้องบันทึกว่าต้องหมุนตามเข็มนาฬิกา 90 องศา
+	data := jpegWithOrientation(t, testImage(200, 100), 6)
+	assert.Equal(t, 6, jpegOrientation(data))
+
+	sanitized, err := sanitizeFaceImage(data, &settings)
+	require.NoError(t, err)
+	assert.Equal(t, "image/jpeg", sanitized.ContentType)
+	assert.Equal(t, ".jpg", sanitized.Extension)
+	assert.NotContains(t, string(sanitized.Data), "Exif")
+	assert.NotContains(t, string(sanitized.Data), "GPS")
+	assert.Equal(t, 1, jpegOrientation(sanitized.Data))
+
+	decoded, err := jpeg.Decode(bytes.NewReader(sanitized.Data))
+	require.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 100, 200), decoded.Bounds())
+
+	// ครึ่งซ้ายสีแดงของรูปเดิมอยู่ด้านบนหลังหมุน
+	r, _, b, _ := decoded.At(50, 20).RGBA()
+	assert.Greater(t, r, b)
+	r, _, b, _ = decoded.At(50, 180).RGBA()
+	assert.Greater(t, b, r)
+
+	// PNG ยังเป็น PNG
+	var pngData bytes.Buffer
+	require.NoError(t, png.Encode(&pngData, testImage(100, 80)))
+	sanitized, err = sanitizeFaceImage(pngData.Bytes(), &settings)
+	require.NoError(t, err)
+	assert.Equal(t, "image/png", sanitized.ContentType)
+	assert.Equal(t, ".png", sanitized.Extension)
+}
+
+// TestSanitizeFaceImage_Rejected ทดสอบรหัสข้อผิดพลาดของไฟล์ที่ถูกปฏิเสธ
+func TestSanitizeFaceImage_Rejected(t *testing.T) {
+	settings := models.DefaultFaceUploadSettings("org-1")
+
+	var jpegData bytes.Buffer
+	require.NoError(t, jpeg.Encode(&jpegData, testImage(100, 100), nil))
+
+	var small bytes.Buffer
+	require.NoError(t, jpeg.Encode(&small, testImage(32, 100), nil))
+
+	var large bytes.Buffer
+	require.NoError(t, png.Encode(&large, testImage(5000, 70)))
+
+	tests := []struct {
+		name string
+		data []byte
+		code string
+	}{
+		{"not an image", []byte("<html><body>not an image</body></html>"), FaceImageUnsupportedFormat},
+		{"gif", []byte("GIF89a\x01\x00\x01\x00\x00\x00\x00"), FaceImageUnsupportedFormat},
+		{"truncated", jpegData.Bytes()[:len(jpegData.Bytes())/2], FaceImageDecodeFailed},
+		{"too small", small.Bytes(), FaceImageDimensionsTooSmall},
+		{"too large", large.Bytes(), FaceImageDimensionsTooLarge},
+	}
+	for _, tt := range tests {
+		_, err := sanitizeFaceImage(tt.data, &settings)
+		assert.Equal(t, tt.code, faceImageErrorCode(err), tt.name)
+	}
+
+	settings.MaxFileSize = 100
+	_, err := sanitizeFaceImage(jpegData.Bytes(), &settings)
+	assert.Equal(t, FaceImageFileTooLarge, faceImageErrorCode(err))
+}
+
+// TestCompleteUploadIntent_ExistingImage ทดสอบว่าการยืนยันซ้ำหลังสร้างรูปภาพแล้วแต่บันทึกคำขอไม่สำเร็จใช้รูปภาพเดิม ไม่สร้างรูปภาพใหม่
+func TestCompleteUploadIntent_ExistingImage(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { sqlDB.Close() })
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{Logger: logger.Discard})
+	require.NoError(t, err)
+
+	store, err := storage.NewLocalStorageService(t.TempDir(), "http://localhost:8080/api/files", []byte("test-signing-key"))
+	require.NoError(t, err)
+	content := []byte("uploaded image")
+	require.NoError(t, store.PutObject(context.Background(), "org-1/uploads/intent-1", bytes.NewReader(content), int64(len(content)), "image/jpeg"))
+	service := &FaceService{DB: &db.PostgresDB{DB: gormDB}, Storage: store}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "face_upload_intents" WHERE \(id = \$1 AND organization_id = \$2\) .* FOR UPDATE`).
+		WithArgs("intent-1", "org-1", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "organization_id", "status", "object_key", "expires_at"}).
+			AddRow("intent-1", "org-1", models.FaceUploadIntentPending, "org-1/uploads/intent-1", time.Now().Add(time.Hour)))
+	mock.ExpectQuery(`SELECT "id" FROM "face_images" WHERE upload_intent_id = \$1 LIMIT \$2`).
+		WithArgs("intent-1", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("face-1"))
+	mock.ExpectExec(`UPDATE "face_upload_intents" SET "completed_at"=\$1,"face_image_id"=\$2,"status"=\$3`).
+		WithArgs(sqlmock.AnyArg(), "face-1", models.FaceUploadIntentCompleted, sqlmock.AnyArg(), "intent-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`SELECT \* FROM "face_images" WHERE \(id = \$1 AND organization_id = \$2\)`).
+		WithArgs("face-1", "org-1", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "organization_id", "person_hash"}).AddRow("face-1", "org-1", "hash-1"))
+	mock.ExpectCommit()
+
+	faceImage, err := service.CompleteUploadIntent(context.Background(), "intent-1", "org-1")
+	require.NoError(t, err)
+	assert.Equal(t, "face-1", faceImage.ID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	// ไฟล์ที่อัปโหลดถูกลบหลัง commit
+	_, err = store.StatObject(context.Background(), "org-1/uploads/intent-1")
+	assert.Error(t, err)
+}
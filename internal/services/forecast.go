@@ -0,0 +1,416 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/db"
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ForecastService ให้บริการพยากรณ์ปริมาณคนรายชั่วโมงระยะสั้น
+type ForecastService struct {
+	DB           *db.PostgresDB
+	HistoryWeeks int
+	HorizonDays  int
+	HolidayAware bool
+}
+
+// NewForecastService สร้าง ForecastService ใหม่
+func NewForecastService(postgres *db.PostgresDB, historyWeeks, horizonDays int, holidayAware bool) *ForecastService {
+	if historyWeeks < 2 {
+		historyWeeks = 2
+	}
+	if horizonDays <= 0 {
+		horizonDays = 7
+	}
+	return &ForecastService{
+		DB:           postgres,
+		HistoryWeeks: historyWeeks,
+		HorizonDays:  horizonDays,
+		HolidayAware: holidayAware,
+	}
+}
+
+// scopeCondition คืนเงื่อนไข SQL สำหรับกรอง person_logs ตามขอบเขตของการพยากรณ์
+func scopeCondition(scopeType string) (string, error) {
+	switch scopeType {
+	case models.ForecastScopeOrganization:
+		return "TRUE", nil
+	case models.ForecastScopeSite:
+		return "l.camera_id IN (SELECT id FROM cameras WHERE site_id = @scope_id AND organization_id = @org_id)", nil
+	case models.ForecastScopeCamera:
+		return "l.camera_id = @scope_id", nil
+	default:
+		return "", fmt.Errorf("scope ต้องเป็น organization, site หรือ camera")
+	}
+}
+
+// loadHourlyCounts ดึงจำนวนคนรายชั่วโมงของขอบเขต (ไม่นับพนักงาน) โดยชั่วโมงที่ไม่มีคนจะมีค่าเป็นศูนย์
+func (s *ForecastService) loadHourlyCounts(ctx context.Context, organizationID, scopeType, scopeID string, from, to time.Time) ([]HourlyPoint, error) {
+	condition, err := scopeCondition(scopeType)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []struct {
+		Hour  time.Time
+		Count int
+	}
+	if err := s.DB.DB.WithContext(ctx).Raw(`
+		WITH hours AS (
+			SELECT generate_series(@from::timestamp, @to::timestamp - INTERVAL '1 hour', INTERVAL '1 hour') AS hour
+		),
+		counts AS (
+			SELECT date_trunc('hour', l.timestamp) AS hour, COUNT(*) AS count
+			FROM person_logs l
+			WHERE l.organization_id = @org_id
+				AND l.deleted_at IS NULL
+				AND l.timestamp >= @from AND l.timestamp < @to
+				AND `+condition+`
+				AND `+staffCondition("l.person_hash", "l.organization_id")+`
+			GROUP BY 1
+		)
+		SELECT hours.hour, COALESCE(counts.count, 0) AS count
+		FROM hours
+		LEFT JOIN counts ON counts.hour = hours.hour
+		ORDER BY hours.hour
+	`, map[string]interface{}{
+		"org_id":   organizationID,
+		"scope_id": scopeID,
+		"from":     from,
+		"to":       to,
+	}).Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("ไม่สามารถดึงจำนวนคนรายชั่วโมง: %w", err)
+	}
+
+	points := make([]HourlyPoint, len(rows))
+	for i, row := range rows {
+		points[i] = HourlyPoint{Time: localHour(row.Hour), Count: float64(row.Count)}
+	}
+
+	return points, nil
+}
+
+// localHour แปลงชั่วโมงที่อ่านจากคอลัมน์ timestamp (ไม่มี timezone จึงได้เวลาแบบ UTC) เป็นเวลาเดียวกันตามเวลาท้องถิ่นของเซิร์ฟเวอร์
+// ซึ่งเป็นเวลาที่ logs ถูกบันทึก เพื่อให้วันในสัปดาห์ ชั่วโมง และวันหยุดของแต่ละจุดตรงกับเวลาที่ใช้พยากรณ์
+func localHour(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, time.Local)
+}
+
+// loadHolidays ดึงวันหยุดขององค์กรในรูปแบบ map สำหรับแบบจำลอง
+func (s *ForecastService) loadHolidays(ctx context.Context, organizationID string) (map[string]bool, error) {
+	holidays := make(map[string]bool)
+	if !s.HolidayAware {
+		return holidays, nil
+	}
+
+	var records []models.Holiday
+	if err := s.DB.DB.WithContext(ctx).Where("organization_id = ?", organizationID).Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("ไม่สามารถดึงรายการวันหยุด: %w", err)
+	}
+	for _, holiday := range records {
+		holidays[holidayKey(holiday.Date)] = true
+	}
+
+	return holidays, nil
+}
+
+// RefreshScope สร้างแบบจำลองใหม่จากข้อมูลย้อนหลัง บันทึกผลพยากรณ์ล่วงหน้าและความแม่นยำจากการทดสอบย้อนหลัง
+func (s *ForecastService) RefreshScope(ctx context.Context, organizationID, scopeType, scopeID string) error {
+	// ชั่วโมงและวันของแบบจำลองเป็นเวลาท้องถิ่นของเซิร์ฟเวอร์ เหมือนเวลาใน logs และสถิติอื่น
+	now := time.Now().Truncate(time.Hour)
+	historyStart := now.Add(-time.Duration(s.HistoryWeeks) * 7 * 24 * time.Hour)
+
+	points, err := s.loadHourlyCounts(ctx, organizationID, scopeType, scopeID, historyStart, now)
+	if err != nil {
+		return err
+	}
+
+	holidays, err := s.loadHolidays(ctx, organizationID)
+	if err != nil {
+		return err
+	}
+
+	model, err := FitSeasonalModel(points, holidays)
+	if err != nil {
+		return err
+	}
+
+	generatedAt := time.Now()
+	horizon := s.HorizonDays * 24
+	forecasts := make([]models.TrafficForecast, 0, horizon)
+	for i := 0; i < horizon; i++ {
+		hour := now.Add(time.Duration(i) * time.Hour)
+		predicted, lower, upper, isHoliday := model.Predict(hour)
+		forecasts = append(forecasts, models.TrafficForecast{
+			Base: models.Base{
+				ID: uuid.New().String(),
+			},
+			OrganizationID: organizationID,
+			ScopeType:      scopeType,
+			ScopeID:        scopeID,
+			HourStart:      hour,
+			Predicted:      roundTo(predicted, 2),
+			Lower:          roundTo(lower, 2),
+			Upper:          roundTo(upper, 2),
+			IsHoliday:      isHoliday,
+			GeneratedAt:    generatedAt,
+		})
+	}
+
+	// ทดสอบย้อนหลังกับสัปดาห์ล่าสุด ถ้ามีข้อมูลพอ
+	var accuracy *models.ForecastAccuracy
+	if backtest, err := Backtest(points, holidays, hoursPerWeek); err == nil {
+		accuracy = &models.ForecastAccuracy{
+			Base: models.Base{
+				ID: uuid.New().String(),
+			},
+			OrganizationID: organizationID,
+			ScopeType:      scopeType,
+			ScopeID:        scopeID,
+			MAE:            roundTo(backtest.MAE, 2),
+			RMSE:           roundTo(backtest.RMSE, 2),
+			MAPE:           roundTo(backtest.MAPE, 2),
+			Coverage:       roundTo(backtest.Coverage, 4),
+			TrainingHours:  backtest.TrainingHours,
+			TestHours:      backtest.TestHours,
+			GeneratedAt:    generatedAt,
+		}
+	}
+
+	// บันทึกผลทั้งหมดใน transaction เดียว โดยเขียนทับผลพยากรณ์เดิมของชั่วโมงเดียวกัน
+	return s.DB.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "organization_id"}, {Name: "scope_type"}, {Name: "scope_id"}, {Name: "hour_start"}},
+			DoUpdates: clause.AssignmentColumns([]string{
+				"predicted", "lower", "upper", "is_holiday", "generated_at", "updated_at",
+			}),
+		}).CreateInBatches(forecasts, 500).Error; err != nil {
+			return fmt.Errorf("ไม่สามารถบันทึกผลพยากรณ์: %w", err)
+		}
+
+		if accuracy != nil {
+			if err := tx.Clauses(clause.OnConflict{
+				Columns: []clause.Column{{Name: "organization_id"}, {Name: "scope_type"}, {Name: "scope_id"}},
+				DoUpdates: clause.AssignmentColumns([]string{
+					"mae", "rmse", "mape", "coverage", "training_hours", "test_hours", "generated_at", "updated_at",
+				}),
+			}).Create(accuracy).Error; err != nil {
+				return fmt.Errorf("ไม่สามารถบันทึกความแม่นยำของแบบจำลอง: %w", err)
+			}
+		}
+
+		// ลบผลพยากรณ์ที่เก่ากว่า 30 วัน
+		if err := tx.Unscoped().
+			Where("organization_id = ? AND scope_type = ? AND scope_id = ? AND hour_start < ?",
+				organizationID, scopeType, scopeID, now.AddDate(0, 0, -30)).
+			Delete(&models.TrafficForecast{}).Error; err != nil {
+			return fmt.Errorf("ไม่สามารถลบผลพยากรณ์เก่า: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// RefreshOrganization สร้างผลพยากรณ์ใหม่ขององค์กร ทุกสาขา และทุกกล้อง
+func (s *ForecastService) RefreshOrganization(ctx context.Context, organizationID string) error {
+	if err := s.RefreshScope(ctx, organizationID, models.ForecastScopeOrganization, organizationID); err != nil {
+		return fmt.Errorf("องค์กร %s: %w", organizationID, err)
+	}
+
+	var siteIDs []string
+	if err := s.DB.DB.WithContext(ctx).Model(&models.Site{}).
+		Where("organization_id = ?", organizationID).Pluck("id", &siteIDs).Error; err != nil {
+		return fmt.Errorf("ไม่สามารถดึงรายการสาขา: %w", err)
+	}
+	for _, siteID := range siteIDs {
+		if err := s.RefreshScope(ctx, organizationID, models.ForecastScopeSite, siteID); err != nil {
+			log.Printf("ไม่สามารถพยากรณ์ของสาขา %s: %v", siteID, err)
+		}
+	}
+
+	var cameraIDs []string
+	if err := s.DB.DB.WithContext(ctx).Model(&models.Camera{}).
+		Where("organization_id = ?", organizationID).Pluck("id", &cameraIDs).Error; err != nil {
+		return fmt.Errorf("ไม่สามารถดึงรายการกล้อง: %w", err)
+	}
+	for _, cameraID := range cameraIDs {
+		if err := s.RefreshScope(ctx, organizationID, models.ForecastScopeCamera, cameraID); err != nil {
+			log.Printf("ไม่สามารถพยากรณ์ของกล้อง %s: %v", cameraID, err)
+		}
+	}
+
+	return nil
+}
+
+// RefreshAll สร้างผลพยากรณ์ใหม่ของทุกองค์กร
+func (s *ForecastService) RefreshAll(ctx context.Context) error {
+	var organizationIDs []string
+	if err := s.DB.DB.WithContext(ctx).Model(&models.Organization{}).Pluck("id", &organizationIDs).Error; err != nil {
+		return fmt.Errorf("ไม่สามารถดึงรายการองค์กร: %w", err)
+	}
+
+	for _, organizationID := range organizationIDs {
+		if err := s.RefreshOrganization(ctx, organizationID); err != nil {
+			log.Printf("ไม่สามารถพยากรณ์ปริมาณคน: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// StartNightlyJob เริ่มการสร้างผลพยากรณ์ใหม่ทุกวันในชั่วโมงที่กำหนด (เวลาท้องถิ่น)
+func (s *ForecastService) StartNightlyJob(ctx context.Context, hour int) {
+	go func() {
+		for {
+			now := time.Now()
+			next := time.Date(now.Year(), now.Month(), now.Day(), hour, 0, 0, 0, now.Location())
+			if !next.After(now) {
+				next = next.AddDate(0, 0, 1)
+			}
+
+			timer := time.NewTimer(next.Sub(now))
+			select {
+			case <-timer.C:
+				log.Println("เริ่มสร้างผลพยากรณ์ปริมาณคนประจำวัน")
+				if err := s.RefreshAll(ctx); err != nil {
+					log.Printf("ไม่สามารถสร้างผลพยากรณ์ประจำวัน: %v", err)
+				}
+			case <-ctx.Done():
+				timer.Stop()
+				log.Println("การสร้างผลพยากรณ์ประจำวันถูกยกเลิก")
+				return
+			}
+		}
+	}()
+}
+
+// GetForecast ดึงผลพยากรณ์ของขอบเขตในช่วงเวลาที่กำหนด ถ้ายังไม่เคยสร้างจะสร้างให้ทันที
+func (s *ForecastService) GetForecast(ctx context.Context, organizationID, scopeType, scopeID string, from, to time.Time) (*models.ForecastResult, error) {
+	if scopeType == models.ForecastScopeOrganization {
+		scopeID = organizationID
+	}
+	if scopeID == "" {
+		return nil, fmt.Errorf("ต้องระบุ scope_id")
+	}
+
+	// ตรวจสอบว่าขอบเขตเป็นขององค์กรนี้
+	switch scopeType {
+	case models.ForecastScopeOrganization:
+	case models.ForecastScopeSite:
+		if err := s.ensureOwned(ctx, &models.Site{}, scopeID, organizationID); err != nil {
+			return nil, err
+		}
+	case models.ForecastScopeCamera:
+		if err := s.ensureOwned(ctx, &models.Camera{}, scopeID, organizationID); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("scope ต้องเป็น organization, site หรือ camera")
+	}
+
+	query := s.DB.DB.WithContext(ctx).Model(&models.TrafficForecast{}).
+		Where("organization_id = ? AND scope_type = ? AND scope_id = ?", organizationID, scopeType, scopeID)
+
+	// ถ้ายังไม่เคยพยากรณ์ขอบเขตนี้ ให้สร้างทันที
+	var existing int64
+	if err := query.Session(&gorm.Session{}).Count(&existing).Error; err != nil {
+		return nil, fmt.Errorf("ไม่สามารถตรวจสอบผลพยากรณ์: %w", err)
+	}
+	if existing == 0 {
+		if err := s.RefreshScope(ctx, organizationID, scopeType, scopeID); err != nil {
+			return nil, err
+		}
+	}
+
+	var forecasts []models.TrafficForecast
+	if err := query.Where("hour_start >= ? AND hour_start < ?", from, to).
+		Order("hour_start").
+		Find(&forecasts).Error; err != nil {
+		return nil, fmt.Errorf("ไม่สามารถดึงผลพยากรณ์: %w", err)
+	}
+
+	result := &models.ForecastResult{
+		ScopeType:      scopeType,
+		ScopeID:        scopeID,
+		Confidence:     0.95,
+		Forecasts:      forecasts,
+		OrganizationID: organizationID,
+	}
+
+	var accuracy models.ForecastAccuracy
+	err := s.DB.DB.WithContext(ctx).
+		Where("organization_id = ? AND scope_type = ? AND scope_id = ?", organizationID, scopeType, scopeID).
+		First(&accuracy).Error
+	if err == nil {
+		result.Accuracy = &accuracy
+	} else if err != gorm.ErrRecordNotFound {
+		return nil, fmt.Errorf("ไม่สามารถดึงความแม่นยำของแบบจำลอง: %w", err)
+	}
+
+	return result, nil
+}
+
+// ensureOwned ตรวจสอบว่าข้อมูลที่อ้างถึงเป็นขององค์กรที่กำหนด
+func (s *ForecastService) ensureOwned(ctx context.Context, model interface{}, id, organizationID string) error {
+	var count int64
+	if err := s.DB.DB.WithContext(ctx).Model(model).
+		Where("id = ? AND organization_id = ?", id, organizationID).
+		Count(&count).Error; err != nil {
+		return fmt.Errorf("ไม่สามารถตรวจสอบขอบเขตของการพยากรณ์: %w", err)
+	}
+	if count == 0 {
+		return fmt.Errorf("ไม่พบขอบเขตของการพยากรณ์")
+	}
+	return nil
+}
+
+// ListHolidays ดึงรายการวันหยุดขององค์กร
+func (s *ForecastService) ListHolidays(ctx context.Context, organizationID string) ([]models.Holiday, error) {
+	var holidays []models.Holiday
+	if err := s.DB.DB.WithContext(ctx).
+		Where("organization_id = ?", organizationID).
+		Order("date").
+		Find(&holidays).Error; err != nil {
+		return nil, fmt.Errorf("ไม่สามารถดึงรายการวันหยุด: %w", err)
+	}
+	return holidays, nil
+}
+
+// CreateHoliday เพิ่มวันหยุดขององค์กร
+func (s *ForecastService) CreateHoliday(ctx context.Context, holiday *models.Holiday) error {
+	if holiday.ID == "" {
+		holiday.ID = uuid.New().String()
+	}
+	if err := s.DB.DB.WithContext(ctx).Create(holiday).Error; err != nil {
+		return fmt.Errorf("ไม่สามารถเพิ่มวันหยุด: %w", err)
+	}
+	return nil
+}
+
+// DeleteHoliday ลบวันหยุดขององค์กร
+func (s *ForecastService) DeleteHoliday(ctx context.Context, id, organizationID string) error {
+	result := s.DB.DB.WithContext(ctx).Unscoped().
+		Where("id = ? AND organization_id = ?", id, organizationID).
+		Delete(&models.Holiday{})
+	if result.Error != nil {
+		return fmt.Errorf("ไม่สามารถลบวันหยุด: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("ไม่พบวันหยุดที่ต้องการลบ")
+	}
+	return nil
+}
+
+// roundTo ปัดเศษทศนิยมตามจำนวนหลักที่กำหนด
+func roundTo(value float64, digits int) float64 {
+	factor := math.Pow(10, float64(digits))
+	return math.Round(value*factor) / factor
+}
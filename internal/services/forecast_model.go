@@ -0,0 +1,248 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// hoursPerWeek คือจำนวนช่องฤดูกาล (วันในสัปดาห์ × ชั่วโมง)
+const hoursPerWeek = 7 * 24
+
+// forecastZ คือค่า z ของช่วงความเชื่อมั่น 95%
+const forecastZ = 1.96
+
+// HourlyPoint เป็นจำนวนคนในชั่วโมงหนึ่ง
+type HourlyPoint struct {
+	Time  time.Time
+	Count float64
+}
+
+// SeasonalModel เป็นแบบจำลอง trend + ฤดูกาลรายสัปดาห์ (วันในสัปดาห์ × ชั่วโมง)
+// โดยวันหยุดใช้รูปแบบรายชั่วโมงแยกต่างหาก
+type SeasonalModel struct {
+	Origin    time.Time
+	Intercept float64
+	Slope     float64 // การเปลี่ยนแปลงต่อชั่วโมง
+
+	Seasonal    [hoursPerWeek]float64
+	SeasonalStd [hoursPerWeek]float64
+
+	Holidays       map[string]bool
+	HasHoliday     bool
+	HolidayProfile [24]float64
+	HolidayStd     [24]float64
+}
+
+// seasonSlot คืนตำแหน่งช่องฤดูกาลของเวลา ตามวันและชั่วโมงใน timezone ของ t (เวลาท้องถิ่นของเซิร์ฟเวอร์)
+func seasonSlot(t time.Time) int {
+	return int(t.Weekday())*24 + t.Hour()
+}
+
+// holidayKey คืน key ของวันสำหรับเทียบกับรายการวันหยุด ตามวันที่ใน timezone ของ t
+func holidayKey(t time.Time) string {
+	return t.Format("2006-01-02")
+}
+
+// FitSeasonalModel สร้างแบบจำลองจากข้อมูลรายชั่วโมง โดยต้องมีข้อมูลอย่างน้อย 1 สัปดาห์
+func FitSeasonalModel(points []HourlyPoint, holidays map[string]bool) (*SeasonalModel, error) {
+	if len(points) < hoursPerWeek {
+		return nil, fmt.Errorf("ข้อมูลย้อนหลังไม่พอสำหรับสร้างแบบจำลอง (ต้องมีอย่างน้อย %d ชั่วโมง)", hoursPerWeek)
+	}
+
+	m := &SeasonalModel{
+		Origin:   points[0].Time,
+		Holidays: holidays,
+	}
+
+	// แยกข้อมูลวันปกติกับวันหยุด
+	regular := make([]HourlyPoint, 0, len(points))
+	holiday := make([]HourlyPoint, 0)
+	for _, p := range points {
+		if holidays[holidayKey(p.Time)] {
+			holiday = append(holiday, p)
+		} else {
+			regular = append(regular, p)
+		}
+	}
+	if len(regular) < hoursPerWeek {
+		return nil, fmt.Errorf("ข้อมูลวันปกติไม่พอสำหรับสร้างแบบจำลอง")
+	}
+
+	// ประมาณค่า trend และฤดูกาลสลับกัน (backfitting)
+	// รอบแรกใช้ trend เป็นศูนย์ เพื่อได้ค่าฤดูกาลเริ่มต้นจากค่าเฉลี่ยของแต่ละช่อง
+	for iteration := 0; iteration < 3; iteration++ {
+		var sums, counts [hoursPerWeek]float64
+		for _, p := range regular {
+			slot := seasonSlot(p.Time)
+			sums[slot] += p.Count - m.trend(p.Time)
+			counts[slot]++
+		}
+
+		var overall float64
+		var filled int
+		for slot := range sums {
+			if counts[slot] > 0 {
+				m.Seasonal[slot] = sums[slot] / counts[slot]
+				overall += m.Seasonal[slot]
+				filled++
+			}
+		}
+		// ช่องที่ไม่มีข้อมูลใช้ค่าเฉลี่ยรวม
+		if filled > 0 {
+			overall /= float64(filled)
+		}
+		for slot := range counts {
+			if counts[slot] == 0 {
+				m.Seasonal[slot] = overall
+			}
+		}
+
+		// ประมาณ trend เชิงเส้นด้วย least squares จากส่วนที่เหลือหลังหักฤดูกาล
+		var sumX, sumY, sumXY, sumXX float64
+		n := float64(len(regular))
+		for _, p := range regular {
+			x := p.Time.Sub(m.Origin).Hours()
+			y := p.Count - m.Seasonal[seasonSlot(p.Time)]
+			sumX += x
+			sumY += y
+			sumXY += x * y
+			sumXX += x * x
+		}
+		denominator := n*sumXX - sumX*sumX
+		if denominator != 0 {
+			m.Slope = (n*sumXY - sumX*sumY) / denominator
+		}
+		m.Intercept = (sumY - m.Slope*sumX) / n
+	}
+
+	// ความคลาดเคลื่อนต่อช่อง ใช้สร้างช่วงความเชื่อมั่น
+	var sqSums, sqCounts [hoursPerWeek]float64
+	var globalSq float64
+	for _, p := range regular {
+		slot := seasonSlot(p.Time)
+		residual := p.Count - (m.trend(p.Time) + m.Seasonal[slot])
+		sqSums[slot] += residual * residual
+		sqCounts[slot]++
+		globalSq += residual * residual
+	}
+	globalStd := math.Sqrt(globalSq / float64(len(regular)))
+	for slot := range sqSums {
+		// ช่องที่มีข้อมูลน้อยเกินไปใช้ค่าความคลาดเคลื่อนรวมแทน
+		if sqCounts[slot] >= 2 {
+			m.SeasonalStd[slot] = math.Sqrt(sqSums[slot] / sqCounts[slot])
+		} else {
+			m.SeasonalStd[slot] = globalStd
+		}
+	}
+
+	// รูปแบบรายชั่วโมงของวันหยุด
+	if len(holiday) > 0 {
+		var sums, counts, sq [24]float64
+		for _, p := range holiday {
+			sums[p.Time.Hour()] += p.Count - m.trend(p.Time)
+			counts[p.Time.Hour()]++
+		}
+		for hour := range sums {
+			if counts[hour] > 0 {
+				m.HolidayProfile[hour] = sums[hour] / counts[hour]
+			}
+		}
+		for _, p := range holiday {
+			residual := p.Count - (m.trend(p.Time) + m.HolidayProfile[p.Time.Hour()])
+			sq[p.Time.Hour()] += residual * residual
+		}
+		for hour := range sq {
+			if counts[hour] >= 2 {
+				m.HolidayStd[hour] = math.Sqrt(sq[hour] / counts[hour])
+			} else {
+				m.HolidayStd[hour] = globalStd
+			}
+		}
+		m.HasHoliday = true
+	}
+
+	return m, nil
+}
+
+// trend คืนค่าของเส้นแนวโน้ม ณ เวลาที่กำหนด
+func (m *SeasonalModel) trend(t time.Time) float64 {
+	return m.Intercept + m.Slope*t.Sub(m.Origin).Hours()
+}
+
+// Predict พยากรณ์จำนวนคนของชั่วโมงที่กำหนด พร้อมขอบล่างและขอบบนของช่วงความเชื่อมั่น 95%
+func (m *SeasonalModel) Predict(t time.Time) (predicted, lower, upper float64, isHoliday bool) {
+	std := m.SeasonalStd[seasonSlot(t)]
+	predicted = m.trend(t) + m.Seasonal[seasonSlot(t)]
+
+	if m.HasHoliday && m.Holidays[holidayKey(t)] {
+		isHoliday = true
+		predicted = m.trend(t) + m.HolidayProfile[t.Hour()]
+		std = m.HolidayStd[t.Hour()]
+	}
+
+	// ข้อมูลนับจำนวนมีความแปรปรวนอย่างน้อยแบบ Poisson
+	std = math.Max(std, math.Sqrt(math.Max(predicted, 0)))
+
+	predicted = math.Max(predicted, 0)
+	lower = math.Max(predicted-forecastZ*std, 0)
+	upper = predicted + forecastZ*std
+
+	return predicted, lower, upper, isHoliday
+}
+
+// BacktestResult เป็นผลการวัดความแม่นยำของแบบจำลองกับข้อมูลที่กันไว้
+type BacktestResult struct {
+	MAE           float64
+	RMSE          float64
+	MAPE          float64
+	Coverage      float64
+	TrainingHours int
+	TestHours     int
+}
+
+// Backtest สร้างแบบจำลองจากข้อมูลก่อนหน้า แล้ววัดความแม่นยำกับ testHours ชั่วโมงสุดท้าย
+func Backtest(points []HourlyPoint, holidays map[string]bool, testHours int) (*BacktestResult, error) {
+	if testHours <= 0 || len(points) <= testHours {
+		return nil, fmt.Errorf("ข้อมูลไม่พอสำหรับการทดสอบย้อนหลัง")
+	}
+
+	training := points[:len(points)-testHours]
+	test := points[len(points)-testHours:]
+
+	model, err := FitSeasonalModel(training, holidays)
+	if err != nil {
+		return nil, err
+	}
+
+	var absSum, sqSum, pctSum float64
+	var pctCount, covered int
+	for _, p := range test {
+		predicted, lower, upper, _ := model.Predict(p.Time)
+		diff := p.Count - predicted
+		absSum += math.Abs(diff)
+		sqSum += diff * diff
+		// MAPE ไม่นับชั่วโมงที่ไม่มีคน เพราะหารด้วยศูนย์ไม่ได้
+		if p.Count > 0 {
+			pctSum += math.Abs(diff) / p.Count
+			pctCount++
+		}
+		if p.Count >= lower && p.Count <= upper {
+			covered++
+		}
+	}
+
+	n := float64(len(test))
+	result := &BacktestResult{
+		MAE:           absSum / n,
+		RMSE:          math.Sqrt(sqSum / n),
+		Coverage:      float64(covered) / n,
+		TrainingHours: len(training),
+		TestHours:     len(test),
+	}
+	if pctCount > 0 {
+		result.MAPE = pctSum / float64(pctCount) * 100
+	}
+
+	return result, nil
+}
@@ -0,0 +1,118 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// syntheticTraffic สร้างข้อมูลรายชั่วโมงที่มีรูปแบบวันในสัปดาห์ × ชั่วโมง และแนวโน้มเพิ่มขึ้นชั่วโมงละ 0.01 คน
+func syntheticTraffic(start time.Time, hours int, holidays map[string]bool) []HourlyPoint {
+	points := make([]HourlyPoint, hours)
+	for i := 0; i < hours; i++ {
+		t := start.Add(time.Duration(i) * time.Hour)
+		count := 0.0
+		if t.Hour() >= 9 && t.Hour() < 21 {
+			count = 20 + float64(t.Weekday())*5
+		}
+		if holidays[holidayKey(t)] {
+			count = 2
+		}
+		points[i] = HourlyPoint{Time: t, Count: count + 0.01*float64(i)}
+	}
+	return points
+}
+
+// TestFitSeasonalModel ทดสอบว่าแบบจำลองเรียนรู้รูปแบบรายสัปดาห์และแนวโน้มได้
+func TestFitSeasonalModel(t *testing.T) {
+	start := time.Date(2025, 1, 5, 0, 0, 0, 0, time.UTC) // วันอาทิตย์
+	points := syntheticTraffic(start, 4*hoursPerWeek, nil)
+
+	model, err := FitSeasonalModel(points, nil)
+	require.NoError(t, err)
+	assert.InDelta(t, 0.01, model.Slope, 0.001)
+
+	// วันเสาร์ถัดไปเวลา 12:00
+	target := start.Add(time.Duration(4*hoursPerWeek+6*24+12) * time.Hour)
+	predicted, lower, upper, isHoliday := model.Predict(target)
+	expected := 20 + 6*5 + 0.01*float64(4*hoursPerWeek+6*24+12)
+	assert.InDelta(t, expected, predicted, 0.5)
+	assert.LessOrEqual(t, lower, predicted)
+	assert.GreaterOrEqual(t, upper, predicted)
+	assert.False(t, isHoliday)
+
+	// ข้อมูลน้อยกว่า 1 สัปดาห์สร้างแบบจำลองไม่ได้
+	_, err = FitSeasonalModel(points[:hoursPerWeek-1], nil)
+	assert.Error(t, err)
+}
+
+// TestFitSeasonalModelHolidays ทดสอบว่าวันหยุดใช้รูปแบบของวันหยุดแทนรูปแบบวันปกติ
+func TestFitSeasonalModelHolidays(t *testing.T) {
+	start := time.Date(2025, 1, 5, 0, 0, 0, 0, time.UTC)
+	holidays := map[string]bool{"2025-01-15": true, "2025-01-22": true, "2025-02-05": true}
+	points := syntheticTraffic(start, 4*hoursPerWeek, holidays)
+
+	model, err := FitSeasonalModel(points, holidays)
+	require.NoError(t, err)
+
+	predicted, _, _, isHoliday := model.Predict(time.Date(2025, 2, 5, 12, 0, 0, 0, time.UTC))
+	assert.True(t, isHoliday)
+	assert.Less(t, predicted, 15.0)
+
+	predicted, _, _, isHoliday = model.Predict(time.Date(2025, 2, 12, 12, 0, 0, 0, time.UTC))
+	assert.False(t, isHoliday)
+	assert.Greater(t, predicted, 30.0)
+}
+
+// TestBacktest ทดสอบการวัดความแม่นยำกับข้อมูลที่กันไว้
+func TestBacktest(t *testing.T) {
+	start := time.Date(2025, 1, 5, 0, 0, 0, 0, time.UTC)
+	points := syntheticTraffic(start, 5*hoursPerWeek, nil)
+
+	result, err := Backtest(points, nil, hoursPerWeek)
+	require.NoError(t, err)
+	assert.Equal(t, 4*hoursPerWeek, result.TrainingHours)
+	assert.Equal(t, hoursPerWeek, result.TestHours)
+	assert.Less(t, result.MAE, 0.5)
+	assert.Less(t, result.MAPE, 5.0)
+	assert.Greater(t, result.Coverage, 0.9)
+
+	// ข้อมูลฝึกไม่พอหลังกันข้อมูลทดสอบ
+	_, err = Backtest(points[:hoursPerWeek+10], nil, hoursPerWeek)
+	assert.Error(t, err)
+}
+
+// TestForecastLocalTime ทดสอบว่าชั่วโมงจาก logs ถูกแปลงเป็นเวลาท้องถิ่น และวันหยุดกับช่องฤดูกาลใช้วันและชั่วโมงท้องถิ่นเมื่อเซิร์ฟเวอร์ไม่ได้อยู่ใน UTC
+func TestForecastLocalTime(t *testing.T) {
+	bangkok, err := time.LoadLocation("Asia/Bangkok")
+	require.NoError(t, err)
+	local := time.Local
+	time.Local = bangkok
+	t.Cleanup(func() { time.Local = local })
+
+	// คอลัมน์ timestamp อ่านออกมาเป็น UTC แต่เก็บเวลาท้องถิ่น
+	hour := localHour(time.Date(2025, 1, 15, 3, 0, 0, 0, time.UTC))
+	assert.Equal(t, time.Date(2025, 1, 15, 3, 0, 0, 0, bangkok), hour)
+	assert.Equal(t, "2025-01-15", holidayKey(hour))
+	assert.Equal(t, int(time.Wednesday)*24+3, seasonSlot(hour))
+
+	// 03:00 ของวันหยุดในกรุงเทพฯ คือ 20:00 ของวันก่อนใน UTC แต่ยังเป็นวันหยุด
+	start := time.Date(2025, 1, 5, 0, 0, 0, 0, bangkok)
+	holidays := map[string]bool{"2025-01-15": true, "2025-01-22": true, "2025-02-05": true}
+	points := syntheticTraffic(start, 4*hoursPerWeek, holidays)
+
+	model, err := FitSeasonalModel(points, holidays)
+	require.NoError(t, err)
+
+	_, _, _, isHoliday := model.Predict(time.Date(2025, 2, 5, 3, 0, 0, 0, bangkok))
+	assert.True(t, isHoliday)
+	_, _, _, isHoliday = model.Predict(time.Date(2025, 2, 4, 23, 0, 0, 0, bangkok))
+	assert.False(t, isHoliday)
+
+	// ชั่วโมงที่มีคนคือ 9:00-21:00 ตามเวลาท้องถิ่น
+	open, _, _, _ := model.Predict(time.Date(2025, 2, 8, 10, 0, 0, 0, bangkok))
+	closed, _, _, _ := model.Predict(time.Date(2025, 2, 8, 3, 0, 0, 0, bangkok))
+	assert.Greater(t, open, closed+20)
+}
@@ -0,0 +1,205 @@
+package services
+
+import (
+	"container/heap"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+const (
+	// hnswM จำนวนเพื่อนบ้านของแต่ละ node ในชั้นบน (ชั้นล่างสุดมีได้สองเท่า)
+	hnswM = 16
+	// hnswEfConstruction จำนวน candidate ที่พิจารณาเมื่อเพิ่ม node
+	hnswEfConstruction = 200
+	// hnswEfSearch จำนวน candidate ขั้นต่ำที่พิจารณาเมื่อค้นหา
+	hnswEfSearch = 64
+)
+
+// hnswCandidate เป็น node พร้อมระยะห่างจาก vector ที่ค้นหา
+type hnswCandidate struct {
+	node     int32
+	distance float32
+}
+
+// hnswHeap เป็น heap ของ candidate เรียงจากใกล้ไปไกล หรือจากไกลไปใกล้เมื่อ farthest เป็น true
+type hnswHeap struct {
+	items    []hnswCandidate
+	farthest bool
+}
+
+func (h *hnswHeap) Len() int { return len(h.items) }
+func (h *hnswHeap) Less(i, j int) bool {
+	if h.farthest {
+		return h.items[i].distance > h.items[j].distance
+	}
+	return h.items[i].distance < h.items[j].distance
+}
+func (h *hnswHeap) Swap(i, j int)      { h.items[i], h.items[j] = h.items[j], h.items[i] }
+func (h *hnswHeap) Push(x interface{}) { h.items = append(h.items, x.(hnswCandidate)) }
+func (h *hnswHeap) Pop() interface{} {
+	last := h.items[len(h.items)-1]
+	h.items = h.items[:len(h.items)-1]
+	return last
+}
+
+// hnswGraph เป็นดัชนี Hierarchical Navigable Small World สำหรับค้นหา vector ที่ใกล้ที่สุดโดยประมาณ
+// vector ต้องมีความยาวหนึ่งหน่วย ระยะห่างจึงเป็น 1 - cosine similarity
+// node ถูกเพิ่มตามลำดับ หมายเลขของ node จึงเป็นลำดับของ vector ที่เพิ่ม ผู้เรียกต้องล็อกเองเมื่อใช้พร้อมกัน
+type hnswGraph struct {
+	vectors   [][]float32
+	links     [][][]int32 // เพื่อนบ้านของแต่ละ node แยกตามชั้น
+	entry     int32
+	maxLevel  int
+	levelMult float64
+	rng       *rand.Rand
+}
+
+// newHNSWGraph สร้างดัชนีว่าง โดย seed กำหนดการสุ่มชั้นของ node
+func newHNSWGraph(seed int64) *hnswGraph {
+	return &hnswGraph{
+		entry:     -1,
+		levelMult: 1 / math.Log(hnswM),
+		rng:       rand.New(rand.NewSource(seed)),
+	}
+}
+
+// dotProduct คำนวณผลคูณภายในของ vector สองตัวที่ยาวเท่ากัน ซึ่งเป็น cosine similarity เมื่อยาวหนึ่งหน่วย
+func dotProduct(a, b []float32) float32 {
+	var sum float32
+	for i := range a {
+		sum += a[i] * b[i]
+	}
+	return sum
+}
+
+func (g *hnswGraph) distance(query []float32, node int32) float32 {
+	return 1 - dotProduct(query, g.vectors[node])
+}
+
+// maxLinks จำนวนเพื่อนบ้านสูงสุดของ node ในชั้น level
+func maxLinks(level int) int {
+	if level == 0 {
+		return 2 * hnswM
+	}
+	return hnswM
+}
+
+// Insert เพิ่ม vector เป็น node ใหม่ของดัชนี
+func (g *hnswGraph) Insert(vector []float32) {
+	node := int32(len(g.vectors))
+	level := int(-math.Log(1-g.rng.Float64()) * g.levelMult)
+	g.vectors = append(g.vectors, vector)
+	g.links = append(g.links, make([][]int32, level+1))
+
+	if g.entry < 0 {
+		g.entry, g.maxLevel = node, level
+		return
+	}
+
+	// ไล่จากชั้นบนสุดลงมาหาจุดเริ่มต้นที่ใกล้ที่สุดของชั้นที่ node อยู่
+	entry := g.entry
+	for l := g.maxLevel; l > level; l-- {
+		entry = g.searchLayer(vector, []int32{entry}, 1, l)[0].node
+	}
+
+	entries := []int32{entry}
+	for l := min(level, g.maxLevel); l >= 0; l-- {
+		candidates := g.searchLayer(vector, entries, hnswEfConstruction, l)
+		neighbors := g.selectNeighbors(candidates, hnswM)
+		g.links[node][l] = neighbors
+
+		// เชื่อมกลับจากเพื่อนบ้าน และตัดเพื่อนบ้านที่ไกลที่สุดออกเมื่อเกินจำนวนสูงสุด
+		for _, neighbor := range neighbors {
+			links := append(g.links[neighbor][l], node)
+			if len(links) > maxLinks(l) {
+				pruned := make([]hnswCandidate, len(links))
+				for i, link := range links {
+					pruned[i] = hnswCandidate{node: link, distance: g.distance(g.vectors[neighbor], link)}
+				}
+				sort.Slice(pruned, func(i, j int) bool { return pruned[i].distance < pruned[j].distance })
+				links = g.selectNeighbors(pruned, maxLinks(l))
+			}
+			g.links[neighbor][l] = links
+		}
+
+		entries = entries[:0]
+		for _, candidate := range candidates {
+			entries = append(entries, candidate.node)
+		}
+	}
+
+	if level > g.maxLevel {
+		g.entry, g.maxLevel = node, level
+	}
+}
+
+// selectNeighbors เลือก candidate ที่ใกล้ที่สุดไม่เกิน m node (candidates เรียงจากใกล้ไปไกลแล้ว)
+func (g *hnswGraph) selectNeighbors(candidates []hnswCandidate, m int) []int32 {
+	neighbors := make([]int32, 0, min(m, len(candidates)))
+	for _, candidate := range candidates[:min(m, len(candidates))] {
+		neighbors = append(neighbors, candidate.node)
+	}
+	return neighbors
+}
+
+// searchLayer ค้นหา node ที่ใกล้ query ที่สุดไม่เกิน ef node ในชั้น level โดยเริ่มจาก entries
+// ผลลัพธ์เรียงจากใกล้ไปไกล
+func (g *hnswGraph) searchLayer(query []float32, entries []int32, ef, level int) []hnswCandidate {
+	visited := make(map[int32]bool, ef*4)
+	candidates := &hnswHeap{}
+	results := &hnswHeap{farthest: true}
+	for _, entry := range entries {
+		if visited[entry] {
+			continue
+		}
+		visited[entry] = true
+		candidate := hnswCandidate{node: entry, distance: g.distance(query, entry)}
+		heap.Push(candidates, candidate)
+		heap.Push(results, candidate)
+	}
+	for results.Len() > ef {
+		heap.Pop(results)
+	}
+
+	for candidates.Len() > 0 {
+		nearest := heap.Pop(candidates).(hnswCandidate)
+		if results.Len() >= ef && nearest.distance > results.items[0].distance {
+			break
+		}
+		for _, neighbor := range g.links[nearest.node][level] {
+			if visited[neighbor] {
+				continue
+			}
+			visited[neighbor] = true
+			distance := g.distance(query, neighbor)
+			if results.Len() < ef || distance < results.items[0].distance {
+				candidate := hnswCandidate{node: neighbor, distance: distance}
+				heap.Push(candidates, candidate)
+				heap.Push(results, candidate)
+				if results.Len() > ef {
+					heap.Pop(results)
+				}
+			}
+		}
+	}
+
+	nearest := make([]hnswCandidate, results.Len())
+	for i := len(nearest) - 1; i >= 0; i-- {
+		nearest[i] = heap.Pop(results).(hnswCandidate)
+	}
+	return nearest
+}
+
+// Search คืน node ที่ใกล้ query ที่สุดโดยประมาณไม่เกิน k node เรียงจากใกล้ไปไกล
+func (g *hnswGraph) Search(query []float32, k int) []hnswCandidate {
+	if g.entry < 0 || k < 1 {
+		return nil
+	}
+	entry := g.entry
+	for l := g.maxLevel; l > 0; l-- {
+		entry = g.searchLayer(query, []int32{entry}, 1, l)[0].node
+	}
+	nearest := g.searchLayer(query, []int32{entry}, max(k, hnswEfSearch), 0)
+	return nearest[:min(k, len(nearest))]
+}
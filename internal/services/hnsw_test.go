@@ -0,0 +1,80 @@
+package services
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// randomEmbeddings สร้าง embedding ที่ยาวหนึ่งหน่วยแบบสุ่มจาก seed
+func randomEmbeddings(t *testing.T, rng *rand.Rand, n, dimensions int) [][]float32 {
+	t.Helper()
+	embeddings := make([][]float32, n)
+	for i := range embeddings {
+		vector := make([]float32, dimensions)
+		for j := range vector {
+			vector[j] = float32(rng.NormFloat64())
+		}
+		normalized, err := normalizeEmbedding(vector)
+		require.NoError(t, err)
+		embeddings[i] = normalized
+	}
+	return embeddings
+}
+
+// TestHNSWGraph_Recall ทดสอบว่าดัชนี HNSW พบเพื่อนบ้านที่ใกล้ที่สุดเกือบครบเมื่อเทียบกับการเปรียบเทียบทุกรายการ
+func TestHNSWGraph_Recall(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	vectors := randomEmbeddings(t, rng, 2000, 32)
+	queries := randomEmbeddings(t, rng, 50, 32)
+
+	graph := newHNSWGraph(1)
+	for _, vector := range vectors {
+		graph.Insert(vector)
+	}
+
+	const k = 10
+	found, total := 0, 0
+	for _, query := range queries {
+		exact := make([]int32, len(vectors))
+		for i := range exact {
+			exact[i] = int32(i)
+		}
+		sort.Slice(exact, func(i, j int) bool {
+			return dotProduct(query, vectors[exact[i]]) > dotProduct(query, vectors[exact[j]])
+		})
+		expected := make(map[int32]bool, k)
+		for _, node := range exact[:k] {
+			expected[node] = true
+		}
+
+		results := graph.Search(query, k)
+		require.Len(t, results, k)
+		for i, result := range results {
+			if i > 0 {
+				assert.GreaterOrEqual(t, result.distance, results[i-1].distance)
+			}
+			if expected[result.node] {
+				found++
+			}
+		}
+		total += k
+	}
+
+	assert.GreaterOrEqual(t, float64(found)/float64(total), 0.9)
+}
+
+// TestHNSWGraph_Empty ทดสอบการค้นหาในดัชนีว่างและดัชนีที่มี node เดียว
+func TestHNSWGraph_Empty(t *testing.T) {
+	graph := newHNSWGraph(1)
+	assert.Empty(t, graph.Search([]float32{1, 0}, 5))
+
+	graph.Insert([]float32{0, 1})
+	results := graph.Search([]float32{1, 0}, 5)
+	require.Len(t, results, 1)
+	assert.Equal(t, int32(0), results[0].node)
+	assert.InDelta(t, 1, results[0].distance, 1e-6)
+}
@@ -0,0 +1,89 @@
+package services
+
+import (
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/models"
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/storage"
+)
+
+// ImageURLSigner สร้าง URL ของรูปภาพใบหน้าที่มีอายุจำกัดจาก object key เพื่อส่งใน API
+// ฐานข้อมูลเก็บเฉพาะ object key จึงไม่มี URL ถาวรของข้อมูลชีวมิติหลุดออกไป
+type ImageURLSigner struct {
+	Storage storage.StorageService
+	TTL     time.Duration
+}
+
+// NewImageURLSigner สร้าง ImageURLSigner ใหม่ โดยคืน nil ถ้าไม่มี storage (ไม่มี URL ของรูปภาพใน response)
+func NewImageURLSigner(storageService storage.StorageService, ttl time.Duration) *ImageURLSigner {
+	if storageService == nil {
+		return nil
+	}
+	if ttl <= 0 {
+		ttl = 15 * time.Minute
+	}
+	return &ImageURLSigner{
+		Storage: storageService,
+		TTL:     ttl,
+	}
+}
+
+// URL สร้าง URL ของ object key โดยคืนค่าว่างถ้าไม่มี key หรือสร้างไม่สำเร็จ
+func (s *ImageURLSigner) URL(key string) string {
+	if s == nil || key == "" {
+		return ""
+	}
+	signedURL, err := s.Storage.SignedURL(key, s.TTL)
+	if err != nil {
+		log.Printf("ไม่สามารถสร้าง URL ของรูปภาพ %s: %v", key, err)
+		return ""
+	}
+	return signedURL
+}
+
+// SignFaceImage กำหนด URL ของรูปภาพและรูปย่อทุกขนาด พร้อมเวลาที่ URL หมดอายุ
+func (s *ImageURLSigner) SignFaceImage(faceImage *models.FaceImage) {
+	if s == nil {
+		return
+	}
+
+	// URL ทุกรายการใช้ได้อย่างน้อยจนถึงเวลานี้
+	expiresAt := time.Now().Add(s.TTL).Truncate(time.Second)
+	faceImage.URLsExpireAt = &expiresAt
+
+	faceImage.ImageURL = s.URL(faceImage.ImageKey)
+	faceImage.Thumbnails = nil
+	faceImage.ThumbnailURL = ""
+	smallest := 0
+	for size, key := range faceImage.ThumbnailKeys {
+		signedURL := s.URL(key)
+		if signedURL == "" {
+			continue
+		}
+		if faceImage.Thumbnails == nil {
+			faceImage.Thumbnails = make(map[string]string, len(faceImage.ThumbnailKeys))
+		}
+		faceImage.Thumbnails[size] = signedURL
+		if pixels, err := strconv.Atoi(size); err == nil && (smallest == 0 || pixels < smallest) {
+			smallest = pixels
+			faceImage.ThumbnailURL = signedURL
+		}
+	}
+}
+
+// SignFaceImages กำหนด URL ของรายการรูปภาพ
+func (s *ImageURLSigner) SignFaceImages(faceImages []models.FaceImage) {
+	for i := range faceImages {
+		s.SignFaceImage(&faceImages[i])
+	}
+}
+
+// SignWatchlistAlert กำหนด URL ของรูปใบหน้าที่แนบกับการแจ้งเตือน
+func (s *ImageURLSigner) SignWatchlistAlert(watchlistAlert *models.WatchlistAlert) {
+	if s == nil {
+		return
+	}
+	watchlistAlert.FaceImageURL = s.URL(watchlistAlert.FaceImageKey)
+}
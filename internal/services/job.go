@@ -0,0 +1,321 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/db"
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	// jobHeartbeatInterval ระยะเวลาที่งานซึ่งรอหรือกำลังทำงานบันทึก updated_at เพื่อแสดงว่า process ที่ถืองานยังทำงานอยู่
+	jobHeartbeatInterval = time.Minute
+	// jobStaleAfter งานที่รอหรือกำลังทำงานแต่ไม่บันทึก updated_at นานกว่านี้ถือว่า process ที่ถืองานหยุดไปแล้ว (เช่น server เริ่มใหม่)
+	jobStaleAfter = 5 * time.Minute
+)
+
+// JobFunc เป็นงานที่ทำงานเบื้องหลัง โดยรายงานความคืบหน้าผ่าน progress และคืนผลลัพธ์เมื่อเสร็จ
+type JobFunc func(ctx context.Context, job *models.Job, progress func(int64)) (interface{}, error)
+
+// JobService ให้บริการจัดการงานเบื้องหลังที่เริ่มจาก API
+type JobService struct {
+	DB            *db.PostgresDB
+	maxConcurrent int
+
+	mu    sync.Mutex
+	slots map[string]chan struct{} // ช่องทำงานของแต่ละประเภทงาน
+}
+
+// NewJobService สร้าง JobService ใหม่ โดยจำกัดจำนวนงานที่ทำพร้อมกันของแต่ละประเภทงานตาม maxConcurrent
+// งานแต่ละประเภทมีช่องทำงานของตัวเอง งานที่ใช้เวลานาน (เช่น อัปโหลดแบบกลุ่ม) จึงไม่ทำให้งานประเภทอื่น (เช่น ส่งออก logs) ต้องรอ
+func NewJobService(postgres *db.PostgresDB, maxConcurrent int) *JobService {
+	if maxConcurrent <= 0 {
+		maxConcurrent = 2
+	}
+	return &JobService{
+		DB:            postgres,
+		maxConcurrent: maxConcurrent,
+		slots:         make(map[string]chan struct{}),
+	}
+}
+
+// typeSlots คืนช่องทำงานของประเภทงาน โดยสร้างเมื่อใช้ครั้งแรก
+func (s *JobService) typeSlots(jobType string) chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	slots, ok := s.slots[jobType]
+	if !ok {
+		slots = make(chan struct{}, s.maxConcurrent)
+		s.slots[jobType] = slots
+	}
+	return slots
+}
+
+// CreateJob บันทึกงานใหม่ในสถานะรอดำเนินการ
+func (s *JobService) CreateJob(ctx context.Context, organizationID, jobType string, params interface{}) (*models.Job, error) {
+	encoded, err := json.Marshal(params)
+	if err != nil {
+		return nil, fmt.Errorf("ไม่สามารถแปลงพารามิเตอร์ของงาน: %w", err)
+	}
+
+	job := &models.Job{
+		Base: models.Base{
+			ID: uuid.New().String(),
+		},
+		OrganizationID: organizationID,
+		Type:           jobType,
+		Status:         models.JobPending,
+		Params:         encoded,
+	}
+	if err := s.DB.DB.WithContext(ctx).Create(job).Error; err != nil {
+		return nil, fmt.Errorf("ไม่สามารถสร้างงาน: %w", err)
+	}
+
+	return job, nil
+}
+
+// Run เริ่มทำงานเบื้องหลัง และบันทึกสถานะ ความคืบหน้า และผลลัพธ์ของงาน
+func (s *JobService) Run(job *models.Job, fn JobFunc) {
+	go func() {
+		// บันทึก updated_at ตั้งแต่รอช่องทำงาน เพื่อไม่ให้งานที่รออยู่ถูกถือว่าค้าง
+		stop := s.keepAlive(job.ID)
+		defer stop()
+
+		// รอจนมีช่องว่างสำหรับทำงานของประเภทงานนี้
+		slots := s.typeSlots(job.Type)
+		slots <- struct{}{}
+		defer func() { <-slots }()
+
+		s.execute(context.Background(), job, fn)
+	}()
+}
+
+// Execute ทำงานจนเสร็จใน goroutine ปัจจุบัน และบันทึกสถานะ ความคืบหน้า และผลลัพธ์ของงาน
+// ใช้กับงานที่เริ่มจาก CLI ซึ่งต้องรอผล ส่วนงานจาก API ใช้ Run
+func (s *JobService) Execute(ctx context.Context, job *models.Job, fn JobFunc) (interface{}, error) {
+	stop := s.keepAlive(job.ID)
+	defer stop()
+	return s.execute(ctx, job, fn)
+}
+
+// execute ทำงานและบันทึกสถานะ ความคืบหน้า และผลลัพธ์ของงาน
+func (s *JobService) execute(ctx context.Context, job *models.Job, fn JobFunc) (interface{}, error) {
+	// ล้างข้อผิดพลาดและเวลาเสร็จของรอบก่อน กรณีทำงานต่อจาก checkpoint
+	startedAt := time.Now()
+	if err := s.DB.DB.WithContext(ctx).Model(&models.Job{}).Where("id = ?", job.ID).Updates(map[string]interface{}{
+		"status":      models.JobRunning,
+		"started_at":  startedAt,
+		"error":       "",
+		"finished_at": nil,
+	}).Error; err != nil {
+		log.Printf("ไม่สามารถอัปเดตสถานะงาน %s: %v", job.ID, err)
+	}
+	job.Status = models.JobRunning
+	job.StartedAt = &startedAt
+
+	progress := func(done int64) {
+		if err := s.DB.DB.WithContext(ctx).Model(&models.Job{}).Where("id = ?", job.ID).Update("progress", done).Error; err != nil {
+			log.Printf("ไม่สามารถอัปเดตความคืบหน้าของงาน %s: %v", job.ID, err)
+		}
+	}
+
+	result, err := s.runSafely(ctx, job, fn, progress)
+
+	// บันทึกผลด้วย context ใหม่ เพื่อให้บันทึกได้แม้งานถูกยกเลิก
+	finishedAt := time.Now()
+	updates := map[string]interface{}{
+		"finished_at": finishedAt,
+	}
+	if err != nil {
+		log.Printf("งาน %s (%s) ล้มเหลว: %v", job.ID, job.Type, err)
+		updates["status"] = models.JobFailed
+		updates["error"] = err.Error()
+	} else {
+		encoded, encodeErr := json.Marshal(result)
+		if encodeErr != nil {
+			err = encodeErr
+			updates["status"] = models.JobFailed
+			updates["error"] = encodeErr.Error()
+		} else {
+			updates["status"] = models.JobCompleted
+			updates["result"] = encoded
+		}
+	}
+	if updateErr := s.DB.DB.Model(&models.Job{}).Where("id = ?", job.ID).Updates(updates).Error; updateErr != nil {
+		log.Printf("ไม่สามารถบันทึกผลของงาน %s: %v", job.ID, updateErr)
+	}
+	job.Status = updates["status"].(string)
+	job.FinishedAt = &finishedAt
+
+	return result, err
+}
+
+// keepAlive บันทึก updated_at ของงานที่รอหรือกำลังทำงานทุก jobHeartbeatInterval จนกว่าจะเรียก stop
+// เพื่อให้ FailStaleJobs แยกงานที่ยังมี process ถืออยู่ออกจากงานของ process ที่หยุดไปแล้ว
+func (s *JobService) keepAlive(jobID string) (stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(jobHeartbeatInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if err := s.DB.DB.WithContext(ctx).Model(&models.Job{}).
+					Where("id = ? AND status IN ?", jobID, []string{models.JobPending, models.JobRunning}).
+					Update("updated_at", time.Now()).Error; err != nil && ctx.Err() == nil {
+					log.Printf("ไม่สามารถบันทึกสถานะการทำงานของงาน %s: %v", jobID, err)
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// FailStaleJobs ทำเครื่องหมายงานที่รอหรือกำลังทำงานแต่ไม่บันทึก updated_at นานกว่า jobStaleAfter ว่าล้มเหลว
+// งานเหล่านี้เป็นของ process ที่หยุดไปแล้ว (เช่น service เริ่มใหม่ระหว่างทำงาน) จึงไม่มีใครทำต่อ
+// งานที่มี checkpoint เช่น งานสร้างข้อมูลใหม่ สั่งทำต่อได้หลังถูกทำเครื่องหมาย คืนจำนวนงานที่ทำเครื่องหมาย
+func (s *JobService) FailStaleJobs(ctx context.Context) (int64, error) {
+	now := time.Now()
+	result := s.DB.DB.WithContext(ctx).Model(&models.Job{}).
+		Where("status IN ? AND updated_at < ?", []string{models.JobPending, models.JobRunning}, now.Add(-jobStaleAfter)).
+		Updates(map[string]interface{}{
+			"status":      models.JobFailed,
+			"error":       "งานหยุดทำงานเพราะ service ที่ทำงานนี้หยุดหรือเริ่มใหม่ระหว่างทำงาน",
+			"finished_at": now,
+		})
+	if result.Error != nil {
+		return 0, fmt.Errorf("ไม่สามารถทำเครื่องหมายงานที่ค้าง: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+// StartStaleJobCleanupJob ทำเครื่องหมายงานที่ค้างว่าล้มเหลวเมื่อเริ่ม service และทุก jobHeartbeatInterval
+// งานที่ค้างจากการเริ่มใหม่ครั้งนี้ถูกทำเครื่องหมายเมื่อครบ jobStaleAfter หลังบันทึก updated_at ครั้งสุดท้าย
+func (s *JobService) StartStaleJobCleanupJob(ctx context.Context) {
+	cleanup := func() {
+		count, err := s.FailStaleJobs(ctx)
+		if err != nil {
+			log.Printf("ไม่สามารถเก็บกวาดงานที่ค้าง: %v", err)
+		}
+		if count > 0 {
+			log.Printf("ทำเครื่องหมายงานที่ค้างว่าล้มเหลว %d งาน", count)
+		}
+	}
+
+	go func() {
+		cleanup()
+
+		ticker := time.NewTicker(jobHeartbeatInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				cleanup()
+			case <-ctx.Done():
+				log.Println("การเก็บกวาดงานที่ค้างถูกยกเลิก")
+				return
+			}
+		}
+	}()
+}
+
+// SetTotal บันทึกจำนวนงานทั้งหมดเพื่อให้แสดงความคืบหน้าเป็นสัดส่วนได้
+func (s *JobService) SetTotal(ctx context.Context, jobID string, total int64) error {
+	if err := s.DB.DB.WithContext(ctx).Model(&models.Job{}).Where("id = ?", jobID).Update("total", total).Error; err != nil {
+		return fmt.Errorf("ไม่สามารถบันทึกจำนวนงานทั้งหมด: %w", err)
+	}
+	return nil
+}
+
+// SaveCheckpoint บันทึกจุดที่ทำงานต่อได้และความคืบหน้า โดยใช้ tx ของงาน เพื่อให้ checkpoint ตรงกับข้อมูลที่บันทึกแล้วเสมอ
+func (s *JobService) SaveCheckpoint(tx *gorm.DB, jobID string, checkpoint interface{}, progress int64) error {
+	encoded, err := json.Marshal(checkpoint)
+	if err != nil {
+		return fmt.Errorf("ไม่สามารถแปลง checkpoint ของงาน: %w", err)
+	}
+	if err := tx.Model(&models.Job{}).Where("id = ?", jobID).Updates(map[string]interface{}{
+		"checkpoint": encoded,
+		"progress":   progress,
+	}).Error; err != nil {
+		return fmt.Errorf("ไม่สามารถบันทึก checkpoint ของงาน: %w", err)
+	}
+	return nil
+}
+
+// runSafely เรียกงานโดยแปลง panic เป็นข้อผิดพลาด เพื่อไม่ให้งานค้างในสถานะกำลังทำงาน
+func (s *JobService) runSafely(ctx context.Context, job *models.Job, fn JobFunc, progress func(int64)) (result interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("งานหยุดทำงานกะทันหัน: %v", r)
+		}
+	}()
+	return fn(ctx, job, progress)
+}
+
+// GetJob ดึงข้อมูลงานตาม ID
+func (s *JobService) GetJob(ctx context.Context, id, organizationID string) (*models.Job, error) {
+	var job models.Job
+	if err := s.DB.DB.WithContext(ctx).Where("id = ? AND organization_id = ?", id, organizationID).First(&job).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("ไม่พบงาน")
+		}
+		return nil, fmt.Errorf("ไม่สามารถดึงข้อมูลงาน: %w", err)
+	}
+	return &job, nil
+}
+
+// ListJobs ดึงรายการงานขององค์กรตามประเภท
+func (s *JobService) ListJobs(ctx context.Context, organizationID, jobType string, page, pageSize int) ([]models.Job, *models.Pagination, error) {
+	if page <= 0 {
+		page = 1
+	}
+	if pageSize <= 0 {
+		pageSize = 10
+	}
+	offset := (page - 1) * pageSize
+
+	query := s.DB.DB.WithContext(ctx).Model(&models.Job{}).Where("organization_id = ?", organizationID)
+	if jobType != "" {
+		query = query.Where("type = ?", jobType)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, nil, fmt.Errorf("ไม่สามารถนับจำนวนงาน: %w", err)
+	}
+
+	var jobs []models.Job
+	if err := query.Order("created_at DESC").Limit(pageSize).Offset(offset).Find(&jobs).Error; err != nil {
+		return nil, nil, fmt.Errorf("ไม่สามารถดึงรายการงาน: %w", err)
+	}
+
+	totalPage := int(total) / pageSize
+	if int(total)%pageSize > 0 {
+		totalPage++
+	}
+
+	pagination := &models.Pagination{
+		Total:     int(total),
+		Page:      page,
+		PageSize:  pageSize,
+		TotalPage: totalPage,
+	}
+
+	return jobs, pagination, nil
+}
@@ -0,0 +1,60 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/db"
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newMockJobService สร้าง JobService ที่เชื่อมกับ sqlmock
+func newMockJobService(t *testing.T, maxConcurrent int) (*JobService, sqlmock.Sqlmock) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{Logger: logger.Discard})
+	require.NoError(t, err)
+
+	return NewJobService(&db.PostgresDB{DB: gormDB}, maxConcurrent), mock
+}
+
+// TestJobService_TypeSlots ทดสอบว่างานแต่ละประเภทมีช่องทำงานของตัวเอง งานที่ใช้ช่องครบแล้วจึงไม่ทำให้งานประเภทอื่นต้องรอ
+func TestJobService_TypeSlots(t *testing.T) {
+	service, _ := newMockJobService(t, 2)
+
+	batches := service.typeSlots(models.JobTypeFaceBatchUpload)
+	assert.Equal(t, 2, cap(batches))
+	assert.Equal(t, batches, service.typeSlots(models.JobTypeFaceBatchUpload))
+
+	batches <- struct{}{}
+	batches <- struct{}{}
+	select {
+	case service.typeSlots(models.JobTypeLogExport) <- struct{}{}:
+	default:
+		t.Fatal("log export waited for face batch upload slots")
+	}
+}
+
+// TestJobService_FailStaleJobs ทดสอบว่าเฉพาะงานที่รอหรือกำลังทำงานแต่ไม่บันทึก updated_at นานเกินไปถูกทำเครื่องหมายว่าล้มเหลว
+func TestJobService_FailStaleJobs(t *testing.T) {
+	service, mock := newMockJobService(t, 1)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "jobs" SET "error"=\$1,"finished_at"=\$2,"status"=\$3,"updated_at"=\$4 WHERE \(status IN \(\$5,\$6\) AND updated_at < \$7\)`).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), models.JobFailed, sqlmock.AnyArg(), models.JobPending, models.JobRunning, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectCommit()
+
+	count, err := service.FailStaleJobs(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), count)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,277 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/db"
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/models"
+)
+
+// journeyCursorKey ผูก cursor ของ timeline กับการเรียงการเข้าชมจากใหม่ไปเก่า
+const journeyCursorKey = "visits:desc"
+
+// JourneyService ให้บริการ timeline การเข้าชมของบุคคล
+type JourneyService struct {
+	DB       *db.PostgresDB
+	URLs     *ImageURLSigner
+	VisitGap time.Duration
+}
+
+// NewJourneyService สร้าง JourneyService ใหม่ โดยการตรวจจับที่ห่างกันเกิน visitGap นับเป็นการเข้าชมใหม่
+func NewJourneyService(postgres *db.PostgresDB, urls *ImageURLSigner, visitGap time.Duration) *JourneyService {
+	if visitGap <= 0 {
+		visitGap = 30 * time.Minute
+	}
+	return &JourneyService{
+		DB:       postgres,
+		URLs:     urls,
+		VisitGap: visitGap,
+	}
+}
+
+// journeyVisit เป็นช่วงเวลาของการเข้าชมหนึ่งครั้ง
+type journeyVisit struct {
+	StartedAt  time.Time
+	EndedAt    time.Time
+	Detections int
+	FirstLogID string
+}
+
+// journeyDetection เป็นการตรวจจับหนึ่งครั้งพร้อมข้อมูลกล้อง
+type journeyDetection struct {
+	ID         string
+	Timestamp  time.Time
+	CameraID   string
+	CameraName string
+	Zone       string
+}
+
+// buildJourneyStops รวมการตรวจจับที่เรียงตามเวลาเป็นลำดับของกล้อง โดยการตรวจจับต่อเนื่องที่กล้องเดียวกันนับเป็นจุดเดียว
+func buildJourneyStops(detections []journeyDetection) []models.JourneyStop {
+	stops := []models.JourneyStop{}
+	for _, detection := range detections {
+		if n := len(stops); n > 0 && stops[n-1].CameraID == detection.CameraID {
+			stops[n-1].LeftAt = detection.Timestamp
+			stops[n-1].Detections++
+			continue
+		}
+		stops = append(stops, models.JourneyStop{
+			CameraID:   detection.CameraID,
+			CameraName: detection.CameraName,
+			Zone:       detection.Zone,
+			ArrivedAt:  detection.Timestamp,
+			LeftAt:     detection.Timestamp,
+			Detections: 1,
+		})
+	}
+
+	// เวลาที่อยู่ที่กล้องหนึ่งนับจนถึงเวลาที่พบที่กล้องถัดไป ส่วนกล้องสุดท้ายนับถึงการตรวจจับครั้งสุดท้าย
+	for i := range stops {
+		end := stops[i].LeftAt
+		if i+1 < len(stops) {
+			end = stops[i+1].ArrivedAt
+		}
+		stops[i].DwellSeconds = int64(end.Sub(stops[i].ArrivedAt).Seconds())
+	}
+
+	return stops
+}
+
+// groupJourneyDays จัดกลุ่มการเข้าชมที่เรียงจากใหม่ไปเก่าตามวันที่เริ่มเข้าชม
+func groupJourneyDays(visits []models.JourneyVisit) []models.JourneyDay {
+	days := []models.JourneyDay{}
+	for _, visit := range visits {
+		date := visit.StartedAt.Format("2006-01-02")
+		if n := len(days); n > 0 && days[n-1].Date == date {
+			days[n-1].Visits = append(days[n-1].Visits, visit)
+			continue
+		}
+		days = append(days, models.JourneyDay{Date: date, Visits: []models.JourneyVisit{visit}})
+	}
+	return days
+}
+
+// GetJourney ดึง timeline การเข้าชมของบุคคล แบ่งหน้าตามการเข้าชมจากใหม่ไปเก่า
+func (s *JourneyService) GetJourney(ctx context.Context, personHash, organizationID string, from, to time.Time, cursor string, limit int) (*models.PersonJourney, error) {
+	var count int64
+	if err := s.DB.DB.WithContext(ctx).Model(&models.Person{}).
+		Where("person_hash = ? AND organization_id = ?", personHash, organizationID).
+		Count(&count).Error; err != nil {
+		return nil, fmt.Errorf("ไม่สามารถดึงข้อมูลบุคคล: %w", err)
+	}
+	if count == 0 {
+		return nil, fmt.Errorf("ไม่พบข้อมูลบุคคล")
+	}
+
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+	if limit > maxListLimit {
+		limit = maxListLimit
+	}
+
+	params := map[string]interface{}{
+		"organization_id": organizationID,
+		"person_hash":     personHash,
+		"gap":             s.VisitGap.Seconds(),
+		"limit":           limit + 1,
+	}
+
+	rangeCondition := ""
+	if !from.IsZero() {
+		rangeCondition += " AND l.timestamp >= @from"
+		params["from"] = from
+	}
+	if !to.IsZero() {
+		rangeCondition += " AND l.timestamp < @to"
+		params["to"] = to
+	}
+
+	cursorCondition := ""
+	if cursor != "" {
+		value, id, err := decodeCursor(cursor, journeyCursorKey, sortKindTime)
+		if err != nil {
+			return nil, err
+		}
+		cursorCondition = "WHERE (started_at, first_log_id) < (@cursor_time, @cursor_id)"
+		params["cursor_time"] = value
+		params["cursor_id"] = id
+	}
+
+	// แบ่งการตรวจจับเป็นการเข้าชม โดยเริ่มการเข้าชมใหม่เมื่อห่างจากการตรวจจับก่อนหน้าเกินช่วงที่กำหนด
+	var visits []journeyVisit
+	if err := s.DB.DB.WithContext(ctx).Raw(`
+		WITH ordered AS (
+			SELECT l.id, l.timestamp,
+				CASE WHEN l.timestamp - LAG(l.timestamp) OVER (ORDER BY l.timestamp, l.id) <= make_interval(secs => @gap)
+					THEN 0 ELSE 1 END AS new_visit
+			FROM person_logs l
+			WHERE l.deleted_at IS NULL
+				AND l.organization_id = @organization_id
+				AND l.person_hash = @person_hash`+rangeCondition+`
+		), numbered AS (
+			SELECT id, timestamp, SUM(new_visit) OVER (ORDER BY timestamp, id) AS visit_no
+			FROM ordered
+		), visits AS (
+			SELECT
+				MIN(timestamp) AS started_at,
+				MAX(timestamp) AS ended_at,
+				COUNT(*) AS detections,
+				(ARRAY_AGG(id ORDER BY timestamp, id))[1] AS first_log_id
+			FROM numbered
+			GROUP BY visit_no
+		)
+		SELECT started_at, ended_at, detections, first_log_id
+		FROM visits
+		`+cursorCondition+`
+		ORDER BY started_at DESC, first_log_id DESC
+		LIMIT @limit
+	`, params).Scan(&visits).Error; err != nil {
+		return nil, fmt.Errorf("ไม่สามารถดึงการเข้าชมของบุคคล: %w", err)
+	}
+
+	journey := &models.PersonJourney{
+		PersonHash: personHash,
+		Days:       []models.JourneyDay{},
+		Pagination: &models.CursorPagination{Limit: limit},
+	}
+	if len(visits) > limit {
+		visits = visits[:limit]
+		last := visits[len(visits)-1]
+		journey.Pagination.HasMore = true
+		journey.Pagination.NextCursor = encodeCursor(journeyCursorKey, last.StartedAt, last.FirstLogID)
+	}
+	if len(visits) == 0 {
+		return journey, nil
+	}
+
+	// การเข้าชมในหน้านี้ต่อเนื่องกัน จึงดึงการตรวจจับและรูปภาพของทั้งช่วงในครั้งเดียว
+	spanStart := visits[len(visits)-1].StartedAt
+	spanEnd := visits[0].EndedAt
+
+	var detections []journeyDetection
+	if err := s.DB.DB.WithContext(ctx).Raw(`
+		SELECT l.id, l.timestamp, l.camera_id,
+			COALESCE(c.name, '') AS camera_name,
+			COALESCE(c.zone, '') AS zone
+		FROM person_logs l
+		LEFT JOIN cameras c ON c.id = l.camera_id AND c.deleted_at IS NULL
+		WHERE l.deleted_at IS NULL
+			AND l.organization_id = @organization_id
+			AND l.person_hash = @person_hash
+			AND l.timestamp >= @from AND l.timestamp <= @to
+		ORDER BY l.timestamp, l.id
+	`, map[string]interface{}{
+		"organization_id": organizationID,
+		"person_hash":     personHash,
+		"from":            spanStart,
+		"to":              spanEnd,
+	}).Scan(&detections).Error; err != nil {
+		return nil, fmt.Errorf("ไม่สามารถดึงการตรวจจับของบุคคล: %w", err)
+	}
+
+	// รูปภาพจากอัปโหลดแบบกลุ่มหรือคำขออัปโหลดถูกสร้างหลังถ่ายได้นาน จึงใช้เวลาที่ถ่าย (captured_at) ถ้ามี
+	var images []models.FaceImage
+	if err := s.DB.DB.WithContext(ctx).
+		Where("person_hash = ? AND organization_id = ?", personHash, organizationID).
+		Where("COALESCE(captured_at, created_at) >= ? AND COALESCE(captured_at, created_at) <= ?", spanStart, spanEnd.Add(s.VisitGap)).
+		Order("COALESCE(captured_at, created_at)").
+		Find(&images).Error; err != nil {
+		return nil, fmt.Errorf("ไม่สามารถดึงรูปภาพใบหน้าของบุคคล: %w", err)
+	}
+
+	result := make([]models.JourneyVisit, 0, len(visits))
+	for _, visit := range visits {
+		first := sort.Search(len(detections), func(i int) bool {
+			return !detections[i].Timestamp.Before(visit.StartedAt)
+		})
+		last := sort.Search(len(detections), func(i int) bool {
+			return detections[i].Timestamp.After(visit.EndedAt)
+		})
+
+		journeyVisit := models.JourneyVisit{
+			StartedAt:       visit.StartedAt,
+			EndedAt:         visit.EndedAt,
+			DurationSeconds: int64(visit.EndedAt.Sub(visit.StartedAt).Seconds()),
+			Detections:      visit.Detections,
+			Stops:           buildJourneyStops(detections[first:last]),
+		}
+
+		// ใช้รูปภาพแรกที่ถ่ายระหว่างการเข้าชมเป็นรูปตัวแทน
+		if image := visitFaceImage(images, visit.StartedAt, visit.EndedAt.Add(s.VisitGap)); image != nil {
+			s.URLs.SignFaceImage(image)
+			journeyVisit.FaceImageID = image.ID
+			journeyVisit.ThumbnailURL = image.ThumbnailURL
+			if journeyVisit.ThumbnailURL == "" {
+				journeyVisit.ThumbnailURL = image.ImageURL
+			}
+		}
+
+		result = append(result, journeyVisit)
+	}
+	journey.Days = groupJourneyDays(result)
+
+	return journey, nil
+}
+
+// faceImageTime คืนเวลาที่ถ่ายรูปภาพ หรือเวลาที่อัปโหลดถ้าไม่ทราบ ตรงกับ COALESCE(captured_at, created_at)
+func faceImageTime(image *models.FaceImage) time.Time {
+	if image.CapturedAt != nil {
+		return *image.CapturedAt
+	}
+	return image.CreatedAt
+}
+
+// visitFaceImage คืนรูปภาพแรกที่ถ่ายระหว่าง from ถึง to จากรูปภาพที่เรียงตามเวลาที่ถ่ายแล้ว หรือ nil ถ้าไม่มี
+func visitFaceImage(images []models.FaceImage, from, to time.Time) *models.FaceImage {
+	for i := range images {
+		taken := faceImageTime(&images[i])
+		if !taken.Before(from) && !taken.After(to) {
+			return &images[i]
+		}
+	}
+	return nil
+}
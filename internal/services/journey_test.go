@@ -0,0 +1,78 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestBuildJourneyStops ทดสอบการรวมการตรวจจับเป็นลำดับกล้องและเวลาที่อยู่ที่แต่ละกล้อง
+func TestBuildJourneyStops(t *testing.T) {
+	start := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	detections := []journeyDetection{
+		{ID: "1", Timestamp: start, CameraID: "entrance", CameraName: "Entrance", Zone: "front"},
+		{ID: "2", Timestamp: start.Add(2 * time.Minute), CameraID: "entrance", CameraName: "Entrance", Zone: "front"},
+		{ID: "3", Timestamp: start.Add(5 * time.Minute), CameraID: "shelf", CameraName: "Shelf A", Zone: "aisle"},
+		{ID: "4", Timestamp: start.Add(20 * time.Minute), CameraID: "shelf", CameraName: "Shelf A", Zone: "aisle"},
+		{ID: "5", Timestamp: start.Add(25 * time.Minute), CameraID: "entrance", CameraName: "Entrance", Zone: "front"},
+	}
+
+	stops := buildJourneyStops(detections)
+	require.Len(t, stops, 3)
+
+	assert.Equal(t, "entrance", stops[0].CameraID)
+	assert.Equal(t, 2, stops[0].Detections)
+	assert.Equal(t, start.Add(2*time.Minute), stops[0].LeftAt)
+	// อยู่ที่ทางเข้าจนพบที่ชั้นวางในนาทีที่ 5
+	assert.Equal(t, int64(300), stops[0].DwellSeconds)
+
+	assert.Equal(t, "aisle", stops[1].Zone)
+	assert.Equal(t, int64(20*60), stops[1].DwellSeconds)
+
+	// กล้องสุดท้ายพบเพียงครั้งเดียว
+	assert.Equal(t, int64(0), stops[2].DwellSeconds)
+
+	assert.Empty(t, buildJourneyStops(nil))
+}
+
+// TestGroupJourneyDays ทดสอบการจัดกลุ่มการเข้าชมตามวันที่เริ่ม
+func TestGroupJourneyDays(t *testing.T) {
+	day1 := time.Date(2025, 3, 1, 18, 0, 0, 0, time.UTC)
+	day2 := time.Date(2025, 3, 2, 9, 0, 0, 0, time.UTC)
+	visits := []models.JourneyVisit{
+		{StartedAt: day2.Add(3 * time.Hour)},
+		{StartedAt: day2},
+		{StartedAt: day1},
+	}
+
+	days := groupJourneyDays(visits)
+	require.Len(t, days, 2)
+	assert.Equal(t, "2025-03-02", days[0].Date)
+	assert.Len(t, days[0].Visits, 2)
+	assert.Equal(t, "2025-03-01", days[1].Date)
+}
+
+// TestVisitFaceImage ทดสอบว่ารูปตัวแทนของการเข้าชมเลือกตามเวลาที่ถ่าย ไม่ใช่เวลาที่อัปโหลด
+func TestVisitFaceImage(t *testing.T) {
+	start := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	end := start.Add(30 * time.Minute)
+	captured := start.Add(5 * time.Minute)
+	images := []models.FaceImage{
+		// อัปโหลดแบบกลุ่มในวันถัดมา แต่ถ่ายระหว่างการเข้าชม
+		{Base: models.Base{ID: "batch", CreatedAt: start.Add(24 * time.Hour)}, CapturedAt: &captured},
+		{Base: models.Base{ID: "live", CreatedAt: start.Add(10 * time.Minute)}},
+	}
+
+	image := visitFaceImage(images, start, end)
+	require.NotNil(t, image)
+	assert.Equal(t, "batch", image.ID)
+
+	image = visitFaceImage(images[1:], start, end)
+	require.NotNil(t, image)
+	assert.Equal(t, "live", image.ID)
+
+	assert.Nil(t, visitFaceImage(images, end.Add(time.Hour), end.Add(2*time.Hour)))
+}
@@ -0,0 +1,247 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/db"
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// maxNoteLength จำนวนตัวอักษรสูงสุดของบันทึกหนึ่งรายการ
+const maxNoteLength = 5000
+
+// labelPattern รูปแบบของป้ายกำกับหลังแปลงเป็นตัวพิมพ์เล็ก
+var labelPattern = regexp.MustCompile(`^[a-z0-9_-]{1,50}$`)
+
+// normalizeLabel แปลงป้ายกำกับเป็นตัวพิมพ์เล็กและตรวจสอบรูปแบบ
+func normalizeLabel(label string) (string, error) {
+	label = strings.ToLower(strings.TrimSpace(label))
+	if !labelPattern.MatchString(label) {
+		return "", fmt.Errorf("ป้ายกำกับต้องประกอบด้วย a-z, 0-9, _ หรือ - และยาวไม่เกิน 50 ตัวอักษร")
+	}
+	return label, nil
+}
+
+// staffCondition สร้างเงื่อนไข SQL ที่ตัดบุคคลที่มีป้ายกำกับ staff ออก
+// personColumn และ orgColumn ต้องระบุชื่อตารางด้วย (เช่น "l.person_hash") เพื่อไม่ให้ชนกับคอลัมน์ของ person_labels
+func staffCondition(personColumn, orgColumn string) string {
+	return fmt.Sprintf(`NOT EXISTS (
+			SELECT 1 FROM person_labels pl
+			WHERE pl.organization_id = %s AND pl.person_hash = %s
+				AND pl.label = '%s' AND pl.deleted_at IS NULL
+		)`, orgColumn, personColumn, models.LabelStaff)
+}
+
+// excludeStaff เป็น GORM scope ที่ตัดการตรวจจับของพนักงานออกจาก query บนตาราง table เว้นแต่ scope กำหนดให้นับรวม
+func excludeStaff(scope models.StatsScope, table string) func(*gorm.DB) *gorm.DB {
+	return func(query *gorm.DB) *gorm.DB {
+		if scope.IncludeStaff {
+			return query
+		}
+		return query.Where(staffCondition(table+".person_hash", table+".organization_id"))
+	}
+}
+
+// staffClause คืนเงื่อนไข " AND ..." สำหรับต่อท้าย Raw SQL เมื่อ scope ไม่ได้กำหนดให้นับรวมพนักงาน
+func staffClause(scope models.StatsScope, personColumn, orgColumn string) string {
+	if scope.IncludeStaff {
+		return ""
+	}
+	return " AND " + staffCondition(personColumn, orgColumn)
+}
+
+// LabelService ให้บริการป้ายกำกับและบันทึกของบุคคล
+type LabelService struct {
+	DB    *db.PostgresDB
+	Stats *StatsService
+}
+
+// NewLabelService สร้าง LabelService ใหม่ โดยใช้ StatsService ล้าง cache เมื่อป้ายกำกับ staff เปลี่ยน
+func NewLabelService(postgres *db.PostgresDB, statsService *StatsService) *LabelService {
+	return &LabelService{
+		DB:    postgres,
+		Stats: statsService,
+	}
+}
+
+// ensurePerson ตรวจสอบว่าบุคคลมีอยู่ในองค์กร
+func (s *LabelService) ensurePerson(ctx context.Context, personHash, organizationID string) error {
+	var count int64
+	if err := s.DB.DB.WithContext(ctx).Model(&models.Person{}).
+		Where("person_hash = ? AND organization_id = ?", personHash, organizationID).
+		Count(&count).Error; err != nil {
+		return fmt.Errorf("ไม่สามารถดึงข้อมูลบุคคล: %w", err)
+	}
+	if count == 0 {
+		return fmt.Errorf("ไม่พบข้อมูลบุคคล")
+	}
+	return nil
+}
+
+// labelChanged ล้าง cache สถิติขององค์กรเมื่อป้ายกำกับที่มีผลต่อสถิติเปลี่ยน
+func (s *LabelService) labelChanged(ctx context.Context, organizationID, label string) error {
+	if label != models.LabelStaff || s.Stats == nil {
+		return nil
+	}
+	return s.Stats.InvalidateOrganizationCache(ctx, organizationID)
+}
+
+// ListLabels ดึงป้ายกำกับของบุคคล
+func (s *LabelService) ListLabels(ctx context.Context, personHash, organizationID string) ([]models.PersonLabel, error) {
+	if err := s.ensurePerson(ctx, personHash, organizationID); err != nil {
+		return nil, err
+	}
+
+	var labels []models.PersonLabel
+	if err := s.DB.DB.WithContext(ctx).
+		Where("person_hash = ? AND organization_id = ?", personHash, organizationID).
+		Order("label").
+		Find(&labels).Error; err != nil {
+		return nil, fmt.Errorf("ไม่สามารถดึงป้ายกำกับของบุคคล: %w", err)
+	}
+
+	return labels, nil
+}
+
+// AddLabel ติดป้ายกำกับให้บุคคล ถ้ามีป้ายกำกับนี้อยู่แล้วจะคืนรายการเดิม
+func (s *LabelService) AddLabel(ctx context.Context, personHash, organizationID, label, createdBy string) (*models.PersonLabel, error) {
+	label, err := normalizeLabel(label)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.ensurePerson(ctx, personHash, organizationID); err != nil {
+		return nil, err
+	}
+
+	var existing models.PersonLabel
+	result := s.DB.DB.WithContext(ctx).
+		Where("person_hash = ? AND organization_id = ? AND label = ?", personHash, organizationID, label).
+		First(&existing)
+	if result.Error == nil {
+		return &existing, nil
+	}
+	if result.Error != gorm.ErrRecordNotFound {
+		return nil, fmt.Errorf("ไม่สามารถตรวจสอบป้ายกำกับ: %w", result.Error)
+	}
+
+	personLabel := models.PersonLabel{
+		Base: models.Base{
+			ID: uuid.New().String(),
+		},
+		OrganizationID: organizationID,
+		PersonHash:     personHash,
+		Label:          label,
+		CreatedBy:      createdBy,
+	}
+	if err := s.DB.DB.WithContext(ctx).Create(&personLabel).Error; err != nil {
+		return nil, fmt.Errorf("ไม่สามารถบันทึกป้ายกำกับ: %w", err)
+	}
+
+	if err := s.labelChanged(ctx, organizationID, label); err != nil {
+		return nil, err
+	}
+
+	return &personLabel, nil
+}
+
+// RemoveLabel ถอดป้ายกำกับออกจากบุคคล
+func (s *LabelService) RemoveLabel(ctx context.Context, personHash, organizationID, label string) error {
+	label, err := normalizeLabel(label)
+	if err != nil {
+		return err
+	}
+
+	// ลบถาวรเพื่อให้ติดป้ายกำกับเดิมซ้ำได้โดยไม่ชนกับ unique index
+	result := s.DB.DB.WithContext(ctx).Unscoped().
+		Where("person_hash = ? AND organization_id = ? AND label = ?", personHash, organizationID, label).
+		Delete(&models.PersonLabel{})
+	if result.Error != nil {
+		return fmt.Errorf("ไม่สามารถลบป้ายกำกับ: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("ไม่พบป้ายกำกับ")
+	}
+
+	return s.labelChanged(ctx, organizationID, label)
+}
+
+// CountLabels นับจำนวนบุคคลในแต่ละป้ายกำกับขององค์กร
+func (s *LabelService) CountLabels(ctx context.Context, organizationID string) ([]models.LabelCount, error) {
+	var counts []models.LabelCount
+	if err := s.DB.DB.WithContext(ctx).Model(&models.PersonLabel{}).
+		Select("label, COUNT(*) AS count").
+		Where("organization_id = ?", organizationID).
+		Group("label").
+		Order("label").
+		Scan(&counts).Error; err != nil {
+		return nil, fmt.Errorf("ไม่สามารถนับจำนวนบุคคลตามป้ายกำกับ: %w", err)
+	}
+
+	return counts, nil
+}
+
+// ListNotes ดึงบันทึกของบุคคล เรียงจากใหม่ไปเก่า
+func (s *LabelService) ListNotes(ctx context.Context, personHash, organizationID string) ([]models.PersonNote, error) {
+	if err := s.ensurePerson(ctx, personHash, organizationID); err != nil {
+		return nil, err
+	}
+
+	var notes []models.PersonNote
+	if err := s.DB.DB.WithContext(ctx).
+		Where("person_hash = ? AND organization_id = ?", personHash, organizationID).
+		Order("created_at DESC").
+		Find(&notes).Error; err != nil {
+		return nil, fmt.Errorf("ไม่สามารถดึงบันทึกของบุคคล: %w", err)
+	}
+
+	return notes, nil
+}
+
+// AddNote เพิ่มบันทึกให้บุคคล
+func (s *LabelService) AddNote(ctx context.Context, personHash, organizationID, body, author string) (*models.PersonNote, error) {
+	body = strings.TrimSpace(body)
+	if body == "" {
+		return nil, fmt.Errorf("ต้องระบุข้อความของบันทึก")
+	}
+	if len([]rune(body)) > maxNoteLength {
+		return nil, fmt.Errorf("ข้อความของบันทึกยาวเกินไป")
+	}
+	if err := s.ensurePerson(ctx, personHash, organizationID); err != nil {
+		return nil, err
+	}
+
+	note := models.PersonNote{
+		Base: models.Base{
+			ID: uuid.New().String(),
+		},
+		OrganizationID: organizationID,
+		PersonHash:     personHash,
+		Body:           body,
+		Author:         author,
+	}
+	if err := s.DB.DB.WithContext(ctx).Create(&note).Error; err != nil {
+		return nil, fmt.Errorf("ไม่สามารถบันทึกข้อความ: %w", err)
+	}
+
+	return &note, nil
+}
+
+// DeleteNote ลบบันทึกของบุคคล
+func (s *LabelService) DeleteNote(ctx context.Context, personHash, organizationID, noteID string) error {
+	result := s.DB.DB.WithContext(ctx).
+		Where("id = ? AND person_hash = ? AND organization_id = ?", noteID, personHash, organizationID).
+		Delete(&models.PersonNote{})
+	if result.Error != nil {
+		return fmt.Errorf("ไม่สามารถลบบันทึก: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("ไม่พบบันทึก")
+	}
+
+	return nil
+}
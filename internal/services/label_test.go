@@ -0,0 +1,41 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestNormalizeLabel ทดสอบการแปลงและตรวจสอบรูปแบบของป้ายกำกับ
+func TestNormalizeLabel(t *testing.T) {
+	label, err := normalizeLabel("  Staff ")
+	require.NoError(t, err)
+	assert.Equal(t, models.LabelStaff, label)
+
+	label, err = normalizeLabel("night_shift-2")
+	require.NoError(t, err)
+	assert.Equal(t, "night_shift-2", label)
+
+	for _, invalid := range []string{"", "   ", "has space", "staff'; --", "พนักงาน"} {
+		_, err := normalizeLabel(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+// TestStaffClause ทดสอบว่าเงื่อนไขตัดพนักงานออกใช้เฉพาะเมื่อไม่ได้ขอให้นับรวม
+func TestStaffClause(t *testing.T) {
+	clause := staffClause(models.StatsScope{}, "l.person_hash", "l.organization_id")
+	assert.Contains(t, clause, "NOT EXISTS")
+	assert.Contains(t, clause, "pl.person_hash = l.person_hash")
+	assert.Contains(t, clause, "pl.label = 'staff'")
+
+	assert.Empty(t, staffClause(models.StatsScope{IncludeStaff: true}, "l.person_hash", "l.organization_id"))
+}
+
+// TestStatsCacheKey ทดสอบว่าสถิติที่นับรวมพนักงานใช้ cache แยกกัน แต่ล้างด้วยรูปแบบเดียวกันได้
+func TestStatsCacheKey(t *testing.T) {
+	assert.Equal(t, "heatmap:org-1:2025-03-01", statsCacheKey("heatmap", "org-1", "2025-03-01", models.StatsScope{}))
+	assert.Equal(t, "heatmap:org-1:2025-03-01:with_staff", statsCacheKey("heatmap", "org-1", "2025-03-01", models.StatsScope{IncludeStaff: true}))
+}
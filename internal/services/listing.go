@@ -0,0 +1,195 @@
+package services
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/models"
+	"gorm.io/gorm"
+)
+
+// ErrInvalidListOptions เป็นข้อผิดพลาดของ cursor, sort, order หรือ fields ที่ผู้เรียกส่งมาไม่ถูกต้อง
+var ErrInvalidListOptions = errors.New("ตัวเลือกของรายการไม่ถูกต้อง")
+
+// ขนาดหน้าเริ่มต้นและสูงสุดของรายการแบบ cursor
+const (
+	defaultListLimit = 10
+	maxListLimit     = 100
+)
+
+// ชนิดของค่าที่ใช้เรียงลำดับ ใช้แปลงค่าใน cursor กลับเป็นชนิดเดิม
+const (
+	sortKindTime   = "time"
+	sortKindString = "string"
+	sortKindInt    = "int"
+)
+
+// sortField เป็นคอลัมน์ที่ใช้เรียงลำดับรายการได้
+type sortField struct {
+	Column string
+	Kind   string
+}
+
+// listSpec อธิบายการเรียงลำดับ การเลือกฟิลด์ และ cursor ของรายการหนึ่งประเภท
+type listSpec[T any] struct {
+	Sorts        map[string]sortField
+	DefaultSort  string
+	DefaultOrder string
+	// Fields จับคู่ชื่อฟิลด์ใน JSON กับคอลัมน์ที่ต้องดึงเพื่อแสดงฟิลด์นั้น คั่นด้วย , ถ้าต้องใช้หลายคอลัมน์
+	Fields map[string]string
+	// Key คืนค่าของคอลัมน์ที่ใช้เรียงลำดับและ ID ของแถว สำหรับสร้าง cursor ของหน้าถัดไป
+	Key func(item T, sort string) (interface{}, string)
+}
+
+// cursorPayload เป็นข้อมูลที่เข้ารหัสไว้ใน cursor
+type cursorPayload struct {
+	Sort  string `json:"s"`
+	Value string `json:"v"`
+	ID    string `json:"id"`
+}
+
+// encodeCursor สร้าง cursor ที่ชี้ไปยังแถวหลังจากแถวที่ระบุ
+func encodeCursor(sortKey string, value interface{}, id string) string {
+	payload := cursorPayload{Sort: sortKey, ID: id}
+	switch v := value.(type) {
+	case time.Time:
+		payload.Value = v.Format(time.RFC3339Nano)
+	default:
+		payload.Value = fmt.Sprint(v)
+	}
+	encoded, _ := json.Marshal(payload)
+	return base64.RawURLEncoding.EncodeToString(encoded)
+}
+
+// decodeCursor อ่าน cursor และแปลงค่ากลับเป็นชนิดของคอลัมน์ที่ใช้เรียงลำดับ
+func decodeCursor(cursor, sortKey, kind string) (interface{}, string, error) {
+	invalid := fmt.Errorf("%w: cursor ไม่ถูกต้อง", ErrInvalidListOptions)
+
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, "", invalid
+	}
+	var payload cursorPayload
+	if err := json.Unmarshal(raw, &payload); err != nil || payload.ID == "" {
+		return nil, "", invalid
+	}
+	// cursor ใช้ได้กับการเรียงลำดับเดียวกับที่สร้างมาเท่านั้น
+	if payload.Sort != sortKey {
+		return nil, "", fmt.Errorf("%w: cursor ไม่ตรงกับ sort และ order ที่ระบุ", ErrInvalidListOptions)
+	}
+
+	switch kind {
+	case sortKindTime:
+		value, err := time.Parse(time.RFC3339Nano, payload.Value)
+		if err != nil {
+			return nil, "", invalid
+		}
+		return value, payload.ID, nil
+	case sortKindInt:
+		value, err := strconv.ParseInt(payload.Value, 10, 64)
+		if err != nil {
+			return nil, "", invalid
+		}
+		return value, payload.ID, nil
+	}
+	return payload.Value, payload.ID, nil
+}
+
+// selectColumns คืนรายการคอลัมน์ที่ต้องดึงสำหรับฟิลด์ที่ผู้เรียกเลือก
+func (spec listSpec[T]) selectColumns(fields []string, sortColumn string) ([]string, error) {
+	columns := []string{"id", sortColumn}
+	seen := map[string]bool{"id": true, sortColumn: true}
+	for _, field := range fields {
+		fieldColumns, ok := spec.Fields[field]
+		if !ok {
+			return nil, fmt.Errorf("%w: ไม่รู้จักฟิลด์ %s", ErrInvalidListOptions, field)
+		}
+		for _, column := range strings.Split(fieldColumns, ",") {
+			if column != "" && !seen[column] {
+				seen[column] = true
+				columns = append(columns, column)
+			}
+		}
+	}
+	return columns, nil
+}
+
+// paginate ดึงรายการหนึ่งหน้าด้วย keyset pagination บน (คอลัมน์ที่เรียงลำดับ, id) แทน OFFSET
+func paginate[T any](query *gorm.DB, spec listSpec[T], opts models.ListOptions) ([]T, *models.CursorPagination, error) {
+	sortName := opts.Sort
+	if sortName == "" {
+		sortName = spec.DefaultSort
+	}
+	field, ok := spec.Sorts[sortName]
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: ไม่สามารถเรียงลำดับด้วย %s", ErrInvalidListOptions, sortName)
+	}
+
+	order := opts.Order
+	if order == "" {
+		order = spec.DefaultOrder
+	}
+	if order != "asc" && order != "desc" {
+		return nil, nil, fmt.Errorf("%w: order ต้องเป็น asc หรือ desc", ErrInvalidListOptions)
+	}
+
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+	if limit > maxListLimit {
+		limit = maxListLimit
+	}
+
+	pagination := &models.CursorPagination{Limit: limit}
+
+	// นับจำนวนทั้งหมดเฉพาะเมื่อผู้เรียกต้องการ เพราะ COUNT(*) ช้าเมื่อข้อมูลมาก
+	if opts.IncludeTotal {
+		var total int64
+		if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+			return nil, nil, fmt.Errorf("ไม่สามารถนับจำนวนรายการทั้งหมด: %w", err)
+		}
+		pagination.Total = &total
+	}
+
+	if len(opts.Fields) > 0 {
+		columns, err := spec.selectColumns(opts.Fields, field.Column)
+		if err != nil {
+			return nil, nil, err
+		}
+		query = query.Select(columns)
+	}
+
+	sortKey := sortName + ":" + order
+	if opts.Cursor != "" {
+		value, id, err := decodeCursor(opts.Cursor, sortKey, field.Kind)
+		if err != nil {
+			return nil, nil, err
+		}
+		operator := "<"
+		if order == "asc" {
+			operator = ">"
+		}
+		query = query.Where(fmt.Sprintf("(%s, id) %s (?, ?)", field.Column, operator), value, id)
+	}
+
+	// ดึงเกินหนึ่งแถวเพื่อรู้ว่ายังมีหน้าถัดไปหรือไม่
+	var items []T
+	if err := query.Order(fmt.Sprintf("%s %s, id %s", field.Column, order, order)).Limit(limit + 1).Find(&items).Error; err != nil {
+		return nil, nil, err
+	}
+
+	if len(items) > limit {
+		items = items[:limit]
+		pagination.HasMore = true
+		value, id := spec.Key(items[len(items)-1], sortName)
+		pagination.NextCursor = encodeCursor(sortKey, value, id)
+	}
+
+	return items, pagination, nil
+}
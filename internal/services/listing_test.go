@@ -0,0 +1,65 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestCursorRoundTrip ทดสอบว่า cursor แปลงกลับเป็นค่าและชนิดเดิมได้
+func TestCursorRoundTrip(t *testing.T) {
+	timestamp := time.Date(2025, 3, 1, 9, 30, 15, 123456000, time.UTC)
+	cursor := encodeCursor("timestamp:desc", timestamp, "log-1")
+
+	value, id, err := decodeCursor(cursor, "timestamp:desc", sortKindTime)
+	require.NoError(t, err)
+	assert.Equal(t, "log-1", id)
+	assert.True(t, timestamp.Equal(value.(time.Time)))
+
+	cursor = encodeCursor("visit_count:asc", 42, "person-1")
+	value, _, err = decodeCursor(cursor, "visit_count:asc", sortKindInt)
+	require.NoError(t, err)
+	assert.Equal(t, int64(42), value)
+
+	cursor = encodeCursor("name:asc", "Front, Door", "cam-1")
+	value, _, err = decodeCursor(cursor, "name:asc", sortKindString)
+	require.NoError(t, err)
+	assert.Equal(t, "Front, Door", value)
+}
+
+// TestDecodeCursorInvalid ทดสอบ cursor ที่เสียหายหรือใช้กับการเรียงลำดับอื่น
+func TestDecodeCursorInvalid(t *testing.T) {
+	_, _, err := decodeCursor("not-a-cursor!", "timestamp:desc", sortKindTime)
+	assert.True(t, errors.Is(err, ErrInvalidListOptions))
+
+	// cursor ของการเรียงจากใหม่ไปเก่าใช้กับการเรียงจากเก่าไปใหม่ไม่ได้
+	cursor := encodeCursor("timestamp:desc", time.Now(), "log-1")
+	_, _, err = decodeCursor(cursor, "timestamp:asc", sortKindTime)
+	assert.True(t, errors.Is(err, ErrInvalidListOptions))
+}
+
+// TestSelectColumns ทดสอบการเลือกคอลัมน์จากฟิลด์ที่ผู้เรียกเลือก
+func TestSelectColumns(t *testing.T) {
+	columns, err := personListSpec.selectColumns([]string{"person_hash", "labels", "visit_count"}, "last_seen")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"id", "last_seen", "person_hash", "organization_id", "visit_count"}, columns)
+
+	_, err = logListSpec.selectColumns([]string{"password"}, "timestamp")
+	assert.True(t, errors.Is(err, ErrInvalidListOptions))
+}
+
+// TestListSpecKey ทดสอบว่าค่าใน cursor มาจากคอลัมน์ที่ใช้เรียงลำดับ
+func TestListSpecKey(t *testing.T) {
+	person := models.Person{Base: models.Base{ID: "person-1"}, VisitCount: 7}
+	value, id := personListSpec.Key(person, "visit_count")
+	assert.Equal(t, 7, value)
+	assert.Equal(t, "person-1", id)
+
+	camera := models.Camera{Base: models.Base{ID: "cam-1"}, Name: "Lobby"}
+	value, _ = cameraListSpec.Key(camera, "name")
+	assert.Equal(t, "Lobby", value)
+}
@@ -0,0 +1,136 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/db"
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/events"
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/models"
+)
+
+// counterDelay คือเวลาที่รอหลังจบนาทีก่อนนับ เพื่อให้ข้อมูลที่ซิงค์ช้าเข้ามาทัน
+const counterDelay = 5 * time.Second
+
+// LiveCounterService ให้บริการนับจำนวนการตรวจจับรายนาทีและส่งไปยัง live stream
+type LiveCounterService struct {
+	DB     *db.PostgresDB
+	Events *events.Broker
+}
+
+// NewLiveCounterService สร้าง LiveCounterService ใหม่
+func NewLiveCounterService(postgres *db.PostgresDB, broker *events.Broker) *LiveCounterService {
+	return &LiveCounterService{
+		DB:     postgres,
+		Events: broker,
+	}
+}
+
+// minuteCount เป็นผลการนับของหนึ่งกล้องหรือทั้งองค์กรในหนึ่งนาที
+type minuteCount struct {
+	OrganizationID string
+	CameraID       string
+	Zone           string
+	Detections     int
+	UniquePersons  int
+	NewPersons     int
+	IsTotal        bool
+}
+
+// PublishMinute นับจำนวนการตรวจจับของนาทีที่กำหนด (ไม่นับพนักงาน) แล้วส่งตัวนับรายกล้องและรวมทั้งองค์กร
+func (s *LiveCounterService) PublishMinute(ctx context.Context, minute time.Time) error {
+	// ให้ replica เดียวเป็นผู้ส่งตัวนับของแต่ละนาที
+	acquired, err := s.Events.TryLock(ctx, fmt.Sprintf("counter:%d", minute.Unix()), 2*time.Minute)
+	if err != nil {
+		return err
+	}
+	if !acquired {
+		return nil
+	}
+
+	var counts []minuteCount
+	if err := s.DB.DB.WithContext(ctx).Raw(`
+		SELECT
+			l.organization_id,
+			COALESCE(l.camera_id, '') AS camera_id,
+			COALESCE(MAX(c.zone), '') AS zone,
+			COUNT(*) AS detections,
+			COUNT(DISTINCT l.person_hash) AS unique_persons,
+			COUNT(*) FILTER (WHERE l.is_new_person) AS new_persons,
+			GROUPING(l.camera_id) = 1 AS is_total
+		FROM person_logs l
+		LEFT JOIN cameras c ON c.id = l.camera_id AND c.deleted_at IS NULL
+		WHERE l.deleted_at IS NULL
+			AND l.timestamp >= @from AND l.timestamp < @to
+			AND `+staffCondition("l.person_hash", "l.organization_id")+`
+		GROUP BY GROUPING SETS ((l.organization_id, l.camera_id), (l.organization_id))
+	`, map[string]interface{}{
+		"from": minute,
+		"to":   minute.Add(time.Minute),
+	}).Scan(&counts).Error; err != nil {
+		return fmt.Errorf("ไม่สามารถนับจำนวนการตรวจจับรายนาที: %w", err)
+	}
+
+	// ทุกองค์กรได้รับตัวนับรวม แม้ไม่มีการตรวจจับในนาทีนั้น
+	var organizationIDs []string
+	if err := s.DB.DB.WithContext(ctx).Model(&models.Organization{}).Pluck("id", &organizationIDs).Error; err != nil {
+		return fmt.Errorf("ไม่สามารถดึงรายการองค์กร: %w", err)
+	}
+	hasTotal := make(map[string]bool)
+	for _, count := range counts {
+		if count.IsTotal {
+			hasTotal[count.OrganizationID] = true
+		}
+	}
+	for _, organizationID := range organizationIDs {
+		if !hasTotal[organizationID] {
+			counts = append(counts, minuteCount{OrganizationID: organizationID, IsTotal: true})
+		}
+	}
+
+	for _, count := range counts {
+		cameraID := count.CameraID
+		if count.IsTotal {
+			cameraID = ""
+		}
+		event, err := events.NewEvent(events.TypeCounter, count.OrganizationID, cameraID, count.Zone, models.CounterPayload{
+			Minute:        minute,
+			Detections:    count.Detections,
+			UniquePersons: count.UniquePersons,
+			NewPersons:    count.NewPersons,
+		})
+		if err != nil {
+			return err
+		}
+		if err := s.Events.Publish(ctx, event); err != nil {
+			return fmt.Errorf("ไม่สามารถส่งตัวนับรายนาที: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// StartCounterJob เริ่มการส่งตัวนับทุกนาที
+func (s *LiveCounterService) StartCounterJob(ctx context.Context) {
+	go func() {
+		for {
+			now := time.Now()
+			next := now.Add(-counterDelay).Truncate(time.Minute).Add(time.Minute).Add(counterDelay)
+
+			timer := time.NewTimer(next.Sub(now))
+			select {
+			case <-timer.C:
+				minute := next.Add(-counterDelay).Add(-time.Minute)
+				if err := s.PublishMinute(ctx, minute); err != nil {
+					log.Printf("ไม่สามารถส่งตัวนับรายนาที: %v", err)
+				}
+			case <-ctx.Done():
+				timer.Stop()
+				log.Println("การส่งตัวนับรายนาทีถูกยกเลิก")
+				return
+			}
+		}
+	}()
+}
@@ -0,0 +1,554 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/alert"
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/db"
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// การเคลื่อนที่ของบุคคลที่ได้จากการตรวจจับ
+const (
+	movementIn   = "in"
+	movementOut  = "out"
+	movementSeen = "seen"
+)
+
+// OccupancyService ให้บริการประมาณจำนวนคนที่อยู่ในสาขาจากกล้องทางเข้าและทางออก
+type OccupancyService struct {
+	DB       *db.PostgresDB
+	Notifier alert.Notifier
+	Timeout  time.Duration
+}
+
+// NewOccupancyService สร้าง OccupancyService ใหม่
+func NewOccupancyService(postgres *db.PostgresDB, notifier alert.Notifier, timeout time.Duration) *OccupancyService {
+	if timeout <= 0 {
+		timeout = 2 * time.Hour
+	}
+	return &OccupancyService{
+		DB:       postgres,
+		Notifier: notifier,
+		Timeout:  timeout,
+	}
+}
+
+// IsValidCameraRole ตรวจสอบว่าบทบาทของกล้องถูกต้องหรือไม่
+func IsValidCameraRole(role string) bool {
+	switch role {
+	case models.CameraRoleEntrance, models.CameraRoleExit, models.CameraRoleBoth, models.CameraRoleInterior:
+		return true
+	}
+	return false
+}
+
+// NormalizeDirection แปลงทิศทางที่กล้องส่งมาให้อยู่ในรูปแบบมาตรฐาน (in, out หรือค่าว่าง)
+func NormalizeDirection(direction string) string {
+	switch strings.ToLower(strings.TrimSpace(direction)) {
+	case "in", "enter", "entry":
+		return models.DirectionIn
+	case "out", "exit", "leave":
+		return models.DirectionOut
+	}
+	return ""
+}
+
+// ResolveMovement ระบุการเคลื่อนที่ของบุคคลจากบทบาทของกล้องและทิศทางของการตรวจจับ
+// ทิศทางที่กล้องส่งมามีผลเหนือบทบาทของกล้องทางเข้าและทางออก ส่วนกล้องภายในใช้ยืนยันว่ายังอยู่ในสาขาเท่านั้น
+func ResolveMovement(role, direction string) string {
+	switch role {
+	case models.CameraRoleEntrance, models.CameraRoleExit, models.CameraRoleBoth:
+		switch direction {
+		case models.DirectionIn:
+			return movementIn
+		case models.DirectionOut:
+			return movementOut
+		}
+		if role == models.CameraRoleEntrance {
+			return movementIn
+		}
+		if role == models.CameraRoleExit {
+			return movementOut
+		}
+		return movementSeen
+	case models.CameraRoleInterior, "":
+		return movementSeen
+	}
+	return ""
+}
+
+// EvaluateThreshold คืนสถานะใหม่ของเกณฑ์และเหตุการณ์ที่เกิดขึ้น (ถ้ามี) เมื่อจำนวนคนเปลี่ยนไป
+func EvaluateThreshold(exceeded bool, occupancy, limit int) (bool, string) {
+	if !exceeded && occupancy >= limit {
+		return true, models.OccupancyEventExceeded
+	}
+	if exceeded && occupancy < limit {
+		return false, models.OccupancyEventCleared
+	}
+	return exceeded, ""
+}
+
+// RecordDetection ปรับสถานะการอยู่ในสาขาของบุคคลจากการตรวจจับหนึ่งครั้ง
+func (s *OccupancyService) RecordDetection(ctx context.Context, camera *models.Camera, personLog *models.PersonLog) error {
+	if camera.SiteID == nil || *camera.SiteID == "" {
+		return nil
+	}
+	siteID := *camera.SiteID
+
+	movement := ResolveMovement(camera.Role, personLog.Direction)
+	if movement == "" {
+		return nil
+	}
+
+	changed := false
+	err := s.DB.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// หาช่วงเวลาที่บุคคลนี้ยังอยู่ในสาขา และยังไม่เกินเวลาที่กำหนด
+		var presence models.SitePresence
+		err := tx.Where("site_id = ? AND person_hash = ? AND exited_at IS NULL AND last_seen_at >= ?",
+			siteID, personLog.PersonHash, personLog.Timestamp.Add(-s.Timeout)).
+			Order("entered_at DESC").
+			First(&presence).Error
+		if err != nil && err != gorm.ErrRecordNotFound {
+			return fmt.Errorf("ไม่สามารถดึงข้อมูลการอยู่ในสาขา: %w", err)
+		}
+		open := err == nil
+
+		switch {
+		case movement == movementIn && !open:
+			presence = models.SitePresence{
+				Base: models.Base{
+					ID: uuid.New().String(),
+				},
+				OrganizationID: personLog.OrganizationID,
+				SiteID:         siteID,
+				PersonHash:     personLog.PersonHash,
+				EnteredAt:      personLog.Timestamp,
+				LastSeenAt:     personLog.Timestamp,
+			}
+			if err := tx.Create(&presence).Error; err != nil {
+				return fmt.Errorf("ไม่สามารถบันทึกการเข้าสาขา: %w", err)
+			}
+			changed = true
+		case movement == movementOut && open:
+			exitedAt := personLog.Timestamp
+			if exitedAt.Before(presence.EnteredAt) {
+				exitedAt = presence.EnteredAt
+			}
+			if err := tx.Model(&presence).Updates(map[string]interface{}{
+				"last_seen_at": exitedAt,
+				"exited_at":    exitedAt,
+				"exit_reason":  models.PresenceExitSeen,
+			}).Error; err != nil {
+				return fmt.Errorf("ไม่สามารถบันทึกการออกจากสาขา: %w", err)
+			}
+			changed = true
+		case open && personLog.Timestamp.After(presence.LastSeenAt):
+			// เห็นซ้ำภายในสาขา ขยายเวลาที่ยังอยู่
+			if err := tx.Model(&presence).Update("last_seen_at", personLog.Timestamp).Error; err != nil {
+				return fmt.Errorf("ไม่สามารถอัปเดตเวลาที่พบล่าสุด: %w", err)
+			}
+		}
+		// การออกโดยไม่เคยเห็นตอนเข้า และการพบภายในโดยไม่เคยเห็นตอนเข้า จะไม่มีผลกับจำนวนคน
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if changed {
+		return s.CheckThresholds(ctx, personLog.OrganizationID, siteID)
+	}
+	return nil
+}
+
+// currentOccupancy นับจำนวนคนที่ยังอยู่ในสาขา ณ ขณะนี้
+func (s *OccupancyService) currentOccupancy(ctx context.Context, siteID string, now time.Time) (int, error) {
+	var count int64
+	if err := s.DB.DB.WithContext(ctx).Model(&models.SitePresence{}).
+		Where("site_id = ? AND exited_at IS NULL AND last_seen_at > ? AND entered_at <= ?", siteID, now.Add(-s.Timeout), now).
+		Count(&count).Error; err != nil {
+		return 0, fmt.Errorf("ไม่สามารถนับจำนวนคนในสาขา: %w", err)
+	}
+	return int(count), nil
+}
+
+// GetCurrent ดึงจำนวนคนที่อยู่ในสาขา ณ ขณะนี้พร้อมสถานะของเกณฑ์ความจุ
+func (s *OccupancyService) GetCurrent(ctx context.Context, organizationID, siteID string) (*models.OccupancyStatus, error) {
+	if err := s.ensureSite(ctx, organizationID, siteID); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	occupancy, err := s.currentOccupancy(ctx, siteID, now)
+	if err != nil {
+		return nil, err
+	}
+
+	thresholds, err := s.ListThresholds(ctx, organizationID, siteID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.OccupancyStatus{
+		SiteID:     siteID,
+		Occupancy:  occupancy,
+		AsOf:       now,
+		Thresholds: thresholds,
+	}, nil
+}
+
+// presenceEnd คือ SQL ของเวลาสิ้นสุดการอยู่ในสาขา ช่วงที่ยังไม่ปิดถือว่าสิ้นสุดเมื่อเกินเวลาที่กำหนดนับจากที่พบล่าสุด
+const presenceEnd = "COALESCE(p.exited_at, LEAST(p.last_seen_at + make_interval(secs => @timeout), @now::timestamp))"
+
+// GetTimeSeries ดึงจำนวนคนในสาขาเป็นช่วงเวลาตามที่กำหนด
+func (s *OccupancyService) GetTimeSeries(ctx context.Context, organizationID, siteID string, from, to time.Time, interval time.Duration) ([]models.OccupancyPoint, error) {
+	if err := s.ensureSite(ctx, organizationID, siteID); err != nil {
+		return nil, err
+	}
+
+	var points []models.OccupancyPoint
+	if err := s.DB.DB.WithContext(ctx).Raw(`
+		SELECT series.time AS time, COUNT(p.id) AS occupancy
+		FROM generate_series(@from::timestamp, @to::timestamp, make_interval(secs => @interval)) AS series(time)
+		LEFT JOIN site_presences p
+			ON p.site_id = @site_id
+			AND p.deleted_at IS NULL
+			AND p.entered_at <= series.time
+			AND `+presenceEnd+` > series.time
+		GROUP BY series.time
+		ORDER BY series.time
+	`, map[string]interface{}{
+		"site_id":  siteID,
+		"from":     from,
+		"to":       to,
+		"interval": interval.Seconds(),
+		"timeout":  s.Timeout.Seconds(),
+		"now":      time.Now(),
+	}).Scan(&points).Error; err != nil {
+		return nil, fmt.Errorf("ไม่สามารถดึงข้อมูลจำนวนคนในสาขาตามช่วงเวลา: %w", err)
+	}
+
+	return points, nil
+}
+
+// GetPeaks ดึงจำนวนคนสูงสุดในสาขาของแต่ละวัน พร้อมเวลาที่มีคนมากที่สุดและจำนวนครั้งที่มีคนเข้า
+func (s *OccupancyService) GetPeaks(ctx context.Context, organizationID, siteID string, from, to time.Time) ([]models.OccupancyPeak, error) {
+	if err := s.ensureSite(ctx, organizationID, siteID); err != nil {
+		return nil, err
+	}
+
+	// จำนวนคนเปลี่ยนเฉพาะตอนมีคนเข้าหรือออก จึงหาค่าสูงสุดจากผลรวมสะสมของเหตุการณ์ได้ตรงตัว
+	// เหตุการณ์ออกในเวลาเดียวกันจะถูกนับก่อนเหตุการณ์เข้า
+	var peaks []models.OccupancyPeak
+	if err := s.DB.DB.WithContext(ctx).Raw(`
+		WITH presences AS (
+			SELECT p.entered_at, `+presenceEnd+` AS ended_at
+			FROM site_presences p
+			WHERE p.site_id = @site_id
+				AND p.deleted_at IS NULL
+				AND p.entered_at < @to
+		),
+		events AS (
+			SELECT GREATEST(entered_at, @from::timestamp) AS time, 1 AS delta, (entered_at >= @from) AS is_entry
+			FROM presences
+			WHERE ended_at > @from
+			UNION ALL
+			SELECT ended_at AS time, -1 AS delta, FALSE AS is_entry
+			FROM presences
+			WHERE ended_at > @from AND ended_at < @to
+		),
+		running AS (
+			SELECT time, is_entry,
+				SUM(delta) OVER (ORDER BY time, delta ROWS BETWEEN UNBOUNDED PRECEDING AND CURRENT ROW) AS occupancy
+			FROM events
+		),
+		daily AS (
+			SELECT DISTINCT ON (DATE(time)) DATE(time) AS day, occupancy AS peak, time AS peak_at
+			FROM running
+			ORDER BY DATE(time), occupancy DESC, time
+		),
+		entries AS (
+			SELECT DATE(time) AS day, COUNT(*) FILTER (WHERE is_entry) AS entries
+			FROM running
+			GROUP BY DATE(time)
+		)
+		SELECT TO_CHAR(daily.day, 'YYYY-MM-DD') AS date, daily.peak, daily.peak_at, COALESCE(entries.entries, 0) AS entries
+		FROM daily
+		LEFT JOIN entries ON entries.day = daily.day
+		ORDER BY daily.day
+	`, map[string]interface{}{
+		"site_id": siteID,
+		"from":    from,
+		"to":      to,
+		"timeout": s.Timeout.Seconds(),
+		"now":     time.Now(),
+	}).Scan(&peaks).Error; err != nil {
+		return nil, fmt.Errorf("ไม่สามารถดึงจำนวนคนสูงสุดในสาขา: %w", err)
+	}
+
+	return peaks, nil
+}
+
+// CheckThresholds ตรวจสอบว่าจำนวนคนปัจจุบันข้ามเกณฑ์ความจุของสาขาหรือไม่ และสร้างเหตุการณ์เมื่อข้าม
+func (s *OccupancyService) CheckThresholds(ctx context.Context, organizationID, siteID string) error {
+	now := time.Now()
+	occupancy, err := s.currentOccupancy(ctx, siteID, now)
+	if err != nil {
+		return err
+	}
+
+	var thresholds []models.OccupancyThreshold
+	if err := s.DB.DB.WithContext(ctx).Where("site_id = ? AND organization_id = ?", siteID, organizationID).
+		Find(&thresholds).Error; err != nil {
+		return fmt.Errorf("ไม่สามารถดึงเกณฑ์ความจุของสาขา: %w", err)
+	}
+
+	for _, threshold := range thresholds {
+		exceeded, eventType := EvaluateThreshold(threshold.Exceeded, occupancy, threshold.Occupancy)
+		if eventType == "" {
+			continue
+		}
+
+		event := models.OccupancyEvent{
+			Base: models.Base{
+				ID: uuid.New().String(),
+			},
+			OrganizationID: organizationID,
+			SiteID:         siteID,
+			ThresholdID:    threshold.ID,
+			Type:           eventType,
+			Occupancy:      occupancy,
+			Threshold:      threshold.Occupancy,
+			Severity:       threshold.Severity,
+			OccurredAt:     now,
+		}
+
+		// อัปเดตสถานะเฉพาะเมื่อสถานะเดิมยังไม่ถูกเปลี่ยนโดยการตรวจจับอื่นที่เกิดพร้อมกัน
+		created := false
+		err := s.DB.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			result := tx.Model(&models.OccupancyThreshold{}).
+				Where("id = ? AND exceeded = ?", threshold.ID, threshold.Exceeded).
+				Update("exceeded", exceeded)
+			if result.Error != nil {
+				return fmt.Errorf("ไม่สามารถอัปเดตสถานะเกณฑ์ความจุ: %w", result.Error)
+			}
+			if result.RowsAffected == 0 {
+				return nil
+			}
+			if err := tx.Create(&event).Error; err != nil {
+				return fmt.Errorf("ไม่สามารถบันทึกเหตุการณ์ความจุ: %w", err)
+			}
+			created = true
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		if created {
+			s.notify(ctx, threshold, event)
+		}
+	}
+
+	return nil
+}
+
+// notify ส่งการแจ้งเตือนเมื่อจำนวนคนข้ามเกณฑ์ความจุ
+func (s *OccupancyService) notify(ctx context.Context, threshold models.OccupancyThreshold, event models.OccupancyEvent) {
+	if s.Notifier == nil {
+		return
+	}
+
+	title := fmt.Sprintf("จำนวนคนในสาขาถึงเกณฑ์ %s", threshold.Name)
+	severity := event.Severity
+	if event.Type == models.OccupancyEventCleared {
+		title = fmt.Sprintf("จำนวนคนในสาขาต่ำกว่าเกณฑ์ %s แล้ว", threshold.Name)
+		severity = models.SeverityLow
+	}
+
+	if err := s.Notifier.Notify(ctx, alert.Alert{
+		Type:           "occupancy_threshold",
+		Severity:       severity,
+		OrganizationID: event.OrganizationID,
+		Title:          title,
+		Message:        fmt.Sprintf("สาขา %s มีคน %d คน (เกณฑ์ %d คน)", event.SiteID, event.Occupancy, event.Threshold),
+		Data:           event,
+		CreatedAt:      event.OccurredAt,
+	}); err != nil {
+		log.Printf("ไม่สามารถส่งการแจ้งเตือนความจุของสาขา %s: %v", event.SiteID, err)
+	}
+}
+
+// CloseTimedOut ปิดช่วงการอยู่ในสาขาของคนที่ไม่ถูกพบเกินเวลาที่กำหนด และตรวจเกณฑ์ความจุของสาขาที่ได้รับผลกระทบ
+func (s *OccupancyService) CloseTimedOut(ctx context.Context) error {
+	var affected []struct {
+		OrganizationID string
+		SiteID         string
+	}
+	if err := s.DB.DB.WithContext(ctx).Raw(`
+		UPDATE site_presences
+		SET exited_at = last_seen_at, exit_reason = @reason, updated_at = NOW()
+		WHERE exited_at IS NULL AND deleted_at IS NULL AND last_seen_at <= @cutoff
+		RETURNING organization_id, site_id
+	`, map[string]interface{}{
+		"reason": models.PresenceTimeout,
+		"cutoff": time.Now().Add(-s.Timeout),
+	}).Scan(&affected).Error; err != nil {
+		return fmt.Errorf("ไม่สามารถปิดช่วงการอยู่ในสาขาที่หมดเวลา: %w", err)
+	}
+
+	checked := make(map[string]bool)
+	for _, site := range affected {
+		if checked[site.SiteID] {
+			continue
+		}
+		checked[site.SiteID] = true
+		if err := s.CheckThresholds(ctx, site.OrganizationID, site.SiteID); err != nil {
+			log.Printf("ไม่สามารถตรวจเกณฑ์ความจุของสาขา %s: %v", site.SiteID, err)
+		}
+	}
+
+	return nil
+}
+
+// StartTimeoutJob เริ่มการปิดช่วงการอยู่ในสาขาที่หมดเวลาเป็นระยะ
+func (s *OccupancyService) StartTimeoutJob(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if err := s.CloseTimedOut(ctx); err != nil {
+					log.Printf("ไม่สามารถปรับจำนวนคนในสาขา: %v", err)
+				}
+			case <-ctx.Done():
+				log.Println("การปรับจำนวนคนในสาขาถูกยกเลิก")
+				return
+			}
+		}
+	}()
+}
+
+// ensureSite ตรวจสอบว่าสาขาเป็นขององค์กรที่กำหนด
+func (s *OccupancyService) ensureSite(ctx context.Context, organizationID, siteID string) error {
+	var count int64
+	if err := s.DB.DB.WithContext(ctx).Model(&models.Site{}).
+		Where("id = ? AND organization_id = ?", siteID, organizationID).
+		Count(&count).Error; err != nil {
+		return fmt.Errorf("ไม่สามารถตรวจสอบสาขา: %w", err)
+	}
+	if count == 0 {
+		return fmt.Errorf("ไม่พบสาขา")
+	}
+	return nil
+}
+
+// ListThresholds ดึงเกณฑ์ความจุของสาขา
+func (s *OccupancyService) ListThresholds(ctx context.Context, organizationID, siteID string) ([]models.OccupancyThreshold, error) {
+	var thresholds []models.OccupancyThreshold
+	if err := s.DB.DB.WithContext(ctx).
+		Where("site_id = ? AND organization_id = ?", siteID, organizationID).
+		Order("occupancy").
+		Find(&thresholds).Error; err != nil {
+		return nil, fmt.Errorf("ไม่สามารถดึงเกณฑ์ความจุของสาขา: %w", err)
+	}
+	return thresholds, nil
+}
+
+// CreateThreshold เพิ่มเกณฑ์ความจุของสาขา
+func (s *OccupancyService) CreateThreshold(ctx context.Context, threshold *models.OccupancyThreshold) error {
+	if err := s.ensureSite(ctx, threshold.OrganizationID, threshold.SiteID); err != nil {
+		return err
+	}
+	if threshold.Occupancy <= 0 {
+		return fmt.Errorf("จำนวนคนของเกณฑ์ต้องมากกว่า 0")
+	}
+	if threshold.Severity == "" {
+		threshold.Severity = models.SeverityMedium
+	}
+	if threshold.ID == "" {
+		threshold.ID = uuid.New().String()
+	}
+
+	// กำหนดสถานะเริ่มต้นตามจำนวนคนปัจจุบัน เพื่อไม่ให้เกิดเหตุการณ์ย้อนหลัง
+	occupancy, err := s.currentOccupancy(ctx, threshold.SiteID, time.Now())
+	if err != nil {
+		return err
+	}
+	threshold.Exceeded = occupancy >= threshold.Occupancy
+
+	if err := s.DB.DB.WithContext(ctx).Create(threshold).Error; err != nil {
+		return fmt.Errorf("ไม่สามารถเพิ่มเกณฑ์ความจุ: %w", err)
+	}
+	return nil
+}
+
+// DeleteThreshold ลบเกณฑ์ความจุของสาขา
+func (s *OccupancyService) DeleteThreshold(ctx context.Context, organizationID, siteID, id string) error {
+	result := s.DB.DB.WithContext(ctx).
+		Where("id = ? AND site_id = ? AND organization_id = ?", id, siteID, organizationID).
+		Delete(&models.OccupancyThreshold{})
+	if result.Error != nil {
+		return fmt.Errorf("ไม่สามารถลบเกณฑ์ความจุ: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("ไม่พบเกณฑ์ความจุที่ต้องการลบ")
+	}
+	return nil
+}
+
+// ListEvents ดึงเหตุการณ์ที่จำนวนคนข้ามเกณฑ์ความจุของสาขา
+func (s *OccupancyService) ListEvents(ctx context.Context, organizationID, siteID string, page, pageSize int) ([]models.OccupancyEvent, *models.Pagination, error) {
+	if err := s.ensureSite(ctx, organizationID, siteID); err != nil {
+		return nil, nil, err
+	}
+
+	if page <= 0 {
+		page = 1
+	}
+	if pageSize <= 0 {
+		pageSize = 10
+	}
+	offset := (page - 1) * pageSize
+
+	query := s.DB.DB.WithContext(ctx).Model(&models.OccupancyEvent{}).
+		Where("site_id = ? AND organization_id = ?", siteID, organizationID)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, nil, fmt.Errorf("ไม่สามารถนับจำนวนเหตุการณ์ความจุ: %w", err)
+	}
+
+	var events []models.OccupancyEvent
+	if err := query.Order("occurred_at DESC").Limit(pageSize).Offset(offset).Find(&events).Error; err != nil {
+		return nil, nil, fmt.Errorf("ไม่สามารถดึงรายการเหตุการณ์ความจุ: %w", err)
+	}
+
+	totalPage := int(total) / pageSize
+	if int(total)%pageSize > 0 {
+		totalPage++
+	}
+
+	pagination := &models.Pagination{
+		Total:     int(total),
+		Page:      page,
+		PageSize:  pageSize,
+		TotalPage: totalPage,
+	}
+
+	return events, pagination, nil
+}
@@ -0,0 +1,52 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestResolveMovement ทดสอบการระบุการเข้าออกจากบทบาทของกล้องและทิศทาง
+func TestResolveMovement(t *testing.T) {
+	assert.Equal(t, movementIn, ResolveMovement(models.CameraRoleEntrance, ""))
+	assert.Equal(t, movementOut, ResolveMovement(models.CameraRoleExit, ""))
+	assert.Equal(t, movementSeen, ResolveMovement(models.CameraRoleBoth, ""))
+	assert.Equal(t, movementOut, ResolveMovement(models.CameraRoleBoth, models.DirectionOut))
+	assert.Equal(t, movementIn, ResolveMovement(models.CameraRoleBoth, models.DirectionIn))
+
+	// ทิศทางที่กล้องส่งมามีผลเหนือบทบาท
+	assert.Equal(t, movementOut, ResolveMovement(models.CameraRoleEntrance, models.DirectionOut))
+
+	// กล้องภายในไม่นับการเข้าออก
+	assert.Equal(t, movementSeen, ResolveMovement(models.CameraRoleInterior, models.DirectionIn))
+	assert.Equal(t, "", ResolveMovement("unknown", ""))
+}
+
+// TestNormalizeDirection ทดสอบการแปลงทิศทางให้อยู่ในรูปแบบมาตรฐาน
+func TestNormalizeDirection(t *testing.T) {
+	assert.Equal(t, models.DirectionIn, NormalizeDirection(" IN "))
+	assert.Equal(t, models.DirectionIn, NormalizeDirection("enter"))
+	assert.Equal(t, models.DirectionOut, NormalizeDirection("exit"))
+	assert.Equal(t, "", NormalizeDirection("sideways"))
+}
+
+// TestEvaluateThreshold ทดสอบการเกิดเหตุการณ์เมื่อจำนวนคนข้ามเกณฑ์
+func TestEvaluateThreshold(t *testing.T) {
+	exceeded, event := EvaluateThreshold(false, 50, 50)
+	assert.True(t, exceeded)
+	assert.Equal(t, models.OccupancyEventExceeded, event)
+
+	// ยังเกินเกณฑ์อยู่ ไม่เกิดเหตุการณ์ซ้ำ
+	exceeded, event = EvaluateThreshold(true, 60, 50)
+	assert.True(t, exceeded)
+	assert.Equal(t, "", event)
+
+	exceeded, event = EvaluateThreshold(true, 49, 50)
+	assert.False(t, exceeded)
+	assert.Equal(t, models.OccupancyEventCleared, event)
+
+	exceeded, event = EvaluateThreshold(false, 10, 50)
+	assert.False(t, exceeded)
+	assert.Equal(t, "", event)
+}
@@ -2,7 +2,9 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"slices"
 	"time"
 
 	"github.com/bemindtech/bmt-manta-dashboard-service/internal/db"
@@ -13,13 +15,18 @@ import (
 
 // PersonService ให้บริการเกี่ยวกับการจัดการข้อมูลบุคคล
 type PersonService struct {
-	DB *db.PostgresDB
+	DB    *db.PostgresDB
+	Stats *StatsService
+	URLs  *ImageURLSigner
 }
 
-// NewPersonService สร้าง PersonService ใหม่
-func NewPersonService(postgres *db.PostgresDB) *PersonService {
+// NewPersonService สร้าง PersonService ใหม่ โดยใช้ StatsService (ถ้ามี) ล้าง cache สถิติเมื่อรวมหรือแยกบุคคล
+// และใช้ ImageURLSigner (ถ้ามี) สร้าง URL ของรูปภาพใบหน้าใน response
+func NewPersonService(postgres *db.PostgresDB, statsService *StatsService, urls *ImageURLSigner) *PersonService {
 	return &PersonService{
-		DB: postgres,
+		DB:    postgres,
+		Stats: statsService,
+		URLs:  urls,
 	}
 }
 
@@ -27,9 +34,11 @@ func NewPersonService(postgres *db.PostgresDB) *PersonService {
 func (s *PersonService) GetPerson(ctx context.Context, personHash, organizationID string) (*models.Person, error) {
 	var person models.Person
 
-	// ดึงข้อมูลบุคคลด้วย GORM รวมถึงรูปภาพใบหน้า
+	// ดึงข้อมูลบุคคลด้วย GORM รวมถึงรูปภาพใบหน้าและป้ายกำกับ ซึ่งเชื่อมกันด้วย (organization_id, person_hash) และรูปภาพหลัก
 	result := s.DB.DB.WithContext(ctx).
 		Preload("FaceImages").
+		Preload("PrimaryFaceImage").
+		Preload("Labels").
 		Where("person_hash = ? AND organization_id = ?", personHash, organizationID).
 		First(&person)
 
@@ -40,104 +49,320 @@ func (s *PersonService) GetPerson(ctx context.Context, personHash, organizationI
 		return nil, fmt.Errorf("ไม่สามารถดึงข้อมูลบุคคล: %w", result.Error)
 	}
 
+	s.URLs.SignFaceImages(person.FaceImages)
+	if person.PrimaryFaceImage != nil {
+		s.URLs.SignFaceImage(person.PrimaryFaceImage)
+	}
 	return &person, nil
 }
 
-// ListPersons ดึงรายการบุคคลทั้งหมดขององค์กร
-func (s *PersonService) ListPersons(ctx context.Context, organizationID string, page, pageSize int) ([]models.Person, *models.Pagination, error) {
-	// จัดการ pagination
-	if page <= 0 {
-		page = 1
+// personListSpec กำหนดการเรียงลำดับและฟิลด์ที่เลือกได้ของรายการบุคคล
+var personListSpec = listSpec[models.Person]{
+	Sorts: map[string]sortField{
+		"last_seen":   {Column: "last_seen", Kind: sortKindTime},
+		"first_seen":  {Column: "first_seen", Kind: sortKindTime},
+		"visit_count": {Column: "visit_count", Kind: sortKindInt},
+		"created_at":  {Column: "created_at", Kind: sortKindTime},
+	},
+	DefaultSort:  "last_seen",
+	DefaultOrder: "desc",
+	Fields: map[string]string{
+		"id":              "id",
+		"created_at":      "created_at",
+		"updated_at":      "updated_at",
+		"person_hash":     "person_hash",
+		"first_seen":      "first_seen",
+		"last_seen":       "last_seen",
+		"visit_count":     "visit_count",
+		"organization_id":           "organization_id",
+		"primary_face_image_id":     "primary_face_image_id",
+		"primary_face_image_pinned": "primary_face_image_pinned",
+		"primary_face_image":        "primary_face_image_id",
+		"face_images":               "primary_face_image_id",
+		"labels":                    "organization_id,person_hash",
+	},
+	Key: func(person models.Person, sort string) (interface{}, string) {
+		switch sort {
+		case "first_seen":
+			return person.FirstSeen, person.ID
+		case "visit_count":
+			return person.VisitCount, person.ID
+		case "created_at":
+			return person.CreatedAt, person.ID
+		}
+		return person.LastSeen, person.ID
+	},
+}
+
+// searchPersons สร้าง query ของบุคคลในองค์กรที่ตรงกับ search และการค้นหาที่บันทึกไว้ใน search.SavedSearchID (ถ้ามี)
+// ตัวกรองที่ไม่ถูกต้องจะคืน ErrInvalidListOptions
+func (s *PersonService) searchPersons(ctx context.Context, organizationID string, search models.PersonSearch) (*gorm.DB, error) {
+	if err := validatePersonSearch(&search); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidListOptions, err)
 	}
-	if pageSize <= 0 {
-		pageSize = 10
+
+	now := time.Now()
+	query := s.DB.DB.WithContext(ctx).Model(&models.Person{}).Where("persons.organization_id = ?", organizationID)
+	if condition, args := personSearchCondition(search, "persons", "search", now); condition != "" {
+		query = query.Where(condition, args)
 	}
-	offset := (page - 1) * pageSize
 
-	// นับจำนวนบุคคลทั้งหมด
-	var total int64
-	if err := s.DB.DB.WithContext(ctx).Model(&models.Person{}).
-		Where("organization_id = ?", organizationID).
-		Count(&total).Error; err != nil {
-		return nil, nil, fmt.Errorf("ไม่สามารถนับจำนวนบุคคล: %w", err)
+	if search.SavedSearchID != "" {
+		_, saved, err := loadSavedSearch(ctx, s.DB.DB, search.SavedSearchID, organizationID)
+		if err != nil {
+			return nil, err
+		}
+		if condition, args := personSearchCondition(*saved, "persons", "saved", now); condition != "" {
+			query = query.Where(condition, args)
+		}
 	}
 
-	// ดึงข้อมูลบุคคล
-	var persons []models.Person
-	if err := s.DB.DB.WithContext(ctx).
-		Preload("FaceImages", func(db *gorm.DB) *gorm.DB {
-			return db.Order("created_at DESC").Limit(1)
-		}).
-		Where("organization_id = ?", organizationID).
-		Order("last_seen DESC").
-		Limit(pageSize).
-		Offset(offset).
-		Find(&persons).Error; err != nil {
-		return nil, nil, fmt.Errorf("ไม่สามารถดึงรายการบุคคล: %w", err)
+	return query, nil
+}
+
+// ListPersons ดึงรายการบุคคลขององค์กรที่ตรงกับ search โดยแบ่งหน้าด้วย cursor
+func (s *PersonService) ListPersons(ctx context.Context, organizationID string, search models.PersonSearch, opts models.ListOptions) ([]models.Person, *models.CursorPagination, error) {
+	query, err := s.searchPersons(ctx, organizationID, search)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	// คำนวณจำนวนหน้าทั้งหมด
-	totalPage := int(total) / pageSize
-	if int(total)%pageSize > 0 {
-		totalPage++
+	// ดึงรูปภาพหลักเฉพาะเมื่อไม่ได้เลือกฟิลด์ หรือเลือก primary_face_image หรือ face_images
+	// face_images ของรายการบุคคลมีเพียงรูปภาพหลัก เพื่อให้ client ที่ใช้รูปแรกเป็นรูปประจำตัวแสดงรูปเดียวกัน
+	withFaceImages := len(opts.Fields) == 0 || slices.Contains(opts.Fields, "face_images")
+	if withFaceImages || slices.Contains(opts.Fields, "primary_face_image") {
+		query = query.Preload("PrimaryFaceImage")
+	}
+	if len(opts.Fields) == 0 || slices.Contains(opts.Fields, "labels") {
+		query = query.Preload("Labels", func(db *gorm.DB) *gorm.DB {
+			return db.Order("label")
+		})
 	}
 
-	// สร้างข้อมูล pagination
-	pagination := &models.Pagination{
-		Total:     int(total),
-		Page:      page,
-		PageSize:  pageSize,
-		TotalPage: totalPage,
+	persons, pagination, err := paginate(query, personListSpec, opts)
+	if err != nil {
+		if errors.Is(err, ErrInvalidListOptions) {
+			return nil, nil, err
+		}
+		return nil, nil, fmt.Errorf("ไม่สามารถดึงรายการบุคคล: %w", err)
 	}
 
+	for i := range persons {
+		if persons[i].PrimaryFaceImage == nil {
+			continue
+		}
+		s.URLs.SignFaceImage(persons[i].PrimaryFaceImage)
+		if withFaceImages {
+			persons[i].FaceImages = []models.FaceImage{*persons[i].PrimaryFaceImage}
+		}
+	}
 	return persons, pagination, nil
 }
 
-// CreateOrUpdatePerson สร้างหรืออัปเดตข้อมูลบุคคล
-func (s *PersonService) CreateOrUpdatePerson(ctx context.Context, personLog *models.PersonLog) (*models.Person, error) {
-	// ตรวจสอบว่ามีบุคคลนี้ในฐานข้อมูลแล้วหรือไม่
+// GetFacets นับจำนวนบุคคลที่ตรงกับ search แยกตามป้ายกำกับ กล้อง โซน การมีรูปภาพใบหน้า จำนวนการเข้าชม และระยะเวลาตั้งแต่พบครั้งแรก
+func (s *PersonService) GetFacets(ctx context.Context, organizationID string, search models.PersonSearch) (*models.PersonFacets, error) {
+	query, err := s.searchPersons(ctx, organizationID, search)
+	if err != nil {
+		return nil, err
+	}
+	matched := query.Select("persons.person_hash, persons.visit_count, persons.first_seen")
+
+	// นับจำนวนรวม การมีรูปภาพใบหน้า และการกระจายของจำนวนการเข้าชมและวันที่พบครั้งแรก
+	var totals struct {
+		Total      int `gorm:"column:total"`
+		WithFaces  int `gorm:"column:with_faces"`
+		Visits1    int `gorm:"column:visits1"`
+		Visits2    int `gorm:"column:visits2"`
+		Visits3To4 int `gorm:"column:visits3_to4"`
+		Visits5To9 int `gorm:"column:visits5_to9"`
+		Visits10   int `gorm:"column:visits10_plus"`
+		New0To7    int `gorm:"column:new0_to7"`
+		New8To30   int `gorm:"column:new8_to30"`
+		New31To90  int `gorm:"column:new31_to90"`
+		New90Plus  int `gorm:"column:new90_plus"`
+	}
+	if err := s.DB.DB.WithContext(ctx).Raw(`
+		WITH m AS (?)
+		SELECT
+			COUNT(*) AS total,
+			COUNT(*) FILTER (WHERE EXISTS (
+				SELECT 1 FROM face_images f
+				WHERE f.organization_id = ? AND f.person_hash = m.person_hash AND f.deleted_at IS NULL
+			)) AS with_faces,
+			COUNT(*) FILTER (WHERE visit_count <= 1) AS visits1,
+			COUNT(*) FILTER (WHERE visit_count = 2) AS visits2,
+			COUNT(*) FILTER (WHERE visit_count BETWEEN 3 AND 4) AS visits3_to4,
+			COUNT(*) FILTER (WHERE visit_count BETWEEN 5 AND 9) AS visits5_to9,
+			COUNT(*) FILTER (WHERE visit_count >= 10) AS visits10_plus,
+			COUNT(*) FILTER (WHERE first_seen >= NOW() - INTERVAL '8 days') AS new0_to7,
+			COUNT(*) FILTER (WHERE first_seen < NOW() - INTERVAL '8 days' AND first_seen >= NOW() - INTERVAL '31 days') AS new8_to30,
+			COUNT(*) FILTER (WHERE first_seen < NOW() - INTERVAL '31 days' AND first_seen >= NOW() - INTERVAL '91 days') AS new31_to90,
+			COUNT(*) FILTER (WHERE first_seen < NOW() - INTERVAL '91 days') AS new90_plus
+		FROM m
+	`, matched, organizationID).Scan(&totals).Error; err != nil {
+		return nil, fmt.Errorf("ไม่สามารถนับจำนวนบุคคลที่ค้นหา: %w", err)
+	}
+
+	// นับจำนวนบุคคลแยกตามป้ายกำกับ
+	labels := []models.LabelCount{}
+	if err := s.DB.DB.WithContext(ctx).Raw(`
+		WITH m AS (?)
+		SELECT pl.label, COUNT(*) AS count
+		FROM person_labels pl
+		JOIN m ON m.person_hash = pl.person_hash
+		WHERE pl.organization_id = ? AND pl.deleted_at IS NULL
+		GROUP BY pl.label
+		ORDER BY count DESC, pl.label
+	`, matched, organizationID).Scan(&labels).Error; err != nil {
+		return nil, fmt.Errorf("ไม่สามารถนับจำนวนบุคคลตามป้ายกำกับ: %w", err)
+	}
+
+	// นับจำนวนบุคคลที่เคยพบที่แต่ละกล้องและแต่ละโซน
+	cameras := []models.FacetCount{}
+	if err := s.DB.DB.WithContext(ctx).Raw(`
+		WITH m AS (?)
+		SELECT l.camera_id AS value, COUNT(DISTINCT l.person_hash) AS count
+		FROM person_logs l
+		JOIN m ON m.person_hash = l.person_hash
+		WHERE l.organization_id = ? AND l.deleted_at IS NULL
+		GROUP BY l.camera_id
+		ORDER BY count DESC, l.camera_id
+	`, matched, organizationID).Scan(&cameras).Error; err != nil {
+		return nil, fmt.Errorf("ไม่สามารถนับจำนวนบุคคลตามกล้อง: %w", err)
+	}
+
+	zones := []models.FacetCount{}
+	if err := s.DB.DB.WithContext(ctx).Raw(`
+		WITH m AS (?)
+		SELECT c.zone AS value, COUNT(DISTINCT l.person_hash) AS count
+		FROM person_logs l
+		JOIN m ON m.person_hash = l.person_hash
+		JOIN cameras c ON c.id = l.camera_id AND c.deleted_at IS NULL
+		WHERE l.organization_id = ? AND l.deleted_at IS NULL AND c.zone <> ''
+		GROUP BY c.zone
+		ORDER BY count DESC, c.zone
+	`, matched, organizationID).Scan(&zones).Error; err != nil {
+		return nil, fmt.Errorf("ไม่สามารถนับจำนวนบุคคลตามโซน: %w", err)
+	}
+
+	return &models.PersonFacets{
+		Total:        totals.Total,
+		Labels:       labels,
+		Cameras:      cameras,
+		Zones:        zones,
+		WithFaces:    totals.WithFaces,
+		WithoutFaces: totals.Total - totals.WithFaces,
+		Visits: []models.HistogramBucket{
+			{Label: "1", Count: totals.Visits1},
+			{Label: "2", Count: totals.Visits2},
+			{Label: "3-4", Count: totals.Visits3To4},
+			{Label: "5-9", Count: totals.Visits5To9},
+			{Label: "10+", Count: totals.Visits10},
+		},
+		FirstSeenDays: []models.HistogramBucket{
+			{Label: "0-7", Count: totals.New0To7},
+			{Label: "8-30", Count: totals.New8To30},
+			{Label: "31-90", Count: totals.New31To90},
+			{Label: "90+", Count: totals.New90Plus},
+		},
+	}, nil
+}
+
+// ErrDuplicatePersonLog log ของบุคคลเดียวกันจากกล้องเดียวกันในเวลาเดียวกันถูกบันทึกไว้แล้ว
+var ErrDuplicatePersonLog = errors.New("log นี้ถูกบันทึกไว้แล้ว")
+
+// upsertPersonSQL สร้างหรืออัปเดตบุคคลในคำสั่งเดียว
+// first_seen และ last_seen ใช้ LEAST/GREATEST จึงถูกต้องแม้ event มาไม่เรียงลำดับ
+// บุคคลที่ถูกลบไปแล้ว (ข้อมูลทั้งหมดถูกลบพร้อมกัน) จะถูกสร้างขึ้นใหม่จาก log นี้
+const upsertPersonSQL = `
+	INSERT INTO persons (id, created_at, updated_at, person_hash, first_seen, last_seen, visit_count, organization_id)
+	VALUES (@id, @now, @now, @person_hash, @timestamp, @timestamp, 1, @organization_id)
+	ON CONFLICT (organization_id, person_hash) DO UPDATE SET
+		first_seen = CASE WHEN persons.deleted_at IS NULL THEN LEAST(persons.first_seen, EXCLUDED.first_seen) ELSE EXCLUDED.first_seen END,
+		last_seen = CASE WHEN persons.deleted_at IS NULL THEN GREATEST(persons.last_seen, EXCLUDED.last_seen) ELSE EXCLUDED.last_seen END,
+		visit_count = CASE WHEN persons.deleted_at IS NULL THEN persons.visit_count + 1 ELSE 1 END,
+		deleted_at = NULL,
+		updated_at = EXCLUDED.updated_at
+	RETURNING *`
+
+// RecordPersonLog บันทึก log ใหม่และปรับข้อมูลบุคคลใน transaction เดียวกัน
+// การ upsert ล็อกแถวของบุคคลไว้จนจบ transaction worker ที่บันทึกบุคคลเดียวกันพร้อมกันจึงทำงานต่อกันทีละตัว
+// ทำให้การตรวจ log ซ้ำและสถานะคนใหม่ไม่ชนกัน ถ้า log ซ้ำจะคืน ErrDuplicatePersonLog และไม่มีการเปลี่ยนแปลงใดๆ
+func (s *PersonService) RecordPersonLog(ctx context.Context, personLog *models.PersonLog) (*models.Person, error) {
 	var person models.Person
-	result := s.DB.DB.WithContext(ctx).
-		Where("person_hash = ? AND organization_id = ?", personLog.PersonHash, personLog.OrganizationID).
-		First(&person)
+	err := s.DB.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Raw(upsertPersonSQL, map[string]interface{}{
+			"id":              uuid.New().String(),
+			"now":             time.Now(),
+			"person_hash":     personLog.PersonHash,
+			"timestamp":       personLog.Timestamp,
+			"organization_id": personLog.OrganizationID,
+		}).Scan(&person).Error; err != nil {
+			return fmt.Errorf("ไม่สามารถบันทึกข้อมูลบุคคล: %w", err)
+		}
 
-	isNewPerson := result.Error == gorm.ErrRecordNotFound
-	now := time.Now()
+		// ตรวจ log ซ้ำหลังล็อกแถวของบุคคลแล้ว ถ้าซ้ำให้ rollback จำนวนการเข้าชมที่เพิ่งเพิ่ม
+		var duplicates int64
+		if err := tx.Model(&models.PersonLog{}).Where(
+			"organization_id = @organization_id AND person_hash = @person_hash AND camera_id = @camera_id AND timestamp = @timestamp",
+			map[string]interface{}{
+				"organization_id": personLog.OrganizationID,
+				"person_hash":     personLog.PersonHash,
+				"camera_id":       personLog.CameraID,
+				"timestamp":       personLog.Timestamp,
+			},
+		).Count(&duplicates).Error; err != nil {
+			return fmt.Errorf("ไม่สามารถตรวจสอบ log ซ้ำ: %w", err)
+		}
+		if duplicates > 0 {
+			return ErrDuplicatePersonLog
+		}
 
-	if isNewPerson {
-		// สร้างบุคคลใหม่
-		person = models.Person{
-			Base: models.Base{
-				ID: uuid.New().String(),
+		// เป็นคนใหม่ถ้าไม่มี log ที่มาก่อน (เวลาเท่ากันเรียงตาม id) ในองค์กรเดียวกัน
+		var earlier int64
+		if err := tx.Model(&models.PersonLog{}).Where(
+			"organization_id = @organization_id AND person_hash = @person_hash AND (timestamp < @timestamp OR (timestamp = @timestamp AND id < @id))",
+			map[string]interface{}{
+				"organization_id": personLog.OrganizationID,
+				"person_hash":     personLog.PersonHash,
+				"timestamp":       personLog.Timestamp,
+				"id":              personLog.ID,
 			},
-			PersonHash:     personLog.PersonHash,
-			FirstSeen:      personLog.Timestamp,
-			LastSeen:       personLog.Timestamp,
-			VisitCount:     1,
-			OrganizationID: personLog.OrganizationID,
+		).Count(&earlier).Error; err != nil {
+			return fmt.Errorf("ไม่สามารถตรวจสอบประวัติบุคคล: %w", err)
 		}
+		personLog.IsNewPerson = earlier == 0
 
-		if err := s.DB.DB.WithContext(ctx).Create(&person).Error; err != nil {
-			return nil, fmt.Errorf("ไม่สามารถสร้างข้อมูลบุคคลใหม่: %w", err)
+		if err := tx.Create(personLog).Error; err != nil {
+			return fmt.Errorf("ไม่สามารถเพิ่มข้อมูลใน PostgreSQL: %w", err)
 		}
-	} else if result.Error != nil {
-		// เกิดข้อผิดพลาดอื่นๆ
-		return nil, fmt.Errorf("ไม่สามารถตรวจสอบข้อมูลบุคคล: %w", result.Error)
-	} else {
-		// อัปเดตข้อมูลบุคคลที่มีอยู่แล้ว
-		updates := map[string]interface{}{
-			"last_seen":   personLog.Timestamp,
-			"visit_count": gorm.Expr("visit_count + 1"),
-			"updated_at":  now,
+
+		// รูปภาพใบหน้าที่อัปโหลดก่อน log แรกของบุคคลยังไม่ถูกเลือกเป็นรูปภาพหลัก
+		if person.VisitCount == 1 {
+			if err := selectPrimaryFaceImage(tx, personLog.OrganizationID, personLog.PersonHash); err != nil {
+				return err
+			}
 		}
 
-		if err := s.DB.DB.WithContext(ctx).Model(&person).Updates(updates).Error; err != nil {
-			return nil, fmt.Errorf("ไม่สามารถอัปเดตข้อมูลบุคคล: %w", err)
+		// event ที่มาช้ากว่าแต่เกิดก่อนกลายเป็นครั้งแรกของบุคคล log ที่เคยเป็นคนใหม่จึงเป็นคนซ้ำ
+		if personLog.IsNewPerson {
+			if err := tx.Model(&models.PersonLog{}).Where(
+				"organization_id = @organization_id AND person_hash = @person_hash AND is_new_person AND id <> @id",
+				map[string]interface{}{
+					"organization_id": personLog.OrganizationID,
+					"person_hash":     personLog.PersonHash,
+					"id":              personLog.ID,
+				},
+			).Update("is_new_person", false).Error; err != nil {
+				return fmt.Errorf("ไม่สามารถปรับสถานะคนใหม่: %w", err)
+			}
 		}
-	}
 
-	// อัปเดตสถานะ IsNewPerson ใน PersonLog ตามผลการตรวจสอบ
-	personLog.IsNewPerson = isNewPerson
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
 
 	return &person, nil
 }
@@ -185,7 +410,12 @@ func (s *PersonService) GetPersonStats(ctx context.Context, personHash, organiza
 func (s *PersonService) DeletePerson(ctx context.Context, personHash, organizationID string) error {
 	// ใช้ transaction เพื่อให้แน่ใจว่าการลบทั้งหมดสำเร็จหรือล้มเหลวพร้อมกัน
 	return s.DB.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
-		// ลบรูปภาพใบหน้าที่เกี่ยวข้อง
+		// ลบ embedding และรูปภาพใบหน้าที่เกี่ยวข้อง
+		if err := tx.Where("face_image_id IN (?)", tx.Model(&models.FaceImage{}).Select("id").
+			Where("person_hash = ? AND organization_id = ?", personHash, organizationID)).
+			Delete(&models.FaceEmbedding{}).Error; err != nil {
+			return fmt.Errorf("ไม่สามารถลบ embedding ของใบหน้า: %w", err)
+		}
 		if err := tx.Where("person_hash = ? AND organization_id = ?", personHash, organizationID).
 			Delete(&models.FaceImage{}).Error; err != nil {
 			return fmt.Errorf("ไม่สามารถลบรูปภาพใบหน้า: %w", err)
@@ -197,6 +427,22 @@ func (s *PersonService) DeletePerson(ctx context.Context, personHash, organizati
 			return fmt.Errorf("ไม่สามารถลบข้อมูล logs: %w", err)
 		}
 
+		// ลบป้ายกำกับและบันทึกของบุคคล
+		if err := tx.Unscoped().Where("person_hash = ? AND organization_id = ?", personHash, organizationID).
+			Delete(&models.PersonLabel{}).Error; err != nil {
+			return fmt.Errorf("ไม่สามารถลบป้ายกำกับของบุคคล: %w", err)
+		}
+		if err := tx.Where("person_hash = ? AND organization_id = ?", personHash, organizationID).
+			Delete(&models.PersonNote{}).Error; err != nil {
+			return fmt.Errorf("ไม่สามารถลบบันทึกของบุคคล: %w", err)
+		}
+
+		// นำบุคคลออกจากรายการเฝ้าระวัง
+		if err := tx.Unscoped().Where("person_hash = ? AND organization_id = ?", personHash, organizationID).
+			Delete(&models.WatchlistEntry{}).Error; err != nil {
+			return fmt.Errorf("ไม่สามารถนำบุคคลออกจากรายการเฝ้าระวัง: %w", err)
+		}
+
 		// ลบข้อมูลบุคคล
 		result := tx.Where("person_hash = ? AND organization_id = ?", personHash, organizationID).
 			Delete(&models.Person{})
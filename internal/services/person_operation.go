@@ -0,0 +1,753 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// resolvePersonHash คืน person_hash ที่ hash ถูกรวมเข้าไป หรือ hash เดิมถ้าไม่ใช่ชื่อแฝง
+func resolvePersonHash(ctx context.Context, tx *gorm.DB, organizationID, personHash string) (string, error) {
+	var alias models.PersonAlias
+	result := tx.WithContext(ctx).
+		Where("organization_id = ? AND alias_hash = ?", organizationID, personHash).
+		First(&alias)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return personHash, nil
+		}
+		return "", fmt.Errorf("ไม่สามารถตรวจสอบชื่อแฝงของบุคคล: %w", result.Error)
+	}
+	return alias.PersonHash, nil
+}
+
+// ResolvePersonHash คืน person_hash ปัจจุบันของ hash ที่อุปกรณ์ส่งมา โดยแปลงชื่อแฝงจากการรวมบุคคล
+func (s *PersonService) ResolvePersonHash(ctx context.Context, organizationID, personHash string) (string, error) {
+	return resolvePersonHash(ctx, s.DB.DB, organizationID, personHash)
+}
+
+// splitSelection ตรวจสอบ logs ที่เลือกแยกว่าเป็นของบุคคลเดิมทั้งหมดและไม่ใช่ทุกรายการ แล้วคืนรายการที่ไม่ซ้ำกัน
+func splitSelection(owned, requested []string) ([]string, error) {
+	selected := make([]string, 0, len(requested))
+	for _, id := range requested {
+		if slices.Contains(selected, id) {
+			continue
+		}
+		if !slices.Contains(owned, id) {
+			return nil, fmt.Errorf("พบ log ที่ไม่ใช่ของบุคคลนี้")
+		}
+		selected = append(selected, id)
+	}
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("ต้องระบุ logs ที่ต้องการแยก")
+	}
+	if len(selected) == len(owned) {
+		return nil, fmt.Errorf("ต้องเหลือ log อย่างน้อยหนึ่งรายการกับบุคคลเดิม")
+	}
+	return selected, nil
+}
+
+// lockPerson ดึงและล็อกแถวของบุคคลไว้จนจบ transaction
+func lockPerson(tx *gorm.DB, organizationID, personHash string) (*models.Person, error) {
+	var person models.Person
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("person_hash = ? AND organization_id = ?", personHash, organizationID).
+		First(&person).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("ไม่พบข้อมูลบุคคล")
+		}
+		return nil, fmt.Errorf("ไม่สามารถดึงข้อมูลบุคคล: %w", err)
+	}
+	return &person, nil
+}
+
+// recomputePerson คำนวณ first_seen, last_seen และ visit_count ของบุคคลใหม่จาก person_logs
+// และเลือกรูปภาพหลักใหม่จากรูปภาพใบหน้าที่เป็นของบุคคลในตอนนี้
+func recomputePerson(tx *gorm.DB, organizationID, personHash string) error {
+	if err := tx.Exec(`
+		UPDATE persons p
+		SET first_seen = agg.first_seen, last_seen = agg.last_seen, visit_count = agg.visits, updated_at = NOW()
+		FROM (
+			SELECT MIN(timestamp) AS first_seen, MAX(timestamp) AS last_seen, COUNT(*) AS visits
+			FROM person_logs
+			WHERE organization_id = @org_id AND person_hash = @person_hash AND deleted_at IS NULL
+		) agg
+		WHERE p.organization_id = @org_id AND p.person_hash = @person_hash AND agg.visits > 0
+	`, map[string]interface{}{
+		"org_id":      organizationID,
+		"person_hash": personHash,
+	}).Error; err != nil {
+		return fmt.Errorf("ไม่สามารถคำนวณข้อมูลบุคคลใหม่: %w", err)
+	}
+	return selectPrimaryFaceImage(tx, organizationID, personHash)
+}
+
+// recomputeNewPersonFlags กำหนดให้ log แรกของแต่ละบุคคลเป็นคนใหม่ และ log อื่นเป็นคนซ้ำ
+func recomputeNewPersonFlags(tx *gorm.DB, organizationID string, personHashes []string) error {
+	if err := tx.Exec(`
+		UPDATE person_logs l
+		SET is_new_person = (l.id = first_log.id)
+		FROM (
+			SELECT DISTINCT ON (person_hash) person_hash, id
+			FROM person_logs
+			WHERE organization_id = @org_id AND person_hash IN @person_hashes AND deleted_at IS NULL
+			ORDER BY person_hash, timestamp, id
+		) first_log
+		WHERE l.organization_id = @org_id AND l.person_hash = first_log.person_hash AND l.deleted_at IS NULL
+	`, map[string]interface{}{
+		"org_id":        organizationID,
+		"person_hashes": personHashes,
+	}).Error; err != nil {
+		return fmt.Errorf("ไม่สามารถคำนวณสถานะคนใหม่ของ logs: %w", err)
+	}
+	return nil
+}
+
+// restoreNewPersonFlags คืนสถานะคนใหม่ของ logs ของบุคคลให้เหมือนก่อนการดำเนินการ
+func restoreNewPersonFlags(tx *gorm.DB, organizationID string, personHashes, newPersonLogIDs []string) error {
+	if err := tx.Model(&models.PersonLog{}).
+		Where("organization_id = ? AND person_hash IN ?", organizationID, personHashes).
+		Update("is_new_person", false).Error; err != nil {
+		return fmt.Errorf("ไม่สามารถคืนสถานะคนใหม่ของ logs: %w", err)
+	}
+	if len(newPersonLogIDs) > 0 {
+		if err := tx.Model(&models.PersonLog{}).
+			Where("organization_id = ? AND id IN ?", organizationID, newPersonLogIDs).
+			Update("is_new_person", true).Error; err != nil {
+			return fmt.Errorf("ไม่สามารถคืนสถานะคนใหม่ของ logs: %w", err)
+		}
+	}
+	return nil
+}
+
+// operationsChanged ล้าง cache สถิติหลังรวมหรือแยกบุคคล เพราะจำนวนคนใหม่และคนซ้ำเปลี่ยน
+func (s *PersonService) operationsChanged(ctx context.Context, organizationID string) {
+	if s.Stats == nil {
+		return
+	}
+	if err := s.Stats.InvalidateOrganizationCache(ctx, organizationID); err != nil {
+		log.Printf("ไม่สามารถล้าง cache สถิติขององค์กร %s: %v", organizationID, err)
+	}
+}
+
+// MergePersons รวมบุคคล sourceHash เข้ากับ targetHash โดยย้าย logs รูปภาพ ป้ายกำกับ บันทึก และรายการเฝ้าระวังทั้งหมด
+// และให้การตรวจจับของ sourceHash ในอนาคตถูกนับเป็น targetHash
+func (s *PersonService) MergePersons(ctx context.Context, organizationID, targetHash, sourceHash, reason, performedBy string) (*models.PersonOperation, error) {
+	if sourceHash == "" {
+		return nil, fmt.Errorf("ต้องระบุรหัสบุคคลที่ต้องการรวม")
+	}
+	if sourceHash == targetHash {
+		return nil, fmt.Errorf("ไม่สามารถรวมบุคคลเข้ากับตัวเอง")
+	}
+
+	var operation *models.PersonOperation
+	err := s.DB.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if _, err := lockPerson(tx, organizationID, targetHash); err != nil {
+			return err
+		}
+		source, err := lockPerson(tx, organizationID, sourceHash)
+		if err != nil {
+			return err
+		}
+
+		// บันทึกสิ่งที่จะเปลี่ยนไว้สำหรับยกเลิกการรวม
+		snapshot := models.PersonOperationSnapshot{SourcePersonID: source.ID}
+		scoped := func(model interface{}) *gorm.DB {
+			return tx.Model(model).Where("organization_id = ? AND person_hash = ?", organizationID, sourceHash)
+		}
+		if err := scoped(&models.PersonLog{}).Pluck("id", &snapshot.LogIDs).Error; err != nil {
+			return fmt.Errorf("ไม่สามารถดึง logs ของบุคคล: %w", err)
+		}
+		if err := scoped(&models.FaceImage{}).Pluck("id", &snapshot.FaceImageIDs).Error; err != nil {
+			return fmt.Errorf("ไม่สามารถดึงรูปภาพใบหน้าของบุคคล: %w", err)
+		}
+		if err := scoped(&models.PersonNote{}).Pluck("id", &snapshot.NoteIDs).Error; err != nil {
+			return fmt.Errorf("ไม่สามารถดึงบันทึกของบุคคล: %w", err)
+		}
+		if err := tx.Model(&models.PersonAlias{}).
+			Where("organization_id = ? AND person_hash = ?", organizationID, sourceHash).
+			Pluck("id", &snapshot.AliasIDs).Error; err != nil {
+			return fmt.Errorf("ไม่สามารถดึงชื่อแฝงของบุคคล: %w", err)
+		}
+		if err := tx.Model(&models.PersonLog{}).
+			Where("organization_id = ? AND person_hash IN ? AND is_new_person = ?", organizationID, []string{targetHash, sourceHash}, true).
+			Pluck("id", &snapshot.NewPersonLogIDs).Error; err != nil {
+			return fmt.Errorf("ไม่สามารถดึงสถานะคนใหม่ของ logs: %w", err)
+		}
+
+		// ป้ายกำกับที่บุคคลปลายทางมีอยู่แล้วจะถูกลบ ส่วนที่เหลือย้ายไปยังบุคคลปลายทาง
+		var sourceLabels []models.PersonLabel
+		if err := scoped(&models.PersonLabel{}).Find(&sourceLabels).Error; err != nil {
+			return fmt.Errorf("ไม่สามารถดึงป้ายกำกับของบุคคล: %w", err)
+		}
+		var targetLabels []string
+		if err := tx.Model(&models.PersonLabel{}).
+			Where("organization_id = ? AND person_hash = ?", organizationID, targetHash).
+			Pluck("label", &targetLabels).Error; err != nil {
+			return fmt.Errorf("ไม่สามารถดึงป้ายกำกับของบุคคล: %w", err)
+		}
+		var droppedLabelIDs []string
+		for _, label := range sourceLabels {
+			if slices.Contains(targetLabels, label.Label) {
+				snapshot.DroppedLabels = append(snapshot.DroppedLabels, label)
+				droppedLabelIDs = append(droppedLabelIDs, label.ID)
+			} else {
+				snapshot.LabelIDs = append(snapshot.LabelIDs, label.ID)
+			}
+		}
+
+		// ย้ายข้อมูลทั้งหมดไปยังบุคคลปลายทาง
+		if err := scoped(&models.PersonLog{}).Update("person_hash", targetHash).Error; err != nil {
+			return fmt.Errorf("ไม่สามารถย้าย logs: %w", err)
+		}
+		if err := scoped(&models.FaceImage{}).Update("person_hash", targetHash).Error; err != nil {
+			return fmt.Errorf("ไม่สามารถย้ายรูปภาพใบหน้า: %w", err)
+		}
+		if err := scoped(&models.PersonNote{}).Update("person_hash", targetHash).Error; err != nil {
+			return fmt.Errorf("ไม่สามารถย้ายบันทึก: %w", err)
+		}
+		if len(droppedLabelIDs) > 0 {
+			if err := tx.Unscoped().Where("id IN ?", droppedLabelIDs).Delete(&models.PersonLabel{}).Error; err != nil {
+				return fmt.Errorf("ไม่สามารถลบป้ายกำกับที่ซ้ำกัน: %w", err)
+			}
+		}
+		if err := scoped(&models.PersonLabel{}).Update("person_hash", targetHash).Error; err != nil {
+			return fmt.Errorf("ไม่สามารถย้ายป้ายกำกับ: %w", err)
+		}
+		if err := mergeWatchlistEntries(tx, organizationID, targetHash, sourceHash, &snapshot); err != nil {
+			return err
+		}
+
+		// ชื่อแฝงเดิมของบุคคลต้นทางชี้ไปยังบุคคลปลายทางโดยตรง
+		if err := tx.Model(&models.PersonAlias{}).
+			Where("organization_id = ? AND person_hash = ?", organizationID, sourceHash).
+			Update("person_hash", targetHash).Error; err != nil {
+			return fmt.Errorf("ไม่สามารถย้ายชื่อแฝงของบุคคล: %w", err)
+		}
+
+		if err := tx.Delete(source).Error; err != nil {
+			return fmt.Errorf("ไม่สามารถลบบุคคลที่ถูกรวม: %w", err)
+		}
+		if err := recomputeNewPersonFlags(tx, organizationID, []string{targetHash}); err != nil {
+			return err
+		}
+		if err := recomputePerson(tx, organizationID, targetHash); err != nil {
+			return err
+		}
+
+		encoded, err := json.Marshal(snapshot)
+		if err != nil {
+			return fmt.Errorf("ไม่สามารถแปลงข้อมูลสำหรับยกเลิกการรวม: %w", err)
+		}
+		operation = &models.PersonOperation{
+			Base: models.Base{
+				ID: uuid.New().String(),
+			},
+			OrganizationID: organizationID,
+			Type:           models.PersonOperationMerge,
+			Status:         models.PersonOperationApplied,
+			SourceHash:     sourceHash,
+			TargetHash:     targetHash,
+			LogCount:       len(snapshot.LogIDs),
+			FaceImageCount: len(snapshot.FaceImageIDs),
+			Reason:         reason,
+			PerformedBy:    performedBy,
+			Snapshot:       encoded,
+		}
+		if err := tx.Create(operation).Error; err != nil {
+			return fmt.Errorf("ไม่สามารถบันทึกประวัติการรวมบุคคล: %w", err)
+		}
+
+		alias := models.PersonAlias{
+			Base: models.Base{
+				ID: uuid.New().String(),
+			},
+			OrganizationID: organizationID,
+			AliasHash:      sourceHash,
+			PersonHash:     targetHash,
+			OperationID:    operation.ID,
+		}
+		if err := tx.Create(&alias).Error; err != nil {
+			return fmt.Errorf("ไม่สามารถบันทึกชื่อแฝงของบุคคล: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.operationsChanged(ctx, organizationID)
+	return operation, nil
+}
+
+// mergeWatchlistEntries ย้ายรายการเฝ้าระวังของ sourceHash ไปยัง targetHash และบันทึกสิ่งที่เปลี่ยนใน snapshot
+// รายการเฝ้าระวังหนึ่งมีบุคคลได้ครั้งเดียว (idx_watchlist_entry) ถ้าบุคคลปลายทางอยู่ในรายการนั้นแล้วจึงใช้รายการของบุคคลปลายทาง
+func mergeWatchlistEntries(tx *gorm.DB, organizationID, targetHash, sourceHash string, snapshot *models.PersonOperationSnapshot) error {
+	var sourceEntries []models.WatchlistEntry
+	if err := tx.Where("organization_id = ? AND person_hash = ?", organizationID, sourceHash).Find(&sourceEntries).Error; err != nil {
+		return fmt.Errorf("ไม่สามารถดึงรายการเฝ้าระวังของบุคคล: %w", err)
+	}
+	if len(sourceEntries) == 0 {
+		return nil
+	}
+	var targetWatchlistIDs []string
+	if err := tx.Model(&models.WatchlistEntry{}).
+		Where("organization_id = ? AND person_hash = ?", organizationID, targetHash).
+		Pluck("watchlist_id", &targetWatchlistIDs).Error; err != nil {
+		return fmt.Errorf("ไม่สามารถดึงรายการเฝ้าระวังของบุคคล: %w", err)
+	}
+
+	var droppedIDs []string
+	for _, entry := range sourceEntries {
+		if slices.Contains(targetWatchlistIDs, entry.WatchlistID) {
+			snapshot.DroppedWatchlistEntries = append(snapshot.DroppedWatchlistEntries, entry)
+			droppedIDs = append(droppedIDs, entry.ID)
+		} else {
+			snapshot.WatchlistEntryIDs = append(snapshot.WatchlistEntryIDs, entry.ID)
+		}
+	}
+	if len(droppedIDs) > 0 {
+		if err := tx.Unscoped().Where("id IN ?", droppedIDs).Delete(&models.WatchlistEntry{}).Error; err != nil {
+			return fmt.Errorf("ไม่สามารถลบรายการเฝ้าระวังที่ซ้ำกัน: %w", err)
+		}
+	}
+	if len(snapshot.WatchlistEntryIDs) > 0 {
+		if err := tx.Model(&models.WatchlistEntry{}).
+			Where("id IN ?", snapshot.WatchlistEntryIDs).
+			Update("person_hash", targetHash).Error; err != nil {
+			return fmt.Errorf("ไม่สามารถย้ายรายการเฝ้าระวัง: %w", err)
+		}
+	}
+	return nil
+}
+
+// restoreWatchlistEntries คืนรายการเฝ้าระวังที่ย้ายหรือลบตอนรวมบุคคลให้ sourceHash
+func restoreWatchlistEntries(tx *gorm.DB, organizationID, sourceHash string, snapshot *models.PersonOperationSnapshot) error {
+	if len(snapshot.WatchlistEntryIDs) > 0 {
+		if err := tx.Model(&models.WatchlistEntry{}).
+			Where("organization_id = ? AND id IN ?", organizationID, snapshot.WatchlistEntryIDs).
+			Update("person_hash", sourceHash).Error; err != nil {
+			return fmt.Errorf("ไม่สามารถคืนรายการเฝ้าระวัง: %w", err)
+		}
+	}
+	for _, entry := range snapshot.DroppedWatchlistEntries {
+		entry := entry
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&entry).Error; err != nil {
+			return fmt.Errorf("ไม่สามารถคืนรายการเฝ้าระวัง: %w", err)
+		}
+	}
+	return nil
+}
+
+// SplitPerson แยก logs และรูปภาพใบหน้าที่เลือกของบุคคลไปเป็นบุคคลใหม่ ถ้าไม่ระบุ newHash จะสร้างรหัสใหม่ให้
+func (s *PersonService) SplitPerson(ctx context.Context, organizationID, sourceHash string, logIDs, faceImageIDs []string, newHash, reason, performedBy string) (*models.PersonOperation, error) {
+	if len(logIDs) == 0 {
+		return nil, fmt.Errorf("ต้องระบุ logs ที่ต้องการแยก")
+	}
+	if newHash == "" {
+		newHash = uuid.New().String()
+	}
+
+	var operation *models.PersonOperation
+	err := s.DB.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if _, err := lockPerson(tx, organizationID, sourceHash); err != nil {
+			return err
+		}
+
+		// รหัสใหม่ต้องไม่ซ้ำกับบุคคลหรือชื่อแฝงที่มีอยู่ในองค์กร (รวมถึงบุคคลที่ถูกรวมไปแล้ว)
+		var existing int64
+		if err := tx.Unscoped().Model(&models.Person{}).
+			Where("organization_id = ? AND person_hash = ?", organizationID, newHash).
+			Count(&existing).Error; err != nil {
+			return fmt.Errorf("ไม่สามารถตรวจสอบรหัสบุคคลใหม่: %w", err)
+		}
+		if existing == 0 {
+			if err := tx.Model(&models.PersonAlias{}).
+				Where("organization_id = ? AND alias_hash = ?", organizationID, newHash).
+				Count(&existing).Error; err != nil {
+				return fmt.Errorf("ไม่สามารถตรวจสอบรหัสบุคคลใหม่: %w", err)
+			}
+		}
+		if existing > 0 {
+			return fmt.Errorf("รหัสบุคคลใหม่ถูกใช้แล้ว")
+		}
+
+		var ownedLogIDs, ownedImageIDs []string
+		if err := tx.Model(&models.PersonLog{}).
+			Where("organization_id = ? AND person_hash = ?", organizationID, sourceHash).
+			Pluck("id", &ownedLogIDs).Error; err != nil {
+			return fmt.Errorf("ไม่สามารถดึง logs ของบุคคล: %w", err)
+		}
+		selectedLogs, err := splitSelection(ownedLogIDs, logIDs)
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Model(&models.FaceImage{}).
+			Where("organization_id = ? AND person_hash = ?", organizationID, sourceHash).
+			Pluck("id", &ownedImageIDs).Error; err != nil {
+			return fmt.Errorf("ไม่สามารถดึงรูปภาพใบหน้าของบุคคล: %w", err)
+		}
+		selectedImages := make([]string, 0, len(faceImageIDs))
+		for _, id := range faceImageIDs {
+			if !slices.Contains(ownedImageIDs, id) {
+				return fmt.Errorf("พบรูปภาพใบหน้าที่ไม่ใช่ของบุคคลนี้")
+			}
+			if !slices.Contains(selectedImages, id) {
+				selectedImages = append(selectedImages, id)
+			}
+		}
+
+		snapshot := models.PersonOperationSnapshot{
+			LogIDs:       selectedLogs,
+			FaceImageIDs: selectedImages,
+		}
+		if err := tx.Model(&models.PersonLog{}).
+			Where("organization_id = ? AND person_hash = ? AND is_new_person = ?", organizationID, sourceHash, true).
+			Pluck("id", &snapshot.NewPersonLogIDs).Error; err != nil {
+			return fmt.Errorf("ไม่สามารถดึงสถานะคนใหม่ของ logs: %w", err)
+		}
+
+		// สร้างบุคคลใหม่ แล้วคำนวณเวลาที่พบจาก logs ที่ย้ายมา
+		now := time.Now()
+		person := models.Person{
+			Base: models.Base{
+				ID: uuid.New().String(),
+			},
+			PersonHash:     newHash,
+			FirstSeen:      now,
+			LastSeen:       now,
+			OrganizationID: organizationID,
+		}
+		if err := tx.Create(&person).Error; err != nil {
+			return fmt.Errorf("ไม่สามารถสร้างบุคคลใหม่: %w", err)
+		}
+
+		if err := tx.Model(&models.PersonLog{}).
+			Where("organization_id = ? AND id IN ?", organizationID, selectedLogs).
+			Update("person_hash", newHash).Error; err != nil {
+			return fmt.Errorf("ไม่สามารถย้าย logs: %w", err)
+		}
+		if len(selectedImages) > 0 {
+			if err := tx.Model(&models.FaceImage{}).
+				Where("organization_id = ? AND id IN ?", organizationID, selectedImages).
+				Update("person_hash", newHash).Error; err != nil {
+				return fmt.Errorf("ไม่สามารถย้ายรูปภาพใบหน้า: %w", err)
+			}
+		}
+
+		if err := recomputeNewPersonFlags(tx, organizationID, []string{sourceHash, newHash}); err != nil {
+			return err
+		}
+		for _, hash := range []string{sourceHash, newHash} {
+			if err := recomputePerson(tx, organizationID, hash); err != nil {
+				return err
+			}
+		}
+
+		encoded, err := json.Marshal(snapshot)
+		if err != nil {
+			return fmt.Errorf("ไม่สามารถแปลงข้อมูลสำหรับยกเลิกการแยก: %w", err)
+		}
+		operation = &models.PersonOperation{
+			Base: models.Base{
+				ID: uuid.New().String(),
+			},
+			OrganizationID: organizationID,
+			Type:           models.PersonOperationSplit,
+			Status:         models.PersonOperationApplied,
+			SourceHash:     sourceHash,
+			TargetHash:     newHash,
+			LogCount:       len(selectedLogs),
+			FaceImageCount: len(selectedImages),
+			Reason:         reason,
+			PerformedBy:    performedBy,
+			Snapshot:       encoded,
+		}
+		if err := tx.Create(operation).Error; err != nil {
+			return fmt.Errorf("ไม่สามารถบันทึกประวัติการแยกบุคคล: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.operationsChanged(ctx, organizationID)
+	return operation, nil
+}
+
+// GetOperation ดึงประวัติการรวมหรือแยกบุคคลตาม ID
+func (s *PersonService) GetOperation(ctx context.Context, id, organizationID string) (*models.PersonOperation, error) {
+	var operation models.PersonOperation
+	if err := s.DB.DB.WithContext(ctx).
+		Where("id = ? AND organization_id = ?", id, organizationID).
+		First(&operation).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("ไม่พบการดำเนินการ")
+		}
+		return nil, fmt.Errorf("ไม่สามารถดึงการดำเนินการ: %w", err)
+	}
+	return &operation, nil
+}
+
+// personOperationListSpec กำหนดการเรียงลำดับของประวัติการรวมและแยกบุคคล
+var personOperationListSpec = listSpec[models.PersonOperation]{
+	Sorts: map[string]sortField{
+		"created_at": {Column: "created_at", Kind: sortKindTime},
+	},
+	DefaultSort:  "created_at",
+	DefaultOrder: "desc",
+	Fields: map[string]string{
+		"id":               "id",
+		"created_at":       "created_at",
+		"updated_at":       "updated_at",
+		"organization_id":  "organization_id",
+		"type":             "type",
+		"status":           "status",
+		"source_hash":      "source_hash",
+		"target_hash":      "target_hash",
+		"log_count":        "log_count",
+		"face_image_count": "face_image_count",
+		"reason":           "reason",
+		"performed_by":     "performed_by",
+		"undone_by":        "undone_by",
+		"undone_at":        "undone_at",
+		"snapshot":         "snapshot",
+	},
+	Key: func(operation models.PersonOperation, sort string) (interface{}, string) {
+		return operation.CreatedAt, operation.ID
+	},
+}
+
+// ListOperations ดึงประวัติการรวมและแยกบุคคลขององค์กร หรือเฉพาะที่เกี่ยวข้องกับ personHash ถ้าระบุ
+func (s *PersonService) ListOperations(ctx context.Context, organizationID, personHash string, opts models.ListOptions) ([]models.PersonOperation, *models.CursorPagination, error) {
+	query := s.DB.DB.WithContext(ctx).Model(&models.PersonOperation{}).Where("organization_id = ?", organizationID)
+	if personHash != "" {
+		query = query.Where("(source_hash = ? OR target_hash = ?)", personHash, personHash)
+	}
+
+	operations, pagination, err := paginate(query, personOperationListSpec, opts)
+	if err != nil {
+		if errors.Is(err, ErrInvalidListOptions) {
+			return nil, nil, err
+		}
+		return nil, nil, fmt.Errorf("ไม่สามารถดึงประวัติการรวมและแยกบุคคล: %w", err)
+	}
+
+	return operations, pagination, nil
+}
+
+// UndoOperation ยกเลิกการรวมหรือแยกบุคคล โดยต้องยกเลิกการดำเนินการที่ใหม่กว่าของบุคคลเดียวกันก่อน
+func (s *PersonService) UndoOperation(ctx context.Context, id, organizationID, undoneBy string) (*models.PersonOperation, error) {
+	var operation models.PersonOperation
+	err := s.DB.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND organization_id = ?", id, organizationID).
+			First(&operation).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return fmt.Errorf("ไม่พบการดำเนินการ")
+			}
+			return fmt.Errorf("ไม่สามารถดึงการดำเนินการ: %w", err)
+		}
+		if operation.Status == models.PersonOperationUndone {
+			return fmt.Errorf("การดำเนินการนี้ถูกยกเลิกแล้ว")
+		}
+
+		hashes := []string{operation.SourceHash, operation.TargetHash}
+		var newer int64
+		if err := tx.Model(&models.PersonOperation{}).
+			Where("organization_id = ? AND status = ? AND id <> ? AND created_at > ?", organizationID, models.PersonOperationApplied, operation.ID, operation.CreatedAt).
+			Where("(source_hash IN ? OR target_hash IN ?)", hashes, hashes).
+			Count(&newer).Error; err != nil {
+			return fmt.Errorf("ไม่สามารถตรวจสอบการดำเนินการที่ใหม่กว่า: %w", err)
+		}
+		if newer > 0 {
+			return fmt.Errorf("ต้องยกเลิกการดำเนินการที่ใหม่กว่าของบุคคลเดียวกันก่อน")
+		}
+
+		var snapshot models.PersonOperationSnapshot
+		if err := json.Unmarshal(operation.Snapshot, &snapshot); err != nil {
+			return fmt.Errorf("ไม่สามารถอ่านข้อมูลสำหรับยกเลิกการดำเนินการ: %w", err)
+		}
+
+		switch operation.Type {
+		case models.PersonOperationMerge:
+			if err := undoMerge(tx, &operation, &snapshot); err != nil {
+				return err
+			}
+		case models.PersonOperationSplit:
+			if err := undoSplit(tx, &operation, &snapshot); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("ไม่รู้จักประเภทการดำเนินการ %s", operation.Type)
+		}
+
+		now := time.Now()
+		operation.Status = models.PersonOperationUndone
+		operation.UndoneBy = undoneBy
+		operation.UndoneAt = &now
+		if err := tx.Model(&operation).Updates(map[string]interface{}{
+			"status":    operation.Status,
+			"undone_by": undoneBy,
+			"undone_at": now,
+		}).Error; err != nil {
+			return fmt.Errorf("ไม่สามารถบันทึกการยกเลิกการดำเนินการ: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.operationsChanged(ctx, organizationID)
+	return &operation, nil
+}
+
+// undoMerge คืนบุคคลที่ถูกรวมพร้อม logs รูปภาพ ป้ายกำกับ บันทึก และรายการเฝ้าระวังของบุคคลนั้น
+// รวมถึงการตรวจจับที่เข้ามาด้วยชื่อแฝงหลังการรวม
+func undoMerge(tx *gorm.DB, operation *models.PersonOperation, snapshot *models.PersonOperationSnapshot) error {
+	organizationID, sourceHash, targetHash := operation.OrganizationID, operation.SourceHash, operation.TargetHash
+
+	if _, err := lockPerson(tx, organizationID, targetHash); err != nil {
+		return err
+	}
+	if err := tx.Unscoped().Model(&models.Person{}).
+		Where("id = ?", snapshot.SourcePersonID).
+		Update("deleted_at", nil).Error; err != nil {
+		return fmt.Errorf("ไม่สามารถคืนบุคคลที่ถูกรวม: %w", err)
+	}
+
+	// การตรวจจับที่เข้ามาหลังการรวมด้วย hash ของบุคคลต้นทางหรือชื่อแฝงเดิมของบุคคลต้นทางต้องย้ายกลับด้วย
+	reportedHashes := []string{sourceHash}
+	if len(snapshot.AliasIDs) > 0 {
+		var aliasHashes []string
+		if err := tx.Model(&models.PersonAlias{}).Where("id IN ?", snapshot.AliasIDs).Pluck("alias_hash", &aliasHashes).Error; err != nil {
+			return fmt.Errorf("ไม่สามารถดึงชื่อแฝงของบุคคล: %w", err)
+		}
+		reportedHashes = append(reportedHashes, aliasHashes...)
+	}
+	moved := func(model interface{}, ids []string) *gorm.DB {
+		return tx.Model(model).
+			Where("organization_id = ? AND person_hash = ?", organizationID, targetHash).
+			Where("(id IN ? OR reported_hash IN ?)", ids, reportedHashes)
+	}
+	if err := moved(&models.PersonLog{}, snapshot.LogIDs).Updates(map[string]interface{}{
+		"person_hash":   sourceHash,
+		"reported_hash": gorm.Expr("CASE WHEN reported_hash = ? THEN '' ELSE reported_hash END", sourceHash),
+	}).Error; err != nil {
+		return fmt.Errorf("ไม่สามารถคืน logs: %w", err)
+	}
+	if err := moved(&models.FaceImage{}, snapshot.FaceImageIDs).Updates(map[string]interface{}{
+		"person_hash":   sourceHash,
+		"reported_hash": gorm.Expr("CASE WHEN reported_hash = ? THEN '' ELSE reported_hash END", sourceHash),
+	}).Error; err != nil {
+		return fmt.Errorf("ไม่สามารถคืนรูปภาพใบหน้า: %w", err)
+	}
+	if len(snapshot.NoteIDs) > 0 {
+		if err := tx.Model(&models.PersonNote{}).
+			Where("organization_id = ? AND id IN ?", organizationID, snapshot.NoteIDs).
+			Update("person_hash", sourceHash).Error; err != nil {
+			return fmt.Errorf("ไม่สามารถคืนบันทึก: %w", err)
+		}
+	}
+	if len(snapshot.LabelIDs) > 0 {
+		if err := tx.Model(&models.PersonLabel{}).
+			Where("organization_id = ? AND id IN ?", organizationID, snapshot.LabelIDs).
+			Update("person_hash", sourceHash).Error; err != nil {
+			return fmt.Errorf("ไม่สามารถคืนป้ายกำกับ: %w", err)
+		}
+	}
+	for _, label := range snapshot.DroppedLabels {
+		label := label
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&label).Error; err != nil {
+			return fmt.Errorf("ไม่สามารถคืนป้ายกำกับ: %w", err)
+		}
+	}
+	if err := restoreWatchlistEntries(tx, organizationID, sourceHash, snapshot); err != nil {
+		return err
+	}
+
+	// ลบชื่อแฝงที่สร้างจากการรวม และคืนชื่อแฝงเดิมให้บุคคลต้นทาง
+	if err := tx.Unscoped().Where("operation_id = ?", operation.ID).Delete(&models.PersonAlias{}).Error; err != nil {
+		return fmt.Errorf("ไม่สามารถลบชื่อแฝงของบุคคล: %w", err)
+	}
+	if len(snapshot.AliasIDs) > 0 {
+		if err := tx.Model(&models.PersonAlias{}).
+			Where("organization_id = ? AND id IN ?", organizationID, snapshot.AliasIDs).
+			Update("person_hash", sourceHash).Error; err != nil {
+			return fmt.Errorf("ไม่สามารถคืนชื่อแฝงของบุคคล: %w", err)
+		}
+	}
+
+	hashes := []string{sourceHash, targetHash}
+	if err := restoreNewPersonFlags(tx, organizationID, hashes, snapshot.NewPersonLogIDs); err != nil {
+		return err
+	}
+	for _, hash := range hashes {
+		if err := recomputePerson(tx, organizationID, hash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// undoSplit คืน logs และรูปภาพของบุคคลที่แยกออกไปให้บุคคลเดิม แล้วลบบุคคลที่สร้างจากการแยก
+func undoSplit(tx *gorm.DB, operation *models.PersonOperation, snapshot *models.PersonOperationSnapshot) error {
+	organizationID, sourceHash, newHash := operation.OrganizationID, operation.SourceHash, operation.TargetHash
+
+	if _, err := lockPerson(tx, organizationID, sourceHash); err != nil {
+		return err
+	}
+	split, err := lockPerson(tx, organizationID, newHash)
+	if err != nil {
+		return err
+	}
+
+	scoped := func(model interface{}) *gorm.DB {
+		return tx.Model(model).Where("organization_id = ? AND person_hash = ?", organizationID, newHash)
+	}
+	if err := scoped(&models.PersonLog{}).Update("person_hash", sourceHash).Error; err != nil {
+		return fmt.Errorf("ไม่สามารถคืน logs: %w", err)
+	}
+	if err := scoped(&models.FaceImage{}).Update("person_hash", sourceHash).Error; err != nil {
+		return fmt.Errorf("ไม่สามารถคืนรูปภาพใบหน้า: %w", err)
+	}
+	if err := scoped(&models.PersonNote{}).Update("person_hash", sourceHash).Error; err != nil {
+		return fmt.Errorf("ไม่สามารถคืนบันทึก: %w", err)
+	}
+
+	// ป้ายกำกับที่ติดให้บุคคลใหม่หลังการแยกจะย้ายไปยังบุคคลเดิม ถ้าบุคคลเดิมยังไม่มี
+	if err := tx.Unscoped().
+		Where("organization_id = ? AND person_hash = ?", organizationID, newHash).
+		Where("label IN (?)", tx.Model(&models.PersonLabel{}).Select("label").Where("organization_id = ? AND person_hash = ?", organizationID, sourceHash)).
+		Delete(&models.PersonLabel{}).Error; err != nil {
+		return fmt.Errorf("ไม่สามารถลบป้ายกำกับที่ซ้ำกัน: %w", err)
+	}
+	if err := scoped(&models.PersonLabel{}).Update("person_hash", sourceHash).Error; err != nil {
+		return fmt.Errorf("ไม่สามารถคืนป้ายกำกับ: %w", err)
+	}
+
+	// ลบถาวรเพื่อให้รหัสที่สร้างจากการแยกใช้ใหม่ได้
+	if err := tx.Unscoped().Delete(split).Error; err != nil {
+		return fmt.Errorf("ไม่สามารถลบบุคคลที่สร้างจากการแยก: %w", err)
+	}
+
+	if err := restoreNewPersonFlags(tx, organizationID, []string{sourceHash}, snapshot.NewPersonLogIDs); err != nil {
+		return err
+	}
+	return recomputePerson(tx, organizationID, sourceHash)
+}
@@ -0,0 +1,72 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// TestSplitSelection ทดสอบการตรวจสอบ logs ที่เลือกแยกออกจากบุคคล
+func TestSplitSelection(t *testing.T) {
+	owned := []string{"log-1", "log-2", "log-3"}
+
+	selected, err := splitSelection(owned, []string{"log-2", "log-3", "log-2"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"log-2", "log-3"}, selected)
+
+	_, err = splitSelection(owned, []string{"log-1", "log-4"})
+	assert.EqualError(t, err, "พบ log ที่ไม่ใช่ของบุคคลนี้")
+
+	_, err = splitSelection(owned, nil)
+	assert.EqualError(t, err, "ต้องระบุ logs ที่ต้องการแยก")
+
+	// ห้ามย้ายทุก log เพราะบุคคลเดิมจะไม่เหลือข้อมูล
+	_, err = splitSelection(owned, []string{"log-3", "log-1", "log-2"})
+	assert.EqualError(t, err, "ต้องเหลือ log อย่างน้อยหนึ่งรายการกับบุคคลเดิม")
+}
+
+// TestMergeWatchlistEntries ทดสอบว่าการรวมบุคคลย้ายรายการเฝ้าระวังไปยังบุคคลปลายทาง รายการที่บุคคลปลายทางอยู่แล้วถูกลบ และการยกเลิกคืนทั้งสองแบบ
+func TestMergeWatchlistEntries(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { sqlDB.Close() })
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{Logger: logger.Discard, SkipDefaultTransaction: true})
+	require.NoError(t, err)
+
+	mock.ExpectQuery(`SELECT \* FROM "watchlist_entries" WHERE \(organization_id = \$1 AND person_hash = \$2\)`).
+		WithArgs("org-1", "source").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "organization_id", "watchlist_id", "person_hash", "priority"}).
+			AddRow("entry-1", "org-1", "list-1", "source", "high").
+			AddRow("entry-2", "org-1", "list-2", "source", "low"))
+	mock.ExpectQuery(`SELECT "watchlist_id" FROM "watchlist_entries" WHERE \(organization_id = \$1 AND person_hash = \$2\)`).
+		WithArgs("org-1", "target").
+		WillReturnRows(sqlmock.NewRows([]string{"watchlist_id"}).AddRow("list-2"))
+	mock.ExpectExec(`DELETE FROM "watchlist_entries" WHERE id IN \(\$1\)`).
+		WithArgs("entry-2").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE "watchlist_entries" SET "person_hash"=\$1,"updated_at"=\$2 WHERE id IN \(\$3\)`).
+		WithArgs("target", sqlmock.AnyArg(), "entry-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	var snapshot models.PersonOperationSnapshot
+	require.NoError(t, mergeWatchlistEntries(gormDB, "org-1", "target", "source", &snapshot))
+	assert.Equal(t, []string{"entry-1"}, snapshot.WatchlistEntryIDs)
+	require.Len(t, snapshot.DroppedWatchlistEntries, 1)
+	assert.Equal(t, "entry-2", snapshot.DroppedWatchlistEntries[0].ID)
+	assert.Equal(t, "low", snapshot.DroppedWatchlistEntries[0].Priority)
+
+	mock.ExpectExec(`UPDATE "watchlist_entries" SET "person_hash"=\$1,"updated_at"=\$2 WHERE \(organization_id = \$3 AND id IN \(\$4\)\)`).
+		WithArgs("source", sqlmock.AnyArg(), "org-1", "entry-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO "watchlist_entries" .* ON CONFLICT DO NOTHING`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	require.NoError(t, restoreWatchlistEntries(gormDB, "org-1", "source", &snapshot))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,443 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/db"
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/models"
+	"gorm.io/gorm"
+)
+
+const (
+	// defaultRebuildChunkSize จำนวนบุคคลต่อ transaction ถ้าไม่ได้ระบุ
+	defaultRebuildChunkSize = 500
+	// maxRebuildChunkSize จำนวนบุคคลต่อ transaction สูงสุด
+	maxRebuildChunkSize = 5000
+	// maxRebuildChanges จำนวนรายการเปลี่ยนแปลงสูงสุดที่เก็บในผลลัพธ์ ส่วนจำนวนนับยังครบทุกรายการ
+	maxRebuildChanges = 1000
+	// rebuildStaleAfter งานที่สถานะกำลังทำงานแต่ไม่บันทึก checkpoint นานกว่านี้ถือว่าหยุดไปแล้ว (เช่น server ปิดระหว่างทำงาน)
+	rebuildStaleAfter = 10 * time.Minute
+)
+
+// rebuildPersonRow ค่าของบุคคลที่คำนวณจาก logs เทียบกับค่าที่บันทึกไว้
+type rebuildPersonRow struct {
+	PersonHash       string
+	FirstSeen        *time.Time
+	LastSeen         *time.Time
+	Visits           int
+	PersonID         *string
+	CurrentFirstSeen *time.Time
+	CurrentLastSeen  *time.Time
+	CurrentVisits    *int
+}
+
+// rebuildLogRow log ที่สถานะคนใหม่ไม่ตรงกับ log แรกของบุคคล
+type rebuildLogRow struct {
+	ID          string
+	PersonHash  string
+	IsNewPerson bool
+}
+
+// RebuildService ให้บริการสร้างข้อมูลที่คำนวณจาก person_logs ใหม่ทั้งหมด
+type RebuildService struct {
+	DB    *db.PostgresDB
+	Jobs  *JobService
+	Stats *StatsService
+}
+
+// NewRebuildService สร้าง RebuildService ใหม่ โดยใช้ StatsService (ถ้ามี) ล้าง cache สถิติเมื่อข้อมูลเปลี่ยน
+func NewRebuildService(postgres *db.PostgresDB, jobService *JobService, statsService *StatsService) *RebuildService {
+	return &RebuildService{
+		DB:    postgres,
+		Jobs:  jobService,
+		Stats: statsService,
+	}
+}
+
+// validateRebuildParams ตรวจสอบช่วงเวลาและกำหนดขนาด chunk เริ่มต้น
+func validateRebuildParams(params *models.RebuildParams) error {
+	if params.OrganizationID == "" {
+		return fmt.Errorf("ไม่พบข้อมูลองค์กร")
+	}
+	if params.From != nil && params.To != nil && !params.To.After(*params.From) {
+		return fmt.Errorf("to ต้องอยู่หลัง from")
+	}
+	if params.ChunkSize == 0 {
+		params.ChunkSize = defaultRebuildChunkSize
+	}
+	if params.ChunkSize < 0 || params.ChunkSize > maxRebuildChunkSize {
+		return fmt.Errorf("chunk_size ต้องอยู่ระหว่าง 1 ถึง %d", maxRebuildChunkSize)
+	}
+	return nil
+}
+
+// rebuildScope สร้าง query ของ person_hash ที่ต้องสร้างใหม่ คือบุคคลที่มี log ในช่วงเวลา
+// หรือบุคคลที่บันทึกไว้ว่าพบในช่วงเวลา (รวมถึงบุคคลที่ logs ถูกลบไปแล้ว)
+func rebuildScope(params models.RebuildParams) (string, map[string]interface{}) {
+	args := map[string]interface{}{"organization_id": params.OrganizationID}
+	logRange, personRange := "", ""
+	if params.From != nil {
+		logRange += " AND timestamp >= @from"
+		personRange += " AND last_seen >= @from"
+		args["from"] = *params.From
+	}
+	if params.To != nil {
+		logRange += " AND timestamp < @to"
+		personRange += " AND first_seen < @to"
+		args["to"] = *params.To
+	}
+
+	query := `
+		SELECT person_hash FROM person_logs
+		WHERE organization_id = @organization_id AND deleted_at IS NULL` + logRange + `
+		UNION
+		SELECT person_hash FROM persons
+		WHERE organization_id = @organization_id AND deleted_at IS NULL` + personRange
+	return query, args
+}
+
+// diffRebuildPerson เปรียบเทียบค่าที่บันทึกไว้ของบุคคลกับค่าที่คำนวณจาก logs
+// บุคคลที่ไม่มี log เหลืออยู่ยังคง first_seen และ last_seen เดิม แต่จำนวนการเข้าชมเป็น 0
+func diffRebuildPerson(row rebuildPersonRow) []models.RebuildChange {
+	if row.PersonID == nil {
+		if row.Visits == 0 {
+			return nil
+		}
+		return []models.RebuildChange{{PersonHash: row.PersonHash, Field: models.RebuildFieldPerson, Old: nil, New: row.Visits}}
+	}
+
+	var changes []models.RebuildChange
+	currentVisits := 0
+	if row.CurrentVisits != nil {
+		currentVisits = *row.CurrentVisits
+	}
+	if row.Visits > 0 {
+		if !sameTime(row.CurrentFirstSeen, row.FirstSeen) {
+			changes = append(changes, models.RebuildChange{PersonHash: row.PersonHash, Field: models.RebuildFieldFirstSeen, Old: row.CurrentFirstSeen, New: row.FirstSeen})
+		}
+		if !sameTime(row.CurrentLastSeen, row.LastSeen) {
+			changes = append(changes, models.RebuildChange{PersonHash: row.PersonHash, Field: models.RebuildFieldLastSeen, Old: row.CurrentLastSeen, New: row.LastSeen})
+		}
+	}
+	if currentVisits != row.Visits {
+		changes = append(changes, models.RebuildChange{PersonHash: row.PersonHash, Field: models.RebuildFieldVisitCount, Old: currentVisits, New: row.Visits})
+	}
+	return changes
+}
+
+// sameTime เปรียบเทียบเวลาที่อาจเป็น nil
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return a.Equal(*b)
+}
+
+// addRebuildChanges นับและเก็บรายการเปลี่ยนแปลง โดยเก็บไม่เกิน maxRebuildChanges รายการ
+func addRebuildChanges(result *models.RebuildResult, changes []models.RebuildChange) {
+	for _, change := range changes {
+		if change.Field == models.RebuildFieldIsNewPerson {
+			result.LogsUpdated++
+		}
+		if len(result.Changes) >= maxRebuildChanges {
+			result.ChangesTruncated = true
+			continue
+		}
+		result.Changes = append(result.Changes, change)
+	}
+}
+
+// CreateRebuildJob ตรวจสอบพารามิเตอร์และบันทึกงานสร้างข้อมูลใหม่ในสถานะรอดำเนินการ
+func (s *RebuildService) CreateRebuildJob(ctx context.Context, params models.RebuildParams) (*models.Job, error) {
+	if err := validateRebuildParams(&params); err != nil {
+		return nil, err
+	}
+	return s.Jobs.CreateJob(ctx, params.OrganizationID, models.JobTypeDerivedRebuild, params)
+}
+
+// StartRebuild สร้างงานสร้างข้อมูลใหม่และเริ่มทำงานเบื้องหลัง
+func (s *RebuildService) StartRebuild(ctx context.Context, params models.RebuildParams) (*models.Job, error) {
+	job, err := s.CreateRebuildJob(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	s.Jobs.Run(job, s.RunRebuildJob)
+	return job, nil
+}
+
+// GetRebuildJob ดึงงานสร้างข้อมูลใหม่ตาม ID
+func (s *RebuildService) GetRebuildJob(ctx context.Context, id, organizationID string) (*models.Job, error) {
+	job, err := s.Jobs.GetJob(ctx, id, organizationID)
+	if err != nil {
+		return nil, err
+	}
+	if job.Type != models.JobTypeDerivedRebuild {
+		return nil, fmt.Errorf("ไม่พบงาน")
+	}
+	return job, nil
+}
+
+// ClaimRebuildJob จองงานที่ล้มเหลวหรือหยุดค้างไว้เพื่อทำงานต่อจาก checkpoint
+// การจองเปลี่ยนสถานะแบบมีเงื่อนไข จึงมีผู้ทำงานต่อได้เพียงรายเดียว
+func (s *RebuildService) ClaimRebuildJob(ctx context.Context, id, organizationID string) (*models.Job, error) {
+	job, err := s.GetRebuildJob(ctx, id, organizationID)
+	if err != nil {
+		return nil, err
+	}
+
+	switch job.Status {
+	case models.JobCompleted:
+		return nil, fmt.Errorf("งานสร้างข้อมูลใหม่เสร็จแล้ว")
+	case models.JobPending, models.JobRunning:
+		if time.Since(job.UpdatedAt) < rebuildStaleAfter {
+			return nil, fmt.Errorf("งานสร้างข้อมูลใหม่กำลังทำงานอยู่")
+		}
+	}
+
+	result := s.DB.DB.WithContext(ctx).Model(&models.Job{}).
+		Where("id = ? AND status = ? AND updated_at = ?", job.ID, job.Status, job.UpdatedAt).
+		Updates(map[string]interface{}{"status": models.JobPending, "error": ""})
+	if result.Error != nil {
+		return nil, fmt.Errorf("ไม่สามารถจองงานสร้างข้อมูลใหม่: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, fmt.Errorf("งานสร้างข้อมูลใหม่กำลังทำงานอยู่")
+	}
+	job.Status = models.JobPending
+	job.Error = ""
+
+	return job, nil
+}
+
+// ResumeRebuild ทำงานสร้างข้อมูลใหม่ที่ล้มเหลวหรือหยุดค้างต่อจาก checkpoint แบบเบื้องหลัง
+func (s *RebuildService) ResumeRebuild(ctx context.Context, id, organizationID string) (*models.Job, error) {
+	job, err := s.ClaimRebuildJob(ctx, id, organizationID)
+	if err != nil {
+		return nil, err
+	}
+	s.Jobs.Run(job, s.RunRebuildJob)
+	return job, nil
+}
+
+// RunRebuildJob สร้างข้อมูลใหม่ทีละ chunk ของบุคคลเรียงตาม person_hash โดยเริ่มต่อจาก checkpoint ของงาน (ถ้ามี)
+// แต่ละ chunk บันทึกการแก้ไขและ checkpoint ใน transaction เดียวกัน การทำซ้ำจึงไม่เปลี่ยนข้อมูลที่ถูกต้องแล้ว
+func (s *RebuildService) RunRebuildJob(ctx context.Context, job *models.Job, progress func(int64)) (interface{}, error) {
+	var params models.RebuildParams
+	if err := json.Unmarshal(job.Params, &params); err != nil {
+		return nil, fmt.Errorf("ไม่สามารถอ่านพารามิเตอร์ของงาน: %w", err)
+	}
+	if err := validateRebuildParams(&params); err != nil {
+		return nil, err
+	}
+
+	// โหลด checkpoint ล่าสุดจากฐานข้อมูล เพราะ job ในหน่วยความจำอาจเก่ากว่า
+	var saved models.Job
+	if err := s.DB.DB.WithContext(ctx).Select("checkpoint").Where("id = ?", job.ID).First(&saved).Error; err != nil {
+		return nil, fmt.Errorf("ไม่สามารถอ่าน checkpoint ของงาน: %w", err)
+	}
+	var checkpoint models.RebuildCheckpoint
+	if len(saved.Checkpoint) > 0 && string(saved.Checkpoint) != "null" {
+		if err := json.Unmarshal(saved.Checkpoint, &checkpoint); err != nil {
+			return nil, fmt.Errorf("ไม่สามารถอ่าน checkpoint ของงาน: %w", err)
+		}
+		log.Printf("งานสร้างข้อมูลใหม่ %s ทำต่อหลังบุคคล %s (%d บุคคล)", job.ID, checkpoint.Cursor, checkpoint.Result.Persons)
+	}
+	checkpoint.Result.DryRun = params.DryRun
+
+	scope, scopeArgs := rebuildScope(params)
+	var total int64
+	if err := s.DB.DB.WithContext(ctx).Raw(`SELECT COUNT(*) FROM (`+scope+`) scope`, scopeArgs).Scan(&total).Error; err != nil {
+		return nil, fmt.Errorf("ไม่สามารถนับบุคคลที่ต้องสร้างข้อมูลใหม่: %w", err)
+	}
+	if err := s.Jobs.SetTotal(ctx, job.ID, total); err != nil {
+		return nil, err
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("งานสร้างข้อมูลใหม่ถูกยกเลิก: %w", err)
+		}
+
+		scopeArgs["cursor"] = checkpoint.Cursor
+		scopeArgs["limit"] = params.ChunkSize
+		var hashes []string
+		if err := s.DB.DB.WithContext(ctx).Raw(`
+			SELECT person_hash FROM (`+scope+`) scope
+			WHERE person_hash > @cursor
+			ORDER BY person_hash
+			LIMIT @limit
+		`, scopeArgs).Scan(&hashes).Error; err != nil {
+			return nil, fmt.Errorf("ไม่สามารถดึงบุคคลที่ต้องสร้างข้อมูลใหม่: %w", err)
+		}
+		if len(hashes) == 0 {
+			break
+		}
+
+		// คัดลอกรายการเปลี่ยนแปลง เพื่อไม่ให้ chunk ที่ rollback ไปแก้ checkpoint เดิม
+		next := checkpoint
+		next.Result.Changes = append([]models.RebuildChange(nil), checkpoint.Result.Changes...)
+		if err := s.DB.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			if err := s.rebuildChunk(tx, params, hashes, &next.Result); err != nil {
+				return err
+			}
+			next.Cursor = hashes[len(hashes)-1]
+			next.Result.Persons += int64(len(hashes))
+			return s.Jobs.SaveCheckpoint(tx, job.ID, next, next.Result.Persons)
+		}); err != nil {
+			return nil, err
+		}
+		checkpoint = next
+
+		log.Printf("งานสร้างข้อมูลใหม่ %s: %d/%d บุคคล", job.ID, checkpoint.Result.Persons, total)
+	}
+
+	// ล้าง cache สถิติขององค์กรเมื่อมีข้อมูลเปลี่ยน
+	changed := checkpoint.Result.PersonsCreated+checkpoint.Result.PersonsUpdated+checkpoint.Result.LogsUpdated > 0
+	if changed && !params.DryRun && s.Stats != nil {
+		if err := s.Stats.InvalidateOrganizationCache(ctx, params.OrganizationID); err != nil {
+			log.Printf("ไม่สามารถล้าง cache สถิติขององค์กร %s: %v", params.OrganizationID, err)
+		}
+	}
+
+	return checkpoint.Result, nil
+}
+
+// rebuildChunk เปรียบเทียบและ (ถ้าไม่ใช่ dry run) แก้ไขข้อมูลของบุคคลใน chunk จากประวัติ logs ทั้งหมดของบุคคล
+func (s *RebuildService) rebuildChunk(tx *gorm.DB, params models.RebuildParams, hashes []string, result *models.RebuildResult) error {
+	args := map[string]interface{}{
+		"organization_id": params.OrganizationID,
+		"hashes":          hashes,
+	}
+
+	// ล็อกแถวของบุคคลก่อนอ่าน การบันทึก log ใหม่ของบุคคลเดียวกันจะรอจนจบ chunk
+	if !params.DryRun {
+		if err := tx.Exec(`
+			SELECT id FROM persons
+			WHERE organization_id = @organization_id AND person_hash IN @hashes
+			ORDER BY person_hash
+			FOR UPDATE
+		`, args).Error; err != nil {
+			return fmt.Errorf("ไม่สามารถล็อกข้อมูลบุคคล: %w", err)
+		}
+	}
+
+	var persons []rebuildPersonRow
+	if err := tx.Raw(`
+		SELECT COALESCE(agg.person_hash, p.person_hash) AS person_hash, agg.first_seen, agg.last_seen, COALESCE(agg.visits, 0) AS visits,
+			p.id AS person_id, p.first_seen AS current_first_seen, p.last_seen AS current_last_seen, p.visit_count AS current_visits
+		FROM (
+			SELECT person_hash, MIN(timestamp) AS first_seen, MAX(timestamp) AS last_seen, COUNT(*) AS visits
+			FROM person_logs
+			WHERE organization_id = @organization_id AND person_hash IN @hashes AND deleted_at IS NULL
+			GROUP BY person_hash
+		) agg
+		FULL JOIN (
+			SELECT id, person_hash, first_seen, last_seen, visit_count
+			FROM persons
+			WHERE organization_id = @organization_id AND person_hash IN @hashes AND deleted_at IS NULL
+		) p ON p.person_hash = agg.person_hash
+		ORDER BY 1
+	`, args).Scan(&persons).Error; err != nil {
+		return fmt.Errorf("ไม่สามารถคำนวณข้อมูลบุคคลจาก logs: %w", err)
+	}
+
+	var logs []rebuildLogRow
+	if err := tx.Raw(`
+		SELECT l.id, l.person_hash, l.is_new_person
+		FROM person_logs l
+		JOIN (
+			SELECT DISTINCT ON (person_hash) person_hash, id
+			FROM person_logs
+			WHERE organization_id = @organization_id AND person_hash IN @hashes AND deleted_at IS NULL
+			ORDER BY person_hash, timestamp, id
+		) first_log ON first_log.person_hash = l.person_hash
+		WHERE l.organization_id = @organization_id AND l.person_hash IN @hashes AND l.deleted_at IS NULL
+			AND l.is_new_person <> (l.id = first_log.id)
+		ORDER BY l.person_hash, l.timestamp, l.id
+	`, args).Scan(&logs).Error; err != nil {
+		return fmt.Errorf("ไม่สามารถตรวจสอบสถานะคนใหม่ของ logs: %w", err)
+	}
+
+	var changes []models.RebuildChange
+	for _, person := range persons {
+		personChanges := diffRebuildPerson(person)
+		if len(personChanges) == 0 {
+			continue
+		}
+		if person.PersonID == nil {
+			result.PersonsCreated++
+		} else {
+			result.PersonsUpdated++
+		}
+		changes = append(changes, personChanges...)
+	}
+	for _, personLog := range logs {
+		changes = append(changes, models.RebuildChange{
+			PersonHash: personLog.PersonHash,
+			LogID:      personLog.ID,
+			Field:      models.RebuildFieldIsNewPerson,
+			Old:        personLog.IsNewPerson,
+			New:        !personLog.IsNewPerson,
+		})
+	}
+	addRebuildChanges(result, changes)
+
+	if params.DryRun || len(changes) == 0 {
+		return nil
+	}
+
+	// สร้างบุคคลที่มี logs แต่ไม่มีข้อมูลบุคคล หรือบุคคลที่ถูกลบไปแล้ว และแก้ค่าของบุคคลที่ไม่ตรงกับ logs
+	if err := tx.Exec(`
+		INSERT INTO persons (id, created_at, updated_at, person_hash, first_seen, last_seen, visit_count, organization_id)
+		SELECT gen_random_uuid()::text, NOW(), NOW(), person_hash, MIN(timestamp), MAX(timestamp), COUNT(*), organization_id
+		FROM person_logs
+		WHERE organization_id = @organization_id AND person_hash IN @hashes AND deleted_at IS NULL
+		GROUP BY organization_id, person_hash
+		ON CONFLICT (organization_id, person_hash) DO UPDATE SET
+			first_seen = EXCLUDED.first_seen,
+			last_seen = EXCLUDED.last_seen,
+			visit_count = EXCLUDED.visit_count,
+			deleted_at = NULL,
+			updated_at = NOW()
+		WHERE persons.deleted_at IS NOT NULL
+			OR persons.first_seen IS DISTINCT FROM EXCLUDED.first_seen
+			OR persons.last_seen IS DISTINCT FROM EXCLUDED.last_seen
+			OR persons.visit_count <> EXCLUDED.visit_count
+	`, args).Error; err != nil {
+		return fmt.Errorf("ไม่สามารถแก้ไขข้อมูลบุคคล: %w", err)
+	}
+
+	// บุคคลที่ไม่มี log เหลืออยู่
+	if err := tx.Exec(`
+		UPDATE persons p SET visit_count = 0, updated_at = NOW()
+		WHERE p.organization_id = @organization_id AND p.person_hash IN @hashes AND p.deleted_at IS NULL AND p.visit_count <> 0
+			AND NOT EXISTS (
+				SELECT 1 FROM person_logs l
+				WHERE l.organization_id = p.organization_id AND l.person_hash = p.person_hash AND l.deleted_at IS NULL
+			)
+	`, args).Error; err != nil {
+		return fmt.Errorf("ไม่สามารถแก้ไขข้อมูลบุคคลที่ไม่มี logs: %w", err)
+	}
+
+	// log แรกของแต่ละบุคคล (เวลาเท่ากันเรียงตาม id) เป็นคนใหม่ ที่เหลือเป็นคนซ้ำ
+	if err := tx.Exec(`
+		UPDATE person_logs l
+		SET is_new_person = (l.id = first_log.id)
+		FROM (
+			SELECT DISTINCT ON (person_hash) person_hash, id
+			FROM person_logs
+			WHERE organization_id = @organization_id AND person_hash IN @hashes AND deleted_at IS NULL
+			ORDER BY person_hash, timestamp, id
+		) first_log
+		WHERE l.organization_id = @organization_id AND l.person_hash = first_log.person_hash
+			AND l.deleted_at IS NULL AND l.is_new_person <> (l.id = first_log.id)
+	`, args).Error; err != nil {
+		return fmt.Errorf("ไม่สามารถแก้ไขสถานะคนใหม่ของ logs: %w", err)
+	}
+
+	return nil
+}
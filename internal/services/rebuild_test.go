@@ -0,0 +1,97 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestValidateRebuildParams ทดสอบการตรวจสอบช่วงเวลาและขนาด chunk
+func TestValidateRebuildParams(t *testing.T) {
+	params := models.RebuildParams{OrganizationID: "org-1"}
+	require.NoError(t, validateRebuildParams(&params))
+	assert.Equal(t, defaultRebuildChunkSize, params.ChunkSize)
+
+	from := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	assert.EqualError(t, validateRebuildParams(&models.RebuildParams{OrganizationID: "org-1", From: &from, To: &from}), "to ต้องอยู่หลัง from")
+	assert.Error(t, validateRebuildParams(&models.RebuildParams{OrganizationID: "org-1", ChunkSize: maxRebuildChunkSize + 1}))
+	assert.Error(t, validateRebuildParams(&models.RebuildParams{OrganizationID: "org-1", ChunkSize: -1}))
+	assert.EqualError(t, validateRebuildParams(&models.RebuildParams{}), "ไม่พบข้อมูลองค์กร")
+}
+
+// TestRebuildScope ทดสอบว่าช่วงเวลาใช้กับทั้ง logs และข้อมูลบุคคลที่บันทึกไว้
+func TestRebuildScope(t *testing.T) {
+	query, args := rebuildScope(models.RebuildParams{OrganizationID: "org-1"})
+	assert.NotContains(t, query, "@from")
+	assert.NotContains(t, query, "@to")
+	assert.Equal(t, map[string]interface{}{"organization_id": "org-1"}, args)
+
+	from := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 1, 0)
+	query, args = rebuildScope(models.RebuildParams{OrganizationID: "org-1", From: &from, To: &to})
+	assert.Contains(t, query, "timestamp >= @from AND timestamp < @to")
+	assert.Contains(t, query, "last_seen >= @from AND first_seen < @to")
+	assert.Equal(t, from, args["from"])
+	assert.Equal(t, to, args["to"])
+}
+
+// TestDiffRebuildPerson ทดสอบการเปรียบเทียบค่าที่บันทึกไว้กับค่าที่คำนวณจาก logs
+func TestDiffRebuildPerson(t *testing.T) {
+	first := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	last := first.Add(5 * time.Hour)
+	id := "person-1"
+	visits := 3
+
+	// ข้อมูลตรงกัน (เวลาเดียวกันต่าง time zone ถือว่าเท่ากัน)
+	localFirst, localLast := first.In(time.FixedZone("ICT", 7*3600)), last
+	assert.Empty(t, diffRebuildPerson(rebuildPersonRow{
+		PersonHash: "hash-1", FirstSeen: &first, LastSeen: &last, Visits: 3,
+		PersonID: &id, CurrentFirstSeen: &localFirst, CurrentLastSeen: &localLast, CurrentVisits: &visits,
+	}))
+
+	// ไม่มีข้อมูลบุคคลแต่มี logs
+	changes := diffRebuildPerson(rebuildPersonRow{PersonHash: "hash-1", FirstSeen: &first, LastSeen: &last, Visits: 2})
+	require.Len(t, changes, 1)
+	assert.Equal(t, models.RebuildFieldPerson, changes[0].Field)
+	assert.Equal(t, 2, changes[0].New)
+
+	// event เก่ามาช้าทำให้ first_seen และ last_seen ผิด
+	stale := last.Add(time.Hour)
+	changes = diffRebuildPerson(rebuildPersonRow{
+		PersonHash: "hash-1", FirstSeen: &first, LastSeen: &last, Visits: 4,
+		PersonID: &id, CurrentFirstSeen: &last, CurrentLastSeen: &stale, CurrentVisits: &visits,
+	})
+	require.Len(t, changes, 3)
+	assert.Equal(t, []string{models.RebuildFieldFirstSeen, models.RebuildFieldLastSeen, models.RebuildFieldVisitCount},
+		[]string{changes[0].Field, changes[1].Field, changes[2].Field})
+	assert.Equal(t, 3, changes[2].Old)
+	assert.Equal(t, 4, changes[2].New)
+
+	// logs ถูกลบหมด: คงเวลาเดิมไว้ แต่จำนวนการเข้าชมเป็น 0
+	changes = diffRebuildPerson(rebuildPersonRow{
+		PersonHash: "hash-1", PersonID: &id, CurrentFirstSeen: &first, CurrentLastSeen: &last, CurrentVisits: &visits,
+	})
+	require.Len(t, changes, 1)
+	assert.Equal(t, models.RebuildFieldVisitCount, changes[0].Field)
+	assert.Equal(t, 0, changes[0].New)
+
+	// ไม่มีทั้งข้อมูลบุคคลและ logs
+	assert.Empty(t, diffRebuildPerson(rebuildPersonRow{PersonHash: "hash-1"}))
+}
+
+// TestAddRebuildChanges ทดสอบว่ารายการเปลี่ยนแปลงถูกจำกัดจำนวน แต่ยังนับ logs ครบ
+func TestAddRebuildChanges(t *testing.T) {
+	changes := make([]models.RebuildChange, maxRebuildChanges+5)
+	for i := range changes {
+		changes[i] = models.RebuildChange{PersonHash: "hash-1", Field: models.RebuildFieldIsNewPerson, Old: true, New: false}
+	}
+
+	var result models.RebuildResult
+	addRebuildChanges(&result, changes)
+	assert.Len(t, result.Changes, maxRebuildChanges)
+	assert.True(t, result.ChangesTruncated)
+	assert.Equal(t, int64(maxRebuildChanges+5), result.LogsUpdated)
+}
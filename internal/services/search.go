@@ -0,0 +1,278 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"strings"
+	"time"
+
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/db"
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// validatePersonSearch ตรวจสอบช่วงของตัวกรอง และแปลงป้ายกำกับให้อยู่ในรูปแบบมาตรฐาน
+func validatePersonSearch(search *models.PersonSearch) error {
+	if (search.MinVisits != nil && *search.MinVisits < 0) || (search.MaxVisits != nil && *search.MaxVisits < 0) {
+		return fmt.Errorf("จำนวนการเข้าชมต้องไม่ติดลบ")
+	}
+	if search.MinVisits != nil && search.MaxVisits != nil && *search.MinVisits > *search.MaxVisits {
+		return fmt.Errorf("min_visits ต้องไม่มากกว่า max_visits")
+	}
+	if search.FirstSeenFrom != nil && search.FirstSeenTo != nil && search.FirstSeenTo.Before(*search.FirstSeenFrom) {
+		return fmt.Errorf("first_seen_to ต้องไม่ก่อน first_seen_from")
+	}
+	if search.LastSeenFrom != nil && search.LastSeenTo != nil && search.LastSeenTo.Before(*search.LastSeenFrom) {
+		return fmt.Errorf("last_seen_to ต้องไม่ก่อน last_seen_from")
+	}
+	if search.NewWithinDays < 0 {
+		return fmt.Errorf("new_within_days ต้องไม่ติดลบ")
+	}
+
+	for i, label := range search.Labels {
+		normalized, err := normalizeLabel(label)
+		if err != nil {
+			return err
+		}
+		search.Labels[i] = normalized
+	}
+	return nil
+}
+
+// personSearchCondition สร้างเงื่อนไข SQL ของ PersonSearch บนตาราง persons ที่มีชื่อย่อ table
+// ทุกตัวกรองใช้พารามิเตอร์แบบมีชื่อที่ขึ้นต้นด้วย param เพื่อให้ใช้หลายการค้นหาใน query เดียวกันได้ คืนค่าว่างถ้าไม่มีตัวกรอง
+func personSearchCondition(search models.PersonSearch, table, param string, now time.Time) (string, map[string]interface{}) {
+	var conditions []string
+	args := map[string]interface{}{}
+
+	add := func(condition, name string, value interface{}) {
+		conditions = append(conditions, strings.ReplaceAll(condition, "@"+name, "@"+param+"_"+name))
+		args[param+"_"+name] = value
+	}
+
+	if search.FirstSeenFrom != nil {
+		add(table+".first_seen >= @first_seen_from", "first_seen_from", *search.FirstSeenFrom)
+	}
+	if search.FirstSeenTo != nil {
+		add(table+".first_seen < @first_seen_to", "first_seen_to", *search.FirstSeenTo)
+	}
+	if search.LastSeenFrom != nil {
+		add(table+".last_seen >= @last_seen_from", "last_seen_from", *search.LastSeenFrom)
+	}
+	if search.LastSeenTo != nil {
+		add(table+".last_seen < @last_seen_to", "last_seen_to", *search.LastSeenTo)
+	}
+	if search.MinVisits != nil {
+		add(table+".visit_count >= @min_visits", "min_visits", *search.MinVisits)
+	}
+	if search.MaxVisits != nil {
+		add(table+".visit_count <= @max_visits", "max_visits", *search.MaxVisits)
+	}
+	if search.NewWithinDays > 0 {
+		add(table+".first_seen >= @new_since", "new_since", now.AddDate(0, 0, -search.NewWithinDays))
+	}
+	if len(search.CameraIDs) > 0 {
+		add(`EXISTS (
+			SELECT 1 FROM person_logs sl
+			WHERE sl.organization_id = `+table+`.organization_id AND sl.person_hash = `+table+`.person_hash
+				AND sl.camera_id IN @camera_ids AND sl.deleted_at IS NULL
+		)`, "camera_ids", search.CameraIDs)
+	}
+	if len(search.Zones) > 0 {
+		add(`EXISTS (
+			SELECT 1 FROM person_logs sl
+			JOIN cameras sc ON sc.id = sl.camera_id AND sc.deleted_at IS NULL
+			WHERE sl.organization_id = `+table+`.organization_id AND sl.person_hash = `+table+`.person_hash
+				AND sc.zone IN @zones AND sl.deleted_at IS NULL
+		)`, "zones", search.Zones)
+	}
+	if len(search.Labels) > 0 {
+		add(`EXISTS (
+			SELECT 1 FROM person_labels sp
+			WHERE sp.organization_id = `+table+`.organization_id AND sp.person_hash = `+table+`.person_hash
+				AND sp.label IN @labels AND sp.deleted_at IS NULL
+		)`, "labels", search.Labels)
+	}
+	if search.HasFaceImages != nil {
+		add(`EXISTS (
+			SELECT 1 FROM face_images sf
+			WHERE sf.organization_id = `+table+`.organization_id AND sf.person_hash = `+table+`.person_hash
+				AND sf.deleted_at IS NULL
+		) = @has_face_images`, "has_face_images", *search.HasFaceImages)
+	}
+
+	return strings.Join(conditions, " AND "), args
+}
+
+// searchClause คืนเงื่อนไข " AND ..." สำหรับต่อท้าย Raw SQL ที่นับเฉพาะบุคคลที่ตรงกับการค้นหาที่บันทึกไว้ใน scope
+// personColumn และ orgColumn ต้องระบุชื่อตารางด้วย พารามิเตอร์ที่คืนมาต้องรวมเข้ากับพารามิเตอร์ของ query
+func searchClause(scope models.StatsScope, personColumn, orgColumn string) (string, map[string]interface{}) {
+	if scope.Search == nil {
+		return "", map[string]interface{}{}
+	}
+	condition, args := personSearchCondition(*scope.Search, "ss", "scope", time.Now())
+	if condition == "" {
+		return "", args
+	}
+	return fmt.Sprintf(` AND EXISTS (
+			SELECT 1 FROM persons ss
+			WHERE ss.organization_id = %s AND ss.person_hash = %s AND ss.deleted_at IS NULL
+				AND %s
+		)`, orgColumn, personColumn, condition), args
+}
+
+// matchSearch เป็น GORM scope ที่นับเฉพาะการตรวจจับของบุคคลที่ตรงกับการค้นหาที่บันทึกไว้ใน scope
+func matchSearch(scope models.StatsScope, table string) func(*gorm.DB) *gorm.DB {
+	return func(query *gorm.DB) *gorm.DB {
+		clause, args := searchClause(scope, table+".person_hash", table+".organization_id")
+		if clause == "" {
+			return query
+		}
+		return query.Where(strings.TrimPrefix(clause, " AND "), args)
+	}
+}
+
+// withSearchArgs รวมพารามิเตอร์ของ searchClause เข้ากับพารามิเตอร์ของ query
+func withSearchArgs(args map[string]interface{}, searchArgs map[string]interface{}) map[string]interface{} {
+	maps.Copy(args, searchArgs)
+	return args
+}
+
+// loadSavedSearch ดึงการค้นหาที่บันทึกไว้พร้อมแปลงตัวกรอง
+func loadSavedSearch(ctx context.Context, tx *gorm.DB, id, organizationID string) (*models.SavedSearch, *models.PersonSearch, error) {
+	var saved models.SavedSearch
+	if err := tx.WithContext(ctx).
+		Where("id = ? AND organization_id = ?", id, organizationID).
+		First(&saved).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil, fmt.Errorf("ไม่พบการค้นหาที่บันทึกไว้")
+		}
+		return nil, nil, fmt.Errorf("ไม่สามารถดึงการค้นหาที่บันทึกไว้: %w", err)
+	}
+
+	var search models.PersonSearch
+	if err := json.Unmarshal(saved.Filter, &search); err != nil {
+		return nil, nil, fmt.Errorf("ตัวกรองของการค้นหาที่บันทึกไว้ไม่ถูกต้อง: %w", err)
+	}
+	return &saved, &search, nil
+}
+
+// resolveStatsScope ดึงตัวกรองของการค้นหาที่บันทึกไว้ใน scope เพื่อใช้เป็นกลุ่มของสถิติ
+// SearchKey รวมเวลาแก้ไขล่าสุดไว้ เพื่อไม่ให้ cache เดิมถูกใช้หลังแก้ไขตัวกรอง
+func resolveStatsScope(ctx context.Context, tx *gorm.DB, organizationID string, scope models.StatsScope) (models.StatsScope, error) {
+	if scope.SavedSearchID == "" || scope.Search != nil {
+		return scope, nil
+	}
+	saved, search, err := loadSavedSearch(ctx, tx, scope.SavedSearchID, organizationID)
+	if err != nil {
+		return scope, err
+	}
+	scope.Search = search
+	scope.SearchKey = fmt.Sprintf("%s:%d", saved.ID, saved.UpdatedAt.UnixNano())
+	return scope, nil
+}
+
+// SearchService ให้บริการการค้นหาบุคคลที่บันทึกไว้
+type SearchService struct {
+	DB *db.PostgresDB
+}
+
+// NewSearchService สร้าง SearchService ใหม่
+func NewSearchService(postgres *db.PostgresDB) *SearchService {
+	return &SearchService{
+		DB: postgres,
+	}
+}
+
+// encodeSearchFilter ตรวจสอบตัวกรองแล้วแปลงเป็น JSON สำหรับบันทึก
+func encodeSearchFilter(search models.PersonSearch) (json.RawMessage, error) {
+	if err := validatePersonSearch(&search); err != nil {
+		return nil, err
+	}
+	filter, err := json.Marshal(search)
+	if err != nil {
+		return nil, fmt.Errorf("ไม่สามารถแปลงตัวกรอง: %w", err)
+	}
+	return filter, nil
+}
+
+// ListSavedSearches ดึงการค้นหาที่บันทึกไว้ทั้งหมดขององค์กร
+func (s *SearchService) ListSavedSearches(ctx context.Context, organizationID string) ([]models.SavedSearch, error) {
+	var searches []models.SavedSearch
+	if err := s.DB.DB.WithContext(ctx).
+		Where("organization_id = ?", organizationID).
+		Order("name").
+		Find(&searches).Error; err != nil {
+		return nil, fmt.Errorf("ไม่สามารถดึงรายการการค้นหาที่บันทึกไว้: %w", err)
+	}
+	return searches, nil
+}
+
+// GetSavedSearch ดึงการค้นหาที่บันทึกไว้ตาม ID
+func (s *SearchService) GetSavedSearch(ctx context.Context, id, organizationID string) (*models.SavedSearch, error) {
+	saved, _, err := loadSavedSearch(ctx, s.DB.DB, id, organizationID)
+	return saved, err
+}
+
+// CreateSavedSearch บันทึกการค้นหาใหม่
+func (s *SearchService) CreateSavedSearch(ctx context.Context, saved *models.SavedSearch, search models.PersonSearch) error {
+	saved.Name = strings.TrimSpace(saved.Name)
+	if saved.Name == "" {
+		return fmt.Errorf("ต้องระบุชื่อการค้นหา")
+	}
+	filter, err := encodeSearchFilter(search)
+	if err != nil {
+		return err
+	}
+	saved.Filter = filter
+	if saved.ID == "" {
+		saved.ID = uuid.New().String()
+	}
+
+	if err := s.DB.DB.WithContext(ctx).Create(saved).Error; err != nil {
+		return fmt.Errorf("ไม่สามารถบันทึกการค้นหา: %w", err)
+	}
+	return nil
+}
+
+// UpdateSavedSearch แก้ไขชื่อ คำอธิบาย และตัวกรองของการค้นหาที่บันทึกไว้
+func (s *SearchService) UpdateSavedSearch(ctx context.Context, id, organizationID, name, description string, search models.PersonSearch) (*models.SavedSearch, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, fmt.Errorf("ต้องระบุชื่อการค้นหา")
+	}
+	filter, err := encodeSearchFilter(search)
+	if err != nil {
+		return nil, err
+	}
+
+	saved, err := s.GetSavedSearch(ctx, id, organizationID)
+	if err != nil {
+		return nil, err
+	}
+
+	saved.Name = name
+	saved.Description = description
+	saved.Filter = filter
+	if err := s.DB.DB.WithContext(ctx).Model(saved).Select("name", "description", "filter").Updates(saved).Error; err != nil {
+		return nil, fmt.Errorf("ไม่สามารถแก้ไขการค้นหาที่บันทึกไว้: %w", err)
+	}
+	return saved, nil
+}
+
+// DeleteSavedSearch ลบการค้นหาที่บันทึกไว้
+func (s *SearchService) DeleteSavedSearch(ctx context.Context, id, organizationID string) error {
+	result := s.DB.DB.WithContext(ctx).
+		Where("id = ? AND organization_id = ?", id, organizationID).
+		Delete(&models.SavedSearch{})
+	if result.Error != nil {
+		return fmt.Errorf("ไม่สามารถลบการค้นหาที่บันทึกไว้: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("ไม่พบการค้นหาที่บันทึกไว้")
+	}
+	return nil
+}
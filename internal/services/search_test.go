@@ -0,0 +1,90 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestValidatePersonSearch ทดสอบการตรวจสอบช่วงของตัวกรองและการแปลงป้ายกำกับ
+func TestValidatePersonSearch(t *testing.T) {
+	one, five := 1, 5
+	search := models.PersonSearch{MinVisits: &one, MaxVisits: &five, Labels: []string{" VIP ", "staff"}}
+	require.NoError(t, validatePersonSearch(&search))
+	assert.Equal(t, []string{"vip", "staff"}, search.Labels)
+
+	assert.EqualError(t, validatePersonSearch(&models.PersonSearch{MinVisits: &five, MaxVisits: &one}), "min_visits ต้องไม่มากกว่า max_visits")
+
+	negative := -1
+	assert.EqualError(t, validatePersonSearch(&models.PersonSearch{MinVisits: &negative}), "จำนวนการเข้าชมต้องไม่ติดลบ")
+	assert.EqualError(t, validatePersonSearch(&models.PersonSearch{NewWithinDays: -1}), "new_within_days ต้องไม่ติดลบ")
+
+	from := time.Date(2025, 3, 2, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, -1)
+	assert.EqualError(t, validatePersonSearch(&models.PersonSearch{FirstSeenFrom: &from, FirstSeenTo: &to}), "first_seen_to ต้องไม่ก่อน first_seen_from")
+	assert.EqualError(t, validatePersonSearch(&models.PersonSearch{LastSeenFrom: &from, LastSeenTo: &to}), "last_seen_to ต้องไม่ก่อน last_seen_from")
+
+	assert.Error(t, validatePersonSearch(&models.PersonSearch{Labels: []string{"has space"}}))
+}
+
+// TestPersonSearchCondition ทดสอบว่าเงื่อนไขมีเฉพาะตัวกรองที่ระบุ และพารามิเตอร์ขึ้นต้นด้วย param
+func TestPersonSearchCondition(t *testing.T) {
+	now := time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	condition, args := personSearchCondition(models.PersonSearch{}, "persons", "search", now)
+	assert.Empty(t, condition)
+	assert.Empty(t, args)
+
+	three := 3
+	noFaces := false
+	condition, args = personSearchCondition(models.PersonSearch{
+		MinVisits:     &three,
+		Zones:         []string{"entrance"},
+		Labels:        []string{"vip"},
+		HasFaceImages: &noFaces,
+		NewWithinDays: 7,
+	}, "p", "saved", now)
+
+	assert.Contains(t, condition, "p.visit_count >= @saved_min_visits")
+	assert.Contains(t, condition, "p.first_seen >= @saved_new_since")
+	assert.Contains(t, condition, "sc.zone IN @saved_zones")
+	assert.Contains(t, condition, "sp.label IN @saved_labels")
+	assert.Contains(t, condition, ") = @saved_has_face_images")
+	assert.Contains(t, condition, "sl.person_hash = p.person_hash")
+	assert.NotContains(t, condition, "@min_visits")
+	assert.NotContains(t, condition, "last_seen")
+
+	assert.Equal(t, map[string]interface{}{
+		"saved_min_visits":      3,
+		"saved_new_since":       now.AddDate(0, 0, -7),
+		"saved_zones":           []string{"entrance"},
+		"saved_labels":          []string{"vip"},
+		"saved_has_face_images": false,
+	}, args)
+}
+
+// TestSearchClause ทดสอบว่าการค้นหาที่บันทึกไว้ใช้เฉพาะเมื่อ scope มีตัวกรอง
+func TestSearchClause(t *testing.T) {
+	clause, args := searchClause(models.StatsScope{}, "l.person_hash", "l.organization_id")
+	assert.Empty(t, clause)
+	assert.Empty(t, args)
+
+	clause, _ = searchClause(models.StatsScope{Search: &models.PersonSearch{}}, "l.person_hash", "l.organization_id")
+	assert.Empty(t, clause)
+
+	two := 2
+	clause, args = searchClause(models.StatsScope{Search: &models.PersonSearch{MinVisits: &two}}, "l.person_hash", "l.organization_id")
+	assert.Contains(t, clause, " AND EXISTS")
+	assert.Contains(t, clause, "ss.person_hash = l.person_hash")
+	assert.Contains(t, clause, "ss.visit_count >= @scope_min_visits")
+	assert.Equal(t, 2, args["scope_min_visits"])
+}
+
+// TestStatsCacheKeySearch ทดสอบว่าสถิติของการค้นหาที่บันทึกไว้ใช้ cache แยกตามเวอร์ชันของการค้นหา
+func TestStatsCacheKeySearch(t *testing.T) {
+	scope := models.StatsScope{IncludeStaff: true, SearchKey: "search-1:123"}
+	assert.Equal(t, "heatmap:org-1:2025-03-01:with_staff:search:search-1:123", statsCacheKey("heatmap", "org-1", "2025-03-01", scope))
+}
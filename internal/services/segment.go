@@ -0,0 +1,407 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/db"
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// SegmentService ให้บริการแบ่งกลุ่มผู้เข้าชมตามความถี่และความใหม่ของการเข้าชม (RFM)
+type SegmentService struct {
+	DB *db.PostgresDB
+}
+
+// NewSegmentService สร้าง SegmentService ใหม่
+func NewSegmentService(postgres *db.PostgresDB) *SegmentService {
+	return &SegmentService{
+		DB: postgres,
+	}
+}
+
+// ClassifySegment จัดกลุ่มบุคคลจากจำนวนครั้งที่เข้าชมและเวลาที่พบล่าสุด
+func ClassifySegment(visitCount int, lastSeen, asOf time.Time, settings models.SegmentSettings) string {
+	// คนที่ไม่กลับมานานเกินกำหนดถือว่าหายไป ไม่ว่าจะเคยมากี่ครั้ง
+	if asOf.Sub(lastSeen) > time.Duration(settings.LapsedAfterDays)*24*time.Hour {
+		return models.SegmentLapsed
+	}
+	if visitCount <= settings.OneTimerMaxVisits {
+		return models.SegmentOneTimer
+	}
+	if visitCount <= settings.OccasionalMaxVisits {
+		return models.SegmentOccasional
+	}
+	return models.SegmentLoyal
+}
+
+// GetSettings ดึงเกณฑ์การแบ่งกลุ่มขององค์กร หรือค่าเริ่มต้นถ้ายังไม่ได้ตั้งค่า
+func (s *SegmentService) GetSettings(ctx context.Context, organizationID string) (*models.SegmentSettings, error) {
+	var settings models.SegmentSettings
+	result := s.DB.DB.WithContext(ctx).Where("organization_id = ?", organizationID).First(&settings)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			defaults := models.DefaultSegmentSettings(organizationID)
+			return &defaults, nil
+		}
+		return nil, fmt.Errorf("ไม่สามารถดึงเกณฑ์การแบ่งกลุ่ม: %w", result.Error)
+	}
+
+	return &settings, nil
+}
+
+// UpdateSettings บันทึกเกณฑ์การแบ่งกลุ่มขององค์กร
+func (s *SegmentService) UpdateSettings(ctx context.Context, settings *models.SegmentSettings) error {
+	// ตรวจสอบความถูกต้องของเกณฑ์
+	if settings.OneTimerMaxVisits < 1 {
+		return fmt.Errorf("one_timer_max_visits ต้องมีค่าอย่างน้อย 1")
+	}
+	if settings.OccasionalMaxVisits <= settings.OneTimerMaxVisits {
+		return fmt.Errorf("occasional_max_visits ต้องมากกว่า one_timer_max_visits")
+	}
+	if settings.LapsedAfterDays < 1 {
+		return fmt.Errorf("lapsed_after_days ต้องมีค่าอย่างน้อย 1")
+	}
+
+	// อัปเดตถ้ามีอยู่แล้ว ไม่เช่นนั้นสร้างใหม่
+	var existing models.SegmentSettings
+	result := s.DB.DB.WithContext(ctx).Where("organization_id = ?", settings.OrganizationID).First(&existing)
+	if result.Error != nil && result.Error != gorm.ErrRecordNotFound {
+		return fmt.Errorf("ไม่สามารถตรวจสอบเกณฑ์การแบ่งกลุ่ม: %w", result.Error)
+	}
+
+	if result.Error == gorm.ErrRecordNotFound {
+		settings.ID = uuid.New().String()
+		if err := s.DB.DB.WithContext(ctx).Create(settings).Error; err != nil {
+			return fmt.Errorf("ไม่สามารถบันทึกเกณฑ์การแบ่งกลุ่ม: %w", err)
+		}
+		return nil
+	}
+
+	settings.ID = existing.ID
+	settings.CreatedAt = existing.CreatedAt
+	if err := s.DB.DB.WithContext(ctx).Model(&existing).Updates(map[string]interface{}{
+		"one_timer_max_visits":  settings.OneTimerMaxVisits,
+		"occasional_max_visits": settings.OccasionalMaxVisits,
+		"lapsed_after_days":     settings.LapsedAfterDays,
+	}).Error; err != nil {
+		return fmt.Errorf("ไม่สามารถบันทึกเกณฑ์การแบ่งกลุ่ม: %w", err)
+	}
+
+	return nil
+}
+
+// segmentCase สร้างนิพจน์ SQL สำหรับจัดกลุ่มบุคคลให้ตรงกับ ClassifySegment
+func segmentCase(visitsColumn, lastSeenColumn, asOfExpr string) string {
+	return fmt.Sprintf(`CASE
+			WHEN %[3]s - %[2]s > make_interval(days => @lapsed_days) THEN '%[4]s'
+			WHEN %[1]s <= @one_timer_max THEN '%[5]s'
+			WHEN %[1]s <= @occasional_max THEN '%[6]s'
+			ELSE '%[7]s'
+		END`,
+		visitsColumn, lastSeenColumn, asOfExpr,
+		models.SegmentLapsed, models.SegmentOneTimer, models.SegmentOccasional, models.SegmentLoyal)
+}
+
+// segmentArgs สร้างพารามิเตอร์ที่ใช้ร่วมกับ segmentCase
+func segmentArgs(organizationID string, asOf time.Time, settings *models.SegmentSettings) map[string]interface{} {
+	return map[string]interface{}{
+		"org_id":         organizationID,
+		"as_of":          asOf,
+		"lapsed_days":    settings.LapsedAfterDays,
+		"one_timer_max":  settings.OneTimerMaxVisits,
+		"occasional_max": settings.OccasionalMaxVisits,
+	}
+}
+
+// GetDistribution ดึงการกระจายของความถี่ ความใหม่ และกลุ่มของผู้เข้าชม ณ ปัจจุบัน โดยไม่นับพนักงานเว้นแต่ scope กำหนดให้นับรวม
+func (s *SegmentService) GetDistribution(ctx context.Context, organizationID string, scope models.StatsScope) (*models.SegmentDistribution, error) {
+	settings, err := s.GetSettings(ctx, organizationID)
+	if err != nil {
+		return nil, err
+	}
+
+	scope, err = resolveStatsScope(ctx, s.DB.DB, organizationID, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	asOf := time.Now()
+	search, searchArgs := searchClause(scope, "persons.person_hash", "persons.organization_id")
+	args := withSearchArgs(segmentArgs(organizationID, asOf, settings), searchArgs)
+
+	// นับจำนวนบุคคลในแต่ละกลุ่ม
+	var segmentRows []struct {
+		Segment string
+		Count   int
+	}
+	if err := s.DB.DB.WithContext(ctx).Raw(`
+		SELECT `+segmentCase("visit_count", "last_seen", "@as_of")+` AS segment, COUNT(*) AS count
+		FROM persons
+		WHERE organization_id = @org_id AND deleted_at IS NULL`+staffClause(scope, "persons.person_hash", "persons.organization_id")+search+`
+		GROUP BY segment
+	`, args).Scan(&segmentRows).Error; err != nil {
+		return nil, fmt.Errorf("ไม่สามารถนับจำนวนบุคคลในแต่ละกลุ่ม: %w", err)
+	}
+
+	// นับการกระจายของความถี่และความใหม่
+	var buckets struct {
+		Total       int      `gorm:"column:total"`
+		Freq1       int      `gorm:"column:freq1"`
+		Freq2       int      `gorm:"column:freq2"`
+		Freq3To4    int      `gorm:"column:freq3_to4"`
+		Freq5To9    int      `gorm:"column:freq5_to9"`
+		Freq10Plus  int      `gorm:"column:freq10_plus"`
+		Rec0To7     int      `gorm:"column:rec0_to7"`
+		Rec8To14    int      `gorm:"column:rec8_to14"`
+		Rec15To30   int      `gorm:"column:rec15_to30"`
+		Rec31To60   int      `gorm:"column:rec31_to60"`
+		Rec61To90   int      `gorm:"column:rec61_to90"`
+		Rec90Plus   int      `gorm:"column:rec90_plus"`
+		AvgInterval *float64 `gorm:"column:avg_interval"`
+	}
+	if err := s.DB.DB.WithContext(ctx).Raw(`
+		WITH p AS (
+			SELECT visit_count,
+				EXTRACT(EPOCH FROM (@as_of - last_seen)) / 86400 AS days_since,
+				CASE WHEN visit_count > 1
+					THEN EXTRACT(EPOCH FROM (last_seen - first_seen)) / 86400 / (visit_count - 1)
+				END AS interval_days
+			FROM persons
+			WHERE organization_id = @org_id AND deleted_at IS NULL`+staffClause(scope, "persons.person_hash", "persons.organization_id")+search+`
+		)
+		SELECT
+			COUNT(*) AS total,
+			COUNT(*) FILTER (WHERE visit_count <= 1) AS freq1,
+			COUNT(*) FILTER (WHERE visit_count = 2) AS freq2,
+			COUNT(*) FILTER (WHERE visit_count BETWEEN 3 AND 4) AS freq3_to4,
+			COUNT(*) FILTER (WHERE visit_count BETWEEN 5 AND 9) AS freq5_to9,
+			COUNT(*) FILTER (WHERE visit_count >= 10) AS freq10_plus,
+			COUNT(*) FILTER (WHERE days_since < 8) AS rec0_to7,
+			COUNT(*) FILTER (WHERE days_since >= 8 AND days_since < 15) AS rec8_to14,
+			COUNT(*) FILTER (WHERE days_since >= 15 AND days_since < 31) AS rec15_to30,
+			COUNT(*) FILTER (WHERE days_since >= 31 AND days_since < 61) AS rec31_to60,
+			COUNT(*) FILTER (WHERE days_since >= 61 AND days_since < 91) AS rec61_to90,
+			COUNT(*) FILTER (WHERE days_since >= 91) AS rec90_plus,
+			AVG(interval_days) AS avg_interval
+		FROM p
+	`, args).Scan(&buckets).Error; err != nil {
+		return nil, fmt.Errorf("ไม่สามารถคำนวณการกระจายของการเข้าชม: %w", err)
+	}
+
+	// เรียงกลุ่มตามลำดับที่กำหนด และใส่ 0 ให้กลุ่มที่ไม่มีข้อมูล
+	counts := make(map[string]int, len(segmentRows))
+	for _, row := range segmentRows {
+		counts[row.Segment] = row.Count
+	}
+	segments := make([]models.SegmentCount, 0, len(models.Segments))
+	for _, name := range models.Segments {
+		segments = append(segments, models.SegmentCount{Segment: name, Count: counts[name]})
+	}
+
+	avgInterval := 0.0
+	if buckets.AvgInterval != nil {
+		avgInterval = math.Round(*buckets.AvgInterval*100) / 100
+	}
+
+	return &models.SegmentDistribution{
+		AsOf:         asOf.Format(time.RFC3339),
+		TotalPersons: buckets.Total,
+		Segments:     segments,
+		Frequency: []models.HistogramBucket{
+			{Label: "1", Count: buckets.Freq1},
+			{Label: "2", Count: buckets.Freq2},
+			{Label: "3-4", Count: buckets.Freq3To4},
+			{Label: "5-9", Count: buckets.Freq5To9},
+			{Label: "10+", Count: buckets.Freq10Plus},
+		},
+		Recency: []models.HistogramBucket{
+			{Label: "0-7", Count: buckets.Rec0To7},
+			{Label: "8-14", Count: buckets.Rec8To14},
+			{Label: "15-30", Count: buckets.Rec15To30},
+			{Label: "31-60", Count: buckets.Rec31To60},
+			{Label: "61-90", Count: buckets.Rec61To90},
+			{Label: "90+", Count: buckets.Rec90Plus},
+		},
+		AvgDaysBetweenVisits: avgInterval,
+		Settings:             *settings,
+		OrganizationID:       organizationID,
+	}, nil
+}
+
+// GetTrend ดึงจำนวนบุคคลในแต่ละกลุ่มตามช่วงเวลา โดยคำนวณย้อนหลังจากประวัติการเข้าชม
+func (s *SegmentService) GetTrend(ctx context.Context, organizationID string, from, to time.Time, interval string, scope models.StatsScope) ([]models.SegmentTrendPoint, error) {
+	step := "1 day"
+	switch interval {
+	case "", "day":
+	case "week":
+		step = "7 days"
+	case "month":
+		step = "1 month"
+	default:
+		return nil, fmt.Errorf("interval ต้องเป็น day, week หรือ month")
+	}
+
+	if to.Before(from) {
+		return nil, fmt.Errorf("วันที่สิ้นสุดต้องไม่ก่อนวันที่เริ่มต้น")
+	}
+
+	settings, err := s.GetSettings(ctx, organizationID)
+	if err != nil {
+		return nil, err
+	}
+
+	scope, err = resolveStatsScope(ctx, s.DB.DB, organizationID, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	search, searchArgs := searchClause(scope, "l.person_hash", "l.organization_id")
+	args := withSearchArgs(segmentArgs(organizationID, to, settings), searchArgs)
+	args["from"] = from
+	args["to"] = to
+	args["step"] = step
+
+	// คำนวณสถานะของแต่ละบุคคล ณ สิ้นวันของแต่ละจุดเวลา โดยอ่าน logs เพียงครั้งเดียว
+	// แต่ละ log ถูกนับที่จุดเวลาแรกที่ครอบคลุม แล้วสะสมจำนวนการเข้าชมด้วย window function
+	// สถานะของบุคคลใช้ต่อไปจนถึงจุดเวลาถัดไปที่บุคคลนั้นมีการเข้าชม
+	var rows []models.SegmentTrendPoint
+	if err := s.DB.DB.WithContext(ctx).Raw(`
+		WITH points AS (
+			SELECT d, LAG(d) OVER (ORDER BY d) AS prev_d
+			FROM generate_series(@from::date, @to::date, @step::interval) AS d
+		),
+		buckets AS (
+			SELECT p.d, l.person_hash, COUNT(*) AS visits, MAX(l.timestamp) AS last_seen
+			FROM person_logs l
+			JOIN points p ON l.timestamp < p.d + INTERVAL '1 day'
+				AND (p.prev_d IS NULL OR l.timestamp >= p.prev_d + INTERVAL '1 day')
+			WHERE l.organization_id = @org_id
+				AND l.deleted_at IS NULL
+				AND l.timestamp < @to::date + INTERVAL '1 day'`+staffClause(scope, "l.person_hash", "l.organization_id")+search+`
+			GROUP BY p.d, l.person_hash
+		),
+		history AS (
+			SELECT d, person_hash, last_seen,
+				SUM(visits) OVER (PARTITION BY person_hash ORDER BY d) AS visits,
+				LEAD(d) OVER (PARTITION BY person_hash ORDER BY d) AS next_d
+			FROM buckets
+		),
+		classified AS (
+			SELECT p.d, `+segmentCase("h.visits", "h.last_seen", "(p.d + INTERVAL '1 day')")+` AS segment
+			FROM history h
+			JOIN points p ON p.d >= h.d AND (h.next_d IS NULL OR p.d < h.next_d)
+		)
+		SELECT TO_CHAR(p.d, 'YYYY-MM-DD') AS date,
+			COUNT(c.segment) FILTER (WHERE c.segment = 'one_timer') AS one_timer,
+			COUNT(c.segment) FILTER (WHERE c.segment = 'occasional') AS occasional,
+			COUNT(c.segment) FILTER (WHERE c.segment = 'loyal') AS loyal,
+			COUNT(c.segment) FILTER (WHERE c.segment = 'lapsed') AS lapsed
+		FROM points p
+		LEFT JOIN classified c ON c.d = p.d
+		GROUP BY p.d
+		ORDER BY p.d
+	`, args).Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("ไม่สามารถดึงแนวโน้มของกลุ่มผู้เข้าชม: %w", err)
+	}
+
+	return rows, nil
+}
+
+// ListSegmentMembers ดึงรายการบุคคลในกลุ่มที่กำหนด พร้อม pagination
+func (s *SegmentService) ListSegmentMembers(ctx context.Context, organizationID, segment string, page, pageSize int, scope models.StatsScope) ([]models.SegmentMember, *models.Pagination, error) {
+	if !isValidSegment(segment) {
+		return nil, nil, fmt.Errorf("ไม่รู้จักกลุ่ม %s", segment)
+	}
+
+	// จัดการ pagination
+	if page <= 0 {
+		page = 1
+	}
+	if pageSize <= 0 {
+		pageSize = 10
+	}
+	offset := (page - 1) * pageSize
+
+	settings, err := s.GetSettings(ctx, organizationID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	scope, err = resolveStatsScope(ctx, s.DB.DB, organizationID, scope)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	asOf := time.Now()
+	search, searchArgs := searchClause(scope, "persons.person_hash", "persons.organization_id")
+	args := withSearchArgs(segmentArgs(organizationID, asOf, settings), searchArgs)
+	args["segment"] = segment
+	args["limit"] = pageSize
+	args["offset"] = offset
+
+	segmentFilter := `FROM persons
+		WHERE organization_id = @org_id AND deleted_at IS NULL` + staffClause(scope, "persons.person_hash", "persons.organization_id") + search + `
+			AND ` + segmentCase("visit_count", "last_seen", "@as_of") + ` = @segment`
+
+	// นับจำนวนบุคคลทั้งหมดในกลุ่ม
+	var total int64
+	if err := s.DB.DB.WithContext(ctx).Raw(`SELECT COUNT(*) `+segmentFilter, args).Scan(&total).Error; err != nil {
+		return nil, nil, fmt.Errorf("ไม่สามารถนับจำนวนบุคคลในกลุ่ม: %w", err)
+	}
+
+	// ดึงข้อมูลบุคคลในกลุ่ม
+	var persons []models.Person
+	if err := s.DB.DB.WithContext(ctx).Raw(`SELECT * `+segmentFilter+`
+		ORDER BY last_seen DESC, id
+		LIMIT @limit OFFSET @offset`, args).Scan(&persons).Error; err != nil {
+		return nil, nil, fmt.Errorf("ไม่สามารถดึงรายการบุคคลในกลุ่ม: %w", err)
+	}
+
+	members := make([]models.SegmentMember, len(persons))
+	for i, person := range persons {
+		avgInterval := 0.0
+		if person.VisitCount > 1 {
+			avgInterval = person.LastSeen.Sub(person.FirstSeen).Hours() / 24 / float64(person.VisitCount-1)
+			avgInterval = math.Round(avgInterval*100) / 100
+		}
+		members[i] = models.SegmentMember{
+			PersonHash:           person.PersonHash,
+			VisitCount:           person.VisitCount,
+			FirstSeen:            person.FirstSeen,
+			LastSeen:             person.LastSeen,
+			DaysSinceLastVisit:   int(asOf.Sub(person.LastSeen).Hours() / 24),
+			AvgDaysBetweenVisits: avgInterval,
+		}
+	}
+
+	// คำนวณจำนวนหน้าทั้งหมด
+	totalPage := int(total) / pageSize
+	if int(total)%pageSize > 0 {
+		totalPage++
+	}
+
+	// สร้างข้อมูล pagination
+	pagination := &models.Pagination{
+		Total:     int(total),
+		Page:      page,
+		PageSize:  pageSize,
+		TotalPage: totalPage,
+	}
+
+	return members, pagination, nil
+}
+
+// isValidSegment ตรวจสอบว่าชื่อกลุ่มถูกต้องหรือไม่
+func isValidSegment(segment string) bool {
+	for _, name := range models.Segments {
+		if name == segment {
+			return true
+		}
+	}
+	return false
+}
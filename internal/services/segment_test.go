@@ -0,0 +1,82 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/db"
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// TestClassifySegment ทดสอบการจัดกลุ่มบุคคลตามเกณฑ์เริ่มต้น
+func TestClassifySegment(t *testing.T) {
+	settings := models.DefaultSegmentSettings("org-1")
+	asOf := time.Date(2025, 4, 30, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		visitCount int
+		lastSeen   time.Time
+		expected   string
+	}{
+		{"one visit recently", 1, asOf.AddDate(0, 0, -1), models.SegmentOneTimer},
+		{"few visits recently", 3, asOf.AddDate(0, 0, -2), models.SegmentOccasional},
+		{"boundary of occasional", 4, asOf, models.SegmentOccasional},
+		{"many visits recently", 12, asOf.AddDate(0, 0, -3), models.SegmentLoyal},
+		{"loyal but gone", 12, asOf.AddDate(0, 0, -31), models.SegmentLapsed},
+		{"exactly at lapse boundary", 1, asOf.AddDate(0, 0, -30), models.SegmentOneTimer},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, ClassifySegment(tt.visitCount, tt.lastSeen, asOf, settings))
+		})
+	}
+}
+
+// TestClassifySegment_CustomThresholds ทดสอบการจัดกลุ่มเมื่อองค์กรกำหนดเกณฑ์เอง
+func TestClassifySegment_CustomThresholds(t *testing.T) {
+	settings := models.SegmentSettings{OneTimerMaxVisits: 2, OccasionalMaxVisits: 10, LapsedAfterDays: 7}
+	asOf := time.Date(2025, 4, 30, 12, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, models.SegmentOneTimer, ClassifySegment(2, asOf, asOf, settings))
+	assert.Equal(t, models.SegmentOccasional, ClassifySegment(10, asOf, asOf, settings))
+	assert.Equal(t, models.SegmentLoyal, ClassifySegment(11, asOf, asOf, settings))
+	assert.Equal(t, models.SegmentLapsed, ClassifySegment(11, asOf.AddDate(0, 0, -8), asOf, settings))
+}
+
+// TestSegmentService_GetTrend ทดสอบว่าแนวโน้มอ่าน logs ครั้งเดียวแล้วสะสมการเข้าชมด้วย window function แทนการ join ทุกจุดเวลากับ logs ก่อนหน้าทั้งหมด
+func TestSegmentService_GetTrend(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { sqlDB.Close() })
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{Logger: logger.Discard})
+	require.NoError(t, err)
+	service := NewSegmentService(&db.PostgresDB{DB: gormDB})
+
+	from := time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2025, 4, 3, 0, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(`SELECT \* FROM "segment_settings"`).WillReturnError(gorm.ErrRecordNotFound)
+	mock.ExpectQuery(`(?s)FROM person_logs l\s+JOIN points p ON l.timestamp < p.d \+ INTERVAL '1 day'\s+AND \(p.prev_d IS NULL OR l.timestamp >= p.prev_d \+ INTERVAL '1 day'\).*SUM\(visits\) OVER \(PARTITION BY person_hash ORDER BY d\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"date", "one_timer", "occasional", "loyal", "lapsed"}).
+			AddRow("2025-04-01", 2, 0, 0, 0).
+			AddRow("2025-04-02", 1, 1, 0, 0).
+			AddRow("2025-04-03", 1, 1, 0, 1))
+
+	points, err := service.GetTrend(context.Background(), "org-1", from, to, "day", models.StatsScope{})
+	require.NoError(t, err)
+	require.Len(t, points, 3)
+	assert.Equal(t, "2025-04-03", points[2].Date)
+	assert.Equal(t, 1, points[2].Lapsed)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	_, err = service.GetTrend(context.Background(), "org-1", from, to, "year", models.StatsScope{})
+	assert.EqualError(t, err, "interval ต้องเป็น day, week หรือ month")
+}
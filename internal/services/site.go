@@ -0,0 +1,129 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/db"
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// SiteService ให้บริการเกี่ยวกับการจัดการสาขา
+type SiteService struct {
+	DB *db.PostgresDB
+}
+
+// NewSiteService สร้าง SiteService ใหม่
+func NewSiteService(postgres *db.PostgresDB) *SiteService {
+	return &SiteService{
+		DB: postgres,
+	}
+}
+
+// CreateSite สร้างสาขาใหม่
+func (s *SiteService) CreateSite(ctx context.Context, site *models.Site) error {
+	// สร้าง ID ใหม่ถ้ายังไม่มี
+	if site.ID == "" {
+		site.ID = uuid.New().String()
+	}
+
+	if err := s.DB.DB.WithContext(ctx).Create(site).Error; err != nil {
+		return fmt.Errorf("ไม่สามารถสร้างสาขา: %w", err)
+	}
+
+	return nil
+}
+
+// GetSite ดึงข้อมูลสาขาตาม ID พร้อมรายการกล้องในสาขา
+func (s *SiteService) GetSite(ctx context.Context, id, organizationID string) (*models.Site, error) {
+	var site models.Site
+
+	result := s.DB.DB.WithContext(ctx).Preload("Cameras").Where("id = ? AND organization_id = ?", id, organizationID).First(&site)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("ไม่พบสาขา")
+		}
+		return nil, fmt.Errorf("ไม่สามารถดึงข้อมูลสาขา: %w", result.Error)
+	}
+
+	return &site, nil
+}
+
+// UpdateSite อัปเดตข้อมูลสาขา
+func (s *SiteService) UpdateSite(ctx context.Context, site *models.Site) error {
+	result := s.DB.DB.WithContext(ctx).Model(&models.Site{}).Where("id = ? AND organization_id = ?", site.ID, site.OrganizationID).Updates(map[string]interface{}{
+		"name":    site.Name,
+		"address": site.Address,
+	})
+
+	if result.Error != nil {
+		return fmt.Errorf("ไม่สามารถอัปเดตสาขา: %w", result.Error)
+	}
+
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("ไม่พบสาขาที่ต้องการอัปเดต")
+	}
+
+	return nil
+}
+
+// DeleteSite ลบสาขา โดยกล้องในสาขาจะไม่ถูกผูกกับสาขาใดอีก
+func (s *SiteService) DeleteSite(ctx context.Context, id, organizationID string) error {
+	return s.DB.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("id = ? AND organization_id = ?", id, organizationID).Delete(&models.Site{})
+		if result.Error != nil {
+			return fmt.Errorf("ไม่สามารถลบสาขา: %w", result.Error)
+		}
+
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("ไม่พบสาขาที่ต้องการลบ")
+		}
+
+		if err := tx.Model(&models.Camera{}).Where("site_id = ? AND organization_id = ?", id, organizationID).Update("site_id", nil).Error; err != nil {
+			return fmt.Errorf("ไม่สามารถยกเลิกการผูกกล้องกับสาขา: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// ListSites ดึงรายการสาขาทั้งหมดขององค์กร
+func (s *SiteService) ListSites(ctx context.Context, organizationID string, page, pageSize int) ([]models.Site, *models.Pagination, error) {
+	// คำนวณ offset
+	if page <= 0 {
+		page = 1
+	}
+	if pageSize <= 0 {
+		pageSize = 10
+	}
+	offset := (page - 1) * pageSize
+
+	// นับจำนวนสาขาทั้งหมด
+	var total int64
+	if err := s.DB.DB.WithContext(ctx).Model(&models.Site{}).Where("organization_id = ?", organizationID).Count(&total).Error; err != nil {
+		return nil, nil, fmt.Errorf("ไม่สามารถนับจำนวนสาขา: %w", err)
+	}
+
+	// ดึงข้อมูลสาขา
+	var sites []models.Site
+	if err := s.DB.DB.WithContext(ctx).Where("organization_id = ?", organizationID).Order("name").Limit(pageSize).Offset(offset).Find(&sites).Error; err != nil {
+		return nil, nil, fmt.Errorf("ไม่สามารถดึงรายการสาขา: %w", err)
+	}
+
+	// คำนวณจำนวนหน้าทั้งหมด
+	totalPage := int(total) / pageSize
+	if int(total)%pageSize > 0 {
+		totalPage++
+	}
+
+	pagination := &models.Pagination{
+		Total:     int(total),
+		Page:      page,
+		PageSize:  pageSize,
+		TotalPage: totalPage,
+	}
+
+	return sites, pagination, nil
+}
@@ -2,11 +2,13 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/bemindtech/bmt-manta-dashboard-service/internal/db"
 	"github.com/bemindtech/bmt-manta-dashboard-service/internal/models"
+	"gorm.io/gorm"
 )
 
 // StatsService เป็นโครงสร้างสำหรับการวิเคราะห์ข้อมูล
@@ -23,10 +25,43 @@ func NewStatsService(postgres *db.PostgresDB, redis *db.RedisClient) *StatsServi
 	}
 }
 
-// GetDailySummary ดึงข้อมูลสรุปรายวัน
-func (s *StatsService) GetDailySummary(ctx context.Context, date string, organizationID string) (*models.DailySummary, error) {
+// statsCachePrefixes เป็น prefix ของ cache สถิติรายวันที่ต้องล้างเมื่อบุคคลที่นับเปลี่ยน
+var statsCachePrefixes = []string{"daily_summary", "heatmap", "person_stats"}
+
+// statsCacheKey สร้าง key ของ cache สถิติ โดยแยก key ของสถิติที่นับรวมพนักงานและสถิติของการค้นหาที่บันทึกไว้
+func statsCacheKey(prefix, organizationID, date string, scope models.StatsScope) string {
+	key := fmt.Sprintf("%s:%s:%s", prefix, organizationID, date)
+	if scope.IncludeStaff {
+		key += ":with_staff"
+	}
+	if scope.SearchKey != "" {
+		key += ":search:" + scope.SearchKey
+	}
+	return key
+}
+
+// InvalidateOrganizationCache ล้าง cache สถิติทั้งหมดขององค์กร เพื่อให้การเปลี่ยนแปลงมีผลย้อนหลังทันที
+func (s *StatsService) InvalidateOrganizationCache(ctx context.Context, organizationID string) error {
+	if s.Redis == nil {
+		return nil
+	}
+	for _, prefix := range statsCachePrefixes {
+		if err := s.Redis.DeletePattern(ctx, fmt.Sprintf("%s:%s:*", prefix, organizationID)); err != nil {
+			return fmt.Errorf("ไม่สามารถล้าง cache สถิติ: %w", err)
+		}
+	}
+	return nil
+}
+
+// GetDailySummary ดึงข้อมูลสรุปรายวัน โดยไม่นับบุคคลที่มีป้ายกำกับ staff เว้นแต่ scope กำหนดให้นับรวม
+func (s *StatsService) GetDailySummary(ctx context.Context, date string, organizationID string, scope models.StatsScope) (*models.DailySummary, error) {
+	scope, err := resolveStatsScope(ctx, s.DB.DB, organizationID, scope)
+	if err != nil {
+		return nil, err
+	}
+
 	// ตรวจสอบใน Redis cache ก่อน
-	cacheKey := fmt.Sprintf("daily_summary:%s:%s", organizationID, date)
+	cacheKey := statsCacheKey("daily_summary", organizationID, date, scope)
 	var summary models.DailySummary
 	
 	// ดึงข้อมูลจาก cache
@@ -57,6 +92,7 @@ func (s *StatsService) GetDailySummary(ctx context.Context, date string, organiz
 
 	// ดึงจำนวนรายการทั้งหมด
 	if err := s.DB.DB.WithContext(ctx).Model(&models.PersonLog{}).
+		Scopes(excludeStaff(scope, "person_logs"), matchSearch(scope, "person_logs")).
 		Where("timestamp >= ? AND timestamp < ? AND organization_id = ?", startOfDay, endOfDay, organizationID).
 		Count(&total).Error; err != nil {
 		return nil, fmt.Errorf("ไม่สามารถดึงข้อมูลสรุปรายวัน (total): %w", err)
@@ -64,6 +100,7 @@ func (s *StatsService) GetDailySummary(ctx context.Context, date string, organiz
 
 	// ดึงจำนวนคนใหม่
 	if err := s.DB.DB.WithContext(ctx).Model(&models.PersonLog{}).
+		Scopes(excludeStaff(scope, "person_logs"), matchSearch(scope, "person_logs")).
 		Where("timestamp >= ? AND timestamp < ? AND organization_id = ? AND is_new_person = ?", startOfDay, endOfDay, organizationID, true).
 		Count(&newCount).Error; err != nil {
 		return nil, fmt.Errorf("ไม่สามารถดึงข้อมูลสรุปรายวัน (new count): %w", err)
@@ -71,6 +108,7 @@ func (s *StatsService) GetDailySummary(ctx context.Context, date string, organiz
 
 	// ดึงจำนวนคนซ้ำ
 	if err := s.DB.DB.WithContext(ctx).Model(&models.PersonLog{}).
+		Scopes(excludeStaff(scope, "person_logs"), matchSearch(scope, "person_logs")).
 		Where("timestamp >= ? AND timestamp < ? AND organization_id = ? AND is_new_person = ?", startOfDay, endOfDay, organizationID, false).
 		Count(&repeatCount).Error; err != nil {
 		return nil, fmt.Errorf("ไม่สามารถดึงข้อมูลสรุปรายวัน (repeat count): %w", err)
@@ -92,10 +130,15 @@ func (s *StatsService) GetDailySummary(ctx context.Context, date string, organiz
 	return &summary, nil
 }
 
-// GetHeatmapData ดึงข้อมูลความหนาแน่นตามช่วงเวลา
-func (s *StatsService) GetHeatmapData(ctx context.Context, date string, organizationID string) ([]models.HeatmapData, error) {
+// GetHeatmapData ดึงข้อมูลความหนาแน่นตามช่วงเวลา โดยไม่นับพนักงานเว้นแต่ scope กำหนดให้นับรวม
+func (s *StatsService) GetHeatmapData(ctx context.Context, date string, organizationID string, scope models.StatsScope) ([]models.HeatmapData, error) {
+	scope, err := resolveStatsScope(ctx, s.DB.DB, organizationID, scope)
+	if err != nil {
+		return nil, err
+	}
+
 	// ตรวจสอบใน Redis cache ก่อน
-	cacheKey := fmt.Sprintf("heatmap:%s:%s", organizationID, date)
+	cacheKey := statsCacheKey("heatmap", organizationID, date, scope)
 	var heatmap []models.HeatmapData
 	
 	// ดึงข้อมูลจาก cache
@@ -127,15 +170,20 @@ func (s *StatsService) GetHeatmapData(ctx context.Context, date string, organiza
 	}
 
 	// ดึงข้อมูลโดยใช้ GORM Raw
+	search, searchArgs := searchClause(scope, "person_logs.person_hash", "person_logs.organization_id")
 	if err := s.DB.DB.WithContext(ctx).Raw(`
 		SELECT 
 			TO_CHAR(timestamp, 'HH24:00') as hour,
 			COUNT(*) as count
 		FROM person_logs
-		WHERE timestamp >= ? AND timestamp < ? AND organization_id = ?
+		WHERE timestamp >= @start AND timestamp < @end AND organization_id = @org_id`+staffClause(scope, "person_logs.person_hash", "person_logs.organization_id")+search+`
 		GROUP BY hour
 		ORDER BY hour
-	`, startOfDay, endOfDay, organizationID).Scan(&result).Error; err != nil {
+	`, withSearchArgs(map[string]interface{}{
+		"start":  startOfDay,
+		"end":    endOfDay,
+		"org_id": organizationID,
+	}, searchArgs)).Scan(&result).Error; err != nil {
 		return nil, fmt.Errorf("ไม่สามารถดึงข้อมูล heatmap: %w", err)
 	}
 
@@ -156,10 +204,15 @@ func (s *StatsService) GetHeatmapData(ctx context.Context, date string, organiza
 	return heatmap, nil
 }
 
-// GetPersonStats ดึงข้อมูลสถิติคนใหม่และคนซ้ำ
-func (s *StatsService) GetPersonStats(ctx context.Context, date string, organizationID string) (*models.PersonStats, error) {
+// GetPersonStats ดึงข้อมูลสถิติคนใหม่และคนซ้ำ โดยไม่นับพนักงานเว้นแต่ scope กำหนดให้นับรวม
+func (s *StatsService) GetPersonStats(ctx context.Context, date string, organizationID string, scope models.StatsScope) (*models.PersonStats, error) {
+	scope, err := resolveStatsScope(ctx, s.DB.DB, organizationID, scope)
+	if err != nil {
+		return nil, err
+	}
+
 	// ตรวจสอบใน Redis cache ก่อน
-	cacheKey := fmt.Sprintf("person_stats:%s:%s", organizationID, date)
+	cacheKey := statsCacheKey("person_stats", organizationID, date, scope)
 	var stats models.PersonStats
 	
 	// ดึงข้อมูลจาก cache
@@ -189,6 +242,7 @@ func (s *StatsService) GetPersonStats(ctx context.Context, date string, organiza
 
 	// นับจำนวนคนใหม่
 	if err := s.DB.DB.WithContext(ctx).Model(&models.PersonLog{}).
+		Scopes(excludeStaff(scope, "person_logs"), matchSearch(scope, "person_logs")).
 		Where("timestamp >= ? AND timestamp < ? AND organization_id = ? AND is_new_person = ?", 
 			startOfDay, endOfDay, organizationID, true).
 		Count(&newCount).Error; err != nil {
@@ -197,6 +251,7 @@ func (s *StatsService) GetPersonStats(ctx context.Context, date string, organiza
 
 	// นับจำนวนคนซ้ำ
 	if err := s.DB.DB.WithContext(ctx).Model(&models.PersonLog{}).
+		Scopes(excludeStaff(scope, "person_logs"), matchSearch(scope, "person_logs")).
 		Where("timestamp >= ? AND timestamp < ? AND organization_id = ? AND is_new_person = ?", 
 			startOfDay, endOfDay, organizationID, false).
 		Count(&repeatCount).Error; err != nil {
@@ -217,67 +272,71 @@ func (s *StatsService) GetPersonStats(ctx context.Context, date string, organiza
 	return &stats, nil
 }
 
-// GetLogs ดึงข้อมูล logs ตามเงื่อนไข
-func (s *StatsService) GetLogs(ctx context.Context, filter models.LogFilter) ([]models.PersonLog, *models.Pagination, error) {
-	// จัดการ pagination
-	if filter.Page <= 0 {
-		filter.Page = 1
-	}
-	if filter.PageSize <= 0 {
-		filter.PageSize = 10
-	}
-
-	// คำนวณ offset
-	offset := (filter.Page - 1) * filter.PageSize
-
-	// สร้าง query ด้วย GORM
-	query := s.DB.DB.WithContext(ctx).Model(&models.PersonLog{})
+// logListSpec กำหนดการเรียงลำดับและฟิลด์ที่เลือกได้ของรายการ logs
+var logListSpec = listSpec[models.PersonLog]{
+	Sorts: map[string]sortField{
+		"timestamp": {Column: "timestamp", Kind: sortKindTime},
+	},
+	DefaultSort:  "timestamp",
+	DefaultOrder: "desc",
+	Fields: map[string]string{
+		"id":              "id",
+		"created_at":      "created_at",
+		"updated_at":      "updated_at",
+		"timestamp":       "timestamp",
+		"person_hash":     "person_hash",
+		"reported_hash":   "reported_hash",
+		"camera_id":       "camera_id",
+		"is_new_person":   "is_new_person",
+		"direction":       "direction",
+		"organization_id": "organization_id",
+	},
+	Key: func(log models.PersonLog, sort string) (interface{}, string) {
+		return log.Timestamp, log.ID
+	},
+}
 
-	// เพิ่มเงื่อนไขการค้นหา
+// applyLogFilter เพิ่มเงื่อนไขของ LogFilter ให้กับ query โดย prefix เป็นชื่อย่อของตาราง person_logs (เช่น "l.") หรือว่าง
+func applyLogFilter(query *gorm.DB, filter models.LogFilter, prefix string) *gorm.DB {
+	if filter.OrganizationID != "" {
+		query = query.Where(prefix+"organization_id = ?", filter.OrganizationID)
+	}
 	if !filter.From.IsZero() {
-		query = query.Where("timestamp >= ?", filter.From)
+		query = query.Where(prefix+"timestamp >= ?", filter.From)
 	}
 	if !filter.To.IsZero() {
-		query = query.Where("timestamp < ?", filter.To)
+		query = query.Where(prefix+"timestamp < ?", filter.To)
 	}
 	if filter.CameraID != "" {
-		query = query.Where("camera_id = ?", filter.CameraID)
+		query = query.Where(prefix+"camera_id = ?", filter.CameraID)
 	}
-	if filter.PersonID != "" {
-		query = query.Where("person_hash = ?", filter.PersonID)
+	if len(filter.CameraIDs) > 0 {
+		query = query.Where(prefix+"camera_id IN ?", filter.CameraIDs)
 	}
-	if filter.OrganizationID != "" {
-		query = query.Where("organization_id = ?", filter.OrganizationID)
+	if len(filter.Zones) > 0 {
+		query = query.Where(prefix+"camera_id IN (SELECT id FROM cameras WHERE organization_id = ? AND zone IN ? AND deleted_at IS NULL)", filter.OrganizationID, filter.Zones)
 	}
-
-	// นับจำนวนรายการทั้งหมด
-	var total int64
-	if err := query.Count(&total).Error; err != nil {
-		return nil, nil, fmt.Errorf("ไม่สามารถนับจำนวนรายการทั้งหมด: %w", err)
+	if filter.IsNewPerson != nil {
+		query = query.Where(prefix+"is_new_person = ?", *filter.IsNewPerson)
 	}
-
-	// ดึงข้อมูลพร้อม pagination
-	var logs []models.PersonLog
-	if err := query.Order("timestamp DESC").
-		Limit(filter.PageSize).
-		Offset(offset).
-		Find(&logs).Error; err != nil {
-		return nil, nil, fmt.Errorf("ไม่สามารถดึงข้อมูล logs: %w", err)
+	if filter.PersonID != "" {
+		query = query.Where(prefix+"person_hash = ?", filter.PersonID)
 	}
+	return query
+}
 
-	// คำนวณจำนวนหน้าทั้งหมด
-	totalPage := int(total) / filter.PageSize
-	if int(total)%filter.PageSize > 0 {
-		totalPage++
-	}
+// GetLogs ดึงข้อมูล logs ตามเงื่อนไข โดยแบ่งหน้าด้วย cursor บน (timestamp, id)
+func (s *StatsService) GetLogs(ctx context.Context, filter models.LogFilter, opts models.ListOptions) ([]models.PersonLog, *models.CursorPagination, error) {
+	query := applyLogFilter(s.DB.DB.WithContext(ctx).Model(&models.PersonLog{}), filter, "")
 
-	// สร้างข้อมูล pagination
-	pagination := &models.Pagination{
-		Total:     int(total),
-		Page:      filter.Page,
-		PageSize:  filter.PageSize,
-		TotalPage: totalPage,
+	logs, pagination, err := paginate(query, logListSpec, opts)
+	if err != nil {
+		if errors.Is(err, ErrInvalidListOptions) {
+			return nil, nil, err
+		}
+		return nil, nil, fmt.Errorf("ไม่สามารถดึงข้อมูล logs: %w", err)
 	}
 
 	return logs, pagination, nil
-} 
\ No newline at end of file
+}
+ 
\ No newline at end of file
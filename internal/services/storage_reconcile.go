@@ -0,0 +1,286 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/models"
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/storage"
+)
+
+const (
+	// storageReconcileMinAge อายุขั้นต่ำของ object ที่ถือว่าไม่มีรูปภาพใช้
+	// การอัปโหลดบันทึกไฟล์ก่อนบันทึกข้อมูลรูปภาพ object ที่ใหม่กว่านี้จึงอาจเป็นของการอัปโหลดที่ยังไม่เสร็จ
+	storageReconcileMinAge = time.Hour
+	// storageReconcileChunkSize จำนวนรูปภาพที่ตรวจต่อการดึงข้อมูลหนึ่งครั้ง
+	storageReconcileChunkSize = 1000
+	// maxStorageReconcileItems จำนวน object และไฟล์ที่หายไปสูงสุดที่แสดงในผลลัพธ์ และจำนวนข้อผิดพลาดสูงสุดที่บันทึก
+	maxStorageReconcileItems = 1000
+)
+
+// storageReconcileSkippedDirs เป็นโฟลเดอร์ขององค์กรที่ไม่ได้เก็บไฟล์ของรูปภาพ จึงไม่ถูกตรวจ:
+// ไฟล์ที่รอยืนยันการอัปโหลด (ถูกเก็บกวาดโดยงานของคำขออัปโหลด) และไฟล์ที่ถูกกักไว้
+var storageReconcileSkippedDirs = []string{"uploads/", "quarantine/"}
+
+// storageReconcileObject เป็น object ที่พบใน storage และบอกว่ามีรูปภาพใช้หรือไม่
+type storageReconcileObject struct {
+	info storage.ObjectInfo
+	used bool
+}
+
+// quarantineKey สร้าง object key ที่ใช้กัก object ขององค์กร โดยคง path เดิมไว้ใต้โฟลเดอร์ quarantine
+func quarantineKey(organizationID, key string) string {
+	return organizationID + "/quarantine/" + strings.TrimPrefix(key, organizationID+"/")
+}
+
+// StartStorageReconcile สร้างงานเบื้องหลังที่เปรียบเทียบไฟล์ใน storage ขององค์กรกับข้อมูลรูปภาพใบหน้า
+func (s *FaceService) StartStorageReconcile(ctx context.Context, organizationID string, params models.StorageReconcileParams) (*models.Job, error) {
+	if organizationID == "" {
+		return nil, fmt.Errorf("ไม่พบข้อมูลองค์กร")
+	}
+	if params.ObjectAction == "" {
+		params.ObjectAction = models.StorageReconcileReport
+	}
+	if params.ImageAction == "" {
+		params.ImageAction = models.StorageReconcileReport
+	}
+	switch params.ObjectAction {
+	case models.StorageReconcileReport, models.StorageReconcileDelete, models.StorageReconcileQuarantine:
+	default:
+		return nil, fmt.Errorf("object_action ต้องเป็น report, delete หรือ quarantine")
+	}
+	switch params.ImageAction {
+	case models.StorageReconcileReport, models.StorageReconcileDelete:
+	default:
+		return nil, fmt.Errorf("image_action ต้องเป็น report หรือ delete")
+	}
+
+	job, err := s.Jobs.CreateJob(ctx, organizationID, models.JobTypeStorageReconcile, params)
+	if err != nil {
+		return nil, err
+	}
+
+	s.Jobs.Run(job, func(ctx context.Context, job *models.Job, progress func(int64)) (interface{}, error) {
+		return s.RunStorageReconcile(ctx, job.OrganizationID, params, progress)
+	})
+	return job, nil
+}
+
+// GetStorageReconcileJob ดึงงานเปรียบเทียบไฟล์ใน storage กับข้อมูลรูปภาพขององค์กร
+func (s *FaceService) GetStorageReconcileJob(ctx context.Context, id, organizationID string) (*models.Job, error) {
+	job, err := s.Jobs.GetJob(ctx, id, organizationID)
+	if err != nil {
+		return nil, err
+	}
+	if job.Type != models.JobTypeStorageReconcile {
+		return nil, fmt.Errorf("ไม่พบงาน")
+	}
+	return job, nil
+}
+
+// RunStorageReconcile เปรียบเทียบ object ใน storage ขององค์กรกับรูปภาพใบหน้าที่ยังไม่ถูกลบทั้งสองทาง:
+// object ที่ไม่มีรูปภาพใช้ (เช่น ไฟล์ของบุคคลที่ถูกลบ หรือของการอัปโหลดที่ล้มเหลวกลางทาง) ถูกรายงาน ลบ หรือกักไว้ตาม ObjectAction
+// และรูปภาพที่ไม่มีไฟล์รูปใน storage ถูกรายงานหรือลบตาม ImageAction ส่วนรูปย่อที่หายไปถูกรายงานเท่านั้น
+// เมื่อ DryRun เป็น true ผลลัพธ์นับและแสดงสิ่งที่จะถูกลบหรือกักไว้โดยไม่เปลี่ยนแปลงอะไร
+func (s *FaceService) RunStorageReconcile(ctx context.Context, organizationID string, params models.StorageReconcileParams, progress func(int64)) (*models.StorageReconcileResult, error) {
+	// รูปภาพที่บันทึกหลังเริ่มตรวจอาจมีไฟล์ที่ไม่อยู่ในรายการ object จึงตรวจเฉพาะรูปที่บันทึกก่อนเริ่ม
+	started := time.Now()
+	result := &models.StorageReconcileResult{DryRun: params.DryRun}
+
+	prefix := organizationID + "/"
+	objects := make(map[string]*storageReconcileObject)
+	err := s.Storage.ListObjects(ctx, prefix, func(key string, info storage.ObjectInfo) error {
+		for _, dir := range storageReconcileSkippedDirs {
+			if strings.HasPrefix(key, prefix+dir) {
+				return nil
+			}
+		}
+		objects[key] = &storageReconcileObject{info: info}
+		result.Objects++
+		if result.Objects%storageReconcileChunkSize == 0 {
+			progress(result.Objects)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("ไม่สามารถดึงรายการไฟล์ใน storage: %w", err)
+	}
+
+	if err := s.reconcileFaceImages(ctx, organizationID, params, started, objects, result, progress); err != nil {
+		return nil, err
+	}
+	s.reconcileOrphanObjects(ctx, organizationID, params, started, objects, result)
+	return result, nil
+}
+
+// reconcileFaceImages ทำเครื่องหมาย object ที่รูปภาพใช้ และรายงาน (หรือลบ) รูปภาพที่ไฟล์หายไป
+func (s *FaceService) reconcileFaceImages(ctx context.Context, organizationID string, params models.StorageReconcileParams, started time.Time, objects map[string]*storageReconcileObject, result *models.StorageReconcileResult, progress func(int64)) error {
+	lastID := ""
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		var images []models.FaceImage
+		if err := s.DB.DB.WithContext(ctx).
+			Select("id", "person_hash", "image_key", "thumbnail_keys").
+			Where("organization_id = ? AND created_at < ? AND id > ?", organizationID, started, lastID).
+			Order("id").Limit(storageReconcileChunkSize).
+			Find(&images).Error; err != nil {
+			return fmt.Errorf("ไม่สามารถดึงข้อมูลรูปภาพ: %w", err)
+		}
+		if len(images) == 0 {
+			return nil
+		}
+
+		for i := range images {
+			s.reconcileFaceImage(ctx, organizationID, &images[i], params, objects, result)
+		}
+		result.Images += int64(len(images))
+		progress(result.Objects + result.Images)
+		lastID = images[len(images)-1].ID
+	}
+}
+
+// reconcileFaceImage ตรวจไฟล์รูปและรูปย่อของรูปภาพหนึ่งรูป
+func (s *FaceService) reconcileFaceImage(ctx context.Context, organizationID string, faceImage *models.FaceImage, params models.StorageReconcileParams, objects map[string]*storageReconcileObject, result *models.StorageReconcileResult) {
+	missing := func(key, thumbnail, action string) {
+		result.MissingFiles++
+		if len(result.Missing) >= maxStorageReconcileItems {
+			result.Truncated = true
+			return
+		}
+		result.Missing = append(result.Missing, models.StorageMissingFile{
+			FaceImageID: faceImage.ID,
+			PersonHash:  faceImage.PersonHash,
+			Key:         key,
+			Thumbnail:   thumbnail,
+			Action:      action,
+		})
+	}
+
+	sizes := make([]string, 0, len(faceImage.ThumbnailKeys))
+	for size := range faceImage.ThumbnailKeys {
+		sizes = append(sizes, size)
+	}
+	sort.Strings(sizes)
+	for _, size := range sizes {
+		key := faceImage.ThumbnailKeys[size]
+		if object := objects[key]; object != nil {
+			object.used = true
+		} else {
+			missing(key, size, "")
+		}
+	}
+
+	if faceImage.ImageKey == "" {
+		return
+	}
+	if object := objects[faceImage.ImageKey]; object != nil {
+		object.used = true
+		return
+	}
+
+	if params.ImageAction != models.StorageReconcileDelete {
+		missing(faceImage.ImageKey, "", "")
+		return
+	}
+	if !params.DryRun {
+		// ลบรูปภาพด้วยวิธีเดียวกับ API ซึ่งลบ embedding และรูปย่อที่เหลือ และเลือกรูปภาพหลักของบุคคลใหม่ด้วย
+		if err := s.DeleteFaceImage(ctx, faceImage.ID, organizationID); err != nil {
+			missing(faceImage.ImageKey, "", "")
+			storageReconcileFailed(result, models.StorageReconcileFailure{FaceImageID: faceImage.ID, Error: err.Error()})
+			return
+		}
+	}
+	missing(faceImage.ImageKey, "", "deleted")
+	result.ImagesDeleted++
+}
+
+// reconcileOrphanObjects รายงาน ลบ หรือกัก object ที่ไม่มีรูปภาพใช้ ตามลำดับของ key
+// object ที่ใหม่กว่า storageReconcileMinAge ก่อนเริ่มตรวจถูกข้าม
+func (s *FaceService) reconcileOrphanObjects(ctx context.Context, organizationID string, params models.StorageReconcileParams, started time.Time, objects map[string]*storageReconcileObject, result *models.StorageReconcileResult) {
+	keys := make([]string, 0)
+	for key, object := range objects {
+		if !object.used && object.info.ModTime.Before(started.Add(-storageReconcileMinAge)) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if ctx.Err() != nil {
+			return
+		}
+		info := objects[key].info
+		result.OrphanObjects++
+		result.OrphanBytes += info.Size
+
+		action := ""
+		var err error
+		switch params.ObjectAction {
+		case models.StorageReconcileDelete:
+			if !params.DryRun {
+				err = s.Storage.DeleteObject(ctx, key)
+			}
+			if err == nil {
+				action = "deleted"
+				result.ObjectsDeleted++
+			}
+		case models.StorageReconcileQuarantine:
+			if !params.DryRun {
+				err = s.quarantineObject(ctx, organizationID, key)
+			}
+			if err == nil {
+				action = "quarantined"
+				result.ObjectsQuarantined++
+			}
+		}
+		if err != nil {
+			storageReconcileFailed(result, models.StorageReconcileFailure{Key: key, Error: err.Error()})
+		}
+
+		if len(result.Orphans) >= maxStorageReconcileItems {
+			result.Truncated = true
+			continue
+		}
+		result.Orphans = append(result.Orphans, models.StorageOrphanObject{
+			Key:     key,
+			Size:    info.Size,
+			ModTime: info.ModTime,
+			Action:  action,
+		})
+	}
+}
+
+// quarantineObject ย้าย object ไปไว้ใต้โฟลเดอร์ quarantine ขององค์กร เพื่อให้กู้คืนได้ถ้าพบว่ายังต้องใช้
+func (s *FaceService) quarantineObject(ctx context.Context, organizationID, key string) error {
+	info, err := s.Storage.StatObject(ctx, key)
+	if err != nil {
+		return fmt.Errorf("ไม่สามารถอ่านข้อมูลไฟล์: %w", err)
+	}
+	reader, size, err := s.Storage.GetObject(ctx, key)
+	if err != nil {
+		return fmt.Errorf("ไม่สามารถอ่านไฟล์: %w", err)
+	}
+	defer reader.Close()
+
+	if err := s.Storage.PutObject(ctx, quarantineKey(organizationID, key), reader, size, info.ContentType); err != nil {
+		return fmt.Errorf("ไม่สามารถกักไฟล์: %w", err)
+	}
+	if err := s.Storage.DeleteObject(ctx, key); err != nil {
+		return fmt.Errorf("ไม่สามารถลบไฟล์หลังกักไว้: %w", err)
+	}
+	return nil
+}
+
+// storageReconcileFailed นับข้อผิดพลาด และบันทึกไว้ในผลลัพธ์ไม่เกินจำนวนสูงสุด
+func storageReconcileFailed(result *models.StorageReconcileResult, failure models.StorageReconcileFailure) {
+	result.Failed++
+	if len(result.Failures) < maxStorageReconcileItems {
+		result.Failures = append(result.Failures, failure)
+	}
+}
@@ -0,0 +1,127 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/models"
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newReconcileStorage สร้าง storage ในเครื่องที่มี object ตาม key โดยกำหนดเวลาแก้ไขของแต่ละ object
+func newReconcileStorage(t *testing.T, modTimes map[string]time.Time) (*storage.LocalStorageService, map[string]*storageReconcileObject) {
+	t.Helper()
+	dir := t.TempDir()
+	store, err := storage.NewLocalStorageService(dir, "http://images.example.com/api/files", []byte("test-signing-key"))
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	for key, modTime := range modTimes {
+		require.NoError(t, store.PutObject(ctx, key, bytes.NewReader([]byte("image")), 5, "image/jpeg"))
+		require.NoError(t, os.Chtimes(filepath.Join(dir, key), modTime, modTime))
+	}
+
+	objects := make(map[string]*storageReconcileObject)
+	require.NoError(t, store.ListObjects(ctx, "org-1/", func(key string, info storage.ObjectInfo) error {
+		objects[key] = &storageReconcileObject{info: info}
+		return nil
+	}))
+	return store, objects
+}
+
+// TestReconcileFaceImage ทดสอบการทำเครื่องหมายไฟล์ที่รูปภาพใช้ และการรายงานไฟล์ที่หายไป
+func TestReconcileFaceImage(t *testing.T) {
+	old := time.Now().Add(-2 * time.Hour)
+	_, objects := newReconcileStorage(t, map[string]time.Time{
+		"org-1/p1_a.jpg":            old,
+		"org-1/thumbnails/a_48.jpg": old,
+	})
+	service := &FaceService{}
+	result := &models.StorageReconcileResult{}
+
+	present := &models.FaceImage{
+		PersonHash:    "p1",
+		ImageKey:      "org-1/p1_a.jpg",
+		ThumbnailKeys: map[string]string{"48": "org-1/thumbnails/a_48.jpg", "96": "org-1/thumbnails/a_96.jpg"},
+	}
+	present.ID = "a"
+	service.reconcileFaceImage(context.Background(), "org-1", present, models.StorageReconcileParams{ImageAction: models.StorageReconcileReport}, objects, result)
+	assert.True(t, objects["org-1/p1_a.jpg"].used)
+	assert.True(t, objects["org-1/thumbnails/a_48.jpg"].used)
+	assert.Equal(t, []models.StorageMissingFile{
+		{FaceImageID: "a", PersonHash: "p1", Key: "org-1/thumbnails/a_96.jpg", Thumbnail: "96"},
+	}, result.Missing)
+
+	// รูปที่ไฟล์รูปหายไปถูกนับว่าจะถูกลบเมื่อเป็น dry run โดยไม่ลบจริง
+	missing := &models.FaceImage{PersonHash: "p2", ImageKey: "org-1/p2_b.jpg"}
+	missing.ID = "b"
+	service.reconcileFaceImage(context.Background(), "org-1", missing, models.StorageReconcileParams{ImageAction: models.StorageReconcileDelete, DryRun: true}, objects, result)
+	assert.Equal(t, int64(2), result.MissingFiles)
+	assert.Equal(t, int64(1), result.ImagesDeleted)
+	assert.Equal(t, models.StorageMissingFile{FaceImageID: "b", PersonHash: "p2", Key: "org-1/p2_b.jpg", Action: "deleted"}, result.Missing[1])
+}
+
+// TestReconcileOrphanObjects ทดสอบว่าเฉพาะ object เก่าที่ไม่มีรูปภาพใช้ถูกลบหรือกักไว้ และ dry run ไม่เปลี่ยนแปลงอะไร
+func TestReconcileOrphanObjects(t *testing.T) {
+	old := time.Now().Add(-2 * time.Hour)
+	modTimes := map[string]time.Time{
+		"org-1/p1_a.jpg":            old,
+		"org-1/p1_orphan.jpg":       old,
+		"org-1/thumbnails/x_48.jpg": old,
+		"org-1/p1_recent.jpg":       time.Now(),
+	}
+	ctx := context.Background()
+
+	for _, test := range []struct {
+		name        string
+		params      models.StorageReconcileParams
+		action      string
+		remaining   []string
+		quarantined []string
+	}{
+		{"report", models.StorageReconcileParams{ObjectAction: models.StorageReconcileReport}, "", []string{"org-1/p1_orphan.jpg", "org-1/thumbnails/x_48.jpg"}, nil},
+		{"dry run", models.StorageReconcileParams{ObjectAction: models.StorageReconcileDelete, DryRun: true}, "deleted", []string{"org-1/p1_orphan.jpg", "org-1/thumbnails/x_48.jpg"}, nil},
+		{"delete", models.StorageReconcileParams{ObjectAction: models.StorageReconcileDelete}, "deleted", nil, nil},
+		{"quarantine", models.StorageReconcileParams{ObjectAction: models.StorageReconcileQuarantine}, "quarantined", nil, []string{"org-1/quarantine/p1_orphan.jpg", "org-1/quarantine/thumbnails/x_48.jpg"}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			store, objects := newReconcileStorage(t, modTimes)
+			objects["org-1/p1_a.jpg"].used = true
+			service := &FaceService{Storage: store}
+			result := &models.StorageReconcileResult{}
+
+			service.reconcileOrphanObjects(ctx, "org-1", test.params, time.Now(), objects, result)
+			assert.Equal(t, int64(2), result.OrphanObjects)
+			assert.Equal(t, int64(10), result.OrphanBytes)
+			assert.Zero(t, result.Failed)
+			require.Len(t, result.Orphans, 2)
+			assert.Equal(t, "org-1/p1_orphan.jpg", result.Orphans[0].Key)
+			assert.Equal(t, "org-1/thumbnails/x_48.jpg", result.Orphans[1].Key)
+			assert.Equal(t, test.action, result.Orphans[0].Action)
+
+			for _, key := range []string{"org-1/p1_a.jpg", "org-1/p1_recent.jpg"} {
+				_, err := store.StatObject(ctx, key)
+				assert.NoError(t, err, key)
+			}
+			for _, key := range []string{"org-1/p1_orphan.jpg", "org-1/thumbnails/x_48.jpg"} {
+				_, err := store.StatObject(ctx, key)
+				if slices.Contains(test.remaining, key) {
+					assert.NoError(t, err, key)
+				} else {
+					assert.ErrorIs(t, err, storage.ErrObjectNotFound, key)
+				}
+			}
+			for _, key := range test.quarantined {
+				_, err := store.StatObject(ctx, key)
+				assert.NoError(t, err, key)
+			}
+		})
+	}
+}
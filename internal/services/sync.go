@@ -2,15 +2,16 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"time"
 
 	"github.com/bemindtech/bmt-manta-dashboard-service/internal/db"
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/events"
 	"github.com/bemindtech/bmt-manta-dashboard-service/internal/firebase"
 	"github.com/bemindtech/bmt-manta-dashboard-service/internal/models"
 	"github.com/google/uuid"
-	"gorm.io/gorm"
 )
 
 // SyncService เป็นโครงสร้างสำหรับการซิงค์ข้อมูลจาก Firebase ไปยัง PostgreSQL
@@ -18,14 +19,20 @@ type SyncService struct {
 	DB           *db.PostgresDB
 	Firebase     *firebase.FirebaseClient
 	PersonService *PersonService
+	Occupancy     *OccupancyService
+	Watchlist     *WatchlistService
+	Events        *events.Broker
 }
 
 // NewSyncService สร้าง SyncService ใหม่
-func NewSyncService(postgres *db.PostgresDB, firebaseClient *firebase.FirebaseClient) *SyncService {
+func NewSyncService(postgres *db.PostgresDB, firebaseClient *firebase.FirebaseClient, occupancyService *OccupancyService, watchlistService *WatchlistService, broker *events.Broker) *SyncService {
 	return &SyncService{
 		DB:           postgres,
 		Firebase:     firebaseClient,
-		PersonService: NewPersonService(postgres),
+		PersonService: NewPersonService(postgres, nil, nil),
+		Occupancy:     occupancyService,
+		Watchlist:     watchlistService,
+		Events:        broker,
 	}
 }
 
@@ -104,6 +111,9 @@ func (s *SyncService) syncLogToPostgres(ctx context.Context, data map[string]int
 		return fmt.Errorf("ไม่พบหรือรูปแบบของ camera_id ไม่ถูกต้อง")
 	}
 
+	// ทิศทางของการตรวจจับ (ถ้ากล้องส่งมา)
+	direction, _ := data["direction"].(string)
+
 	// แปลง timestamp เป็น time.Time
 	timestampTime := time.Unix(int64(timestamp), 0)
 	
@@ -122,64 +132,84 @@ func (s *SyncService) syncLogToPostgres(ctx context.Context, data map[string]int
 		camera.OrganizationID = defaultOrg.ID
 	}
 
-	// ตรวจสอบว่ามี log นี้อยู่ในฐานข้อมูลแล้วหรือไม่
-	var existingLog models.PersonLog
-	result = s.DB.DB.WithContext(ctx).Where(
-		"person_hash = ? AND camera_id = ? AND timestamp = ?",
-		personHash, cameraID, timestampTime,
-	).First(&existingLog)
-
-	// ถ้ามีข้อมูลอยู่แล้ว ไม่ต้องเพิ่มใหม่
-	if result.Error == nil {
-		return nil
-	}
-
-	// ถ้าไม่พบข้อมูล (ErrRecordNotFound) ให้เพิ่มใหม่
-	if result.Error != gorm.ErrRecordNotFound {
-		return fmt.Errorf("ไม่สามารถตรวจสอบข้อมูลใน PostgreSQL: %w", result.Error)
+	// แปลง hash ที่ถูกรวมเข้ากับบุคคลอื่นแล้วเป็น hash ของบุคคลปัจจุบัน
+	reportedHash := ""
+	resolvedHash, err := s.PersonService.ResolvePersonHash(ctx, camera.OrganizationID, personHash)
+	if err != nil {
+		return err
 	}
-
-	// ตรวจสอบว่าเป็นคนใหม่หรือคนซ้ำ
-	var count int64
-	if err := s.DB.DB.WithContext(ctx).Model(&models.PersonLog{}).Where(
-		"person_hash = ? AND timestamp < ?",
-		personHash, timestampTime,
-	).Count(&count).Error; err != nil {
-		return fmt.Errorf("ไม่สามารถตรวจสอบประวัติบุคคล: %w", err)
+	if resolvedHash != personHash {
+		reportedHash = personHash
+		personHash = resolvedHash
 	}
 
-	// กำหนดว่าเป็นคนใหม่หรือไม่
-	isNewPerson := count == 0
-
-	// สร้าง log ใหม่
+	// สร้าง log ใหม่ สถานะคนใหม่กำหนดตอนบันทึก
 	newLog := models.PersonLog{
 		Base: models.Base{
 			ID:        id,
 			CreatedAt: time.Now(),
 			UpdatedAt: time.Now(),
 		},
-		Timestamp:     timestampTime,
-		PersonHash:    personHash,
-		CameraID:      cameraID,
-		IsNewPerson:   isNewPerson,
+		Timestamp:      timestampTime,
+		PersonHash:     personHash,
+		ReportedHash:   reportedHash,
+		CameraID:       cameraID,
+		Direction:      NormalizeDirection(direction),
 		OrganizationID: camera.OrganizationID,
 	}
 
-	// เพิ่มข้อมูลใน PostgreSQL
-	if err := s.DB.DB.WithContext(ctx).Create(&newLog).Error; err != nil {
-		return fmt.Errorf("ไม่สามารถเพิ่มข้อมูลใน PostgreSQL: %w", err)
+	// เพิ่ม log และอัปเดตหรือสร้างข้อมูลบุคคลใน transaction เดียวกัน
+	if _, err := s.PersonService.RecordPersonLog(ctx, &newLog); err != nil {
+		// ถ้ามีข้อมูลอยู่แล้ว ไม่ต้องเพิ่มใหม่
+		if errors.Is(err, ErrDuplicatePersonLog) {
+			return nil
+		}
+		return err
 	}
 
-	// อัปเดตหรือสร้างข้อมูลบุคคล
-	if _, personErr := s.PersonService.CreateOrUpdatePerson(ctx, &newLog); personErr != nil {
-		log.Printf("ไม่สามารถอัปเดตข้อมูลบุคคล: %v", personErr)
-		// ดำเนินการต่อแม้จะมีข้อผิดพลาดในการอัปเดตข้อมูลบุคคล
+	// ปรับจำนวนคนในสาขาตามบทบาทของกล้อง
+	if s.Occupancy != nil {
+		if err := s.Occupancy.RecordDetection(ctx, &camera, &newLog); err != nil {
+			log.Printf("ไม่สามารถปรับจำนวนคนในสาขา: %v", err)
+		}
 	}
 
-	log.Printf("ซิงค์ข้อมูล log %s สำเร็จ (คนใหม่: %v)", id, isNewPerson)
+	// ส่งการตรวจจับใหม่ไปยัง live stream
+	s.publishDetection(ctx, &camera, &newLog)
+
+	// แจ้งเตือนถ้าบุคคลอยู่ในรายการเฝ้าระวังขององค์กร
+	if s.Watchlist != nil {
+		if err := s.Watchlist.MatchDetection(ctx, &camera, &newLog); err != nil {
+			log.Printf("ไม่สามารถตรวจรายการเฝ้าระวัง: %v", err)
+		}
+	}
+
+	log.Printf("ซิงค์ข้อมูล log %s สำเร็จ (คนใหม่: %v)", id, newLog.IsNewPerson)
 	return nil
 }
 
+// publishDetection ส่งการตรวจจับไปยัง live stream ขององค์กร
+func (s *SyncService) publishDetection(ctx context.Context, camera *models.Camera, personLog *models.PersonLog) {
+	if s.Events == nil {
+		return
+	}
+
+	event, err := events.NewEvent(events.TypeDetection, personLog.OrganizationID, personLog.CameraID, camera.Zone, models.DetectionPayload{
+		ID:          personLog.ID,
+		Timestamp:   personLog.Timestamp,
+		PersonHash:  personLog.PersonHash,
+		CameraID:    personLog.CameraID,
+		IsNewPerson: personLog.IsNewPerson,
+		Direction:   personLog.Direction,
+	})
+	if err == nil {
+		err = s.Events.Publish(ctx, event)
+	}
+	if err != nil {
+		log.Printf("ไม่สามารถส่งการตรวจจับไปยัง live stream: %v", err)
+	}
+}
+
 // SyncPersonLogToFirebase ซิงค์ข้อมูล log จาก PostgreSQL ไปยัง Firebase (ถ้าจำเป็น)
 func (s *SyncService) SyncPersonLogToFirebase(ctx context.Context, personLog models.PersonLog, logsPath string) error {
 	// สร้างข้อมูลสำหรับบันทึกใน Firebase
@@ -188,6 +218,9 @@ func (s *SyncService) SyncPersonLogToFirebase(ctx context.Context, personLog mod
 		"person_hash": personLog.PersonHash,
 		"camera_id":   personLog.CameraID,
 	}
+	if personLog.Direction != "" {
+		data["direction"] = personLog.Direction
+	}
 
 	// บันทึกข้อมูลใน Firebase
 	ref := s.Firebase.DB.NewRef(fmt.Sprintf("%s/%s", logsPath, personLog.ID))
@@ -0,0 +1,273 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"io"
+	"log"
+	"slices"
+	"strconv"
+
+	// ลงทะเบียนตัวถอดรหัส PNG และ WebP ให้ image.Decode (JPEG ลงทะเบียนจากการ import image/jpeg)
+	_ "image/png"
+
+	_ "golang.org/x/image/webp"
+
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/models"
+	xdraw "golang.org/x/image/draw"
+	"gorm.io/gorm"
+)
+
+const (
+	// thumbnailQuality คุณภาพ JPEG ของรูปย่อ
+	thumbnailQuality = 85
+	// maxImagePixels จำนวน pixel สูงสุดของรูปที่ยอมถอดรหัส เพื่อป้องกันรูปที่ขยายเต็มหน่วยความจำ
+	maxImagePixels = 40_000_000
+	// thumbnailBackfillChunkSize จำนวนรูปภาพที่ดึงต่อครั้งในงานสร้างรูปย่อย้อนหลัง
+	thumbnailBackfillChunkSize = 100
+	// maxThumbnailBackfillFailures จำนวนรายการรูปที่สร้างไม่สำเร็จสูงสุดที่เก็บในผลลัพธ์
+	maxThumbnailBackfillFailures = 100
+)
+
+// decodeFaceImage ถอดรหัสรูปภาพ JPEG, PNG หรือ WebP
+func decodeFaceImage(r io.ReadSeeker) (image.Image, error) {
+	config, _, err := image.DecodeConfig(r)
+	if errors.Is(err, image.ErrFormat) {
+		return nil, &FaceImageError{Code: FaceImageUnsupportedFormat, Message: "ไฟล์ต้องเป็นรูปภาพ JPEG, PNG หรือ WebP"}
+	}
+	if err != nil {
+		return nil, &FaceImageError{Code: FaceImageDecodeFailed, Message: "ไม่สามารถถอดรหัสรูปภาพ ไฟล์อาจเสียหาย"}
+	}
+	if config.Width <= 0 || config.Height <= 0 || config.Width*config.Height > maxImagePixels {
+		return nil, &FaceImageError{Code: FaceImageDimensionsTooLarge, Message: fmt.Sprintf("ขนาดรูปภาพต้องไม่เกิน %d ล้าน pixel", maxImagePixels/1_000_000)}
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("ไม่สามารถอ่านรูปภาพ: %w", err)
+	}
+
+	img, _, err := image.Decode(r)
+	if err != nil {
+		return nil, &FaceImageError{Code: FaceImageDecodeFailed, Message: "ไม่สามารถถอดรหัสรูปภาพ ไฟล์อาจเสียหาย"}
+	}
+	return img, nil
+}
+
+// thumbnailSizes เรียงขนาดรูปย่อจากเล็กไปใหญ่และตัดขนาดที่ซ้ำ
+func thumbnailSizes(sizes []int) []int {
+	sorted := slices.Clone(sizes)
+	slices.Sort(sorted)
+	return slices.Compact(sorted)
+}
+
+// squareThumbnail ตัดกึ่งกลางของรูปเป็นสี่เหลี่ยมจัตุรัสแล้วย่อเป็น size x size โดยไม่ขยายรูปที่เล็กกว่า
+// ส่วนที่โปร่งใสของ PNG หรือ WebP ถูกแทนด้วยพื้นขาว เพราะรูปย่อเป็น JPEG
+func squareThumbnail(src image.Image, size int) image.Image {
+	bounds := src.Bounds()
+	side := min(bounds.Dx(), bounds.Dy())
+	crop := image.Rect(0, 0, side, side).Add(image.Pt(
+		bounds.Min.X+(bounds.Dx()-side)/2,
+		bounds.Min.Y+(bounds.Dy()-side)/2,
+	))
+	size = min(size, side)
+
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+	draw.Draw(dst, dst.Bounds(), image.White, image.Point{}, draw.Src)
+	xdraw.CatmullRom.Scale(dst, dst.Bounds(), src, crop, draw.Over, nil)
+	return dst
+}
+
+// encodeThumbnail เข้ารหัสรูปย่อเป็น JPEG
+func encodeThumbnail(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: thumbnailQuality}); err != nil {
+		return nil, fmt.Errorf("ไม่สามารถสร้างรูปย่อ: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// thumbnailKey สร้าง object key ของรูปย่อตามขนาด การสร้างซ้ำจึงเขียนทับไฟล์เดิม
+func thumbnailKey(organizationID, faceImageID string, size int) string {
+	return fmt.Sprintf("%s/thumbnails/%s_%s.jpg", organizationID, faceImageID, strconv.Itoa(size))
+}
+
+// hasThumbnails ตรวจว่ารูปภาพมีรูปย่อครบทุกขนาดที่ตั้งค่าไว้
+func hasThumbnails(thumbnailKeys map[string]string, sizes []int) bool {
+	for _, size := range sizes {
+		if thumbnailKeys[strconv.Itoa(size)] == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// storeThumbnails สร้างรูปย่อทุกขนาดที่ตั้งค่าไว้จากรูปที่ถอดรหัสแล้ว บันทึกลง storage และกำหนด object key ให้ faceImage
+// ถ้าบันทึกไม่สำเร็จ จะลบรูปย่อที่เพิ่งสร้างใหม่ (ไม่รวมรูปย่อเดิมที่ถูกเขียนทับ)
+func (s *FaceService) storeThumbnails(ctx context.Context, faceImage *models.FaceImage, img image.Image) error {
+	sizes := thumbnailSizes(s.ThumbnailSizes)
+	thumbnailKeys := make(map[string]string, len(sizes))
+	var created []string
+	for _, size := range sizes {
+		data, err := encodeThumbnail(squareThumbnail(img, size))
+		if err == nil {
+			key := thumbnailKey(faceImage.OrganizationID, faceImage.ID, size)
+			err = s.Storage.PutObject(ctx, key, bytes.NewReader(data), int64(len(data)), "image/jpeg")
+			if err == nil {
+				if faceImage.ThumbnailKeys[strconv.Itoa(size)] == "" {
+					created = append(created, key)
+				}
+				thumbnailKeys[strconv.Itoa(size)] = key
+				continue
+			}
+			err = fmt.Errorf("ไม่สามารถบันทึกรูปย่อ: %w", err)
+		}
+
+		for _, key := range created {
+			_ = s.Storage.DeleteObject(ctx, key)
+		}
+		return err
+	}
+
+	faceImage.ThumbnailKeys = thumbnailKeys
+	return nil
+}
+
+// deleteThumbnails ลบไฟล์รูปย่อตาม object key โดยบันทึก log เมื่อลบไม่สำเร็จ
+func (s *FaceService) deleteThumbnails(ctx context.Context, thumbnailKeys map[string]string) {
+	for _, key := range thumbnailKeys {
+		if err := s.Storage.DeleteObject(ctx, key); err != nil {
+			log.Printf("ไม่สามารถลบรูปย่อ %s: %v", key, err)
+		}
+	}
+}
+
+// StartThumbnailBackfill สร้างงานเบื้องหลังที่สร้างรูปย่อให้รูปภาพใบหน้าเดิมขององค์กรที่ยังไม่มีรูปย่อครบทุกขนาด
+func (s *FaceService) StartThumbnailBackfill(ctx context.Context, organizationID string) (*models.Job, error) {
+	if organizationID == "" {
+		return nil, fmt.Errorf("ไม่พบข้อมูลองค์กร")
+	}
+
+	params := models.ThumbnailBackfillParams{
+		OrganizationID: organizationID,
+		Sizes:          thumbnailSizes(s.ThumbnailSizes),
+	}
+	job, err := s.Jobs.CreateJob(ctx, organizationID, models.JobTypeThumbnailBackfill, params)
+	if err != nil {
+		return nil, err
+	}
+
+	s.Jobs.Run(job, s.RunThumbnailBackfill)
+	return job, nil
+}
+
+// GetThumbnailBackfillJob ดึงงานสร้างรูปย่อขององค์กร
+func (s *FaceService) GetThumbnailBackfillJob(ctx context.Context, id, organizationID string) (*models.Job, error) {
+	job, err := s.Jobs.GetJob(ctx, id, organizationID)
+	if err != nil {
+		return nil, err
+	}
+	if job.Type != models.JobTypeThumbnailBackfill {
+		return nil, fmt.Errorf("ไม่พบงาน")
+	}
+	return job, nil
+}
+
+// RunThumbnailBackfill ไล่รูปภาพใบหน้าขององค์กรทีละ chunk ตาม id และสร้างรูปย่อให้รูปที่ยังไม่ครบ
+// รูปที่สร้างไม่สำเร็จถูกบันทึกในผลลัพธ์โดยไม่หยุดงาน งานจึงเริ่มใหม่ได้เสมอ เพราะรูปที่มีรูปย่อครบแล้วจะถูกข้าม
+func (s *FaceService) RunThumbnailBackfill(ctx context.Context, job *models.Job, progress func(int64)) (interface{}, error) {
+	// รูปที่ซ้ำแบบ link ใช้รูปย่อของรูปที่ใช้ไฟล์ร่วมกัน ซึ่งถูกอัปเดตพร้อมกัน
+	query := s.DB.DB.WithContext(ctx).Model(&models.FaceImage{}).Where("organization_id = ? AND duplicate_of_id IS NULL", job.OrganizationID)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, fmt.Errorf("ไม่สามารถนับจำนวนรูปภาพ: %w", err)
+	}
+	if err := s.Jobs.SetTotal(ctx, job.ID, total); err != nil {
+		return nil, err
+	}
+
+	sizes := thumbnailSizes(s.ThumbnailSizes)
+	result := models.ThumbnailBackfillResult{}
+	lastID := ""
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		var images []models.FaceImage
+		if err := query.Session(&gorm.Session{}).Where("id > ?", lastID).Order("id").Limit(thumbnailBackfillChunkSize).Find(&images).Error; err != nil {
+			return nil, fmt.Errorf("ไม่สามารถดึงข้อมูลรูปภาพ: %w", err)
+		}
+		if len(images) == 0 {
+			break
+		}
+
+		for i := range images {
+			faceImage := &images[i]
+			result.Images++
+			if hasThumbnails(faceImage.ThumbnailKeys, sizes) {
+				result.Skipped++
+				continue
+			}
+			if err := s.backfillThumbnails(ctx, faceImage); err != nil {
+				result.Failed++
+				if len(result.Failures) < maxThumbnailBackfillFailures {
+					result.Failures = append(result.Failures, models.ThumbnailBackfillFailure{
+						FaceImageID: faceImage.ID,
+						Error:       err.Error(),
+					})
+				}
+				continue
+			}
+			result.Generated++
+		}
+
+		lastID = images[len(images)-1].ID
+		progress(result.Images)
+	}
+
+	return result, nil
+}
+
+// backfillThumbnails สร้างรูปย่อของรูปภาพเดิมจากไฟล์ต้นฉบับ และลบรูปย่อของขนาดที่ไม่ได้ตั้งค่าไว้แล้ว
+func (s *FaceService) backfillThumbnails(ctx context.Context, faceImage *models.FaceImage) error {
+	reader, _, err := s.Storage.GetObject(ctx, faceImage.ImageKey)
+	if err != nil {
+		return fmt.Errorf("ไม่สามารถอ่านไฟล์รูปภาพ: %w", err)
+	}
+	data, err := io.ReadAll(reader)
+	reader.Close()
+	if err != nil {
+		return fmt.Errorf("ไม่สามารถอ่านไฟล์รูปภาพ: %w", err)
+	}
+
+	img, err := decodeFaceImage(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+
+	previous := faceImage.ThumbnailKeys
+	if err := s.storeThumbnails(ctx, faceImage, img); err != nil {
+		return err
+	}
+	if err := s.DB.DB.WithContext(ctx).Model(faceImage).Select("thumbnail_keys").Updates(faceImage).Error; err != nil {
+		return fmt.Errorf("ไม่สามารถบันทึกข้อมูลรูปย่อ: %w", err)
+	}
+	if err := s.DB.DB.WithContext(ctx).Model(&models.FaceImage{}).Where("duplicate_of_id = ?", faceImage.ID).
+		Select("thumbnail_keys").Updates(&models.FaceImage{ThumbnailKeys: faceImage.ThumbnailKeys}).Error; err != nil {
+		return fmt.Errorf("ไม่สามารถบันทึกข้อมูลรูปย่อ: %w", err)
+	}
+
+	stale := make(map[string]string)
+	for size, key := range previous {
+		if _, ok := faceImage.ThumbnailKeys[size]; !ok {
+			stale[size] = key
+		}
+	}
+	s.deleteThumbnails(ctx, stale)
+	return nil
+}
@@ -0,0 +1,78 @@
+package services
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testImage สร้างรูปที่ครึ่งซ้ายเป็นสีแดงและครึ่งขวาเป็นสีน้ำเงิน
+func testImage(width, height int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			c := color.RGBA{R: 255, A: 255}
+			if x >= width/2 {
+				c = color.RGBA{B: 255, A: 255}
+			}
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+// TestDecodeFaceImage ทดสอบการถอดรหัส JPEG และ PNG และการปฏิเสธไฟล์ที่ไม่ใช่รูปภาพ
+func TestDecodeFaceImage(t *testing.T) {
+	var pngData bytes.Buffer
+	require.NoError(t, png.Encode(&pngData, testImage(40, 20)))
+	img, err := decodeFaceImage(bytes.NewReader(pngData.Bytes()))
+	require.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 40, 20), img.Bounds())
+
+	var jpegData bytes.Buffer
+	require.NoError(t, jpeg.Encode(&jpegData, testImage(30, 50), nil))
+	img, err = decodeFaceImage(bytes.NewReader(jpegData.Bytes()))
+	require.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 30, 50), img.Bounds())
+
+	_, err = decodeFaceImage(bytes.NewReader([]byte("not an image")))
+	assert.EqualError(t, err, "ไฟล์ต้องเป็นรูปภาพ JPEG, PNG หรือ WebP")
+}
+
+// TestSquareThumbnail ทดสอบการตัดกึ่งกลางเป็นสี่เหลี่ยมจัตุรัสและการไม่ขยายรูปที่เล็กกว่า
+func TestSquareThumbnail(t *testing.T) {
+	thumbnail := squareThumbnail(testImage(400, 200), 48)
+	assert.Equal(t, image.Rect(0, 0, 48, 48), thumbnail.Bounds())
+
+	// ตัดกึ่งกลาง จึงมีทั้งสีแดงด้านซ้ายและสีน้ำเงินด้านขวา
+	r, _, b, _ := thumbnail.At(2, 24).RGBA()
+	assert.Greater(t, r, b)
+	r, _, b, _ = thumbnail.At(45, 24).RGBA()
+	assert.Greater(t, b, r)
+
+	thumbnail = squareThumbnail(testImage(30, 50), 160)
+	assert.Equal(t, image.Rect(0, 0, 30, 30), thumbnail.Bounds())
+
+	data, err := encodeThumbnail(thumbnail)
+	require.NoError(t, err)
+	_, format, err := image.DecodeConfig(bytes.NewReader(data))
+	require.NoError(t, err)
+	assert.Equal(t, "jpeg", format)
+}
+
+// TestThumbnailSizes ทดสอบการเรียงขนาดรูปย่อ ชื่อไฟล์ และการตรวจว่ามีรูปย่อครบ
+func TestThumbnailSizes(t *testing.T) {
+	assert.Equal(t, []int{48, 160}, thumbnailSizes([]int{160, 48, 160}))
+	assert.Equal(t, "org-1/thumbnails/img-1_48.jpg", thumbnailKey("org-1", "img-1", 48))
+
+	thumbnails := map[string]string{"48": "http://localhost/48.jpg"}
+	assert.True(t, hasThumbnails(thumbnails, []int{48}))
+	assert.False(t, hasThumbnails(thumbnails, []int{48, 160}))
+	assert.False(t, hasThumbnails(nil, []int{48}))
+}
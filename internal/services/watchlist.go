@@ -0,0 +1,566 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/alert"
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/db"
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/events"
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// WatchlistService เป็นโครงสร้างสำหรับจัดการรายการเฝ้าระวังและการแจ้งเตือนเมื่อพบบุคคลในรายการ
+type WatchlistService struct {
+	DB       *db.PostgresDB
+	Notifier alert.Notifier
+	Events   *events.Broker
+	URLs     *ImageURLSigner
+	Cooldown time.Duration
+}
+
+// NewWatchlistService สร้าง WatchlistService ใหม่
+func NewWatchlistService(postgres *db.PostgresDB, notifier alert.Notifier, broker *events.Broker, urls *ImageURLSigner, cooldown time.Duration) *WatchlistService {
+	if cooldown <= 0 {
+		cooldown = 15 * time.Minute
+	}
+	return &WatchlistService{
+		DB:       postgres,
+		Notifier: notifier,
+		Events:   broker,
+		URLs:     urls,
+		Cooldown: cooldown,
+	}
+}
+
+// IsValidPriority ตรวจสอบว่าเป็นระดับความสำคัญที่รองรับหรือไม่
+func IsValidPriority(priority string) bool {
+	switch priority {
+	case models.SeverityLow, models.SeverityMedium, models.SeverityHigh, models.SeverityCritical:
+		return true
+	}
+	return false
+}
+
+// entryActive ตรวจสอบว่ารายการบุคคลยังไม่หมดอายุ ณ เวลาที่ระบุ
+func entryActive(entry models.WatchlistEntry, at time.Time) bool {
+	return entry.ExpiresAt == nil || entry.ExpiresAt.After(at)
+}
+
+// nextAlertStatus ตรวจสอบว่าเปลี่ยนสถานะการแจ้งเตือนได้หรือไม่ รับทราบได้เฉพาะการแจ้งเตือนที่ยังเปิดอยู่ และปิดได้ทุกสถานะยกเว้นที่ปิดแล้ว
+func nextAlertStatus(current, target string) error {
+	if current == models.WatchlistAlertResolved {
+		return fmt.Errorf("การแจ้งเตือนนี้ถูกปิดแล้ว")
+	}
+	if target == models.WatchlistAlertAcknowledged && current == models.WatchlistAlertAcknowledged {
+		return fmt.Errorf("การแจ้งเตือนนี้ถูกรับทราบแล้ว")
+	}
+	return nil
+}
+
+// ListWatchlists ดึงรายการเฝ้าระวังทั้งหมดขององค์กร
+func (s *WatchlistService) ListWatchlists(ctx context.Context, organizationID string) ([]models.Watchlist, error) {
+	var watchlists []models.Watchlist
+	if err := s.DB.DB.WithContext(ctx).
+		Where("organization_id = ?", organizationID).
+		Order("name").
+		Find(&watchlists).Error; err != nil {
+		return nil, fmt.Errorf("ไม่สามารถดึงรายการเฝ้าระวัง: %w", err)
+	}
+	return watchlists, nil
+}
+
+// GetWatchlist ดึงรายการเฝ้าระวังตาม ID
+func (s *WatchlistService) GetWatchlist(ctx context.Context, id, organizationID string) (*models.Watchlist, error) {
+	var watchlist models.Watchlist
+	if err := s.DB.DB.WithContext(ctx).
+		Where("id = ? AND organization_id = ?", id, organizationID).
+		First(&watchlist).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("ไม่พบรายการเฝ้าระวัง")
+		}
+		return nil, fmt.Errorf("ไม่สามารถดึงรายการเฝ้าระวัง: %w", err)
+	}
+	return &watchlist, nil
+}
+
+// CreateWatchlist สร้างรายการเฝ้าระวังใหม่
+func (s *WatchlistService) CreateWatchlist(ctx context.Context, watchlist *models.Watchlist) error {
+	watchlist.Name = strings.TrimSpace(watchlist.Name)
+	if watchlist.Name == "" {
+		return fmt.Errorf("ต้องระบุชื่อรายการเฝ้าระวัง")
+	}
+	if watchlist.ID == "" {
+		watchlist.ID = uuid.New().String()
+	}
+
+	if err := s.DB.DB.WithContext(ctx).Create(watchlist).Error; err != nil {
+		return fmt.Errorf("ไม่สามารถสร้างรายการเฝ้าระวัง: %w", err)
+	}
+	return nil
+}
+
+// UpdateWatchlist แก้ไขชื่อและคำอธิบายของรายการเฝ้าระวัง
+func (s *WatchlistService) UpdateWatchlist(ctx context.Context, id, organizationID, name, description string) (*models.Watchlist, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, fmt.Errorf("ต้องระบุชื่อรายการเฝ้าระวัง")
+	}
+
+	watchlist, err := s.GetWatchlist(ctx, id, organizationID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.DB.DB.WithContext(ctx).Model(watchlist).Updates(map[string]interface{}{
+		"name":        name,
+		"description": description,
+	}).Error; err != nil {
+		return nil, fmt.Errorf("ไม่สามารถแก้ไขรายการเฝ้าระวัง: %w", err)
+	}
+	return watchlist, nil
+}
+
+// DeleteWatchlist ลบรายการเฝ้าระวังพร้อมบุคคลในรายการ การแจ้งเตือนที่เกิดขึ้นแล้วยังคงอยู่
+func (s *WatchlistService) DeleteWatchlist(ctx context.Context, id, organizationID string) error {
+	return s.DB.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("id = ? AND organization_id = ?", id, organizationID).Delete(&models.Watchlist{})
+		if result.Error != nil {
+			return fmt.Errorf("ไม่สามารถลบรายการเฝ้าระวัง: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("ไม่พบรายการเฝ้าระวัง")
+		}
+
+		if err := tx.Unscoped().Where("watchlist_id = ?", id).Delete(&models.WatchlistEntry{}).Error; err != nil {
+			return fmt.Errorf("ไม่สามารถลบบุคคลในรายการเฝ้าระวัง: %w", err)
+		}
+		return nil
+	})
+}
+
+// ListEntries ดึงบุคคลในรายการเฝ้าระวัง โดยไม่รวมรายการที่หมดอายุแล้วเว้นแต่ระบุ includeExpired
+func (s *WatchlistService) ListEntries(ctx context.Context, watchlistID, organizationID string, includeExpired bool) ([]models.WatchlistEntry, error) {
+	if _, err := s.GetWatchlist(ctx, watchlistID, organizationID); err != nil {
+		return nil, err
+	}
+
+	query := s.DB.DB.WithContext(ctx).Where("watchlist_id = ? AND organization_id = ?", watchlistID, organizationID)
+	if !includeExpired {
+		query = query.Where("(expires_at IS NULL OR expires_at > ?)", time.Now())
+	}
+
+	var entries []models.WatchlistEntry
+	if err := query.Order("created_at DESC").Find(&entries).Error; err != nil {
+		return nil, fmt.Errorf("ไม่สามารถดึงบุคคลในรายการเฝ้าระวัง: %w", err)
+	}
+	return entries, nil
+}
+
+// validateEntry ตรวจสอบระดับความสำคัญและเวลาหมดอายุของรายการบุคคล
+func validateEntry(entry *models.WatchlistEntry, now time.Time) error {
+	if entry.Priority == "" {
+		entry.Priority = models.SeverityMedium
+	}
+	if !IsValidPriority(entry.Priority) {
+		return fmt.Errorf("priority ต้องเป็น low, medium, high หรือ critical")
+	}
+	if !entryActive(*entry, now) {
+		return fmt.Errorf("เวลาหมดอายุต้องอยู่ในอนาคต")
+	}
+	return nil
+}
+
+// AddEntry เพิ่มบุคคลในรายการเฝ้าระวัง ถ้า person_hash เป็นชื่อแฝงจากการรวมบุคคลจะเพิ่มบุคคลปัจจุบันแทน
+func (s *WatchlistService) AddEntry(ctx context.Context, entry *models.WatchlistEntry) error {
+	if entry.PersonHash == "" {
+		return fmt.Errorf("ต้องระบุรหัสบุคคล")
+	}
+	if err := validateEntry(entry, time.Now()); err != nil {
+		return err
+	}
+	if _, err := s.GetWatchlist(ctx, entry.WatchlistID, entry.OrganizationID); err != nil {
+		return err
+	}
+
+	personHash, err := resolvePersonHash(ctx, s.DB.DB, entry.OrganizationID, entry.PersonHash)
+	if err != nil {
+		return err
+	}
+	entry.PersonHash = personHash
+
+	var count int64
+	if err := s.DB.DB.WithContext(ctx).Model(&models.Person{}).
+		Where("person_hash = ? AND organization_id = ?", entry.PersonHash, entry.OrganizationID).
+		Count(&count).Error; err != nil {
+		return fmt.Errorf("ไม่สามารถดึงข้อมูลบุคคล: %w", err)
+	}
+	if count == 0 {
+		return fmt.Errorf("ไม่พบข้อมูลบุคคล")
+	}
+
+	if entry.ID == "" {
+		entry.ID = uuid.New().String()
+	}
+	result := s.DB.DB.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(entry)
+	if result.Error != nil {
+		return fmt.Errorf("ไม่สามารถเพิ่มบุคคลในรายการเฝ้าระวัง: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("บุคคลนี้อยู่ในรายการเฝ้าระวังแล้ว")
+	}
+	return nil
+}
+
+// UpdateEntry แก้ไขเหตุผล ระดับความสำคัญ และเวลาหมดอายุของบุคคลในรายการเฝ้าระวัง
+func (s *WatchlistService) UpdateEntry(ctx context.Context, watchlistID, entryID, organizationID string, update models.WatchlistEntry) (*models.WatchlistEntry, error) {
+	if err := validateEntry(&update, time.Now()); err != nil {
+		return nil, err
+	}
+
+	var entry models.WatchlistEntry
+	if err := s.DB.DB.WithContext(ctx).
+		Where("id = ? AND watchlist_id = ? AND organization_id = ?", entryID, watchlistID, organizationID).
+		First(&entry).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("ไม่พบบุคคลในรายการเฝ้าระวัง")
+		}
+		return nil, fmt.Errorf("ไม่สามารถดึงบุคคลในรายการเฝ้าระวัง: %w", err)
+	}
+
+	if err := s.DB.DB.WithContext(ctx).Model(&entry).Updates(map[string]interface{}{
+		"reason":     update.Reason,
+		"priority":   update.Priority,
+		"expires_at": update.ExpiresAt,
+	}).Error; err != nil {
+		return nil, fmt.Errorf("ไม่สามารถแก้ไขบุคคลในรายการเฝ้าระวัง: %w", err)
+	}
+	entry.ExpiresAt = update.ExpiresAt
+	return &entry, nil
+}
+
+// RemoveEntry นำบุคคลออกจากรายการเฝ้าระวัง
+func (s *WatchlistService) RemoveEntry(ctx context.Context, watchlistID, entryID, organizationID string) error {
+	result := s.DB.DB.WithContext(ctx).Unscoped().
+		Where("id = ? AND watchlist_id = ? AND organization_id = ?", entryID, watchlistID, organizationID).
+		Delete(&models.WatchlistEntry{})
+	if result.Error != nil {
+		return fmt.Errorf("ไม่สามารถนำบุคคลออกจากรายการเฝ้าระวัง: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("ไม่พบบุคคลในรายการเฝ้าระวัง")
+	}
+	return nil
+}
+
+// MatchDetection ตรวจการตรวจจับใหม่กับรายการเฝ้าระวังขององค์กร และแจ้งเตือนเมื่อพบบุคคลที่ยังไม่หมดอายุ
+func (s *WatchlistService) MatchDetection(ctx context.Context, camera *models.Camera, personLog *models.PersonLog) error {
+	hashes := []string{personLog.PersonHash}
+	if personLog.ReportedHash != "" {
+		hashes = append(hashes, personLog.ReportedHash)
+	}
+
+	var entries []models.WatchlistEntry
+	if err := s.DB.DB.WithContext(ctx).
+		Where("organization_id = ? AND person_hash IN ?", personLog.OrganizationID, hashes).
+		Where("(expires_at IS NULL OR expires_at > ?)", personLog.Timestamp).
+		Find(&entries).Error; err != nil {
+		return fmt.Errorf("ไม่สามารถตรวจรายการเฝ้าระวัง: %w", err)
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+
+	// รูปใบหน้าล่าสุดของบุคคล (ถ้ามี) แนบไปกับการแจ้งเตือน
+	var faceImage models.FaceImage
+	if err := s.DB.DB.WithContext(ctx).
+		Where("organization_id = ? AND person_hash = ?", personLog.OrganizationID, personLog.PersonHash).
+		Order("created_at DESC").
+		First(&faceImage).Error; err != nil && err != gorm.ErrRecordNotFound {
+		log.Printf("ไม่สามารถดึงรูปใบหน้าล่าสุดของบุคคล %s: %v", personLog.PersonHash, err)
+	}
+
+	var errs []error
+	for _, entry := range entries {
+		if err := s.raiseAlert(ctx, camera, personLog, entry, faceImage); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// raiseAlert สร้างการแจ้งเตือนของรายการบุคคล หรืออัปเดตการแจ้งเตือนที่ยังไม่ปิดถ้าพบบุคคลซ้ำภายในช่วง cooldown
+func (s *WatchlistService) raiseAlert(ctx context.Context, camera *models.Camera, personLog *models.PersonLog, entry models.WatchlistEntry, faceImage models.FaceImage) error {
+	var watchlistAlert models.WatchlistAlert
+	created := false
+	err := s.DB.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("entry_id = ? AND status <> ? AND last_seen_at >= ?", entry.ID, models.WatchlistAlertResolved, personLog.Timestamp.Add(-s.Cooldown)).
+			Order("last_seen_at DESC").
+			First(&watchlistAlert).Error
+		if err != nil && err != gorm.ErrRecordNotFound {
+			return fmt.Errorf("ไม่สามารถดึงการแจ้งเตือนของรายการเฝ้าระวัง: %w", err)
+		}
+
+		if err == nil {
+			updates := map[string]interface{}{
+				"detection_count": gorm.Expr("detection_count + 1"),
+			}
+			if personLog.Timestamp.After(watchlistAlert.LastSeenAt) {
+				updates["last_seen_at"] = personLog.Timestamp
+				updates["last_camera_id"] = personLog.CameraID
+				watchlistAlert.LastSeenAt = personLog.Timestamp
+				watchlistAlert.LastCameraID = personLog.CameraID
+			}
+			if faceImage.ID != "" {
+				updates["face_image_id"] = faceImage.ID
+				updates["face_image_key"] = faceImage.ImageKey
+				watchlistAlert.FaceImageID = faceImage.ID
+				watchlistAlert.FaceImageKey = faceImage.ImageKey
+			}
+			if err := tx.Model(&watchlistAlert).Updates(updates).Error; err != nil {
+				return fmt.Errorf("ไม่สามารถอัปเดตการแจ้งเตือนของรายการเฝ้าระวัง: %w", err)
+			}
+			watchlistAlert.DetectionCount++
+			return nil
+		}
+
+		watchlistAlert = models.WatchlistAlert{
+			Base: models.Base{
+				ID: uuid.New().String(),
+			},
+			OrganizationID: personLog.OrganizationID,
+			WatchlistID:    entry.WatchlistID,
+			EntryID:        entry.ID,
+			PersonHash:     personLog.PersonHash,
+			Priority:       entry.Priority,
+			Reason:         entry.Reason,
+			CameraID:       personLog.CameraID,
+			LogID:          personLog.ID,
+			DetectedAt:     personLog.Timestamp,
+			LastCameraID:   personLog.CameraID,
+			LastSeenAt:     personLog.Timestamp,
+			DetectionCount: 1,
+			FaceImageID:    faceImage.ID,
+			FaceImageKey:   faceImage.ImageKey,
+			Status:         models.WatchlistAlertOpen,
+		}
+		if err := tx.Create(&watchlistAlert).Error; err != nil {
+			return fmt.Errorf("ไม่สามารถบันทึกการแจ้งเตือนของรายการเฝ้าระวัง: %w", err)
+		}
+		created = true
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	s.URLs.SignWatchlistAlert(&watchlistAlert)
+	s.publish(ctx, camera.Zone, &watchlistAlert)
+	if created {
+		s.notify(ctx, camera, &watchlistAlert)
+	}
+	return nil
+}
+
+// publish ส่งการแจ้งเตือนไปยัง live stream ขององค์กร
+func (s *WatchlistService) publish(ctx context.Context, zone string, watchlistAlert *models.WatchlistAlert) {
+	if s.Events == nil {
+		return
+	}
+
+	event, err := events.NewEvent(events.TypeWatchlistAlert, watchlistAlert.OrganizationID, watchlistAlert.LastCameraID, zone, watchlistAlert)
+	if err == nil {
+		err = s.Events.Publish(ctx, event)
+	}
+	if err != nil {
+		log.Printf("ไม่สามารถส่งการแจ้งเตือนของรายการเฝ้าระวังไปยัง live stream: %v", err)
+	}
+}
+
+// notify ส่งการแจ้งเตือนเมื่อพบบุคคลในรายการเฝ้าระวังครั้งแรกในช่วง cooldown
+func (s *WatchlistService) notify(ctx context.Context, camera *models.Camera, watchlistAlert *models.WatchlistAlert) {
+	if s.Notifier == nil {
+		return
+	}
+
+	watchlistName := watchlistAlert.WatchlistID
+	if watchlist, err := s.GetWatchlist(ctx, watchlistAlert.WatchlistID, watchlistAlert.OrganizationID); err == nil {
+		watchlistName = watchlist.Name
+	}
+	cameraName := camera.Name
+	if cameraName == "" {
+		cameraName = watchlistAlert.CameraID
+	}
+
+	message := fmt.Sprintf("พบบุคคล %s ที่กล้อง %s เวลา %s", watchlistAlert.PersonHash, cameraName, watchlistAlert.DetectedAt.Format(time.RFC3339))
+	if watchlistAlert.Reason != "" {
+		message += " (" + watchlistAlert.Reason + ")"
+	}
+
+	if err := s.Notifier.Notify(ctx, alert.Alert{
+		Type:           "watchlist",
+		Severity:       watchlistAlert.Priority,
+		OrganizationID: watchlistAlert.OrganizationID,
+		Title:          fmt.Sprintf("พบบุคคลในรายการเฝ้าระวัง %s", watchlistName),
+		Message:        message,
+		Data:           watchlistAlert,
+		CreatedAt:      watchlistAlert.DetectedAt,
+	}); err != nil {
+		log.Printf("ไม่สามารถส่งการแจ้งเตือนของรายการเฝ้าระวัง %s: %v", watchlistAlert.WatchlistID, err)
+	}
+}
+
+// watchlistAlertListSpec กำหนดการเรียงลำดับและฟิลด์ที่เลือกได้ของรายการการแจ้งเตือน
+var watchlistAlertListSpec = listSpec[models.WatchlistAlert]{
+	Sorts: map[string]sortField{
+		"detected_at":  {Column: "detected_at", Kind: sortKindTime},
+		"last_seen_at": {Column: "last_seen_at", Kind: sortKindTime},
+	},
+	DefaultSort:  "detected_at",
+	DefaultOrder: "desc",
+	Fields: map[string]string{
+		"id":              "id",
+		"created_at":      "created_at",
+		"updated_at":      "updated_at",
+		"organization_id": "organization_id",
+		"watchlist_id":    "watchlist_id",
+		"entry_id":        "entry_id",
+		"person_hash":     "person_hash",
+		"priority":        "priority",
+		"reason":          "reason",
+		"camera_id":       "camera_id",
+		"log_id":          "log_id",
+		"detected_at":     "detected_at",
+		"last_camera_id":  "last_camera_id",
+		"last_seen_at":    "last_seen_at",
+		"detection_count": "detection_count",
+		"face_image_id":   "face_image_id",
+		"face_image_url":  "face_image_key",
+		"status":          "status",
+		"acknowledged_by": "acknowledged_by",
+		"acknowledged_at": "acknowledged_at",
+		"resolved_by":     "resolved_by",
+		"resolved_at":     "resolved_at",
+		"resolution":      "resolution",
+	},
+	Key: func(watchlistAlert models.WatchlistAlert, sort string) (interface{}, string) {
+		if sort == "last_seen_at" {
+			return watchlistAlert.LastSeenAt, watchlistAlert.ID
+		}
+		return watchlistAlert.DetectedAt, watchlistAlert.ID
+	},
+}
+
+// ListAlerts ดึงการแจ้งเตือนของรายการเฝ้าระวังตามตัวกรอง
+func (s *WatchlistService) ListAlerts(ctx context.Context, organizationID string, filter models.WatchlistAlertFilter, opts models.ListOptions) ([]models.WatchlistAlert, *models.CursorPagination, error) {
+	query := s.DB.DB.WithContext(ctx).Model(&models.WatchlistAlert{}).Where("organization_id = ?", organizationID)
+	if len(filter.Statuses) > 0 {
+		query = query.Where("status IN ?", filter.Statuses)
+	}
+	if len(filter.WatchlistIDs) > 0 {
+		query = query.Where("watchlist_id IN ?", filter.WatchlistIDs)
+	}
+	if len(filter.PersonHashes) > 0 {
+		query = query.Where("person_hash IN ?", filter.PersonHashes)
+	}
+	if len(filter.CameraIDs) > 0 {
+		query = query.Where("(camera_id IN ? OR last_camera_id IN ?)", filter.CameraIDs, filter.CameraIDs)
+	}
+	if len(filter.Priorities) > 0 {
+		query = query.Where("priority IN ?", filter.Priorities)
+	}
+
+	alerts, pagination, err := paginate(query, watchlistAlertListSpec, opts)
+	if err != nil {
+		if errors.Is(err, ErrInvalidListOptions) {
+			return nil, nil, err
+		}
+		return nil, nil, fmt.Errorf("ไม่สามารถดึงการแจ้งเตือนของรายการเฝ้าระวัง: %w", err)
+	}
+
+	for i := range alerts {
+		s.URLs.SignWatchlistAlert(&alerts[i])
+	}
+	return alerts, pagination, nil
+}
+
+// GetAlert ดึงการแจ้งเตือนของรายการเฝ้าระวังตาม ID
+func (s *WatchlistService) GetAlert(ctx context.Context, id, organizationID string) (*models.WatchlistAlert, error) {
+	var watchlistAlert models.WatchlistAlert
+	if err := s.DB.DB.WithContext(ctx).
+		Where("id = ? AND organization_id = ?", id, organizationID).
+		First(&watchlistAlert).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("ไม่พบการแจ้งเตือน")
+		}
+		return nil, fmt.Errorf("ไม่สามารถดึงการแจ้งเตือน: %w", err)
+	}
+	s.URLs.SignWatchlistAlert(&watchlistAlert)
+	return &watchlistAlert, nil
+}
+
+// AcknowledgeAlert รับทราบการแจ้งเตือนที่ยังเปิดอยู่
+func (s *WatchlistService) AcknowledgeAlert(ctx context.Context, id, organizationID, acknowledgedBy string) (*models.WatchlistAlert, error) {
+	return s.transitionAlert(ctx, id, organizationID, models.WatchlistAlertAcknowledged, func(now time.Time) map[string]interface{} {
+		return map[string]interface{}{
+			"status":          models.WatchlistAlertAcknowledged,
+			"acknowledged_by": acknowledgedBy,
+			"acknowledged_at": now,
+		}
+	})
+}
+
+// ResolveAlert ปิดการแจ้งเตือนพร้อมบันทึกผลการดำเนินการ การตรวจจับครั้งถัดไปจะสร้างการแจ้งเตือนใหม่
+func (s *WatchlistService) ResolveAlert(ctx context.Context, id, organizationID, resolvedBy, resolution string) (*models.WatchlistAlert, error) {
+	return s.transitionAlert(ctx, id, organizationID, models.WatchlistAlertResolved, func(now time.Time) map[string]interface{} {
+		return map[string]interface{}{
+			"status":      models.WatchlistAlertResolved,
+			"resolved_by": resolvedBy,
+			"resolved_at": now,
+			"resolution":  resolution,
+		}
+	})
+}
+
+// transitionAlert เปลี่ยนสถานะการแจ้งเตือนภายใต้ล็อกของแถว แล้วส่งสถานะใหม่ไปยัง live stream
+func (s *WatchlistService) transitionAlert(ctx context.Context, id, organizationID, target string, updates func(now time.Time) map[string]interface{}) (*models.WatchlistAlert, error) {
+	var watchlistAlert models.WatchlistAlert
+	err := s.DB.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND organization_id = ?", id, organizationID).
+			First(&watchlistAlert).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return fmt.Errorf("ไม่พบการแจ้งเตือน")
+			}
+			return fmt.Errorf("ไม่สามารถดึงการแจ้งเตือน: %w", err)
+		}
+		if err := nextAlertStatus(watchlistAlert.Status, target); err != nil {
+			return err
+		}
+
+		if err := tx.Model(&watchlistAlert).Updates(updates(time.Now())).Error; err != nil {
+			return fmt.Errorf("ไม่สามารถเปลี่ยนสถานะการแจ้งเตือน: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var camera models.Camera
+	if err := s.DB.DB.WithContext(ctx).First(&camera, "id = ?", watchlistAlert.LastCameraID).Error; err != nil && err != gorm.ErrRecordNotFound {
+		log.Printf("ไม่สามารถดึงข้อมูลกล้อง %s: %v", watchlistAlert.LastCameraID, err)
+	}
+	s.URLs.SignWatchlistAlert(&watchlistAlert)
+	s.publish(ctx, camera.Zone, &watchlistAlert)
+
+	return &watchlistAlert, nil
+}
@@ -0,0 +1,51 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bemindtech/bmt-manta-dashboard-service/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestValidateEntry ทดสอบค่าเริ่มต้นของระดับความสำคัญและการตรวจสอบเวลาหมดอายุ
+func TestValidateEntry(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	entry := models.WatchlistEntry{}
+	require.NoError(t, validateEntry(&entry, now))
+	assert.Equal(t, models.SeverityMedium, entry.Priority)
+
+	entry = models.WatchlistEntry{Priority: "urgent"}
+	assert.EqualError(t, validateEntry(&entry, now), "priority ต้องเป็น low, medium, high หรือ critical")
+
+	past := now.Add(-time.Hour)
+	entry = models.WatchlistEntry{Priority: models.SeverityHigh, ExpiresAt: &past}
+	assert.EqualError(t, validateEntry(&entry, now), "เวลาหมดอายุต้องอยู่ในอนาคต")
+
+	future := now.Add(24 * time.Hour)
+	entry = models.WatchlistEntry{Priority: models.SeverityCritical, ExpiresAt: &future}
+	assert.NoError(t, validateEntry(&entry, now))
+}
+
+// TestEntryActive ทดสอบว่ารายการที่ไม่มีเวลาหมดอายุใช้งานได้เสมอ และรายการที่หมดอายุแล้วไม่ถูกจับคู่
+func TestEntryActive(t *testing.T) {
+	at := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	expiresAt := at
+
+	assert.True(t, entryActive(models.WatchlistEntry{}, at))
+	assert.False(t, entryActive(models.WatchlistEntry{ExpiresAt: &expiresAt}, at))
+	assert.True(t, entryActive(models.WatchlistEntry{ExpiresAt: &expiresAt}, at.Add(-time.Second)))
+}
+
+// TestNextAlertStatus ทดสอบการเปลี่ยนสถานะของการแจ้งเตือน open -> acknowledged -> resolved
+func TestNextAlertStatus(t *testing.T) {
+	assert.NoError(t, nextAlertStatus(models.WatchlistAlertOpen, models.WatchlistAlertAcknowledged))
+	assert.NoError(t, nextAlertStatus(models.WatchlistAlertOpen, models.WatchlistAlertResolved))
+	assert.NoError(t, nextAlertStatus(models.WatchlistAlertAcknowledged, models.WatchlistAlertResolved))
+
+	assert.EqualError(t, nextAlertStatus(models.WatchlistAlertAcknowledged, models.WatchlistAlertAcknowledged), "การแจ้งเตือนนี้ถูกรับทราบแล้ว")
+	assert.EqualError(t, nextAlertStatus(models.WatchlistAlertResolved, models.WatchlistAlertAcknowledged), "การแจ้งเตือนนี้ถูกปิดแล้ว")
+	assert.EqualError(t, nextAlertStatus(models.WatchlistAlertResolved, models.WatchlistAlertResolved), "การแจ้งเตือนนี้ถูกปิดแล้ว")
+}
@@ -2,37 +2,107 @@ package storage
 
 import (
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
 	"fmt"
 	"io"
-	"mime/multipart"
+	"mime"
+	"net/url"
 	"os"
+	"path"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	awsconfig "github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 	appconfig "github.com/bemindtech/bmt-manta-dashboard-service/config"
-	"github.com/google/uuid"
 )
 
+// ErrObjectNotFound is returned by StatObject when no object is stored under the key
+var ErrObjectNotFound = errors.New("object not found")
+
+// ErrInvalidSignature is returned when a signed URL was not issued for the key or has expired
+var ErrInvalidSignature = errors.New("invalid or expired signature")
+
+// ErrWeakSigningKey is returned by NewStorageService when local storage would sign URLs with a missing or guessable key
+var ErrWeakSigningKey = errors.New("URL_SIGNING_KEY must be a secret of at least 32 characters that differs from JWT_SECRET when S3 is not enabled")
+
+// minSigningKeyLength is the shortest URL_SIGNING_KEY accepted for local storage
+const minSigningKeyLength = 32
+
+// publicSigningKeys are placeholder keys from the defaults and examples of this repository, which anyone can read
+var publicSigningKeys = map[string]bool{
+	"default-jwt-secret":   true,
+	"your-url-signing-key": true,
+	"your-jwt-secret-key":  true,
+}
+
+// ObjectInfo describes a stored object
+type ObjectInfo struct {
+	Size        int64
+	ContentType string
+	ModTime     time.Time
+	ETag        string // quoted entity tag that changes whenever the object is rewritten
+}
+
 // StorageService is an interface for different storage implementations
 type StorageService interface {
-	// UploadFaceImage uploads a face image and returns the file URL
-	UploadFaceImage(ctx context.Context, file *multipart.FileHeader, personHash string, organizationID string) (string, error)
-	
-	// DeleteFaceImage deletes a face image from storage
-	DeleteFaceImage(ctx context.Context, fileURL string) error
+	// PutObject stores an object under the given key
+	PutObject(ctx context.Context, key string, body io.Reader, size int64, contentType string) error
+
+	// GetObject opens an object for reading and returns its size
+	GetObject(ctx context.Context, key string) (io.ReadCloser, int64, error)
+
+	// DeleteObject deletes an object; deleting a missing object is not an error
+	DeleteObject(ctx context.Context, key string) error
+
+	// StatObject returns the size, content type, modification time and ETag of an object
+	StatObject(ctx context.Context, key string) (*ObjectInfo, error)
+
+	// SignedURL returns a URL that allows reading the object without other credentials until ttl has passed
+	SignedURL(key string, ttl time.Duration) (string, error)
+
+	// SignedUploadURL returns a URL that allows one PUT of exactly size bytes with the given Content-Type
+	// to key without other credentials until ttl has passed
+	SignedUploadURL(key, contentType string, size int64, ttl time.Duration) (string, error)
+
+	// ListObjects calls fn with the key, size, modification time and ETag of every object whose key starts with prefix.
+	// ContentType is not set. Listing stops at the first error returned by fn, which ListObjects returns.
+	ListObjects(ctx context.Context, prefix string, fn func(key string, info ObjectInfo) error) error
 }
 
-// LocalStorageService implements StorageService for local filesystem storage
+// SignatureVerifier is implemented by storages whose signed URLs are served by this API
+type SignatureVerifier interface {
+	// VerifySignature checks the expires and signature query parameters of a signed URL for key
+	VerifySignature(key, expires, signature string) error
+
+	// VerifyUploadSignature checks the expires and signature query parameters of a signed upload URL
+	// against the key, Content-Type and size of the PUT request
+	VerifyUploadSignature(key, contentType string, size int64, expires, signature string) error
+}
+
+// LocalStorageService implements StorageService for local filesystem storage.
+// Its objects are served by the API under BaseURL, which only accepts URLs signed with keys derived from the signing key.
+// Download and upload URLs are signed with different keys, so a URL for reading a file never allows writing it.
 type LocalStorageService struct {
 	StoragePath string
 	BaseURL     string
+	downloadKey []byte
+	uploadKey   []byte
 }
 
 // NewLocalStorageService creates a new local storage service
-func NewLocalStorageService(storagePath, baseURL string) (*LocalStorageService, error) {
+func NewLocalStorageService(storagePath, baseURL string, signingKey []byte) (*LocalStorageService, error) {
+	if len(signingKey) == 0 {
+		return nil, fmt.Errorf("a URL signing key is required for local storage")
+	}
 	// Create storage directory if it doesn't exist
 	if err := os.MkdirAll(storagePath, 0755); err != nil {
 		return nil, fmt.Errorf("unable to create storage directory: %w", err)
@@ -40,70 +110,236 @@ func NewLocalStorageService(storagePath, baseURL string) (*LocalStorageService,
 	return &LocalStorageService{
 		StoragePath: storagePath,
 		BaseURL:     baseURL,
+		downloadKey: deriveSigningKey(signingKey, "download"),
+		uploadKey:   deriveSigningKey(signingKey, "upload"),
 	}, nil
 }
 
-// UploadFaceImage implements StorageService interface for local storage
-func (s *LocalStorageService) UploadFaceImage(ctx context.Context, file *multipart.FileHeader, personHash string, organizationID string) (string, error) {
-	// Create organization directory
-	orgPath := filepath.Join(s.StoragePath, organizationID)
-	if err := os.MkdirAll(orgPath, 0755); err != nil {
-		return "", fmt.Errorf("unable to create organization directory: %w", err)
-	}
+// deriveSigningKey derives the key for signing URLs of one purpose from the configured signing key
+func deriveSigningKey(signingKey []byte, purpose string) []byte {
+	mac := hmac.New(sha256.New, signingKey)
+	mac.Write([]byte("local storage " + purpose + " URL"))
+	return mac.Sum(nil)
+}
 
-	// Generate a unique filename
-	fileExt := filepath.Ext(file.Filename)
-	fileName := fmt.Sprintf("%s_%s%s", personHash, uuid.New().String(), fileExt)
-	filePath := filepath.Join(orgPath, fileName)
+// objectPath resolves an object key to a path inside the storage directory
+func (s *LocalStorageService) objectPath(key string) (string, error) {
+	cleaned := filepath.Clean("/" + key)
+	if cleaned == "/" {
+		return "", fmt.Errorf("invalid object key %q", key)
+	}
+	return filepath.Join(s.StoragePath, cleaned), nil
+}
 
-	// Open uploaded file
-	src, err := file.Open()
+// PutObject implements StorageService interface for local storage
+func (s *LocalStorageService) PutObject(ctx context.Context, key string, body io.Reader, size int64, contentType string) error {
+	filePath, err := s.objectPath(key)
 	if err != nil {
-		return "", fmt.Errorf("unable to open uploaded file: %w", err)
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return fmt.Errorf("unable to create object directory: %w", err)
 	}
-	defer src.Close()
 
-	// Create destination file
 	dst, err := os.Create(filePath)
 	if err != nil {
-		return "", fmt.Errorf("unable to create destination file: %w", err)
+		return fmt.Errorf("unable to create object file: %w", err)
 	}
 	defer dst.Close()
 
-	// Copy file contents
-	if _, err = io.Copy(dst, src); err != nil {
-		return "", fmt.Errorf("unable to copy file: %w", err)
+	if _, err := io.Copy(dst, body); err != nil {
+		return fmt.Errorf("unable to write object: %w", err)
 	}
+	return nil
+}
 
-	// Return the URL to access the file
-	fileURL := fmt.Sprintf("%s/%s/%s", s.BaseURL, organizationID, fileName)
-	return fileURL, nil
+// GetObject implements StorageService interface for local storage
+func (s *LocalStorageService) GetObject(ctx context.Context, key string) (io.ReadCloser, int64, error) {
+	filePath, err := s.objectPath(key)
+	if err != nil {
+		return nil, 0, err
+	}
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, 0, fmt.Errorf("unable to open object: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, 0, fmt.Errorf("unable to stat object: %w", err)
+	}
+	return file, info.Size(), nil
 }
 
-// DeleteFaceImage implements StorageService interface for local storage
-func (s *LocalStorageService) DeleteFaceImage(ctx context.Context, fileURL string) error {
-	// Extract file path from URL
-	// This assumes the fileURL format is: baseURL/organizationID/fileName
-	relativePath := fileURL[len(s.BaseURL)+1:]
-	filePath := filepath.Join(s.StoragePath, relativePath)
+// DeleteObject implements StorageService interface for local storage
+func (s *LocalStorageService) DeleteObject(ctx context.Context, key string) error {
+	filePath, err := s.objectPath(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(filePath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("unable to delete object: %w", err)
+	}
+	return nil
+}
 
-	// Delete the file
-	if err := os.Remove(filePath); err != nil {
+// StatObject implements StorageService interface for local storage
+func (s *LocalStorageService) StatObject(ctx context.Context, key string) (*ObjectInfo, error) {
+	filePath, err := s.objectPath(key)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(filePath)
+	if err != nil {
 		if os.IsNotExist(err) {
-			// File already doesn't exist, not an error
+			return nil, ErrObjectNotFound
+		}
+		return nil, fmt.Errorf("unable to stat object: %w", err)
+	}
+	if info.IsDir() {
+		return nil, ErrObjectNotFound
+	}
+
+	contentType := mime.TypeByExtension(strings.ToLower(filepath.Ext(filePath)))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	return &ObjectInfo{
+		Size:        info.Size(),
+		ContentType: contentType,
+		ModTime:     info.ModTime(),
+		ETag:        fmt.Sprintf(`"%x-%x"`, info.ModTime().UnixNano(), info.Size()),
+	}, nil
+}
+
+// ListObjects implements StorageService interface for local storage by walking the directory of the prefix
+func (s *LocalStorageService) ListObjects(ctx context.Context, prefix string, fn func(key string, info ObjectInfo) error) error {
+	// Walk the deepest directory that contains every key with the prefix
+	dir := s.StoragePath
+	if i := strings.LastIndex(prefix, "/"); i >= 0 {
+		dir = filepath.Join(s.StoragePath, filepath.Clean("/"+prefix[:i]))
+	}
+	var fnErr error
+	err := filepath.WalkDir(dir, func(filePath string, entry os.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && filePath == dir {
+				return filepath.SkipAll
+			}
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if entry.IsDir() {
 			return nil
 		}
-		return fmt.Errorf("unable to delete file: %w", err)
+		rel, err := filepath.Rel(s.StoragePath, filePath)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		fnErr = fn(key, ObjectInfo{
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+			ETag:    fmt.Sprintf(`"%x-%x"`, info.ModTime().UnixNano(), info.Size()),
+		})
+		return fnErr
+	})
+	if fnErr != nil {
+		return fnErr
+	}
+	if err != nil {
+		return fmt.Errorf("unable to list objects: %w", err)
+	}
+	return nil
+}
+
+// canonicalKey reports whether key is already in the form objectPath resolves it to.
+// Only canonical keys are signed or verified, so a signature cannot be reused on another spelling of
+// the same file (such as a/../b) or on a key that resolves to a different file.
+func canonicalKey(key string) bool {
+	return key != "" && path.Clean("/"+key) == "/"+key
+}
+
+// signature computes the signature of a key and expiry time for local signed URLs
+func (s *LocalStorageService) signature(key, expires string) string {
+	mac := hmac.New(sha256.New, s.downloadKey)
+	mac.Write([]byte(key + "\n" + expires))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// SignedURL implements StorageService interface for local storage
+func (s *LocalStorageService) SignedURL(key string, ttl time.Duration) (string, error) {
+	if !canonicalKey(key) {
+		return "", fmt.Errorf("invalid object key %q", key)
+	}
+	expires := strconv.FormatInt(time.Now().Add(ttl).Unix(), 10)
+	query := url.Values{
+		"expires":   {expires},
+		"signature": {s.signature(key, expires)},
+	}
+	return fmt.Sprintf("%s/%s?%s", s.BaseURL, (&url.URL{Path: key}).EscapedPath(), query.Encode()), nil
+}
+
+// VerifySignature checks the expires and signature query parameters of a URL returned by SignedURL for key
+func (s *LocalStorageService) VerifySignature(key, expires, signature string) error {
+	expiresAt, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() > expiresAt || !canonicalKey(key) {
+		return ErrInvalidSignature
 	}
+	if !hmac.Equal([]byte(signature), []byte(s.signature(key, expires))) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// uploadSignature computes the signature of a PUT of size bytes with contentType to key for local signed upload URLs
+func (s *LocalStorageService) uploadSignature(key, contentType string, size int64, expires string) string {
+	mac := hmac.New(sha256.New, s.uploadKey)
+	mac.Write([]byte("PUT\n" + key + "\n" + expires + "\n" + contentType + "\n" + strconv.FormatInt(size, 10)))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
 
+// SignedUploadURL implements StorageService interface for local storage
+func (s *LocalStorageService) SignedUploadURL(key, contentType string, size int64, ttl time.Duration) (string, error) {
+	if !canonicalKey(key) {
+		return "", fmt.Errorf("invalid object key %q", key)
+	}
+	expires := strconv.FormatInt(time.Now().Add(ttl).Unix(), 10)
+	query := url.Values{
+		"expires":   {expires},
+		"signature": {s.uploadSignature(key, contentType, size, expires)},
+	}
+	return fmt.Sprintf("%s/%s?%s", s.BaseURL, (&url.URL{Path: key}).EscapedPath(), query.Encode()), nil
+}
+
+// VerifyUploadSignature checks the expires and signature query parameters of a URL returned by SignedUploadURL
+func (s *LocalStorageService) VerifyUploadSignature(key, contentType string, size int64, expires, signature string) error {
+	expiresAt, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() > expiresAt || !canonicalKey(key) {
+		return ErrInvalidSignature
+	}
+	if !hmac.Equal([]byte(signature), []byte(s.uploadSignature(key, contentType, size, expires))) {
+		return ErrInvalidSignature
+	}
 	return nil
 }
 
 // S3StorageService implements StorageService for S3 or compatible storage
 type S3StorageService struct {
-	Client    *s3.Client
+	Client     *s3.Client
+	Presign    *s3.PresignClient
 	BucketName string
-	BaseURL   string
 }
 
 // NewS3StorageService creates a new S3 storage service
@@ -156,55 +392,119 @@ func NewS3StorageService(cfg *appconfig.Config) (*S3StorageService, error) {
 
 	return &S3StorageService{
 		Client:     s3Client,
+		Presign:    s3.NewPresignClient(s3Client),
 		BucketName: cfg.S3Bucket,
-		BaseURL:    fmt.Sprintf("%s/%s", cfg.S3Endpoint, cfg.S3Bucket),
 	}, nil
 }
 
-// UploadFaceImage implements StorageService interface for S3 storage
-func (s *S3StorageService) UploadFaceImage(ctx context.Context, file *multipart.FileHeader, personHash string, organizationID string) (string, error) {
-	// Open uploaded file
-	src, err := file.Open()
+// PutObject implements StorageService interface for S3 storage
+func (s *S3StorageService) PutObject(ctx context.Context, key string, body io.Reader, size int64, contentType string) error {
+	_, err := s.Client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:        aws.String(s.BucketName),
+		Key:           aws.String(key),
+		Body:          body,
+		ContentLength: aws.Int64(size),
+		ContentType:   aws.String(contentType),
+	})
 	if err != nil {
-		return "", fmt.Errorf("unable to open uploaded file: %w", err)
+		return fmt.Errorf("unable to upload object to S3: %w", err)
 	}
-	defer src.Close()
-
-	// Generate object key
-	fileExt := filepath.Ext(file.Filename)
-	fileName := fmt.Sprintf("%s/%s_%s%s", organizationID, personHash, uuid.New().String(), fileExt)
+	return nil
+}
 
-	// Upload to S3
-	_, err = s.Client.PutObject(ctx, &s3.PutObjectInput{
-		Bucket:      aws.String(s.BucketName),
-		Key:         aws.String(fileName),
-		Body:        src,
-		ContentType: aws.String(file.Header.Get("Content-Type")),
+// GetObject implements StorageService interface for S3 storage
+func (s *S3StorageService) GetObject(ctx context.Context, key string) (io.ReadCloser, int64, error) {
+	output, err := s.Client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.BucketName),
+		Key:    aws.String(key),
 	})
 	if err != nil {
-		return "", fmt.Errorf("unable to upload file to S3: %w", err)
+		return nil, 0, fmt.Errorf("unable to get object from S3: %w", err)
 	}
-
-	// Return the URL to access the file
-	fileURL := fmt.Sprintf("%s/%s", s.BaseURL, fileName)
-	return fileURL, nil
+	return output.Body, aws.ToInt64(output.ContentLength), nil
 }
 
-// DeleteFaceImage implements StorageService interface for S3 storage
-func (s *S3StorageService) DeleteFaceImage(ctx context.Context, fileURL string) error {
-	// Extract object key from URL
-	// This assumes the fileURL format is: baseURL/objectKey
-	objectKey := fileURL[len(s.BaseURL)+1:]
-
-	// Delete from S3
+// DeleteObject implements StorageService interface for S3 storage
+func (s *S3StorageService) DeleteObject(ctx context.Context, key string) error {
 	_, err := s.Client.DeleteObject(ctx, &s3.DeleteObjectInput{
 		Bucket: aws.String(s.BucketName),
-		Key:    aws.String(objectKey),
+		Key:    aws.String(key),
 	})
 	if err != nil {
-		return fmt.Errorf("unable to delete file from S3: %w", err)
+		return fmt.Errorf("unable to delete object from S3: %w", err)
 	}
+	return nil
+}
 
+// StatObject implements StorageService interface for S3 storage
+func (s *S3StorageService) StatObject(ctx context.Context, key string) (*ObjectInfo, error) {
+	output, err := s.Client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s.BucketName),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		var notFound *types.NotFound
+		if errors.As(err, &notFound) {
+			return nil, ErrObjectNotFound
+		}
+		return nil, fmt.Errorf("unable to stat object in S3: %w", err)
+	}
+	return &ObjectInfo{
+		Size:        aws.ToInt64(output.ContentLength),
+		ContentType: aws.ToString(output.ContentType),
+		ModTime:     aws.ToTime(output.LastModified),
+		ETag:        aws.ToString(output.ETag),
+	}, nil
+}
+
+// SignedURL implements StorageService interface for S3 storage with a presigned GET request
+func (s *S3StorageService) SignedURL(key string, ttl time.Duration) (string, error) {
+	request, err := s.Presign.PresignGetObject(context.Background(), &s3.GetObjectInput{
+		Bucket: aws.String(s.BucketName),
+		Key:    aws.String(key),
+	}, s3.WithPresignExpires(ttl))
+	if err != nil {
+		return "", fmt.Errorf("unable to presign S3 object: %w", err)
+	}
+	return request.URL, nil
+}
+
+// SignedUploadURL implements StorageService interface for S3 storage with a presigned PUT request.
+// Content-Type and Content-Length are signed, so S3 rejects uploads of another type or size.
+func (s *S3StorageService) SignedUploadURL(key, contentType string, size int64, ttl time.Duration) (string, error) {
+	request, err := s.Presign.PresignPutObject(context.Background(), &s3.PutObjectInput{
+		Bucket:        aws.String(s.BucketName),
+		Key:           aws.String(key),
+		ContentType:   aws.String(contentType),
+		ContentLength: aws.Int64(size),
+	}, s3.WithPresignExpires(ttl))
+	if err != nil {
+		return "", fmt.Errorf("unable to presign S3 upload: %w", err)
+	}
+	return request.URL, nil
+}
+
+// ListObjects implements StorageService interface for S3 storage, one page of up to 1000 objects at a time
+func (s *S3StorageService) ListObjects(ctx context.Context, prefix string, fn func(key string, info ObjectInfo) error) error {
+	paginator := s3.NewListObjectsV2Paginator(s.Client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.BucketName),
+		Prefix: aws.String(prefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return fmt.Errorf("unable to list objects in S3: %w", err)
+		}
+		for _, object := range page.Contents {
+			if err := fn(aws.ToString(object.Key), ObjectInfo{
+				Size:    aws.ToInt64(object.Size),
+				ModTime: aws.ToTime(object.LastModified),
+				ETag:    aws.ToString(object.ETag),
+			}); err != nil {
+				return err
+			}
+		}
+	}
 	return nil
 }
 
@@ -214,8 +514,11 @@ func NewStorageService(cfg *appconfig.Config) (StorageService, error) {
 		return NewS3StorageService(cfg)
 	}
 	
-	// Default to local storage
-	storagePath := "./storage/faces"
-	baseURL := fmt.Sprintf("http://localhost:%s/api/faces", cfg.Port)
-	return NewLocalStorageService(storagePath, baseURL)
+	// Default to local storage, served by the API under /api/files.
+	// Its signed URLs are the only credential for reading and writing files, so the key must be secret and not shared with JWTs.
+	if len(cfg.URLSigningKey) < minSigningKeyLength || publicSigningKeys[cfg.URLSigningKey] || cfg.URLSigningKey == cfg.JWTSecret {
+		return nil, ErrWeakSigningKey
+	}
+	baseURL := strings.TrimRight(cfg.PublicBaseURL, "/") + "/api/files"
+	return NewLocalStorageService(cfg.LocalStoragePath, baseURL, []byte(cfg.URLSigningKey))
 }
\ No newline at end of file
@@ -0,0 +1,258 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	appconfig "github.com/bemindtech/bmt-manta-dashboard-service/config"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestLocalStorageService_SignedURL checks that download signatures are bound to the key, the expiry time and the signing key
+func TestLocalStorageService_SignedURL(t *testing.T) {
+	store, err := NewLocalStorageService(t.TempDir(), "http://images.example.com/api/files", []byte("test-signing-key"))
+	require.NoError(t, err)
+
+	signedURL, err := store.SignedURL("org-1/face.jpg", time.Hour)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(signedURL, "http://images.example.com/api/files/org-1/face.jpg?"))
+	expires, signature := signedQuery(t, signedURL)
+	assert.NoError(t, store.VerifySignature("org-1/face.jpg", expires, signature))
+
+	// Expired URL, and an expiry time moved without signing it again
+	expiredURL, err := store.SignedURL("org-1/face.jpg", -time.Minute)
+	require.NoError(t, err)
+	expiredExpires, expiredSignature := signedQuery(t, expiredURL)
+	assert.ErrorIs(t, store.VerifySignature("org-1/face.jpg", expiredExpires, expiredSignature), ErrInvalidSignature)
+	assert.ErrorIs(t, store.VerifySignature("org-1/face.jpg", expires+"0", signature), ErrInvalidSignature)
+	assert.ErrorIs(t, store.VerifySignature("org-1/face.jpg", "never", signature), ErrInvalidSignature)
+
+	// Tampered or missing signature
+	tampered := []byte(signature)
+	tampered[0] ^= 1
+	assert.ErrorIs(t, store.VerifySignature("org-1/face.jpg", expires, string(tampered)), ErrInvalidSignature)
+	assert.ErrorIs(t, store.VerifySignature("org-1/face.jpg", expires, ""), ErrInvalidSignature)
+
+	// The signature of one key is not valid for another key, including other spellings of the same file
+	for _, key := range []string{
+		"org-2/face.jpg",
+		"org-1/face.jpg/",
+		"/org-1/face.jpg",
+		"org-1//face.jpg",
+		"org-1/./face.jpg",
+		"org-1/thumbnails/../face.jpg",
+		"org-2/../org-1/face.jpg",
+		"../org-1/face.jpg",
+	} {
+		assert.ErrorIs(t, store.VerifySignature(key, expires, signature), ErrInvalidSignature, key)
+	}
+
+	// Keys that objectPath would clean are never signed, so their signatures cannot be replayed on the cleaned key
+	for _, key := range []string{"org-2/../org-1/face.jpg", "org-1//face.jpg", "/org-1/face.jpg", ""} {
+		_, err := store.SignedURL(key, time.Hour)
+		assert.Error(t, err, key)
+		_, err = store.SignedUploadURL(key, "image/jpeg", 100, time.Hour)
+		assert.Error(t, err, key)
+	}
+
+	// A signature made with another signing key is rejected
+	other, err := NewLocalStorageService(t.TempDir(), "http://images.example.com/api/files", []byte("other-signing-key"))
+	require.NoError(t, err)
+	assert.ErrorIs(t, other.VerifySignature("org-1/face.jpg", expires, signature), ErrInvalidSignature)
+	otherURL, err := other.SignedURL("org-1/face.jpg", time.Hour)
+	require.NoError(t, err)
+	otherExpires, otherSignature := signedQuery(t, otherURL)
+	assert.ErrorIs(t, store.VerifySignature("org-1/face.jpg", otherExpires, otherSignature), ErrInvalidSignature)
+}
+
+// TestLocalStorageService_SignedUploadURL checks that upload signatures are bound to the key, content type and size
+func TestLocalStorageService_SignedUploadURL(t *testing.T) {
+	store, err := NewLocalStorageService(t.TempDir(), "http://images.example.com/api/files", []byte("test-signing-key"))
+	require.NoError(t, err)
+
+	uploadURL, err := store.SignedUploadURL("org-1/uploads/intent-1", "image/jpeg", 100, time.Hour)
+	require.NoError(t, err)
+	expires, signature := signedQuery(t, uploadURL)
+
+	assert.NoError(t, store.VerifyUploadSignature("org-1/uploads/intent-1", "image/jpeg", 100, expires, signature))
+	assert.ErrorIs(t, store.VerifyUploadSignature("org-1/uploads/intent-2", "image/jpeg", 100, expires, signature), ErrInvalidSignature)
+	assert.ErrorIs(t, store.VerifyUploadSignature("org-1/uploads/intent-1", "image/png", 100, expires, signature), ErrInvalidSignature)
+	assert.ErrorIs(t, store.VerifyUploadSignature("org-1/uploads/intent-1", "image/jpeg", 101, expires, signature), ErrInvalidSignature)
+
+	// An upload signature is not a download signature and the other way round
+	assert.ErrorIs(t, store.VerifySignature("org-1/uploads/intent-1", expires, signature), ErrInvalidSignature)
+	downloadURL, err := store.SignedURL("org-1/uploads/intent-1", time.Hour)
+	require.NoError(t, err)
+	expires, signature = signedQuery(t, downloadURL)
+	assert.ErrorIs(t, store.VerifyUploadSignature("org-1/uploads/intent-1", "image/jpeg", 100, expires, signature), ErrInvalidSignature)
+
+	// Expired URL
+	uploadURL, err = store.SignedUploadURL("org-1/uploads/intent-1", "image/jpeg", 100, -time.Minute)
+	require.NoError(t, err)
+	expires, signature = signedQuery(t, uploadURL)
+	assert.ErrorIs(t, store.VerifyUploadSignature("org-1/uploads/intent-1", "image/jpeg", 100, expires, signature), ErrInvalidSignature)
+
+	// Other spellings of the key and another signing key
+	uploadURL, err = store.SignedUploadURL("org-1/uploads/intent-1", "image/jpeg", 100, time.Hour)
+	require.NoError(t, err)
+	expires, signature = signedQuery(t, uploadURL)
+	for _, key := range []string{"org-1/uploads/../uploads/intent-1", "org-1//uploads/intent-1", "org-1/uploads/intent-1/../../../org-2/face.jpg"} {
+		assert.ErrorIs(t, store.VerifyUploadSignature(key, "image/jpeg", 100, expires, signature), ErrInvalidSignature, key)
+	}
+	other, err := NewLocalStorageService(t.TempDir(), "http://images.example.com/api/files", []byte("other-signing-key"))
+	require.NoError(t, err)
+	assert.ErrorIs(t, other.VerifyUploadSignature("org-1/uploads/intent-1", "image/jpeg", 100, expires, signature), ErrInvalidSignature)
+}
+
+// TestNewStorageService_SigningKey checks that local storage is not created with a missing, public or shared URL signing key
+func TestNewStorageService_SigningKey(t *testing.T) {
+	const jwtSecret = "a-jwt-secret-that-is-long-enough-to-pass"
+	for name, key := range map[string]string{
+		"unset":      "",
+		"default":    "default-jwt-secret",
+		"example":    "your-url-signing-key",
+		"too short":  "short-key",
+		"jwt secret": jwtSecret,
+	} {
+		_, err := NewStorageService(&appconfig.Config{LocalStoragePath: t.TempDir(), URLSigningKey: key, JWTSecret: jwtSecret})
+		assert.ErrorIs(t, err, ErrWeakSigningKey, name)
+	}
+
+	store, err := NewStorageService(&appconfig.Config{
+		LocalStoragePath: t.TempDir(),
+		PublicBaseURL:    "http://images.example.com/",
+		URLSigningKey:    "0123456789abcdef0123456789abcdef",
+		JWTSecret:        jwtSecret,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "http://images.example.com/api/files", store.(*LocalStorageService).BaseURL)
+}
+
+// TestLocalStorageService_ListObjects checks that listing returns only the keys under the prefix
+func TestLocalStorageService_ListObjects(t *testing.T) {
+	store, err := NewLocalStorageService(t.TempDir(), "http://images.example.com/api/files", []byte("test-signing-key"))
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	for _, key := range []string{"org-1/p1_a.jpg", "org-1/thumbnails/a_48.jpg", "org-10/p2_b.jpg", "exports/org-1/job/logs.csv"} {
+		require.NoError(t, store.PutObject(ctx, key, bytes.NewReader([]byte("data")), 4, "image/jpeg"))
+	}
+
+	list := func(prefix string) map[string]int64 {
+		sizes := make(map[string]int64)
+		require.NoError(t, store.ListObjects(ctx, prefix, func(key string, info ObjectInfo) error {
+			assert.False(t, info.ModTime.IsZero())
+			assert.NotEmpty(t, info.ETag)
+			sizes[key] = info.Size
+			return nil
+		}))
+		return sizes
+	}
+	assert.Equal(t, map[string]int64{"org-1/p1_a.jpg": 4, "org-1/thumbnails/a_48.jpg": 4}, list("org-1/"))
+	assert.Equal(t, map[string]int64{"org-1/thumbnails/a_48.jpg": 4}, list("org-1/thumbnails/a"))
+	assert.Len(t, list(""), 4)
+	assert.Empty(t, list("org-2/"))
+
+	// An error from fn stops the listing and is returned as is
+	stop := errors.New("stop")
+	calls := 0
+	err = store.ListObjects(ctx, "org-1/", func(key string, info ObjectInfo) error {
+		calls++
+		return stop
+	})
+	assert.Equal(t, stop, err)
+	assert.Equal(t, 1, calls)
+}
+
+// TestS3StorageService_SignedUploadURL uploads through a presigned PUT URL on MinIO or another S3 compatible storage.
+// Requires TEST_S3_ENDPOINT, TEST_S3_ACCESS_KEY and TEST_S3_SECRET_KEY, for example with
+// docker run -p 9000:9000 minio/minio server /data and TEST_S3_ENDPOINT=http://localhost:9000
+func TestS3StorageService_SignedUploadURL(t *testing.T) {
+	endpoint := os.Getenv("TEST_S3_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("TEST_S3_ENDPOINT not set")
+	}
+	bucket := os.Getenv("TEST_S3_BUCKET")
+	if bucket == "" {
+		bucket = "manta-test"
+	}
+
+	store, err := NewS3StorageService(&appconfig.Config{
+		S3Endpoint:     endpoint,
+		S3Region:       "us-east-1",
+		S3Bucket:       bucket,
+		S3AccessKey:    os.Getenv("TEST_S3_ACCESS_KEY"),
+		S3SecretKey:    os.Getenv("TEST_S3_SECRET_KEY"),
+		S3UsePathStyle: true,
+	})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	key := "org-1/uploads/" + uuid.New().String()
+	t.Cleanup(func() { store.DeleteObject(ctx, key) })
+	content := []byte("uploaded image")
+
+	uploadURL, err := store.SignedUploadURL(key, "image/jpeg", int64(len(content)), time.Hour)
+	require.NoError(t, err)
+
+	put := func(contentType string, body []byte) int {
+		req, err := http.NewRequest(http.MethodPut, uploadURL, bytes.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", contentType)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	// The signature covers Content-Type and Content-Length
+	assert.Equal(t, http.StatusForbidden, put("image/png", content))
+	assert.Equal(t, http.StatusForbidden, put("image/jpeg", append(content, '!')))
+	_, err = store.StatObject(ctx, key)
+	assert.ErrorIs(t, err, ErrObjectNotFound)
+
+	assert.Equal(t, http.StatusOK, put("image/jpeg", content))
+	info, err := store.StatObject(ctx, key)
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(content)), info.Size)
+	assert.Equal(t, "image/jpeg", info.ContentType)
+
+	// The uploaded object can be read back through a signed GET URL
+	downloadURL, err := store.SignedURL(key, time.Minute)
+	require.NoError(t, err)
+	resp, err := http.Get(downloadURL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, content, body)
+
+	// The uploaded object is listed under its prefix
+	listed := map[string]int64{}
+	require.NoError(t, store.ListObjects(ctx, key, func(key string, info ObjectInfo) error {
+		listed[key] = info.Size
+		return nil
+	}))
+	assert.Equal(t, map[string]int64{key: int64(len(content))}, listed)
+
+	require.NoError(t, store.DeleteObject(ctx, key))
+	_, err = store.StatObject(ctx, key)
+	assert.ErrorIs(t, err, ErrObjectNotFound)
+}
+
+// signedQuery returns the expires and signature query parameters of a signed URL
+func signedQuery(t *testing.T, signedURL string) (string, string) {
+	req, err := http.NewRequest(http.MethodGet, signedURL, nil)
+	require.NoError(t, err)
+	query := req.URL.Query()
+	return query.Get("expires"), query.Get("signature")
+}